@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/app"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"go.uber.org/fx"
+)
+
+// adminClient performs the admin commands, either on the database through the services or through the
+// HTTP API of a running server.
+type adminClient interface {
+	// GetUser looks a user up by ID or, with direct database access, by email.
+	GetUser(ctx context.Context, ref string) (*aggregate.UserDto, error)
+	EndSessions(ctx context.Context, userID string) error
+	AssignRole(ctx context.Context, req aggregate.AssignRoleToUserReq) (*aggregate.UserRoleResp, error)
+	GrantRelation(ctx context.Context, req aggregate.GrantRelationReq) (*aggregate.RelationTupleResp, error)
+	CheckRelation(ctx context.Context, req aggregate.CheckRelationReq) (*aggregate.CheckRelationResp, error)
+	Close()
+}
+
+// withAdminClient opens a client with connect, runs fn with it and closes it.
+func withAdminClient(ctx context.Context, connect func() (adminClient, error), fn func(context.Context, adminClient) error) error {
+	client, err := connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return fn(ctx, client)
+}
+
+// dbClient runs the commands through the services, wired as in the server, so caches are invalidated and
+// events published the same way.
+type dbClient struct {
+	app         *fx.App
+	userRepo    repository.IUserRepository
+	userSvc     service.IUserSvc
+	roleSvc     service.IRoleSvc
+	relationSvc service.IRelationSvc
+}
+
+func newDBClient() (*dbClient, error) {
+	client := &dbClient{}
+	client.app = fx.New(
+		fx.NopLogger,
+		app.Core,
+		app.Services,
+		app.Repositories,
+		fx.Invoke(service.RegisterConnectionHooks),
+		fx.Invoke(service.RegisterEventPublisherHooks),
+		// Close waits for the permission cache invalidations a command leaves behind.
+		fx.Invoke(service.RegisterBackgroundHooks),
+		fx.Populate(&client.userRepo, &client.userSvc, &client.roleSvc, &client.relationSvc),
+	)
+	if err := client.app.Err(); err != nil {
+		return nil, err
+	}
+	if err := client.app.Start(context.Background()); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (c *dbClient) Close() {
+	_ = c.app.Stop(context.Background())
+}
+
+// asOperator runs the services as a super admin, which is what the API requires for these commands.
+func (c *dbClient) asOperator(ctx context.Context) context.Context {
+	return context.WithValue(ctx, constant.JWT_PAYLOAD_CONTEXT_KEY, &jwt.Payload{UserID: "dreon-cli:" + operatorName(), IsSuperAdmin: true})
+}
+
+func (c *dbClient) GetUser(ctx context.Context, ref string) (*aggregate.UserDto, error) {
+	if strings.Contains(ref, "@") {
+		user, err := c.userRepo.FindByEmail(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("no user with email %s", ref)
+		}
+		ref = user.ID
+	}
+	return c.userSvc.GetByID(c.asOperator(ctx), ref)
+}
+
+func (c *dbClient) EndSessions(ctx context.Context, userID string) error {
+	return c.userSvc.EndSessions(c.asOperator(ctx), userID)
+}
+
+func (c *dbClient) AssignRole(ctx context.Context, req aggregate.AssignRoleToUserReq) (*aggregate.UserRoleResp, error) {
+	return c.roleSvc.AssignRoleToUser(c.asOperator(ctx), req)
+}
+
+func (c *dbClient) GrantRelation(ctx context.Context, req aggregate.GrantRelationReq) (*aggregate.RelationTupleResp, error) {
+	return c.relationSvc.GrantRelation(c.asOperator(ctx), req)
+}
+
+func (c *dbClient) CheckRelation(ctx context.Context, req aggregate.CheckRelationReq) (*aggregate.CheckRelationResp, error) {
+	return c.relationSvc.CheckRelation(c.asOperator(ctx), req)
+}
+
+// apiClient runs the commands against the /api/v1 routes of a running server.
+type apiClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func (c *apiClient) Close() {}
+
+func (c *apiClient) GetUser(ctx context.Context, ref string) (*aggregate.UserDto, error) {
+	if strings.Contains(ref, "@") {
+		return nil, errors.New("the API looks users up by ID only; omit --api to look one up by email")
+	}
+	var user aggregate.UserDto
+	return &user, c.do(ctx, http.MethodGet, "/api/v1/users/"+url.PathEscape(ref), nil, &user)
+}
+
+func (c *apiClient) EndSessions(ctx context.Context, userID string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/users/"+url.PathEscape(userID)+"/sessions", nil, nil)
+}
+
+func (c *apiClient) AssignRole(ctx context.Context, req aggregate.AssignRoleToUserReq) (*aggregate.UserRoleResp, error) {
+	var assignment aggregate.UserRoleResp
+	return &assignment, c.do(ctx, http.MethodPost, "/api/v1/roles/assign", req, &assignment)
+}
+
+func (c *apiClient) GrantRelation(ctx context.Context, req aggregate.GrantRelationReq) (*aggregate.RelationTupleResp, error) {
+	var tuple aggregate.RelationTupleResp
+	return &tuple, c.do(ctx, http.MethodPost, "/api/v1/relations/grant", req, &tuple)
+}
+
+func (c *apiClient) CheckRelation(ctx context.Context, req aggregate.CheckRelationReq) (*aggregate.CheckRelationResp, error) {
+	var result aggregate.CheckRelationResp
+	return &result, c.do(ctx, http.MethodPost, "/api/v1/relations/check", req, &result)
+}
+
+// do sends body as JSON and decodes the data of the response envelope into out.
+func (c *apiClient) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+		Errors  []struct {
+			Field   string `json:"field"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("%s %s: status %d: %w", method, path, resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := envelope.Message
+		for _, e := range envelope.Errors {
+			msg += fmt.Sprintf("; %s %s", e.Field, e.Message)
+		}
+		return fmt.Errorf("%s %s: status %d: %s", method, path, resp.StatusCode, msg)
+	}
+	if out == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+// operatorName identifies who ran the command for the audit log.
+func operatorName() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, _ := os.Hostname()
+	return name + "@" + host
+}
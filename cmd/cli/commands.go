@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/spf13/cobra"
+)
+
+func newUserCmd(connect func() (adminClient, error)) *cobra.Command {
+	user := &cobra.Command{Use: "user", Short: "Look up users"}
+	user.AddCommand(&cobra.Command{
+		Use:   "get <id|email>",
+		Short: "Look up a user by ID or, on the database, by email",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAdminClient(cmd.Context(), connect, func(ctx context.Context, client adminClient) error {
+				user, err := client.GetUser(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				return printJSON(user)
+			})
+		},
+	})
+	return user
+}
+
+func newSessionCmd(connect func() (adminClient, error)) *cobra.Command {
+	var userID string
+	revoke := &cobra.Command{
+		Use:   "revoke --user ID",
+		Short: "Sign a user out everywhere",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAdminClient(cmd.Context(), connect, func(ctx context.Context, client adminClient) error {
+				if err := client.EndSessions(ctx, userID); err != nil {
+					return err
+				}
+				fmt.Printf("ended all sessions of user %s\n", userID)
+				return nil
+			})
+		},
+	}
+	revoke.Flags().StringVar(&userID, "user", "", "ID of the user to sign out everywhere")
+	_ = revoke.MarkFlagRequired("user")
+
+	session := &cobra.Command{Use: "session", Short: "Revoke sessions"}
+	session.AddCommand(revoke)
+	return session
+}
+
+func newRoleCmd(connect func() (adminClient, error)) *cobra.Command {
+	var userID, roleID, projectID string
+	var ttl time.Duration
+	assign := &cobra.Command{
+		Use:   "assign --user ID --role ID [--project ID] [--ttl DURATION]",
+		Short: "Assign a role to a user",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := aggregate.AssignRoleToUserReq{UserID: userID, RoleID: roleID}
+			if projectID != "" {
+				req.ProjectID = &projectID
+			}
+			if ttl > 0 {
+				expiresAt := time.Now().Add(ttl)
+				req.ExpiresAt = &expiresAt
+			}
+			return withAdminClient(cmd.Context(), connect, func(ctx context.Context, client adminClient) error {
+				assignment, err := client.AssignRole(ctx, req)
+				if err != nil {
+					return err
+				}
+				return printJSON(assignment)
+			})
+		},
+	}
+	assign.Flags().StringVar(&userID, "user", "", "user ID")
+	assign.Flags().StringVar(&roleID, "role", "", "role ID")
+	assign.Flags().StringVar(&projectID, "project", "", "project ID; empty for a system role")
+	assign.Flags().DurationVar(&ttl, "ttl", 0, "let the assignment expire after this long, e.g. 72h; 0 for a permanent one")
+	_ = assign.MarkFlagRequired("user")
+	_ = assign.MarkFlagRequired("role")
+
+	role := &cobra.Command{Use: "role", Short: "Assign roles"}
+	role.AddCommand(assign)
+	return role
+}
+
+func newRelationCmd(connect func() (adminClient, error)) *cobra.Command {
+	const tupleArg = "namespace:object#relation@subjectNamespace:subject[#subjectRelation]"
+	grant := &cobra.Command{
+		Use:   "grant " + tupleArg,
+		Short: "Grant a relation tuple",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tuple, err := parseTuple(args[0])
+			if err != nil {
+				return err
+			}
+			return withAdminClient(cmd.Context(), connect, func(ctx context.Context, client adminClient) error {
+				granted, err := client.GrantRelation(ctx, tuple)
+				if err != nil {
+					return err
+				}
+				return printJSON(granted)
+			})
+		},
+	}
+	check := &cobra.Command{
+		Use:   "check namespace:object#relation@subjectNamespace:subject",
+		Short: "Check whether a subject has a relation to an object",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tuple, err := parseTuple(args[0])
+			if err != nil {
+				return err
+			}
+			if tuple.SubjectRelation != "" {
+				return errors.New("checks take a plain subject, without #relation")
+			}
+			return withAdminClient(cmd.Context(), connect, func(ctx context.Context, client adminClient) error {
+				result, err := client.CheckRelation(ctx, aggregate.CheckRelationReq{
+					Namespace:        tuple.Namespace,
+					ObjectID:         tuple.ObjectID,
+					Relation:         tuple.Relation,
+					SubjectNamespace: tuple.SubjectNamespace,
+					SubjectObjectID:  tuple.SubjectObjectID,
+				})
+				if err != nil {
+					return err
+				}
+				return printJSON(result)
+			})
+		},
+	}
+
+	relation := &cobra.Command{Use: "relation", Short: "Grant or check relation tuples"}
+	relation.AddCommand(grant, check)
+	return relation
+}
+
+func newTokenCmd() *cobra.Command {
+	token := &cobra.Command{Use: "token", Short: "Inspect tokens"}
+	token.AddCommand(&cobra.Command{
+		Use:   "decode <token|->",
+		Short: "Decode a JWT's header and claims, without verifying the signature",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			raw := args[0]
+			if raw == "-" {
+				data, err := io.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				raw = string(data)
+			}
+			return decodeToken(raw)
+		},
+	})
+	return token
+}
+
+// decodeToken prints the header and claims of a JWT without verifying it.
+func decodeToken(raw string) error {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(raw), "Bearer "), ".")
+	if len(parts) == 5 {
+		// A JWE: only its protected header is readable without the encryption key.
+		data, err := base64.RawURLEncoding.DecodeString(parts[0])
+		if err != nil {
+			return fmt.Errorf("decode JWE header: %w", err)
+		}
+		var header map[string]any
+		if err := json.Unmarshal(data, &header); err != nil {
+			return fmt.Errorf("decode JWE header: %w", err)
+		}
+		fmt.Fprintln(os.Stderr, "The token is encrypted; its claims cannot be shown.")
+		return printJSON(map[string]any{"header": header, "encrypted": true})
+	}
+	if len(parts) != 3 {
+		return errors.New("not a JWT: expected three dot-separated parts")
+	}
+	var header, claims map[string]any
+	for i, dst := range []*map[string]any{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			return fmt.Errorf("decode JWT part %d: %w", i+1, err)
+		}
+		if err := json.Unmarshal(data, dst); err != nil {
+			return fmt.Errorf("decode JWT part %d: %w", i+1, err)
+		}
+	}
+	out := map[string]any{"header": header, "claims": claims}
+	if exp, ok := claims["exp"].(float64); ok {
+		expiresAt := time.Unix(int64(exp), 0).UTC()
+		out["expiresAt"] = expiresAt
+		out["expired"] = time.Now().After(expiresAt)
+	}
+	fmt.Fprintln(os.Stderr, "The signature was not verified.")
+	return printJSON(out)
+}
+
+// parseTuple reads a relation tuple in the namespace:object#relation@subjectNamespace:subject[#subjectRelation]
+// form the server logs tuples in.
+func parseTuple(s string) (aggregate.GrantRelationReq, error) {
+	var req aggregate.GrantRelationReq
+	object, subject, ok := strings.Cut(s, "@")
+	if !ok {
+		return req, fmt.Errorf("tuple %q has no @subject", s)
+	}
+	object, req.Relation, ok = strings.Cut(object, "#")
+	if !ok || req.Relation == "" {
+		return req, fmt.Errorf("tuple %q has no #relation", s)
+	}
+	if req.Namespace, req.ObjectID, ok = strings.Cut(object, ":"); !ok || req.Namespace == "" || req.ObjectID == "" {
+		return req, fmt.Errorf("tuple %q needs namespace:object", s)
+	}
+	subject, req.SubjectRelation, _ = strings.Cut(subject, "#")
+	if req.SubjectNamespace, req.SubjectObjectID, ok = strings.Cut(subject, ":"); !ok || req.SubjectNamespace == "" || req.SubjectObjectID == "" {
+		return req, fmt.Errorf("tuple %q needs subjectNamespace:subject", s)
+	}
+	return req, nil
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,75 @@
+// Command dreon-cli runs day-to-day support tasks: looking users up, revoking sessions, assigning roles,
+// granting and checking relations and decoding tokens. The commands use the database unless --api (or
+// DREON_API_URL) names a running server, in which case they go through its HTTP API with a super-admin access
+// token.
+//
+// Usage:
+//
+//	dreon-cli user get [--api URL] <id|email>
+//	dreon-cli session revoke [--api URL] --user ID
+//	dreon-cli role assign [--api URL] --user ID --role ID [--project ID] [--ttl DURATION]
+//	dreon-cli relation <grant|check> [--api URL] namespace:object#relation@subjectNamespace:subject[#subjectRelation]
+//	dreon-cli token decode <token|->
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Environment variables for talking to a running server instead of the database.
+const (
+	apiURLEnv   = "DREON_API_URL"
+	apiTokenEnv = "DREON_API_TOKEN"
+)
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:   "dreon-cli",
+		Short: "Support tasks against a dreon-auth database or server",
+		Long: `Support tasks against a dreon-auth database or server.
+
+The user, session, role and relation commands use the database unless --api (or DREON_API_URL) names a
+running server, in which case they need a super-admin access token in --token or DREON_API_TOKEN.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	apiURL := root.PersistentFlags().String("api", os.Getenv(apiURLEnv), "base URL of a running server, e.g. https://auth.example.com; without it the database is used ("+apiURLEnv+")")
+	token := root.PersistentFlags().String("token", "", "super-admin access token for --api (defaults to "+apiTokenEnv+")")
+
+	// connect opens the client the flags choose, once they are parsed.
+	connect := func() (adminClient, error) {
+		if *apiURL == "" {
+			return newDBClient()
+		}
+		if *token == "" {
+			*token = os.Getenv(apiTokenEnv)
+		}
+		if *token == "" {
+			return nil, errors.New("--api needs a super-admin access token in --token or " + apiTokenEnv)
+		}
+		return &apiClient{baseURL: strings.TrimRight(*apiURL, "/"), token: *token, http: &http.Client{Timeout: 30 * time.Second}}, nil
+	}
+
+	root.AddCommand(
+		newUserCmd(connect),
+		newSessionCmd(connect),
+		newRoleCmd(connect),
+		newRelationCmd(connect),
+		newTokenCmd(),
+	)
+	return root
+}
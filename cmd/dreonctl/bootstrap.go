@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/seed"
+	"github.com/hiamthach108/dreon-auth/internal/shared/permission"
+	"github.com/hiamthach108/dreon-auth/pkg/database"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// bootstrapPasswordEnv lets scripts pass the password without putting it on the command line.
+const bootstrapPasswordEnv = "BOOTSTRAP_PASSWORD"
+
+func bootstrap(args []string) error {
+	fs := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	email := fs.String("email", "", "email of the first super admin")
+	password := fs.String("password", "", "password of the first super admin; read from "+bootstrapPasswordEnv+" or prompted for when empty")
+	name := fs.String("name", "", "display name of the super admin (defaults to the email)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("-email is required")
+	}
+	if *password == "" {
+		*password = os.Getenv(bootstrapPasswordEnv)
+	}
+	if *password == "" {
+		var err error
+		if *password, err = prompt(bufio.NewReader(os.Stdin), "Password for "+*email+": "); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := config.NewAppConfig()
+	if err != nil {
+		return err
+	}
+	log, err := logger.NewLogger(cfg)
+	if err != nil {
+		return err
+	}
+	registry, err := permission.NewRegistryFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	db, err := database.NewDbClient(cfg, log)
+	if err != nil {
+		return err
+	}
+
+	bootstrapper := seed.NewBootstrapper(
+		log,
+		registry,
+		repository.NewSuperAdminRepository(db),
+		repository.NewPermissionRepository(db),
+		repository.NewRoleRepository(db),
+	)
+	result, err := bootstrapper.Run(context.Background(), seed.BootstrapReq{Email: *email, Password: *password, Name: *name})
+	if err != nil {
+		return err
+	}
+	if result.SuperAdmin {
+		fmt.Printf("created super admin %s\n", *email)
+	} else {
+		fmt.Printf("super admin %s already exists; its password was not changed\n", *email)
+	}
+	fmt.Printf("created %d permissions and %d system roles\n", result.Permissions, result.Roles)
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hiamthach108/dreon-auth/pkg/breach"
+)
+
+// breachBloom builds the offline filter for PASSWORD_BREACH_BLOOM_FILE from a Pwned Passwords SHA-1
+// download, one "HASH:count" line each.
+func breachBloom(args []string) error {
+	fs := flag.NewFlagSet("breach-bloom", flag.ExitOnError)
+	in := fs.String("in", "", "Pwned Passwords SHA-1 file, or - for stdin")
+	out := fs.String("out", "", "filter file to write")
+	fpRate := fs.Float64("fp", 0.001, "false positive rate; lower rates make a larger file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" || *out == "" {
+		return errors.New("usage: dreonctl breach-bloom -in FILE -out FILE [-fp RATE]")
+	}
+
+	// The filter is sized from the line count, so the input is read twice; stdin is buffered to a temp file.
+	src, cleanup, err := openSeekable(*in)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	entries, err := countLines(src)
+	if err != nil {
+		return err
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	filter, err := breach.NewBloomFilter(entries, *fpRate)
+	if err != nil {
+		return err
+	}
+	added, err := filter.AddHashes(src)
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	size, err := filter.WriteTo(dst)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d hashes to %s (%d MiB, %g false positive rate)\n", added, *out, size>>20, *fpRate)
+	return nil
+}
+
+func openSeekable(path string) (*os.File, func(), error) {
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, func() { _ = f.Close() }, nil
+	}
+	tmp, err := os.CreateTemp("", "dreonctl-pwned-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { _ = tmp.Close(); _ = os.Remove(tmp.Name()) }
+	if _, err := io.Copy(tmp, os.Stdin); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return tmp, cleanup, nil
+}
+
+func countLines(r io.Reader) (uint64, error) {
+	scanner := bufio.NewScanner(r)
+	var n uint64
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			n++
+		}
+	}
+	return n, scanner.Err()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/database"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+func breakGlass(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: dreonctl break-glass <provision|activate> -label NAME")
+	}
+	switch args[0] {
+	case "provision":
+		return breakGlassProvision(args[1:])
+	case "activate":
+		return breakGlassActivate(args[1:])
+	default:
+		return fmt.Errorf("unknown break-glass command %q", args[0])
+	}
+}
+
+func breakGlassProvision(args []string) error {
+	fs := flag.NewFlagSet("break-glass provision", flag.ExitOnError)
+	label := fs.String("label", "", "credential label, e.g. the vault or envelope it will be sealed in")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	svc, err := newBreakGlassService()
+	if err != nil {
+		return err
+	}
+	secret, err := svc.Provision(context.Background(), *label)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Break-glass credential %q provisioned. Seal this secret offline now; it will not be shown again:\n\n  %s\n\n", *label, secret)
+	fmt.Println("Any previous secret for this label no longer works.")
+	return nil
+}
+
+// breakGlassActivate walks the operator through three confirmations before granting access:
+// a written justification, the sealed secret, and typing the label back.
+func breakGlassActivate(args []string) error {
+	fs := flag.NewFlagSet("break-glass activate", flag.ExitOnError)
+	label := fs.String("label", "", "credential label")
+	ttl := fs.Duration("ttl", constant.BreakGlassDefaultSessionTTL, "lifetime of the emergency session (max 4h)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *label == "" {
+		return errors.New("-label is required")
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	fmt.Fprintf(os.Stderr, "WARNING: this grants a %s super-admin session, consumes credential %q and alerts every super admin.\n\n", *ttl, *label)
+
+	justification, err := prompt(in, fmt.Sprintf("Step 1/3 - justification (min %d characters, recorded in the audit log): ", constant.BreakGlassMinJustificationLength))
+	if err != nil {
+		return err
+	}
+	if len(justification) < constant.BreakGlassMinJustificationLength {
+		return fmt.Errorf("justification must be at least %d characters", constant.BreakGlassMinJustificationLength)
+	}
+	secret, err := prompt(in, "Step 2/3 - sealed secret: ")
+	if err != nil {
+		return err
+	}
+	confirm, err := prompt(in, fmt.Sprintf("Step 3/3 - type %q to activate: ", "ACTIVATE "+*label))
+	if err != nil {
+		return err
+	}
+	if confirm != "ACTIVATE "+*label {
+		return errors.New("confirmation did not match; nothing was activated")
+	}
+
+	svc, err := newBreakGlassService()
+	if err != nil {
+		return err
+	}
+	activation, err := svc.Activate(context.Background(), aggregate.ActivateBreakGlassReq{
+		Label:         *label,
+		Secret:        secret,
+		Justification: justification,
+		Operator:      operatorName(),
+		TTL:           *ttl,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !activation.AlertDelivered {
+		fmt.Fprintln(os.Stderr, "WARNING: super admins could not be alerted automatically; notify them yourself.")
+	}
+	fmt.Printf("Emergency super-admin session %s granted until %s (activation %s).\nAccess token:\n\n  %s\n",
+		activation.SessionID, activation.ExpiresAt.Format("2006-01-02 15:04:05 MST"), activation.ActivationID, activation.AccessToken)
+	return nil
+}
+
+func newBreakGlassService() (service.IBreakGlassSvc, error) {
+	cfg, err := config.NewAppConfig()
+	if err != nil {
+		return nil, err
+	}
+	log, err := logger.NewLogger(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db, err := database.NewDbClient(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := jwt.NewKeySetFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tokenManager, err := jwt.NewJwtTokenManagerFromConfig(cfg, keys)
+	if err != nil {
+		return nil, err
+	}
+	return service.NewBreakGlassSvc(
+		log,
+		tokenManager,
+		repository.NewBreakGlassCredentialRepository(db),
+		repository.NewBreakGlassActivationRepository(db),
+		repository.NewSessionRepository(db),
+		repository.NewSuperAdminRepository(db),
+		service.NewBreakGlassWebhookAlerter(cfg.BreakGlass.AlertWebhookURL),
+	), nil
+}
+
+func prompt(in *bufio.Reader, question string) (string, error) {
+	fmt.Fprint(os.Stderr, question)
+	line, err := in.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// operatorName identifies who ran the command for the audit log.
+func operatorName() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, _ := os.Hostname()
+	return name + "@" + host
+}
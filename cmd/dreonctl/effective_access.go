@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hiamthach108/dreon-auth/internal/app"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"go.uber.org/fx"
+)
+
+// effectiveAccessCmd recomputes the effective access table from the role assignments and relation tuples.
+// Run it when switching EFFECTIVE_ACCESS_MODE from off, and after writing roles or tuples to the database
+// directly, e.g. with seed-demo.
+func effectiveAccessCmd(args []string) error {
+	if len(args) != 1 || args[0] != "rebuild" {
+		return errors.New("usage: dreonctl effective-access rebuild")
+	}
+
+	var svc service.IEffectiveAccessSvc
+	fxApp := fx.New(
+		fx.NopLogger,
+		app.Core,
+		app.Services,
+		app.Repositories,
+		fx.Invoke(service.RegisterConnectionHooks),
+		fx.Populate(&svc),
+	)
+	if err := fxApp.Err(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if err := fxApp.Start(ctx); err != nil {
+		return err
+	}
+	defer func() { _ = fxApp.Stop(ctx) }()
+
+	result, err := svc.Rebuild(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("rebuilt effective access: %d users with permissions, %d object relations, %d stale rows removed\n",
+		result.Users, result.Relations, result.Removed)
+	return nil
+}
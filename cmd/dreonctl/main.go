@@ -0,0 +1,120 @@
+// Command dreonctl runs operational tasks against a dreon-auth database. Day-to-day support tasks (users,
+// sessions, roles, relations and tokens) are in cmd/cli.
+//
+// Usage:
+//
+//	dreonctl migrate <up|down|status> [-to VERSION]
+//	dreonctl migrate create NAME
+//	dreonctl bootstrap -email EMAIL [-password PASSWORD] [-name NAME]
+//	dreonctl seed-demo [-projects N] [-users N] [-documents N]
+//	dreonctl break-glass provision -label NAME
+//	dreonctl break-glass activate -label NAME [-ttl 30m]
+//	dreonctl breach-bloom -in FILE -out FILE [-fp RATE]
+//	dreonctl effective-access rebuild
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/seed"
+	"github.com/hiamthach108/dreon-auth/pkg/database"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "migrate":
+		err = migrate(os.Args[2:])
+	case "bootstrap":
+		err = bootstrap(os.Args[2:])
+	case "seed-demo":
+		err = seedDemo(os.Args[2:])
+	case "break-glass":
+		err = breakGlass(os.Args[2:])
+	case "breach-bloom":
+		err = breachBloom(os.Args[2:])
+	case "effective-access":
+		err = effectiveAccessCmd(os.Args[2:])
+	case "-h", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: dreonctl <command> [flags]
+
+Commands:
+  migrate       Apply, roll back or list the database migrations, or create a new one
+  bootstrap     Create the permission catalog, default system roles and the first super admin (idempotent)
+  seed-demo     Populate demo projects, users, roles and relation tuples (idempotent; refused when APP_ENV=production)
+  break-glass   Provision or activate sealed emergency super-admin access
+  breach-bloom  Build the offline breached-password filter from a Pwned Passwords SHA-1 download
+  effective-access
+                Rebuild the precomputed effective access table from the roles and relation tuples
+
+Looking up users, revoking sessions, assigning roles, granting and checking relations and decoding tokens
+are in the dreon-cli command (cmd/cli).`)
+}
+
+func seedDemo(args []string) error {
+	fs := flag.NewFlagSet("seed-demo", flag.ExitOnError)
+	size := seed.DefaultDemoSize
+	fs.IntVar(&size.Projects, "projects", size.Projects, "number of demo projects")
+	fs.IntVar(&size.Users, "users", size.Users, "number of demo users, spread across projects")
+	fs.IntVar(&size.DocumentsPerProject, "documents", size.DocumentsPerProject, "documents per project in the relation tuple graph")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.NewAppConfig()
+	if err != nil {
+		return err
+	}
+	if err := seed.GuardEnvironment(cfg.App.Env); err != nil {
+		return err
+	}
+	log, err := logger.NewLogger(cfg)
+	if err != nil {
+		return err
+	}
+	db, err := database.NewDbClient(cfg, log)
+	if err != nil {
+		return err
+	}
+
+	seeder := seed.NewDemoSeeder(
+		log,
+		repository.NewUserRepository(db),
+		repository.NewProjectRepository(db),
+		repository.NewRoleRepository(db),
+		repository.NewUserRoleRepository(db),
+		repository.NewRelationTupleRepository(db),
+	)
+	result, err := seeder.Run(context.Background(), size)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created %d projects, %d users, %d roles, %d role assignments, %d relation tuples (password: %s)\n",
+		result.Projects, result.Users, result.Roles, result.UserRoles, result.Tuples, seed.DemoPassword)
+	return nil
+}
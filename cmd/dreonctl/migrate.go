@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/pkg/database"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = "usage: dreonctl migrate <up|down|status> [-to VERSION] | dreonctl migrate create NAME"
+
+// migrate applies, rolls back or lists the SQL migrations embedded in the binary, or creates a new one in
+// the source tree.
+func migrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	command := args[0]
+	if command == "create" {
+		if len(args) != 2 {
+			return errors.New("usage: dreonctl migrate create NAME")
+		}
+		goose.SetSequential(true)
+		return goose.Create(nil, database.MigrationsDir, args[1], "sql")
+	}
+
+	fs := flag.NewFlagSet("migrate "+command, flag.ExitOnError)
+	to := fs.Int64("to", -1, "up: stop after this version; down: roll back to this version, 0 for all")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := config.NewAppConfig()
+	if err != nil {
+		return err
+	}
+	log, err := logger.NewLogger(cfg)
+	if err != nil {
+		return err
+	}
+	db, err := database.Connect(cfg, log)
+	if err != nil {
+		return err
+	}
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		var results []*goose.MigrationResult
+		if *to >= 0 {
+			results, err = migrator.UpTo(ctx, *to)
+		} else {
+			results, err = migrator.Up(ctx)
+		}
+		printMigrationResults("applied", results)
+		if err == nil && len(results) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return err
+	case "down":
+		if *to >= 0 {
+			results, err := migrator.DownTo(ctx, *to)
+			printMigrationResults("rolled back", results)
+			return err
+		}
+		result, err := migrator.Down(ctx)
+		if result != nil {
+			printMigrationResults("rolled back", []*goose.MigrationResult{result})
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+		for _, s := range statuses {
+			appliedAt := "-"
+			if !s.AppliedAt.IsZero() {
+				appliedAt = s.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, s.Source.Path)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func printMigrationResults(verb string, results []*goose.MigrationResult) {
+	for _, r := range results {
+		if r.Error != nil {
+			continue
+		}
+		fmt.Printf("%s %s (%s)\n", verb, r.Source.Path, r.Duration.Round(time.Millisecond))
+	}
+}
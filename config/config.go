@@ -12,11 +12,18 @@ type AppConfig struct {
 	App struct {
 		Name    string `env:"APP_NAME"`
 		Version string `env:"APP_VERSION"`
+		Env     string `env:"APP_ENV"` // e.g. development, staging, production
 	}
 	Server struct {
 		Host     string `env:"HTTP_HOST"`
 		Port     string `env:"HTTP_PORT"`
 		GRPCPort string `env:"GRPC_PORT"`
+		// TrustedProxyCIDRs lists the reverse proxies (comma-separated CIDRs or addresses) whose
+		// X-Forwarded-For entries are believed. Empty uses the connection's address as the client IP.
+		TrustedProxyCIDRs string `env:"TRUSTED_PROXY_CIDRS"`
+		// ShutdownTimeoutSec bounds each step of a graceful shutdown: draining in-flight HTTP requests, then
+		// gRPC calls, then the background work they started. Defaults to 10.
+		ShutdownTimeoutSec int `env:"SHUTDOWN_TIMEOUT_SEC"`
 	}
 	Logger struct {
 		Level string `env:"LOG_LEVEL"`
@@ -29,6 +36,13 @@ type AppConfig struct {
 		RedisPort            string `env:"REDIS_PORT"`
 		RedisPassword        string `env:"REDIS_PASSWORD"`
 		RedisDB              int    `env:"REDIS_DB"`
+		// Driver is "redis" (the default) or "memory", which keeps everything in process for local development
+		// and tests. The memory driver shares nothing between instances.
+		Driver string `env:"CACHE_DRIVER"`
+		// LocalSize, when set, puts an in-process LRU tier of that many entries in front of Redis. Entries stay
+		// there at most LocalTTLSec seconds (30 by default); writes on any instance evict them sooner.
+		LocalSize   int `env:"CACHE_LOCAL_SIZE"`
+		LocalTTLSec int `env:"CACHE_LOCAL_TTL_SEC"`
 	}
 
 	Postgres struct {
@@ -41,19 +55,241 @@ type AppConfig struct {
 		SSL            bool   `env:"POSTGRES_SSL"`
 		MaxIdleConns   int    `env:"POSTGRES_MAX_IDLE_CONNS"`
 		MaxOpenConns   int    `env:"POSTGRES_MAX_OPEN_CONNS"`
+		// SkipMigrations leaves the schema alone at startup, for deployments that run `dreonctl migrate up` as a
+		// separate step.
+		SkipMigrations bool `env:"POSTGRES_SKIP_MIGRATIONS"`
+		// ReplicaHosts lists read replicas as comma-separated host or host:port entries (Cloud SQL connection
+		// names when ConnectionName is set). They share the primary's credentials, database and SSL setting.
+		ReplicaHosts string `env:"POSTGRES_REPLICA_HOSTS"`
 	}
 
 	Jwt struct {
 		PrivateKey            string `env:"JWT_PRIVATE_KEY"`
 		PublicKey             string `env:"JWT_PUBLIC_KEY"`
+		KeyID                 string `env:"JWT_KEY_ID"`             // kid of the key above; defaults to its RFC 7638 thumbprint
+		VerifyPublicKeys      string `env:"JWT_VERIFY_PUBLIC_KEYS"` // extra PEM blocks (or comma-separated base64 DER) still accepted and published
 		AccessTokenExpiresIn  int    `env:"JWT_ACCESS_TOKEN_EXPIRES_IN"`
 		RefreshTokenExpiresIn int    `env:"JWT_REFRESH_TOKEN_EXPIRES_IN"`
+		// EmbedRoles and EmbedPermissions put the user's role codes and permission keys into access tokens,
+		// for downstream services that authorize without calling back.
+		EmbedRoles       bool `env:"JWT_EMBED_ROLES"`
+		EmbedPermissions bool `env:"JWT_EMBED_PERMISSIONS"`
+		// EncryptionKey is an RSA private key; when set, access tokens are encrypted (JWE) for it.
+		EncryptionKey string `env:"JWT_ENCRYPTION_KEY"`
+		// AccessTokenFormat is "jwt" (the default) or "opaque": random tokens resolved from the cache.
+		AccessTokenFormat string `env:"JWT_ACCESS_TOKEN_FORMAT"`
+		// LeewaySec tolerates this much clock drift between servers when checking exp, nbf and iat.
+		LeewaySec int `env:"JWT_LEEWAY_SEC"`
+		// Issuer (default: App.Name) and the comma-separated Audience are set on access tokens and required by Verify.
+		Issuer   string `env:"JWT_ISSUER"`
+		Audience string `env:"JWT_AUDIENCE"`
 	}
 
 	Permissions struct {
 		FilePath string `env:"PERMISSIONS_FILE"`
 	}
 
+	// Quota is the default per-API-key request quota; 0 means unlimited.
+	Quota struct {
+		DailyRequests   int `env:"API_KEY_DAILY_QUOTA"`
+		MonthlyRequests int `env:"API_KEY_MONTHLY_QUOTA"`
+	}
+
+	// ProjectQuota caps the logins, token validations and relation checks made for each project; 0 means unlimited.
+	ProjectQuota struct {
+		DailyRequests   int `env:"PROJECT_DAILY_QUOTA"`
+		MonthlyRequests int `env:"PROJECT_MONTHLY_QUOTA"`
+	}
+
+	// RateLimit throttles the sign-in endpoints with token buckets in Redis, shared by all replicas. A policy
+	// is a comma-separated list of identity:requests/period entries, e.g. "ip:10/1m,apikey:300/1m". The
+	// caller's identity is their API key, else their user, else their IP; "off" lifts the route's limit.
+	RateLimit struct {
+		Disabled bool   `env:"RATE_LIMIT_DISABLED"`
+		Login    string `env:"RATE_LIMIT_LOGIN"`    // defaults to ip:10/1m,user:10/1m,apikey:300/1m
+		Register string `env:"RATE_LIMIT_REGISTER"` // defaults to ip:5/1m,apikey:100/1m
+		OTP      string `env:"RATE_LIMIT_OTP"`      // OTP, MFA and magic-link verification; defaults to ip:10/1m,user:10/1m,apikey:300/1m
+	}
+
+	// Captcha challenges sign-in and registration after repeated failures from the same IP or account;
+	// without CAPTCHA_PROVIDER nobody is challenged.
+	Captcha struct {
+		Provider         string `env:"CAPTCHA_PROVIDER"` // "recaptcha", "turnstile" or "hcaptcha"
+		SecretKey        string `env:"CAPTCHA_SECRET_KEY"`
+		FailureThreshold int    `env:"CAPTCHA_FAILURE_THRESHOLD"`  // failures before a captcha is required, defaults to 5
+		FailureWindowSec int    `env:"CAPTCHA_FAILURE_WINDOW_SEC"` // how long failures count, defaults to 900
+	}
+
+	// IPBan bans a client IP from the whole API for DurationSec once it fails sign-in Threshold times within
+	// WindowSec; without IP_BAN_THRESHOLD nobody is banned.
+	IPBan struct {
+		Threshold   int `env:"IP_BAN_THRESHOLD"`
+		WindowSec   int `env:"IP_BAN_WINDOW_SEC"`   // how long failures count, defaults to 600
+		DurationSec int `env:"IP_BAN_DURATION_SEC"` // how long a ban lasts, defaults to 3600
+	}
+
+	// LoginAnomaly flags logins from a device or location none of the user's recent sessions used.
+	LoginAnomaly struct {
+		Disabled            bool `env:"LOGIN_ANOMALY_DISABLED"`
+		HistorySize         int  `env:"LOGIN_ANOMALY_HISTORY_SIZE"`         // recent sessions compared, defaults to 20
+		RequireConfirmation bool `env:"LOGIN_ANOMALY_REQUIRE_CONFIRMATION"` // email a sign-in link instead of issuing tokens; needs MAGIC_LINK_URL
+	}
+
+	// AccountEnumeration, when Protection is set, stops sign-in and sign-up responses from telling which emails
+	// have an account: unknown emails and wrong passwords both fail with "invalid credentials", in about the
+	// same time, and every registration answers with an emailed sign-in link (needs MAGIC_LINK_URL).
+	AccountEnumeration struct {
+		Protection bool `env:"ACCOUNT_ENUMERATION_PROTECTION"`
+	}
+
+	OAuth struct {
+		StateSecret     string `env:"OAUTH_STATE_SECRET"`
+		RoleMappingFile string `env:"OAUTH_ROLE_MAPPING_FILE"`
+		// StateBinding ties a refreshState to the client that started the login: user-agent (default), ip or none.
+		StateBinding string `env:"OAUTH_STATE_BINDING"`
+		// RedirectURLs lists, comma-separated, where logins that name no project may send users back to.
+		RedirectURLs string `env:"OAUTH_REDIRECT_URLS"`
+	}
+
+	OIDC struct {
+		ClientsFile   string `env:"OIDC_CLIENTS_FILE"`
+		ProvidersFile string `env:"OIDC_PROVIDERS_FILE"` // upstream issuers users can sign in with (AuthType OIDC)
+		IssuerURL     string `env:"OIDC_ISSUER_URL"`     // public URL of this server without /api/v1; enables the OIDC provider endpoints
+		LoginURL      string `env:"OIDC_LOGIN_URL"`      // frontend page that signs the user in and approves ?authRequest=
+
+		DeviceVerificationURL string `env:"OIDC_DEVICE_VERIFICATION_URL"` // frontend page where users enter device user codes; enables the device grant
+	}
+
+	// Password selects how passwords are hashed. Hashes made with another algorithm or other parameters
+	// still verify, and are replaced on the user's next successful login.
+	Password struct {
+		Algorithm         string `env:"PASSWORD_HASH_ALGORITHM"`     // "bcrypt" (default) or "argon2id"
+		BcryptCost        int    `env:"PASSWORD_BCRYPT_COST"`        // defaults to 10
+		Argon2MemoryKiB   int    `env:"PASSWORD_ARGON2_MEMORY_KIB"`  // defaults to 65536 (64 MiB)
+		Argon2Iterations  int    `env:"PASSWORD_ARGON2_ITERATIONS"`  // defaults to 3
+		Argon2Parallelism int    `env:"PASSWORD_ARGON2_PARALLELISM"` // defaults to 2
+		// Peppers are secrets mixed into passwords before hashing, as comma-separated version:base64 pairs,
+		// e.g. "1:...,2:...". The highest version peppers new hashes; keep older ones until no user has them.
+		Peppers string `env:"PASSWORD_PEPPERS"`
+		// PeppersFile is a file with the peppers in the PASSWORD_PEPPERS format, mounted by a secrets manager.
+		PeppersFile string `env:"PASSWORD_PEPPERS_FILE"`
+		// PeppersWrapped lists version:ciphertext pairs of peppers encrypted with a Vault transit key, which is
+		// asked to decrypt them on startup.
+		PeppersWrapped    string `env:"PASSWORD_PEPPERS_WRAPPED"`
+		PeppersVaultAddr  string `env:"PASSWORD_PEPPERS_VAULT_ADDR"`
+		PeppersVaultToken string `env:"PASSWORD_PEPPERS_VAULT_TOKEN"`
+		PeppersVaultKey   string `env:"PASSWORD_PEPPERS_VAULT_TRANSIT_KEY"`
+
+		// BreachPolicy is what happens to a new password found in a known breach: "off" (default), "warn"
+		// or "reject". Projects may set their own.
+		BreachPolicy    string `env:"PASSWORD_BREACH_POLICY"`
+		BreachAPIURL    string `env:"PASSWORD_BREACH_API_URL"`    // Pwned Passwords range API, defaults to https://api.pwnedpasswords.com
+		BreachBloomFile string `env:"PASSWORD_BREACH_BLOOM_FILE"` // offline filter used when the API fails; see dreonctl breach-bloom
+		BreachOffline   bool   `env:"PASSWORD_BREACH_OFFLINE"`    // only use the Bloom filter, e.g. without internet access
+	}
+
+	// Mail selects the provider for outgoing email; with the default smtp driver and no SMTP_HOST mail is dropped.
+	Mail struct {
+		Driver      string `env:"MAIL_DRIVER"` // "smtp" (default), "ses" or "sendgrid"
+		SMTPHost    string `env:"SMTP_HOST"`
+		SMTPPort    int    `env:"SMTP_PORT"` // defaults to 587
+		Username    string `env:"SMTP_USERNAME"`
+		Password    string `env:"SMTP_PASSWORD"`
+		From        string `env:"MAIL_FROM"`         // e.g. "Dreon <no-reply@example.com>"; projects may set their own
+		MaxAttempts int    `env:"MAIL_MAX_ATTEMPTS"` // delivery attempts per message, defaults to 3
+
+		SESRegion          string `env:"SES_REGION"`
+		SESAccessKeyID     string `env:"SES_ACCESS_KEY_ID"`
+		SESSecretAccessKey string `env:"SES_SECRET_ACCESS_KEY"`
+		SendGridAPIKey     string `env:"SENDGRID_API_KEY"`
+	}
+
+	// SMS selects the provider for outgoing text messages; without SMS_PROVIDER messages are dropped.
+	SMS struct {
+		Provider         string `env:"SMS_PROVIDER"` // "twilio"
+		From             string `env:"SMS_FROM"`     // sender number in E.164
+		TwilioAccountSID string `env:"TWILIO_ACCOUNT_SID"`
+		TwilioAuthToken  string `env:"TWILIO_AUTH_TOKEN"`
+	}
+
+	// EventBus selects the message bus domain events are published to; without EVENT_BUS_DRIVER they are dropped.
+	EventBus struct {
+		Driver string `env:"EVENT_BUS_DRIVER"` // "nats" or "kafka"
+		URL    string `env:"EVENT_BUS_URL"`    // NATS server URL, or comma-separated Kafka brokers
+		Topic  string `env:"EVENT_BUS_TOPIC"`  // NATS subject prefix or Kafka topic, defaults to dreon.auth
+	}
+
+	// Tracing configures OpenTelemetry export; without OTEL_EXPORTER_OTLP_ENDPOINT spans are not recorded.
+	Tracing struct {
+		Endpoint    string  `env:"OTEL_EXPORTER_OTLP_ENDPOINT"` // OTLP/gRPC collector, host:port or URL
+		Insecure    bool    `env:"OTEL_EXPORTER_OTLP_INSECURE"` // plaintext gRPC, for a collector on a private network
+		SampleRatio float64 `env:"OTEL_TRACES_SAMPLE_RATIO"`    // share of new traces recorded, defaults to 1
+	}
+
+	// MagicLink configures passwordless email login.
+	MagicLink struct {
+		URL string `env:"MAGIC_LINK_URL"` // frontend page that receives ?token= and calls /auth/magic-link/verify
+	}
+
+	// SAML configures the service provider side of per-project enterprise SSO.
+	SAML struct {
+		BaseURL  string `env:"SAML_BASE_URL"`     // public API URL including /api/v1; SP entity IDs and ACS URLs derive from it
+		CertFile string `env:"SAML_SP_CERT_FILE"` // optional PEM certificate published in SP metadata
+		KeyFile  string `env:"SAML_SP_KEY_FILE"`  // optional RSA key for signed requests and encrypted assertions
+	}
+
+	// AccessPolicy configures how per-project network policies see the client.
+	AccessPolicy struct {
+		CountryHeader string `env:"ACCESS_POLICY_COUNTRY_HEADER"` // set by a trusted proxy, defaults to CF-IPCountry
+	}
+
+	// Admin restricts super-admin sign-in and the super-admin API to the comma-separated AllowedCIDRs; empty
+	// allows any network.
+	Admin struct {
+		AllowedCIDRs string `env:"ADMIN_ALLOWED_CIDRS"`
+	}
+
+	// Relations configures relation checks.
+	Relations struct {
+		MaxCheckDepth   int `env:"RELATION_MAX_CHECK_DEPTH"`    // rewrites and userset hops one check may follow, defaults to 25
+		DenyCacheTTLSec int `env:"RELATION_DENY_CACHE_TTL_SEC"` // how long a denied check is cached; unset, denials are not cached
+	}
+
+	// EffectiveAccess precomputes what users and subjects hold into one table, so permission lookups and most
+	// relation checks are a single indexed read. Mode is off (the default), maintain or serve.
+	EffectiveAccess struct {
+		Mode string `env:"EFFECTIVE_ACCESS_MODE"`
+	}
+
+	MFA struct {
+		TrustedDeviceDays int `env:"MFA_TRUSTED_DEVICE_DAYS"` // how long "trust this device" skips MFA, defaults to 30
+	}
+
+	// Scheduler configures the background cleanup jobs every replica runs.
+	Scheduler struct {
+		Disabled             bool `env:"SCHEDULER_DISABLED"`          // e.g. to run cleanup on only one replica
+		CleanupIntervalMin   int  `env:"CLEANUP_INTERVAL_MINUTES"`    // defaults to 60
+		SessionRetentionDays int  `env:"SESSION_RETENTION_DAYS"`      // how long ended sessions are kept, defaults to 7
+		ProjectRetentionDays int  `env:"PROJECT_RETENTION_DAYS"`      // how long archived projects are kept, defaults to 30
+		ErasedRetentionDays  int  `env:"ERASED_AUDIT_RETENTION_DAYS"` // how long audit records of erased accounts are kept, defaults to 90
+	}
+
+	// Health configures the dependency checks of /healthz and /readyz.
+	Health struct {
+		CheckTimeoutMs int `env:"HEALTH_CHECK_TIMEOUT_MS"` // how long each dependency may take to answer, defaults to 1000
+	}
+
+	// Reload configures how changes to the .env file and the permissions file are picked up without a restart.
+	// Connection settings (ports, Postgres, Redis, keys) still need one.
+	Reload struct {
+		Disabled    bool `env:"CONFIG_RELOAD_DISABLED"`
+		IntervalSec int  `env:"CONFIG_RELOAD_INTERVAL_SEC"` // how often the files are checked, defaults to 10
+	}
+
+	BreakGlass struct {
+		AlertWebhookURL string `env:"BREAK_GLASS_ALERT_WEBHOOK_URL"` // receives a JSON alert addressed to every super admin
+	}
+
 	Google struct {
 		ClientID     string `env:"GOOGLE_CLIENT_ID"`
 		ClientSecret string `env:"GOOGLE_CLIENT_SECRET"`
@@ -65,7 +301,7 @@ func NewAppConfig() (*AppConfig, error) {
 	config := &AppConfig{}
 
 	// Try to load from .env file first (optional)
-	file, err := os.Open(".env")
+	file, err := os.Open(EnvFile)
 	if err == nil {
 		defer func() {
 			if closeErr := file.Close(); closeErr != nil {
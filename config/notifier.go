@@ -0,0 +1,69 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// EnvFile is the optional dotenv file NewAppConfig reads before the environment.
+const EnvFile = ".env"
+
+// INotifier holds the live configuration. Services that should pick up a reload without a restart read
+// Current at call time, or Subscribe to rebuild what they derive from it.
+type INotifier interface {
+	// Current returns the latest configuration. The returned value must not be modified.
+	Current() *AppConfig
+	// Subscribe calls fn with the new configuration after every reload that changed it.
+	Subscribe(fn func(cfg *AppConfig))
+	// Reload loads the configuration again and swaps it in, reporting whether anything changed. On error the
+	// current configuration is kept.
+	Reload() (bool, error)
+}
+
+type Notifier struct {
+	current     atomic.Pointer[AppConfig]
+	load        func() (*AppConfig, error)
+	mu          sync.Mutex // serializes reloads and guards subscribers
+	subscribers []func(cfg *AppConfig)
+}
+
+// NewNotifier starts from cfg, the configuration loaded at startup, and reloads with NewAppConfig.
+func NewNotifier(cfg *AppConfig) INotifier {
+	return NewNotifierWithLoader(cfg, NewAppConfig)
+}
+
+// NewNotifierWithLoader is NewNotifier with the loader used by Reload, for tests.
+func NewNotifierWithLoader(cfg *AppConfig, load func() (*AppConfig, error)) *Notifier {
+	n := &Notifier{load: load}
+	n.current.Store(cfg)
+	return n
+}
+
+func (n *Notifier) Current() *AppConfig {
+	return n.current.Load()
+}
+
+func (n *Notifier) Subscribe(fn func(cfg *AppConfig)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subscribers = append(n.subscribers, fn)
+}
+
+func (n *Notifier) Reload() (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	cfg, err := n.load()
+	if err != nil {
+		return false, err
+	}
+	if reflect.DeepEqual(cfg, n.current.Load()) {
+		return false, nil
+	}
+	n.current.Store(cfg)
+	for _, fn := range n.subscribers {
+		fn(cfg)
+	}
+	return true, nil
+}
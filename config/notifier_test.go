@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNotifier_Reload(t *testing.T) {
+	initial := &AppConfig{}
+	initial.Jwt.AccessTokenExpiresIn = 3600
+
+	next := &AppConfig{}
+	next.Jwt.AccessTokenExpiresIn = 3600
+	var loadErr error
+	n := NewNotifierWithLoader(initial, func() (*AppConfig, error) {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		copied := *next
+		return &copied, nil
+	})
+
+	var notified []*AppConfig
+	n.Subscribe(func(cfg *AppConfig) { notified = append(notified, cfg) })
+
+	if changed, err := n.Reload(); err != nil || changed {
+		t.Errorf("Reload(unchanged) = %v, %v, want false, nil", changed, err)
+	}
+	if n.Current() != initial || len(notified) != 0 {
+		t.Error("an unchanged reload should keep the configuration and notify no one")
+	}
+
+	next.Jwt.AccessTokenExpiresIn = 600
+	if changed, err := n.Reload(); err != nil || !changed {
+		t.Fatalf("Reload(changed) = %v, %v, want true, nil", changed, err)
+	}
+	if got := n.Current().Jwt.AccessTokenExpiresIn; got != 600 {
+		t.Errorf("Current().Jwt.AccessTokenExpiresIn = %d, want 600", got)
+	}
+	if len(notified) != 1 || notified[0] != n.Current() {
+		t.Errorf("subscribers notified %d times, want once with the new configuration", len(notified))
+	}
+	if initial.Jwt.AccessTokenExpiresIn != 3600 {
+		t.Error("Reload modified the startup configuration")
+	}
+
+	loadErr = errors.New("bad .env")
+	if _, err := n.Reload(); err == nil {
+		t.Fatal("Reload() err = nil, want the load error")
+	}
+	if got := n.Current().Jwt.AccessTokenExpiresIn; got != 600 {
+		t.Errorf("a failed reload replaced the configuration, AccessTokenExpiresIn = %d", got)
+	}
+}
@@ -0,0 +1,75 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+)
+
+// UpsertAccessPolicyReq replaces a project's access policy. Empty lists impose no restriction.
+type UpsertAccessPolicyReq struct {
+	AllowedCIDRs             []string `json:"allowedCidrs" validate:"dive,cidr"`
+	BlockedCountries         []string `json:"blockedCountries" validate:"dive,len=2"`
+	CorporateCIDRs           []string `json:"corporateCidrs" validate:"dive,cidr"`
+	RequireMFAOutsideNetwork bool     `json:"requireMfaOutsideNetwork"`
+	IsActive                 *bool    `json:"isActive"` // defaults to true
+}
+
+// AccessPolicyResp is a project's access policy.
+type AccessPolicyResp struct {
+	ProjectID                string    `json:"projectId"`
+	AllowedCIDRs             []string  `json:"allowedCidrs"`
+	BlockedCountries         []string  `json:"blockedCountries"`
+	CorporateCIDRs           []string  `json:"corporateCidrs"`
+	RequireMFAOutsideNetwork bool      `json:"requireMfaOutsideNetwork"`
+	IsActive                 bool      `json:"isActive"`
+	UpdatedAt                time.Time `json:"updatedAt"`
+}
+
+func (r *AccessPolicyResp) FromModel(m *model.AccessPolicy) {
+	if m == nil {
+		return
+	}
+	rules := m.Rules()
+	r.ProjectID = m.ProjectID
+	r.AllowedCIDRs = rules.AllowedCIDRs
+	r.BlockedCountries = rules.BlockedCountries
+	r.CorporateCIDRs = rules.CorporateCIDRs
+	r.RequireMFAOutsideNetwork = rules.RequireMFAOutsideNetwork
+	r.IsActive = m.IsActive
+	r.UpdatedAt = m.UpdatedAt
+}
+
+// AccessDenialResp is one audited policy denial.
+type AccessDenialResp struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Stage     string    `json:"stage"`
+	Reason    string    `json:"reason"`
+	IP        string    `json:"ip"`
+	Country   string    `json:"country,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (r *AccessDenialResp) FromModel(m *model.AccessDenial) {
+	if m == nil {
+		return
+	}
+	r.ID = m.ID
+	r.UserID = m.UserID
+	r.Email = m.Email
+	r.Stage = m.Stage
+	r.Reason = m.Reason
+	r.IP = m.IP
+	r.Country = m.Country
+	r.CreatedAt = m.CreatedAt
+	var meta struct {
+		RequestID string `json:"request_id"`
+	}
+	if len(m.Metadata) > 0 && json.Unmarshal(m.Metadata, &meta) == nil {
+		r.RequestID = meta.RequestID
+	}
+}
@@ -0,0 +1,48 @@
+package aggregate
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+)
+
+// CreateAPIKeyReq mints an API key for a project.
+type CreateAPIKeyReq struct {
+	Name      string     `json:"name" validate:"required,max=255"`
+	Scopes    []string   `json:"scopes"`    // permission codes the key holds in the project
+	ExpiresAt *time.Time `json:"expiresAt"` // never expires when omitted
+}
+
+// APIKeyResp describes an API key without its secret.
+type APIKeyResp struct {
+	ID         string     `json:"id"`
+	ProjectID  string     `json:"projectId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func (r *APIKeyResp) FromModel(m *model.APIKey) {
+	if m == nil {
+		return
+	}
+	r.ID = m.ID
+	r.ProjectID = m.ProjectID
+	r.Name = m.Name
+	r.Prefix = m.Prefix
+	r.Scopes = m.ScopeList()
+	r.ExpiresAt = m.ExpiresAt
+	r.LastUsedAt = m.LastUsedAt
+	r.RevokedAt = m.RevokedAt
+	r.CreatedAt = m.CreatedAt
+}
+
+// CreateAPIKeyResp carries the full key, which is returned only once.
+type CreateAPIKeyResp struct {
+	APIKeyResp
+	Key string `json:"key"` // send as the X-API-Key header
+}
@@ -4,14 +4,23 @@ import (
 	"time"
 
 	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
 )
 
 type LoginReq struct {
 	IsSuperAdmin bool                  `json:"isSuperAdmin"`
-	AuthType     constant.UserAuthType `json:"authType" validate:"required,oneof=EMAIL SUPER_ADMIN GOOGLE FACEBOOK APPLE"`
+	AuthType     constant.UserAuthType `json:"authType" validate:"required,oneof=EMAIL SUPER_ADMIN GOOGLE FACEBOOK APPLE OIDC MAGIC_LINK PHONE_OTP"`
 	Email        string                `json:"email"`
+	Phone        string                `json:"phone"` // E.164, required for PHONE_OTP
 	Password     string                `json:"password"`
 	RedirectURL  string                `json:"redirectUrl"`
+	ProjectID    string                `json:"projectId"`    // optional; enforces that project's access policy
+	DeviceToken  string                `json:"deviceToken"`  // trusted device token; skips the MFA challenge while valid
+	Provider     string                `json:"provider"`     // configured provider key, required for OIDC
+	CaptchaToken string                `json:"captchaToken"` // required with EMAIL and SUPER_ADMIN after repeated failures
+	// PKCE for GOOGLE and OIDC: the codeVerifier is then required at /auth/session-from-state.
+	CodeChallenge       string `json:"codeChallenge"`
+	CodeChallengeMethod string `json:"codeChallengeMethod"` // S256
 }
 
 type TokenResp struct {
@@ -21,12 +30,30 @@ type TokenResp struct {
 	AccessTokenExpiresAt  time.Time `json:"accessTokenExpiresAt"`
 	RefreshToken          string    `json:"refreshToken"`
 	RefreshTokenExpiresAt time.Time `json:"refreshTokenExpiresAt"`
+	// Warnings are shown to the user, e.g. that the password they registered with was found in a breach.
+	Warnings []string `json:"warnings,omitempty"`
+	// SignInLinkSent is returned, without tokens, for every registration under account enumeration protection,
+	// whether or not the email already had an account.
+	SignInLinkSent bool `json:"signInLinkSent,omitempty"`
 }
 
 type LoginResp struct {
 	TokenResp
 	RedirectURL  string `json:"redirectUrl,omitempty"`
 	RefreshState string `json:"refreshState,omitempty"`
+	MFARequired  bool   `json:"mfaRequired,omitempty"` // no tokens yet: complete the challenge at /auth/mfa/verify
+	MFAToken     string `json:"mfaToken,omitempty"`
+	// MagicLinkSent is returned for every MAGIC_LINK login, whether or not the email has an account.
+	MagicLinkSent bool `json:"magicLinkSent,omitempty"`
+	// OTPSent is returned for every PHONE_OTP login, whether or not the number has an account.
+	OTPSent bool `json:"otpSent,omitempty"`
+	// LinkRequired means the external login matched an existing account by email: no tokens yet, the account
+	// owner signs in and confirms LinkToken at /me/identities/confirm.
+	LinkRequired bool   `json:"linkRequired,omitempty"`
+	LinkToken    string `json:"linkToken,omitempty"`
+	// ConfirmationRequired means the login came from a new device or location: no tokens yet, a sign-in
+	// link was emailed to the account instead.
+	ConfirmationRequired bool `json:"confirmationRequired,omitempty"`
 }
 
 // GoogleUserData is the shape returned by Google userinfo / used in store request.
@@ -34,29 +61,58 @@ type GoogleUserData struct {
 	Email string `json:"email"`
 	Name  string `json:"name"`
 	ID    string `json:"id"`
+	HD    string `json:"hd"` // hosted domain, set for Google Workspace accounts
 }
 
 // OAuthUserData is provider-agnostic user data stored in cache (Google, Facebook, Apple).
 type OAuthUserData struct {
-	Email      string `json:"email"`
-	Name       string `json:"name"`
-	ProviderID string `json:"providerId"`
+	Email      string   `json:"email"`
+	Name       string   `json:"name"`
+	ProviderID string   `json:"providerId"`
+	Groups     []string `json:"groups,omitempty"` // provider group/role claims, mapped to local roles on login
 }
 
-// CachedOAuthState is the value stored in cache under refresh_state:{state}.
-type CachedOAuthState struct {
+// OAuthLoginState is sealed into the provider `state` parameter when an OAuth login starts.
+type OAuthLoginState struct {
+	AuthType    constant.UserAuthType `json:"authType"`
+	Provider    string                `json:"provider,omitempty"` // OIDC provider key
+	RedirectURL string                `json:"redirectUrl"`
+	ProjectID   string                `json:"projectId,omitempty"` // whose redirect URLs RedirectURL is checked against
+	// CodeChallenge is the client's PKCE challenge, passed on to the refresh state.
+	CodeChallenge string `json:"codeChallenge,omitempty"`
+	// ClientBinding identifies the client that started the login, passed on to the refresh state.
+	ClientBinding string `json:"clientBinding,omitempty"`
+}
+
+// OAuthRefreshState is sealed into the refreshState handed to the frontend after the provider callback.
+// It carries everything SessionFromState needs, so no shared cache is required between regions.
+type OAuthRefreshState struct {
+	ID       string                `json:"id"` // unique per state; used to reject replays
 	AuthType constant.UserAuthType `json:"authType"`
+	Provider string                `json:"provider,omitempty"` // OIDC provider key
 	UserData OAuthUserData         `json:"userData"`
+	// ProjectID is the project the login was started for; its quota and access policy apply to the session.
+	ProjectID string `json:"projectId,omitempty"`
+	// CodeChallenge, when the login started with one, must be matched by the codeVerifier sent to
+	// SessionFromState, so an intercepted refreshState is useless on its own.
+	CodeChallenge string `json:"codeChallenge,omitempty"`
+	// ClientBinding, for logins without a CodeChallenge, is an HMAC of the starting client's user agent
+	// (and IP) that the client exchanging the state must match.
+	ClientBinding string `json:"clientBinding,omitempty"`
 }
 
 // SessionFromStateReq is the request to exchange a valid refreshState for a session.
 type SessionFromStateReq struct {
 	RefreshState string `json:"refreshState" validate:"required"`
+	CodeVerifier string `json:"codeVerifier"` // PKCE verifier, required when the login sent a codeChallenge
+	DeviceToken  string `json:"deviceToken"`  // trusted device token; skips the MFA challenge while valid
 }
 
 type RegisterReq struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8"`
+	Email        string `json:"email" validate:"required,email"`
+	Password     string `json:"password" validate:"required,min=8"`
+	CaptchaToken string `json:"captchaToken"` // required after repeated failures
+	ProjectID    string `json:"projectId"`    // the project signed up to; its breached-password policy applies
 }
 
 type RefreshTokenReq struct {
@@ -66,3 +122,27 @@ type RefreshTokenReq struct {
 type LogoutReq struct {
 	RefreshToken string `json:"refreshToken" validate:"required"`
 }
+
+// GetSessionReq selects the optional authorization hints returned with the current session.
+// Query params are used because the endpoint is a GET (e.g. ?includeRoles=true&includePermissions=true&projectId=...).
+type GetSessionReq struct {
+	IncludeRoles       bool    `query:"includeRoles" json:"includeRoles"`
+	IncludePermissions bool    `query:"includePermissions" json:"includePermissions"`
+	ProjectID          *string `query:"projectId" json:"projectId"` // limit hints to one project, "system" for system scope
+}
+
+// SessionResp is the current session payload plus optional roles and permission keys ("<projectId>/<code>").
+// Super admins bypass RBAC, so hints are never populated for them.
+type SessionResp struct {
+	jwt.Payload
+	Roles       []UserRoleResp `json:"roles,omitempty"`
+	Permissions []string       `json:"permissions,omitempty"`
+}
+
+// EndSessionReq is an OIDC RP-initiated logout request (query string on GET, form body on POST).
+type EndSessionReq struct {
+	IDTokenHint           string `query:"id_token_hint" form:"id_token_hint"`
+	ClientID              string `query:"client_id" form:"client_id"`
+	PostLogoutRedirectURI string `query:"post_logout_redirect_uri" form:"post_logout_redirect_uri"`
+	State                 string `query:"state" form:"state"`
+}
@@ -0,0 +1,33 @@
+package aggregate
+
+import "time"
+
+// ActivateBreakGlassReq is a fully confirmed break-glass activation.
+type ActivateBreakGlassReq struct {
+	Label         string
+	Secret        string
+	Justification string
+	Operator      string
+	TTL           time.Duration // constant.BreakGlassDefaultSessionTTL when zero
+}
+
+// BreakGlassActivationResp is the emergency session granted by a successful activation.
+type BreakGlassActivationResp struct {
+	ActivationID   string
+	SessionID      string
+	AccessToken    string
+	ExpiresAt      time.Time
+	AlertDelivered bool
+}
+
+// BreakGlassAlert tells super admins that break-glass access was attempted or granted.
+type BreakGlassAlert struct {
+	Recipients    []string  `json:"recipients"` // emails of all active super admins
+	Label         string    `json:"label"`
+	Operator      string    `json:"operator"`
+	Justification string    `json:"justification"`
+	SessionID     string    `json:"sessionId,omitempty"`
+	Rejected      string    `json:"rejected,omitempty"` // reason, set only for rejected attempts
+	ActivatedAt   time.Time `json:"activatedAt"`
+	ExpiresAt     time.Time `json:"expiresAt,omitzero"`
+}
@@ -0,0 +1,66 @@
+package aggregate
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+)
+
+// CredentialResp describes a passkey or MFA device without exposing its key material.
+type CredentialResp struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+func (r *CredentialResp) FromModel(m *model.UserCredential) {
+	if m == nil {
+		return
+	}
+	r.ID = m.ID
+	r.Type = m.Type.String()
+	r.Name = m.Name
+	r.CreatedAt = m.CreatedAt
+	r.LastUsedAt = m.LastUsedAt
+}
+
+// RenameCredentialReq sets a new display name on a credential.
+type RenameCredentialReq struct {
+	Name string `json:"name" validate:"required,min=1,max=255"`
+}
+
+// DeleteCredentialReq carries the step-up proof required to remove a credential.
+type DeleteCredentialReq struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// TrustedDeviceResp describes a device that may skip MFA.
+type TrustedDeviceResp struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+func (r *TrustedDeviceResp) FromModel(m *model.TrustedDevice) {
+	if m == nil {
+		return
+	}
+	r.ID = m.ID
+	r.Name = m.Name
+	r.IP = m.IP
+	r.CreatedAt = m.CreatedAt
+	r.ExpiresAt = m.ExpiresAt
+	r.LastUsedAt = m.LastUsedAt
+}
+
+// TrustedDeviceTokenResp is returned once when a device is trusted; the client stores deviceToken
+// and sends it on later logins.
+type TrustedDeviceTokenResp struct {
+	DeviceToken string    `json:"deviceToken"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,8 @@
+package aggregate
+
+// EffectiveAccessRebuildResp reports a rebuild of the effective access table.
+type EffectiveAccessRebuildResp struct {
+	Users     int   `json:"users"`     // users holding permissions through a role
+	Relations int   `json:"relations"` // object relations whose subjects were recomputed
+	Removed   int64 `json:"removed"`   // rows no role assignment or tuple backs anymore
+}
@@ -0,0 +1,14 @@
+package aggregate
+
+// HealthResp is the body of /healthz and /readyz. Status is one of the constant.HealthStatus* values.
+type HealthResp struct {
+	Status string                     `json:"status"`
+	Checks map[string]HealthCheckResp `json:"checks"`
+}
+
+// HealthCheckResp is the outcome of one dependency check.
+type HealthCheckResp struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
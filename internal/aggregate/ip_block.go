@@ -0,0 +1,44 @@
+package aggregate
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+)
+
+// CreateIPBlockReq denies a network range, or a single address, access to the whole API.
+type CreateIPBlockReq struct {
+	CIDR      string     `json:"cidr" validate:"required,cidr|ip"`
+	Reason    string     `json:"reason" validate:"max=500"`
+	ExpiresAt *time.Time `json:"expiresAt"` // blocks until deleted when omitted
+}
+
+// IPBlockResp is one entry of the IP denylist.
+type IPBlockResp struct {
+	ID        string     `json:"id"`
+	CIDR      string     `json:"cidr"`
+	Reason    string     `json:"reason,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Active    bool       `json:"active"`
+	CreatedBy string     `json:"createdBy,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func (r *IPBlockResp) FromModel(m *model.IPBlock) {
+	r.ID = m.ID
+	r.CIDR = m.CIDR
+	r.Reason = m.Reason
+	r.ExpiresAt = m.ExpiresAt
+	r.Active = m.Active(time.Now())
+	r.CreatedBy = m.CreatedBy
+	r.CreatedAt = m.CreatedAt
+}
+
+// IPBanResp is a client IP banned automatically after repeated failed sign-ins.
+type IPBanResp struct {
+	IP        string    `json:"ip"`
+	Failures  int64     `json:"failures"` // within IP_BAN_WINDOW_SEC when the ban started
+	Route     string    `json:"route"`    // of the failure that started the ban
+	BannedAt  time.Time `json:"bannedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
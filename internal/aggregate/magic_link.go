@@ -0,0 +1,15 @@
+package aggregate
+
+// MagicLinkState is sealed into the token emailed to the user. Its ID must still be pending in
+// the cache when the link is used, so every link works once.
+type MagicLinkState struct {
+	ID        string `json:"id"`
+	UserID    string `json:"userId"`
+	ProjectID string `json:"projectId,omitempty"`
+}
+
+// VerifyMagicLinkReq exchanges the emailed token for a session (or an MFA challenge).
+type VerifyMagicLinkReq struct {
+	Token       string `json:"token" validate:"required"`
+	DeviceToken string `json:"deviceToken"` // trusted device token; skips the MFA challenge while valid
+}
@@ -0,0 +1,50 @@
+package aggregate
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+)
+
+// MFAChallengeState is sealed into the mfaToken returned by Login when the user has TOTP enabled.
+// It carries the already-authenticated identity until the second factor is verified.
+type MFAChallengeState struct {
+	ID        string `json:"id"` // unique per challenge; used to limit attempts and reject replays
+	UserID    string `json:"userId"`
+	Email     string `json:"email"`
+	ProjectID string `json:"projectId,omitempty"`
+	// LoginMethod is how the user passed the first factor, recorded on the session once MFA completes.
+	LoginMethod constant.UserAuthType `json:"loginMethod,omitempty"`
+}
+
+// EnableTOTPReq starts TOTP enrollment for the caller.
+type EnableTOTPReq struct {
+	Name string `json:"name" validate:"max=255"` // display name, defaults to "Authenticator app"
+}
+
+// EnableTOTPResp holds the new secret; it is shown once and must be confirmed with a code.
+type EnableTOTPResp struct {
+	CredentialID string `json:"credentialId"`
+	Secret       string `json:"secret"`
+	OtpauthURL   string `json:"otpauthUrl"` // render as a QR code for authenticator apps
+}
+
+// VerifyTOTPReq completes a login challenge when MFAToken is set, otherwise confirms the caller's pending enrollment.
+type VerifyTOTPReq struct {
+	MFAToken    string `json:"mfaToken"`
+	Code        string `json:"code" validate:"required,len=6,numeric"`
+	TrustDevice bool   `json:"trustDevice"` // login only: remember this device and skip MFA on it
+}
+
+// VerifyTOTPResp carries the session tokens after a login challenge, or Enabled after an enrollment.
+type VerifyTOTPResp struct {
+	*TokenResp
+	Enabled              bool       `json:"enabled,omitempty"`
+	DeviceToken          string     `json:"deviceToken,omitempty"`
+	DeviceTokenExpiresAt *time.Time `json:"deviceTokenExpiresAt,omitempty"`
+}
+
+// DisableTOTPReq turns TOTP off; a current code proves possession of the authenticator.
+type DisableTOTPReq struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
@@ -0,0 +1,47 @@
+package aggregate
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/notification"
+)
+
+// UpsertNotificationTemplateReq sets a project's template for one notification and locale. Texts use
+// {{variable}} placeholders; an empty locale makes it the template for every locale without its own.
+type UpsertNotificationTemplateReq struct {
+	Channel constant.NotificationChannel `json:"channel" validate:"required,oneof=email sms"`
+	Name    string                       `json:"name" validate:"required"` // e.g. magic_link, phone_otp
+	Locale  string                       `json:"locale" validate:"omitempty,bcp47_language_tag"`
+	Subject string                       `json:"subject" validate:"max=255"` // required for email
+	Text    string                       `json:"text" validate:"required"`
+	HTML    string                       `json:"html"` // email only
+}
+
+// NotificationTemplateResp is one of a project's notification templates.
+type NotificationTemplateResp struct {
+	ID        string                       `json:"id"`
+	ProjectID string                       `json:"projectId"`
+	Channel   constant.NotificationChannel `json:"channel"`
+	Name      string                       `json:"name"`
+	Locale    string                       `json:"locale"`
+	Subject   string                       `json:"subject,omitempty"`
+	Text      string                       `json:"text"`
+	HTML      string                       `json:"html,omitempty"`
+	Variables []string                     `json:"variables"` // the placeholders the notification offers
+	UpdatedAt time.Time                    `json:"updatedAt"`
+}
+
+func (r *NotificationTemplateResp) FromModel(m *model.NotificationTemplate) {
+	r.ID = m.ID
+	r.ProjectID = m.ProjectID
+	r.Channel = m.Channel
+	r.Name = m.Name
+	r.Locale = m.Locale
+	r.Subject = m.Subject
+	r.Text = m.Text
+	r.HTML = m.HTML
+	r.Variables = notification.Variables[m.Channel][m.Name]
+	r.UpdatedAt = m.UpdatedAt
+}
@@ -0,0 +1,114 @@
+package aggregate
+
+import "time"
+
+// OIDCDiscoveryResp is the OpenID Provider metadata served at /.well-known/openid-configuration.
+type OIDCDiscoveryResp struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	EndSessionEndpoint                string   `json:"end_session_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint,omitempty"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	BackchannelLogoutSupported        bool     `json:"backchannel_logout_supported"`
+	BackchannelLogoutSessionSupported bool     `json:"backchannel_logout_session_supported"`
+}
+
+// OIDCAuthorizeReq is an OIDC authentication request for the authorization code flow.
+type OIDCAuthorizeReq struct {
+	ClientID            string `query:"client_id" json:"clientId"`
+	RedirectURI         string `query:"redirect_uri" json:"redirectUri"`
+	ResponseType        string `query:"response_type" json:"responseType"`
+	Scope               string `query:"scope" json:"scope"`
+	State               string `query:"state" json:"state"`
+	Nonce               string `query:"nonce" json:"nonce"`
+	CodeChallenge       string `query:"code_challenge" json:"codeChallenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" json:"codeChallengeMethod"`
+}
+
+// CompleteOIDCAuthorizeReq approves a pending authorization request for the signed-in user.
+type CompleteOIDCAuthorizeReq struct {
+	AuthRequest string `json:"authRequest" validate:"required"` // the ?authRequest= the login page was opened with
+}
+
+// CompleteOIDCAuthorizeResp is where the frontend sends the browser to hand the code to the client.
+type CompleteOIDCAuthorizeResp struct {
+	RedirectURL string `json:"redirectUrl"`
+}
+
+// OIDCTokenReq is a token endpoint request (form body). Client credentials may instead be sent with HTTP Basic auth.
+type OIDCTokenReq struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	DeviceCode   string `form:"device_code"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// OIDCTokenResp is a successful token endpoint response (RFC 6749 section 5.1).
+type OIDCTokenResp struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OIDCUserInfoResp holds the standard claims about the user an access token was issued to.
+type OIDCUserInfoResp struct {
+	Sub               string `json:"sub"`
+	Email             string `json:"email,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	PhoneNumber       string `json:"phone_number,omitempty"`
+}
+
+// DeviceCodeReq starts a device authorization (form body, RFC 8628 section 3.1). Client credentials may
+// instead be sent with HTTP Basic auth.
+type DeviceCodeReq struct {
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// DeviceCodeResp tells the device what to show the user and how to poll (RFC 8628 section 3.2).
+type DeviceCodeResp struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// GetDeviceAuthorizationReq looks up the pending device authorization a user code belongs to.
+type GetDeviceAuthorizationReq struct {
+	UserCode string `query:"userCode" validate:"required"`
+}
+
+// DeviceAuthorizationResp describes a pending device authorization so the user can decide whether to approve it.
+type DeviceAuthorizationResp struct {
+	UserCode  string    `json:"userCode"`
+	ClientID  string    `json:"clientId"`
+	ProjectID string    `json:"projectId,omitempty"`
+	Scope     string    `json:"scope,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// CompleteDeviceAuthorizationReq approves, or with deny rejects, a device authorization for the signed-in user.
+type CompleteDeviceAuthorizationReq struct {
+	UserCode string `json:"userCode" validate:"required"`
+	Deny     bool   `json:"deny"`
+}
@@ -1,9 +1,10 @@
 package aggregate
 
+// PaginationReq selects a page by number, or by the nextCursor of the previous page, which takes precedence.
 type PaginationReq struct {
-	Page     int     `form:"page" json:"page" validate:"gte=1"`
-	PageSize int     `form:"pageSize" json:"pageSize" validate:"gte=1,lte=100"`
-	Cursor   *string `form:"cursor" json:"cursor"`
+	Page     int     `query:"page" form:"page" json:"page" validate:"omitempty,gte=1"`
+	PageSize int     `query:"pageSize" form:"pageSize" json:"pageSize" validate:"omitempty,gte=1,lte=100"`
+	Cursor   *string `query:"cursor" form:"cursor" json:"cursor"`
 }
 
 type PaginationResp[T any] struct {
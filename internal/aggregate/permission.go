@@ -0,0 +1,66 @@
+package aggregate
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+)
+
+// CreatePermissionReq represents a request to add a permission to the catalog
+type CreatePermissionReq struct {
+	Code        string `json:"code" validate:"required,min=2,max=255"`
+	Name        string `json:"name" validate:"required,min=2,max=255"`
+	Description string `json:"description"`
+}
+
+// UpdatePermissionReq represents a request to update a catalog permission; its code cannot change
+type UpdatePermissionReq struct {
+	Name        string `json:"name" validate:"required,min=2,max=255"`
+	Description string `json:"description"`
+}
+
+// PermissionResp represents a catalog permission; ProjectID is set on codes a project defines
+type PermissionResp struct {
+	ID          string    `json:"id"`
+	ProjectID   *string   `json:"projectId,omitempty"`
+	Code        string    `json:"code"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func (r *PermissionResp) FromModel(m *model.Permission) {
+	if m == nil {
+		return
+	}
+	r.ID = m.ID
+	r.ProjectID = m.ProjectID
+	r.Code = m.Code
+	r.Name = m.Name
+	r.Description = m.Description
+	r.CreatedAt = m.CreatedAt
+	r.UpdatedAt = m.UpdatedAt
+}
+
+// CheckPermissionReq asks whether a user holds a permission code, in a project or (without ProjectID) the
+// system scope
+type CheckPermissionReq struct {
+	UserID     string  `json:"userId" validate:"required"`
+	Permission string  `json:"permission" validate:"required"`
+	ProjectID  *string `json:"projectId"`
+}
+
+// CheckPermissionResp is the outcome of a permission check with the role assignments that grant it
+type CheckPermissionResp struct {
+	Allowed bool                  `json:"allowed"`
+	Roles   []PermissionGrantRole `json:"roles"`
+}
+
+// PermissionGrantRole is an assigned role contributing a permission; ProjectID is the project it is assigned in
+type PermissionGrantRole struct {
+	ID        string  `json:"id"`
+	Code      string  `json:"code"`
+	Name      string  `json:"name"`
+	ProjectID *string `json:"projectId,omitempty"`
+}
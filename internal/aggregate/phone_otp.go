@@ -0,0 +1,8 @@
+package aggregate
+
+// VerifyPhoneOTPReq exchanges an SMS login code for a session (or an MFA challenge).
+type VerifyPhoneOTPReq struct {
+	Phone       string `json:"phone" validate:"required,e164"`
+	Code        string `json:"code" validate:"required,len=6,numeric"`
+	DeviceToken string `json:"deviceToken"` // trusted device token; skips the MFA challenge while valid
+}
@@ -0,0 +1,69 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+)
+
+// UserDataExport bundles what the service stores about a user, for GET /me/data-export.
+// Secrets such as the password hash, refresh tokens and passkey public keys are left out.
+type UserDataExport struct {
+	ExportedAt     time.Time           `json:"exportedAt"`
+	Profile        UserDto             `json:"profile"`
+	Identities     []UserIdentityResp  `json:"identities"`
+	Credentials    []CredentialResp    `json:"credentials"`
+	TrustedDevices []TrustedDeviceResp `json:"trustedDevices"`
+	Devices        []UserDeviceResp    `json:"devices"`
+	Sessions       []UserSessionResp   `json:"sessions"`
+	Memberships    []ProjectMemberResp `json:"memberships"`
+	Roles          []UserRoleResp      `json:"roles"`
+	Relations      []RelationTupleResp `json:"relations"` // tuples naming the user as subject or object
+	AccessDenials  []AccessDenialResp  `json:"accessDenials"`
+}
+
+// UserSessionResp describes one of the user's sessions in a data export.
+type UserSessionResp struct {
+	ID          string    `json:"id"`
+	ProjectID   string    `json:"projectId,omitempty"`
+	DeviceID    string    `json:"deviceId,omitempty"`
+	IsActive    bool      `json:"isActive"`
+	MFAVerified bool      `json:"mfaVerified"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Anomalies   []string  `json:"anomalies,omitempty"` // new_device, new_location: how the login differed from earlier ones
+	// LoginMethod is the auth type of the login that started the session; empty for refreshed sessions.
+	LoginMethod string `json:"loginMethod,omitempty"`
+	// ImpersonatorID is the super admin the session was issued to, for impersonation sessions.
+	ImpersonatorID string `json:"impersonatorId,omitempty"`
+}
+
+func (r *UserSessionResp) FromModel(m *model.Session) {
+	if m == nil {
+		return
+	}
+	r.ID = m.ID
+	r.ProjectID = m.ProjectID
+	r.DeviceID = m.DeviceID
+	r.IsActive = m.IsActive
+	r.MFAVerified = m.MFAVerified
+	r.ExpiresAt = m.ExpiresAt
+	r.CreatedAt = m.CreatedAt
+	var meta struct {
+		Anomalies    []string `json:"anomalies"`
+		Impersonator string   `json:"impersonator"`
+		LoginMethod  string   `json:"login_method"`
+	}
+	if json.Unmarshal(m.Metadata, &meta) == nil {
+		r.Anomalies = meta.Anomalies
+		r.ImpersonatorID = meta.Impersonator
+		r.LoginMethod = meta.LoginMethod
+	}
+}
+
+// EraseAccountReq is the request body for DELETE /me. The account's email has to be repeated to confirm
+// that the caller means to erase it.
+type EraseAccountReq struct {
+	ConfirmEmail string `json:"confirmEmail" validate:"required"`
+}
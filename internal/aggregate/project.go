@@ -4,28 +4,56 @@ import (
 	"time"
 
 	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
 )
 
 // CreateProjectReq is the request body for creating a project.
 type CreateProjectReq struct {
-	Name        string `json:"name" validate:"required"`
-	Description string `json:"description"`
+	Name         string   `json:"name" validate:"required"`
+	Description  string   `json:"description"`
+	RedirectURLs []string `json:"redirectUrls" validate:"omitempty,dive,url"` // allowed OAuth login redirect targets
+	// AttributeSchema declares the user attributes the project relies on.
+	AttributeSchema []UserAttributeDef `json:"attributeSchema" validate:"omitempty,unique=Key,dive"`
+	// MailFrom is the sender of the project's email, e.g. "Acme <no-reply@acme.com>"; empty uses MAIL_FROM.
+	MailFrom string `json:"mailFrom" validate:"max=255"`
+	// BreachedPasswordPolicy is off, warn or reject for new passwords found in a known breach; empty uses PASSWORD_BREACH_POLICY.
+	BreachedPasswordPolicy constant.BreachedPasswordPolicy `json:"breachedPasswordPolicy" validate:"omitempty,oneof=off warn reject"`
 }
 
 // UpdateProjectReq is the request body for updating a project (partial update).
 type UpdateProjectReq struct {
-	Name        *string `json:"name"`
-	Description *string `json:"description"`
+	Name         *string   `json:"name"`
+	Description  *string   `json:"description"`
+	RedirectURLs *[]string `json:"redirectUrls" validate:"omitempty,dive,url"` // replaces the whole list
+	// AttributeSchema replaces the whole schema; attributes users already hold are kept.
+	AttributeSchema *[]UserAttributeDef `json:"attributeSchema" validate:"omitempty,unique=Key,dive"`
+	MailFrom        *string             `json:"mailFrom" validate:"omitempty,max=255"` // "" goes back to MAIL_FROM
+	// BreachedPasswordPolicy is off, warn or reject; "" goes back to PASSWORD_BREACH_POLICY.
+	BreachedPasswordPolicy *constant.BreachedPasswordPolicy `json:"breachedPasswordPolicy" validate:"omitempty,oneof=off warn reject"`
+}
+
+// UserAttributeDef declares a user attribute in a project's schema. Claim adds it to the attrs claim
+// of tokens signed in to the project.
+type UserAttributeDef struct {
+	Key   string                     `json:"key" validate:"required,max=64"`
+	Type  constant.UserAttributeType `json:"type" validate:"required,oneof=string number boolean object array"`
+	Claim bool                       `json:"claim"`
 }
 
 // ProjectDto is the response DTO for project.
 type ProjectDto struct {
-	ID          string    `json:"id"`
-	Code        string    `json:"code"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID              string             `json:"id"`
+	Code            string             `json:"code"`
+	Name            string             `json:"name"`
+	Description     string             `json:"description"`
+	RedirectURLs    []string           `json:"redirectUrls"`
+	AttributeSchema []UserAttributeDef `json:"attributeSchema"`
+	MailFrom        string             `json:"mailFrom,omitempty"`
+	ArchivedAt      *time.Time         `json:"archivedAt,omitempty"`
+	CreatedAt       time.Time          `json:"createdAt"`
+	UpdatedAt       time.Time          `json:"updatedAt"`
+	// BreachedPasswordPolicy is the project's own policy, empty when it inherits PASSWORD_BREACH_POLICY.
+	BreachedPasswordPolicy constant.BreachedPasswordPolicy `json:"breachedPasswordPolicy,omitempty"`
 }
 
 // FromModel maps a model.Project to ProjectDto.
@@ -37,16 +65,32 @@ func (d *ProjectDto) FromModel(m *model.Project) {
 	d.Code = m.Code
 	d.Name = m.Name
 	d.Description = m.Description
+	d.RedirectURLs = m.RedirectURLList()
+	if d.RedirectURLs == nil {
+		d.RedirectURLs = []string{}
+	}
+	d.AttributeSchema = []UserAttributeDef{}
+	for _, a := range m.AttributeSchemaList() {
+		d.AttributeSchema = append(d.AttributeSchema, UserAttributeDef(a))
+	}
+	d.MailFrom = m.MailFrom
+	d.BreachedPasswordPolicy = m.BreachedPasswordPolicy
+	d.ArchivedAt = m.ArchivedAt
 	d.CreatedAt = m.CreatedAt
 	d.UpdatedAt = m.UpdatedAt
 }
 
 // ToModel maps CreateProjectReq to model.Project.
 func (r *CreateProjectReq) ToModel() *model.Project {
-	return &model.Project{
-		Name:        r.Name,
-		Description: r.Description,
+	p := &model.Project{
+		Name:                   r.Name,
+		Description:            r.Description,
+		MailFrom:               r.MailFrom,
+		BreachedPasswordPolicy: r.BreachedPasswordPolicy,
 	}
+	p.SetRedirectURLs(r.RedirectURLs)
+	p.SetAttributeSchema(attributeSchemaToModel(r.AttributeSchema))
+	return p
 }
 
 // ToModelAndFields returns the model and list of fields to update for UpdateProjectReq.
@@ -60,5 +104,29 @@ func (r *UpdateProjectReq) ToModelAndFields() (p *model.Project, fields []string
 		p.Description = *r.Description
 		fields = append(fields, "description")
 	}
+	if r.RedirectURLs != nil {
+		p.SetRedirectURLs(*r.RedirectURLs)
+		fields = append(fields, "redirect_urls")
+	}
+	if r.AttributeSchema != nil {
+		p.SetAttributeSchema(attributeSchemaToModel(*r.AttributeSchema))
+		fields = append(fields, "attribute_schema")
+	}
+	if r.MailFrom != nil {
+		p.MailFrom = *r.MailFrom
+		fields = append(fields, "mail_from")
+	}
+	if r.BreachedPasswordPolicy != nil {
+		p.BreachedPasswordPolicy = *r.BreachedPasswordPolicy
+		fields = append(fields, "breached_password_policy")
+	}
 	return p, fields
 }
+
+func attributeSchemaToModel(defs []UserAttributeDef) []model.UserAttribute {
+	attributes := make([]model.UserAttribute, 0, len(defs))
+	for _, d := range defs {
+		attributes = append(attributes, model.UserAttribute(d))
+	}
+	return attributes
+}
@@ -0,0 +1,42 @@
+package aggregate
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+)
+
+// InviteProjectMemberReq invites an existing user, found by ID or email, to a project.
+type InviteProjectMemberReq struct {
+	UserID string `json:"userId" validate:"required_without=Email"`
+	Email  string `json:"email" validate:"omitempty,email"`
+}
+
+// ListProjectMembersReq filters a project's members.
+type ListProjectMembersReq struct {
+	Status string `query:"status" validate:"omitempty,oneof=invited active"`
+}
+
+// ProjectMemberResp describes a user's membership of a project.
+type ProjectMemberResp struct {
+	ID         string     `json:"id"`
+	ProjectID  string     `json:"projectId"`
+	UserID     string     `json:"userId"`
+	Status     string     `json:"status"`
+	InvitedBy  string     `json:"invitedBy,omitempty"`
+	AcceptedAt *time.Time `json:"acceptedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func (r *ProjectMemberResp) FromModel(m *model.ProjectMember) {
+	if m == nil {
+		return
+	}
+	r.ID = m.ID
+	r.ProjectID = m.ProjectID
+	r.UserID = m.UserID
+	r.Status = m.Status
+	r.InvitedBy = m.InvitedBy
+	r.AcceptedAt = m.AcceptedAt
+	r.CreatedAt = m.CreatedAt
+}
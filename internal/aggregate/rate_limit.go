@@ -0,0 +1,17 @@
+package aggregate
+
+import "time"
+
+// RateLimitCaller is who a request is counted against: Kind is one of the constant.RateLimitIdentity*
+// values and ID the key, user or IP.
+type RateLimitCaller struct {
+	Kind string
+	ID   string
+}
+
+// RateLimitResp is the state of the caller's bucket after a request.
+type RateLimitResp struct {
+	Limit      int           // requests allowed at once
+	Remaining  int           // requests left before the caller has to wait
+	RetryAfter time.Duration // when rejected, until the next request is allowed
+}
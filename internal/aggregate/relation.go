@@ -18,6 +18,10 @@ type GrantRelationReq struct {
 	
 	// Optional metadata
 	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Condition string     `json:"condition,omitempty"` // Optional: e.g. ip_in_range("10.0.0.0/8")
+	
+	// Upsert updates a stored tuple (reactivating it if expired) instead of failing when it is active
+	Upsert bool `json:"upsert,omitempty"`
 }
 
 // RevokeRelationReq represents a request to revoke a relation tuple
@@ -30,6 +34,19 @@ type RevokeRelationReq struct {
 	SubjectRelation  string `json:"subjectRelation,omitempty"`
 }
 
+// ExtendRelationReq represents a request to renew a relation tuple. Set either ExpiresAt or TTLSeconds;
+// with neither the tuple no longer expires
+type ExtendRelationReq struct {
+	Namespace        string     `json:"namespace" validate:"required"`
+	ObjectID         string     `json:"objectId" validate:"required"`
+	Relation         string     `json:"relation" validate:"required"`
+	SubjectNamespace string     `json:"subjectNamespace" validate:"required"`
+	SubjectObjectID  string     `json:"subjectObjectId" validate:"required"`
+	SubjectRelation  string     `json:"subjectRelation,omitempty"`
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty"`
+	TTLSeconds       int        `json:"ttlSeconds,omitempty" validate:"gte=0"`
+}
+
 // CheckRelationReq represents a request to check if a relation exists
 type CheckRelationReq struct {
 	Namespace        string `json:"namespace" validate:"required"`
@@ -37,6 +54,9 @@ type CheckRelationReq struct {
 	Relation         string `json:"relation" validate:"required"`
 	SubjectNamespace string `json:"subjectNamespace" validate:"required"`
 	SubjectObjectID  string `json:"subjectObjectId" validate:"required"`
+	
+	// Context for tuple conditions, e.g. {"ip": "10.0.0.7"}; ip defaults to the caller's IP
+	Context map[string]any `json:"context,omitempty"`
 }
 
 // CheckRelationResp represents the response of a relation check
@@ -48,15 +68,15 @@ type CheckRelationResp struct {
 // ListRelationsReq represents a request to list relation tuples
 type ListRelationsReq struct {
 	// Filter by object
-	Namespace string `json:"namespace,omitempty"`
-	ObjectID  string `json:"objectId,omitempty"`
+	Namespace string `query:"namespace" form:"namespace" json:"namespace,omitempty"`
+	ObjectID  string `query:"objectId" form:"objectId" json:"objectId,omitempty"`
 	
 	// Filter by relation
-	Relation string `json:"relation,omitempty"`
+	Relation string `query:"relation" form:"relation" json:"relation,omitempty"`
 	
 	// Filter by subject
-	SubjectNamespace string `json:"subjectNamespace,omitempty"`
-	SubjectObjectID  string `json:"subjectObjectId,omitempty"`
+	SubjectNamespace string `query:"subjectNamespace" form:"subjectNamespace" json:"subjectNamespace,omitempty"`
+	SubjectObjectID  string `query:"subjectObjectId" form:"subjectObjectId" json:"subjectObjectId,omitempty"`
 	
 	// Pagination
 	PaginationReq
@@ -71,6 +91,7 @@ type RelationTupleResp struct {
 	SubjectNamespace string     `json:"subjectNamespace"`
 	SubjectObjectID  string     `json:"subjectObjectId"`
 	SubjectRelation  string     `json:"subjectRelation,omitempty"`
+	Condition        string     `json:"condition,omitempty"`
 	IsActive         bool       `json:"isActive"`
 	ExpiresAt        *time.Time `json:"expiresAt,omitempty"`
 	CreatedAt        time.Time  `json:"createdAt"`
@@ -79,7 +100,23 @@ type RelationTupleResp struct {
 
 // BulkGrantRelationReq represents a request to grant multiple relation tuples
 type BulkGrantRelationReq struct {
-	Relations []GrantRelationReq `json:"relations" validate:"required,min=1,dive"`
+	Relations []GrantRelationReq `json:"relations" validate:"required,min=1"` // items are validated one by one
+}
+
+// BulkGrantRelationResp reports the outcome of every item of a bulk grant, in request order
+type BulkGrantRelationResp struct {
+	Results []BulkGrantRelationResult `json:"results"`
+	Created int                       `json:"created"`
+	Skipped int                       `json:"skipped"`
+	Failed  int                       `json:"failed"`
+}
+
+// BulkGrantRelationResult is the outcome of one item: created, skipped (already active) or error
+type BulkGrantRelationResult struct {
+	Index    int                `json:"index"`
+	Status   string             `json:"status"`
+	Relation *RelationTupleResp `json:"relation,omitempty"`
+	Error    string             `json:"error,omitempty"`
 }
 
 // BulkRevokeRelationReq represents a request to revoke multiple relation tuples
@@ -106,3 +143,48 @@ type ExpandRelationResp struct {
 	Subjects []RelationSubjectResp `json:"subjects"`
 	Count    int                   `json:"count"`
 }
+
+// ListObjectsReq represents a request to list the objects of a namespace a subject has a relation on
+type ListObjectsReq struct {
+	SubjectNamespace string `json:"subjectNamespace" validate:"required"`
+	SubjectObjectID  string `json:"subjectObjectId" validate:"required"`
+	Namespace        string `json:"namespace" validate:"required"`
+	Relation         string `json:"relation" validate:"required"`
+}
+
+// ListObjectsResp represents the objects a subject can reach, directly or through usersets and rewrites
+type ListObjectsResp struct {
+	ObjectIDs []string `json:"objectIds"`
+	Count     int      `json:"count"`
+}
+
+// ExportRelationsReq filters the tuples streamed by GET /relations/export
+type ExportRelationsReq struct {
+	Namespace        string `query:"namespace"`
+	Relation         string `query:"relation"`
+	SubjectNamespace string `query:"subjectNamespace"`
+}
+
+// ImportRelationsReq holds the options of POST /relations/import; the tuples themselves are the NDJSON body,
+// one GrantRelationReq per line
+type ImportRelationsReq struct {
+	DryRun     bool   `query:"dryRun"`
+	OnConflict string `query:"onConflict"` // skip (default), overwrite or fail
+}
+
+// ImportRelationsResp reports what an import did, or would do on a dry run. When Errors is not empty
+// nothing was written
+type ImportRelationsResp struct {
+	DryRun  bool                    `json:"dryRun"`
+	Total   int                     `json:"total"`
+	Created int                     `json:"created"`
+	Updated int                     `json:"updated"`
+	Skipped int                     `json:"skipped"`
+	Errors  []ImportRelationLineErr `json:"errors,omitempty"`
+}
+
+// ImportRelationLineErr is a rejected line of an import, numbered from 1
+type ImportRelationLineErr struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,30 @@
+package aggregate
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/relationconfig"
+)
+
+// UpsertRelationNamespaceReq replaces the userset rewrite rules of a namespace.
+// Relations maps each relation name to its rewrite; an empty rewrite matches stored tuples only.
+type UpsertRelationNamespaceReq struct {
+	Relations map[string]*relationconfig.Rewrite `json:"relations" validate:"required"`
+}
+
+// RelationNamespaceResp is a namespace and its rewrite rules.
+type RelationNamespaceResp struct {
+	Name      string                             `json:"name"`
+	Relations map[string]*relationconfig.Rewrite `json:"relations"`
+	UpdatedAt time.Time                          `json:"updatedAt"`
+}
+
+func (r *RelationNamespaceResp) FromModel(m *model.RelationNamespace) {
+	if m == nil {
+		return
+	}
+	r.Name = m.Name
+	r.Relations = m.Rewrites().Relations
+	r.UpdatedAt = m.UpdatedAt
+}
@@ -1,6 +1,7 @@
 package aggregate
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/hiamthach108/dreon-auth/internal/model"
@@ -112,17 +113,18 @@ func (r *UpdateRoleReq) ApplyTo(m *model.Role) {
 
 // ListRolesReq represents a request to list roles
 type ListRolesReq struct {
-	ProjectID *string `form:"projectId" json:"projectId"` // filter by project, "system" for system roles
-	IsActive  *bool   `form:"isActive" json:"isActive"`   // filter by active status
-	Search    string  `form:"search" json:"search"`       // search by code or name
+	ProjectID *string `query:"projectId" form:"projectId" json:"projectId"` // filter by project, "system" for system roles
+	IsActive  *bool   `query:"isActive" form:"isActive" json:"isActive"`    // filter by active status
+	Search    string  `query:"search" form:"search" json:"search"`          // search by code or name
 	PaginationReq
 }
 
 // AssignRoleToUserReq represents a request to assign a role to a user
 type AssignRoleToUserReq struct {
-	UserID    string  `json:"userId" validate:"required"`
-	RoleID    string  `json:"roleId" validate:"required"`
-	ProjectID *string `json:"projectId"` // null for system role assignment
+	UserID    string     `json:"userId" validate:"required"`
+	RoleID    string     `json:"roleId" validate:"required"`
+	ProjectID *string    `json:"projectId"` // null for system role assignment
+	ExpiresAt *time.Time `json:"expiresAt"` // null for a permanent assignment
 }
 
 // RemoveRoleFromUserReq represents a request to remove a role from a user
@@ -132,14 +134,46 @@ type RemoveRoleFromUserReq struct {
 	ProjectID *string `json:"projectId"`
 }
 
+// BulkAssignRolesReq represents a request to assign several roles at once
+type BulkAssignRolesReq struct {
+	Assignments []AssignRoleToUserReq `json:"assignments" validate:"required,min=1,max=1000"` // items are validated one by one
+}
+
+// BulkRemoveRolesReq represents a request to remove several role assignments at once
+type BulkRemoveRolesReq struct {
+	Assignments []RemoveRoleFromUserReq `json:"assignments" validate:"required,min=1,max=1000"` // items are validated one by one
+}
+
+// GetUsersPermissionsReq represents a request for the permissions of several users at once
+type GetUsersPermissionsReq struct {
+	UserIDs []string `json:"userIds" validate:"required,min=1,max=1000"`
+}
+
+// BulkRoleAssignmentResp reports the outcome of every item of a bulk assignment or removal, in request order
+type BulkRoleAssignmentResp struct {
+	Results   []BulkRoleAssignmentResult `json:"results"`
+	Succeeded int                        `json:"succeeded"`
+	Skipped   int                        `json:"skipped"`
+	Failed    int                        `json:"failed"`
+}
+
+// BulkRoleAssignmentResult is the outcome of one item: assigned or removed, skipped, or error
+type BulkRoleAssignmentResult struct {
+	Index      int           `json:"index"`
+	Status     string        `json:"status"`
+	Assignment *UserRoleResp `json:"assignment,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
 // UserRoleResp represents a user role assignment response
 type UserRoleResp struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"userId"`
-	RoleID    string    `json:"roleId"`
-	ProjectID *string   `json:"projectId"`
-	Role      *RoleResp `json:"role,omitempty"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID        string     `json:"id"`
+	UserID    string     `json:"userId"`
+	RoleID    string     `json:"roleId"`
+	ProjectID *string    `json:"projectId"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Role      *RoleResp  `json:"role,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
 }
 
 // GetUserRolesReq represents a request to get user roles
@@ -158,6 +192,7 @@ func UserRoleRespFromModel(userRole *model.UserRole, role *model.Role) *UserRole
 		UserID:    userRole.UserID,
 		RoleID:    userRole.RoleID,
 		ProjectID: userRole.ProjectID,
+		ExpiresAt: userRole.ExpiresAt,
 		CreatedAt: userRole.CreatedAt,
 	}
 	if role != nil {
@@ -166,5 +201,47 @@ func UserRoleRespFromModel(userRole *model.UserRole, role *model.Role) *UserRole
 	return r
 }
 
+// RoleHistoryResp is one recorded change to a role or its assignments. Before and After are the role, or the
+// assignment for assign and remove, as the API showed it; null when it did not exist.
+type RoleHistoryResp struct {
+	ID        string          `json:"id"`
+	RoleID    string          `json:"roleId"`
+	ProjectID *string         `json:"projectId"`
+	Action    string          `json:"action"`
+	ActorID   string          `json:"actorId,omitempty"`
+	ActorType string          `json:"actorType,omitempty"`
+	UserID    string          `json:"userId,omitempty"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	RequestID string          `json:"requestId,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+func (r *RoleHistoryResp) FromModel(m *model.RoleHistory) {
+	if m == nil {
+		return
+	}
+	r.ID = m.ID
+	r.RoleID = m.RoleID
+	r.ProjectID = m.ProjectID
+	r.Action = m.Action
+	r.ActorID = m.ActorID
+	r.ActorType = m.ActorType
+	r.UserID = m.UserID
+	if len(m.Before) > 0 {
+		r.Before = json.RawMessage(m.Before)
+	}
+	if len(m.After) > 0 {
+		r.After = json.RawMessage(m.After)
+	}
+	r.CreatedAt = m.CreatedAt
+	var meta struct {
+		RequestID string `json:"request_id"`
+	}
+	if len(m.Metadata) > 0 && json.Unmarshal(m.Metadata, &meta) == nil {
+		r.RequestID = meta.RequestID
+	}
+}
+
 // UserPermission
 type UserPermissions map[string]bool
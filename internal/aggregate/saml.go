@@ -0,0 +1,52 @@
+package aggregate
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+)
+
+// StartSAMLLoginReq starts SP-initiated SSO; the browser lands on RedirectURL with a refreshState.
+type StartSAMLLoginReq struct {
+	RedirectURL         string
+	CodeChallenge       string // optional PKCE challenge, verified at /auth/session-from-state
+	CodeChallengeMethod string // S256
+}
+
+// UpsertSAMLConnectionReq replaces a project's SAML identity provider.
+type UpsertSAMLConnectionReq struct {
+	IdPMetadataXML  string   `json:"idpMetadataXml" validate:"required"`
+	EmailAttribute  string   `json:"emailAttribute"` // empty means the NameID is the email
+	NameAttribute   string   `json:"nameAttribute"`
+	GroupsAttribute string   `json:"groupsAttribute"`
+	AllowedDomains  []string `json:"allowedDomains" validate:"required,min=1,dive,fqdn"` // email domains the IdP may sign in
+	IsActive        *bool    `json:"isActive"`                                           // defaults to true
+}
+
+// SAMLConnectionResp is a project's SAML connection plus the SP values to register at the IdP.
+type SAMLConnectionResp struct {
+	ProjectID       string    `json:"projectId"`
+	IdPEntityID     string    `json:"idpEntityId"`
+	EmailAttribute  string    `json:"emailAttribute,omitempty"`
+	NameAttribute   string    `json:"nameAttribute,omitempty"`
+	GroupsAttribute string    `json:"groupsAttribute,omitempty"`
+	AllowedDomains  []string  `json:"allowedDomains"`
+	IsActive        bool      `json:"isActive"`
+	SPEntityID      string    `json:"spEntityId"`
+	ACSURL          string    `json:"acsUrl"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+func (r *SAMLConnectionResp) FromModel(m *model.SAMLConnection) {
+	if m == nil {
+		return
+	}
+	r.ProjectID = m.ProjectID
+	r.IdPEntityID = m.IdPEntityID
+	r.EmailAttribute = m.EmailAttribute
+	r.NameAttribute = m.NameAttribute
+	r.GroupsAttribute = m.GroupsAttribute
+	r.AllowedDomains = m.Domains()
+	r.IsActive = m.IsActive
+	r.UpdatedAt = m.UpdatedAt
+}
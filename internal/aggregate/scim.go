@@ -0,0 +1,175 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/scim"
+)
+
+// SCIMMeta is the meta attribute of a SCIM resource.
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// SCIMEmail is one entry of a SCIM user's emails.
+type SCIMEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMUser is a SCIM User resource. Attributes dreon-auth does not store (name, phoneNumbers, ...) are
+// accepted on requests and dropped.
+type SCIMUser struct {
+	Schemas    []string    `json:"schemas"`
+	ID         string      `json:"id,omitempty"`
+	ExternalID string      `json:"externalId,omitempty"`
+	UserName   string      `json:"userName" validate:"required"`
+	Emails     []SCIMEmail `json:"emails,omitempty"`
+	Active     *bool       `json:"active,omitempty"`
+	Meta       *SCIMMeta   `json:"meta,omitempty"`
+}
+
+// Email returns the primary email, the first email, or userName when it is an address.
+func (u *SCIMUser) Email() string {
+	for _, e := range u.Emails {
+		if e.Primary && e.Value != "" {
+			return e.Value
+		}
+	}
+	if len(u.Emails) > 0 && u.Emails[0].Value != "" {
+		return u.Emails[0].Value
+	}
+	if strings.Contains(u.UserName, "@") {
+		return u.UserName
+	}
+	return ""
+}
+
+// SCIMUserFromModel maps a provisioned user and its SCIM link to the User resource.
+func SCIMUserFromModel(link *model.SCIMUser) *SCIMUser {
+	if link == nil {
+		return nil
+	}
+	active := !link.User.IsDisabled()
+	return &SCIMUser{
+		Schemas:    []string{scim.SchemaUser},
+		ID:         link.UserID,
+		ExternalID: link.ExternalID,
+		UserName:   link.User.Username,
+		Emails:     []SCIMEmail{{Value: link.User.Email, Type: "work", Primary: true}},
+		Active:     &active,
+		Meta: &SCIMMeta{
+			ResourceType: "User",
+			Created:      link.User.CreatedAt,
+			LastModified: link.User.UpdatedAt,
+		},
+	}
+}
+
+// SCIMGroupMember is one member of a SCIM group; Value is the member's user ID.
+type SCIMGroupMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+// SCIMGroup is a SCIM Group resource, backed by one of the project's roles.
+type SCIMGroup struct {
+	Schemas     []string          `json:"schemas"`
+	ID          string            `json:"id,omitempty"`
+	DisplayName string            `json:"displayName" validate:"required"`
+	Members     []SCIMGroupMember `json:"members,omitempty"`
+	Meta        *SCIMMeta         `json:"meta,omitempty"`
+}
+
+// SCIMGroupFromModel maps a role and its assignments in the role's project to the Group resource.
+func SCIMGroupFromModel(role *model.Role, members []model.UserRole) *SCIMGroup {
+	if role == nil {
+		return nil
+	}
+	group := &SCIMGroup{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          role.ID,
+		DisplayName: role.Name,
+		Members:     make([]SCIMGroupMember, 0, len(members)),
+		Meta: &SCIMMeta{
+			ResourceType: "Group",
+			Created:      role.CreatedAt,
+			LastModified: role.UpdatedAt,
+		},
+	}
+	for _, m := range members {
+		group.Members = append(group.Members, SCIMGroupMember{Value: m.UserID, Display: m.User.Username})
+	}
+	return group
+}
+
+// SCIMListReq holds the query parameters of a SCIM list request. StartIndex is 1-based.
+// ExcludedAttributes=members skips loading group members, as Azure AD requests.
+type SCIMListReq struct {
+	Filter             string `query:"filter"`
+	StartIndex         int    `query:"startIndex"`
+	Count              int    `query:"count"`
+	ExcludedAttributes string `query:"excludedAttributes"`
+}
+
+// SCIMListResp is a SCIM ListResponse.
+type SCIMListResp[T any] struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []T      `json:"Resources"`
+}
+
+// SCIMPatchOp is one operation of a SCIM PATCH request. Op is matched case-insensitively because
+// Azure AD sends "Replace" and "Add".
+type SCIMPatchOp struct {
+	Op    string          `json:"op" validate:"required"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// SCIMPatchReq is a SCIM PatchOp request.
+type SCIMPatchReq struct {
+	Schemas    []string      `json:"schemas"`
+	Operations []SCIMPatchOp `json:"Operations" validate:"required,min=1,dive"`
+}
+
+// SCIMServiceProviderConfig advertises which optional SCIM features are supported.
+type SCIMServiceProviderConfig struct {
+	Schemas               []string          `json:"schemas"`
+	Patch                 SCIMSupported     `json:"patch"`
+	Bulk                  SCIMBulkSupported `json:"bulk"`
+	Filter                SCIMFilterSupport `json:"filter"`
+	ChangePassword        SCIMSupported     `json:"changePassword"`
+	Sort                  SCIMSupported     `json:"sort"`
+	ETag                  SCIMSupported     `json:"etag"`
+	AuthenticationSchemes []SCIMAuthScheme  `json:"authenticationSchemes"`
+}
+
+type SCIMSupported struct {
+	Supported bool `json:"supported"`
+}
+
+type SCIMBulkSupported struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type SCIMFilterSupport struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type SCIMAuthScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,52 @@
+package aggregate
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+)
+
+// CreateServiceAccountReq creates a client_credentials client for a project.
+type CreateServiceAccountReq struct {
+	Name        string   `json:"name" validate:"required,max=255"`
+	Permissions []string `json:"permissions"` // permission codes the account may request in the project
+}
+
+// ServiceAccountResp describes a service account without its secret.
+type ServiceAccountResp struct {
+	ID          string     `json:"id"`
+	ProjectID   string     `json:"projectId"`
+	Name        string     `json:"name"`
+	ClientID    string     `json:"clientId"`
+	Permissions []string   `json:"permissions"`
+	LastTokenAt *time.Time `json:"lastTokenAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+func (r *ServiceAccountResp) FromModel(m *model.ServiceAccount) {
+	if m == nil {
+		return
+	}
+	r.ID = m.ID
+	r.ProjectID = m.ProjectID
+	r.Name = m.Name
+	r.ClientID = m.ClientID
+	r.Permissions = m.PermissionList()
+	r.LastTokenAt = m.LastTokenAt
+	r.CreatedAt = m.CreatedAt
+}
+
+// CreateServiceAccountResp carries the client secret, which is returned only once.
+type CreateServiceAccountResp struct {
+	ServiceAccountResp
+	ClientSecret string `json:"clientSecret"`
+}
+
+// ClientCredentialsTokenReq is a client_credentials token request (form body, RFC 6749 section 4.4).
+// Client credentials may instead be sent with HTTP Basic auth.
+type ClientCredentialsTokenReq struct {
+	GrantType    string `form:"grant_type"`
+	Scope        string `form:"scope"` // space-separated permission codes; all of the account's when empty
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
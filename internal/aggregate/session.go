@@ -0,0 +1,74 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+)
+
+// SearchSessionsReq filters the sessions of all users for GET /sessions. from and to (RFC 3339) bound when
+// the session was issued; active selects active, unexpired sessions (true) or ended and expired ones (false).
+type SearchSessionsReq struct {
+	UserID string     `query:"userId" form:"userId" json:"userId"`
+	IP     string     `query:"ip" form:"ip" json:"ip" validate:"omitempty,ip"`
+	From   *time.Time `query:"from" form:"from" json:"from"`
+	To     *time.Time `query:"to" form:"to" json:"to"`
+	Active *bool      `query:"active" form:"active" json:"active"`
+	PaginationReq
+}
+
+// SessionDetailResp is a session of any user, with the request it was issued for.
+type SessionDetailResp struct {
+	UserSessionResp
+	UserID       string `json:"userId"`
+	Email        string `json:"email,omitempty"`
+	IsSuperAdmin bool   `json:"isSuperAdmin"`
+	IP           string `json:"ip,omitempty"`
+	UserAgent    string `json:"userAgent,omitempty"`
+	Country      string `json:"country,omitempty"`
+}
+
+func (r *SessionDetailResp) FromModel(m *model.Session) {
+	if m == nil {
+		return
+	}
+	r.UserSessionResp.FromModel(m)
+	r.UserID = m.UserID
+	r.Email = m.Email
+	r.IsSuperAdmin = m.IsSuperAdmin
+	var meta struct {
+		IP        string `json:"ip"`
+		UserAgent string `json:"user_agent"`
+		Country   string `json:"country"`
+	}
+	if json.Unmarshal(m.Metadata, &meta) == nil {
+		r.IP = meta.IP
+		r.UserAgent = meta.UserAgent
+		r.Country = meta.Country
+	}
+}
+
+// SessionStatsReq is the range for GET /sessions/stats (RFC 3339). Both default to the last 30 days.
+type SessionStatsReq struct {
+	From *time.Time `query:"from" form:"from" json:"from"`
+	To   *time.Time `query:"to" form:"to" json:"to"`
+}
+
+// DailyCountResp is a count for one UTC day.
+type DailyCountResp struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Count int64  `json:"count"`
+}
+
+// SessionStatsResp summarises sign-in activity between From and To.
+type SessionStatsResp struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// DailyActiveUsers counts, for every UTC day in the range, the users who signed in or refreshed a session.
+	DailyActiveUsers []DailyCountResp `json:"dailyActiveUsers"`
+	// LoginsByMethod counts the logins by auth type (EMAIL, GOOGLE, ...); refreshes are not logins.
+	LoginsByMethod map[string]int64 `json:"loginsByMethod"`
+	// DeniedLogins counts the logins rejected by a project access policy, from the denial audit log.
+	DeniedLogins int64 `json:"deniedLogins"`
+}
@@ -0,0 +1,12 @@
+package aggregate
+
+import "time"
+
+// SigningKeyResp describes one JWT key without its private half.
+type SigningKeyResp struct {
+	KID         string     `json:"kid"`
+	Source      string     `json:"source"`  // "config" or "managed"
+	Signing     bool       `json:"signing"` // new tokens are signed with this key
+	ActivatesAt *time.Time `json:"activatesAt,omitempty"`
+	CreatedAt   *time.Time `json:"createdAt,omitempty"`
+}
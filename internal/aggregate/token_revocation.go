@@ -0,0 +1,6 @@
+package aggregate
+
+// RevokeTokenReq revokes an access token before it expires. An empty Token revokes the caller's own token.
+type RevokeTokenReq struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,44 @@
+package aggregate
+
+import "time"
+
+// UsageQuota caps requests per calendar window (UTC). Zero means unlimited.
+type UsageQuota struct {
+	Daily   int64 `json:"daily"`
+	Monthly int64 `json:"monthly"`
+}
+
+// UsageWindow is the request count for one window and when it resets.
+type UsageWindow struct {
+	Used     int64     `json:"used"`
+	Limit    int64     `json:"limit,omitempty"` // omitted when unlimited
+	ResetsAt time.Time `json:"resetsAt"`
+}
+
+// UsageResp reports metered usage for a caller (e.g. an API key).
+type UsageResp struct {
+	SubjectID string      `json:"subjectId"`
+	Daily     UsageWindow `json:"daily"`
+	Monthly   UsageWindow `json:"monthly"`
+}
+
+// Exceeded reports whether either window is over its limit.
+func (u *UsageResp) Exceeded() bool {
+	return (u.Daily.Limit > 0 && u.Daily.Used > u.Daily.Limit) ||
+		(u.Monthly.Limit > 0 && u.Monthly.Used > u.Monthly.Limit)
+}
+
+// UsageCounts are the requests of one kind in the current day and month.
+type UsageCounts struct {
+	Daily   int64 `json:"daily"`
+	Monthly int64 `json:"monthly"`
+}
+
+// ProjectUsageResp reports a project's metered requests against its quota, with a breakdown by kind
+// (see constant.ProjectUsage*).
+type ProjectUsageResp struct {
+	ProjectID string                 `json:"projectId"`
+	Daily     UsageWindow            `json:"daily"`
+	Monthly   UsageWindow            `json:"monthly"`
+	ByKind    map[string]UsageCounts `json:"byKind"`
+}
@@ -4,12 +4,14 @@ import (
 	"time"
 
 	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
 )
 
 // CreateUserReq is the request body for creating a user.
 type CreateUserReq struct {
 	Username string `json:"username" validate:"required"`
 	Email    string `json:"email" validate:"required,email"`
+	Phone    string `json:"phone" validate:"omitempty,e164"`
 	Password string `json:"password" validate:"required,min=8"`
 }
 
@@ -17,16 +19,65 @@ type CreateUserReq struct {
 type UpdateUserReq struct {
 	Username *string `json:"username"`
 	Email    *string `json:"email" validate:"omitempty,email"`
+	Phone    *string `json:"phone" validate:"omitempty,e164"` // empty string clears the number
 	Password *string `json:"password" validate:"omitempty,min=8"`
 }
 
+// UpdateProfileReq is the request body for a user editing their own profile (partial update).
+// Email changes go through the admin API, since the address identifies the account.
+type UpdateProfileReq struct {
+	Username *string `json:"username" validate:"omitempty,min=1"`
+	Phone    *string `json:"phone" validate:"omitempty,e164"` // empty string clears the number
+}
+
+// ChangePasswordReq is the request body for a user changing their own password. CurrentPassword may be
+// left out while the user is required to reset their password.
+type ChangePasswordReq struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8"`
+}
+
+// ChangePasswordResp is the response of a password change.
+type ChangePasswordResp struct {
+	Warnings []string `json:"warnings,omitempty"` // e.g. that the new password was found in a breach
+}
+
+// UpdateUserStatusReq is the request body for activating, deactivating or blocking a user.
+type UpdateUserStatusReq struct {
+	Status constant.UserStatus `json:"status" validate:"required,oneof=ACTIVE INACTIVE BLOCKED"`
+}
+
+// UpdateUserAttributesReq merges attributes into a user's custom attributes; a null value removes the key.
+type UpdateUserAttributesReq struct {
+	Attributes map[string]any `json:"attributes" validate:"required"`
+}
+
+// ImpersonateReq is the request body for a super admin signing in as a user. Reason is kept in the audit record.
+type ImpersonateReq struct {
+	Reason    string `json:"reason" validate:"required,max=500"`
+	ProjectID string `json:"projectId"` // optional project the token is signed in to
+}
+
+// ImpersonationResp holds the impersonation token. It has no refresh token and cannot be extended.
+type ImpersonationResp struct {
+	ImpersonationID string    `json:"impersonationId"`
+	UserID          string    `json:"userId"`
+	SessionID       string    `json:"sessionId"`
+	AccessToken     string    `json:"accessToken"`
+	ExpiresAt       time.Time `json:"expiresAt"`
+}
+
 // UserDto is the response DTO for user (password omitted).
 type UserDto struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID                    string         `json:"id"`
+	Username              string         `json:"username"`
+	Email                 string         `json:"email"`
+	Phone                 string         `json:"phone,omitempty"`
+	Status                string         `json:"status"`
+	Attributes            map[string]any `json:"attributes"`
+	PasswordResetRequired bool           `json:"passwordResetRequired,omitempty"`
+	CreatedAt             time.Time      `json:"createdAt"`
+	UpdatedAt             time.Time      `json:"updatedAt"`
 }
 
 // FromModel maps a model.User to UserDto (excludes password).
@@ -37,6 +88,10 @@ func (d *UserDto) FromModel(m *model.User) {
 	d.ID = m.ID
 	d.Username = m.Username
 	d.Email = m.Email
+	d.Phone = m.Phone
+	d.Status = m.Status.String()
+	d.Attributes = m.AttributeMap()
+	d.PasswordResetRequired = m.PasswordResetRequired
 	d.CreatedAt = m.CreatedAt
 	d.UpdatedAt = m.UpdatedAt
 }
@@ -46,7 +101,9 @@ func (r *CreateUserReq) ToModel(hashedPassword string) *model.User {
 	return &model.User{
 		Username: r.Username,
 		Email:    r.Email,
+		Phone:    r.Phone,
 		Password: hashedPassword,
+		Status:   constant.UserStatusActive,
 	}
 }
 
@@ -61,6 +118,10 @@ func (r *UpdateUserReq) ToModelAndFields() (u *model.User, fields []string) {
 		u.Email = *r.Email
 		fields = append(fields, "email")
 	}
+	if r.Phone != nil {
+		u.Phone = *r.Phone
+		fields = append(fields, "phone")
+	}
 	if r.Password != nil {
 		u.Password = *r.Password
 		fields = append(fields, "password")
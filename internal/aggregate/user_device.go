@@ -0,0 +1,37 @@
+package aggregate
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+)
+
+// UserDeviceResp is a device the calling user signed in from.
+type UserDeviceResp struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name,omitempty"`
+	Platform       string    `json:"platform,omitempty"`
+	UserAgent      string    `json:"userAgent,omitempty"`
+	LastIP         string    `json:"lastIp,omitempty"`
+	LastCountry    string    `json:"lastCountry,omitempty"`
+	LastSeenAt     time.Time `json:"lastSeenAt"`
+	CreatedAt      time.Time `json:"createdAt"`
+	ActiveSessions int       `json:"activeSessions"`
+	Current        bool      `json:"current"` // the device of the session making the request
+}
+
+func (r *UserDeviceResp) FromModel(m *model.UserDevice) {
+	r.ID = m.ID
+	r.Name = m.Name
+	r.Platform = m.Platform
+	r.UserAgent = m.UserAgent
+	r.LastIP = m.LastIP
+	r.LastCountry = m.LastCountry
+	r.LastSeenAt = m.LastSeenAt
+	r.CreatedAt = m.CreatedAt
+}
+
+// RenameUserDeviceReq names one of the calling user's devices; an empty name clears it.
+type RenameUserDeviceReq struct {
+	Name string `json:"name" validate:"max=100"`
+}
@@ -0,0 +1,43 @@
+package aggregate
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+)
+
+// UserIdentityResp is an external login linked to the calling user.
+type UserIdentityResp struct {
+	ID         string                `json:"id"`
+	AuthType   constant.UserAuthType `json:"authType"`
+	Provider   string                `json:"provider,omitempty"`
+	Email      string                `json:"email,omitempty"`
+	CreatedAt  time.Time             `json:"createdAt"`
+	LastUsedAt *time.Time            `json:"lastUsedAt,omitempty"`
+}
+
+func (r *UserIdentityResp) FromModel(m *model.UserIdentity) {
+	r.ID = m.ID
+	r.AuthType = m.AuthType
+	r.Provider = m.Provider
+	r.Email = m.Email
+	r.CreatedAt = m.CreatedAt
+	r.LastUsedAt = m.LastUsedAt
+}
+
+// ConfirmIdentityLinkReq confirms linking an external login to the calling user's account.
+type ConfirmIdentityLinkReq struct {
+	LinkToken string `json:"linkToken" validate:"required"`
+}
+
+// PendingIdentityLink is sealed into the link token returned when an external login matches an existing
+// account by email. ID keys the single-use marker in the cache.
+type PendingIdentityLink struct {
+	ID             string                `json:"id"`
+	UserID         string                `json:"userId"`
+	AuthType       constant.UserAuthType `json:"authType"`
+	Provider       string                `json:"provider,omitempty"`
+	ProviderUserID string                `json:"providerUserId"`
+	Email          string                `json:"email"`
+}
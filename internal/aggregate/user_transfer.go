@@ -0,0 +1,75 @@
+package aggregate
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+)
+
+// UserImportRecord is one user in a bulk import file: an NDJSON line, or a CSV row under a header
+// naming these keys (attributes as a JSON object). Other keys, such as those of an export, are ignored.
+type UserImportRecord struct {
+	Email        string              `json:"email"`
+	Username     string              `json:"username"` // defaults to the email
+	Phone        string              `json:"phone"`
+	PasswordHash string              `json:"passwordHash"` // bcrypt or argon2id; without one the user has to set a new password
+	Status       constant.UserStatus `json:"status"`       // defaults to ACTIVE
+	Attributes   map[string]any      `json:"attributes"`
+	// PasswordPepperVersion is the PASSWORD_PEPPERS version PasswordHash was made with, 0 for none.
+	PasswordPepperVersion int `json:"passwordPepperVersion"`
+}
+
+// UserImportJob reports the progress of a bulk user import.
+type UserImportJob struct {
+	ID                 string            `json:"id"`
+	Status             string            `json:"status"` // running, completed or failed
+	ForcePasswordReset bool              `json:"forcePasswordReset"`
+	Total              int               `json:"total"`
+	Imported           int               `json:"imported"`
+	Skipped            int               `json:"skipped"`
+	Errors             []UserImportError `json:"errors"` // the first UserImportMaxErrors rejected records
+	StartedAt          time.Time         `json:"startedAt"`
+	FinishedAt         *time.Time        `json:"finishedAt,omitempty"`
+}
+
+// UserImportError explains why a record of an import file was skipped.
+type UserImportError struct {
+	Line    int    `json:"line"`
+	Email   string `json:"email,omitempty"`
+	Message string `json:"message"`
+}
+
+// UserExportRecord is one user in a bulk export. PasswordHash is only filled in when asked for.
+type UserExportRecord struct {
+	ID           string         `json:"id"`
+	Email        string         `json:"email"`
+	Username     string         `json:"username"`
+	Phone        string         `json:"phone,omitempty"`
+	Status       string         `json:"status"`
+	PasswordHash string         `json:"passwordHash,omitempty"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	CreatedAt    time.Time      `json:"createdAt"`
+	// PasswordPepperVersion goes with PasswordHash: the hash only verifies where that pepper is configured.
+	PasswordPepperVersion int `json:"passwordPepperVersion,omitempty"`
+}
+
+// FromModel maps a model.User to UserExportRecord, with the password hash only when withPasswordHash is set.
+func (r *UserExportRecord) FromModel(m *model.User, withPasswordHash bool) {
+	if m == nil {
+		return
+	}
+	r.ID = m.ID
+	r.Email = m.Email
+	r.Username = m.Username
+	r.Phone = m.Phone
+	r.Status = m.Status.String()
+	if withPasswordHash {
+		r.PasswordHash = m.Password
+		r.PasswordPepperVersion = m.PasswordPepperVersion
+	}
+	if attributes := m.AttributeMap(); len(attributes) > 0 {
+		r.Attributes = attributes
+	}
+	r.CreatedAt = m.CreatedAt
+}
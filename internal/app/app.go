@@ -0,0 +1,118 @@
+// Package app lists the constructors of the server's components, so the server and dreonctl build them
+// the same way.
+package app
+
+import (
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/oidc"
+	"github.com/hiamthach108/dreon-auth/internal/shared/permission"
+	"github.com/hiamthach108/dreon-auth/internal/shared/rolemapping"
+	"github.com/hiamthach108/dreon-auth/pkg/background"
+	"github.com/hiamthach108/dreon-auth/pkg/breach"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/captcha"
+	"github.com/hiamthach108/dreon-auth/pkg/database"
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/mailer"
+	"github.com/hiamthach108/dreon-auth/pkg/password"
+	"github.com/hiamthach108/dreon-auth/pkg/sms"
+	"github.com/hiamthach108/dreon-auth/pkg/statetoken"
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
+	"go.uber.org/fx"
+)
+
+// Core provides configuration, logging and the clients of the external systems the services use.
+var Core = fx.Provide(
+	config.NewAppConfig,
+	config.NewNotifier,
+	logger.NewLogger,
+	tracing.NewProviderFromConfig,
+	background.NewGroup,
+	cache.NewAppCache,
+	password.NewHasher,
+	mailer.NewMailer,
+	sms.NewSender,
+	captcha.NewVerifier,
+	breach.NewChecker,
+	eventbus.NewPublisher,
+	database.NewDbClient,
+	jwt.NewKeySetFromConfig,
+	jwt.NewAccessTokenManagerFromConfig,
+	statetoken.NewSealerFromConfig,
+	permission.NewRegistryFromConfig,
+	rolemapping.NewTableFromConfig,
+	oidc.NewClientRegistryFromConfig,
+)
+
+// Services provides the business services.
+var Services = fx.Provide(
+	service.NewUserSvc,
+	service.NewPasswordPolicySvc,
+	service.NewAuthSvc,
+	fx.Annotate(service.NewClaimsEnrichers, fx.ParamTags(service.ClaimsEnricherGroup)),
+	service.NewProjectSvc,
+	service.NewRelationSvc,
+	service.NewRoleSvc,
+	service.NewCredentialSvc,
+	service.NewAccessPolicySvc,
+	service.NewTrustedDeviceSvc,
+	service.NewUserDeviceSvc,
+	service.NewSessionSvc,
+	service.NewSAMLSvc,
+	service.NewLogoutNotifier,
+	service.NewOAuthProviderRegistryFromConfig,
+	service.NewUsageSvc,
+	service.NewProjectUsageSvc,
+	service.NewSigningKeySvc,
+	service.NewTokenRevocationSvc,
+	service.NewAPIKeySvc,
+	service.NewProjectMemberSvc,
+	service.NewNotificationTemplateSvc,
+	service.NewUserIdentitySvc,
+	service.NewPrivacySvc,
+	service.NewUserTransferSvc,
+	service.NewServiceAccountSvc,
+	service.NewSCIMSvc,
+	service.NewPermissionSvc,
+	service.NewRateLimitSvc,
+	service.NewHealthSvc,
+	service.NewIPFilterSvc,
+	service.NewEffectiveAccessSvc,
+)
+
+// Repositories provides the PostgreSQL repositories.
+var Repositories = fx.Provide(
+	repository.NewUserRepository,
+	repository.NewSuperAdminRepository,
+	repository.NewProjectRepository,
+	repository.NewSessionRepository,
+	repository.NewRelationTupleRepository,
+	repository.NewRelationNamespaceRepository,
+	repository.NewRoleRepository,
+	repository.NewUserRoleRepository,
+	repository.NewRoleHistoryRepository,
+	repository.NewUserCredentialRepository,
+	repository.NewAccessPolicyRepository,
+	repository.NewAccessDenialRepository,
+	repository.NewTrustedDeviceRepository,
+	repository.NewUserDeviceRepository,
+	repository.NewImpersonationRepository,
+	repository.NewSAMLConnectionRepository,
+	repository.NewSigningKeyRepository,
+	repository.NewRevokedTokenRepository,
+	repository.NewAPIKeyRepository,
+	repository.NewProjectMemberRepository,
+	repository.NewNotificationTemplateRepository,
+	repository.NewUserIdentityRepository,
+	repository.NewServiceAccountRepository,
+	repository.NewSCIMUserRepository,
+	repository.NewPermissionRepository,
+	repository.NewTxManager,
+	repository.NewHealthRepository,
+	repository.NewIPBlockRepository,
+	repository.NewEffectiveAccessRepository,
+)
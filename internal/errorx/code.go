@@ -4,14 +4,15 @@ type AppErrCode int
 
 const (
 	// General errors
-	ErrInternal      AppErrCode = 500
-	ErrBadRequest    AppErrCode = 400
-	ErrNotFound      AppErrCode = 404
-	ErrUnauthorized  AppErrCode = 401
-	ErrForbidden     AppErrCode = 403
-	ErrConflict      AppErrCode = 409
-	ErrUnprocessable AppErrCode = 422
-	ErrRateLimit     AppErrCode = 429
+	ErrInternal        AppErrCode = 500
+	ErrBadRequest      AppErrCode = 400
+	ErrNotFound        AppErrCode = 404
+	ErrUnauthorized    AppErrCode = 401
+	ErrForbidden       AppErrCode = 403
+	ErrConflict        AppErrCode = 409
+	ErrUnprocessable   AppErrCode = 422
+	ErrCaptchaRequired AppErrCode = 428
+	ErrRateLimit       AppErrCode = 429
 
 	// Business errors
 	ErrUserNotFound        AppErrCode = 1001
@@ -45,17 +46,35 @@ const (
 	ErrInvalidRole         AppErrCode = 1029
 	ErrRoleAssignment      AppErrCode = 1030
 	ErrInvalidRefreshState AppErrCode = 1031
+	ErrCredentialNotFound  AppErrCode = 1032
+	ErrUpdateCredential    AppErrCode = 1033
+	ErrDeleteCredential    AppErrCode = 1034
+	ErrPolicyNotFound      AppErrCode = 1035
+	ErrDeviceNotFound      AppErrCode = 1036
+	ErrSAMLNotFound        AppErrCode = 1037
+	ErrSigningKeyNotFound  AppErrCode = 1038
+	ErrAPIKeyNotFound      AppErrCode = 1039
+	ErrSvcAccountNotFound  AppErrCode = 1040
+	ErrNamespaceNotFound   AppErrCode = 1041
+	ErrMemberNotFound      AppErrCode = 1042
+	ErrIdentityNotFound    AppErrCode = 1043
+	ErrUserDeviceNotFound  AppErrCode = 1044
+	ErrTemplateNotFound    AppErrCode = 1045
+	ErrPasswordBreached    AppErrCode = 1046
+	ErrIPBlockNotFound     AppErrCode = 1047
+	ErrIPBanNotFound       AppErrCode = 1048
 )
 
 var errorMsgs = map[AppErrCode]string{
-	ErrInternal:      "Internal server error",
-	ErrBadRequest:    "Bad request",
-	ErrNotFound:      "Resource not found",
-	ErrUnauthorized:  "Unauthorized access",
-	ErrForbidden:     "Forbidden access",
-	ErrConflict:      "Resource conflict",
-	ErrUnprocessable: "Unprocessable entity",
-	ErrRateLimit:     "Too many requests",
+	ErrInternal:        "Internal server error",
+	ErrBadRequest:      "Bad request",
+	ErrNotFound:        "Resource not found",
+	ErrUnauthorized:    "Unauthorized access",
+	ErrForbidden:       "Forbidden access",
+	ErrConflict:        "Resource conflict",
+	ErrUnprocessable:   "Unprocessable entity",
+	ErrCaptchaRequired: "Captcha required",
+	ErrRateLimit:       "Too many requests",
 
 	ErrUserNotFound:        "User not found",
 	ErrUserConflict:        "User already exists",
@@ -91,6 +110,26 @@ var errorMsgs = map[AppErrCode]string{
 	ErrSystemRoleProtected: "System roles can only be modified by super admins",
 	ErrInvalidRole:         "Invalid role data",
 	ErrRoleAssignment:      "Failed to assign/remove role",
+
+	ErrCredentialNotFound: "Credential not found",
+	ErrUpdateCredential:   "Failed to update credential",
+	ErrDeleteCredential:   "Failed to delete credential",
+
+	ErrPolicyNotFound: "Access policy not found",
+	ErrDeviceNotFound: "Trusted device not found",
+	ErrSAMLNotFound:   "SAML connection not found",
+
+	ErrSigningKeyNotFound: "Signing key not found",
+	ErrAPIKeyNotFound:     "API key not found",
+	ErrSvcAccountNotFound: "Service account not found",
+	ErrNamespaceNotFound:  "Relation namespace not found",
+	ErrMemberNotFound:     "Project member not found",
+	ErrIdentityNotFound:   "Identity not found",
+	ErrUserDeviceNotFound: "Device not found",
+	ErrTemplateNotFound:   "Notification template not found",
+	ErrPasswordBreached:   "This password appears in a known data breach; choose another one",
+	ErrIPBlockNotFound:    "IP block not found",
+	ErrIPBanNotFound:      "IP ban not found",
 }
 
 // GetErrorMessage returns a user-friendly error message for a given error code.
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// APIKey authenticates a project's machine client. The key is shown once when it is created;
+// only the SHA256 digest of its secret is stored, and requests find it by its public prefix.
+type APIKey struct {
+	BaseModel
+	ProjectID  string         `gorm:"type:varchar(36);not null;index"`
+	Name       string         `gorm:"type:varchar(255);not null"`
+	Prefix     string         `gorm:"type:varchar(32);not null;unique"`
+	SecretHash string         `gorm:"type:varchar(64);not null"`
+	Scopes     datatypes.JSON `gorm:"type:jsonb"` // permission codes the key holds in ProjectID
+	ExpiresAt  *time.Time     `gorm:"type:timestamp"`
+	LastUsedAt *time.Time     `gorm:"type:timestamp"`
+	RevokedAt  *time.Time     `gorm:"type:timestamp"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// ScopeList returns the permission codes the key holds.
+func (k *APIKey) ScopeList() []string {
+	return stringsFromJSON(k.Scopes)
+}
+
+// SetScopes stores the permission codes the key holds.
+func (k *APIKey) SetScopes(scopes []string) {
+	k.Scopes = stringsToJSON(scopes)
+}
+
+// IsUsable reports whether the key is neither revoked nor expired at t.
+func (k *APIKey) IsUsable(t time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || t.Before(*k.ExpiresAt))
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// BreakGlassCredential is a sealed emergency credential. Only a bcrypt hash of the secret is stored;
+// UsedAt is set on the first successful activation and the credential must be re-provisioned afterwards.
+type BreakGlassCredential struct {
+	BaseModel
+	Label      string     `gorm:"type:varchar(100);not null;unique"`
+	SecretHash string     `gorm:"type:varchar(255);not null"`
+	UsedAt     *time.Time `gorm:"type:timestamp"`
+}
+
+func (BreakGlassCredential) TableName() string {
+	return "break_glass_credentials"
+}
+
+// BreakGlassActivation is the audit record of every activation attempt, successful or not.
+type BreakGlassActivation struct {
+	BaseModel
+	CredentialID   string     `gorm:"type:varchar(36)"`
+	Label          string     `gorm:"type:varchar(100);not null"`
+	Operator       string     `gorm:"type:varchar(255);not null"` // OS user and host that ran the CLI
+	Justification  string     `gorm:"type:text;not null"`
+	Outcome        string     `gorm:"type:varchar(20);not null"` // GRANTED or REJECTED
+	Reason         string     `gorm:"type:varchar(255)"`
+	SessionID      string     `gorm:"type:varchar(36)"`
+	ExpiresAt      *time.Time `gorm:"type:timestamp"`
+	AlertDelivered bool       `gorm:"type:boolean;not null"`
+}
+
+func (BreakGlassActivation) TableName() string {
+	return "break_glass_activations"
+}
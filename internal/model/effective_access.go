@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// EffectiveAccess is a precomputed grant, derived from the role assignments or relation tuples it is
+// rebuilt from and never edited on its own. For a permission, Subject is the user ID, Resource the code and
+// ProjectID the assignment's project. For a relation, Subject is namespace:objectID and Resource
+// namespace:objectID#relation.
+type EffectiveAccess struct {
+	BaseModel
+	Kind      string     `gorm:"type:varchar(20);not null"` // permission or relation
+	Subject   string     `gorm:"type:varchar(511);not null"`
+	Resource  string     `gorm:"type:varchar(767);not null"`
+	ProjectID *string    `gorm:"type:varchar(36)"`
+	ExpiresAt *time.Time `gorm:"type:timestamptz"` // the earliest expiry of the records the grant comes from
+}
+
+func (EffectiveAccess) TableName() string {
+	return "effective_access"
+}
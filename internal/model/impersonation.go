@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Impersonation is the audit record of a super admin signing in as a user. Metadata holds the admin's IP
+// and User-Agent.
+type Impersonation struct {
+	BaseModel
+	ImpersonatorID string    `gorm:"type:varchar(36);not null"` // the super admin
+	UserID         string    `gorm:"type:varchar(36);not null;index"`
+	ProjectID      string    `gorm:"type:varchar(36)"`
+	SessionID      string    `gorm:"type:varchar(36);not null"`
+	Reason         string    `gorm:"type:text;not null"`
+	ExpiresAt      time.Time `gorm:"type:timestamp;not null"`
+}
+
+func (Impersonation) TableName() string {
+	return "impersonations"
+}
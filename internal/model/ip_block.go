@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// IPBlock denies a network range access to the whole API, e.g. a range an attack comes from. Reason is
+// a note for other admins.
+type IPBlock struct {
+	BaseModel
+	CIDR      string     `gorm:"column:cidr;type:varchar(43);not null;unique"`
+	Reason    string     `gorm:"type:text"`
+	ExpiresAt *time.Time `gorm:"type:timestamptz"` // nil blocks until the entry is deleted
+}
+
+func (IPBlock) TableName() string {
+	return "ip_blocks"
+}
+
+// Active reports whether the block still applies at now.
+func (b *IPBlock) Active(now time.Time) bool {
+	return b.ExpiresAt == nil || now.Before(*b.ExpiresAt)
+}
@@ -0,0 +1,20 @@
+package model
+
+import "github.com/hiamthach108/dreon-auth/internal/shared/constant"
+
+// NotificationTemplate replaces a built-in email or SMS of a project, optionally for one locale only.
+// Its texts use {{variable}} placeholders; see the notification package.
+type NotificationTemplate struct {
+	BaseModel
+	ProjectID string                       `gorm:"type:varchar(36);not null;uniqueIndex:idx_notification_templates_key"`
+	Channel   constant.NotificationChannel `gorm:"type:varchar(10);not null;uniqueIndex:idx_notification_templates_key"`
+	Name      string                       `gorm:"type:varchar(50);not null;uniqueIndex:idx_notification_templates_key"`
+	Locale    string                       `gorm:"type:varchar(35);not null;default:'';uniqueIndex:idx_notification_templates_key"` // lower-case BCP 47 tag; empty for every locale
+	Subject   string                       `gorm:"type:varchar(255)"`                                                               // email only
+	Text      string                       `gorm:"type:text;not null"`
+	HTML      string                       `gorm:"column:html;type:text"` // email only; without it the email is plain text
+}
+
+func (NotificationTemplate) TableName() string {
+	return "notification_templates"
+}
@@ -0,0 +1,16 @@
+package model
+
+// Permission is an entry of the permission catalog. Role, API key and service account permission codes must
+// name one; the entries of config/permissions.json are seeded at startup. System permissions have no
+// ProjectID; a project may define its own codes, which only take effect within that project.
+type Permission struct {
+	BaseModel
+	ProjectID   *string `gorm:"type:varchar(36);uniqueIndex:idx_permissions_project_code"`
+	Code        string  `gorm:"type:varchar(255);not null;uniqueIndex:idx_permissions_project_code"`
+	Name        string  `gorm:"type:varchar(255);not null"`
+	Description string  `gorm:"type:text"`
+}
+
+func (Permission) TableName() string {
+	return "permissions"
+}
@@ -1,12 +1,95 @@
 package model
 
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"gorm.io/datatypes"
+)
+
 type Project struct {
 	BaseModel
-	Code        string `gorm:"type:varchar(255);not null;unique"`
-	Name        string `gorm:"type:varchar(255);not null"`
-	Description string `gorm:"type:text"`
+	Code         string         `gorm:"type:varchar(255);not null;unique"`
+	Name         string         `gorm:"type:varchar(255);not null"`
+	Description  string         `gorm:"type:text"`
+	RedirectURLs datatypes.JSON `gorm:"column:redirect_urls;type:jsonb"` // where OAuth logins may send users back to
+	ArchivedAt   *time.Time     `gorm:"index"`                           // set while the project is archived; purged after the retention period
+	// AttributeSchema lists the user attributes the project relies on; see UserAttribute.
+	AttributeSchema datatypes.JSON `gorm:"column:attribute_schema;type:jsonb"`
+	// MailFrom is the sender of the email sent for the project, e.g. "Acme <no-reply@acme.com>"; empty for MAIL_FROM.
+	MailFrom string `gorm:"type:varchar(255)"`
+	// BreachedPasswordPolicy overrides PASSWORD_BREACH_POLICY for the project's users; empty inherits it.
+	BreachedPasswordPolicy constant.BreachedPasswordPolicy `gorm:"type:varchar(16)"`
+}
+
+// UserAttribute declares a user attribute and its type. Attributes marked Claim are added to the
+// attrs claim of tokens signed in to the project.
+type UserAttribute struct {
+	Key   string                     `json:"key"`
+	Type  constant.UserAttributeType `json:"type"`
+	Claim bool                       `json:"claim,omitempty"`
 }
 
 func (Project) TableName() string {
 	return "projects"
 }
+
+// IsArchived reports whether the project has been archived.
+func (p *Project) IsArchived() bool {
+	return p.ArchivedAt != nil
+}
+
+// RedirectURLList returns the URLs the project allows logins to redirect to.
+func (p *Project) RedirectURLList() []string {
+	return stringsFromJSON(p.RedirectURLs)
+}
+
+// SetRedirectURLs stores the URLs the project allows logins to redirect to.
+func (p *Project) SetRedirectURLs(urls []string) {
+	p.RedirectURLs = stringsToJSON(urls)
+}
+
+// AttributeSchemaList returns the user attributes the project declares.
+func (p *Project) AttributeSchemaList() []UserAttribute {
+	if len(p.AttributeSchema) == 0 {
+		return nil
+	}
+	var attributes []UserAttribute
+	if err := json.Unmarshal(p.AttributeSchema, &attributes); err != nil {
+		return nil
+	}
+	return attributes
+}
+
+// SetAttributeSchema stores the user attributes the project declares.
+func (p *Project) SetAttributeSchema(attributes []UserAttribute) {
+	data, _ := json.Marshal(attributes)
+	p.AttributeSchema = datatypes.JSON(data)
+}
+
+// AllowsRedirect reports whether raw has the scheme, host and path of one of the project's redirect URLs.
+func (p *Project) AllowsRedirect(raw string) bool {
+	return RedirectAllowed(p.RedirectURLList(), raw)
+}
+
+// RedirectAllowed reports whether raw has the scheme, host and path of one of allowedURLs. Query and fragment
+// are ignored, so clients can carry their own state; the host is compared case-insensitively.
+func RedirectAllowed(allowedURLs []string, raw string) bool {
+	target, err := url.Parse(raw)
+	if err != nil || target.Host == "" {
+		return false
+	}
+	for _, allowed := range allowedURLs {
+		u, err := url.Parse(strings.TrimSpace(allowed))
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(u.Scheme, target.Scheme) && strings.EqualFold(u.Host, target.Host) && u.Path == target.Path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// ProjectMember records that a user belongs to a project. A member starts out invited and becomes
+// active once the user accepts; only active members can be granted project-scoped roles or relations.
+type ProjectMember struct {
+	BaseModel
+	ProjectID  string     `gorm:"type:varchar(36);not null;uniqueIndex:idx_project_members_project_user"`
+	UserID     string     `gorm:"type:varchar(36);not null;uniqueIndex:idx_project_members_project_user;index"`
+	Status     string     `gorm:"type:varchar(20);not null"` // see constant.ProjectMember*
+	InvitedBy  string     `gorm:"type:varchar(36)"`
+	AcceptedAt *time.Time `gorm:"type:timestamp"`
+}
+
+func (ProjectMember) TableName() string {
+	return "project_members"
+}
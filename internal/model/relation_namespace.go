@@ -0,0 +1,38 @@
+package model
+
+import (
+	"encoding/json"
+
+	"github.com/hiamthach108/dreon-auth/internal/shared/relationconfig"
+	"gorm.io/datatypes"
+)
+
+// RelationNamespace holds the userset rewrite rules of one relation namespace (e.g. "document").
+// Namespaces without a row only match relation tuples directly.
+type RelationNamespace struct {
+	BaseModel
+	Name   string         `gorm:"type:varchar(255);not null;unique"`
+	Config datatypes.JSON `gorm:"type:jsonb"`
+}
+
+func (RelationNamespace) TableName() string {
+	return "relation_namespaces"
+}
+
+// Rewrites returns the stored configuration; malformed JSON yields an empty one.
+func (n *RelationNamespace) Rewrites() *relationconfig.Config {
+	var cfg relationconfig.Config
+	if len(n.Config) > 0 {
+		_ = json.Unmarshal(n.Config, &cfg)
+	}
+	return &cfg
+}
+
+// SetRewrites stores cfg on the model.
+func (n *RelationNamespace) SetRewrites(cfg relationconfig.Config) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return
+	}
+	n.Config = datatypes.JSON(b)
+}
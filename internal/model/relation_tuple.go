@@ -25,8 +25,11 @@ type RelationTuple struct {
 	SubjectObjectID  string `gorm:"type:varchar(255);not null;index:idx_subject"`
 	SubjectRelation  string `gorm:"type:varchar(255);index:idx_subject"` // Optional: for usersets
 	
+	// Optional condition (caveat) evaluated against the check's context, e.g. ip_in_range("10.0.0.0/8")
+	Condition string `gorm:"type:text"`
+	
 	// Metadata
-	IsActive  bool       `gorm:"type:boolean;default:true;index"`
+	IsActive  bool       `gorm:"type:boolean;default:true"`
 	ExpiresAt *time.Time `gorm:"index"` // Optional: for temporary permissions
 }
 
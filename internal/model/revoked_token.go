@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// RevokedToken blacklists an access token by its jti until the token would have expired anyway.
+type RevokedToken struct {
+	BaseModel
+	JTI       string    `gorm:"column:jti;type:varchar(64);not null;unique"`
+	UserID    string    `gorm:"type:varchar(36);not null;index"`
+	ExpiresAt time.Time `gorm:"type:timestamp;not null;index"`
+}
+
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
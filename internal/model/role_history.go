@@ -0,0 +1,20 @@
+package model
+
+import "gorm.io/datatypes"
+
+// RoleHistory is an audit record of a change to a role or to who holds it, with the state before and after.
+type RoleHistory struct {
+	BaseModel
+	RoleID    string         `gorm:"type:varchar(36);not null;index"`
+	ProjectID *string        `gorm:"type:varchar(36)"`
+	Action    string         `gorm:"type:varchar(20);not null"` // create, update, delete, assign or remove
+	ActorID   string         `gorm:"type:varchar(36)"`          // empty when no authenticated caller made the change
+	ActorType string         `gorm:"type:varchar(20)"`          // user, api_key or service_account
+	UserID    string         `gorm:"type:varchar(36);index"`    // the user an assignment was made to or removed from
+	Before    datatypes.JSON `gorm:"type:jsonb"`                // null on create and on a new assignment
+	After     datatypes.JSON `gorm:"type:jsonb"`                // null on delete and remove
+}
+
+func (RoleHistory) TableName() string {
+	return "role_history"
+}
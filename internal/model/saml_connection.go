@@ -0,0 +1,31 @@
+package model
+
+import "gorm.io/datatypes"
+
+// SAMLConnection is a project's SAML 2.0 identity provider. Users asserted by the IdP sign in
+// through the project's ACS endpoint, limited to the connection's email domains.
+type SAMLConnection struct {
+	BaseModel
+	ProjectID       string         `gorm:"type:varchar(36);not null;unique"`
+	IdPMetadataXML  string         `gorm:"column:idp_metadata_xml;type:text;not null"`
+	IdPEntityID     string         `gorm:"column:idp_entity_id;type:varchar(512);not null"`
+	EmailAttribute  string         `gorm:"type:varchar(255)"` // empty means the NameID is the email
+	NameAttribute   string         `gorm:"type:varchar(255)"`
+	GroupsAttribute string         `gorm:"type:varchar(255)"`
+	AllowedDomains  datatypes.JSON `gorm:"type:jsonb"`
+	IsActive        bool           `gorm:"type:boolean;not null"`
+}
+
+func (SAMLConnection) TableName() string {
+	return "saml_connections"
+}
+
+// Domains returns the email domains the IdP may assert.
+func (c *SAMLConnection) Domains() []string {
+	return stringsFromJSON(c.AllowedDomains)
+}
+
+// SetDomains stores the email domains the IdP may assert.
+func (c *SAMLConnection) SetDomains(domains []string) {
+	c.AllowedDomains = stringsToJSON(domains)
+}
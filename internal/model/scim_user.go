@@ -0,0 +1,16 @@
+package model
+
+// SCIMUser links a user to the project whose identity provider provisioned it through SCIM.
+// The project owns the account: only its SCIM client can update, deactivate or delete it.
+type SCIMUser struct {
+	BaseModel
+	ProjectID  string `gorm:"type:varchar(36);not null;index"`
+	UserID     string `gorm:"type:varchar(36);not null;unique"`
+	ExternalID string `gorm:"type:varchar(255);index"` // the identity provider's ID for the user
+
+	User User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (SCIMUser) TableName() string {
+	return "scim_users"
+}
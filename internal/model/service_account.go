@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// ServiceAccount is a project's server-to-server client for the client_credentials grant.
+// The secret is shown once when the account is created; only its SHA256 digest is stored.
+type ServiceAccount struct {
+	BaseModel
+	ProjectID        string         `gorm:"type:varchar(36);not null;index"`
+	Name             string         `gorm:"type:varchar(255);not null"`
+	ClientID         string         `gorm:"type:varchar(64);not null;unique"`
+	ClientSecretHash string         `gorm:"type:varchar(64);not null"`
+	Permissions      datatypes.JSON `gorm:"type:jsonb"` // permission codes the account may request in ProjectID
+	LastTokenAt      *time.Time     `gorm:"type:timestamp"`
+}
+
+func (ServiceAccount) TableName() string {
+	return "service_accounts"
+}
+
+// PermissionList returns the permission codes the account may request.
+func (a *ServiceAccount) PermissionList() []string {
+	return stringsFromJSON(a.Permissions)
+}
+
+// SetPermissions stores the permission codes the account may request.
+func (a *ServiceAccount) SetPermissions(codes []string) {
+	a.Permissions = stringsToJSON(codes)
+}
@@ -4,12 +4,15 @@ import "time"
 
 type Session struct {
 	BaseModel
-	UserID       string    `gorm:"type:varchar(36);not null"`
-	Email        string    `gorm:"type:varchar(255);default:null"`
-	RefreshToken string    `gorm:"type:varchar(255);not null"`
-	ExpiresAt    time.Time `gorm:"type:timestamp;not null"`
-	IsActive     bool      `gorm:"type:boolean;default:true"`
-	IsSuperAdmin bool      `gorm:"type:boolean;default:false"`
+	UserID           string    `gorm:"type:varchar(36);not null"`
+	Email            string    `gorm:"type:varchar(255);default:null"`
+	RefreshTokenHash string    `gorm:"column:refresh_token;type:varchar(255);not null;index"` // SHA256 hex digest; the raw token is never stored
+	ExpiresAt        time.Time `gorm:"type:timestamp;not null"`
+	IsActive         bool      `gorm:"type:boolean;default:true"`
+	IsSuperAdmin     bool      `gorm:"type:boolean;default:false"`
+	ProjectID        string    `gorm:"type:varchar(36);default:null"`
+	MFAVerified      bool      `gorm:"column:mfa_verified;type:boolean;default:false"`
+	DeviceID         string    `gorm:"type:varchar(36);default:null;index"` // the UserDevice the session was issued to
 }
 
 func (Session) TableName() string {
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// SigningKey is a JWT signing key created through the admin API. Keys from config are never stored.
+// The private key is kept sealed with the state secret.
+type SigningKey struct {
+	BaseModel
+	KID         string     `gorm:"column:kid;type:varchar(64);not null;unique"`
+	PublicKey   string     `gorm:"type:text;not null"`      // PEM
+	PrivateKey  string     `gorm:"type:text;not null"`      // sealed base64 PKCS#8 DER
+	ActivatesAt time.Time  `gorm:"type:timestamp;not null"` // signs new tokens from this time; verifies from creation
+	RetiredAt   *time.Time `gorm:"type:timestamp"`          // no longer accepted once set
+}
+
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
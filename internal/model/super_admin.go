@@ -6,6 +6,8 @@ type SuperAdmin struct {
 	Email    string `gorm:"type:varchar(255);not null;unique"`
 	Password string `gorm:"type:varchar(255);not null"`
 	IsActive bool   `gorm:"type:boolean;default:false"`
+	// PasswordPepperVersion is the version of PASSWORD_PEPPERS the password was hashed with, 0 for none.
+	PasswordPepperVersion int `gorm:"not null;default:0"`
 }
 
 func (SuperAdmin) TableName() string {
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// TrustedDevice lets a user skip MFA from a device that already completed it.
+// Only a SHA-256 hash of the device token is stored.
+type TrustedDevice struct {
+	BaseModel
+	UserID     string     `gorm:"type:varchar(36);not null;index"`
+	TokenHash  string     `gorm:"type:varchar(64);not null;unique"`
+	Name       string     `gorm:"type:varchar(255)"` // user agent at the time it was trusted
+	IP         string     `gorm:"type:varchar(45)"`
+	ExpiresAt  time.Time  `gorm:"type:timestamp;not null"`
+	LastUsedAt *time.Time `gorm:"type:timestamp"`
+}
+
+func (TrustedDevice) TableName() string {
+	return "trusted_devices"
+}
@@ -1,22 +1,65 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"gorm.io/datatypes"
 )
 
 type User struct {
 	BaseModel
-	Username    string                `gorm:"type:varchar(255);not null;unique"`
-	Email       string                `gorm:"type:varchar(255);not null;unique"`
-	Password    string                `gorm:"type:varchar(255);not null"`
-	Status      constant.UserStatus   `gorm:"type:varchar(50);default:active"`
-	AuthType    constant.UserAuthType `gorm:"type:varchar(50);default:email"`
-	AuthTypeID  string                `gorm:"type:varchar(100);"`
-	LastLoginAt time.Time             `gorm:"type:timestamp;default:null"`
+	Username    string              `gorm:"type:varchar(255);not null;unique"`
+	Email       string              `gorm:"type:varchar(255);not null;unique"`
+	Phone       string              `gorm:"type:varchar(20);not null;default:'';uniqueIndex:idx_users_phone,where:phone <> ''"` // E.164, empty when unset
+	Password    string              `gorm:"type:varchar(255);not null"`
+	Status      constant.UserStatus `gorm:"type:varchar(50);default:ACTIVE"`
+	LastLoginAt time.Time           `gorm:"type:timestamp;default:null"`
+	Attributes  datatypes.JSON      `gorm:"type:jsonb"` // custom key/value data, typed by the schemas of the user's projects
+	// PasswordResetRequired blocks password sign-in until the user sets a new password, e.g. after an
+	// import without password hashes.
+	PasswordResetRequired bool `gorm:"not null;default:false"`
+	// PasswordPepperVersion is the version of PASSWORD_PEPPERS the password was hashed with, 0 for none.
+	PasswordPepperVersion int `gorm:"not null;default:0"`
+	// ErasedAt is set when the user erased their account; the row is kept anonymized and soft-deleted
+	// until their audit records are purged.
+	ErasedAt *time.Time `gorm:"index"`
 }
 
 func (User) TableName() string {
 	return "users"
 }
+
+// IsDisabled reports whether the account was deactivated or blocked and must not sign in.
+// Accounts created without an explicit status keep the column default and are not disabled.
+func (u *User) IsDisabled() bool {
+	status := u.Status.Normalize()
+	return status == constant.UserStatusInactive || status == constant.UserStatusBlocked
+}
+
+// IsActive reports whether the account is ACTIVE, whatever the case of the stored status.
+func (u *User) IsActive() bool {
+	return u.Status.Normalize() == constant.UserStatusActive
+}
+
+// AttributeMap returns the user's custom attributes.
+func (u *User) AttributeMap() map[string]any {
+	attributes := map[string]any{}
+	if len(u.Attributes) > 0 {
+		_ = json.Unmarshal(u.Attributes, &attributes)
+	}
+	return attributes
+}
+
+// SetAttributes stores the user's custom attributes.
+func (u *User) SetAttributes(attributes map[string]any) {
+	data, _ := json.Marshal(attributes)
+	u.Attributes = datatypes.JSON(data)
+}
+
+// Locale returns the user's preferred language from their "locale" attribute, e.g. "vi" or "pt-BR", or "".
+func (u *User) Locale() string {
+	locale, _ := u.AttributeMap()["locale"].(string)
+	return locale
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"gorm.io/datatypes"
+)
+
+// UserCredential is an authenticator bound to a user: a WebAuthn passkey or an MFA device.
+type UserCredential struct {
+	BaseModel
+	UserID     string                  `gorm:"type:varchar(36);not null;index"`
+	Type       constant.CredentialType `gorm:"type:varchar(20);not null"`
+	Name       string                  `gorm:"type:varchar(255);not null"`
+	ExternalID string                  `gorm:"type:varchar(255);default:null"` // e.g. WebAuthn credential ID
+	Data       datatypes.JSON          `gorm:"type:jsonb"`                     // type-specific material (public key, secret, ...)
+	LastUsedAt *time.Time              `gorm:"type:timestamp"`
+}
+
+func (UserCredential) TableName() string {
+	return "user_credentials"
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// UserDevice is a browser or app a user signed in from, recognised by a fingerprint of its client hints
+// or User-Agent. Its sessions point to it through Session.DeviceID.
+type UserDevice struct {
+	BaseModel
+	UserID      string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_user_devices_user_fingerprint"`
+	Fingerprint string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_user_devices_user_fingerprint"` // SHA-256 hex
+	Name        string    `gorm:"type:varchar(100)"`                                                       // chosen by the user
+	Platform    string    `gorm:"type:varchar(64)"`                                                        // from Sec-CH-UA-Platform
+	UserAgent   string    `gorm:"type:text"`                                                               // as last seen
+	LastIP      string    `gorm:"type:varchar(45)"`
+	LastCountry string    `gorm:"type:varchar(8)"`
+	LastSeenAt  time.Time `gorm:"type:timestamp;not null"`
+}
+
+func (UserDevice) TableName() string {
+	return "user_devices"
+}
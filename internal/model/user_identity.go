@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+)
+
+// UserIdentity links a user to an account at an external identity provider, so the user can sign in
+// through it. The subject is the provider's own user ID; Provider tells OIDC issuers and SAML
+// connections apart, since they share one AuthType each.
+type UserIdentity struct {
+	BaseModel
+	UserID         string                `gorm:"type:varchar(36);not null;index"`
+	AuthType       constant.UserAuthType `gorm:"type:varchar(50);not null;uniqueIndex:idx_user_identities_subject"`
+	Provider       string                `gorm:"type:varchar(255);not null;default:'';uniqueIndex:idx_user_identities_subject"`
+	ProviderUserID string                `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identities_subject"`
+	Email          string                `gorm:"type:varchar(255)"` // as reported by the provider when linked
+	LastUsedAt     *time.Time            `gorm:"type:timestamp"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
@@ -1,10 +1,13 @@
 package model
 
+import "time"
+
 type UserRole struct {
 	BaseModel
-	UserID    string  `gorm:"type:varchar(36);not null"`
-	RoleID    string  `gorm:"type:varchar(36);not null"`
-	ProjectID *string `gorm:"type:varchar(36)"` // may be null for system user roles
+	UserID    string     `gorm:"type:varchar(36);not null"`
+	RoleID    string     `gorm:"type:varchar(36);not null"`
+	ProjectID *string    `gorm:"type:varchar(36)"` // may be null for system user roles
+	ExpiresAt *time.Time `gorm:"index"`            // null for permanent assignments
 
 	User User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 	Role Role `gorm:"foreignKey:RoleID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
@@ -13,3 +16,11 @@ type UserRole struct {
 func (UserRole) TableName() string {
 	return "user_roles"
 }
+
+// IsExpired checks if the assignment has expired
+func (ur *UserRole) IsExpired() bool {
+	if ur.ExpiresAt == nil {
+		return false
+	}
+	return time.Now().After(*ur.ExpiresAt)
+}
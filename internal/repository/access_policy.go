@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IAccessPolicyRepository interface {
+	IRepository[model.AccessPolicy]
+	// FindByProjectID returns the project's policy, or nil if it has none.
+	FindByProjectID(ctx context.Context, projectID string) *model.AccessPolicy
+	// HasActive reports whether any project has an active policy.
+	HasActive(ctx context.Context) (bool, error)
+}
+
+type accessPolicyRepository struct {
+	Repository[model.AccessPolicy]
+}
+
+func NewAccessPolicyRepository(dbClient *gorm.DB) IAccessPolicyRepository {
+	return &accessPolicyRepository{Repository: Repository[model.AccessPolicy]{dbClient: dbClient}}
+}
+
+func (r *accessPolicyRepository) FindByProjectID(ctx context.Context, projectID string) *model.AccessPolicy {
+	var result model.AccessPolicy
+	if err := r.conn(ctx).Where("project_id = ?", projectID).First(&result).Error; err != nil {
+		return nil
+	}
+	return &result
+}
+
+func (r *accessPolicyRepository) HasActive(ctx context.Context) (bool, error) {
+	var count int64
+	if err := r.conn(ctx).Model(&model.AccessPolicy{}).Where("is_active = ?", true).Limit(1).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+type IAccessDenialRepository interface {
+	IRepository[model.AccessDenial]
+	// ListByProjectID returns the project's denials, newest first. total is the count before pagination.
+	ListByProjectID(ctx context.Context, projectID string, offset, limit int) ([]model.AccessDenial, int64, error)
+	// FindByUserID returns the denials recorded for a user, newest first.
+	FindByUserID(ctx context.Context, userID string) ([]model.AccessDenial, error)
+	// CountByStage counts the denials at stage recorded in [from, to).
+	CountByStage(ctx context.Context, stage string, from, to time.Time) (int64, error)
+	// AnonymizeByUserID clears the email, IP and country of a user's denials, keeping the rest as an audit trail.
+	AnonymizeByUserID(ctx context.Context, userID string) error
+	// DeleteByUserIDs permanently removes the denials of the given users.
+	DeleteByUserIDs(ctx context.Context, userIDs []string) (int64, error)
+}
+
+type accessDenialRepository struct {
+	Repository[model.AccessDenial]
+}
+
+func NewAccessDenialRepository(dbClient *gorm.DB) IAccessDenialRepository {
+	return &accessDenialRepository{Repository: Repository[model.AccessDenial]{dbClient: dbClient}}
+}
+
+func (r *accessDenialRepository) ListByProjectID(ctx context.Context, projectID string, offset, limit int) ([]model.AccessDenial, int64, error) {
+	query := r.conn(ctx).Model(new(model.AccessDenial)).Where("project_id = ?", projectID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var results []model.AccessDenial
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&results).Error; err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+func (r *accessDenialRepository) FindByUserID(ctx context.Context, userID string) ([]model.AccessDenial, error) {
+	var results []model.AccessDenial
+	if err := r.conn(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *accessDenialRepository) CountByStage(ctx context.Context, stage string, from, to time.Time) (int64, error) {
+	var count int64
+	err := r.conn(ctx).Model(new(model.AccessDenial)).
+		Where("stage = ? AND created_at >= ? AND created_at < ?", stage, from, to).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *accessDenialRepository) AnonymizeByUserID(ctx context.Context, userID string) error {
+	return r.conn(ctx).Model(new(model.AccessDenial)).
+		Where("user_id = ?", userID).
+		Updates(map[string]any{"email": "", "ip": "", "country": ""}).Error
+}
+
+func (r *accessDenialRepository) DeleteByUserIDs(ctx context.Context, userIDs []string) (int64, error) {
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+	result := r.conn(ctx).Unscoped().Where("user_id IN ?", userIDs).Delete(&model.AccessDenial{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IAPIKeyRepository interface {
+	IRepository[model.APIKey]
+	// FindByPrefix returns the key with the given public prefix, or nil.
+	FindByPrefix(ctx context.Context, prefix string) *model.APIKey
+	FindByProjectID(ctx context.Context, projectID string) ([]model.APIKey, error)
+}
+
+type apiKeyRepository struct {
+	Repository[model.APIKey]
+}
+
+func NewAPIKeyRepository(dbClient *gorm.DB) IAPIKeyRepository {
+	return &apiKeyRepository{Repository: Repository[model.APIKey]{dbClient: dbClient}}
+}
+
+func (r *apiKeyRepository) FindByPrefix(ctx context.Context, prefix string) *model.APIKey {
+	var key model.APIKey
+	if err := r.conn(ctx).Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		return nil
+	}
+	return &key
+}
+
+func (r *apiKeyRepository) FindByProjectID(ctx context.Context, projectID string) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	if err := r.conn(ctx).Where("project_id = ?", projectID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
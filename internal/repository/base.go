@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type IRepository[T any] interface {
@@ -13,7 +15,15 @@ type IRepository[T any] interface {
 	Create(ctx context.Context, model *T) (*T, error)
 	BulkCreate(ctx context.Context, inputs []T) error
 	Update(ctx context.Context, id string, value T, field ...string) error
+	// DeleteById soft-deletes a record: it is hidden from queries but kept until HardDeleteById.
 	DeleteById(ctx context.Context, id string) error
+	// FindDeletedById returns a soft-deleted record, or nil if there is none with that id.
+	FindDeletedById(ctx context.Context, id string) *T
+	RestoreById(ctx context.Context, id string) error
+	HardDeleteById(ctx context.Context, id string) error
+	// LockById locks the record (SELECT ... FOR UPDATE) until the caller's transaction ends, so concurrent
+	// transactions doing the same wait. It does nothing outside WithTx or when there is no such record.
+	LockById(ctx context.Context, id string) error
 }
 
 type Repository[T any] struct {
@@ -22,9 +32,14 @@ type Repository[T any] struct {
 
 var _ IRepository[any] = &Repository[any]{}
 
+// conn returns the connection to query with: the caller's transaction, if any, or the pool.
+func (r *Repository[T]) conn(ctx context.Context) *gorm.DB {
+	return conn(ctx, r.dbClient)
+}
+
 func (r *Repository[T]) FindAll(ctx context.Context) ([]T, error) {
 	var results []T
-	if err := r.dbClient.WithContext(ctx).Find(&results).Error; err != nil {
+	if err := r.conn(ctx).Find(&results).Error; err != nil {
 		return nil, err
 	}
 	return results, nil
@@ -32,7 +47,7 @@ func (r *Repository[T]) FindAll(ctx context.Context) ([]T, error) {
 
 func (r *Repository[T]) FindOneById(ctx context.Context, id string) *T {
 	var result T
-	if err := r.dbClient.WithContext(ctx).First(&result, "id = ?", id).Error; err != nil {
+	if err := r.conn(ctx).First(&result, "id = ?", id).Error; err != nil {
 		return nil
 	}
 	return &result
@@ -40,35 +55,65 @@ func (r *Repository[T]) FindOneById(ctx context.Context, id string) *T {
 
 func (r *Repository[T]) FindByIds(ctx context.Context, ids []string) ([]T, error) {
 	var results []T
-	if err := r.dbClient.WithContext(ctx).Find(&results, "id IN (?)", ids).Error; err != nil {
+	if err := r.conn(ctx).Find(&results, "id IN (?)", ids).Error; err != nil {
 		return nil, err
 	}
 	return results, nil
 }
 
 func (r *Repository[T]) Create(ctx context.Context, model *T) (*T, error) {
-	if err := r.dbClient.WithContext(ctx).Create(model).Error; err != nil {
+	if err := r.conn(ctx).Create(model).Error; err != nil {
 		return nil, err
 	}
 	return model, nil
 }
 
 func (r *Repository[T]) BulkCreate(ctx context.Context, inputs []T) error {
-	if err := r.dbClient.WithContext(ctx).Create(&inputs).Error; err != nil {
+	if err := r.conn(ctx).Create(&inputs).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
 func (r *Repository[T]) Update(ctx context.Context, id string, value T, field ...string) error {
-	if err := r.dbClient.WithContext(ctx).Model(&value).Where("id = ?", id).Select(field).Updates(value).Error; err != nil {
+	if err := r.conn(ctx).Model(&value).Where("id = ?", id).Select(field).Updates(value).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
 func (r *Repository[T]) DeleteById(ctx context.Context, id string) error {
-	if err := r.dbClient.WithContext(ctx).Delete(new(T), "id = ?", id).Error; err != nil {
+	if err := r.conn(ctx).Delete(new(T), "id = ?", id).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *Repository[T]) FindDeletedById(ctx context.Context, id string) *T {
+	var result T
+	if err := r.conn(ctx).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&result).Error; err != nil {
+		return nil
+	}
+	return &result
+}
+
+func (r *Repository[T]) RestoreById(ctx context.Context, id string) error {
+	if err := r.conn(ctx).Unscoped().Model(new(T)).Where("id = ?", id).Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *Repository[T]) HardDeleteById(ctx context.Context, id string) error {
+	if err := r.conn(ctx).Unscoped().Delete(new(T), "id = ?", id).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *Repository[T]) LockById(ctx context.Context, id string) error {
+	err := r.conn(ctx).Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Select("id").First(new(T), "id = ?", id).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return err
 	}
 	return nil
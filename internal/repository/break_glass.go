@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IBreakGlassCredentialRepository interface {
+	IRepository[model.BreakGlassCredential]
+	// FindByLabel returns the credential with the given label, or nil.
+	FindByLabel(ctx context.Context, label string) *model.BreakGlassCredential
+	// MarkUsed sets used_at on the credential unless it is already set, and reports whether it did. Of
+	// several callers racing for the same credential exactly one gets true.
+	MarkUsed(ctx context.Context, id string, usedAt time.Time) (bool, error)
+}
+
+type breakGlassCredentialRepository struct {
+	Repository[model.BreakGlassCredential]
+}
+
+func NewBreakGlassCredentialRepository(dbClient *gorm.DB) IBreakGlassCredentialRepository {
+	return &breakGlassCredentialRepository{Repository: Repository[model.BreakGlassCredential]{dbClient: dbClient}}
+}
+
+func (r *breakGlassCredentialRepository) FindByLabel(ctx context.Context, label string) *model.BreakGlassCredential {
+	var result model.BreakGlassCredential
+	if err := r.conn(ctx).Where("label = ?", label).First(&result).Error; err != nil {
+		return nil
+	}
+	return &result
+}
+
+func (r *breakGlassCredentialRepository) MarkUsed(ctx context.Context, id string, usedAt time.Time) (bool, error) {
+	result := r.conn(ctx).Model(&model.BreakGlassCredential{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", usedAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+type IBreakGlassActivationRepository interface {
+	IRepository[model.BreakGlassActivation]
+}
+
+type breakGlassActivationRepository struct {
+	Repository[model.BreakGlassActivation]
+}
+
+func NewBreakGlassActivationRepository(dbClient *gorm.DB) IBreakGlassActivationRepository {
+	return &breakGlassActivationRepository{Repository: Repository[model.BreakGlassActivation]{dbClient: dbClient}}
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IEffectiveAccessRepository interface {
+	IRepository[model.EffectiveAccess]
+	// FindBySubject returns the unexpired rows of kind held by subject.
+	FindBySubject(ctx context.Context, kind, subject string) ([]model.EffectiveAccess, error)
+	// FindBySubjects is FindBySubject for several subjects in one query.
+	FindBySubjects(ctx context.Context, kind string, subjects []string) ([]model.EffectiveAccess, error)
+	// Holds reports whether subject holds an unexpired row of kind on resource.
+	Holds(ctx context.Context, kind, resource, subject string) (bool, error)
+	// ReplaceSubject swaps the rows of kind held by subject for rows.
+	ReplaceSubject(ctx context.Context, kind, subject string, rows []model.EffectiveAccess) error
+	// ReplaceResource swaps the rows of kind on resource for rows.
+	ReplaceResource(ctx context.Context, kind, resource string, rows []model.EffectiveAccess) error
+	// DeleteCreatedBefore removes the rows of kind written before t.
+	DeleteCreatedBefore(ctx context.Context, kind string, t time.Time) (int64, error)
+	// Lock holds a lock on key until the caller's transaction ends, so concurrent recomputations of the same
+	// rows run one after the other. It does nothing outside WithTx.
+	Lock(ctx context.Context, key string) error
+}
+
+type effectiveAccessRepository struct {
+	Repository[model.EffectiveAccess]
+}
+
+func NewEffectiveAccessRepository(dbClient *gorm.DB) IEffectiveAccessRepository {
+	return &effectiveAccessRepository{Repository: Repository[model.EffectiveAccess]{dbClient: dbClient}}
+}
+
+func (r *effectiveAccessRepository) FindBySubject(ctx context.Context, kind, subject string) ([]model.EffectiveAccess, error) {
+	var rows []model.EffectiveAccess
+	if err := r.conn(ctx).
+		Where("kind = ? AND subject = ?", kind, subject).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *effectiveAccessRepository) FindBySubjects(ctx context.Context, kind string, subjects []string) ([]model.EffectiveAccess, error) {
+	var rows []model.EffectiveAccess
+	if len(subjects) == 0 {
+		return rows, nil
+	}
+	if err := r.conn(ctx).
+		Where("kind = ? AND subject IN ?", kind, subjects).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *effectiveAccessRepository) Holds(ctx context.Context, kind, resource, subject string) (bool, error) {
+	var count int64
+	err := r.conn(ctx).Model(&model.EffectiveAccess{}).
+		Where("kind = ? AND resource = ? AND subject = ?", kind, resource, subject).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *effectiveAccessRepository) ReplaceSubject(ctx context.Context, kind, subject string, rows []model.EffectiveAccess) error {
+	return r.replace(ctx, "kind = ? AND subject = ?", kind, subject, rows)
+}
+
+func (r *effectiveAccessRepository) ReplaceResource(ctx context.Context, kind, resource string, rows []model.EffectiveAccess) error {
+	return r.replace(ctx, "kind = ? AND resource = ?", kind, resource, rows)
+}
+
+// replace hard-deletes the rows matching query and inserts rows, in one transaction.
+func (r *effectiveAccessRepository) replace(ctx context.Context, query string, kind, key string, rows []model.EffectiveAccess) error {
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where(query, kind, key).Delete(&model.EffectiveAccess{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&rows, 500).Error
+	})
+}
+
+func (r *effectiveAccessRepository) DeleteCreatedBefore(ctx context.Context, kind string, t time.Time) (int64, error) {
+	result := r.conn(ctx).Unscoped().Where("kind = ? AND created_at < ?", kind, t).Delete(&model.EffectiveAccess{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *effectiveAccessRepository) Lock(ctx context.Context, key string) error {
+	return r.conn(ctx).Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "effective_access:"+key).Error
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// IHealthRepository checks the database for the readiness probe.
+type IHealthRepository interface {
+	// Ping runs a trivial query on the primary.
+	Ping(ctx context.Context) error
+}
+
+type healthRepository struct {
+	dbClient *gorm.DB
+}
+
+func NewHealthRepository(dbClient *gorm.DB) IHealthRepository {
+	return &healthRepository{dbClient: dbClient}
+}
+
+func (r *healthRepository) Ping(ctx context.Context) error {
+	return conn(ctx, r.dbClient).Exec("SELECT 1").Error
+}
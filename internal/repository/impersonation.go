@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IImpersonationRepository interface {
+	IRepository[model.Impersonation]
+}
+
+type impersonationRepository struct {
+	Repository[model.Impersonation]
+}
+
+func NewImpersonationRepository(dbClient *gorm.DB) IImpersonationRepository {
+	return &impersonationRepository{Repository: Repository[model.Impersonation]{dbClient: dbClient}}
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IIPBlockRepository interface {
+	IRepository[model.IPBlock]
+	// List returns every block, expired ones included, newest first.
+	List(ctx context.Context) ([]model.IPBlock, error)
+	// FindByCIDR returns the block of exactly cidr, or nil.
+	FindByCIDR(ctx context.Context, cidr string) *model.IPBlock
+}
+
+type ipBlockRepository struct {
+	Repository[model.IPBlock]
+}
+
+func NewIPBlockRepository(dbClient *gorm.DB) IIPBlockRepository {
+	return &ipBlockRepository{Repository: Repository[model.IPBlock]{dbClient: dbClient}}
+}
+
+func (r *ipBlockRepository) List(ctx context.Context) ([]model.IPBlock, error) {
+	var results []model.IPBlock
+	if err := r.conn(ctx).Order("created_at DESC").Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *ipBlockRepository) FindByCIDR(ctx context.Context, cidr string) *model.IPBlock {
+	var result model.IPBlock
+	if err := r.conn(ctx).Where("cidr = ?", cidr).First(&result).Error; err != nil {
+		return nil
+	}
+	return &result
+}
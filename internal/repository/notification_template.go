@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"gorm.io/gorm"
+)
+
+type INotificationTemplateRepository interface {
+	IRepository[model.NotificationTemplate]
+	// FindByProjectID returns the project's templates ordered by channel, name and locale.
+	FindByProjectID(ctx context.Context, projectID string) ([]model.NotificationTemplate, error)
+	// FindOne returns the project's template for the notification in exactly the given locale, or nil.
+	FindOne(ctx context.Context, projectID string, channel constant.NotificationChannel, name, locale string) *model.NotificationTemplate
+	// DeleteByID permanently removes a template, so its locale can be added again.
+	DeleteByID(ctx context.Context, id string) error
+}
+
+type notificationTemplateRepository struct {
+	Repository[model.NotificationTemplate]
+}
+
+func NewNotificationTemplateRepository(dbClient *gorm.DB) INotificationTemplateRepository {
+	return &notificationTemplateRepository{Repository: Repository[model.NotificationTemplate]{dbClient: dbClient}}
+}
+
+func (r *notificationTemplateRepository) FindByProjectID(ctx context.Context, projectID string) ([]model.NotificationTemplate, error) {
+	var results []model.NotificationTemplate
+	if err := r.conn(ctx).
+		Where("project_id = ?", projectID).
+		Order("channel, name, locale").
+		Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *notificationTemplateRepository) FindOne(ctx context.Context, projectID string, channel constant.NotificationChannel, name, locale string) *model.NotificationTemplate {
+	var result model.NotificationTemplate
+	if err := r.conn(ctx).
+		Where("project_id = ? AND channel = ? AND name = ? AND locale = ?", projectID, channel, name, locale).
+		First(&result).Error; err != nil {
+		return nil
+	}
+	return &result
+}
+
+func (r *notificationTemplateRepository) DeleteByID(ctx context.Context, id string) error {
+	return r.conn(ctx).Unscoped().Delete(new(model.NotificationTemplate), "id = ?", id).Error
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned by DecodeCursor for tokens it did not make.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page selects one page of a list ordered newest first (created_at, then id, descending): the rows after
+// Cursor when it is set, otherwise the rows from Offset. Limit caps the rows returned; -1 returns them all.
+type Page struct {
+	Offset int
+	Limit  int
+	Cursor *Cursor
+}
+
+// Cursor is the sort key of the last row of a page, where the next page starts. Unlike an offset it stays
+// cheap on large tables and does not skip or repeat rows when rows are added between pages.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// CursorAfter returns the cursor of the page that follows the row base belongs to.
+func CursorAfter(base *model.BaseModel) Cursor {
+	return Cursor{CreatedAt: base.CreatedAt, ID: base.ID}
+}
+
+// EncodeCursor returns the opaque token handed to clients as nextCursor.
+func EncodeCursor(c Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a token made by EncodeCursor.
+func DecodeCursor(token string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// paged applies page to query: the newest-first order, then either the cursor or the offset, then the limit.
+func paged(query *gorm.DB, page Page) *gorm.DB {
+	query = query.Order("created_at DESC, id DESC")
+	if page.Cursor != nil {
+		query = query.Where("(created_at, id) < (?, ?)", page.Cursor.CreatedAt, page.Cursor.ID)
+	} else if page.Offset > 0 {
+		query = query.Offset(page.Offset)
+	}
+	return query.Limit(page.Limit)
+}
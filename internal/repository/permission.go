@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IPermissionRepository interface {
+	IRepository[model.Permission]
+	// FindByCode returns the entry for code in projectID's catalog (nil for system permissions), or nil if
+	// there is none.
+	FindByCode(ctx context.Context, projectID *string, code string) *model.Permission
+	// FindByProjectID returns the permissions a project defines, or the system permissions when projectID is nil.
+	FindByProjectID(ctx context.Context, projectID *string) ([]model.Permission, error)
+}
+
+type permissionRepository struct {
+	Repository[model.Permission]
+}
+
+func NewPermissionRepository(dbClient *gorm.DB) IPermissionRepository {
+	return &permissionRepository{Repository: Repository[model.Permission]{dbClient: dbClient}}
+}
+
+func (r *permissionRepository) FindByCode(ctx context.Context, projectID *string, code string) *model.Permission {
+	var result model.Permission
+	if err := r.scoped(ctx, projectID).Where("code = ?", code).First(&result).Error; err != nil {
+		return nil
+	}
+	return &result
+}
+
+func (r *permissionRepository) FindByProjectID(ctx context.Context, projectID *string) ([]model.Permission, error) {
+	var permissions []model.Permission
+	if err := r.scoped(ctx, projectID).Order("code").Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+func (r *permissionRepository) scoped(ctx context.Context, projectID *string) *gorm.DB {
+	query := r.conn(ctx)
+	if projectID == nil {
+		return query.Where("project_id IS NULL")
+	}
+	return query.Where("project_id = ?", *projectID)
+}
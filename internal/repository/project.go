@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/hiamthach108/dreon-auth/internal/model"
 	"gorm.io/gorm"
@@ -13,6 +14,8 @@ type IProjectRepository interface {
 	List(ctx context.Context, offset, limit int) ([]model.Project, int64, error)
 	// FindByCode returns a project by code, or nil if not found.
 	FindByCode(ctx context.Context, code string) (*model.Project, error)
+	// FindArchivedBefore returns projects archived before the given time.
+	FindArchivedBefore(ctx context.Context, before time.Time) ([]model.Project, error)
 }
 
 type projectRepository struct {
@@ -26,11 +29,11 @@ func NewProjectRepository(dbClient *gorm.DB) IProjectRepository {
 // List returns a paginated list of projects and total count.
 func (r *projectRepository) List(ctx context.Context, offset, limit int) ([]model.Project, int64, error) {
 	var total int64
-	if err := r.dbClient.WithContext(ctx).Model(new(model.Project)).Count(&total).Error; err != nil {
+	if err := r.conn(ctx).Model(new(model.Project)).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 	var results []model.Project
-	q := r.dbClient.WithContext(ctx).Offset(offset).Limit(limit)
+	q := r.conn(ctx).Offset(offset).Limit(limit)
 	if err := q.Find(&results).Error; err != nil {
 		return nil, 0, err
 	}
@@ -40,7 +43,7 @@ func (r *projectRepository) List(ctx context.Context, offset, limit int) ([]mode
 // FindByCode returns one project by code.
 func (r *projectRepository) FindByCode(ctx context.Context, code string) (*model.Project, error) {
 	var result model.Project
-	if err := r.dbClient.WithContext(ctx).Where("code = ?", code).First(&result).Error; err != nil {
+	if err := r.conn(ctx).Where("code = ?", code).First(&result).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -48,3 +51,12 @@ func (r *projectRepository) FindByCode(ctx context.Context, code string) (*model
 	}
 	return &result, nil
 }
+
+// FindArchivedBefore returns projects whose archived_at is set and earlier than before.
+func (r *projectRepository) FindArchivedBefore(ctx context.Context, before time.Time) ([]model.Project, error) {
+	var results []model.Project
+	if err := r.conn(ctx).Where("archived_at IS NOT NULL AND archived_at < ?", before).Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IProjectMemberRepository interface {
+	IRepository[model.ProjectMember]
+	// FindByProjectAndUser returns the user's membership of the project, or nil.
+	FindByProjectAndUser(ctx context.Context, projectID, userID string) *model.ProjectMember
+	// FindByProjectID returns the project's members, optionally only those with status, newest first.
+	FindByProjectID(ctx context.Context, projectID, status string) ([]model.ProjectMember, error)
+	// FindByUserID returns the user's memberships, optionally only those with status.
+	FindByUserID(ctx context.Context, userID, status string) ([]model.ProjectMember, error)
+}
+
+type projectMemberRepository struct {
+	Repository[model.ProjectMember]
+}
+
+func NewProjectMemberRepository(dbClient *gorm.DB) IProjectMemberRepository {
+	return &projectMemberRepository{Repository: Repository[model.ProjectMember]{dbClient: dbClient}}
+}
+
+func (r *projectMemberRepository) FindByProjectAndUser(ctx context.Context, projectID, userID string) *model.ProjectMember {
+	var member model.ProjectMember
+	if err := r.conn(ctx).Where("project_id = ? AND user_id = ?", projectID, userID).First(&member).Error; err != nil {
+		return nil
+	}
+	return &member
+}
+
+func (r *projectMemberRepository) FindByProjectID(ctx context.Context, projectID, status string) ([]model.ProjectMember, error) {
+	var members []model.ProjectMember
+	query := r.conn(ctx).Where("project_id = ?", projectID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at DESC").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *projectMemberRepository) FindByUserID(ctx context.Context, userID, status string) ([]model.ProjectMember, error) {
+	var members []model.ProjectMember
+	query := r.conn(ctx).Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at ASC").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IRelationNamespaceRepository interface {
+	IRepository[model.RelationNamespace]
+	// FindByName returns the namespace configuration, or nil if the namespace has none.
+	FindByName(ctx context.Context, name string) *model.RelationNamespace
+}
+
+type relationNamespaceRepository struct {
+	Repository[model.RelationNamespace]
+}
+
+func NewRelationNamespaceRepository(dbClient *gorm.DB) IRelationNamespaceRepository {
+	return &relationNamespaceRepository{Repository: Repository[model.RelationNamespace]{dbClient: dbClient}}
+}
+
+func (r *relationNamespaceRepository) FindByName(ctx context.Context, name string) *model.RelationNamespace {
+	var result model.RelationNamespace
+	if err := r.conn(ctx).Where("name = ?", name).First(&result).Error; err != nil {
+		return nil
+	}
+	return &result
+}
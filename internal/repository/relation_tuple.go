@@ -17,8 +17,14 @@ type IRelationTupleRepository interface {
 	ListByObject(ctx context.Context, namespace, objectID string, limit, offset int) ([]model.RelationTuple, int64, error)
 	ListBySubject(ctx context.Context, subjectNamespace, subjectObjectID string, limit, offset int) ([]model.RelationTuple, int64, error)
 	ListByRelation(ctx context.Context, namespace, relation string, limit, offset int) ([]model.RelationTuple, int64, error)
-	ListWithFilters(ctx context.Context, filters map[string]interface{}, limit, offset int) ([]model.RelationTuple, int64, error)
+	// ListWithFilters returns a page of the tuples matching filters, newest first. total is the count before
+	// pagination, only counted when the page has no cursor.
+	ListWithFilters(ctx context.Context, filters map[string]interface{}, page Page) ([]model.RelationTuple, int64, error)
+	FindInBatches(ctx context.Context, filters map[string]interface{}, batchSize int, fn func([]model.RelationTuple) error) error
 	ExpandSubjects(ctx context.Context, namespace, objectID, relation string) ([]model.RelationTuple, error)
+	ListUsersets(ctx context.Context, namespace, objectID, relation string) ([]model.RelationTuple, error)
+	FindBySubject(ctx context.Context, subjectNamespace, subjectObjectID, subjectRelation string) ([]model.RelationTuple, error)
+	SaveBatch(ctx context.Context, creates, updates []model.RelationTuple) error
 	DeleteByTuple(ctx context.Context, namespace, objectID, relation, subjectNamespace, subjectObjectID, subjectRelation string) error
 	CleanupExpired(ctx context.Context) (int64, error)
 }
@@ -34,7 +40,7 @@ func NewRelationTupleRepository(dbClient *gorm.DB) IRelationTupleRepository {
 // FindByTuple finds a specific relation tuple
 func (r *relationTupleRepository) FindByTuple(ctx context.Context, namespace, objectID, relation, subjectNamespace, subjectObjectID, subjectRelation string) (*model.RelationTuple, error) {
 	var tuple model.RelationTuple
-	query := r.dbClient.WithContext(ctx).Where(
+	query := r.conn(ctx).Where(
 		"namespace = ? AND object_id = ? AND relation = ? AND subject_namespace = ? AND subject_object_id = ?",
 		namespace, objectID, relation, subjectNamespace, subjectObjectID,
 	)
@@ -54,12 +60,13 @@ func (r *relationTupleRepository) FindByTuple(ctx context.Context, namespace, ob
 	return &tuple, nil
 }
 
-// CheckPermission checks if a permission exists and is valid
+// CheckPermission checks if a permission exists and is valid. The literal is_active predicate lets the planner
+// use the partial idx_relation_tuples_active_check index.
 func (r *relationTupleRepository) CheckPermission(ctx context.Context, namespace, objectID, relation, subjectNamespace, subjectObjectID string) (bool, error) {
 	var count int64
-	err := r.dbClient.WithContext(ctx).Model(&model.RelationTuple{}).Where(
-		"namespace = ? AND object_id = ? AND relation = ? AND subject_namespace = ? AND subject_object_id = ? AND is_active = ?",
-		namespace, objectID, relation, subjectNamespace, subjectObjectID, true,
+	err := r.conn(ctx).Model(&model.RelationTuple{}).Where(
+		"namespace = ? AND object_id = ? AND relation = ? AND subject_namespace = ? AND subject_object_id = ? AND is_active",
+		namespace, objectID, relation, subjectNamespace, subjectObjectID,
 	).Where("expires_at IS NULL OR expires_at > ?", time.Now()).Count(&count).Error
 	
 	if err != nil {
@@ -73,7 +80,7 @@ func (r *relationTupleRepository) ListByObject(ctx context.Context, namespace, o
 	var tuples []model.RelationTuple
 	var total int64
 	
-	query := r.dbClient.WithContext(ctx).Model(&model.RelationTuple{}).Where("namespace = ? AND object_id = ?", namespace, objectID)
+	query := r.conn(ctx).Model(&model.RelationTuple{}).Where("namespace = ? AND object_id = ?", namespace, objectID)
 	
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -91,7 +98,7 @@ func (r *relationTupleRepository) ListBySubject(ctx context.Context, subjectName
 	var tuples []model.RelationTuple
 	var total int64
 	
-	query := r.dbClient.WithContext(ctx).Model(&model.RelationTuple{}).Where("subject_namespace = ? AND subject_object_id = ?", subjectNamespace, subjectObjectID)
+	query := r.conn(ctx).Model(&model.RelationTuple{}).Where("subject_namespace = ? AND subject_object_id = ?", subjectNamespace, subjectObjectID)
 	
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -109,7 +116,7 @@ func (r *relationTupleRepository) ListByRelation(ctx context.Context, namespace,
 	var tuples []model.RelationTuple
 	var total int64
 	
-	query := r.dbClient.WithContext(ctx).Model(&model.RelationTuple{}).Where("namespace = ? AND relation = ?", namespace, relation)
+	query := r.conn(ctx).Model(&model.RelationTuple{}).Where("namespace = ? AND relation = ?", namespace, relation)
 	
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -123,11 +130,11 @@ func (r *relationTupleRepository) ListByRelation(ctx context.Context, namespace,
 }
 
 // ListWithFilters lists permissions with dynamic filters
-func (r *relationTupleRepository) ListWithFilters(ctx context.Context, filters map[string]interface{}, limit, offset int) ([]model.RelationTuple, int64, error) {
+func (r *relationTupleRepository) ListWithFilters(ctx context.Context, filters map[string]interface{}, page Page) ([]model.RelationTuple, int64, error) {
 	var tuples []model.RelationTuple
 	var total int64
 	
-	query := r.dbClient.WithContext(ctx).Model(&model.RelationTuple{})
+	query := r.conn(ctx).Model(&model.RelationTuple{})
 	
 	for key, value := range filters {
 		if value != "" && value != nil {
@@ -135,23 +142,60 @@ func (r *relationTupleRepository) ListWithFilters(ctx context.Context, filters m
 		}
 	}
 	
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+	if page.Cursor == nil {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, err
+		}
 	}
 	
-	if err := query.Limit(limit).Offset(offset).Find(&tuples).Error; err != nil {
+	if err := paged(query, page).Find(&tuples).Error; err != nil {
 		return nil, 0, err
 	}
 	
 	return tuples, total, nil
 }
 
+// FindInBatches calls fn with the tuples matching filters, batchSize at a time in primary key order,
+// stopping at the first error fn returns
+func (r *relationTupleRepository) FindInBatches(ctx context.Context, filters map[string]interface{}, batchSize int, fn func([]model.RelationTuple) error) error {
+	query := r.conn(ctx).Model(&model.RelationTuple{})
+	for key, value := range filters {
+		if value != "" && value != nil {
+			query = query.Where(key+" = ?", value)
+		}
+	}
+
+	var tuples []model.RelationTuple
+	return query.FindInBatches(&tuples, batchSize, func(tx *gorm.DB, batch int) error {
+		return fn(tuples)
+	}).Error
+}
+
+// SaveBatch inserts creates and rewrites the condition, expiry and active flag of updates in one
+// transaction, so either every tuple is saved or none is
+func (r *relationTupleRepository) SaveBatch(ctx context.Context, creates, updates []model.RelationTuple) error {
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(creates) > 0 {
+			if err := tx.Create(&creates).Error; err != nil {
+				return err
+			}
+		}
+		for i := range updates {
+			err := tx.Model(&updates[i]).Select("condition", "expires_at", "is_active", "updated_at").Updates(updates[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // ExpandSubjects gets all subjects with a specific permission on an object
 func (r *relationTupleRepository) ExpandSubjects(ctx context.Context, namespace, objectID, relation string) ([]model.RelationTuple, error) {
 	var tuples []model.RelationTuple
-	err := r.dbClient.WithContext(ctx).Where(
-		"namespace = ? AND object_id = ? AND relation = ? AND is_active = ?",
-		namespace, objectID, relation, true,
+	err := r.conn(ctx).Where(
+		"namespace = ? AND object_id = ? AND relation = ? AND is_active",
+		namespace, objectID, relation,
 	).Where("expires_at IS NULL OR expires_at > ?", time.Now()).Find(&tuples).Error
 	
 	if err != nil {
@@ -160,9 +204,42 @@ func (r *relationTupleRepository) ExpandSubjects(ctx context.Context, namespace,
 	return tuples, nil
 }
 
+// ListUsersets gets the valid tuples of an object relation whose subject is a userset (e.g. group:eng#member)
+func (r *relationTupleRepository) ListUsersets(ctx context.Context, namespace, objectID, relation string) ([]model.RelationTuple, error) {
+	var tuples []model.RelationTuple
+	err := r.conn(ctx).Where(
+		"namespace = ? AND object_id = ? AND relation = ? AND is_active AND subject_relation <> ''",
+		namespace, objectID, relation,
+	).Where("expires_at IS NULL OR expires_at > ?", time.Now()).Find(&tuples).Error
+	if err != nil {
+		return nil, err
+	}
+	return tuples, nil
+}
+
+// FindBySubject gets the valid tuples granted to exactly this subject; an empty subjectRelation matches plain subjects
+func (r *relationTupleRepository) FindBySubject(ctx context.Context, subjectNamespace, subjectObjectID, subjectRelation string) ([]model.RelationTuple, error) {
+	var tuples []model.RelationTuple
+	query := r.conn(ctx).Where(
+		"subject_namespace = ? AND subject_object_id = ? AND is_active = ?",
+		subjectNamespace, subjectObjectID, true,
+	).Where("expires_at IS NULL OR expires_at > ?", time.Now())
+
+	if subjectRelation != "" {
+		query = query.Where("subject_relation = ?", subjectRelation)
+	} else {
+		query = query.Where("subject_relation IS NULL OR subject_relation = ''")
+	}
+
+	if err := query.Find(&tuples).Error; err != nil {
+		return nil, err
+	}
+	return tuples, nil
+}
+
 // DeleteByTuple deletes a specific relation tuple
 func (r *relationTupleRepository) DeleteByTuple(ctx context.Context, namespace, objectID, relation, subjectNamespace, subjectObjectID, subjectRelation string) error {
-	query := r.dbClient.WithContext(ctx).Where(
+	query := r.conn(ctx).Where(
 		"namespace = ? AND object_id = ? AND relation = ? AND subject_namespace = ? AND subject_object_id = ?",
 		namespace, objectID, relation, subjectNamespace, subjectObjectID,
 	)
@@ -178,7 +255,7 @@ func (r *relationTupleRepository) DeleteByTuple(ctx context.Context, namespace,
 
 // CleanupExpired removes expired relation tuples
 func (r *relationTupleRepository) CleanupExpired(ctx context.Context) (int64, error) {
-	result := r.dbClient.WithContext(ctx).Where("expires_at IS NOT NULL AND expires_at <= ?", time.Now()).Delete(&model.RelationTuple{})
+	result := r.conn(ctx).Where("expires_at IS NOT NULL AND expires_at <= ?", time.Now()).Delete(&model.RelationTuple{})
 	if result.Error != nil {
 		return 0, result.Error
 	}
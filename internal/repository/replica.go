@@ -0,0 +1,17 @@
+package repository
+
+import "context"
+
+type replicaContextKey struct{}
+
+// WithReplicaReads lets reads made with the returned context go to a read replica, when POSTGRES_REPLICA_HOSTS
+// configures any. Use it only where results a little behind the primary are acceptable, such as listings;
+// other reads stay on the primary so they see every committed write. It has no effect inside WithTx.
+func WithReplicaReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaContextKey{}, true)
+}
+
+func replicaReads(ctx context.Context) bool {
+	ok, _ := ctx.Value(replicaContextKey{}).(bool)
+	return ok
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IRevokedTokenRepository interface {
+	IRepository[model.RevokedToken]
+	// ExistsByJTI reports whether the jti has been revoked.
+	ExistsByJTI(ctx context.Context, jti string) (bool, error)
+	// DeleteExpired removes entries for tokens that expired before t.
+	DeleteExpired(ctx context.Context, t time.Time) error
+}
+
+type revokedTokenRepository struct {
+	Repository[model.RevokedToken]
+}
+
+func NewRevokedTokenRepository(dbClient *gorm.DB) IRevokedTokenRepository {
+	return &revokedTokenRepository{Repository: Repository[model.RevokedToken]{dbClient: dbClient}}
+}
+
+func (r *revokedTokenRepository) ExistsByJTI(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	if err := r.conn(ctx).Model(new(model.RevokedToken)).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *revokedTokenRepository) DeleteExpired(ctx context.Context, t time.Time) error {
+	return r.conn(ctx).Where("expires_at < ?", t).Delete(new(model.RevokedToken)).Error
+}
@@ -13,7 +13,9 @@ type IRoleRepository interface {
 	FindByCode(ctx context.Context, code string) (*model.Role, error)
 	FindByProjectID(ctx context.Context, projectID *string, limit, offset int) ([]model.Role, int64, error)
 	FindSystemRoles(ctx context.Context, limit, offset int) ([]model.Role, int64, error)
-	SearchRoles(ctx context.Context, search string, projectID *string, isActive *bool, limit, offset int) ([]model.Role, int64, error)
+	// SearchRoles returns a page of the roles matching the filters, newest first. total is the count before
+	// pagination, only counted when the page has no cursor.
+	SearchRoles(ctx context.Context, search string, projectID *string, isActive *bool, page Page) ([]model.Role, int64, error)
 	IsSystemRole(ctx context.Context, roleID string) (bool, error)
 }
 
@@ -28,7 +30,7 @@ func NewRoleRepository(dbClient *gorm.DB) IRoleRepository {
 // FindByCode finds a role by its code
 func (r *roleRepository) FindByCode(ctx context.Context, code string) (*model.Role, error) {
 	var role model.Role
-	if err := r.dbClient.WithContext(ctx).Where("code = ?", code).First(&role).Error; err != nil {
+	if err := r.conn(ctx).Where("code = ?", code).First(&role).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -42,7 +44,7 @@ func (r *roleRepository) FindByProjectID(ctx context.Context, projectID *string,
 	var roles []model.Role
 	var total int64
 	
-	query := r.dbClient.WithContext(ctx).Model(&model.Role{})
+	query := r.conn(ctx).Model(&model.Role{})
 	
 	if projectID == nil {
 		query = query.Where("project_id IS NULL")
@@ -66,7 +68,7 @@ func (r *roleRepository) FindSystemRoles(ctx context.Context, limit, offset int)
 	var roles []model.Role
 	var total int64
 	
-	query := r.dbClient.WithContext(ctx).Model(&model.Role{}).Where("project_id = ?", "system")
+	query := r.conn(ctx).Model(&model.Role{}).Where("project_id = ?", "system")
 	
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -80,11 +82,11 @@ func (r *roleRepository) FindSystemRoles(ctx context.Context, limit, offset int)
 }
 
 // SearchRoles searches roles with filters
-func (r *roleRepository) SearchRoles(ctx context.Context, search string, projectID *string, isActive *bool, limit, offset int) ([]model.Role, int64, error) {
+func (r *roleRepository) SearchRoles(ctx context.Context, search string, projectID *string, isActive *bool, page Page) ([]model.Role, int64, error) {
 	var roles []model.Role
 	var total int64
 	
-	query := r.dbClient.WithContext(ctx).Model(&model.Role{})
+	query := r.conn(ctx).Model(&model.Role{})
 	
 	if search != "" {
 		query = query.Where("code ILIKE ? OR name ILIKE ?", "%"+search+"%", "%"+search+"%")
@@ -102,11 +104,13 @@ func (r *roleRepository) SearchRoles(ctx context.Context, search string, project
 		query = query.Where("is_active = ?", *isActive)
 	}
 	
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+	if page.Cursor == nil {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, err
+		}
 	}
 	
-	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&roles).Error; err != nil {
+	if err := paged(query, page).Find(&roles).Error; err != nil {
 		return nil, 0, err
 	}
 	
@@ -116,7 +120,7 @@ func (r *roleRepository) SearchRoles(ctx context.Context, search string, project
 // IsSystemRole checks if a role is a system role
 func (r *roleRepository) IsSystemRole(ctx context.Context, roleID string) (bool, error) {
 	var count int64
-	err := r.dbClient.WithContext(ctx).Model(&model.Role{}).
+	err := r.conn(ctx).Model(&model.Role{}).
 		Where("id = ? AND project_id = ?", roleID, "system").
 		Count(&count).Error
 	
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IRoleHistoryRepository interface {
+	IRepository[model.RoleHistory]
+	// ListByRoleID returns the role's history, newest first. total is the count before pagination.
+	ListByRoleID(ctx context.Context, roleID string, offset, limit int) ([]model.RoleHistory, int64, error)
+}
+
+type roleHistoryRepository struct {
+	Repository[model.RoleHistory]
+}
+
+func NewRoleHistoryRepository(dbClient *gorm.DB) IRoleHistoryRepository {
+	return &roleHistoryRepository{Repository: Repository[model.RoleHistory]{dbClient: dbClient}}
+}
+
+func (r *roleHistoryRepository) ListByRoleID(ctx context.Context, roleID string, offset, limit int) ([]model.RoleHistory, int64, error) {
+	query := r.conn(ctx).Model(new(model.RoleHistory)).Where("role_id = ?", roleID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var results []model.RoleHistory
+	// id breaks ties between changes recorded in the same transaction; UUIDv6 ids sort by creation time.
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&results).Error; err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type ISAMLConnectionRepository interface {
+	IRepository[model.SAMLConnection]
+	// FindByProjectID returns the project's SAML connection, or nil if it has none.
+	FindByProjectID(ctx context.Context, projectID string) *model.SAMLConnection
+}
+
+type samlConnectionRepository struct {
+	Repository[model.SAMLConnection]
+}
+
+func NewSAMLConnectionRepository(dbClient *gorm.DB) ISAMLConnectionRepository {
+	return &samlConnectionRepository{Repository: Repository[model.SAMLConnection]{dbClient: dbClient}}
+}
+
+func (r *samlConnectionRepository) FindByProjectID(ctx context.Context, projectID string) *model.SAMLConnection {
+	var result model.SAMLConnection
+	if err := r.conn(ctx).Where("project_id = ?", projectID).First(&result).Error; err != nil {
+		return nil
+	}
+	return &result
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type ISCIMUserRepository interface {
+	IRepository[model.SCIMUser]
+	// FindByUserID returns the user's SCIM link with User preloaded, or nil.
+	FindByUserID(ctx context.Context, userID string) *model.SCIMUser
+	// FindByExternalID returns the project's link for an identity provider ID with User preloaded, or nil.
+	FindByExternalID(ctx context.Context, projectID, externalID string) *model.SCIMUser
+	// FindByProjectID returns the project's links with User preloaded. total is the count before pagination.
+	FindByProjectID(ctx context.Context, projectID string, limit, offset int) ([]model.SCIMUser, int64, error)
+}
+
+type scimUserRepository struct {
+	Repository[model.SCIMUser]
+}
+
+func NewSCIMUserRepository(dbClient *gorm.DB) ISCIMUserRepository {
+	return &scimUserRepository{Repository: Repository[model.SCIMUser]{dbClient: dbClient}}
+}
+
+func (r *scimUserRepository) FindByUserID(ctx context.Context, userID string) *model.SCIMUser {
+	var link model.SCIMUser
+	if err := r.conn(ctx).Preload("User").Where("user_id = ?", userID).First(&link).Error; err != nil {
+		return nil
+	}
+	return &link
+}
+
+func (r *scimUserRepository) FindByExternalID(ctx context.Context, projectID, externalID string) *model.SCIMUser {
+	var link model.SCIMUser
+	if err := r.conn(ctx).Preload("User").
+		Where("project_id = ? AND external_id = ?", projectID, externalID).
+		First(&link).Error; err != nil {
+		return nil
+	}
+	return &link
+}
+
+func (r *scimUserRepository) FindByProjectID(ctx context.Context, projectID string, limit, offset int) ([]model.SCIMUser, int64, error) {
+	query := r.conn(ctx).Model(&model.SCIMUser{}).Where("project_id = ?", projectID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var links []model.SCIMUser
+	if err := query.Preload("User").Order("created_at ASC").Limit(limit).Offset(offset).Find(&links).Error; err != nil {
+		return nil, 0, err
+	}
+	return links, total, nil
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IServiceAccountRepository interface {
+	IRepository[model.ServiceAccount]
+	// FindByClientID returns the account with the given client_id, or nil.
+	FindByClientID(ctx context.Context, clientID string) *model.ServiceAccount
+	FindByProjectID(ctx context.Context, projectID string) ([]model.ServiceAccount, error)
+}
+
+type serviceAccountRepository struct {
+	Repository[model.ServiceAccount]
+}
+
+func NewServiceAccountRepository(dbClient *gorm.DB) IServiceAccountRepository {
+	return &serviceAccountRepository{Repository: Repository[model.ServiceAccount]{dbClient: dbClient}}
+}
+
+func (r *serviceAccountRepository) FindByClientID(ctx context.Context, clientID string) *model.ServiceAccount {
+	var account model.ServiceAccount
+	if err := r.conn(ctx).Where("client_id = ?", clientID).First(&account).Error; err != nil {
+		return nil
+	}
+	return &account
+}
+
+func (r *serviceAccountRepository) FindByProjectID(ctx context.Context, projectID string) ([]model.ServiceAccount, error) {
+	var accounts []model.ServiceAccount
+	if err := r.conn(ctx).Where("project_id = ?", projectID).Order("created_at DESC").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
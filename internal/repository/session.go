@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/hiamthach108/dreon-auth/internal/model"
 	"gorm.io/gorm"
@@ -9,7 +10,45 @@ import (
 
 type ISessionRepository interface {
 	IRepository[model.Session]
-	FindByRefreshToken(ctx context.Context, refreshToken string) *model.Session
+	// FindByRefreshTokenHash returns the session for a hashed refresh token, or nil.
+	FindByRefreshTokenHash(ctx context.Context, refreshTokenHash string) *model.Session
+	// IsActive reports whether the session exists and is active. Unlike FindOneById it returns query errors.
+	IsActive(ctx context.Context, id string) (bool, error)
+	// FindActiveByUserID returns the user's sessions that are still active.
+	FindActiveByUserID(ctx context.Context, userID string) ([]model.Session, error)
+	// FindByUserID returns all of the user's sessions, newest first.
+	FindByUserID(ctx context.Context, userID string) ([]model.Session, error)
+	// ListByUserID returns a page of the user's sessions, newest first. total is the count before pagination,
+	// only counted when the page has no cursor.
+	ListByUserID(ctx context.Context, userID string, page Page) ([]model.Session, int64, error)
+	// Search returns a page of the sessions matching filter, newest first. total is the count before pagination,
+	// only counted when the page has no cursor.
+	Search(ctx context.Context, filter SessionFilter, page Page) ([]model.Session, int64, error)
+	// CountDailyUsers counts, per UTC day in [from, to), the distinct users who were issued a session by a
+	// login or a refresh. Super admins are left out; days without sessions are omitted.
+	CountDailyUsers(ctx context.Context, from, to time.Time) ([]DailyCount, error)
+	// CountLoginsByMethod counts the sessions started by a login in [from, to), by login method.
+	CountLoginsByMethod(ctx context.Context, from, to time.Time) (map[string]int64, error)
+	// DeleteByUserID permanently removes all of the user's sessions.
+	DeleteByUserID(ctx context.Context, userID string) error
+	// DeleteStale permanently removes sessions that expired, or were ended, before t.
+	DeleteStale(ctx context.Context, t time.Time) (int64, error)
+}
+
+// SessionFilter narrows Search. Zero fields match every session.
+type SessionFilter struct {
+	UserID string
+	IP     string    // the client IP the session was issued to
+	From   time.Time // issued at or after
+	To     time.Time // issued before
+	// Active selects sessions that are active and unexpired (true) or ended or expired (false).
+	Active *bool
+}
+
+// DailyCount is a count for one UTC day.
+type DailyCount struct {
+	Day   time.Time
+	Count int64
 }
 
 type sessionRepository struct {
@@ -20,13 +59,135 @@ func NewSessionRepository(dbClient *gorm.DB) ISessionRepository {
 	return &sessionRepository{Repository: Repository[model.Session]{dbClient: dbClient}}
 }
 
-func (r *sessionRepository) FindByRefreshToken(ctx context.Context, refreshToken string) *model.Session {
+func (r *sessionRepository) IsActive(ctx context.Context, id string) (bool, error) {
+	var count int64
+	err := r.conn(ctx).Model(&model.Session{}).Where("id = ? AND is_active = ?", id, true).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *sessionRepository) FindByRefreshTokenHash(ctx context.Context, refreshTokenHash string) *model.Session {
 	var result model.Session
-	err := r.dbClient.WithContext(ctx).Where(&model.Session{
-		RefreshToken: refreshToken,
+	err := r.conn(ctx).Where(&model.Session{
+		RefreshTokenHash: refreshTokenHash,
 	}).First(&result).Error
 	if err != nil {
 		return nil
 	}
 	return &result
 }
+
+func (r *sessionRepository) FindActiveByUserID(ctx context.Context, userID string) ([]model.Session, error) {
+	var results []model.Session
+	if err := r.conn(ctx).
+		Where("user_id = ? AND is_active = ?", userID, true).
+		Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *sessionRepository) FindByUserID(ctx context.Context, userID string) ([]model.Session, error) {
+	var results []model.Session
+	if err := r.conn(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *sessionRepository) ListByUserID(ctx context.Context, userID string, page Page) ([]model.Session, int64, error) {
+	query := r.conn(ctx).Model(new(model.Session)).Where("user_id = ?", userID)
+	var total int64
+	if page.Cursor == nil {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	var results []model.Session
+	if err := paged(query, page).Find(&results).Error; err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+func (r *sessionRepository) Search(ctx context.Context, filter SessionFilter, page Page) ([]model.Session, int64, error) {
+	query := r.conn(ctx).Model(new(model.Session))
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.IP != "" {
+		query = query.Where("metadata->>'ip' = ?", filter.IP)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	if filter.Active != nil {
+		if *filter.Active {
+			query = query.Where("is_active = ? AND expires_at > ?", true, time.Now())
+		} else {
+			query = query.Where("(is_active = ? OR expires_at <= ?)", false, time.Now())
+		}
+	}
+	var total int64
+	if page.Cursor == nil {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	var results []model.Session
+	if err := paged(query, page).Find(&results).Error; err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+func (r *sessionRepository) CountDailyUsers(ctx context.Context, from, to time.Time) ([]DailyCount, error) {
+	var results []DailyCount
+	err := r.conn(ctx).Model(new(model.Session)).
+		Select("date_trunc('day', created_at AT TIME ZONE 'UTC') AS day, COUNT(DISTINCT user_id) AS count").
+		Where("created_at >= ? AND created_at < ? AND is_super_admin = ?", from, to, false).
+		Group("day").
+		Order("day").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *sessionRepository) CountLoginsByMethod(ctx context.Context, from, to time.Time) (map[string]int64, error) {
+	var rows []struct {
+		Method string
+		Count  int64
+	}
+	err := r.conn(ctx).Model(new(model.Session)).
+		Select("metadata->>'login_method' AS method, COUNT(*) AS count").
+		Where("created_at >= ? AND created_at < ? AND metadata->>'login_method' IS NOT NULL", from, to).
+		Group("method").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		results[row.Method] = row.Count
+	}
+	return results, nil
+}
+
+func (r *sessionRepository) DeleteByUserID(ctx context.Context, userID string) error {
+	return r.conn(ctx).Unscoped().Where("user_id = ?", userID).Delete(&model.Session{}).Error
+}
+
+func (r *sessionRepository) DeleteStale(ctx context.Context, t time.Time) (int64, error) {
+	// Unscoped: a soft delete would keep the refresh token digests around.
+	result := r.conn(ctx).Unscoped().
+		Where("expires_at < ? OR (is_active = ? AND updated_at < ?)", t, false, t).
+		Delete(&model.Session{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type ISigningKeyRepository interface {
+	IRepository[model.SigningKey]
+	// FindUnretired returns the keys that have not been retired, oldest first.
+	FindUnretired(ctx context.Context) ([]model.SigningKey, error)
+	// FindByKID returns the key with the given kid, or nil.
+	FindByKID(ctx context.Context, kid string) *model.SigningKey
+}
+
+type signingKeyRepository struct {
+	Repository[model.SigningKey]
+}
+
+func NewSigningKeyRepository(dbClient *gorm.DB) ISigningKeyRepository {
+	return &signingKeyRepository{Repository: Repository[model.SigningKey]{dbClient: dbClient}}
+}
+
+func (r *signingKeyRepository) FindUnretired(ctx context.Context) ([]model.SigningKey, error) {
+	var results []model.SigningKey
+	if err := r.conn(ctx).
+		Where("retired_at IS NULL").
+		Order("created_at ASC").
+		Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *signingKeyRepository) FindByKID(ctx context.Context, kid string) *model.SigningKey {
+	var result model.SigningKey
+	if err := r.conn(ctx).Where("kid = ?", kid).First(&result).Error; err != nil {
+		return nil
+	}
+	return &result
+}
@@ -9,6 +9,7 @@ import (
 
 type ISuperAdminRepository interface {
 	IRepository[model.SuperAdmin]
+	// FindByEmail returns the active super admin with the email, or nil if there is none.
 	FindByEmail(ctx context.Context, email string) (*model.SuperAdmin, error)
 }
 
@@ -22,11 +23,14 @@ func NewSuperAdminRepository(dbClient *gorm.DB) ISuperAdminRepository {
 
 func (r *superAdminRepository) FindByEmail(ctx context.Context, email string) (*model.SuperAdmin, error) {
 	var result model.SuperAdmin
-	err := r.dbClient.WithContext(ctx).
+	err := r.conn(ctx).
 		Where(&model.SuperAdmin{Email: email, IsActive: true}).
 		First(&result).
 		Error
 	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
 		return nil, err
 	}
 
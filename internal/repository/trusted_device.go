@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type ITrustedDeviceRepository interface {
+	IRepository[model.TrustedDevice]
+	// FindByUserID returns the user's trusted devices, most recently trusted first.
+	FindByUserID(ctx context.Context, userID string) ([]model.TrustedDevice, error)
+	// FindByTokenHash returns the device for a hashed device token, or nil.
+	FindByTokenHash(ctx context.Context, tokenHash string) *model.TrustedDevice
+	// DeleteByUserID removes all of the user's trusted devices.
+	DeleteByUserID(ctx context.Context, userID string) error
+}
+
+type trustedDeviceRepository struct {
+	Repository[model.TrustedDevice]
+}
+
+func NewTrustedDeviceRepository(dbClient *gorm.DB) ITrustedDeviceRepository {
+	return &trustedDeviceRepository{Repository: Repository[model.TrustedDevice]{dbClient: dbClient}}
+}
+
+func (r *trustedDeviceRepository) FindByUserID(ctx context.Context, userID string) ([]model.TrustedDevice, error) {
+	var results []model.TrustedDevice
+	if err := r.conn(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *trustedDeviceRepository) FindByTokenHash(ctx context.Context, tokenHash string) *model.TrustedDevice {
+	var result model.TrustedDevice
+	if err := r.conn(ctx).Where("token_hash = ?", tokenHash).First(&result).Error; err != nil {
+		return nil
+	}
+	return &result
+}
+
+func (r *trustedDeviceRepository) DeleteByUserID(ctx context.Context, userID string) error {
+	return r.conn(ctx).Where("user_id = ?", userID).Delete(new(model.TrustedDevice)).Error
+}
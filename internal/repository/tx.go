@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+type txContextKey struct{}
+
+// ITxManager runs several repository calls atomically.
+type ITxManager interface {
+	// WithTx runs fn in a database transaction, committed when fn returns nil and rolled back otherwise.
+	// Repository calls made with the context passed to fn join the transaction; calls made with any other
+	// context do not. A WithTx inside fn joins the outer transaction instead of starting its own.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txManager struct {
+	dbClient *gorm.DB
+}
+
+func NewTxManager(dbClient *gorm.DB) ITxManager {
+	return &txManager{dbClient: dbClient}
+}
+
+func (m *txManager) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txContextKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	return m.dbClient.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txContextKey{}, tx))
+	})
+}
+
+// conn returns the transaction WithTx put in ctx, or else dbClient, bound to ctx. Outside WithReplicaReads
+// the connection is pinned to the primary.
+func conn(ctx context.Context, dbClient *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txContextKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	if replicaReads(ctx) {
+		return dbClient.WithContext(ctx)
+	}
+	return dbClient.WithContext(ctx).Clauses(dbresolver.Write)
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/hiamthach108/dreon-auth/internal/model"
 	"gorm.io/gorm"
@@ -10,10 +11,19 @@ import (
 // IUserRepository defines the contract for user persistence.
 type IUserRepository interface {
 	IRepository[model.User]
-	// List returns users with pagination. total is the total count before pagination.
-	List(ctx context.Context, offset, limit int) ([]model.User, int64, error)
+	// List returns a page of users, newest first. total is the count before pagination, only counted when
+	// the page has no cursor.
+	List(ctx context.Context, page Page) ([]model.User, int64, error)
+	// ListAfter returns up to limit users with IDs after afterID, in ID order, for walking all users in batches.
+	ListAfter(ctx context.Context, afterID string, limit int) ([]model.User, error)
 	// FindByEmail returns a user by email, or nil if not found.
 	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	// FindByPhone returns a user by E.164 phone number, or nil if not found.
+	FindByPhone(ctx context.Context, phone string) (*model.User, error)
+	// FindByUsername returns a user by username, or nil if not found.
+	FindByUsername(ctx context.Context, username string) (*model.User, error)
+	// FindErasedBefore returns users, deleted or not, whose account was erased before the given time.
+	FindErasedBefore(ctx context.Context, before time.Time) ([]model.User, error)
 }
 
 type userRepository struct {
@@ -27,15 +37,25 @@ func NewUserRepository(dbClient *gorm.DB) IUserRepository {
 	}
 }
 
-// List returns a paginated list of users and total count.
-func (r *userRepository) List(ctx context.Context, offset, limit int) ([]model.User, int64, error) {
+// ListAfter returns the next batch of users after afterID.
+func (r *userRepository) ListAfter(ctx context.Context, afterID string, limit int) ([]model.User, error) {
+	var results []model.User
+	if err := r.conn(ctx).Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// List returns a page of users and, without a cursor, the total count.
+func (r *userRepository) List(ctx context.Context, page Page) ([]model.User, int64, error) {
 	var total int64
-	if err := r.dbClient.WithContext(ctx).Model(new(model.User)).Count(&total).Error; err != nil {
-		return nil, 0, err
+	if page.Cursor == nil {
+		if err := r.conn(ctx).Model(new(model.User)).Count(&total).Error; err != nil {
+			return nil, 0, err
+		}
 	}
 	var results []model.User
-	q := r.dbClient.WithContext(ctx).Offset(offset).Limit(limit)
-	if err := q.Find(&results).Error; err != nil {
+	if err := paged(r.conn(ctx), page).Find(&results).Error; err != nil {
 		return nil, 0, err
 	}
 	return results, total, nil
@@ -44,7 +64,19 @@ func (r *userRepository) List(ctx context.Context, offset, limit int) ([]model.U
 // FindByEmail returns one user by email.
 func (r *userRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
 	var result model.User
-	if err := r.dbClient.WithContext(ctx).Where("email = ?", email).First(&result).Error; err != nil {
+	if err := r.conn(ctx).Where("email = ?", email).First(&result).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &result, nil
+}
+
+// FindByPhone returns one user by phone number.
+func (r *userRepository) FindByPhone(ctx context.Context, phone string) (*model.User, error) {
+	var result model.User
+	if err := r.conn(ctx).Where("phone = ?", phone).First(&result).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -52,3 +84,24 @@ func (r *userRepository) FindByEmail(ctx context.Context, email string) (*model.
 	}
 	return &result, nil
 }
+
+// FindByUsername returns one user by username.
+func (r *userRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
+	var result model.User
+	if err := r.conn(ctx).Where("username = ?", username).First(&result).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &result, nil
+}
+
+// FindErasedBefore returns erased users, including soft-deleted ones, whose erased_at is earlier than before.
+func (r *userRepository) FindErasedBefore(ctx context.Context, before time.Time) ([]model.User, error) {
+	var results []model.User
+	if err := r.conn(ctx).Unscoped().Where("erased_at IS NOT NULL AND erased_at < ?", before).Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
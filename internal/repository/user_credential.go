@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IUserCredentialRepository interface {
+	IRepository[model.UserCredential]
+	// FindByUserID returns all credentials registered by the user, oldest first.
+	FindByUserID(ctx context.Context, userID string) ([]model.UserCredential, error)
+	// FindByUserIDAndID returns the credential only if it belongs to the user, or nil.
+	FindByUserIDAndID(ctx context.Context, userID, id string) *model.UserCredential
+}
+
+type userCredentialRepository struct {
+	Repository[model.UserCredential]
+}
+
+func NewUserCredentialRepository(dbClient *gorm.DB) IUserCredentialRepository {
+	return &userCredentialRepository{Repository: Repository[model.UserCredential]{dbClient: dbClient}}
+}
+
+func (r *userCredentialRepository) FindByUserID(ctx context.Context, userID string) ([]model.UserCredential, error) {
+	var results []model.UserCredential
+	if err := r.conn(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *userCredentialRepository) FindByUserIDAndID(ctx context.Context, userID, id string) *model.UserCredential {
+	var result model.UserCredential
+	if err := r.conn(ctx).
+		Where("id = ? AND user_id = ?", id, userID).
+		First(&result).Error; err != nil {
+		return nil
+	}
+	return &result
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+type IUserDeviceRepository interface {
+	IRepository[model.UserDevice]
+	// FindByUserID returns the user's devices, most recently seen first.
+	FindByUserID(ctx context.Context, userID string) ([]model.UserDevice, error)
+	// FindByFingerprint returns the user's device with the given fingerprint, or nil.
+	FindByFingerprint(ctx context.Context, userID, fingerprint string) *model.UserDevice
+	// DeleteByUserID permanently removes all of the user's devices.
+	DeleteByUserID(ctx context.Context, userID string) error
+}
+
+type userDeviceRepository struct {
+	Repository[model.UserDevice]
+}
+
+func NewUserDeviceRepository(dbClient *gorm.DB) IUserDeviceRepository {
+	return &userDeviceRepository{Repository: Repository[model.UserDevice]{dbClient: dbClient}}
+}
+
+func (r *userDeviceRepository) FindByUserID(ctx context.Context, userID string) ([]model.UserDevice, error) {
+	var results []model.UserDevice
+	if err := r.conn(ctx).
+		Where("user_id = ?", userID).
+		Order("last_seen_at DESC").
+		Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *userDeviceRepository) FindByFingerprint(ctx context.Context, userID, fingerprint string) *model.UserDevice {
+	var result model.UserDevice
+	if err := r.conn(ctx).Where("user_id = ? AND fingerprint = ?", userID, fingerprint).First(&result).Error; err != nil {
+		return nil
+	}
+	return &result
+}
+
+func (r *userDeviceRepository) DeleteByUserID(ctx context.Context, userID string) error {
+	return r.conn(ctx).Unscoped().Where("user_id = ?", userID).Delete(new(model.UserDevice)).Error
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"gorm.io/gorm"
+)
+
+type IUserIdentityRepository interface {
+	IRepository[model.UserIdentity]
+	// FindBySubject returns the identity for a provider's user, or nil.
+	FindBySubject(ctx context.Context, authType constant.UserAuthType, provider, providerUserID string) *model.UserIdentity
+	// FindByUserID returns the user's identities, oldest first.
+	FindByUserID(ctx context.Context, userID string) ([]model.UserIdentity, error)
+}
+
+type userIdentityRepository struct {
+	Repository[model.UserIdentity]
+}
+
+func NewUserIdentityRepository(dbClient *gorm.DB) IUserIdentityRepository {
+	return &userIdentityRepository{Repository: Repository[model.UserIdentity]{dbClient: dbClient}}
+}
+
+func (r *userIdentityRepository) FindBySubject(ctx context.Context, authType constant.UserAuthType, provider, providerUserID string) *model.UserIdentity {
+	var identity model.UserIdentity
+	if err := r.conn(ctx).
+		Where("auth_type = ? AND provider = ? AND provider_user_id = ?", authType, provider, providerUserID).
+		First(&identity).Error; err != nil {
+		return nil
+	}
+	return &identity
+}
+
+func (r *userIdentityRepository) FindByUserID(ctx context.Context, userID string) ([]model.UserIdentity, error) {
+	var identities []model.UserIdentity
+	if err := r.conn(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
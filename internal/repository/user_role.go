@@ -2,19 +2,33 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/hiamthach108/dreon-auth/internal/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type IUserRoleRepository interface {
 	IRepository[model.UserRole]
 
+	// FindByUserID returns the user's unexpired assignments with Role preloaded.
 	FindByUserID(ctx context.Context, userID string) ([]model.UserRole, error)
+	// FindByUserIDs is FindByUserID for several users, with the roles joined into a single query.
+	FindByUserIDs(ctx context.Context, userIDs []string) ([]model.UserRole, error)
 	FindByUserIDAndProjectID(ctx context.Context, userID string, projectID *string) ([]model.UserRole, error)
 	FindByUserIDAndRoleID(ctx context.Context, userID, roleID string, projectID *string) (*model.UserRole, error)
 	DeleteByUserIDAndRoleID(ctx context.Context, userID, roleID string, projectID *string) error
+	// FindWithRole returns the user's unexpired assignments, optionally in one project, with Role preloaded.
 	FindWithRole(ctx context.Context, userID string, projectID *string) ([]model.UserRole, error)
+	// FindByRoleID returns the role's assignments with User preloaded.
+	FindByRoleID(ctx context.Context, roleID string) ([]model.UserRole, error)
+	// SaveBatch creates assignments and renews the expiry of existing ones in a single transaction.
+	SaveBatch(ctx context.Context, creates, renewals []model.UserRole) error
+	// DeleteByIDs deletes the assignments with the given IDs in one statement.
+	DeleteByIDs(ctx context.Context, ids []string) error
+	// DeleteExpired removes the assignments that expired before t and returns them.
+	DeleteExpired(ctx context.Context, t time.Time) ([]model.UserRole, error)
 }
 
 type userRoleRepository struct {
@@ -28,9 +42,26 @@ func NewUserRoleRepository(dbClient *gorm.DB) IUserRoleRepository {
 // FindByUserID finds all role assignments for a user
 func (r *userRoleRepository) FindByUserID(ctx context.Context, userID string) ([]model.UserRole, error) {
 	var userRoles []model.UserRole
-	if err := r.dbClient.WithContext(ctx).
+	if err := r.conn(ctx).
 		Preload("Role").
 		Where("user_id = ?", userID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Find(&userRoles).Error; err != nil {
+		return nil, err
+	}
+	return userRoles, nil
+}
+
+// FindByUserIDs finds the role assignments of several users in one query
+func (r *userRoleRepository) FindByUserIDs(ctx context.Context, userIDs []string) ([]model.UserRole, error) {
+	var userRoles []model.UserRole
+	if len(userIDs) == 0 {
+		return userRoles, nil
+	}
+	if err := r.conn(ctx).
+		Joins("Role").
+		Where("user_roles.user_id IN ?", userIDs).
+		Where("user_roles.expires_at IS NULL OR user_roles.expires_at > ?", time.Now()).
 		Find(&userRoles).Error; err != nil {
 		return nil, err
 	}
@@ -41,7 +72,7 @@ func (r *userRoleRepository) FindByUserID(ctx context.Context, userID string) ([
 func (r *userRoleRepository) FindByUserIDAndProjectID(ctx context.Context, userID string, projectID *string) ([]model.UserRole, error) {
 	var userRoles []model.UserRole
 
-	query := r.dbClient.WithContext(ctx).Where("user_id = ?", userID)
+	query := r.conn(ctx).Where("user_id = ?", userID)
 
 	if projectID == nil {
 		query = query.Where("project_id IS NULL")
@@ -62,7 +93,7 @@ func (r *userRoleRepository) FindByUserIDAndProjectID(ctx context.Context, userI
 func (r *userRoleRepository) FindByUserIDAndRoleID(ctx context.Context, userID, roleID string, projectID *string) (*model.UserRole, error) {
 	var userRole model.UserRole
 
-	query := r.dbClient.WithContext(ctx).Where("user_id = ? AND role_id = ?", userID, roleID)
+	query := r.conn(ctx).Where("user_id = ? AND role_id = ?", userID, roleID)
 
 	if projectID == nil {
 		query = query.Where("project_id IS NULL")
@@ -82,7 +113,7 @@ func (r *userRoleRepository) FindByUserIDAndRoleID(ctx context.Context, userID,
 
 // DeleteByUserIDAndRoleID deletes a specific user role assignment
 func (r *userRoleRepository) DeleteByUserIDAndRoleID(ctx context.Context, userID, roleID string, projectID *string) error {
-	query := r.dbClient.WithContext(ctx).Where("user_id = ? AND role_id = ?", userID, roleID)
+	query := r.conn(ctx).Where("user_id = ? AND role_id = ?", userID, roleID)
 
 	if projectID == nil {
 		query = query.Where("project_id IS NULL")
@@ -97,7 +128,8 @@ func (r *userRoleRepository) DeleteByUserIDAndRoleID(ctx context.Context, userID
 func (r *userRoleRepository) FindWithRole(ctx context.Context, userID string, projectID *string) ([]model.UserRole, error) {
 	var userRoles []model.UserRole
 
-	query := r.dbClient.WithContext(ctx).Preload("Role").Where("user_id = ?", userID)
+	query := r.conn(ctx).Preload("Role").Where("user_id = ?", userID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
 
 	if projectID != nil {
 		if *projectID == "system" {
@@ -113,3 +145,52 @@ func (r *userRoleRepository) FindWithRole(ctx context.Context, userID string, pr
 
 	return userRoles, nil
 }
+
+// FindByRoleID finds all assignments of a role with preloaded user information
+func (r *userRoleRepository) FindByRoleID(ctx context.Context, roleID string) ([]model.UserRole, error) {
+	var userRoles []model.UserRole
+	if err := r.conn(ctx).
+		Preload("User").
+		Where("role_id = ?", roleID).
+		Find(&userRoles).Error; err != nil {
+		return nil, err
+	}
+	return userRoles, nil
+}
+
+// DeleteExpired removes expired role assignments
+func (r *userRoleRepository) DeleteExpired(ctx context.Context, t time.Time) ([]model.UserRole, error) {
+	var userRoles []model.UserRole
+	if err := r.conn(ctx).
+		Clauses(clause.Returning{}).
+		Where("expires_at IS NOT NULL AND expires_at <= ?", t).
+		Delete(&userRoles).Error; err != nil {
+		return nil, err
+	}
+	return userRoles, nil
+}
+
+// SaveBatch creates and renews role assignments in a single transaction
+func (r *userRoleRepository) SaveBatch(ctx context.Context, creates, renewals []model.UserRole) error {
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(creates) > 0 {
+			if err := tx.Create(&creates).Error; err != nil {
+				return err
+			}
+		}
+		for i := range renewals {
+			if err := tx.Model(&renewals[i]).Select("expires_at", "updated_at").Updates(renewals[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteByIDs deletes role assignments by ID
+func (r *userRoleRepository) DeleteByIDs(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.conn(ctx).Where("id IN ?", ids).Delete(&model.UserRole{}).Error
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB connects to the Postgres server in POSTGRES_TEST_DSN, or a local one, inside a new schema with the
+// migrations applied. The schema is dropped after the test, which is skipped when no server answers.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		dsn = "host=localhost port=5432 user=postgres password=postgres dbname=postgres sslmode=disable"
+	}
+	quiet := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	admin, err := gorm.Open(postgres.Open(dsn), quiet)
+	if err != nil {
+		t.Skip("Postgres not available, skipping test")
+	}
+	schema := fmt.Sprintf("repository_test_%d", time.Now().UnixNano())
+	require.NoError(t, admin.Exec(`CREATE SCHEMA "`+schema+`"`).Error)
+	t.Cleanup(func() {
+		admin.Exec(`DROP SCHEMA "` + schema + `" CASCADE`)
+		if sqlDB, err := admin.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schema), quiet)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	migrator, err := database.NewMigrator(db)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+func TestUserRoleRepository_LoadsRoles(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	repo := NewUserRoleRepository(db)
+
+	alice := &model.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	bob := &model.User{Username: "bob", Email: "bob@example.com", Password: "x"}
+	require.NoError(t, db.Create(alice).Error)
+	require.NoError(t, db.Create(bob).Error)
+	viewer := &model.Role{Code: "viewer", Name: "Viewer", Permissions: model.PermissionsToJSON([]string{"users.view"})}
+	editor := &model.Role{Code: "editor", Name: "Editor", Permissions: model.PermissionsToJSON([]string{"users.update", "roles.view"})}
+	require.NoError(t, db.Create(viewer).Error)
+	require.NoError(t, db.Create(editor).Error)
+
+	expired := time.Now().Add(-time.Hour)
+	for _, assignment := range []*model.UserRole{
+		{UserID: alice.ID, RoleID: viewer.ID},
+		{UserID: alice.ID, RoleID: editor.ID},
+		{UserID: bob.ID, RoleID: editor.ID, ExpiresAt: &expired},
+	} {
+		_, err := repo.Create(ctx, assignment)
+		require.NoError(t, err)
+	}
+
+	// A user with several roles gets every role loaded, so their permissions are the union of the roles'.
+	permissions := func(userRoles []model.UserRole) []string {
+		var codes []string
+		for _, userRole := range userRoles {
+			codes = append(codes, model.PermissionsFromJSON(userRole.Role.Permissions)...)
+		}
+		sort.Strings(codes)
+		return codes
+	}
+	want := []string{"roles.view", "users.update", "users.view"}
+
+	userRoles, err := repo.FindByUserID(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.Len(t, userRoles, 2)
+	assert.Equal(t, want, permissions(userRoles))
+
+	userRoles, err = repo.FindByUserIDs(ctx, []string{alice.ID, bob.ID})
+	require.NoError(t, err)
+	assert.Len(t, userRoles, 2, "bob's expired assignment is left out")
+	assert.Equal(t, want, permissions(userRoles))
+}
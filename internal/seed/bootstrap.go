@@ -0,0 +1,180 @@
+package seed
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/internal/shared/permission"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// minBootstrapPasswordLength matches the minimum length the API enforces on passwords.
+const minBootstrapPasswordLength = 8
+
+// BootstrapReq names the first super admin of a fresh deployment.
+type BootstrapReq struct {
+	Email    string
+	Password string
+	Name     string // defaults to the email
+}
+
+// BootstrapResult reports what a bootstrap run created; records that already existed are not counted.
+type BootstrapResult struct {
+	SuperAdmin  bool
+	Permissions int
+	Roles       int
+}
+
+// systemRoles are created in the system scope with the registry permissions each one selects.
+var systemRoles = []struct {
+	code        string
+	name        string
+	description string
+	includes    func(code string) bool
+}{
+	{constant.RoleAdmin, "Admin", "Full access to users, roles and projects", func(string) bool { return true }},
+	{constant.RoleEditor, "Editor", "View and update users, roles and projects", func(code string) bool {
+		return strings.HasSuffix(code, ".view") || strings.HasSuffix(code, ".update")
+	}},
+	{constant.RoleUser, "User", "Read-only access", func(code string) bool { return strings.HasSuffix(code, ".view") }},
+}
+
+// Bootstrapper prepares an empty database: the permission catalog, the default system roles and the
+// first super admin, so a new deployment can be administered through the API.
+type Bootstrapper struct {
+	logger         logger.ILogger
+	registry       permission.IRegistry
+	superAdminRepo repository.ISuperAdminRepository
+	permissionRepo repository.IPermissionRepository
+	roleRepo       repository.IRoleRepository
+}
+
+func NewBootstrapper(
+	logger logger.ILogger,
+	registry permission.IRegistry,
+	superAdminRepo repository.ISuperAdminRepository,
+	permissionRepo repository.IPermissionRepository,
+	roleRepo repository.IRoleRepository,
+) *Bootstrapper {
+	return &Bootstrapper{
+		logger:         logger,
+		registry:       registry,
+		superAdminRepo: superAdminRepo,
+		permissionRepo: permissionRepo,
+		roleRepo:       roleRepo,
+	}
+}
+
+// Run creates whatever is missing. A super admin that already exists keeps its password, and system roles
+// that already exist keep their permissions, so running it again on a live deployment changes nothing.
+func (s *Bootstrapper) Run(ctx context.Context, req BootstrapReq) (*BootstrapResult, error) {
+	email := strings.TrimSpace(req.Email)
+	if _, err := mail.ParseAddress(email); err != nil {
+		return nil, fmt.Errorf("invalid email %q", req.Email)
+	}
+	if len(req.Password) < minBootstrapPasswordLength {
+		return nil, fmt.Errorf("password must be at least %d characters", minBootstrapPasswordLength)
+	}
+	result := &BootstrapResult{}
+
+	if err := s.ensurePermissions(ctx, result); err != nil {
+		return nil, err
+	}
+	for _, def := range systemRoles {
+		if err := s.ensureSystemRole(ctx, def.code, def.name, def.description, def.includes, result); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.ensureSuperAdmin(ctx, email, req, result); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info(fmt.Sprintf("Bootstrap finished: superAdmin=%t, permissions=%d, roles=%d",
+		result.SuperAdmin, result.Permissions, result.Roles))
+	return result, nil
+}
+
+// ensurePermissions adds the registry permissions missing from the catalog, as the server does at startup.
+func (s *Bootstrapper) ensurePermissions(ctx context.Context, result *BootstrapResult) error {
+	var missing []model.Permission
+	for _, p := range s.registry.List() {
+		if p.Code == "" || s.permissionRepo.FindByCode(ctx, nil, p.Code) != nil {
+			continue
+		}
+		missing = append(missing, model.Permission{Code: p.Code, Name: p.Name})
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if err := s.permissionRepo.BulkCreate(ctx, missing); err != nil {
+		return fmt.Errorf("seed permissions: %w", err)
+	}
+	result.Permissions = len(missing)
+	return nil
+}
+
+func (s *Bootstrapper) ensureSystemRole(ctx context.Context, code, name, description string, includes func(string) bool, result *BootstrapResult) error {
+	existing, err := s.roleRepo.FindByCode(ctx, code)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if existing.ProjectID == nil || *existing.ProjectID != constant.SystemProjectID {
+			return fmt.Errorf("role code %q is already used by a project role", code)
+		}
+		return nil
+	}
+	var permissions []string
+	for _, p := range s.registry.List() {
+		if includes(p.Code) {
+			permissions = append(permissions, p.Code)
+		}
+	}
+	system := constant.SystemProjectID
+	if _, err := s.roleRepo.Create(ctx, &model.Role{
+		Code:        code,
+		Name:        name,
+		Description: description,
+		IsActive:    true,
+		ProjectID:   &system,
+		Permissions: model.PermissionsToJSON(permissions),
+	}); err != nil {
+		return fmt.Errorf("create system role %q: %w", code, err)
+	}
+	result.Roles++
+	return nil
+}
+
+func (s *Bootstrapper) ensureSuperAdmin(ctx context.Context, email string, req BootstrapReq, result *BootstrapResult) error {
+	existing, err := s.superAdminRepo.FindByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+	hashed, err := helper.HashPassword(req.Password)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = email
+	}
+	if _, err := s.superAdminRepo.Create(ctx, &model.SuperAdmin{
+		Name:     name,
+		Email:    email,
+		Password: hashed,
+		IsActive: true,
+	}); err != nil {
+		return fmt.Errorf("create super admin %q: %w", email, err)
+	}
+	result.SuperAdmin = true
+	return nil
+}
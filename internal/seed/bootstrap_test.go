@@ -0,0 +1,73 @@
+package seed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/internal/shared/permission"
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+)
+
+func TestBootstrapper_RunIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "permissions.json")
+	data := `[{"name":"User View","code":"users.view"},{"name":"User Update","code":"users.update"},{"name":"User Delete","code":"users.delete"}]`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := permission.NewRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admins := testutil.NewSuperAdminRepository()
+	permissions := testutil.NewPermissionRepository()
+	roles := testutil.NewRoleRepository()
+	bootstrapper := NewBootstrapper(testutil.NewLogger(), registry, admins, permissions, roles)
+
+	if _, err := bootstrapper.Run(ctx, BootstrapReq{Email: "not-an-email", Password: "password123"}); err == nil {
+		t.Error("Run() accepted an invalid email")
+	}
+	if _, err := bootstrapper.Run(ctx, BootstrapReq{Email: "root@example.com", Password: "short"}); err == nil {
+		t.Error("Run() accepted a short password")
+	}
+
+	first, err := bootstrapper.Run(ctx, BootstrapReq{Email: "root@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	if want := (BootstrapResult{SuperAdmin: true, Permissions: 3, Roles: 3}); *first != want {
+		t.Errorf("Run() = %+v, want %+v", *first, want)
+	}
+	admin, _ := admins.FindByEmail(ctx, "root@example.com")
+	if admin == nil || admin.Name != "root@example.com" || helper.ComparePassword(admin.Password, "password123") != nil {
+		t.Fatalf("super admin = %+v", admin)
+	}
+	for code, want := range map[string]int{constant.RoleAdmin: 3, constant.RoleEditor: 2, constant.RoleUser: 1} {
+		role, _ := roles.FindByCode(ctx, code)
+		if role == nil || role.ProjectID == nil || *role.ProjectID != constant.SystemProjectID {
+			t.Fatalf("system role %q = %+v", code, role)
+		}
+		if got := model.PermissionsFromJSON(role.Permissions); len(got) != want {
+			t.Errorf("role %q permissions = %v, want %d", code, got, want)
+		}
+	}
+
+	second, err := bootstrapper.Run(ctx, BootstrapReq{Email: "root@example.com", Password: "another-password"})
+	if err != nil {
+		t.Fatalf("second Run() err = %v", err)
+	}
+	if *second != (BootstrapResult{}) {
+		t.Errorf("second Run() = %+v, want nothing created", *second)
+	}
+	if admin, _ := admins.FindByEmail(ctx, "root@example.com"); helper.ComparePassword(admin.Password, "password123") != nil {
+		t.Error("second Run() changed the super admin's password")
+	}
+	if admins.Len() != 1 || permissions.Len() != 3 || roles.Len() != 3 {
+		t.Errorf("store sizes admins=%d permissions=%d roles=%d, want 1, 3 and 3", admins.Len(), permissions.Len(), roles.Len())
+	}
+}
@@ -0,0 +1,252 @@
+// Package seed populates a database with demo data for load testing and UI development.
+package seed
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// DemoPassword is the password set on every seeded demo user.
+const DemoPassword = "demo-password"
+
+// demoEmailDomain marks seeded users so they are easy to spot and clean up.
+const demoEmailDomain = "demo.dreon.local"
+
+// DemoSize controls how much data seed-demo creates.
+type DemoSize struct {
+	Projects            int // number of projects
+	Users               int // number of users, spread across projects
+	DocumentsPerProject int // documents per project, each with an owner/editor/viewer tuple graph
+}
+
+// DefaultDemoSize is a small data set suitable for UI development.
+var DefaultDemoSize = DemoSize{Projects: 3, Users: 20, DocumentsPerProject: 10}
+
+// DemoResult counts what a run created; records that already existed are not counted.
+type DemoResult struct {
+	Projects  int
+	Users     int
+	Roles     int
+	UserRoles int
+	Tuples    int
+}
+
+// demoRoles are created in every project as "<project code>-<suffix>".
+var demoRoles = []struct {
+	suffix      string
+	name        string
+	permissions []string
+}{
+	{"viewer", "Viewer", []string{"users.view", "roles.view", "projects.view"}},
+	{"editor", "Editor", []string{"users.view", "users.update", "roles.view", "projects.view", "projects.update"}},
+	{"admin", "Admin", []string{"users.view", "users.create", "users.update", "users.delete", "roles.view", "roles.create", "roles.update", "roles.delete", "roles.assign", "roles.revoke", "projects.view", "projects.update"}},
+}
+
+// GuardEnvironment refuses to seed production environments.
+func GuardEnvironment(env string) error {
+	switch strings.ToLower(strings.TrimSpace(env)) {
+	case "prod", "production":
+		return fmt.Errorf("refusing to seed demo data in %q environment", env)
+	}
+	return nil
+}
+
+type DemoSeeder struct {
+	logger       logger.ILogger
+	userRepo     repository.IUserRepository
+	projectRepo  repository.IProjectRepository
+	roleRepo     repository.IRoleRepository
+	userRoleRepo repository.IUserRoleRepository
+	tupleRepo    repository.IRelationTupleRepository
+}
+
+func NewDemoSeeder(
+	logger logger.ILogger,
+	userRepo repository.IUserRepository,
+	projectRepo repository.IProjectRepository,
+	roleRepo repository.IRoleRepository,
+	userRoleRepo repository.IUserRoleRepository,
+	tupleRepo repository.IRelationTupleRepository,
+) *DemoSeeder {
+	return &DemoSeeder{
+		logger:       logger,
+		userRepo:     userRepo,
+		projectRepo:  projectRepo,
+		roleRepo:     roleRepo,
+		userRoleRepo: userRoleRepo,
+		tupleRepo:    tupleRepo,
+	}
+}
+
+// Run creates the demo data set. Every record has a deterministic code/email/tuple,
+// so running it again only fills in what is missing.
+func (s *DemoSeeder) Run(ctx context.Context, size DemoSize) (*DemoResult, error) {
+	if size.Projects < 1 || size.Users < 1 || size.DocumentsPerProject < 0 {
+		return nil, fmt.Errorf("invalid demo size: %+v", size)
+	}
+	result := &DemoResult{}
+
+	projects := make([]*model.Project, 0, size.Projects)
+	for i := 1; i <= size.Projects; i++ {
+		p, err := s.ensureProject(ctx, i, result)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+
+	rolesByProject := make(map[string][]*model.Role, len(projects))
+	for _, p := range projects {
+		for _, def := range demoRoles {
+			r, err := s.ensureRole(ctx, p, def.suffix, def.name, def.permissions, result)
+			if err != nil {
+				return nil, err
+			}
+			rolesByProject[p.ID] = append(rolesByProject[p.ID], r)
+		}
+	}
+
+	hashed, err := helper.HashPassword(DemoPassword)
+	if err != nil {
+		return nil, err
+	}
+	usersByProject := make(map[string][]*model.User, len(projects))
+	for i := 1; i <= size.Users; i++ {
+		u, err := s.ensureUser(ctx, i, hashed, result)
+		if err != nil {
+			return nil, err
+		}
+		p := projects[(i-1)%len(projects)]
+		usersByProject[p.ID] = append(usersByProject[p.ID], u)
+
+		roles := rolesByProject[p.ID]
+		if err := s.ensureUserRole(ctx, u, roles[(i-1)%len(roles)], p, result); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range projects {
+		if err := s.ensureTupleGraph(ctx, p, usersByProject[p.ID], size.DocumentsPerProject, result); err != nil {
+			return nil, err
+		}
+	}
+
+	s.logger.Info(fmt.Sprintf("Demo data seeded: projects=%d, users=%d, roles=%d, userRoles=%d, tuples=%d",
+		result.Projects, result.Users, result.Roles, result.UserRoles, result.Tuples))
+	return result, nil
+}
+
+func (s *DemoSeeder) ensureProject(ctx context.Context, i int, result *DemoResult) (*model.Project, error) {
+	code := fmt.Sprintf("demo-project-%03d", i)
+	existing, err := s.projectRepo.FindByCode(ctx, code)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+	result.Projects++
+	return s.projectRepo.Create(ctx, &model.Project{
+		Code:        code,
+		Name:        fmt.Sprintf("Demo Project %d", i),
+		Description: "Seeded demo project",
+	})
+}
+
+func (s *DemoSeeder) ensureRole(ctx context.Context, p *model.Project, suffix, name string, permissions []string, result *DemoResult) (*model.Role, error) {
+	code := p.Code + "-" + suffix
+	existing, err := s.roleRepo.FindByCode(ctx, code)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+	result.Roles++
+	return s.roleRepo.Create(ctx, &model.Role{
+		Code:        code,
+		Name:        name,
+		Description: "Seeded demo role",
+		IsActive:    true,
+		ProjectID:   &p.ID,
+		Permissions: model.PermissionsToJSON(permissions),
+	})
+}
+
+func (s *DemoSeeder) ensureUser(ctx context.Context, i int, hashedPassword string, result *DemoResult) (*model.User, error) {
+	email := fmt.Sprintf("demo-user-%04d@%s", i, demoEmailDomain)
+	existing, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+	result.Users++
+	return s.userRepo.Create(ctx, &model.User{
+		Username: email,
+		Email:    email,
+		Password: hashedPassword,
+		Status:   constant.UserStatusActive,
+	})
+}
+
+func (s *DemoSeeder) ensureUserRole(ctx context.Context, u *model.User, r *model.Role, p *model.Project, result *DemoResult) error {
+	existing, err := s.userRoleRepo.FindByUserIDAndRoleID(ctx, u.ID, r.ID, &p.ID)
+	if err != nil || existing != nil {
+		return err
+	}
+	result.UserRoles++
+	_, err = s.userRoleRepo.Create(ctx, &model.UserRole{UserID: u.ID, RoleID: r.ID, ProjectID: &p.ID})
+	return err
+}
+
+// ensureTupleGraph builds, per project, a team group holding all project users and documents where
+// one user owns, the next edits, and the whole team views through a userset:
+//
+//	group:<project>-team#member@user:<id>
+//	document:<project>-doc-<n>#owner@user:<id>
+//	document:<project>-doc-<n>#editor@user:<id>
+//	document:<project>-doc-<n>#viewer@group:<project>-team#member
+func (s *DemoSeeder) ensureTupleGraph(ctx context.Context, p *model.Project, users []*model.User, documents int, result *DemoResult) error {
+	if len(users) == 0 {
+		return nil
+	}
+	team := p.Code + "-team"
+	for _, u := range users {
+		if err := s.ensureTuple(ctx, "group", team, "member", "user", u.ID, "", result); err != nil {
+			return err
+		}
+	}
+	for n := 1; n <= documents; n++ {
+		doc := fmt.Sprintf("%s-doc-%03d", p.Code, n)
+		owner := users[(n-1)%len(users)]
+		editor := users[n%len(users)]
+		if err := s.ensureTuple(ctx, "document", doc, "owner", "user", owner.ID, "", result); err != nil {
+			return err
+		}
+		if err := s.ensureTuple(ctx, "document", doc, "editor", "user", editor.ID, "", result); err != nil {
+			return err
+		}
+		if err := s.ensureTuple(ctx, "document", doc, "viewer", "group", team, "member", result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DemoSeeder) ensureTuple(ctx context.Context, namespace, objectID, relation, subjectNamespace, subjectObjectID, subjectRelation string, result *DemoResult) error {
+	existing, err := s.tupleRepo.FindByTuple(ctx, namespace, objectID, relation, subjectNamespace, subjectObjectID, subjectRelation)
+	if err != nil || existing != nil {
+		return err
+	}
+	result.Tuples++
+	_, err = s.tupleRepo.Create(ctx, &model.RelationTuple{
+		Namespace:        namespace,
+		ObjectID:         objectID,
+		Relation:         relation,
+		SubjectNamespace: subjectNamespace,
+		SubjectObjectID:  subjectObjectID,
+		SubjectRelation:  subjectRelation,
+		IsActive:         true,
+	})
+	return err
+}
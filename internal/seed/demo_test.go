@@ -0,0 +1,68 @@
+package seed
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+)
+
+func TestDemoSeeder_RunIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	roles := testutil.NewRoleRepository()
+	users := testutil.NewUserRepository()
+	projects := testutil.NewProjectRepository()
+	userRoles := testutil.NewUserRoleRepository(roles)
+	tuples := testutil.NewRelationTupleRepository()
+	seeder := NewDemoSeeder(testutil.NewLogger(), users, projects, roles, userRoles, tuples)
+
+	size := DemoSize{Projects: 2, Users: 5, DocumentsPerProject: 3}
+	first, err := seeder.Run(ctx, size)
+	if err != nil {
+		t.Fatalf("Run() err = %v", err)
+	}
+	// 5 team memberships + 2 projects * 3 documents * 3 tuples
+	want := DemoResult{Projects: 2, Users: 5, Roles: 6, UserRoles: 5, Tuples: 5 + 18}
+	if *first != want {
+		t.Errorf("Run() = %+v, want %+v", *first, want)
+	}
+
+	second, err := seeder.Run(ctx, size)
+	if err != nil {
+		t.Fatalf("second Run() err = %v", err)
+	}
+	if *second != (DemoResult{}) {
+		t.Errorf("second Run() = %+v, want nothing created", *second)
+	}
+	if users.Len() != 5 || tuples.Len() != 23 {
+		t.Errorf("store sizes users=%d tuples=%d, want 5 and 23", users.Len(), tuples.Len())
+	}
+
+	grown, err := seeder.Run(ctx, DemoSize{Projects: 2, Users: 6, DocumentsPerProject: 3})
+	if err != nil {
+		t.Fatalf("grown Run() err = %v", err)
+	}
+	if grown.Users != 1 || grown.UserRoles != 1 {
+		t.Errorf("grown Run() = %+v, want one new user and assignment", *grown)
+	}
+}
+
+func TestGuardEnvironment(t *testing.T) {
+	for _, env := range []string{"", "development", "staging"} {
+		if err := GuardEnvironment(env); err != nil {
+			t.Errorf("GuardEnvironment(%q) err = %v", env, err)
+		}
+	}
+	for _, env := range []string{"production", "PROD"} {
+		if err := GuardEnvironment(env); err == nil {
+			t.Errorf("GuardEnvironment(%q) expected error", env)
+		}
+	}
+}
+
+func TestDemoSeeder_InvalidSize(t *testing.T) {
+	seeder := NewDemoSeeder(testutil.NewLogger(), nil, nil, nil, nil, nil)
+	if _, err := seeder.Run(context.Background(), DemoSize{}); err == nil {
+		t.Error("Run() expected error for empty size")
+	}
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/accesspolicy"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
+	"gorm.io/datatypes"
+)
+
+// IAccessPolicySvc manages per-project network access policies and enforces them on authentication.
+type IAccessPolicySvc interface {
+	GetPolicy(ctx context.Context, projectID string) (*aggregate.AccessPolicyResp, error)
+	UpsertPolicy(ctx context.Context, projectID string, req aggregate.UpsertAccessPolicyReq) (*aggregate.AccessPolicyResp, error)
+	DeletePolicy(ctx context.Context, projectID string) error
+	ListDenials(ctx context.Context, projectID string, page, pageSize int) (*aggregate.PaginationResp[aggregate.AccessDenialResp], error)
+	// Enforce evaluates the project's policy for the request in ctx and records a denial when it fails.
+	// stage is one of constant.AccessStage*. Super admins are never restricted. A login without a project is
+	// refused while any project has an active policy, so leaving out projectId cannot skip one.
+	Enforce(ctx context.Context, projectID, stage string, subject jwt.Payload) error
+}
+
+type AccessPolicySvc struct {
+	logger      logger.ILogger
+	policyRepo  repository.IAccessPolicyRepository
+	denialRepo  repository.IAccessDenialRepository
+	projectRepo repository.IProjectRepository
+}
+
+func NewAccessPolicySvc(
+	logger logger.ILogger,
+	policyRepo repository.IAccessPolicyRepository,
+	denialRepo repository.IAccessDenialRepository,
+	projectRepo repository.IProjectRepository,
+) IAccessPolicySvc {
+	return &AccessPolicySvc{
+		logger:      logger,
+		policyRepo:  policyRepo,
+		denialRepo:  denialRepo,
+		projectRepo: projectRepo,
+	}
+}
+
+func (s *AccessPolicySvc) GetPolicy(ctx context.Context, projectID string) (*aggregate.AccessPolicyResp, error) {
+	policy := s.policyRepo.FindByProjectID(ctx, projectID)
+	if policy == nil {
+		return nil, errorx.Wrap(errorx.ErrPolicyNotFound, nil)
+	}
+	var resp aggregate.AccessPolicyResp
+	resp.FromModel(policy)
+	return &resp, nil
+}
+
+func (s *AccessPolicySvc) UpsertPolicy(ctx context.Context, projectID string, req aggregate.UpsertAccessPolicyReq) (*aggregate.AccessPolicyResp, error) {
+	if s.projectRepo.FindOneById(ctx, projectID) == nil {
+		return nil, errorx.Wrap(errorx.ErrProjectNotFound, nil)
+	}
+
+	rules := accesspolicy.Policy{
+		AllowedCIDRs:             req.AllowedCIDRs,
+		BlockedCountries:         req.BlockedCountries,
+		CorporateCIDRs:           req.CorporateCIDRs,
+		RequireMFAOutsideNetwork: req.RequireMFAOutsideNetwork,
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, errorx.New(errorx.ErrBadRequest, err.Error())
+	}
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	var actorID string
+	if p := payloadFromContext(ctx); p != nil {
+		actorID = p.UserID
+	}
+
+	policy := s.policyRepo.FindByProjectID(ctx, projectID)
+	if policy == nil {
+		policy = &model.AccessPolicy{ProjectID: projectID, IsActive: isActive}
+		policy.SetRules(rules)
+		policy.CreatedBy = actorID
+		policy.UpdatedBy = actorID
+		created, err := s.policyRepo.Create(ctx, policy)
+		if err != nil {
+			logger.WithContext(ctx, s.logger).Error("[AccessPolicySvc] failed to create policy", "projectID", projectID, "error", err)
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		policy = created
+	} else {
+		policy.SetRules(rules)
+		policy.IsActive = isActive
+		policy.UpdatedBy = actorID
+		if err := s.policyRepo.Update(ctx, policy.ID, *policy,
+			"allowed_cidrs", "blocked_countries", "corporate_cidrs", "require_mfa_outside_network", "is_active", "updated_by",
+		); err != nil {
+			logger.WithContext(ctx, s.logger).Error("[AccessPolicySvc] failed to update policy", "projectID", projectID, "error", err)
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+	}
+
+	var resp aggregate.AccessPolicyResp
+	resp.FromModel(policy)
+	return &resp, nil
+}
+
+func (s *AccessPolicySvc) DeletePolicy(ctx context.Context, projectID string) error {
+	policy := s.policyRepo.FindByProjectID(ctx, projectID)
+	if policy == nil {
+		return errorx.Wrap(errorx.ErrPolicyNotFound, nil)
+	}
+	if err := s.policyRepo.DeleteById(ctx, policy.ID); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[AccessPolicySvc] failed to delete policy", "projectID", projectID, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return nil
+}
+
+func (s *AccessPolicySvc) ListDenials(ctx context.Context, projectID string, page, pageSize int) (*aggregate.PaginationResp[aggregate.AccessDenialResp], error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+	offset := (page - 1) * pageSize
+
+	denials, total, err := s.denialRepo.ListByProjectID(ctx, projectID, offset, pageSize)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[AccessPolicySvc] failed to list denials", "projectID", projectID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	items := make([]aggregate.AccessDenialResp, len(denials))
+	for i := range denials {
+		items[i].FromModel(&denials[i])
+	}
+	return &aggregate.PaginationResp[aggregate.AccessDenialResp]{
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+		HasNext:  int64(offset+len(denials)) < total,
+		Items:    items,
+	}, nil
+}
+
+func (s *AccessPolicySvc) Enforce(ctx context.Context, projectID, stage string, subject jwt.Payload) error {
+	ctx, span := tracing.Start(ctx, "AccessPolicySvc.Enforce")
+	defer span.End()
+	if subject.IsSuperAdmin {
+		return nil
+	}
+	if projectID == "" {
+		return s.enforceWithoutProject(ctx, stage, subject)
+	}
+	policy := s.policyRepo.FindByProjectID(ctx, projectID)
+	if policy == nil || !policy.IsActive {
+		return nil
+	}
+
+	str := func(k constant.ContextKey) string { v, _ := ctx.Value(k).(string); return v }
+	req := accesspolicy.Request{
+		IP:          str(constant.ContextKeyClientIP),
+		Country:     str(constant.ContextKeyCountry),
+		MFAVerified: subject.MFA,
+	}
+	reason := policy.Rules().Evaluate(req)
+	if reason == accesspolicy.ReasonNone {
+		return nil
+	}
+
+	logger.WithContext(ctx, s.logger).Warn("[AccessPolicySvc] access denied", "projectID", projectID, "stage", stage, "reason", reason, "userID", subject.UserID, "ip", req.IP, "country", req.Country)
+	// Auditing is best-effort: a failed write must not turn a denial into an allow.
+	metaJSON, _ := json.Marshal(map[string]any{"request_id": logger.RequestIDFromContext(ctx)})
+	if _, err := s.denialRepo.Create(ctx, &model.AccessDenial{
+		ProjectID: projectID,
+		UserID:    subject.UserID,
+		Email:     subject.Email,
+		Stage:     stage,
+		Reason:    string(reason),
+		IP:        req.IP,
+		Country:   req.Country,
+		BaseModel: model.BaseModel{Metadata: datatypes.JSON(metaJSON)},
+	}); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[AccessPolicySvc] failed to record denial", "projectID", projectID, "error", err)
+	}
+	return errorx.New(errorx.ErrForbidden, fmt.Sprintf("access denied by project policy: %s", reason))
+}
+
+// enforceWithoutProject refuses logins that name no project while any policy is active. Later stages of a
+// session started that way are left alone; it was let in when no policy applied.
+func (s *AccessPolicySvc) enforceWithoutProject(ctx context.Context, stage string, subject jwt.Payload) error {
+	if stage != constant.AccessStageLogin {
+		return nil
+	}
+	active, err := s.policyRepo.HasActive(ctx)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[AccessPolicySvc] failed to look up policies", "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if !active {
+		return nil
+	}
+	logger.WithContext(ctx, s.logger).Warn("[AccessPolicySvc] access denied", "stage", stage, "reason", "PROJECT_REQUIRED", "userID", subject.UserID)
+	return errorx.New(errorx.ErrForbidden, "access denied: projectId is required while access policies are in force")
+}
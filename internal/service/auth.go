@@ -2,12 +2,17 @@ package service
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/hiamthach108/dreon-auth/config"
 	"github.com/hiamthach108/dreon-auth/internal/aggregate"
 	"github.com/hiamthach108/dreon-auth/internal/errorx"
@@ -15,9 +20,18 @@ import (
 	"github.com/hiamthach108/dreon-auth/internal/repository"
 	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
 	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/internal/shared/oidc"
+	"github.com/hiamthach108/dreon-auth/pkg/background"
 	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/captcha"
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
 	"github.com/hiamthach108/dreon-auth/pkg/jwt"
 	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/mailer"
+	"github.com/hiamthach108/dreon-auth/pkg/password"
+	"github.com/hiamthach108/dreon-auth/pkg/sms"
+	"github.com/hiamthach108/dreon-auth/pkg/statetoken"
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"gorm.io/datatypes"
@@ -29,61 +43,178 @@ type IAuthSvc interface {
 	RefreshToken(ctx context.Context, req aggregate.RefreshTokenReq) (*aggregate.TokenResp, error)
 	Logout(ctx context.Context, req aggregate.LogoutReq) error
 	ValidateToken(ctx context.Context, token string) (*jwt.Payload, error)
-	SessionFromState(ctx context.Context, req aggregate.SessionFromStateReq) (*aggregate.TokenResp, error)
+	// RevokeToken blacklists an access token owned by the caller (any token for super admins).
+	RevokeToken(ctx context.Context, req aggregate.RevokeTokenReq) error
+	GetSession(ctx context.Context, req aggregate.GetSessionReq) (*aggregate.SessionResp, error)
+	SessionFromState(ctx context.Context, req aggregate.SessionFromStateReq) (*aggregate.LoginResp, error)
 	ExchangeGoogleCode(ctx context.Context, code, state string) (redirectURL string, err error)
+	ExchangeOIDCCode(ctx context.Context, provider, code, state string) (redirectURL string, err error)
+	EndSession(ctx context.Context, req aggregate.EndSessionReq) (redirectURL string, err error)
+	// EndUserSessions ends every active session of a user, e.g. when the account is deactivated.
+	EndUserSessions(ctx context.Context, userID string) error
+	// EndDeviceSessions ends every active session of a user issued to one of their devices.
+	EndDeviceSessions(ctx context.Context, userID, deviceID string) error
+	// Impersonate issues the calling super admin a short-lived token acting as the user.
+	Impersonate(ctx context.Context, userID string, req aggregate.ImpersonateReq) (*aggregate.ImpersonationResp, error)
+	EnableTOTP(ctx context.Context, req aggregate.EnableTOTPReq) (*aggregate.EnableTOTPResp, error)
+	VerifyTOTP(ctx context.Context, req aggregate.VerifyTOTPReq) (*aggregate.VerifyTOTPResp, error)
+	DisableTOTP(ctx context.Context, req aggregate.DisableTOTPReq) error
+	VerifyMagicLink(ctx context.Context, req aggregate.VerifyMagicLinkReq) (*aggregate.LoginResp, error)
+	VerifyPhoneOTP(ctx context.Context, req aggregate.VerifyPhoneOTPReq) (*aggregate.LoginResp, error)
+
+	// OIDC provider: registered clients sign users in through the authorization code flow.
+	OIDCDiscovery(ctx context.Context) (*aggregate.OIDCDiscoveryResp, error)
+	OIDCAuthorize(ctx context.Context, req aggregate.OIDCAuthorizeReq) (redirectURL string, err error)
+	CompleteOIDCAuthorize(ctx context.Context, req aggregate.CompleteOIDCAuthorizeReq) (*aggregate.CompleteOIDCAuthorizeResp, error)
+	OIDCToken(ctx context.Context, req aggregate.OIDCTokenReq) (*aggregate.OIDCTokenResp, error)
+	OIDCUserInfo(ctx context.Context) (*aggregate.OIDCUserInfoResp, error)
+
+	// Device authorization grant (RFC 8628): devices without a browser sign in with a code the user enters elsewhere.
+	StartDeviceAuthorization(ctx context.Context, req aggregate.DeviceCodeReq) (*aggregate.DeviceCodeResp, error)
+	GetDeviceAuthorization(ctx context.Context, req aggregate.GetDeviceAuthorizationReq) (*aggregate.DeviceAuthorizationResp, error)
+	CompleteDeviceAuthorization(ctx context.Context, req aggregate.CompleteDeviceAuthorizationReq) error
+	DeviceToken(ctx context.Context, req aggregate.OIDCTokenReq) (*aggregate.OIDCTokenResp, error)
 }
 
 type AuthSvc struct {
 	logger             logger.ILogger
 	jwtTokenManager    jwt.IJwtTokenManager
-	cfg                config.AppConfig
+	cfg                config.INotifier
 	userRepo           repository.IUserRepository
 	sessionRepo        repository.ISessionRepository
 	projectRepo        repository.IProjectRepository
 	superAdminRepo     repository.ISuperAdminRepository
+	credentialRepo     repository.IUserCredentialRepository
+	deviceRepo         repository.IUserDeviceRepository
+	impersonationRepo  repository.IImpersonationRepository
+	txManager          repository.ITxManager
+	roleSvc            IRoleSvc
+	accessPolicySvc    IAccessPolicySvc
+	trustedDeviceSvc   ITrustedDeviceSvc
 	cache              cache.ICache
-	googleOAuth2Config *oauth2.Config
+	stateSealer        statetoken.ISealer
+	oidcClients        *oidc.ClientRegistry
+	oauthProviders     IOAuthProviderRegistry
+	logoutNotifier     ILogoutNotifier
+	mailer             mailer.IMailer
+	sms                sms.ISender
+	templateSvc        INotificationTemplateSvc
+	hasher             password.IPasswordHasher
+	passwordPolicySvc  IPasswordPolicySvc
+	tokenRevocationSvc ITokenRevocationSvc
+	projectUsageSvc    IProjectUsageSvc
+	identitySvc        IUserIdentitySvc
+	rateLimitSvc       IRateLimitSvc
+	captcha            captcha.IVerifier
+	events             eventbus.IPublisher
+	ipFilterSvc        IIPFilterSvc
+	claimsEnrichers    ClaimsEnrichers
+	workers            *background.Group
+
+	// dummyHash is compared against for logins of unknown accounts; see loginFailed.
+	dummyHash          string
+	dummyPepperVersion int
 }
 
 func NewAuthSvc(
 	logger logger.ILogger,
 	jwtTokenManager jwt.IJwtTokenManager,
-	cfg *config.AppConfig,
+	cfg config.INotifier,
 	cache cache.ICache,
+	stateSealer statetoken.ISealer,
 	userRepo repository.IUserRepository,
 	sessionRepo repository.ISessionRepository,
 	projectRepo repository.IProjectRepository,
 	superAdminRepo repository.ISuperAdminRepository,
-) IAuthSvc {
+	credentialRepo repository.IUserCredentialRepository,
+	deviceRepo repository.IUserDeviceRepository,
+	impersonationRepo repository.IImpersonationRepository,
+	txManager repository.ITxManager,
+	roleSvc IRoleSvc,
+	accessPolicySvc IAccessPolicySvc,
+	trustedDeviceSvc ITrustedDeviceSvc,
+	oidcClients *oidc.ClientRegistry,
+	oauthProviders IOAuthProviderRegistry,
+	logoutNotifier ILogoutNotifier,
+	mailer mailer.IMailer,
+	sms sms.ISender,
+	templateSvc INotificationTemplateSvc,
+	hasher password.IPasswordHasher,
+	passwordPolicySvc IPasswordPolicySvc,
+	tokenRevocationSvc ITokenRevocationSvc,
+	projectUsageSvc IProjectUsageSvc,
+	identitySvc IUserIdentitySvc,
+	rateLimitSvc IRateLimitSvc,
+	captcha captcha.IVerifier,
+	events eventbus.IPublisher,
+	ipFilterSvc IIPFilterSvc,
+	claimsEnrichers ClaimsEnrichers,
+	workers *background.Group,
+) (IAuthSvc, error) {
+	// The dummy hash is made up front: making it on the first unknown-email login would make that login slow.
+	dummyHash, dummyPepperVersion, err := hasher.Hash(uuid.NewString())
+	if err != nil {
+		return nil, fmt.Errorf("auth: hash dummy password: %w", err)
+	}
 	return &AuthSvc{
-		logger:          logger,
-		jwtTokenManager: jwtTokenManager,
-		cfg:             *cfg,
-		userRepo:        userRepo,
-		sessionRepo:     sessionRepo,
-		projectRepo:     projectRepo,
-		superAdminRepo:  superAdminRepo,
-		cache:           cache,
-		googleOAuth2Config: &oauth2.Config{
-			ClientID:     cfg.Google.ClientID,
-			ClientSecret: cfg.Google.ClientSecret,
-			RedirectURL:  cfg.Google.RedirectURL,
-			Scopes:       []string{"openid", "email", "profile"},
-			Endpoint:     google.Endpoint,
-		},
+		logger:             logger,
+		jwtTokenManager:    jwtTokenManager,
+		cfg:                cfg,
+		userRepo:           userRepo,
+		sessionRepo:        sessionRepo,
+		projectRepo:        projectRepo,
+		superAdminRepo:     superAdminRepo,
+		credentialRepo:     credentialRepo,
+		deviceRepo:         deviceRepo,
+		impersonationRepo:  impersonationRepo,
+		txManager:          txManager,
+		roleSvc:            roleSvc,
+		accessPolicySvc:    accessPolicySvc,
+		trustedDeviceSvc:   trustedDeviceSvc,
+		cache:              cache,
+		stateSealer:        stateSealer,
+		oidcClients:        oidcClients,
+		oauthProviders:     oauthProviders,
+		logoutNotifier:     logoutNotifier,
+		mailer:             mailer,
+		sms:                sms,
+		templateSvc:        templateSvc,
+		hasher:             hasher,
+		passwordPolicySvc:  passwordPolicySvc,
+		tokenRevocationSvc: tokenRevocationSvc,
+		projectUsageSvc:    projectUsageSvc,
+		identitySvc:        identitySvc,
+		rateLimitSvc:       rateLimitSvc,
+		captcha:            captcha,
+		events:             events,
+		ipFilterSvc:        ipFilterSvc,
+		claimsEnrichers:    claimsEnrichers,
+		workers:            workers,
+		dummyHash:          dummyHash,
+		dummyPepperVersion: dummyPepperVersion,
+	}, nil
+}
+
+// googleOAuth2Config is built from the current configuration, so changed Google credentials apply to the
+// next sign-in.
+func (s *AuthSvc) googleOAuth2Config() *oauth2.Config {
+	cfg := s.cfg.Current()
+	return &oauth2.Config{
+		ClientID:     cfg.Google.ClientID,
+		ClientSecret: cfg.Google.ClientSecret,
+		RedirectURL:  cfg.Google.RedirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		Endpoint:     google.Endpoint,
 	}
 }
 
 func (s *AuthSvc) Login(ctx context.Context, req aggregate.LoginReq) (*aggregate.LoginResp, error) {
+	ctx, span := tracing.Start(ctx, "AuthSvc.Login")
+	defer span.End()
+	ctx = withLoginMethod(ctx, req.AuthType)
 	switch req.AuthType {
 	case constant.UserAuthTypeEmail:
-		tokenResp, err := s.loginWithEmail(ctx, req)
-		if err != nil {
-			return nil, err
-		}
-		return &aggregate.LoginResp{
-			TokenResp: *tokenResp,
-		}, nil
+		return s.loginWithEmail(ctx, req)
 	case constant.UserAuthTypeSuperAdmin:
 		tokenResp, err := s.loginWithSuperAdmin(ctx, req)
 		if err != nil {
@@ -98,80 +229,343 @@ func (s *AuthSvc) Login(ctx context.Context, req aggregate.LoginReq) (*aggregate
 		return s.loginWithFacebook(ctx, req)
 	case constant.UserAuthTypeApple:
 		return s.loginWithApple(ctx, req)
+	case constant.UserAuthTypeOIDC:
+		return s.loginWithOIDC(ctx, req)
+	case constant.UserAuthTypeMagicLink:
+		return s.loginWithMagicLink(ctx, req)
+	case constant.UserAuthTypePhoneOTP:
+		return s.loginWithPhoneOTP(ctx, req)
 	default:
 		return nil, errorx.Wrap(errorx.ErrInvalidAuthType, fmt.Errorf("invalid auth type: %s", req.AuthType))
 	}
 }
 
 func (s *AuthSvc) Register(ctx context.Context, req aggregate.RegisterReq) (*aggregate.TokenResp, error) {
+	ctx, span := tracing.Start(ctx, "AuthSvc.Register")
+	defer span.End()
+	ctx = withLoginMethod(ctx, constant.UserAuthTypeEmail)
+	if err := s.checkCaptcha(ctx, constant.RateLimitRouteRegister, req.Email, req.CaptchaToken); err != nil {
+		return nil, err
+	}
 	existing, err := s.userRepo.FindByEmail(ctx, req.Email)
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
-	if existing != nil {
+	protect := s.cfg.Current().AccountEnumeration.Protection
+	if protect && s.cfg.Current().MagicLink.URL == "" {
+		return nil, errorx.New(errorx.ErrBadRequest, "registration with account enumeration protection needs MAGIC_LINK_URL")
+	}
+	if existing != nil && !protect {
+		s.recordFailure(ctx, constant.RateLimitRouteRegister, req.Email)
 		return nil, errorx.New(errorx.ErrUserConflict, errorx.GetErrorMessage(int(errorx.ErrUserConflict)))
 	}
-	hashed, err := helper.HashPassword(req.Password)
+	warnings, err := s.passwordPolicySvc.CheckNew(ctx, req.ProjectID, req.Password)
 	if err != nil {
-		return nil, errorx.Wrap(errorx.ErrInternal, err)
+		return nil, err
 	}
-
-	user, err := s.userRepo.Create(ctx, &model.User{
-		Username: req.Email,
-		Email:    req.Email,
-		Password: hashed,
-		Status:   constant.UserStatusActive,
-	})
-
+	hashed, pepperVersion, err := s.hasher.Hash(req.Password)
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
+	// With enumeration protection a taken email gets the response a new account gets, after the same password
+	// checks, and its owner is emailed a sign-in link instead.
+	if existing != nil {
+		if existing.IsActive() {
+			s.sendSignInLinkInBackground(ctx, existing, req.ProjectID, "Someone tried to register with this email, which already has an account. Use this link to sign in.")
+		}
+		return &aggregate.TokenResp{Warnings: warnings, SignInLinkSent: true}, nil
+	}
 
-	return s.generateTokens(ctx, jwt.Payload{
-		UserID:       user.ID,
-		IsSuperAdmin: false,
-		Email:        user.Email,
+	// The user and their first session are created together, so a failed session does not leave an
+	// account behind that blocks registering again with the same email.
+	var user *model.User
+	var tokens *aggregate.TokenResp
+	err = withTx(ctx, s.txManager, func(ctx context.Context) error {
+		var err error
+		user, err = s.userRepo.Create(ctx, &model.User{
+			Username:              req.Email,
+			Email:                 req.Email,
+			Password:              hashed,
+			PasswordPepperVersion: pepperVersion,
+			Status:                constant.UserStatusActive,
+		})
+		if err != nil {
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+		// With enumeration protection the account signs in from the emailed link, so it gets no session yet.
+		if protect {
+			return nil
+		}
+		tokens, err = s.generateTokens(ctx, jwt.Payload{
+			UserID:       user.ID,
+			IsSuperAdmin: false,
+			Email:        user.Email,
+		})
+		return err
 	})
+	if err != nil {
+		return nil, err
+	}
+	s.publishUserRegistered(ctx, user)
+	if protect {
+		s.sendSignInLinkInBackground(ctx, user, req.ProjectID, "Your account is ready. Use this link to sign in.")
+		return &aggregate.TokenResp{Warnings: warnings, SignInLinkSent: true}, nil
+	}
+	tokens.Warnings = warnings
+	return tokens, nil
 }
 
 func (s *AuthSvc) RefreshToken(ctx context.Context, req aggregate.RefreshTokenReq) (*aggregate.TokenResp, error) {
-	session := s.sessionRepo.FindByRefreshToken(ctx, req.RefreshToken)
+	ctx, span := tracing.Start(ctx, "AuthSvc.RefreshToken")
+	defer span.End()
+	session := s.sessionRepo.FindByRefreshTokenHash(ctx, helper.HashRefreshToken(req.RefreshToken))
 	if session == nil {
 		return nil, errorx.New(errorx.ErrInvalidRefreshToken, errorx.GetErrorMessage(int(errorx.ErrInvalidRefreshToken)))
 	}
 	if session.ExpiresAt.Before(time.Now()) || !session.IsActive {
 		return nil, errorx.New(errorx.ErrRefreshTokenExpired, errorx.GetErrorMessage(int(errorx.ErrRefreshTokenExpired)))
 	}
-	return s.generateTokens(ctx, jwt.Payload{
+	payload := jwt.Payload{
 		UserID:       session.UserID,
 		IsSuperAdmin: session.IsSuperAdmin,
 		Email:        session.Email,
-	})
+		ProjectID:    session.ProjectID,
+		MFA:          session.MFAVerified,
+	}
+	if err := s.accessPolicySvc.Enforce(ctx, payload.ProjectID, constant.AccessStageRefresh, payload); err != nil {
+		return nil, err
+	}
+	// The refreshed session stays on the device of the one it replaces.
+	s.touchDevice(ctx, session.DeviceID)
+	return s.issueTokens(ctx, payload, session.DeviceID)
 }
 
 func (s *AuthSvc) Logout(ctx context.Context, req aggregate.LogoutReq) error {
+	ctx, span := tracing.Start(ctx, "AuthSvc.Logout")
+	defer span.End()
 	// remove refresh token from session table
-	session := s.sessionRepo.FindByRefreshToken(ctx, req.RefreshToken)
+	session := s.sessionRepo.FindByRefreshTokenHash(ctx, helper.HashRefreshToken(req.RefreshToken))
 	if session == nil {
 		return errorx.New(errorx.ErrInvalidRefreshToken, errorx.GetErrorMessage(int(errorx.ErrInvalidRefreshToken)))
 	}
+	return s.endSession(ctx, *session)
+}
+
+// EndSession implements OIDC RP-initiated logout: the id_token_hint (an access token, or an ID token from
+// the OIDC provider endpoints) identifies the session to end (or all of the user's sessions when the token
+// carries no session ID), registered clients are notified over the back channel, and the caller is sent
+// to post_logout_redirect_uri when it is registered.
+func (s *AuthSvc) EndSession(ctx context.Context, req aggregate.EndSessionReq) (string, error) {
+	ctx, span := tracing.Start(ctx, "AuthSvc.EndSession")
+	defer span.End()
+	if req.IDTokenHint == "" {
+		return "", errorx.New(errorx.ErrBadRequest, "id_token_hint is required")
+	}
+	if req.PostLogoutRedirectURI != "" && !s.oidcClients.IsPostLogoutRedirectAllowed(req.ClientID, req.PostLogoutRedirectURI) {
+		return "", errorx.New(errorx.ErrBadRequest, "post_logout_redirect_uri is not registered")
+	}
+	payload, err := s.jwtTokenManager.Verify(ctx, req.IDTokenHint)
+	if err != nil {
+		if payload, err = s.idTokenHintPayload(ctx, req.IDTokenHint); err != nil {
+			return "", errorx.Wrap(errorx.ErrUnauthorized, err)
+		}
+	}
+
+	var sessions []model.Session
+	if payload.SessionID != "" {
+		if session := s.sessionRepo.FindOneById(ctx, payload.SessionID); session != nil && session.UserID == payload.UserID && session.IsActive {
+			sessions = append(sessions, *session)
+		}
+	} else {
+		sessions, err = s.sessionRepo.FindActiveByUserID(ctx, payload.UserID)
+		if err != nil {
+			return "", errorx.Wrap(errorx.ErrInternal, err)
+		}
+	}
+	for _, session := range sessions {
+		if err := s.endSession(ctx, session); err != nil {
+			return "", errorx.Wrap(errorx.ErrInternal, err)
+		}
+	}
+
+	if req.PostLogoutRedirectURI == "" {
+		return "", nil
+	}
+	u, err := url.Parse(req.PostLogoutRedirectURI)
+	if err != nil {
+		return "", errorx.Wrap(errorx.ErrBadRequest, err)
+	}
+	if req.State != "" {
+		q := u.Query()
+		q.Set("state", req.State)
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
+
+func (s *AuthSvc) EndUserSessions(ctx context.Context, userID string) error {
+	sessions, err := s.sessionRepo.FindActiveByUserID(ctx, userID)
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	for _, session := range sessions {
+		if err := s.endSession(ctx, session); err != nil {
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+	}
+	return nil
+}
+
+// endSession deactivates a session and propagates the logout to registered clients.
+func (s *AuthSvc) endSession(ctx context.Context, session model.Session) error {
 	session.IsActive = false
-	return s.sessionRepo.Update(ctx, session.ID, *session, "is_active")
+	if err := s.sessionRepo.Update(ctx, session.ID, session, "is_active"); err != nil {
+		return err
+	}
+	s.tokenRevocationSvc.SessionEnded(ctx, session.ID)
+	s.logoutNotifier.NotifySessionEnded(session)
+	publishEvent(ctx, s.events, s.logger, constant.EventSessionEnded, session.UserID, SessionEndedEvent{
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		ProjectID: session.ProjectID,
+	})
+	return nil
+}
+
+// publishUserRegistered emits user.registered for an account created by sign-up or first external login.
+func (s *AuthSvc) publishUserRegistered(ctx context.Context, user *model.User) {
+	var dto aggregate.UserDto
+	dto.FromModel(user)
+	publishEvent(ctx, s.events, s.logger, constant.EventUserRegistered, user.ID, dto)
 }
 
 func (s *AuthSvc) ValidateToken(ctx context.Context, token string) (*jwt.Payload, error) {
+	ctx, span := tracing.Start(ctx, "AuthSvc.ValidateToken")
+	defer span.End()
 	payload, err := s.jwtTokenManager.Verify(ctx, token)
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrUnauthorized, err)
 	}
+	if err := s.projectUsageSvc.Record(ctx, payload.ProjectID, constant.ProjectUsageTokenValidation); err != nil {
+		return nil, err
+	}
+	if payload.TokenID != "" {
+		revoked, err := s.tokenRevocationSvc.IsRevoked(ctx, payload.TokenID)
+		if err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		if revoked {
+			return nil, errorx.New(errorx.ErrUnauthorized, "token has been revoked")
+		}
+	}
+	if err := s.checkSessionActive(ctx, *payload); err != nil {
+		return nil, err
+	}
+	// Re-evaluate the network policy: the token may be presented from somewhere it was not issued.
+	if err := s.accessPolicySvc.Enforce(ctx, payload.ProjectID, constant.AccessStageToken, *payload); err != nil {
+		return nil, err
+	}
 	return payload, nil
 }
 
+// checkSessionActive rejects a token whose login session was ended, e.g. by logout or because the
+// account was blocked or deactivated. Tokens not tied to a session are not checked.
+func (s *AuthSvc) checkSessionActive(ctx context.Context, payload jwt.Payload) error {
+	if payload.SessionID == "" {
+		return nil
+	}
+	session := s.sessionRepo.FindOneById(ctx, payload.SessionID)
+	if session == nil || !session.IsActive {
+		return errorx.New(errorx.ErrUnauthorized, "session has ended")
+	}
+	return nil
+}
+
+func (s *AuthSvc) RevokeToken(ctx context.Context, req aggregate.RevokeTokenReq) error {
+	caller := payloadFromContext(ctx)
+	if caller == nil {
+		return errorx.New(errorx.ErrUnauthorized, "missing payload")
+	}
+	target := caller
+	if req.Token != "" {
+		payload, err := s.jwtTokenManager.Verify(ctx, req.Token)
+		if err != nil {
+			return errorx.New(errorx.ErrBadRequest, "token is invalid or already expired")
+		}
+		if payload.UserID != caller.UserID && !caller.IsSuperAdmin {
+			return errorx.New(errorx.ErrForbidden, "cannot revoke another user's token")
+		}
+		target = payload
+	}
+	return s.tokenRevocationSvc.Revoke(ctx, *target)
+}
+
+// GetSession returns the caller's JWT payload, optionally enriched with resolved roles and permissions
+// so API gateways can authorize a request with a single call.
+func (s *AuthSvc) GetSession(ctx context.Context, req aggregate.GetSessionReq) (*aggregate.SessionResp, error) {
+	payload := payloadFromContext(ctx)
+	if payload == nil {
+		return nil, errorx.New(errorx.ErrUnauthorized, "missing payload")
+	}
+	if err := s.projectUsageSvc.Record(ctx, payload.ProjectID, constant.ProjectUsageTokenValidation); err != nil {
+		return nil, err
+	}
+	if err := s.checkSessionActive(ctx, *payload); err != nil {
+		return nil, err
+	}
+	resp := &aggregate.SessionResp{Payload: *payload}
+	if payload.IsSuperAdmin {
+		return resp, nil
+	}
+
+	if req.IncludeRoles {
+		roles, err := s.roleSvc.GetUserRoles(ctx, aggregate.GetUserRolesReq{
+			UserID:    payload.UserID,
+			ProjectID: req.ProjectID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp.Roles = roles
+	}
+
+	if req.IncludePermissions {
+		permissions, err := s.roleSvc.GetUserPermissions(ctx, payload.UserID)
+		if err != nil {
+			return nil, err
+		}
+		prefix := ""
+		if req.ProjectID != nil {
+			prefix = *req.ProjectID + "/"
+		}
+		resp.Permissions = make([]string, 0, len(permissions))
+		for key, granted := range permissions {
+			if granted && strings.HasPrefix(key, prefix) {
+				resp.Permissions = append(resp.Permissions, key)
+			}
+		}
+		sort.Strings(resp.Permissions)
+	}
+
+	return resp, nil
+}
+
 func (s *AuthSvc) ExchangeGoogleCode(ctx context.Context, code, state string) (redirectURL string, err error) {
 	if code == "" || state == "" {
 		return "", errorx.New(errorx.ErrBadRequest, "code and state are required")
 	}
-	token, err := s.googleOAuth2Config.Exchange(ctx, code)
+	var loginState aggregate.OAuthLoginState
+	if err := s.stateSealer.Open(state, &loginState); err != nil {
+		return "", errorx.Wrap(errorx.ErrInvalidRefreshState, err)
+	}
+	if loginState.RedirectURL == "" {
+		return "", errorx.New(errorx.ErrBadRequest, "missing redirect_uri; pass redirectUrl in login request")
+	}
+	// Checked again in case the project's redirect URLs changed while the user was at the provider.
+	if err := s.checkRedirectURL(ctx, loginState.ProjectID, loginState.RedirectURL); err != nil {
+		return "", err
+	}
+	token, err := s.googleOAuth2Config().Exchange(ctx, code)
 	if err != nil {
 		return "", errorx.Wrap(errorx.ErrUnauthorized, fmt.Errorf("google token exchange: %w", err))
 	}
@@ -179,115 +573,244 @@ func (s *AuthSvc) ExchangeGoogleCode(ctx context.Context, code, state string) (r
 	if err != nil {
 		return "", errorx.Wrap(errorx.ErrInternal, err)
 	}
-	cached := aggregate.CachedOAuthState{
+	return sealRefreshStateRedirect(s.stateSealer, loginState.RedirectURL, aggregate.OAuthRefreshState{
 		AuthType: constant.UserAuthTypeGoogle,
 		UserData: aggregate.OAuthUserData{
 			Email:      userInfo.Email,
 			Name:       userInfo.Name,
 			ProviderID: userInfo.ID,
+			Groups:     googleGroupClaims(userInfo),
 		},
+		ProjectID:     loginState.ProjectID,
+		CodeChallenge: loginState.CodeChallenge,
+		ClientBinding: loginState.ClientBinding,
+	})
+}
+
+func (s *AuthSvc) ExchangeOIDCCode(ctx context.Context, provider, code, state string) (redirectURL string, err error) {
+	if code == "" || state == "" {
+		return "", errorx.New(errorx.ErrBadRequest, "code and state are required")
 	}
-	stateKey := s.buildRefreshStateCacheKey(ctx, state)
-	ttl := constant.RefreshStateTTL
-	if err := s.cache.Set(stateKey, cached, &ttl); err != nil {
+	var loginState aggregate.OAuthLoginState
+	if err := s.stateSealer.Open(state, &loginState); err != nil {
+		return "", errorx.Wrap(errorx.ErrInvalidRefreshState, err)
+	}
+	// The state must have been issued for this provider, or one issuer's code could be replayed at another's callback.
+	if loginState.AuthType != constant.UserAuthTypeOIDC || loginState.Provider != provider {
+		return "", errorx.New(errorx.ErrInvalidRefreshState, "state was not issued for this provider")
+	}
+	if loginState.RedirectURL == "" {
+		return "", errorx.New(errorx.ErrBadRequest, "missing redirect_uri; pass redirectUrl in login request")
+	}
+	if err := s.checkRedirectURL(ctx, loginState.ProjectID, loginState.RedirectURL); err != nil {
+		return "", err
+	}
+	p, err := s.oauthProvider(provider)
+	if err != nil {
+		return "", err
+	}
+	userData, err := p.Exchange(ctx, code)
+	if err != nil {
+		return "", errorx.Wrap(errorx.ErrUnauthorized, err)
+	}
+	return sealRefreshStateRedirect(s.stateSealer, loginState.RedirectURL, aggregate.OAuthRefreshState{
+		AuthType:      constant.UserAuthTypeOIDC,
+		Provider:      provider,
+		UserData:      *userData,
+		ProjectID:     loginState.ProjectID,
+		CodeChallenge: loginState.CodeChallenge,
+		ClientBinding: loginState.ClientBinding,
+	})
+}
+
+// sealRefreshStateRedirect seals the provider's user data and appends it to the frontend redirect URL.
+// Every external login (OAuth, OIDC, SAML) ends here and continues at SessionFromState.
+func sealRefreshStateRedirect(sealer statetoken.ISealer, redirectURL string, refreshState aggregate.OAuthRefreshState) (string, error) {
+	stateID, err := helper.GenerateRefreshToken()
+	if err != nil {
 		return "", errorx.Wrap(errorx.ErrInternal, err)
 	}
-	redirectKey := s.buildOAuthRedirectCacheKey(ctx, state)
-	var redirectPayload struct {
-		URL string `json:"url"`
+	refreshState.ID = stateID
+	sealed, err := sealer.Seal(refreshState, constant.RefreshStateTTL)
+	if err != nil {
+		return "", errorx.Wrap(errorx.ErrInternal, err)
 	}
-	if getErr := s.cache.Get(redirectKey, &redirectPayload); getErr == nil {
-		_ = s.cache.Delete(redirectKey)
-		frontendRedirect := redirectPayload.URL
-		u, err := url.Parse(frontendRedirect)
-		if err != nil {
-			redirectURL = frontendRedirect + "?refreshState=" + url.QueryEscape(state)
-		} else {
-			q := u.Query()
-			q.Set("refreshState", state)
-			u.RawQuery = q.Encode()
-			redirectURL = u.String()
-		}
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		return redirectURL + "?refreshState=" + url.QueryEscape(sealed), nil
 	}
-	if redirectURL == "" {
-		return "", errorx.New(errorx.ErrBadRequest, "missing redirect_uri; pass redirectUrl in login request")
+	q := u.Query()
+	q.Set("refreshState", sealed)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// clientBinding returns the HMAC that ties an external login's state to the requesting client under mode
+// (OAUTH_STATE_BINDING), or "" when states are not bound. A login that sends a PKCE codeChallenge is bound by
+// it instead, which also works when the login is started and finished by different user agents.
+func clientBinding(ctx context.Context, sealer statetoken.ISealer, mode string) string {
+	str := func(k constant.ContextKey) string { v := ctx.Value(k); s, _ := v.(string); return s }
+	switch mode {
+	case constant.OAuthStateBindingNone:
+		return ""
+	case constant.OAuthStateBindingIP:
+		return sealer.Bind(constant.OAuthStateBindingIP, str(constant.ContextKeyClientIP), str(constant.ContextKeyUserAgent))
+	default:
+		return sealer.Bind(constant.OAuthStateBindingUserAgent, str(constant.ContextKeyUserAgent))
 	}
-	return redirectURL, nil
 }
 
-func (s *AuthSvc) SessionFromState(ctx context.Context, req aggregate.SessionFromStateReq) (*aggregate.TokenResp, error) {
-	key := s.buildRefreshStateCacheKey(ctx, req.RefreshState)
-	var cached aggregate.CachedOAuthState
-	if err := s.cache.Get(key, &cached); err != nil {
-		if err == cache.ErrCacheNil {
-			return nil, errorx.New(errorx.ErrInvalidRefreshState, errorx.GetErrorMessage(int(errorx.ErrInvalidRefreshState)))
-		}
-		return nil, errorx.Wrap(errorx.ErrInternal, err)
+// validateCodeChallenge accepts an empty PKCE challenge or an S256 one. "plain" offers no protection
+// against a stolen refreshState, since the challenge would travel with it.
+func validateCodeChallenge(challenge, method string) error {
+	if challenge == "" {
+		return nil
+	}
+	if method != oidc.CodeChallengeMethodS256 {
+		return errorx.New(errorx.ErrBadRequest, "codeChallengeMethod must be S256")
 	}
-	if err := s.cache.Delete(key); err != nil {
-		s.logger.Error("failed to delete refresh state after use", "key", key, "error", err)
+	// An S256 challenge is the unpadded base64url encoding of a SHA-256 digest.
+	if len(challenge) != 43 {
+		return errorx.New(errorx.ErrBadRequest, "codeChallenge must be a base64url SHA-256 digest")
 	}
-	userData := cached.UserData
-	if userData.Email == "" {
+	return nil
+}
+
+// SessionFromState finishes an external login. The login signs in the user it is linked to; failing that,
+// a new account is created for an unknown email. When the email belongs to an existing account the owner
+// has to confirm the link first, so no tokens are issued and a link token is returned instead. A login
+// started with a PKCE codeChallenge needs the matching codeVerifier.
+func (s *AuthSvc) SessionFromState(ctx context.Context, req aggregate.SessionFromStateReq) (*aggregate.LoginResp, error) {
+	var refreshState aggregate.OAuthRefreshState
+	if err := s.stateSealer.Open(req.RefreshState, &refreshState); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInvalidRefreshState, err)
+	}
+	userData := refreshState.UserData
+	if userData.Email == "" || refreshState.ID == "" {
 		return nil, errorx.New(errorx.ErrInvalidRefreshState, errorx.GetErrorMessage(int(errorx.ErrInvalidRefreshState)))
 	}
-	authType := cached.AuthType
-	user, err := s.userRepo.FindByEmail(ctx, userData.Email)
-	if err != nil {
-		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	// Checked before the state is consumed, so a wrong verifier from whoever intercepted the state does
+	// not burn it for the client that started the login.
+	if refreshState.CodeChallenge != "" && !oidc.VerifyCodeChallenge(refreshState.CodeChallenge, req.CodeVerifier) {
+		return nil, errorx.New(errorx.ErrInvalidRefreshState, "codeVerifier does not match the login's codeChallenge")
+	}
+	if refreshState.CodeChallenge == "" && refreshState.ClientBinding != "" {
+		binding := clientBinding(ctx, s.stateSealer, s.cfg.Current().OAuth.StateBinding)
+		if binding != "" && subtle.ConstantTimeCompare([]byte(binding), []byte(refreshState.ClientBinding)) != 1 {
+			logger.WithContext(ctx, s.logger).Warn("[AuthSvc] refreshState presented by another client", "authType", refreshState.AuthType)
+			return nil, errorx.New(errorx.ErrInvalidRefreshState, "refreshState was issued to another client")
+		}
+	}
+	if err := s.consumeRefreshState(ctx, refreshState.ID); err != nil {
+		return nil, err
+	}
+	authType := refreshState.AuthType
+	ctx = withLoginMethod(ctx, authType)
+	user := s.identitySvc.FindUser(ctx, refreshState)
+	if user == nil {
+		byEmail, err := s.userRepo.FindByEmail(ctx, userData.Email)
+		if err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		if byEmail != nil {
+			if !s.identitySvc.LinksWithoutConfirmation(ctx, byEmail, refreshState) {
+				token, err := s.identitySvc.ProposeLink(ctx, byEmail.ID, refreshState)
+				if err != nil {
+					return nil, err
+				}
+				return &aggregate.LoginResp{LinkRequired: true, LinkToken: token}, nil
+			}
+			if err := s.identitySvc.Link(ctx, byEmail.ID, refreshState); err != nil {
+				return nil, err
+			}
+		}
+		user = byEmail
 	}
 	if user == nil {
 		randomPass, err := helper.GenerateRefreshToken()
 		if err != nil {
 			return nil, errorx.Wrap(errorx.ErrInternal, err)
 		}
-		hashed, err := helper.HashPassword(randomPass)
+		hashed, pepperVersion, err := s.hasher.Hash(randomPass)
 		if err != nil {
 			return nil, errorx.Wrap(errorx.ErrInternal, err)
 		}
 		user, err = s.userRepo.Create(ctx, &model.User{
-			Username:   userData.Email,
-			Email:      userData.Email,
-			Password:   hashed,
-			Status:     constant.UserStatusActive,
-			AuthType:   authType,
-			AuthTypeID: userData.ProviderID,
+			Username:              userData.Email,
+			Email:                 userData.Email,
+			Password:              hashed,
+			PasswordPepperVersion: pepperVersion,
+			Status:                constant.UserStatusActive,
 		})
 		if err != nil {
 			return nil, errorx.Wrap(errorx.ErrInternal, err)
 		}
-	} else {
-		if err := s.updateLastLoginAt(ctx, user.ID); err != nil {
-			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		if err := s.identitySvc.Link(ctx, user.ID, refreshState); err != nil {
+			return nil, err
 		}
+		s.publishUserRegistered(ctx, user)
+	} else if user.IsDisabled() {
+		return nil, errorx.New(errorx.ErrUserInactive, errorx.GetErrorMessage(int(errorx.ErrUserInactive)))
+	}
+	// OIDC issuers and SAML connections share one AuthType each, so their role mappings are keyed by provider.
+	mappingProvider := authType
+	if refreshState.Provider != "" {
+		mappingProvider = constant.UserAuthType(refreshState.Provider)
 	}
-	return s.generateTokens(ctx, jwt.Payload{
+	if err := s.roleSvc.SyncExternalRoles(ctx, user.ID, mappingProvider, userData.Groups); err != nil {
+		return nil, err
+	}
+	// The external login only stands in for the password: TOTP, the project quota and the access policy still apply.
+	return s.signIn(ctx, jwt.Payload{
 		UserID:       user.ID,
 		IsSuperAdmin: false,
 		Email:        user.Email,
-	})
+		ProjectID:    refreshState.ProjectID,
+	}, req.DeviceToken)
 }
 
+// generateTokens starts a session for payload on the requesting device.
 func (s *AuthSvc) generateTokens(ctx context.Context, payload jwt.Payload) (*aggregate.TokenResp, error) {
+	return s.issueTokens(ctx, payload, s.recordDevice(ctx, payload))
+}
+
+// issueTokens starts a session for payload on deviceID, which may be empty, and signs its tokens.
+func (s *AuthSvc) issueTokens(ctx context.Context, payload jwt.Payload, deviceID string) (*aggregate.TokenResp, error) {
 	refreshToken, err := helper.GenerateRefreshToken()
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
-	accessToken, err := s.jwtTokenManager.Generate(ctx, payload, time.Duration(s.cfg.Jwt.AccessTokenExpiresIn)*time.Second)
+	sessionID, err := uuid.NewV6()
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	payload.SessionID = sessionID.String()
+	if err := s.embedAuthzClaims(ctx, &payload); err != nil {
+		return nil, err
+	}
+	s.embedAttributeClaims(ctx, &payload)
+	if err := s.embedCustomClaims(ctx, &payload); err != nil {
+		return nil, err
+	}
+	accessToken, err := s.jwtTokenManager.Generate(ctx, payload, time.Duration(s.cfg.Current().Jwt.AccessTokenExpiresIn)*time.Second)
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
 	metaJSON, _ := json.Marshal(metadataFromContext(ctx))
-	accessExp := time.Duration(s.cfg.Jwt.AccessTokenExpiresIn) * time.Second
-	refreshExp := time.Duration(s.cfg.Jwt.RefreshTokenExpiresIn) * time.Second
+	accessExp := time.Duration(s.cfg.Current().Jwt.AccessTokenExpiresIn) * time.Second
+	refreshExp := time.Duration(s.cfg.Current().Jwt.RefreshTokenExpiresIn) * time.Second
 	session, err := s.sessionRepo.Create(ctx, &model.Session{
-		UserID:       payload.UserID,
-		Email:        payload.Email,
-		RefreshToken: refreshToken,
-		ExpiresAt:    time.Now().Add(refreshExp),
-		IsSuperAdmin: payload.IsSuperAdmin,
-		IsActive:     true,
+		UserID:           payload.UserID,
+		Email:            payload.Email,
+		RefreshTokenHash: helper.HashRefreshToken(refreshToken),
+		ExpiresAt:        time.Now().Add(refreshExp),
+		IsSuperAdmin:     payload.IsSuperAdmin,
+		ProjectID:        payload.ProjectID,
+		MFAVerified:      payload.MFA,
+		DeviceID:         deviceID,
+		IsActive:         true,
 		BaseModel: model.BaseModel{
+			ID:        payload.SessionID,
 			CreatedBy: payload.UserID,
 			UpdatedBy: payload.UserID,
 			Metadata:  datatypes.JSON(metaJSON),
@@ -306,16 +829,122 @@ func (s *AuthSvc) generateTokens(ctx context.Context, payload jwt.Payload) (*agg
 	}, nil
 }
 
+// embedAuthzClaims adds the user's role codes and permission keys to payload when the config asks for them.
+// A token signed in to a project only carries that project's and the system entries.
+func (s *AuthSvc) embedAuthzClaims(ctx context.Context, payload *jwt.Payload) error {
+	if payload.IsSuperAdmin || payload.UserID == "" {
+		return nil
+	}
+	inScope := func(projectID *string) bool {
+		return payload.ProjectID == "" || projectID == nil || *projectID == constant.SystemProjectID || *projectID == payload.ProjectID
+	}
+
+	if s.cfg.Current().Jwt.EmbedRoles {
+		userRoles, err := s.roleSvc.GetUserRoles(ctx, aggregate.GetUserRolesReq{UserID: payload.UserID})
+		if err != nil {
+			return err
+		}
+		payload.Roles = nil
+		for _, userRole := range userRoles {
+			if userRole.Role != nil && inScope(userRole.ProjectID) && !slices.Contains(payload.Roles, userRole.Role.Code) {
+				payload.Roles = append(payload.Roles, userRole.Role.Code)
+			}
+		}
+		sort.Strings(payload.Roles)
+	}
+
+	if s.cfg.Current().Jwt.EmbedPermissions {
+		permissions, err := s.roleSvc.GetUserPermissions(ctx, payload.UserID)
+		if err != nil {
+			return err
+		}
+		payload.Permissions = nil
+		for key, granted := range permissions {
+			project, _, _ := strings.Cut(key, "/")
+			if granted && inScope(&project) {
+				payload.Permissions = append(payload.Permissions, key)
+			}
+		}
+		sort.Strings(payload.Permissions)
+	}
+	return nil
+}
+
+// embedAttributeClaims adds the user attributes the signed-in project's schema marks as claims to payload.
+func (s *AuthSvc) embedAttributeClaims(ctx context.Context, payload *jwt.Payload) {
+	payload.Attributes = nil
+	if payload.IsSuperAdmin || payload.UserID == "" || payload.ProjectID == "" {
+		return
+	}
+	project := s.projectRepo.FindOneById(ctx, payload.ProjectID)
+	if project == nil {
+		return
+	}
+	var attributes map[string]any
+	for _, def := range project.AttributeSchemaList() {
+		if !def.Claim {
+			continue
+		}
+		if attributes == nil {
+			user := s.userRepo.FindOneById(ctx, payload.UserID)
+			if user == nil {
+				return
+			}
+			attributes = user.AttributeMap()
+		}
+		if value, ok := attributes[def.Key]; ok {
+			if payload.Attributes == nil {
+				payload.Attributes = map[string]any{}
+			}
+			payload.Attributes[def.Key] = value
+		}
+	}
+}
+
+// embedCustomClaims merges the claims of the host's enrichers into payload.
+func (s *AuthSvc) embedCustomClaims(ctx context.Context, payload *jwt.Payload) error {
+	payload.Custom = nil
+	for _, enricher := range s.claimsEnrichers {
+		claims, err := enricher.EnrichClaims(ctx, *payload)
+		if err != nil {
+			logger.WithContext(ctx, s.logger).Error("[AuthSvc] claims enricher failed", "userID", payload.UserID, "error", err)
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+		for key, value := range claims {
+			if payload.Custom == nil {
+				payload.Custom = map[string]any{}
+			}
+			payload.Custom[key] = value
+		}
+	}
+	return nil
+}
+
 func (s *AuthSvc) loginWithSuperAdmin(ctx context.Context, req aggregate.LoginReq) (*aggregate.TokenResp, error) {
+	if err := s.ipFilterSvc.CheckAdmin(ctx, clientIPFromContext(ctx)); err != nil {
+		return nil, err
+	}
+	if err := s.checkCaptcha(ctx, constant.RateLimitRouteLogin, req.Email, req.CaptchaToken); err != nil {
+		return nil, err
+	}
 	user, err := s.superAdminRepo.FindByEmail(ctx, req.Email)
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
 	if user == nil {
-		return nil, errorx.New(errorx.ErrUserNotFound, errorx.GetErrorMessage(int(errorx.ErrUserNotFound)))
+		s.recordFailure(ctx, constant.RateLimitRouteLogin, req.Email)
+		return nil, s.loginFailed(errorx.ErrUserNotFound, req.Password)
+	}
+	if err := s.hasher.Compare(user.Password, user.PasswordPepperVersion, req.Password); err != nil {
+		s.recordFailure(ctx, constant.RateLimitRouteLogin, req.Email)
+		return nil, s.loginFailed(errorx.ErrInvalidPassword, "")
 	}
-	if err := helper.ComparePassword(user.Password, req.Password); err != nil {
-		return nil, errorx.New(errorx.ErrInvalidPassword, errorx.GetErrorMessage(int(errorx.ErrInvalidPassword)))
+	s.resetFailures(ctx, constant.RateLimitRouteLogin, req.Email)
+	if s.hasher.NeedsRehash(user.Password, user.PasswordPepperVersion) {
+		s.rehashPassword(ctx, req.Password, func(hashed string, pepperVersion int) error {
+			return s.superAdminRepo.Update(ctx, user.ID, model.SuperAdmin{Password: hashed, PasswordPepperVersion: pepperVersion},
+				"password", "password_pepper_version")
+		})
 	}
 
 	tokenResp, err := s.generateTokens(ctx, jwt.Payload{
@@ -330,59 +959,173 @@ func (s *AuthSvc) loginWithSuperAdmin(ctx context.Context, req aggregate.LoginRe
 	return tokenResp, nil
 }
 
-func (s *AuthSvc) loginWithEmail(ctx context.Context, req aggregate.LoginReq) (*aggregate.TokenResp, error) {
+// loginWithEmail checks the password and either issues tokens or, when the user has TOTP enabled
+// and the device is not trusted, returns an MFA challenge instead. A login from a new device or location
+// may be held until the user confirms it by email.
+func (s *AuthSvc) loginWithEmail(ctx context.Context, req aggregate.LoginReq) (*aggregate.LoginResp, error) {
+	if err := s.checkCaptcha(ctx, constant.RateLimitRouteLogin, req.Email, req.CaptchaToken); err != nil {
+		return nil, err
+	}
 	user, err := s.userRepo.FindByEmail(ctx, req.Email)
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
 	if user == nil {
-		return nil, errorx.New(errorx.ErrUserNotFound, errorx.GetErrorMessage(int(errorx.ErrUserNotFound)))
+		s.recordFailure(ctx, constant.RateLimitRouteLogin, req.Email)
+		return nil, s.loginFailed(errorx.ErrUserNotFound, req.Password)
+	}
+	if err := s.hasher.Compare(user.Password, user.PasswordPepperVersion, req.Password); err != nil {
+		s.recordFailure(ctx, constant.RateLimitRouteLogin, req.Email)
+		return nil, s.loginFailed(errorx.ErrInvalidPassword, "")
+	}
+	s.resetFailures(ctx, constant.RateLimitRouteLogin, req.Email)
+	if s.hasher.NeedsRehash(user.Password, user.PasswordPepperVersion) {
+		s.rehashPassword(ctx, req.Password, func(hashed string, pepperVersion int) error {
+			return s.userRepo.Update(ctx, user.ID, model.User{Password: hashed, PasswordPepperVersion: pepperVersion},
+				"password", "password_pepper_version")
+		})
+	}
+	if user.IsDisabled() {
+		return nil, errorx.New(errorx.ErrUserInactive, errorx.GetErrorMessage(int(errorx.ErrUserInactive)))
 	}
-	if err := helper.ComparePassword(user.Password, req.Password); err != nil {
-		return nil, errorx.New(errorx.ErrInvalidPassword, errorx.GetErrorMessage(int(errorx.ErrInvalidPassword)))
+	if user.PasswordResetRequired {
+		return nil, errorx.New(errorx.ErrForbidden, "Password reset required: sign in another way and set a new password")
+	}
+	if held, err := s.holdSuspiciousLogin(ctx, user, req.ProjectID); held != nil || err != nil {
+		return held, err
 	}
 
-	tokenResp, err := s.generateTokens(ctx, jwt.Payload{
+	return s.signIn(ctx, jwt.Payload{
 		UserID:       user.ID,
 		IsSuperAdmin: false,
 		Email:        user.Email,
-	})
+		ProjectID:    req.ProjectID,
+	}, req.DeviceToken)
+}
+
+// loginFailed returns the error for a password login of an unknown account (ErrUserNotFound) or with a wrong
+// password (ErrInvalidPassword). With enumeration protection both are ErrInvalidCredentials, and for an unknown
+// account password is checked against a dummy hash first, so the failure takes as long as a wrong password.
+func (s *AuthSvc) loginFailed(code errorx.AppErrCode, password string) error {
+	if !s.cfg.Current().AccountEnumeration.Protection {
+		return errorx.New(code, errorx.GetErrorMessage(int(code)))
+	}
+	if code == errorx.ErrUserNotFound {
+		_ = s.hasher.Compare(s.dummyHash, s.dummyPepperVersion, password)
+	}
+	return errorx.New(errorx.ErrInvalidCredentials, errorx.GetErrorMessage(int(errorx.ErrInvalidCredentials)))
+}
+
+// rehashPassword replaces a hash made with an outdated algorithm, parameters or pepper, now that the login
+// gave the password. A failure is only logged: the old hash still works and is replaced on a later login.
+func (s *AuthSvc) rehashPassword(ctx context.Context, plain string, save func(hashed string, pepperVersion int) error) {
+	hashed, pepperVersion, err := s.hasher.Hash(plain)
+	if err == nil {
+		err = save(hashed, pepperVersion)
+	}
 	if err != nil {
-		return nil, errorx.Wrap(errorx.ErrInternal, err)
+		logger.WithContext(ctx, s.logger).Warn("[AuthSvc] failed to upgrade password hash", "error", err)
+	}
+}
+
+// signIn finishes a first-factor login: users with TOTP get an MFA challenge unless the device is trusted.
+func (s *AuthSvc) signIn(ctx context.Context, payload jwt.Payload, deviceToken string) (*aggregate.LoginResp, error) {
+	if s.findTOTP(ctx, payload.UserID, true) != nil {
+		if !s.trustedDeviceSvc.IsTrusted(ctx, payload.UserID, deviceToken) {
+			return s.mfaChallenge(ctx, payload)
+		}
+		payload.MFA = true
+	}
+	tokenResp, err := s.completeLogin(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &aggregate.LoginResp{TokenResp: *tokenResp}, nil
+}
+
+// completeLogin meters the login against the project quota, enforces the project access policy and issues tokens for a fully authenticated user.
+func (s *AuthSvc) completeLogin(ctx context.Context, payload jwt.Payload) (*aggregate.TokenResp, error) {
+	if err := checkProjectNotArchived(ctx, s.projectRepo, payload.ProjectID); err != nil {
+		return nil, err
+	}
+	if err := s.projectUsageSvc.Record(ctx, payload.ProjectID, constant.ProjectUsageLogin); err != nil {
+		return nil, err
 	}
-	err = s.updateLastLoginAt(ctx, user.ID)
+	if err := s.accessPolicySvc.Enforce(ctx, payload.ProjectID, constant.AccessStageLogin, payload); err != nil {
+		return nil, err
+	}
+	anomalies := s.loginAnomalies(ctx, payload.UserID)
+	tokenResp, err := s.generateTokens(ctx, payload)
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
+	if len(anomalies) > 0 {
+		s.flagSession(ctx, payload, tokenResp.SessionID, anomalies)
+	}
+	if err := s.updateLastLoginAt(ctx, payload.UserID); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
 	return tokenResp, nil
 }
 
 func (s *AuthSvc) loginWithGoogle(ctx context.Context, req aggregate.LoginReq) (*aggregate.LoginResp, error) {
-	refreshState, err := helper.GenerateRefreshToken()
+	if req.RedirectURL == "" {
+		return nil, errorx.New(errorx.ErrBadRequest, "redirectUrl is required for OAuth login")
+	}
+	if err := s.checkRedirectURL(ctx, req.ProjectID, req.RedirectURL); err != nil {
+		return nil, err
+	}
+	if err := validateCodeChallenge(req.CodeChallenge, req.CodeChallengeMethod); err != nil {
+		return nil, err
+	}
+	state, err := s.stateSealer.Seal(aggregate.OAuthLoginState{
+		AuthType:      constant.UserAuthTypeGoogle,
+		RedirectURL:   req.RedirectURL,
+		ProjectID:     req.ProjectID,
+		CodeChallenge: req.CodeChallenge,
+		ClientBinding: clientBinding(ctx, s.stateSealer, s.cfg.Current().OAuth.StateBinding),
+	}, constant.RefreshStateTTL)
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
-	if req.RedirectURL != "" {
-		redirectKey := s.buildOAuthRedirectCacheKey(ctx, refreshState)
-		ttl := constant.RefreshStateTTL
-		if err := s.cache.Set(redirectKey, struct {
-			URL string `json:"url"`
-		}{URL: req.RedirectURL}, &ttl); err != nil {
-			return nil, errorx.Wrap(errorx.ErrInternal, err)
-		}
-	}
-	authURL, err := s.buildGoogleAuthURL(refreshState)
+	authURL, err := s.buildGoogleAuthURL(state)
 	if err != nil {
 		return nil, err
 	}
 	return &aggregate.LoginResp{
-		RefreshState: refreshState,
-		RedirectURL:  authURL,
+		RedirectURL: authURL,
 	}, nil
 }
 
+// checkRedirectURL rejects a login redirect target that projectID does not list in its redirect URLs, or that
+// OAUTH_REDIRECT_URLS does not list when the login names no project.
+func (s *AuthSvc) checkRedirectURL(ctx context.Context, projectID, redirectURL string) error {
+	return checkRedirectURL(ctx, s.logger, s.projectRepo, s.cfg.Current().OAuth.RedirectURLs, projectID, redirectURL)
+}
+
+// checkRedirectURL is shared by every login that ends in a browser redirect carrying a refreshState, so none
+// of them can hand the state to a host the operator did not list.
+func checkRedirectURL(ctx context.Context, log logger.ILogger, projectRepo repository.IProjectRepository, globalURLs, projectID, redirectURL string) error {
+	if projectID == "" {
+		if !model.RedirectAllowed(strings.Split(globalURLs, ","), redirectURL) {
+			logger.WithContext(ctx, log).Warn("[AuthSvc] rejected redirect URL not in OAUTH_REDIRECT_URLS", "redirectUrl", redirectURL)
+			return errorx.New(errorx.ErrBadRequest, "redirectUrl is not allowed; pass the projectId it belongs to")
+		}
+		return nil
+	}
+	project := projectRepo.FindOneById(ctx, projectID)
+	if project == nil {
+		return errorx.Wrap(errorx.ErrProjectNotFound, nil)
+	}
+	if !project.AllowsRedirect(redirectURL) {
+		logger.WithContext(ctx, log).Warn("[AuthSvc] rejected redirect URL not allowed by project", "projectID", projectID, "redirectUrl", redirectURL)
+		return errorx.New(errorx.ErrBadRequest, "redirectUrl is not allowed for this project")
+	}
+	return nil
+}
+
 func (s *AuthSvc) buildGoogleAuthURL(state string) (string, error) {
-	return s.googleOAuth2Config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "consent")), nil
+	return s.googleOAuth2Config().AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "consent")), nil
 }
 
 func (s *AuthSvc) fetchGoogleUserInfo(ctx context.Context, accessToken string) (*aggregate.GoogleUserData, error) {
@@ -406,8 +1149,58 @@ func (s *AuthSvc) fetchGoogleUserInfo(ctx context.Context, accessToken string) (
 	return &info, nil
 }
 
-func (s *AuthSvc) buildOAuthRedirectCacheKey(ctx context.Context, state string) string {
-	return fmt.Sprintf("oauth_redirect:%s", state)
+// googleGroupClaims returns the claims used for role mapping. Google userinfo carries no group
+// membership, so the Workspace hosted domain is the only directory claim available.
+func googleGroupClaims(userInfo *aggregate.GoogleUserData) []string {
+	if userInfo.HD == "" {
+		return nil
+	}
+	return []string{userInfo.HD}
+}
+
+func (s *AuthSvc) loginWithOIDC(ctx context.Context, req aggregate.LoginReq) (*aggregate.LoginResp, error) {
+	if req.RedirectURL == "" {
+		return nil, errorx.New(errorx.ErrBadRequest, "redirectUrl is required for OAuth login")
+	}
+	if err := s.checkRedirectURL(ctx, req.ProjectID, req.RedirectURL); err != nil {
+		return nil, err
+	}
+	if err := validateCodeChallenge(req.CodeChallenge, req.CodeChallengeMethod); err != nil {
+		return nil, err
+	}
+	p, err := s.oauthProvider(req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.stateSealer.Seal(aggregate.OAuthLoginState{
+		AuthType:      constant.UserAuthTypeOIDC,
+		Provider:      req.Provider,
+		RedirectURL:   req.RedirectURL,
+		ProjectID:     req.ProjectID,
+		CodeChallenge: req.CodeChallenge,
+		ClientBinding: clientBinding(ctx, s.stateSealer, s.cfg.Current().OAuth.StateBinding),
+	}, constant.RefreshStateTTL)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	authURL, err := p.AuthCodeURL(ctx, state)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return &aggregate.LoginResp{
+		RedirectURL: authURL,
+	}, nil
+}
+
+func (s *AuthSvc) oauthProvider(key string) (IOAuthProvider, error) {
+	if key == "" {
+		return nil, errorx.New(errorx.ErrBadRequest, "provider is required for OIDC login")
+	}
+	p, ok := s.oauthProviders.Get(key)
+	if !ok {
+		return nil, errorx.New(errorx.ErrBadRequest, fmt.Sprintf("unknown OIDC provider: %s", key))
+	}
+	return p, nil
 }
 
 func (s *AuthSvc) loginWithFacebook(ctx context.Context, req aggregate.LoginReq) (*aggregate.LoginResp, error) {
@@ -424,11 +1217,35 @@ func (s *AuthSvc) updateLastLoginAt(ctx context.Context, userID string) error {
 	}, "last_login_at")
 }
 
-func (s *AuthSvc) buildRefreshStateCacheKey(ctx context.Context, state string) string {
-	return fmt.Sprintf("refresh_state:%s", state)
+func (s *AuthSvc) buildRefreshStateCacheKey(ctx context.Context, stateID string) string {
+	return fmt.Sprintf("refresh_state:%s", stateID)
+}
+
+// consumeRefreshState marks a sealed refresh state as used so it cannot be exchanged twice. The marker
+// lives in the local cache and is claimed atomically, so of two concurrent exchanges only one succeeds. If
+// the cache is unreachable the exchange fails, since its use could not be recorded.
+func (s *AuthSvc) consumeRefreshState(ctx context.Context, stateID string) error {
+	key := s.buildRefreshStateCacheKey(ctx, stateID)
+	used := struct {
+		UsedAt time.Time `json:"usedAt"`
+	}{UsedAt: time.Now()}
+	ttl := constant.RefreshStateTTL
+	claimed, err := s.cache.SetNX(key, used, &ttl)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[AuthSvc] failed to mark refresh state as used", "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if !claimed {
+		return errorx.New(errorx.ErrInvalidRefreshState, errorx.GetErrorMessage(int(errorx.ErrInvalidRefreshState)))
+	}
+	return nil
 }
 
 func metadataFromContext(ctx context.Context) map[string]any {
 	str := func(k constant.ContextKey) string { v := ctx.Value(k); s, _ := v.(string); return s }
-	return map[string]any{"ip": str(constant.ContextKeyClientIP), "user_agent": str(constant.ContextKeyUserAgent), "referer": str(constant.ContextKeyReferer)}
+	meta := map[string]any{"ip": str(constant.ContextKeyClientIP), "user_agent": str(constant.ContextKeyUserAgent), "referer": str(constant.ContextKeyReferer), "country": str(constant.ContextKeyCountry), "request_id": logger.RequestIDFromContext(ctx)}
+	if method := loginMethodFromContext(ctx); method != "" {
+		meta["login_method"] = method
+	}
+	return meta
 }
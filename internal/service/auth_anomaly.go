@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"gorm.io/datatypes"
+)
+
+// versionPattern matches the version numbers in a User-Agent, which change with every browser update.
+var versionPattern = regexp.MustCompile(`[0-9][0-9._]*`)
+
+// sessionMetadata is the part of a session's metadata logins are compared on.
+type sessionMetadata struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Country   string `json:"country"`
+}
+
+// loginAnomalies compares the request's device and location with the user's recent sessions. The device
+// is the User-Agent without version numbers; the location is the country from the proxy header, or the
+// client's /24 (IPv4) or /48 (IPv6) network when no country is known. A user without earlier sessions has
+// nothing to compare with and is never flagged.
+func (s *AuthSvc) loginAnomalies(ctx context.Context, userID string) []string {
+	cfg := s.cfg.Current().LoginAnomaly
+	if cfg.Disabled {
+		return nil
+	}
+	limit := cfg.HistorySize
+	if limit <= 0 {
+		limit = constant.DefaultLoginAnomalyHistory
+	}
+	sessions, _, err := s.sessionRepo.ListByUserID(ctx, userID, repository.Page{Limit: limit})
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to read recent sessions, skipping the anomaly check", "userID", userID, "error", err)
+		return nil
+	}
+
+	devices, countries, networks := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for i := range sessions {
+		var meta sessionMetadata
+		if err := json.Unmarshal(sessions[i].Metadata, &meta); err != nil {
+			continue
+		}
+		if device := deviceFingerprint(meta.UserAgent); device != "" {
+			devices[device] = true
+		}
+		if meta.Country != "" {
+			countries[strings.ToUpper(meta.Country)] = true
+		}
+		if network := ipNetwork(meta.IP); network != "" {
+			networks[network] = true
+		}
+	}
+
+	var anomalies []string
+	str := func(k constant.ContextKey) string { v, _ := ctx.Value(k).(string); return v }
+	if device := deviceFingerprint(str(constant.ContextKeyUserAgent)); device != "" && len(devices) > 0 && !devices[device] {
+		anomalies = append(anomalies, constant.LoginAnomalyNewDevice)
+	}
+	country := strings.ToUpper(str(constant.ContextKeyCountry))
+	switch {
+	case country != "" && len(countries) > 0:
+		if !countries[country] {
+			anomalies = append(anomalies, constant.LoginAnomalyNewLocation)
+		}
+	case len(networks) > 0:
+		if network := ipNetwork(str(constant.ContextKeyClientIP)); network != "" && !networks[network] {
+			anomalies = append(anomalies, constant.LoginAnomalyNewLocation)
+		}
+	}
+	return anomalies
+}
+
+// flagSession records anomalies in the metadata of the session just issued for payload and publishes
+// login.suspicious.
+func (s *AuthSvc) flagSession(ctx context.Context, payload jwt.Payload, sessionID string, anomalies []string) {
+	meta := metadataFromContext(ctx)
+	meta["anomalies"] = anomalies
+	metaJSON, _ := json.Marshal(meta)
+	if err := s.sessionRepo.Update(ctx, sessionID, model.Session{
+		BaseModel: model.BaseModel{Metadata: datatypes.JSON(metaJSON)},
+	}, "metadata"); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to flag suspicious session", "sessionID", sessionID, "error", err)
+	}
+	s.publishLoginSuspicious(ctx, LoginSuspiciousEvent{
+		UserID:    payload.UserID,
+		SessionID: sessionID,
+		ProjectID: payload.ProjectID,
+		Anomalies: anomalies,
+	})
+}
+
+// holdSuspiciousLogin emails user a sign-in link instead of letting the login proceed when
+// LOGIN_ANOMALY_REQUIRE_CONFIRMATION is set and the login comes from a new device or location. It returns
+// nil when the login may proceed; users without an email, or a server without MAGIC_LINK_URL, are never held.
+func (s *AuthSvc) holdSuspiciousLogin(ctx context.Context, user *model.User, projectID string) (*aggregate.LoginResp, error) {
+	cfg := s.cfg.Current()
+	if !cfg.LoginAnomaly.RequireConfirmation || cfg.MagicLink.URL == "" || user.Email == "" {
+		return nil, nil
+	}
+	anomalies := s.loginAnomalies(ctx, user.ID)
+	if len(anomalies) == 0 {
+		return nil, nil
+	}
+	if err := s.sendSignInLink(ctx, user, projectID,
+		"We noticed a sign-in from a new device or location. If it was you, use this link to finish signing in."); err != nil {
+		return nil, err
+	}
+	s.publishLoginSuspicious(ctx, LoginSuspiciousEvent{
+		UserID:               user.ID,
+		ProjectID:            projectID,
+		Anomalies:            anomalies,
+		ConfirmationRequired: true,
+	})
+	return &aggregate.LoginResp{ConfirmationRequired: true}, nil
+}
+
+// publishLoginSuspicious fills in the request's IP, country and User-Agent and publishes event.
+func (s *AuthSvc) publishLoginSuspicious(ctx context.Context, event LoginSuspiciousEvent) {
+	event.IP, _ = ctx.Value(constant.ContextKeyClientIP).(string)
+	event.Country, _ = ctx.Value(constant.ContextKeyCountry).(string)
+	event.UserAgent, _ = ctx.Value(constant.ContextKeyUserAgent).(string)
+	logger.WithContext(ctx, s.logger).Info("Suspicious login", "userID", event.UserID, "anomalies", event.Anomalies)
+	publishEvent(ctx, s.events, s.logger, constant.EventLoginSuspicious, event.UserID, event)
+}
+
+// deviceFingerprint reduces a User-Agent to the browser and platform it names, so a browser update is
+// not taken for a new device.
+func deviceFingerprint(userAgent string) string {
+	return strings.TrimSpace(versionPattern.ReplaceAllString(strings.ToLower(userAgent), ""))
+}
+
+// ipNetwork returns the /24 (IPv4) or /48 (IPv6) network of ip, or "" when ip is not an address.
+func ipNetwork(ip string) string {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ""
+	}
+	if v4 := addr.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return addr.Mask(net.CIDRMask(48, 128)).String()
+}
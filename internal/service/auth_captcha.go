@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/captcha"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// checkCaptcha requires a valid captcha token once the client IP or the account has failed route
+// CAPTCHA_FAILURE_THRESHOLD times within the window. Without a captcha provider nobody is challenged.
+func (s *AuthSvc) checkCaptcha(ctx context.Context, route, account, token string) error {
+	cfg := s.cfg.Current().Captcha
+	if cfg.Provider == "" {
+		return nil
+	}
+	threshold := int64(constant.DefaultCaptchaFailureThreshold)
+	if cfg.FailureThreshold > 0 {
+		threshold = int64(cfg.FailureThreshold)
+	}
+	failures, err := s.rateLimitSvc.Failures(ctx, route, failureCallers(ctx, account)...)
+	if err != nil {
+		// Like the rate limiter, an unavailable counter store must not take sign-in down with it.
+		logger.WithContext(ctx, s.logger).Warn("Failed to read sign-in failures, skipping the captcha check", "route", route, "error", err)
+		return nil
+	}
+	if failures < threshold {
+		return nil
+	}
+	if token == "" {
+		return errorx.New(errorx.ErrCaptchaRequired, "captcha required after repeated failures; send captchaToken")
+	}
+	ip, _ := ctx.Value(constant.ContextKeyClientIP).(string)
+	if err := s.captcha.Verify(ctx, token, ip); err != nil {
+		if errors.Is(err, captcha.ErrRejected) {
+			return errorx.New(errorx.ErrCaptchaRequired, "captcha verification failed")
+		}
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt at route against the client IP and the account, for the captcha
+// challenge and the IP ban.
+func (s *AuthSvc) recordFailure(ctx context.Context, route, account string) {
+	s.ipFilterSvc.RecordFailure(ctx, route)
+	cfg := s.cfg.Current().Captcha
+	if cfg.Provider == "" {
+		return
+	}
+	window := constant.DefaultCaptchaFailureWindow
+	if cfg.FailureWindowSec > 0 {
+		window = time.Duration(cfg.FailureWindowSec) * time.Second
+	}
+	if err := s.rateLimitSvc.RecordFailure(ctx, route, window, failureCallers(ctx, account)...); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to count sign-in failure", "route", route, "error", err)
+	}
+}
+
+// resetFailures forgets the failures of the account after it signs in. Those of the IP are kept, so
+// signing in to one account does not clear the way for guessing at others.
+func (s *AuthSvc) resetFailures(ctx context.Context, route, account string) {
+	if s.cfg.Current().Captcha.Provider == "" || account == "" {
+		return
+	}
+	caller := aggregate.RateLimitCaller{Kind: constant.RateLimitIdentityUser, ID: strings.ToLower(account)}
+	if err := s.rateLimitSvc.ResetFailures(ctx, route, caller); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to reset sign-in failures", "route", route, "error", err)
+	}
+}
+
+// failureCallers are who failed attempts are counted against: the client IP and the account, by email.
+func failureCallers(ctx context.Context, account string) []aggregate.RateLimitCaller {
+	var callers []aggregate.RateLimitCaller
+	if ip, _ := ctx.Value(constant.ContextKeyClientIP).(string); ip != "" {
+		callers = append(callers, aggregate.RateLimitCaller{Kind: constant.RateLimitIdentityIP, ID: ip})
+	}
+	if account != "" {
+		callers = append(callers, aggregate.RateLimitCaller{Kind: constant.RateLimitIdentityUser, ID: strings.ToLower(account)})
+	}
+	return callers
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// brandPattern matches one `"Brand";v="128"` entry of a Sec-CH-UA header.
+var brandPattern = regexp.MustCompile(`"([^"]*)"\s*;\s*v="[^"]*"`)
+
+// clientDevice identifies the requesting device by its User-Agent client hints, or by its User-Agent for
+// clients that send none. Versions and the randomised GREASE brand are left out, so a browser update keeps
+// the device. fingerprint is empty when the request carries neither.
+func clientDevice(ctx context.Context) (fingerprint, platform string) {
+	str := func(k constant.ContextKey) string { v, _ := ctx.Value(k).(string); return v }
+	platform = strings.Trim(str(constant.ContextKeyClientHintPlatform), `"`)
+
+	var brands []string
+	for _, m := range brandPattern.FindAllStringSubmatch(str(constant.ContextKeyClientHintBrands), -1) {
+		if brand := strings.ToLower(m[1]); !(strings.Contains(brand, "not") && strings.Contains(brand, "brand")) {
+			brands = append(brands, brand)
+		}
+	}
+	var identity string
+	if len(brands) > 0 {
+		slices.Sort(brands)
+		identity = "hints\n" + strings.Join(brands, ",") + "\n" + strings.ToLower(platform) + "\n" + str(constant.ContextKeyClientHintMobile)
+	} else if ua := deviceFingerprint(str(constant.ContextKeyUserAgent)); ua != "" {
+		identity = "ua\n" + ua
+	} else {
+		return "", platform
+	}
+	sum := sha256.Sum256([]byte(identity))
+	return hex.EncodeToString(sum[:]), platform
+}
+
+// recordDevice finds or creates the user's device for the current request and records it as seen. It
+// returns the device ID, or "" for super admins, requests that do not identify a device, and devices
+// that could not be stored: a missing device never fails a login.
+func (s *AuthSvc) recordDevice(ctx context.Context, payload jwt.Payload) string {
+	if payload.IsSuperAdmin || payload.UserID == "" {
+		return ""
+	}
+	fingerprint, platform := clientDevice(ctx)
+	if fingerprint == "" {
+		return ""
+	}
+	str := func(k constant.ContextKey) string { v, _ := ctx.Value(k).(string); return v }
+	seen := model.UserDevice{
+		Platform:    platform,
+		UserAgent:   str(constant.ContextKeyUserAgent),
+		LastIP:      str(constant.ContextKeyClientIP),
+		LastCountry: str(constant.ContextKeyCountry),
+		LastSeenAt:  time.Now(),
+	}
+
+	device := s.deviceRepo.FindByFingerprint(ctx, payload.UserID, fingerprint)
+	if device == nil {
+		created := seen
+		created.UserID = payload.UserID
+		created.Fingerprint = fingerprint
+		created.CreatedBy = payload.UserID
+		created.UpdatedBy = payload.UserID
+		if _, err := s.deviceRepo.Create(ctx, &created); err == nil {
+			return created.ID
+		}
+		// A concurrent login from the same device may have created it first.
+		if device = s.deviceRepo.FindByFingerprint(ctx, payload.UserID, fingerprint); device == nil {
+			logger.WithContext(ctx, s.logger).Warn("Failed to record device", "userID", payload.UserID)
+			return ""
+		}
+	}
+	if err := s.deviceRepo.Update(ctx, device.ID, seen, "platform", "user_agent", "last_ip", "last_country", "last_seen_at"); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to record device use", "deviceID", device.ID, "error", err)
+	}
+	return device.ID
+}
+
+// touchDevice records a refresh of one of the device's sessions as a use of the device.
+func (s *AuthSvc) touchDevice(ctx context.Context, deviceID string) {
+	if deviceID == "" {
+		return
+	}
+	if err := s.deviceRepo.Update(ctx, deviceID, model.UserDevice{LastSeenAt: time.Now()}, "last_seen_at"); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to record device use", "deviceID", deviceID, "error", err)
+	}
+}
+
+func (s *AuthSvc) EndDeviceSessions(ctx context.Context, userID, deviceID string) error {
+	sessions, err := s.sessionRepo.FindActiveByUserID(ctx, userID)
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	for _, session := range sessions {
+		if session.DeviceID != deviceID {
+			continue
+		}
+		if err := s.endSession(ctx, session); err != nil {
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/internal/shared/oidc"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+const oidcGrantDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+// userCodeAlphabet has no vowels, so user codes never spell words, and is case-insensitive to type.
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// userCodeLength is the number of letters in a user code, shown as two groups of four.
+const userCodeLength = 8
+
+const (
+	deviceAuthPending  = "pending"
+	deviceAuthApproved = "approved"
+	deviceAuthDenied   = "denied"
+)
+
+// deviceAuthorization is cached under a device code until the device redeems it or it expires.
+type deviceAuthorization struct {
+	ClientID  string    `json:"clientId"`
+	Scope     string    `json:"scope"`
+	UserCode  string    `json:"userCode"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Status    string    `json:"status"`
+	UserID    string    `json:"userId"`
+	MFA       bool      `json:"mfa"`
+}
+
+// deviceUserCode is cached under a user code and points at its device authorization.
+type deviceUserCode struct {
+	DeviceCode string `json:"deviceCode"`
+}
+
+// StartDeviceAuthorization issues a device code for the device to poll with and a user code for the user
+// to enter at OIDC_DEVICE_VERIFICATION_URL. Protocol errors are returned as *oidc.Error.
+func (s *AuthSvc) StartDeviceAuthorization(ctx context.Context, req aggregate.DeviceCodeReq) (*aggregate.DeviceCodeResp, error) {
+	verificationURL := s.cfg.Current().OIDC.DeviceVerificationURL
+	if verificationURL == "" {
+		return nil, errorx.New(errorx.ErrNotFound, "device authorization is not configured")
+	}
+	client, err := s.authenticateOIDCClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range strings.Fields(req.Scope) {
+		if !slices.Contains(oidcScopes, scope) {
+			return nil, oidc.NewError(oidc.ErrCodeInvalidScope, "unsupported scope: "+scope)
+		}
+	}
+
+	deviceCode, err := helper.GenerateRefreshToken()
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	userCode, err := generateUserCode()
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	ttl := constant.DeviceCodeTTL
+	if err := s.cache.Set(constant.CacheKeyPrefixDeviceCode+deviceCode, deviceAuthorization{
+		ClientID:  client.ClientID,
+		Scope:     req.Scope,
+		UserCode:  userCode,
+		ExpiresAt: time.Now().Add(ttl),
+		Status:    deviceAuthPending,
+	}, &ttl); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if err := s.cache.Set(constant.CacheKeyPrefixUserCode+userCode, deviceUserCode{DeviceCode: deviceCode}, &ttl); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	display := formatUserCode(userCode)
+	completeURL, err := withQuery(verificationURL, url.Values{"userCode": {display}})
+	if err != nil {
+		return nil, err
+	}
+	return &aggregate.DeviceCodeResp{
+		DeviceCode:              deviceCode,
+		UserCode:                display,
+		VerificationURI:         verificationURL,
+		VerificationURIComplete: completeURL,
+		ExpiresIn:               int(ttl.Seconds()),
+		Interval:                int(constant.DeviceCodePollInterval.Seconds()),
+	}, nil
+}
+
+// GetDeviceAuthorization describes the pending device authorization a user code belongs to, for the
+// verification page to show before the signed-in user approves it.
+func (s *AuthSvc) GetDeviceAuthorization(ctx context.Context, req aggregate.GetDeviceAuthorizationReq) (*aggregate.DeviceAuthorizationResp, error) {
+	if payloadFromContext(ctx) == nil {
+		return nil, errorx.Wrap(errorx.ErrUnauthorized, nil)
+	}
+	_, auth, err := s.pendingDeviceAuthorization(req.UserCode)
+	if err != nil {
+		return nil, err
+	}
+	client, _ := s.oidcClients.Get(auth.ClientID)
+	return &aggregate.DeviceAuthorizationResp{
+		UserCode:  formatUserCode(auth.UserCode),
+		ClientID:  auth.ClientID,
+		ProjectID: client.ProjectID,
+		Scope:     auth.Scope,
+		ExpiresAt: auth.ExpiresAt,
+	}, nil
+}
+
+// CompleteDeviceAuthorization approves the device authorization for the signed-in caller, or rejects it
+// when req.Deny is set. Either way the user code cannot be entered again. As with the authorization code
+// flow, the client's project access policy is enforced here, against the user's browser.
+func (s *AuthSvc) CompleteDeviceAuthorization(ctx context.Context, req aggregate.CompleteDeviceAuthorizationReq) error {
+	caller := payloadFromContext(ctx)
+	if caller == nil {
+		return errorx.Wrap(errorx.ErrUnauthorized, nil)
+	}
+	deviceCode, auth, err := s.pendingDeviceAuthorization(req.UserCode)
+	if err != nil {
+		return err
+	}
+	client, ok := s.oidcClients.Get(auth.ClientID)
+	if !ok {
+		return errorx.New(errorx.ErrBadRequest, "invalid or expired user code")
+	}
+	auth.Status = deviceAuthDenied
+	if !req.Deny {
+		user, err := s.clientSignInUser(ctx, caller, client)
+		if err != nil {
+			return err
+		}
+		auth.Status, auth.UserID, auth.MFA = deviceAuthApproved, user.ID, caller.MFA
+	}
+
+	ttl := time.Until(auth.ExpiresAt)
+	if ttl <= 0 {
+		return errorx.New(errorx.ErrBadRequest, "invalid or expired user code")
+	}
+	if err := s.cache.Set(constant.CacheKeyPrefixDeviceCode+deviceCode, auth, &ttl); err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if err := s.cache.Delete(constant.CacheKeyPrefixUserCode + auth.UserCode); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[AuthSvc] failed to delete used user code", "error", err)
+	}
+	return nil
+}
+
+// pendingDeviceAuthorization finds the device authorization waiting for userCode, which may be typed in
+// any case and with or without the separator.
+func (s *AuthSvc) pendingDeviceAuthorization(userCode string) (string, deviceAuthorization, error) {
+	invalid := errorx.New(errorx.ErrBadRequest, "invalid or expired user code")
+	var pointer deviceUserCode
+	if err := s.cache.Get(constant.CacheKeyPrefixUserCode+normalizeUserCode(userCode), &pointer); err != nil {
+		if errors.Is(err, cache.ErrCacheNil) {
+			return "", deviceAuthorization{}, invalid
+		}
+		return "", deviceAuthorization{}, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	var auth deviceAuthorization
+	if err := s.cache.Get(constant.CacheKeyPrefixDeviceCode+pointer.DeviceCode, &auth); err != nil {
+		if errors.Is(err, cache.ErrCacheNil) {
+			return "", deviceAuthorization{}, invalid
+		}
+		return "", deviceAuthorization{}, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if auth.Status != deviceAuthPending {
+		return "", deviceAuthorization{}, invalid
+	}
+	return pointer.DeviceCode, auth, nil
+}
+
+// DeviceToken serves /auth/device/token, where devices poll with their device code.
+func (s *AuthSvc) DeviceToken(ctx context.Context, req aggregate.OIDCTokenReq) (*aggregate.OIDCTokenResp, error) {
+	if s.cfg.Current().OIDC.DeviceVerificationURL == "" {
+		return nil, errorx.New(errorx.ErrNotFound, "device authorization is not configured")
+	}
+	client, err := s.authenticateOIDCClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	switch req.GrantType {
+	case oidcGrantDeviceCode:
+		return s.redeemDeviceCode(ctx, client, req)
+	case "":
+		return nil, oidc.NewError(oidc.ErrCodeInvalidRequest, "grant_type is required")
+	default:
+		return nil, oidc.NewError(oidc.ErrCodeUnsupportedGrantType, "")
+	}
+}
+
+// redeemDeviceCode answers a poll: authorization_pending until the user decides, slow_down when the
+// device polls more often than the interval, and tokens once, after approval. The session is issued to
+// the polling device.
+func (s *AuthSvc) redeemDeviceCode(ctx context.Context, client oidc.Client, req aggregate.OIDCTokenReq) (*aggregate.OIDCTokenResp, error) {
+	if req.DeviceCode == "" {
+		return nil, oidc.NewError(oidc.ErrCodeInvalidRequest, "device_code is required")
+	}
+	expired := oidc.NewError(oidc.ErrCodeExpiredToken, "invalid or expired device code")
+	key := constant.CacheKeyPrefixDeviceCode + req.DeviceCode
+	var auth deviceAuthorization
+	if err := s.cache.Get(key, &auth); err != nil {
+		if errors.Is(err, cache.ErrCacheNil) {
+			return nil, expired
+		}
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if auth.ClientID != client.ClientID {
+		return nil, oidc.NewError(oidc.ErrCodeInvalidGrant, "device code was issued to another client")
+	}
+	interval := constant.DeviceCodePollInterval
+	polls, err := s.cache.Increment(constant.CacheKeyPrefixDevicePoll+req.DeviceCode, &interval)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if polls > 1 {
+		return nil, oidc.NewError(oidc.ErrCodeSlowDown, "")
+	}
+
+	switch auth.Status {
+	case deviceAuthPending:
+		return nil, oidc.NewError(oidc.ErrCodeAuthorizationPending, "")
+	case deviceAuthDenied:
+		if err := s.cache.Delete(key); err != nil {
+			logger.WithContext(ctx, s.logger).Warn("[AuthSvc] failed to delete denied device code", "error", err)
+		}
+		return nil, oidc.NewError(oidc.ErrCodeAccessDenied, "the user denied the authorization")
+	}
+	// The counter makes redemption atomic: of two concurrent requests only the first sees 1.
+	ttl := constant.DeviceCodeTTL
+	uses, err := s.cache.Increment(key+":used", &ttl)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if uses > 1 {
+		return nil, expired
+	}
+	if err := s.cache.Delete(key); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[AuthSvc] failed to delete used device code", "error", err)
+	}
+
+	user := s.userRepo.FindOneById(ctx, auth.UserID)
+	if user == nil || !user.IsActive() {
+		return nil, oidc.NewError(oidc.ErrCodeInvalidGrant, "the approving account is no longer active")
+	}
+	tokens, err := s.generateTokens(ctx, jwt.Payload{
+		UserID:    user.ID,
+		Email:     user.Email,
+		ProjectID: client.ProjectID,
+		MFA:       auth.MFA,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.updateLastLoginAt(ctx, user.ID); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	resp := &aggregate.OIDCTokenResp{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    s.cfg.Current().Jwt.AccessTokenExpiresIn,
+		RefreshToken: tokens.RefreshToken,
+		Scope:        auth.Scope,
+	}
+	if s.oidcIssuer() != "" && slices.Contains(strings.Fields(auth.Scope), "openid") {
+		if resp.IDToken, err = s.signIDToken(ctx, client, user, auth.Scope, "", tokens.SessionID); err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+	}
+	return resp, nil
+}
+
+// generateUserCode returns userCodeLength random letters of userCodeAlphabet.
+func generateUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	limit := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// formatUserCode splits a user code into two groups for display, e.g. BDFG-HJKL.
+func formatUserCode(code string) string {
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+// normalizeUserCode upper-cases a typed user code and drops everything but its letters.
+func normalizeUserCode(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"gorm.io/datatypes"
+)
+
+// Impersonate signs the calling super admin in as userID for constant.ImpersonationTTL. The token names
+// both identities (the act claim carries the admin), its session has no refresh token, and the
+// impersonation is recorded with req.Reason before the token is returned.
+func (s *AuthSvc) Impersonate(ctx context.Context, userID string, req aggregate.ImpersonateReq) (*aggregate.ImpersonationResp, error) {
+	caller := payloadFromContext(ctx)
+	if caller == nil || !caller.IsSuperAdmin || caller.IsMachine() {
+		return nil, errorx.New(errorx.ErrForbidden, "only super admins can impersonate users")
+	}
+	user := s.userRepo.FindOneById(ctx, userID)
+	if user == nil {
+		return nil, errorx.New(errorx.ErrUserNotFound, errorx.GetErrorMessage(int(errorx.ErrUserNotFound)))
+	}
+	if !user.IsActive() {
+		return nil, errorx.New(errorx.ErrUserInactive, errorx.GetErrorMessage(int(errorx.ErrUserInactive)))
+	}
+	if err := checkProjectNotArchived(ctx, s.projectRepo, req.ProjectID); err != nil {
+		return nil, err
+	}
+
+	sessionID, err := uuid.NewV6()
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	payload := jwt.Payload{
+		UserID:       user.ID,
+		Email:        user.Email,
+		SessionID:    sessionID.String(),
+		ProjectID:    req.ProjectID,
+		Impersonator: &jwt.Impersonator{ID: caller.UserID, Email: caller.Email},
+	}
+	if err := s.embedAuthzClaims(ctx, &payload); err != nil {
+		return nil, err
+	}
+	s.embedAttributeClaims(ctx, &payload)
+	ttl := constant.ImpersonationTTL
+	expiresAt := time.Now().Add(ttl)
+	accessToken, err := s.jwtTokenManager.Generate(ctx, payload, ttl)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	// The refresh token is never handed out: the session cannot outlive its TTL.
+	refreshToken, err := helper.GenerateRefreshToken()
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	requestMeta, _ := json.Marshal(metadataFromContext(ctx))
+	sessionMeta := metadataFromContext(ctx)
+	sessionMeta["impersonator"] = caller.UserID
+	sessionMetaJSON, _ := json.Marshal(sessionMeta)
+	record := model.Impersonation{
+		BaseModel:      model.BaseModel{CreatedBy: caller.UserID, UpdatedBy: caller.UserID, Metadata: datatypes.JSON(requestMeta)},
+		ImpersonatorID: caller.UserID,
+		UserID:         user.ID,
+		ProjectID:      req.ProjectID,
+		SessionID:      payload.SessionID,
+		Reason:         req.Reason,
+		ExpiresAt:      expiresAt,
+	}
+	err = s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := s.sessionRepo.Create(ctx, &model.Session{
+			BaseModel:        model.BaseModel{ID: payload.SessionID, CreatedBy: caller.UserID, UpdatedBy: caller.UserID, Metadata: datatypes.JSON(sessionMetaJSON)},
+			UserID:           user.ID,
+			Email:            user.Email,
+			RefreshTokenHash: helper.HashRefreshToken(refreshToken),
+			ExpiresAt:        expiresAt,
+			ProjectID:        req.ProjectID,
+			IsActive:         true,
+		}); err != nil {
+			return err
+		}
+		_, err := s.impersonationRepo.Create(ctx, &record)
+		return err
+	})
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	logger.WithContext(ctx, s.logger).Warn("[AuthSvc] super admin impersonating user",
+		"impersonator", caller.UserID, "userID", user.ID, "session", payload.SessionID, "reason", req.Reason)
+	publishEvent(ctx, s.events, s.logger, constant.EventUserImpersonated, user.ID, UserImpersonatedEvent{
+		ImpersonationID: record.ID,
+		ImpersonatorID:  caller.UserID,
+		SessionID:       payload.SessionID,
+		ProjectID:       req.ProjectID,
+		Reason:          req.Reason,
+		ExpiresAt:       expiresAt,
+	})
+	return &aggregate.ImpersonationResp{
+		ImpersonationID: record.ID,
+		UserID:          user.ID,
+		SessionID:       payload.SessionID,
+		AccessToken:     accessToken,
+		ExpiresAt:       expiresAt,
+	}, nil
+}
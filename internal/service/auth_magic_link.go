@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/mailer"
+)
+
+// loginWithMagicLink emails a single-use sign-in link. The response is the same whether or not
+// the address has an account, so the endpoint cannot be used to discover users. With enumeration protection
+// the link is also sent in the background, so the response takes as long either way; it is how users who
+// forgot their password sign in.
+func (s *AuthSvc) loginWithMagicLink(ctx context.Context, req aggregate.LoginReq) (*aggregate.LoginResp, error) {
+	if s.cfg.Current().MagicLink.URL == "" {
+		return nil, errorx.New(errorx.ErrBadRequest, "magic link login is not configured")
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == "" {
+		return nil, errorx.New(errorx.ErrBadRequest, "email is required for magic link login")
+	}
+	// The link always opens MAGIC_LINK_URL, but a redirectUrl passed along must still be one the project allows.
+	if req.RedirectURL != "" {
+		if err := s.checkRedirectURL(ctx, req.ProjectID, req.RedirectURL); err != nil {
+			return nil, err
+		}
+	}
+
+	ttl := constant.MagicLinkTTL
+	sends, err := s.cache.Increment(constant.CacheKeyPrefixMagicLinkSend+email, &ttl)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if sends > constant.MagicLinkMaxSends {
+		return nil, errorx.New(errorx.ErrRateLimit, "too many sign-in links requested; try again later")
+	}
+
+	sent := &aggregate.LoginResp{MagicLinkSent: true}
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if user == nil || !user.IsActive() {
+		return sent, nil
+	}
+
+	if s.cfg.Current().AccountEnumeration.Protection {
+		s.sendSignInLinkInBackground(ctx, user, req.ProjectID, "Use this link to sign in.")
+		return sent, nil
+	}
+	if err := s.sendSignInLink(ctx, user, req.ProjectID, "Use this link to sign in."); err != nil {
+		return nil, err
+	}
+	return sent, nil
+}
+
+// sendSignInLinkInBackground is sendSignInLink after the response, so it takes as long whether or not a link is
+// sent; a failure is only logged.
+func (s *AuthSvc) sendSignInLinkInBackground(ctx context.Context, user *model.User, projectID, intro string) {
+	ctx = context.WithoutCancel(ctx)
+	s.workers.Go(func() {
+		if err := s.sendSignInLink(ctx, user, projectID, intro); err != nil {
+			logger.WithContext(ctx, s.logger).Error("[AuthSvc] failed to send magic link in the background", "userID", user.ID, "error", err)
+		}
+	})
+}
+
+// sendSignInLink emails user a single-use link to MAGIC_LINK_URL that signs them in to projectID, introduced
+// by intro.
+func (s *AuthSvc) sendSignInLink(ctx context.Context, user *model.User, projectID, intro string) error {
+	ttl := constant.MagicLinkTTL
+	state := aggregate.MagicLinkState{ID: uuid.NewString(), UserID: user.ID, ProjectID: projectID}
+	token, err := s.stateSealer.Seal(state, ttl)
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if err := s.cache.Set(constant.CacheKeyPrefixMagicLink+state.ID, time.Now(), &ttl); err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	link, err := url.Parse(s.cfg.Current().MagicLink.URL)
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	q := link.Query()
+	q.Set("token", token)
+	link.RawQuery = q.Encode()
+
+	msg, err := s.templateSvc.RenderEmail(ctx, projectID, user.Locale(), mailer.TemplateMagicLink, user.Email, mailer.TemplateData{
+		AppName:   s.cfg.Current().App.Name,
+		Intro:     intro,
+		Link:      link.String(),
+		ExpiresIn: ttl,
+	})
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[AuthSvc] failed to send magic link", "userID", user.ID, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return nil
+}
+
+// VerifyMagicLink consumes an emailed token and signs the user in, subject to MFA like a password login.
+func (s *AuthSvc) VerifyMagicLink(ctx context.Context, req aggregate.VerifyMagicLinkReq) (*aggregate.LoginResp, error) {
+	invalid := errorx.New(errorx.ErrUnauthorized, "invalid or expired sign-in link")
+	var state aggregate.MagicLinkState
+	if err := s.stateSealer.Open(req.Token, &state); err != nil || state.ID == "" {
+		return nil, invalid
+	}
+	key := constant.CacheKeyPrefixMagicLink + state.ID
+	var issuedAt time.Time
+	if err := s.cache.Get(key, &issuedAt); err != nil {
+		return nil, invalid
+	}
+	// The counter makes consumption atomic: of two concurrent clicks only the first sees 1.
+	ttl := constant.MagicLinkTTL
+	uses, err := s.cache.Increment(key+":used", &ttl)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if uses > 1 {
+		return nil, invalid
+	}
+	if err := s.cache.Delete(key); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[AuthSvc] failed to delete used magic link", "error", err)
+	}
+
+	user := s.userRepo.FindOneById(ctx, state.UserID)
+	if user == nil {
+		return nil, invalid
+	}
+	if !user.IsActive() {
+		return nil, errorx.New(errorx.ErrUserInactive, errorx.GetErrorMessage(int(errorx.ErrUserInactive)))
+	}
+	return s.signIn(withLoginMethod(ctx, constant.UserAuthTypeMagicLink), jwt.Payload{
+		UserID:    user.ID,
+		Email:     user.Email,
+		ProjectID: state.ProjectID,
+	}, req.DeviceToken)
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/totp"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"gorm.io/datatypes"
+)
+
+// totpData is the Data payload of a TOTP UserCredential.
+type totpData struct {
+	Secret    string `json:"secret"`
+	Confirmed bool   `json:"confirmed"` // false until the first code is verified
+	LastStep  int64  `json:"lastStep"`  // last accepted time step; codes at or before it are replays
+}
+
+// EnableTOTP starts enrollment: it creates a pending TOTP credential and returns its secret.
+// The credential is only used for login once VerifyTOTP confirms a code from it.
+func (s *AuthSvc) EnableTOTP(ctx context.Context, req aggregate.EnableTOTPReq) (*aggregate.EnableTOTPResp, error) {
+	payload := payloadFromContext(ctx)
+	if payload == nil || payload.IsSuperAdmin {
+		return nil, errorx.New(errorx.ErrForbidden, "TOTP is available to user accounts only")
+	}
+	if s.findTOTP(ctx, payload.UserID, true) != nil {
+		return nil, errorx.New(errorx.ErrConflict, "TOTP is already enabled")
+	}
+	// Restarting enrollment replaces any secret that was never confirmed.
+	if pending := s.findTOTP(ctx, payload.UserID, false); pending != nil {
+		if err := s.credentialRepo.DeleteById(ctx, pending.ID); err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	data, _ := json.Marshal(totpData{Secret: secret})
+	name := req.Name
+	if name == "" {
+		name = "Authenticator app"
+	}
+	credential := &model.UserCredential{
+		UserID: payload.UserID,
+		Type:   constant.CredentialTypeTOTP,
+		Name:   name,
+		Data:   datatypes.JSON(data),
+	}
+	credential.CreatedBy = payload.UserID
+	credential.UpdatedBy = payload.UserID
+	if _, err := s.credentialRepo.Create(ctx, credential); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[AuthSvc] failed to create TOTP credential", "userID", payload.UserID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	issuer := s.cfg.Current().App.Name
+	if issuer == "" {
+		issuer = "dreon-auth"
+	}
+	return &aggregate.EnableTOTPResp{
+		CredentialID: credential.ID,
+		Secret:       secret,
+		OtpauthURL:   totp.URL(issuer, payload.Email, secret),
+	}, nil
+}
+
+// VerifyTOTP completes a login MFA challenge when req.MFAToken is set; otherwise it confirms
+// the authenticated caller's pending enrollment.
+func (s *AuthSvc) VerifyTOTP(ctx context.Context, req aggregate.VerifyTOTPReq) (*aggregate.VerifyTOTPResp, error) {
+	if req.MFAToken != "" {
+		return s.verifyMFAChallenge(ctx, req)
+	}
+
+	payload := payloadFromContext(ctx)
+	if payload == nil {
+		return nil, errorx.New(errorx.ErrUnauthorized, "missing mfaToken")
+	}
+	if s.findTOTP(ctx, payload.UserID, true) != nil {
+		return nil, errorx.New(errorx.ErrConflict, "TOTP is already enabled")
+	}
+	credential := s.findTOTP(ctx, payload.UserID, false)
+	if credential == nil {
+		return nil, errorx.New(errorx.ErrBadRequest, "no pending TOTP enrollment; call enable first")
+	}
+	if err := s.checkTOTPCode(ctx, credential, req.Code, true); err != nil {
+		return nil, err
+	}
+	return &aggregate.VerifyTOTPResp{Enabled: true}, nil
+}
+
+// DisableTOTP removes the caller's TOTP credential and forgets their trusted devices.
+func (s *AuthSvc) DisableTOTP(ctx context.Context, req aggregate.DisableTOTPReq) error {
+	payload := payloadFromContext(ctx)
+	if payload == nil {
+		return errorx.New(errorx.ErrUnauthorized, "missing authentication")
+	}
+	credential := s.findTOTP(ctx, payload.UserID, true)
+	if credential == nil {
+		return errorx.New(errorx.ErrBadRequest, "TOTP is not enabled")
+	}
+	if err := s.checkTOTPCode(ctx, credential, req.Code, false); err != nil {
+		return err
+	}
+	if err := s.credentialRepo.DeleteById(ctx, credential.ID); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[AuthSvc] failed to delete TOTP credential", "userID", payload.UserID, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return s.trustedDeviceSvc.RevokeAllDevices(ctx)
+}
+
+func (s *AuthSvc) verifyMFAChallenge(ctx context.Context, req aggregate.VerifyTOTPReq) (*aggregate.VerifyTOTPResp, error) {
+	var challenge aggregate.MFAChallengeState
+	if err := s.stateSealer.Open(req.MFAToken, &challenge); err != nil || challenge.ID == "" {
+		return nil, errorx.New(errorx.ErrUnauthorized, "invalid or expired mfaToken")
+	}
+	ttl := constant.MFAChallengeTTL
+	attempts, err := s.cache.Increment(constant.CacheKeyPrefixMFAChallenge+challenge.ID+":attempts", &ttl)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Warn("failed to count MFA attempts", "error", err)
+	} else if attempts > constant.MFAMaxAttempts {
+		return nil, errorx.New(errorx.ErrRateLimit, "too many attempts; sign in again")
+	}
+
+	credential := s.findTOTP(ctx, challenge.UserID, true)
+	if credential == nil {
+		return nil, errorx.New(errorx.ErrUnauthorized, "invalid or expired mfaToken")
+	}
+	if err := s.checkTOTPCode(ctx, credential, req.Code, false); err != nil {
+		return nil, err
+	}
+	if used, err := s.cache.Increment(constant.CacheKeyPrefixMFAChallenge+challenge.ID+":used", &ttl); err == nil && used > 1 {
+		return nil, errorx.New(errorx.ErrUnauthorized, "invalid or expired mfaToken")
+	}
+
+	tokenResp, err := s.completeLogin(withLoginMethod(ctx, challenge.LoginMethod), jwt.Payload{
+		UserID:    challenge.UserID,
+		Email:     challenge.Email,
+		ProjectID: challenge.ProjectID,
+		MFA:       true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := &aggregate.VerifyTOTPResp{TokenResp: tokenResp}
+	if req.TrustDevice {
+		device, err := s.trustedDeviceSvc.TrustDevice(ctx, challenge.UserID)
+		if err != nil {
+			return nil, err
+		}
+		resp.DeviceToken = device.DeviceToken
+		resp.DeviceTokenExpiresAt = &device.ExpiresAt
+	}
+	return resp, nil
+}
+
+// mfaChallenge returns the intermediate login response for a user who must still present a TOTP code.
+func (s *AuthSvc) mfaChallenge(ctx context.Context, payload jwt.Payload) (*aggregate.LoginResp, error) {
+	token, err := s.stateSealer.Seal(aggregate.MFAChallengeState{
+		ID:          uuid.NewString(),
+		UserID:      payload.UserID,
+		Email:       payload.Email,
+		ProjectID:   payload.ProjectID,
+		LoginMethod: loginMethodFromContext(ctx),
+	}, constant.MFAChallengeTTL)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return &aggregate.LoginResp{MFARequired: true, MFAToken: token}, nil
+}
+
+// findTOTP returns the user's confirmed (or, with confirmed=false, pending) TOTP credential.
+func (s *AuthSvc) findTOTP(ctx context.Context, userID string, confirmed bool) *model.UserCredential {
+	credentials, err := s.credentialRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[AuthSvc] failed to load credentials", "userID", userID, "error", err)
+		return nil
+	}
+	for i := range credentials {
+		if credentials[i].Type != constant.CredentialTypeTOTP {
+			continue
+		}
+		var data totpData
+		if json.Unmarshal(credentials[i].Data, &data) == nil && data.Confirmed == confirmed {
+			return &credentials[i]
+		}
+	}
+	return nil
+}
+
+// checkTOTPCode validates code against the credential, rejects replays of an already used step and
+// records the use. confirm marks a pending enrollment as confirmed.
+func (s *AuthSvc) checkTOTPCode(ctx context.Context, credential *model.UserCredential, code string, confirm bool) error {
+	var data totpData
+	if err := json.Unmarshal(credential.Data, &data); err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	now := time.Now()
+	step, ok := totp.Validate(data.Secret, code, now)
+	if !ok || step <= data.LastStep {
+		return errorx.New(errorx.ErrUnauthorized, "invalid verification code")
+	}
+
+	data.LastStep = step
+	if confirm {
+		data.Confirmed = true
+	}
+	raw, _ := json.Marshal(data)
+	credential.Data = datatypes.JSON(raw)
+	credential.LastUsedAt = &now
+	if err := s.credentialRepo.Update(ctx, credential.ID, *credential, "data", "last_used_at"); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[AuthSvc] failed to record TOTP use", "id", credential.ID, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return nil
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/internal/shared/oidc"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
+)
+
+const (
+	oidcGrantAuthorizationCode = "authorization_code"
+	oidcGrantRefreshToken      = "refresh_token"
+)
+
+var oidcScopes = []string{"openid", "email", "profile", "phone"}
+
+// oidcAuthCode is cached under an authorization code until the client redeems it.
+type oidcAuthCode struct {
+	ClientID      string `json:"clientId"`
+	RedirectURI   string `json:"redirectUri"`
+	Scope         string `json:"scope"`
+	Nonce         string `json:"nonce"`
+	CodeChallenge string `json:"codeChallenge"`
+	UserID        string `json:"userId"`
+	MFA           bool   `json:"mfa"`
+}
+
+func (s *AuthSvc) oidcIssuer() string {
+	return strings.TrimRight(s.cfg.Current().OIDC.IssuerURL, "/")
+}
+
+func (s *AuthSvc) OIDCDiscovery(ctx context.Context) (*aggregate.OIDCDiscoveryResp, error) {
+	issuer := s.oidcIssuer()
+	if issuer == "" {
+		return nil, errorx.New(errorx.ErrNotFound, "OIDC provider is not configured")
+	}
+	grantTypes := []string{oidcGrantAuthorizationCode, oidcGrantRefreshToken}
+	var deviceEndpoint string
+	if s.cfg.Current().OIDC.DeviceVerificationURL != "" {
+		grantTypes = append(grantTypes, oidcGrantDeviceCode)
+		deviceEndpoint = issuer + "/api/v1/auth/device/code"
+	}
+	return &aggregate.OIDCDiscoveryResp{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/api/v1/oauth2/authorize",
+		TokenEndpoint:                     issuer + "/api/v1/oauth2/token",
+		UserinfoEndpoint:                  issuer + "/api/v1/oauth2/userinfo",
+		JwksURI:                           issuer + "/.well-known/jwks.json",
+		EndSessionEndpoint:                issuer + "/api/v1/auth/end-session",
+		ResponseTypesSupported:            []string{"code"},
+		DeviceAuthorizationEndpoint:       deviceEndpoint,
+		GrantTypesSupported:               grantTypes,
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{jwt.SigningMethodAlg},
+		ScopesSupported:                   oidcScopes,
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "nonce", "sid", "email", "preferred_username", "phone_number"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{oidc.CodeChallengeMethodS256},
+		BackchannelLogoutSupported:        true,
+		BackchannelLogoutSessionSupported: true,
+	}, nil
+}
+
+// OIDCAuthorize validates an authentication request and sends the browser to the login page with the
+// request sealed into ?authRequest=. Requests with an unknown client or redirect_uri fail without a
+// redirect; other errors are reported to the client's redirect_uri.
+func (s *AuthSvc) OIDCAuthorize(ctx context.Context, req aggregate.OIDCAuthorizeReq) (string, error) {
+	if s.oidcIssuer() == "" || s.cfg.Current().OIDC.LoginURL == "" {
+		return "", errorx.New(errorx.ErrBadRequest, "OIDC provider is not configured")
+	}
+	client, ok := s.oidcClients.Get(req.ClientID)
+	if !ok {
+		return "", errorx.New(errorx.ErrBadRequest, "unknown client_id")
+	}
+	if !s.oidcClients.IsRedirectAllowed(req.ClientID, req.RedirectURI) {
+		return "", errorx.New(errorx.ErrBadRequest, "redirect_uri is not registered for this client")
+	}
+	if oauthErr := validateOIDCAuthorizeReq(client, req); oauthErr != nil {
+		return withQuery(req.RedirectURI, url.Values{
+			"error":             {oauthErr.Code},
+			"error_description": {oauthErr.Description},
+			"state":             {req.State},
+		})
+	}
+
+	sealed, err := s.stateSealer.Seal(req, constant.OIDCAuthRequestTTL)
+	if err != nil {
+		return "", errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return withQuery(s.cfg.Current().OIDC.LoginURL, url.Values{"authRequest": {sealed}})
+}
+
+func validateOIDCAuthorizeReq(client oidc.Client, req aggregate.OIDCAuthorizeReq) *oidc.Error {
+	if req.ResponseType != "code" {
+		return oidc.NewError(oidc.ErrCodeUnsupportedResponseType, "only the authorization code flow is supported")
+	}
+	if !slices.Contains(strings.Fields(req.Scope), "openid") {
+		return oidc.NewError(oidc.ErrCodeInvalidScope, "scope must include openid")
+	}
+	if req.CodeChallenge != "" && req.CodeChallengeMethod != oidc.CodeChallengeMethodS256 {
+		return oidc.NewError(oidc.ErrCodeInvalidRequest, "code_challenge_method must be S256")
+	}
+	if client.ClientSecret == "" && req.CodeChallenge == "" {
+		return oidc.NewError(oidc.ErrCodeInvalidRequest, "PKCE is required for public clients")
+	}
+	return nil
+}
+
+// CompleteOIDCAuthorize issues an authorization code for the signed-in caller and returns the client
+// redirect carrying it. The client's project access policy is enforced here, where the request still
+// comes from the user's browser.
+func (s *AuthSvc) CompleteOIDCAuthorize(ctx context.Context, req aggregate.CompleteOIDCAuthorizeReq) (*aggregate.CompleteOIDCAuthorizeResp, error) {
+	caller := payloadFromContext(ctx)
+	if caller == nil {
+		return nil, errorx.Wrap(errorx.ErrUnauthorized, nil)
+	}
+	invalid := errorx.New(errorx.ErrBadRequest, "invalid or expired authorization request")
+	var authReq aggregate.OIDCAuthorizeReq
+	if err := s.stateSealer.Open(req.AuthRequest, &authReq); err != nil {
+		return nil, invalid
+	}
+	client, ok := s.oidcClients.Get(authReq.ClientID)
+	if !ok || !s.oidcClients.IsRedirectAllowed(authReq.ClientID, authReq.RedirectURI) {
+		return nil, invalid
+	}
+	user, err := s.clientSignInUser(ctx, caller, client)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := helper.GenerateRefreshToken()
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	ttl := constant.OIDCAuthCodeTTL
+	if err := s.cache.Set(constant.CacheKeyPrefixOIDCAuthCode+code, oidcAuthCode{
+		ClientID:      authReq.ClientID,
+		RedirectURI:   authReq.RedirectURI,
+		Scope:         authReq.Scope,
+		Nonce:         authReq.Nonce,
+		CodeChallenge: authReq.CodeChallenge,
+		UserID:        user.ID,
+		MFA:           caller.MFA,
+	}, &ttl); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	redirectURL, err := withQuery(authReq.RedirectURI, url.Values{"code": {code}, "state": {authReq.State}})
+	if err != nil {
+		return nil, err
+	}
+	return &aggregate.CompleteOIDCAuthorizeResp{RedirectURL: redirectURL}, nil
+}
+
+// clientSignInUser returns the caller's account if it may sign in to client: super admins and inactive
+// users may not, and the client's project access policy must allow the login.
+func (s *AuthSvc) clientSignInUser(ctx context.Context, caller *jwt.Payload, client oidc.Client) (*model.User, error) {
+	if caller.IsSuperAdmin {
+		return nil, errorx.New(errorx.ErrForbidden, "super admin accounts cannot sign in to OIDC clients")
+	}
+	user := s.userRepo.FindOneById(ctx, caller.UserID)
+	if user == nil {
+		return nil, errorx.New(errorx.ErrUserNotFound, errorx.GetErrorMessage(int(errorx.ErrUserNotFound)))
+	}
+	if !user.IsActive() {
+		return nil, errorx.New(errorx.ErrUserInactive, errorx.GetErrorMessage(int(errorx.ErrUserInactive)))
+	}
+	payload := jwt.Payload{UserID: user.ID, Email: user.Email, ProjectID: client.ProjectID, MFA: caller.MFA}
+	if err := s.accessPolicySvc.Enforce(ctx, client.ProjectID, constant.AccessStageLogin, payload); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// OIDCToken serves the token endpoint. Protocol errors are returned as *oidc.Error.
+func (s *AuthSvc) OIDCToken(ctx context.Context, req aggregate.OIDCTokenReq) (*aggregate.OIDCTokenResp, error) {
+	ctx, span := tracing.Start(ctx, "AuthSvc.OIDCToken")
+	defer span.End()
+	if s.oidcIssuer() == "" {
+		return nil, errorx.New(errorx.ErrNotFound, "OIDC provider is not configured")
+	}
+	client, err := s.authenticateOIDCClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	switch req.GrantType {
+	case oidcGrantAuthorizationCode:
+		return s.redeemOIDCCode(ctx, client, req)
+	case oidcGrantRefreshToken:
+		return s.refreshOIDCTokens(ctx, client, req)
+	case oidcGrantDeviceCode:
+		return s.redeemDeviceCode(ctx, client, req)
+	case "":
+		return nil, oidc.NewError(oidc.ErrCodeInvalidRequest, "grant_type is required")
+	default:
+		return nil, oidc.NewError(oidc.ErrCodeUnsupportedGrantType, "")
+	}
+}
+
+func (s *AuthSvc) authenticateOIDCClient(clientID, clientSecret string) (oidc.Client, error) {
+	client, ok := s.oidcClients.Get(clientID)
+	if !ok {
+		return oidc.Client{}, oidc.NewError(oidc.ErrCodeInvalidClient, "unknown client")
+	}
+	if client.ClientSecret != "" && subtle.ConstantTimeCompare([]byte(clientSecret), []byte(client.ClientSecret)) != 1 {
+		return oidc.Client{}, oidc.NewError(oidc.ErrCodeInvalidClient, "client authentication failed")
+	}
+	return client, nil
+}
+
+func (s *AuthSvc) redeemOIDCCode(ctx context.Context, client oidc.Client, req aggregate.OIDCTokenReq) (*aggregate.OIDCTokenResp, error) {
+	invalid := oidc.NewError(oidc.ErrCodeInvalidGrant, "invalid or expired authorization code")
+	if req.Code == "" {
+		return nil, oidc.NewError(oidc.ErrCodeInvalidRequest, "code is required")
+	}
+	key := constant.CacheKeyPrefixOIDCAuthCode + req.Code
+	var code oidcAuthCode
+	if err := s.cache.Get(key, &code); err != nil {
+		if errors.Is(err, cache.ErrCacheNil) {
+			return nil, invalid
+		}
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	// The counter makes redemption atomic: of two concurrent requests only the first sees 1.
+	ttl := constant.OIDCAuthCodeTTL
+	uses, err := s.cache.Increment(key+":used", &ttl)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if uses > 1 {
+		return nil, invalid
+	}
+	if err := s.cache.Delete(key); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[AuthSvc] failed to delete used authorization code", "error", err)
+	}
+
+	if code.ClientID != client.ClientID || code.RedirectURI != req.RedirectURI {
+		return nil, invalid
+	}
+	if code.CodeChallenge != "" && !oidc.VerifyCodeChallenge(code.CodeChallenge, req.CodeVerifier) {
+		return nil, oidc.NewError(oidc.ErrCodeInvalidGrant, "code_verifier does not match the code_challenge")
+	}
+	user := s.userRepo.FindOneById(ctx, code.UserID)
+	if user == nil || !user.IsActive() {
+		return nil, invalid
+	}
+
+	// The code is redeemed by the client's backend, not the user's device.
+	tokens, err := s.issueTokens(ctx, jwt.Payload{
+		UserID:    user.ID,
+		Email:     user.Email,
+		ProjectID: client.ProjectID,
+		MFA:       code.MFA,
+	}, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.updateLastLoginAt(ctx, user.ID); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	idToken, err := s.signIDToken(ctx, client, user, code.Scope, code.Nonce, tokens.SessionID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return &aggregate.OIDCTokenResp{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    s.cfg.Current().Jwt.AccessTokenExpiresIn,
+		RefreshToken: tokens.RefreshToken,
+		IDToken:      idToken,
+		Scope:        code.Scope,
+	}, nil
+}
+
+// refreshOIDCTokens accepts only refresh tokens of sessions in the client's project.
+func (s *AuthSvc) refreshOIDCTokens(ctx context.Context, client oidc.Client, req aggregate.OIDCTokenReq) (*aggregate.OIDCTokenResp, error) {
+	invalid := oidc.NewError(oidc.ErrCodeInvalidGrant, "invalid or expired refresh token")
+	if req.RefreshToken == "" {
+		return nil, oidc.NewError(oidc.ErrCodeInvalidRequest, "refresh_token is required")
+	}
+	session := s.sessionRepo.FindByRefreshTokenHash(ctx, helper.HashRefreshToken(req.RefreshToken))
+	if session == nil || session.IsSuperAdmin || session.ProjectID != client.ProjectID {
+		return nil, invalid
+	}
+	tokens, err := s.RefreshToken(ctx, aggregate.RefreshTokenReq{RefreshToken: req.RefreshToken})
+	if err != nil {
+		var appErr *errorx.AppError
+		if errors.As(err, &appErr) && appErr.Code != errorx.ErrInternal {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	return &aggregate.OIDCTokenResp{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    s.cfg.Current().Jwt.AccessTokenExpiresIn,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+// signIDToken returns an ID token for client; scope decides which profile claims it carries.
+func (s *AuthSvc) signIDToken(ctx context.Context, client oidc.Client, user *model.User, scope, nonce, sessionID string) (string, error) {
+	now := time.Now()
+	claims := &oidc.IDTokenClaims{
+		RegisteredClaims: gojwt.RegisteredClaims{
+			Issuer:    s.oidcIssuer(),
+			Subject:   user.ID,
+			Audience:  gojwt.ClaimStrings{client.ClientID},
+			IssuedAt:  gojwt.NewNumericDate(now),
+			ExpiresAt: gojwt.NewNumericDate(now.Add(time.Duration(s.cfg.Current().Jwt.AccessTokenExpiresIn) * time.Second)),
+			ID:        uuid.NewString(),
+		},
+		Nonce:     nonce,
+		SessionID: sessionID,
+	}
+	scopes := strings.Fields(scope)
+	if slices.Contains(scopes, "email") {
+		claims.Email = user.Email
+	}
+	if slices.Contains(scopes, "profile") {
+		claims.PreferredUsername = user.Username
+	}
+	if slices.Contains(scopes, "phone") {
+		claims.PhoneNumber = user.Phone
+	}
+	return s.jwtTokenManager.SignClaims(ctx, claims)
+}
+
+// OIDCUserInfo returns the claims about the caller of the userinfo endpoint.
+func (s *AuthSvc) OIDCUserInfo(ctx context.Context) (*aggregate.OIDCUserInfoResp, error) {
+	caller := payloadFromContext(ctx)
+	if caller == nil || caller.IsSuperAdmin {
+		return nil, errorx.Wrap(errorx.ErrUnauthorized, nil)
+	}
+	user := s.userRepo.FindOneById(ctx, caller.UserID)
+	if user == nil {
+		return nil, errorx.New(errorx.ErrUserNotFound, errorx.GetErrorMessage(int(errorx.ErrUserNotFound)))
+	}
+	return &aggregate.OIDCUserInfoResp{
+		Sub:               user.ID,
+		Email:             user.Email,
+		PreferredUsername: user.Username,
+		PhoneNumber:       user.Phone,
+	}, nil
+}
+
+// idTokenHintPayload reads an ID token issued by the OIDC provider endpoints as an end-session hint.
+func (s *AuthSvc) idTokenHintPayload(ctx context.Context, idToken string) (*jwt.Payload, error) {
+	var claims oidc.IDTokenClaims
+	if err := s.jwtTokenManager.VerifyClaims(ctx, idToken, &claims); err != nil {
+		return nil, err
+	}
+	if claims.Issuer == "" || claims.Issuer != s.oidcIssuer() || claims.Subject == "" {
+		return nil, jwt.ErrInvalidToken
+	}
+	return &jwt.Payload{UserID: claims.Subject, SessionID: claims.SessionID}, nil
+}
+
+// withQuery returns rawURL with params added to its query; empty values are left out.
+func withQuery(rawURL string, params url.Values) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", errorx.Wrap(errorx.ErrInternal, err)
+	}
+	q := u.Query()
+	for k, vs := range params {
+		for _, v := range vs {
+			if v != "" {
+				q.Add(k, v)
+			}
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+const phoneOTPDigits = 6
+
+// phoneOTP is the pending code for one phone number. Only the code's hash is cached.
+type phoneOTP struct {
+	ID        string `json:"id"`
+	CodeHash  string `json:"codeHash"`
+	UserID    string `json:"userId"`
+	ProjectID string `json:"projectId,omitempty"`
+}
+
+// loginWithPhoneOTP texts a one-time code to the phone number. The response is the same whether
+// or not the number has an account; sends are throttled per number either way.
+func (s *AuthSvc) loginWithPhoneOTP(ctx context.Context, req aggregate.LoginReq) (*aggregate.LoginResp, error) {
+	phone := strings.TrimSpace(req.Phone)
+	if phone == "" {
+		return nil, errorx.New(errorx.ErrBadRequest, "phone is required for phone OTP login")
+	}
+
+	cooldown := constant.PhoneOTPResendCooldown
+	waits, err := s.cache.Increment(constant.CacheKeyPrefixPhoneOTPWait+phone, &cooldown)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if waits > 1 {
+		return nil, errorx.New(errorx.ErrRateLimit, "a code was sent recently; wait before requesting another")
+	}
+	window := constant.PhoneOTPSendWindow
+	sends, err := s.cache.Increment(constant.CacheKeyPrefixPhoneOTPSend+phone, &window)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if sends > constant.PhoneOTPMaxSends {
+		return nil, errorx.New(errorx.ErrRateLimit, "too many codes requested; try again later")
+	}
+
+	sent := &aggregate.LoginResp{OTPSent: true}
+	user, err := s.userRepo.FindByPhone(ctx, phone)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if user == nil || !user.IsActive() {
+		return sent, nil
+	}
+
+	code, err := helper.GenerateNumericCode(phoneOTPDigits)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	// A resend replaces the previous code and gives the new one a fresh set of attempts.
+	ttl := constant.PhoneOTPTTL
+	pending := phoneOTP{ID: uuid.NewString(), CodeHash: helper.HashRefreshToken(code), UserID: user.ID, ProjectID: req.ProjectID}
+	if err := s.cache.Set(constant.CacheKeyPrefixPhoneOTP+phone, pending, &ttl); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if err := s.cache.Delete(constant.CacheKeyPrefixPhoneOTPTry + phone); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[AuthSvc] failed to reset phone OTP attempts", "error", err)
+	}
+
+	appName := s.cfg.Current().App.Name
+	body, ok := s.templateSvc.RenderSMS(ctx, req.ProjectID, user.Locale(), constant.NotificationPhoneOTP, map[string]string{
+		"appName":          appName,
+		"code":             code,
+		"expiresInMinutes": strconv.Itoa(int(ttl.Minutes())),
+	})
+	if !ok {
+		body = fmt.Sprintf("%s is your %s sign-in code. It expires in %d minutes.", code, appName, int(ttl.Minutes()))
+	}
+	if err := s.sms.Send(ctx, phone, body); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[AuthSvc] failed to send phone OTP", "userID", user.ID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return sent, nil
+}
+
+// VerifyPhoneOTP checks an SMS code and signs the user in, subject to MFA and new-device confirmation like a
+// password login.
+func (s *AuthSvc) VerifyPhoneOTP(ctx context.Context, req aggregate.VerifyPhoneOTPReq) (*aggregate.LoginResp, error) {
+	invalid := errorx.New(errorx.ErrUnauthorized, "invalid or expired code")
+	key := constant.CacheKeyPrefixPhoneOTP + req.Phone
+	var pending phoneOTP
+	if err := s.cache.Get(key, &pending); err != nil {
+		return nil, invalid
+	}
+
+	ttl := constant.PhoneOTPTTL
+	tries, err := s.cache.Increment(constant.CacheKeyPrefixPhoneOTPTry+req.Phone, &ttl)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if tries > constant.PhoneOTPMaxAttempts {
+		if err := s.cache.Delete(key); err != nil {
+			logger.WithContext(ctx, s.logger).Warn("[AuthSvc] failed to delete exhausted phone OTP", "error", err)
+		}
+		return nil, invalid
+	}
+	if subtle.ConstantTimeCompare([]byte(helper.HashRefreshToken(req.Code)), []byte(pending.CodeHash)) != 1 {
+		s.ipFilterSvc.RecordFailure(ctx, constant.RateLimitRouteOTP)
+		return nil, invalid
+	}
+	// The counter makes consumption atomic: of two concurrent requests only the first sees 1.
+	uses, err := s.cache.Increment(constant.CacheKeyPrefixPhoneOTP+pending.ID+":used", &ttl)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if uses > 1 {
+		return nil, invalid
+	}
+	if err := s.cache.Delete(key); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[AuthSvc] failed to delete used phone OTP", "error", err)
+	}
+
+	user := s.userRepo.FindOneById(ctx, pending.UserID)
+	if user == nil || user.Phone != req.Phone {
+		return nil, invalid
+	}
+	if !user.IsActive() {
+		return nil, errorx.New(errorx.ErrUserInactive, errorx.GetErrorMessage(int(errorx.ErrUserInactive)))
+	}
+	if held, err := s.holdSuspiciousLogin(ctx, user, pending.ProjectID); held != nil || err != nil {
+		return held, err
+	}
+	return s.signIn(withLoginMethod(ctx, constant.UserAuthTypePhoneOTP), jwt.Payload{
+		UserID:    user.ID,
+		Email:     user.Email,
+		ProjectID: pending.ProjectID,
+	}, req.DeviceToken)
+}
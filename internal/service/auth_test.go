@@ -0,0 +1,24 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/pkg/password"
+)
+
+func TestNewAuthSvc_MakesDummyHash(t *testing.T) {
+	hasher, err := password.NewHasher(&config.AppConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewAuthSvc(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		nil, nil, nil, hasher, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewAuthSvc err = %v", err)
+	}
+	// Logins of unknown emails compare against it from the first one on, so none is slower than the rest.
+	if s := svc.(*AuthSvc); s.dummyHash == "" || hasher.Compare(s.dummyHash, s.dummyPepperVersion, "password123") == nil {
+		t.Errorf("dummy hash = %q, want one no password matches", s.dummyHash)
+	}
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// IBreakGlassSvc grants sealed emergency super-admin access for when normal sign-in is unavailable.
+//
+// An operator provisions a credential ahead of time and stores the printed secret offline. Activating it
+// (dreonctl break-glass activate) consumes the credential, issues a short-lived super-admin access token,
+// records the mandatory justification and alerts every super admin.
+type IBreakGlassSvc interface {
+	// Provision creates or rotates the credential for label and returns its secret. The secret is never
+	// stored in clear and cannot be recovered later.
+	Provision(ctx context.Context, label string) (string, error)
+	// Activate checks the secret, consumes the credential and issues a time-boxed super-admin session.
+	// Every attempt, including rejected ones, is written to the activation audit log.
+	Activate(ctx context.Context, req aggregate.ActivateBreakGlassReq) (*aggregate.BreakGlassActivationResp, error)
+}
+
+// IBreakGlassAlerter delivers break-glass alerts.
+type IBreakGlassAlerter interface {
+	Alert(ctx context.Context, alert aggregate.BreakGlassAlert) error
+}
+
+type BreakGlassSvc struct {
+	logger         logger.ILogger
+	jwt            jwt.IJwtTokenManager
+	credentialRepo repository.IBreakGlassCredentialRepository
+	activationRepo repository.IBreakGlassActivationRepository
+	sessionRepo    repository.ISessionRepository
+	superAdminRepo repository.ISuperAdminRepository
+	alerter        IBreakGlassAlerter
+	now            func() time.Time
+}
+
+func NewBreakGlassSvc(
+	logger logger.ILogger,
+	jwt jwt.IJwtTokenManager,
+	credentialRepo repository.IBreakGlassCredentialRepository,
+	activationRepo repository.IBreakGlassActivationRepository,
+	sessionRepo repository.ISessionRepository,
+	superAdminRepo repository.ISuperAdminRepository,
+	alerter IBreakGlassAlerter,
+) IBreakGlassSvc {
+	return &BreakGlassSvc{
+		logger:         logger,
+		jwt:            jwt,
+		credentialRepo: credentialRepo,
+		activationRepo: activationRepo,
+		sessionRepo:    sessionRepo,
+		superAdminRepo: superAdminRepo,
+		alerter:        alerter,
+		now:            time.Now,
+	}
+}
+
+func (s *BreakGlassSvc) Provision(ctx context.Context, label string) (string, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return "", errorx.New(errorx.ErrBadRequest, "label is required")
+	}
+	secret, err := helper.GenerateRefreshToken()
+	if err != nil {
+		return "", errorx.Wrap(errorx.ErrInternal, err)
+	}
+	hash, err := helper.HashPassword(secret)
+	if err != nil {
+		return "", errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	existing := s.credentialRepo.FindByLabel(ctx, label)
+	if existing == nil {
+		if _, err := s.credentialRepo.Create(ctx, &model.BreakGlassCredential{Label: label, SecretHash: hash}); err != nil {
+			return "", errorx.Wrap(errorx.ErrInternal, err)
+		}
+	} else {
+		existing.SecretHash = hash
+		existing.UsedAt = nil
+		if err := s.credentialRepo.Update(ctx, existing.ID, *existing, "secret_hash", "used_at"); err != nil {
+			return "", errorx.Wrap(errorx.ErrInternal, err)
+		}
+	}
+	s.logger.Warn("[BreakGlassSvc] credential provisioned", "label", label)
+	return secret, nil
+}
+
+func (s *BreakGlassSvc) Activate(ctx context.Context, req aggregate.ActivateBreakGlassReq) (*aggregate.BreakGlassActivationResp, error) {
+	req.Justification = strings.TrimSpace(req.Justification)
+	if len(req.Justification) < constant.BreakGlassMinJustificationLength {
+		return nil, errorx.New(errorx.ErrBadRequest, fmt.Sprintf("justification must be at least %d characters", constant.BreakGlassMinJustificationLength))
+	}
+	if strings.TrimSpace(req.Operator) == "" {
+		return nil, errorx.New(errorx.ErrBadRequest, "operator is required")
+	}
+	if req.TTL == 0 {
+		req.TTL = constant.BreakGlassDefaultSessionTTL
+	}
+	if req.TTL < 0 || req.TTL > constant.BreakGlassMaxSessionTTL {
+		return nil, errorx.New(errorx.ErrBadRequest, fmt.Sprintf("session TTL must be between 0 and %s", constant.BreakGlassMaxSessionTTL))
+	}
+
+	credential := s.credentialRepo.FindByLabel(ctx, req.Label)
+	if credential == nil {
+		return nil, s.reject(ctx, nil, req, errorx.New(errorx.ErrNotFound, "break-glass credential not found"))
+	}
+	used := errorx.New(errorx.ErrConflict, "break-glass credential was already used; provision a new one")
+	if credential.UsedAt != nil {
+		return nil, s.reject(ctx, credential, req, used)
+	}
+	if helper.ComparePassword(credential.SecretHash, req.Secret) != nil {
+		return nil, s.reject(ctx, credential, req, errorx.New(errorx.ErrUnauthorized, "invalid break-glass secret"))
+	}
+
+	// The credential is consumed with a conditional update, so of two activations racing past the check
+	// above only one gets a session.
+	now := s.now()
+	claimed, err := s.credentialRepo.MarkUsed(ctx, credential.ID, now)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if !claimed {
+		return nil, s.reject(ctx, credential, req, used)
+	}
+
+	expiresAt := now.Add(req.TTL)
+	sessionID, err := uuid.NewV6()
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	payload := jwt.Payload{
+		UserID:       credential.ID,
+		IsSuperAdmin: true,
+		Email:        "break-glass:" + credential.Label,
+		SessionID:    sessionID.String(),
+	}
+	accessToken, err := s.jwt.Generate(ctx, payload, req.TTL)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	// The refresh token is never handed out: the session cannot outlive its TTL.
+	refreshToken, err := helper.GenerateRefreshToken()
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if _, err := s.sessionRepo.Create(ctx, &model.Session{
+		BaseModel:        model.BaseModel{ID: payload.SessionID, CreatedBy: credential.ID, UpdatedBy: credential.ID},
+		UserID:           payload.UserID,
+		Email:            payload.Email,
+		RefreshTokenHash: helper.HashRefreshToken(refreshToken),
+		ExpiresAt:        expiresAt,
+		IsActive:         true,
+		IsSuperAdmin:     true,
+	}); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	activation := &model.BreakGlassActivation{
+		CredentialID:  credential.ID,
+		Label:         credential.Label,
+		Operator:      req.Operator,
+		Justification: req.Justification,
+		Outcome:       constant.BreakGlassOutcomeGranted,
+		SessionID:     payload.SessionID,
+		ExpiresAt:     &expiresAt,
+	}
+	activation.AlertDelivered = s.alertAdmins(ctx, aggregate.BreakGlassAlert{
+		Label:         credential.Label,
+		Operator:      req.Operator,
+		Justification: req.Justification,
+		SessionID:     payload.SessionID,
+		ActivatedAt:   now,
+		ExpiresAt:     expiresAt,
+	})
+	if _, err := s.activationRepo.Create(ctx, activation); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, fmt.Errorf("record activation: %w", err))
+	}
+
+	s.logger.Warn("[BreakGlassSvc] emergency super-admin session granted",
+		"label", credential.Label, "operator", req.Operator, "session", payload.SessionID, "expiresAt", expiresAt)
+	return &aggregate.BreakGlassActivationResp{
+		ActivationID:   activation.ID,
+		SessionID:      payload.SessionID,
+		AccessToken:    accessToken,
+		ExpiresAt:      expiresAt,
+		AlertDelivered: activation.AlertDelivered,
+	}, nil
+}
+
+// reject records a failed attempt and returns cause. Audit failures are logged, never returned, so the caller
+// still sees the real cause.
+func (s *BreakGlassSvc) reject(ctx context.Context, credential *model.BreakGlassCredential, req aggregate.ActivateBreakGlassReq, cause *errorx.AppError) error {
+	record := &model.BreakGlassActivation{
+		Label:         req.Label,
+		Operator:      req.Operator,
+		Justification: req.Justification,
+		Outcome:       constant.BreakGlassOutcomeRejected,
+		Reason:        cause.Message,
+	}
+	if credential != nil {
+		record.CredentialID = credential.ID
+	}
+	record.AlertDelivered = s.alertAdmins(ctx, aggregate.BreakGlassAlert{
+		Label:         req.Label,
+		Operator:      req.Operator,
+		Justification: req.Justification,
+		Rejected:      cause.Message,
+		ActivatedAt:   s.now(),
+	})
+	if _, err := s.activationRepo.Create(ctx, record); err != nil {
+		s.logger.Error("[BreakGlassSvc] failed to record rejected activation", "label", req.Label, "error", err)
+	}
+	s.logger.Warn("[BreakGlassSvc] activation rejected", "label", req.Label, "operator", req.Operator, "reason", cause.Message)
+	return cause
+}
+
+// alertAdmins addresses the alert to every active super admin and reports whether it was delivered.
+func (s *BreakGlassSvc) alertAdmins(ctx context.Context, alert aggregate.BreakGlassAlert) bool {
+	admins, err := s.superAdminRepo.FindAll(ctx)
+	if err != nil {
+		s.logger.Error("[BreakGlassSvc] failed to load super admins for alert", "error", err)
+	}
+	for _, admin := range admins {
+		if admin.IsActive {
+			alert.Recipients = append(alert.Recipients, admin.Email)
+		}
+	}
+	if err := s.alerter.Alert(ctx, alert); err != nil {
+		s.logger.Error("[BreakGlassSvc] failed to alert super admins", "label", alert.Label, "error", err)
+		return false
+	}
+	return true
+}
+
+// BreakGlassWebhookAlerter posts each alert as JSON to a URL, e.g. a chat or mail relay that fans out to
+// Recipients.
+type BreakGlassWebhookAlerter struct {
+	url    string
+	client *http.Client
+}
+
+func NewBreakGlassWebhookAlerter(url string) IBreakGlassAlerter {
+	return &BreakGlassWebhookAlerter{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (a *BreakGlassWebhookAlerter) Alert(ctx context.Context, alert aggregate.BreakGlassAlert) error {
+	if a.url == "" {
+		return errors.New("no alert webhook configured (BREAK_GLASS_ALERT_WEBHOOK_URL)")
+	}
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+)
+
+type recordingAlerter struct {
+	mu     sync.Mutex
+	alerts []aggregate.BreakGlassAlert
+	err    error
+}
+
+func (a *recordingAlerter) Alert(ctx context.Context, alert aggregate.BreakGlassAlert) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.alerts = append(a.alerts, alert)
+	return a.err
+}
+
+type breakGlassFixture struct {
+	svc         IBreakGlassSvc
+	jwt         *testutil.JwtTokenManager
+	activations *testutil.BreakGlassActivationRepository
+	sessions    *testutil.SessionRepository
+	alerter     *recordingAlerter
+}
+
+func newBreakGlassFixture(t *testing.T) *breakGlassFixture {
+	t.Helper()
+	f := &breakGlassFixture{
+		jwt:         testutil.NewJwtTokenManager(),
+		activations: testutil.NewBreakGlassActivationRepository(),
+		sessions:    testutil.NewSessionRepository(),
+		alerter:     &recordingAlerter{},
+	}
+	admins := testutil.NewSuperAdminRepository()
+	for _, a := range []model.SuperAdmin{
+		{Name: "Ops", Email: "ops@example.com", IsActive: true},
+		{Name: "Former", Email: "former@example.com"},
+	} {
+		if _, err := admins.Create(context.Background(), &a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.svc = NewBreakGlassSvc(testutil.NewLogger(), f.jwt, testutil.NewBreakGlassCredentialRepository(), f.activations, f.sessions, admins, f.alerter)
+	return f
+}
+
+const justification = "primary IdP outage, restoring admin access"
+
+func TestBreakGlassSvc_ActivateGrantsTimeBoxedSession(t *testing.T) {
+	ctx := context.Background()
+	f := newBreakGlassFixture(t)
+	secret, err := f.svc.Provision(ctx, "vault-a")
+	if err != nil {
+		t.Fatalf("Provision() err = %v", err)
+	}
+
+	got, err := f.svc.Activate(ctx, aggregate.ActivateBreakGlassReq{Label: "vault-a", Secret: secret, Justification: justification, Operator: "alice@host", TTL: 15 * time.Minute})
+	if err != nil {
+		t.Fatalf("Activate() err = %v", err)
+	}
+
+	payload, err := f.jwt.Verify(ctx, got.AccessToken)
+	if err != nil || !payload.IsSuperAdmin || payload.SessionID != got.SessionID {
+		t.Errorf("access token payload = %+v, err = %v; want super admin for session %s", payload, err, got.SessionID)
+	}
+	session := f.sessions.FindOneById(ctx, got.SessionID)
+	if session == nil || !session.ExpiresAt.Equal(got.ExpiresAt) {
+		t.Errorf("session = %+v, want expiry %s", session, got.ExpiresAt)
+	}
+	if until := time.Until(got.ExpiresAt); until > 15*time.Minute || until < 14*time.Minute {
+		t.Errorf("ExpiresAt in %s, want ~15m", until)
+	}
+
+	if len(f.alerter.alerts) != 1 || len(f.alerter.alerts[0].Recipients) != 1 || f.alerter.alerts[0].Recipients[0] != "ops@example.com" {
+		t.Errorf("alerts = %+v, want one alert to the active super admin", f.alerter.alerts)
+	}
+	audit := f.activations.First(func(a *model.BreakGlassActivation) bool { return a.Outcome == constant.BreakGlassOutcomeGranted })
+	if audit == nil || audit.Justification != justification || !audit.AlertDelivered {
+		t.Errorf("audit record = %+v, want granted activation with justification", audit)
+	}
+
+	if _, err := f.svc.Activate(ctx, aggregate.ActivateBreakGlassReq{Label: "vault-a", Secret: secret, Justification: justification, Operator: "alice@host"}); errorx.GetCode(err) != errorx.ErrConflict {
+		t.Errorf("second Activate() err = %v, want ErrConflict", err)
+	}
+}
+
+func TestBreakGlassSvc_ConcurrentActivationsGrantOneSession(t *testing.T) {
+	ctx := context.Background()
+	f := newBreakGlassFixture(t)
+	secret, err := f.svc.Provision(ctx, "vault-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const attempts = 8
+	var granted, used int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f.svc.Activate(ctx, aggregate.ActivateBreakGlassReq{Label: "vault-a", Secret: secret, Justification: justification, Operator: "alice@host"})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				granted++
+			case errorx.GetCode(err) == errorx.ErrConflict:
+				used++
+			default:
+				t.Errorf("Activate() err = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if granted != 1 || used != attempts-1 {
+		t.Errorf("granted = %d, already used = %d; want 1 and %d", granted, used, attempts-1)
+	}
+	if f.sessions.Len() != 1 {
+		t.Errorf("sessions = %d, want 1", f.sessions.Len())
+	}
+}
+
+func TestBreakGlassSvc_ActivateRejections(t *testing.T) {
+	ctx := context.Background()
+	f := newBreakGlassFixture(t)
+	if _, err := f.svc.Provision(ctx, "vault-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.svc.Activate(ctx, aggregate.ActivateBreakGlassReq{Label: "vault-a", Secret: "x", Justification: "too short", Operator: "bob"}); err == nil {
+		t.Error("Activate() with short justification err = nil")
+	}
+	if _, err := f.svc.Activate(ctx, aggregate.ActivateBreakGlassReq{Label: "vault-a", Secret: "x", Justification: justification, Operator: "bob", TTL: 5 * time.Hour}); err == nil {
+		t.Error("Activate() with TTL over max err = nil")
+	}
+	if _, err := f.svc.Activate(ctx, aggregate.ActivateBreakGlassReq{Label: "vault-a", Secret: "wrong", Justification: justification, Operator: "bob"}); errorx.GetCode(err) != errorx.ErrUnauthorized {
+		t.Errorf("Activate() wrong secret err = %v, want ErrUnauthorized", err)
+	}
+
+	rejected := f.activations.Filter(func(a *model.BreakGlassActivation) bool { return a.Outcome == constant.BreakGlassOutcomeRejected })
+	if len(rejected) != 1 || len(f.alerter.alerts) != 1 || f.alerter.alerts[0].Rejected == "" {
+		t.Errorf("rejected audit = %d, alerts = %+v; want the wrong-secret attempt audited and alerted", len(rejected), f.alerter.alerts)
+	}
+	if f.sessions.Len() != 0 {
+		t.Errorf("sessions = %d, want none", f.sessions.Len())
+	}
+}
+
+func TestBreakGlassSvc_ActivateStillGrantsWhenAlertFails(t *testing.T) {
+	ctx := context.Background()
+	f := newBreakGlassFixture(t)
+	f.alerter.err = errors.New("webhook down")
+	secret, _ := f.svc.Provision(ctx, "vault-a")
+
+	got, err := f.svc.Activate(ctx, aggregate.ActivateBreakGlassReq{Label: "vault-a", Secret: secret, Justification: justification, Operator: "carol"})
+	if err != nil {
+		t.Fatalf("Activate() err = %v", err)
+	}
+	if got.AlertDelivered {
+		t.Error("AlertDelivered = true, want false when the alerter fails")
+	}
+}
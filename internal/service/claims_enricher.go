@@ -0,0 +1,25 @@
+package service
+
+import (
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"go.uber.org/fx"
+)
+
+// ClaimsEnricherGroup is the fx value group the host's jwt.IClaimsEnricher hooks are collected from.
+const ClaimsEnricherGroup = `group:"claims_enrichers"`
+
+// ClaimsEnrichers are the hooks AuthSvc runs before signing an access token, in registration order.
+type ClaimsEnrichers []jwt.IClaimsEnricher
+
+// NewClaimsEnrichers collects the members of ClaimsEnricherGroup; provide it annotated with
+// fx.ParamTags(ClaimsEnricherGroup).
+func NewClaimsEnrichers(enrichers ...jwt.IClaimsEnricher) ClaimsEnrichers {
+	return enrichers
+}
+
+// AsClaimsEnricher registers the result of constructor as a claims hook:
+//
+//	fx.Provide(service.AsClaimsEnricher(NewPlanClaims))
+func AsClaimsEnricher(constructor any) any {
+	return fx.Annotate(constructor, fx.As(new(jwt.IClaimsEnricher)), fx.ResultTags(ClaimsEnricherGroup))
+}
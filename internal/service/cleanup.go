@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/scheduler"
+	"go.uber.org/fx"
+)
+
+// RegisterCleanupJobs schedules the periodic removal of expired relation tuples, expired role assignments,
+// stale sessions, expired token revocations, projects archived past their retention and erased accounts past their audit
+// retention, and runs the scheduler for the app's lifetime. Every replica runs the jobs;
+// the deletes are idempotent, so SCHEDULER_DISABLED only matters for reducing database load.
+func RegisterCleanupJobs(
+	lc fx.Lifecycle,
+	cfg *config.AppConfig,
+	sched scheduler.IScheduler,
+	logger logger.ILogger,
+	relationSvc IRelationSvc,
+	roleSvc IRoleSvc,
+	sessionRepo repository.ISessionRepository,
+	revocationSvc ITokenRevocationSvc,
+	projectSvc IProjectSvc,
+	privacySvc IPrivacySvc,
+) error {
+	if cfg.Scheduler.Disabled {
+		logger.Info("Background cleanup jobs are disabled")
+		return nil
+	}
+	interval := constant.DefaultCleanupInterval
+	if cfg.Scheduler.CleanupIntervalMin > 0 {
+		interval = time.Duration(cfg.Scheduler.CleanupIntervalMin) * time.Minute
+	}
+	retention := constant.DefaultSessionRetention
+	if cfg.Scheduler.SessionRetentionDays > 0 {
+		retention = time.Duration(cfg.Scheduler.SessionRetentionDays) * 24 * time.Hour
+	}
+	projectRetention := constant.DefaultProjectRetention
+	if cfg.Scheduler.ProjectRetentionDays > 0 {
+		projectRetention = time.Duration(cfg.Scheduler.ProjectRetentionDays) * 24 * time.Hour
+	}
+	erasedRetention := constant.DefaultErasedAuditRetention
+	if cfg.Scheduler.ErasedRetentionDays > 0 {
+		erasedRetention = time.Duration(cfg.Scheduler.ErasedRetentionDays) * 24 * time.Hour
+	}
+
+	jobs := []scheduler.Job{
+		{
+			Name:     "cleanup-expired-relations",
+			Interval: interval,
+			Run: func(ctx context.Context) error {
+				_, err := relationSvc.CleanupExpiredRelations(ctx)
+				return err
+			},
+		},
+		{
+			Name:     "cleanup-expired-role-assignments",
+			Interval: interval,
+			Run: func(ctx context.Context) error {
+				_, err := roleSvc.CleanupExpiredAssignments(ctx)
+				return err
+			},
+		},
+		{
+			Name:     "purge-stale-sessions",
+			Interval: interval,
+			Run: func(ctx context.Context) error {
+				count, err := sessionRepo.DeleteStale(ctx, time.Now().Add(-retention))
+				if err != nil {
+					return errorx.Wrap(errorx.ErrInternal, err)
+				}
+				if count > 0 {
+					logger.Info("Purged stale sessions", "count", count)
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "purge-expired-revocations",
+			Interval: interval,
+			Run:      revocationSvc.PurgeExpired,
+		},
+		{
+			Name:     "purge-archived-projects",
+			Interval: interval,
+			Run: func(ctx context.Context) error {
+				count, err := projectSvc.PurgeArchived(ctx, time.Now().Add(-projectRetention))
+				if count > 0 {
+					logger.Info("Purged archived projects", "count", count)
+				}
+				return err
+			},
+		},
+		{
+			Name:     "purge-erased-users",
+			Interval: interval,
+			Run: func(ctx context.Context) error {
+				count, err := privacySvc.PurgeErased(ctx, time.Now().Add(-erasedRetention))
+				if count > 0 {
+					logger.Info("Purged erased users", "count", count)
+				}
+				return err
+			},
+		},
+	}
+	for _, job := range jobs {
+		if err := sched.Add(job); err != nil {
+			return err
+		}
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			sched.Start()
+			return nil
+		},
+		OnStop: sched.Stop,
+	})
+	return nil
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/permission"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"go.uber.org/fx"
+)
+
+// RegisterConfigReload checks the .env file and the permissions file every CONFIG_RELOAD_INTERVAL_SEC, and at
+// once on SIGHUP, and swaps in whatever changed: the configuration through the notifier, so subscribed services
+// pick up new TTLs and limits, and the permission registry, whose new codes are seeded into the catalog.
+func RegisterConfigReload(
+	lc fx.Lifecycle,
+	cfg *config.AppConfig,
+	notifier config.INotifier,
+	registry *permission.Registry,
+	permissionSvc IPermissionSvc,
+	logger logger.ILogger,
+) {
+	if cfg.Reload.Disabled {
+		logger.Info("Configuration reload is disabled")
+		return
+	}
+	interval := constant.DefaultConfigReloadInterval
+	if cfg.Reload.IntervalSec > 0 {
+		interval = time.Duration(cfg.Reload.IntervalSec) * time.Second
+	}
+
+	reloadConfig := func() {
+		changed, err := notifier.Reload()
+		if err != nil {
+			logger.Error("Failed to reload configuration, keeping the current one", "error", err)
+			return
+		}
+		if changed {
+			logger.Info("Reloaded configuration")
+		}
+	}
+	reloadPermissions := func() {
+		changed, err := registry.Reload()
+		if err != nil {
+			logger.Error("Failed to reload permissions file, keeping the current permissions", "path", registry.Path(), "error", err)
+			return
+		}
+		if !changed {
+			return
+		}
+		logger.Info("Reloaded permissions file", "path", registry.Path())
+		if err := permissionSvc.Seed(context.Background()); err != nil {
+			logger.Error("Failed to seed reloaded permissions", "error", err)
+		}
+	}
+
+	stop := make(chan struct{})
+	hup := make(chan os.Signal, 1)
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			signal.Notify(hup, syscall.SIGHUP)
+			envStamp, permissionsStamp := fileStamp(config.EnvFile), fileStamp(registry.Path())
+			go func() {
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ticker.C:
+						// Only reload what changed on disk, so a broken file is reported once, not every tick.
+						if stamp := fileStamp(config.EnvFile); stamp != envStamp {
+							envStamp = stamp
+							reloadConfig()
+						}
+						if stamp := fileStamp(registry.Path()); stamp != permissionsStamp {
+							permissionsStamp = stamp
+							reloadPermissions()
+						}
+					case <-hup:
+						logger.Info("Received SIGHUP, reloading configuration")
+						reloadConfig()
+						reloadPermissions()
+					case <-stop:
+						return
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			signal.Stop(hup)
+			close(stop)
+			return nil
+		},
+	})
+}
+
+// fileStamp identifies the version of the file at path by its size and modification time, or returns "" when
+// there is no file. Comparing stamps also catches files replaced through a symlink, as mounted ConfigMaps are.
+func fileStamp(path string) string {
+	if path == "" {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+}
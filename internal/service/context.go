@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+// payloadFromContext returns the JWT payload set by the HTTP auth middleware, or nil.
+func payloadFromContext(ctx context.Context) *jwt.Payload {
+	p, _ := ctx.Value(constant.JWT_PAYLOAD_CONTEXT_KEY).(*jwt.Payload)
+	return p
+}
+
+// isSuperAdminFromContext reports whether the authenticated caller is a super admin.
+func isSuperAdminFromContext(ctx context.Context) bool {
+	p := payloadFromContext(ctx)
+	return p != nil && p.IsSuperAdmin
+}
+
+// actorFromContext returns who is making a change, as recorded in audit trails: the authenticated user, API key
+// or service account, or empty strings outside of an authenticated request.
+func actorFromContext(ctx context.Context) (id, actorType string) {
+	p := payloadFromContext(ctx)
+	switch {
+	case p == nil:
+		return "", ""
+	case p.APIKeyID != "":
+		return p.APIKeyID, constant.ActorTypeAPIKey
+	case p.ServiceAccountID != "":
+		return p.ServiceAccountID, constant.ActorTypeServiceAccount
+	default:
+		return p.UserID, constant.ActorTypeUser
+	}
+}
+
+// withLoginMethod records how the user is signing in, so the session the login starts says so. Sessions
+// started without one, such as refreshes, are not counted as logins.
+func withLoginMethod(ctx context.Context, method constant.UserAuthType) context.Context {
+	return context.WithValue(ctx, constant.ContextKeyLoginMethod, method)
+}
+
+// loginMethodFromContext returns the method set with withLoginMethod, or "".
+func loginMethodFromContext(ctx context.Context) constant.UserAuthType {
+	method, _ := ctx.Value(constant.ContextKeyLoginMethod).(constant.UserAuthType)
+	return method
+}
+
+// clientIPFromContext returns the client IP set by the HTTP request metadata middleware, or "".
+func clientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(constant.ContextKeyClientIP).(string)
+	return ip
+}
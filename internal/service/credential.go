@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/password"
+)
+
+// ICredentialSvc manages the caller's own passkeys and MFA devices.
+type ICredentialSvc interface {
+	ListCredentials(ctx context.Context) ([]aggregate.CredentialResp, error)
+	RenameCredential(ctx context.Context, id string, req aggregate.RenameCredentialReq) (*aggregate.CredentialResp, error)
+	DeleteCredential(ctx context.Context, id string, req aggregate.DeleteCredentialReq) error
+}
+
+type CredentialSvc struct {
+	logger         logger.ILogger
+	credentialRepo repository.IUserCredentialRepository
+	userRepo       repository.IUserRepository
+	superAdminRepo repository.ISuperAdminRepository
+	hasher         password.IPasswordHasher
+}
+
+func NewCredentialSvc(
+	logger logger.ILogger,
+	credentialRepo repository.IUserCredentialRepository,
+	userRepo repository.IUserRepository,
+	superAdminRepo repository.ISuperAdminRepository,
+	hasher password.IPasswordHasher,
+) ICredentialSvc {
+	return &CredentialSvc{
+		logger:         logger,
+		credentialRepo: credentialRepo,
+		userRepo:       userRepo,
+		superAdminRepo: superAdminRepo,
+		hasher:         hasher,
+	}
+}
+
+func (s *CredentialSvc) ListCredentials(ctx context.Context) ([]aggregate.CredentialResp, error) {
+	payload := payloadFromContext(ctx)
+	if payload == nil {
+		return nil, errorx.New(errorx.ErrUnauthorized, "missing authentication")
+	}
+
+	credentials, err := s.credentialRepo.FindByUserID(ctx, payload.UserID)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[CredentialSvc] failed to list credentials", "userID", payload.UserID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	result := make([]aggregate.CredentialResp, len(credentials))
+	for i := range credentials {
+		result[i].FromModel(&credentials[i])
+	}
+	return result, nil
+}
+
+func (s *CredentialSvc) RenameCredential(ctx context.Context, id string, req aggregate.RenameCredentialReq) (*aggregate.CredentialResp, error) {
+	payload := payloadFromContext(ctx)
+	if payload == nil {
+		return nil, errorx.New(errorx.ErrUnauthorized, "missing authentication")
+	}
+
+	credential := s.credentialRepo.FindByUserIDAndID(ctx, payload.UserID, id)
+	if credential == nil {
+		return nil, errorx.Wrap(errorx.ErrCredentialNotFound, nil)
+	}
+
+	credential.Name = req.Name
+	credential.UpdatedBy = payload.UserID
+	if err := s.credentialRepo.Update(ctx, id, *credential, "name", "updated_by"); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[CredentialSvc] failed to rename credential", "id", id, "error", err)
+		return nil, errorx.Wrap(errorx.ErrUpdateCredential, err)
+	}
+
+	var resp aggregate.CredentialResp
+	resp.FromModel(credential)
+	return &resp, nil
+}
+
+// DeleteCredential removes a credential after re-verifying the caller's password,
+// so a stolen access token alone cannot strip a user's second factor.
+func (s *CredentialSvc) DeleteCredential(ctx context.Context, id string, req aggregate.DeleteCredentialReq) error {
+	payload := payloadFromContext(ctx)
+	if payload == nil {
+		return errorx.New(errorx.ErrUnauthorized, "missing authentication")
+	}
+
+	credential := s.credentialRepo.FindByUserIDAndID(ctx, payload.UserID, id)
+	if credential == nil {
+		return errorx.Wrap(errorx.ErrCredentialNotFound, nil)
+	}
+
+	if err := s.verifyPassword(ctx, payload.UserID, payload.IsSuperAdmin, req.Password); err != nil {
+		return err
+	}
+
+	if err := s.credentialRepo.DeleteById(ctx, id); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[CredentialSvc] failed to delete credential", "id", id, "error", err)
+		return errorx.Wrap(errorx.ErrDeleteCredential, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[CredentialSvc] credential removed", "id", id, "userID", payload.UserID, "type", credential.Type)
+	return nil
+}
+
+// verifyPassword is the step-up check for sensitive credential changes.
+func (s *CredentialSvc) verifyPassword(ctx context.Context, userID string, isSuperAdmin bool, plain string) error {
+	var hashed string
+	var pepperVersion int
+	if isSuperAdmin {
+		admin := s.superAdminRepo.FindOneById(ctx, userID)
+		if admin == nil {
+			return errorx.Wrap(errorx.ErrUserNotFound, nil)
+		}
+		hashed, pepperVersion = admin.Password, admin.PasswordPepperVersion
+	} else {
+		user := s.userRepo.FindOneById(ctx, userID)
+		if user == nil {
+			return errorx.Wrap(errorx.ErrUserNotFound, nil)
+		}
+		hashed, pepperVersion = user.Password, user.PasswordPepperVersion
+	}
+
+	if hashed == "" || s.hasher.Compare(hashed, pepperVersion, plain) != nil {
+		return errorx.New(errorx.ErrForbidden, "step-up authentication failed")
+	}
+	return nil
+}
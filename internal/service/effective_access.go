@@ -0,0 +1,423 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/relationconfig"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// IEffectiveAccessSvc keeps the effective access table: the permission codes each user holds through their
+// role assignments, and the plain subjects holding each object relation through stored tuples and the
+// usersets among them. Relation rows only cover what stored tuples grant on their own: tuples with a
+// condition, and relations with a rewrite rule, are left to the full check.
+//
+// With EFFECTIVE_ACCESS_MODE=off the Refresh methods do nothing.
+type IEffectiveAccessSvc interface {
+	// Serving reports whether checks should read the table (EFFECTIVE_ACCESS_MODE=serve).
+	Serving() bool
+	// UserPermissions returns the unexpired permission rows of a user.
+	UserPermissions(ctx context.Context, userID string) ([]model.EffectiveAccess, error)
+	// UsersPermissions returns the unexpired permission rows of several users; each row's Subject is its user.
+	UsersPermissions(ctx context.Context, userIDs []string) ([]model.EffectiveAccess, error)
+	// HoldsRelation reports whether the table grants subject relation on object. False means the full
+	// check must decide.
+	HoldsRelation(ctx context.Context, namespace, objectID, relation, subjectNamespace, subjectObjectID string) (bool, error)
+	// RefreshUsers recomputes the permissions of users from their role assignments.
+	RefreshUsers(ctx context.Context, userIDs ...string)
+	// RefreshRelations recomputes the subjects of each changed tuple's object relation, and of the object
+	// relations that include it as a userset.
+	RefreshRelations(ctx context.Context, tuples ...model.RelationTuple)
+	// RefreshNamespace recomputes the subjects of every object relation in namespace, after its rewrite
+	// rules changed.
+	RefreshNamespace(ctx context.Context, namespace string)
+	// Rebuild recomputes the whole table from the role assignments and tuples, whatever the mode.
+	Rebuild(ctx context.Context) (*aggregate.EffectiveAccessRebuildResp, error)
+}
+
+type EffectiveAccessSvc struct {
+	logger        logger.ILogger
+	repo          repository.IEffectiveAccessRepository
+	userRepo      repository.IUserRepository
+	userRoleRepo  repository.IUserRoleRepository
+	tupleRepo     repository.IRelationTupleRepository
+	namespaceRepo repository.IRelationNamespaceRepository
+	txManager     repository.ITxManager
+	mode          string
+	maxDepth      int
+}
+
+// effectiveAccessBatchSize is how many users or tuples Rebuild reads per query.
+const effectiveAccessBatchSize = 500
+
+func NewEffectiveAccessSvc(
+	cfg *config.AppConfig,
+	logger logger.ILogger,
+	repo repository.IEffectiveAccessRepository,
+	userRepo repository.IUserRepository,
+	userRoleRepo repository.IUserRoleRepository,
+	tupleRepo repository.IRelationTupleRepository,
+	namespaceRepo repository.IRelationNamespaceRepository,
+	txManager repository.ITxManager,
+) (IEffectiveAccessSvc, error) {
+	mode := cfg.EffectiveAccess.Mode
+	switch mode {
+	case "":
+		mode = constant.EffectiveAccessOff
+	case constant.EffectiveAccessOff, constant.EffectiveAccessMaintain, constant.EffectiveAccessServe:
+	default:
+		return nil, fmt.Errorf("invalid EFFECTIVE_ACCESS_MODE %q: use off, maintain or serve", mode)
+	}
+	maxDepth := cfg.Relations.MaxCheckDepth
+	if maxDepth <= 0 {
+		maxDepth = relationconfig.DefaultMaxDepth
+	}
+	return &EffectiveAccessSvc{
+		logger:        logger,
+		repo:          repo,
+		userRepo:      userRepo,
+		userRoleRepo:  userRoleRepo,
+		tupleRepo:     tupleRepo,
+		namespaceRepo: namespaceRepo,
+		txManager:     txManager,
+		mode:          mode,
+		maxDepth:      maxDepth,
+	}, nil
+}
+
+func (s *EffectiveAccessSvc) Serving() bool {
+	return s.mode == constant.EffectiveAccessServe
+}
+
+func (s *EffectiveAccessSvc) maintained() bool {
+	return s.mode != constant.EffectiveAccessOff
+}
+
+func (s *EffectiveAccessSvc) UserPermissions(ctx context.Context, userID string) ([]model.EffectiveAccess, error) {
+	rows, err := s.repo.FindBySubject(ctx, constant.EffectiveAccessPermission, userID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return rows, nil
+}
+
+func (s *EffectiveAccessSvc) UsersPermissions(ctx context.Context, userIDs []string) ([]model.EffectiveAccess, error) {
+	rows, err := s.repo.FindBySubjects(ctx, constant.EffectiveAccessPermission, userIDs)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return rows, nil
+}
+
+func (s *EffectiveAccessSvc) HoldsRelation(ctx context.Context, namespace, objectID, relation, subjectNamespace, subjectObjectID string) (bool, error) {
+	resource := relationconfig.Userset{Object: relationconfig.Object{Namespace: namespace, ObjectID: objectID}, Relation: relation}
+	return s.repo.Holds(ctx, constant.EffectiveAccessRelation, usersetKey(resource), subjectNamespace+":"+subjectObjectID)
+}
+
+func (s *EffectiveAccessSvc) RefreshUsers(ctx context.Context, userIDs ...string) {
+	if !s.maintained() {
+		return
+	}
+	for _, userID := range userIDs {
+		if _, err := s.refreshUser(ctx, userID); err != nil {
+			logger.WithContext(ctx, s.logger).Error("Failed to refresh effective permissions", "userId", userID, "error", err)
+		}
+	}
+}
+
+// refreshUser replaces the user's permission rows and returns how many were written.
+func (s *EffectiveAccessSvc) refreshUser(ctx context.Context, userID string) (int, error) {
+	var written int
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Lock(ctx, constant.EffectiveAccessPermission+":"+userID); err != nil {
+			return err
+		}
+		rows, err := s.permissionRows(ctx, userID)
+		if err != nil {
+			return err
+		}
+		written = len(rows)
+		return s.repo.ReplaceSubject(ctx, constant.EffectiveAccessPermission, userID, rows)
+	})
+	return written, err
+}
+
+// permissionRows lists the codes of the user's unexpired role assignments, once per project. Codes are kept
+// as the roles list them: readers filter them against the permission catalog, which can change on its own.
+func (s *EffectiveAccessSvc) permissionRows(ctx context.Context, userID string) ([]model.EffectiveAccess, error) {
+	userRoles, err := s.userRoleRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int)
+	var rows []model.EffectiveAccess
+	for _, userRole := range userRoles {
+		for _, code := range model.PermissionsFromJSON(userRole.Role.Permissions) {
+			key := permissionScope(userRole.ProjectID) + "/" + code
+			if i, ok := index[key]; ok {
+				rows[i].ExpiresAt = laterExpiry(rows[i].ExpiresAt, userRole.ExpiresAt)
+				continue
+			}
+			index[key] = len(rows)
+			rows = append(rows, model.EffectiveAccess{
+				Kind:      constant.EffectiveAccessPermission,
+				Subject:   userID,
+				Resource:  code,
+				ProjectID: userRole.ProjectID,
+				ExpiresAt: userRole.ExpiresAt,
+			})
+		}
+	}
+	return rows, nil
+}
+
+func (s *EffectiveAccessSvc) RefreshRelations(ctx context.Context, tuples ...model.RelationTuple) {
+	if !s.maintained() {
+		return
+	}
+	changed := make([]relationconfig.Userset, 0, len(tuples))
+	for _, tuple := range tuples {
+		userset := relationconfig.Userset{Object: relationconfig.Object{Namespace: tuple.Namespace, ObjectID: tuple.ObjectID}, Relation: tuple.Relation}
+		if !slices.Contains(changed, userset) {
+			changed = append(changed, userset)
+		}
+	}
+	s.refreshRelations(ctx, changed)
+}
+
+func (s *EffectiveAccessSvc) RefreshNamespace(ctx context.Context, namespace string) {
+	if !s.maintained() {
+		return
+	}
+	var changed []relationconfig.Userset
+	seen := make(map[relationconfig.Userset]bool)
+	err := s.tupleRepo.FindInBatches(ctx, map[string]interface{}{"namespace": namespace}, effectiveAccessBatchSize, func(batch []model.RelationTuple) error {
+		for _, tuple := range batch {
+			userset := relationconfig.Userset{Object: relationconfig.Object{Namespace: tuple.Namespace, ObjectID: tuple.ObjectID}, Relation: tuple.Relation}
+			if !seen[userset] {
+				seen[userset] = true
+				changed = append(changed, userset)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("Failed to list relations for effective access", "namespace", namespace, "error", err)
+		return
+	}
+	s.refreshRelations(ctx, changed)
+}
+
+// refreshRelations recomputes each changed object relation, then walks up to the object relations holding
+// it as a userset subject, whose subjects include its own, up to the max check depth.
+func (s *EffectiveAccessSvc) refreshRelations(ctx context.Context, changed []relationconfig.Userset) {
+	configs := s.namespaceConfigs()
+	done := make(map[relationconfig.Userset]bool)
+	frontier := changed
+	for depth := 0; depth <= s.maxDepth && len(frontier) > 0; depth++ {
+		var next []relationconfig.Userset
+		for _, userset := range frontier {
+			if done[userset] {
+				continue
+			}
+			done[userset] = true
+			if _, err := s.refreshRelation(ctx, configs, userset); err != nil {
+				logger.WithContext(ctx, s.logger).Error("Failed to refresh effective relation", "relation", usersetKey(userset), "error", err)
+				continue
+			}
+			parents, err := s.tupleRepo.FindBySubject(ctx, userset.Namespace, userset.ObjectID, userset.Relation)
+			if err != nil {
+				logger.WithContext(ctx, s.logger).Error("Failed to list relations including a userset", "userset", usersetKey(userset), "error", err)
+				continue
+			}
+			for _, parent := range parents {
+				next = append(next, relationconfig.Userset{Object: relationconfig.Object{Namespace: parent.Namespace, ObjectID: parent.ObjectID}, Relation: parent.Relation})
+			}
+		}
+		frontier = next
+	}
+}
+
+// refreshRelation replaces the rows of one object relation and returns how many were written.
+func (s *EffectiveAccessSvc) refreshRelation(ctx context.Context, configs relationconfig.ConfigSource, userset relationconfig.Userset) (int, error) {
+	resource := usersetKey(userset)
+	var written int
+	err := s.txManager.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Lock(ctx, constant.EffectiveAccessRelation+":"+resource); err != nil {
+			return err
+		}
+		expiries := make(map[string]*time.Time)
+		if err := s.collectSubjects(ctx, configs, userset, nil, 0, make(map[string]bool), expiries); err != nil {
+			return err
+		}
+		rows := make([]model.EffectiveAccess, 0, len(expiries))
+		for subject, expiresAt := range expiries {
+			rows = append(rows, model.EffectiveAccess{
+				Kind:      constant.EffectiveAccessRelation,
+				Subject:   subject,
+				Resource:  resource,
+				ExpiresAt: expiresAt,
+			})
+		}
+		written = len(rows)
+		return s.repo.ReplaceResource(ctx, constant.EffectiveAccessRelation, resource, rows)
+	})
+	return written, err
+}
+
+// collectSubjects adds the plain subjects of userset to expiries, with the latest time any path to them
+// lasts until. A path is cut at a tuple with a condition, at a relation with a rewrite rule, at a cycle and
+// past the max check depth, which are exactly the cases where the full check could answer differently.
+func (s *EffectiveAccessSvc) collectSubjects(ctx context.Context, configs relationconfig.ConfigSource, userset relationconfig.Userset, expiresAt *time.Time, depth int, path map[string]bool, expiries map[string]*time.Time) error {
+	key := usersetKey(userset)
+	if depth > s.maxDepth || path[key] {
+		return nil
+	}
+	cfg, err := configs(ctx, userset.Namespace)
+	if err != nil {
+		return err
+	}
+	if !cfg.Rewrite(userset.Relation).Direct() {
+		return nil
+	}
+	path[key] = true
+	defer delete(path, key)
+
+	tuples, err := s.tupleRepo.ExpandSubjects(ctx, userset.Namespace, userset.ObjectID, userset.Relation)
+	if err != nil {
+		return err
+	}
+	for _, tuple := range tuples {
+		if tuple.Condition != "" {
+			continue
+		}
+		until := earlierExpiry(expiresAt, tuple.ExpiresAt)
+		if tuple.SubjectRelation == "" {
+			subject := tuple.SubjectNamespace + ":" + tuple.SubjectObjectID
+			if current, ok := expiries[subject]; ok {
+				until = laterExpiry(current, until)
+			}
+			expiries[subject] = until
+			continue
+		}
+		member := relationconfig.Userset{Object: relationconfig.Object{Namespace: tuple.SubjectNamespace, ObjectID: tuple.SubjectObjectID}, Relation: tuple.SubjectRelation}
+		if err := s.collectSubjects(ctx, configs, member, until, depth+1, path, expiries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// namespaceConfigs loads namespace configurations for one refresh, reading each namespace at most once.
+func (s *EffectiveAccessSvc) namespaceConfigs() relationconfig.ConfigSource {
+	loaded := make(map[string]*relationconfig.Config)
+	return func(ctx context.Context, namespace string) (*relationconfig.Config, error) {
+		if cfg, ok := loaded[namespace]; ok {
+			return cfg, nil
+		}
+		var cfg *relationconfig.Config
+		if ns := s.namespaceRepo.FindByName(ctx, namespace); ns != nil {
+			cfg = ns.Rewrites()
+		}
+		loaded[namespace] = cfg
+		return cfg, nil
+	}
+}
+
+// Rebuild rewrites the rows of every user and every object relation, then deletes the rows it did not
+// write, which belonged to users or relations that no longer hold anything. Changes made while it runs
+// write their own rows, so they are kept.
+func (s *EffectiveAccessSvc) Rebuild(ctx context.Context) (*aggregate.EffectiveAccessRebuildResp, error) {
+	started := time.Now()
+	resp := &aggregate.EffectiveAccessRebuildResp{}
+
+	for afterID := ""; ; {
+		users, err := s.userRepo.ListAfter(ctx, afterID, effectiveAccessBatchSize)
+		if err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		for _, user := range users {
+			written, err := s.refreshUser(ctx, user.ID)
+			if err != nil {
+				return nil, errorx.Wrap(errorx.ErrInternal, err)
+			}
+			if written > 0 {
+				resp.Users++
+			}
+		}
+		if len(users) < effectiveAccessBatchSize {
+			break
+		}
+		afterID = users[len(users)-1].ID
+	}
+
+	seen := make(map[relationconfig.Userset]bool)
+	var relations []relationconfig.Userset
+	err := s.tupleRepo.FindInBatches(ctx, map[string]interface{}{}, effectiveAccessBatchSize, func(batch []model.RelationTuple) error {
+		for _, tuple := range batch {
+			userset := relationconfig.Userset{Object: relationconfig.Object{Namespace: tuple.Namespace, ObjectID: tuple.ObjectID}, Relation: tuple.Relation}
+			if !seen[userset] {
+				seen[userset] = true
+				relations = append(relations, userset)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	configs := s.namespaceConfigs()
+	for _, userset := range relations {
+		written, err := s.refreshRelation(ctx, configs, userset)
+		if err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		if written > 0 {
+			resp.Relations++
+		}
+	}
+
+	for _, kind := range []string{constant.EffectiveAccessPermission, constant.EffectiveAccessRelation} {
+		removed, err := s.repo.DeleteCreatedBefore(ctx, kind, started)
+		if err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		resp.Removed += removed
+	}
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Rebuilt effective access: %d users, %d relations, %d stale rows removed", resp.Users, resp.Relations, resp.Removed))
+	return resp, nil
+}
+
+// usersetKey formats namespace:objectID#relation.
+func usersetKey(u relationconfig.Userset) string {
+	return u.Namespace + ":" + u.ObjectID + "#" + u.Relation
+}
+
+// earlierExpiry returns the expiry of a grant that needs both a and b; nil means never.
+func earlierExpiry(a, b *time.Time) *time.Time {
+	if a == nil || b != nil && b.Before(*a) {
+		return b
+	}
+	return a
+}
+
+// laterExpiry returns the expiry of a grant that either a or b gives; nil means never.
+func laterExpiry(a, b *time.Time) *time.Time {
+	if a == nil || b == nil {
+		return nil
+	}
+	if b.After(*a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"go.uber.org/fx"
+)
+
+// SessionEndedEvent is the data of a session.ended event.
+type SessionEndedEvent struct {
+	SessionID string `json:"sessionId"`
+	UserID    string `json:"userId"`
+	ProjectID string `json:"projectId,omitempty"`
+}
+
+// LoginSuspiciousEvent is the data of a login.suspicious event. SessionID is empty when the login was held
+// back until the user confirms it by email.
+type LoginSuspiciousEvent struct {
+	UserID               string   `json:"userId"`
+	SessionID            string   `json:"sessionId,omitempty"`
+	ProjectID            string   `json:"projectId,omitempty"`
+	IP                   string   `json:"ip,omitempty"`
+	Country              string   `json:"country,omitempty"`
+	UserAgent            string   `json:"userAgent,omitempty"`
+	Anomalies            []string `json:"anomalies"`
+	ConfirmationRequired bool     `json:"confirmationRequired,omitempty"`
+}
+
+// UserImpersonatedEvent is the data of a user.impersonated event.
+type UserImpersonatedEvent struct {
+	ImpersonationID string    `json:"impersonationId"`
+	ImpersonatorID  string    `json:"impersonatorId"`
+	SessionID       string    `json:"sessionId"`
+	ProjectID       string    `json:"projectId,omitempty"`
+	Reason          string    `json:"reason"`
+	ExpiresAt       time.Time `json:"expiresAt"`
+}
+
+// IPBannedEvent is the data of an ip.banned event.
+type IPBannedEvent struct {
+	IP        string    `json:"ip"`
+	Failures  int64     `json:"failures"`
+	Route     string    `json:"route"` // of the failure that tipped the IP over the threshold
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// publishEvent emits a domain event after the change it describes was committed.
+// A bus failure is logged rather than returned: the change itself already succeeded.
+func publishEvent(ctx context.Context, publisher eventbus.IPublisher, logger logger.ILogger, eventType, subject string, data any) {
+	if err := publisher.Publish(ctx, eventbus.NewEvent(eventType, subject, data)); err != nil {
+		logger.Error("Failed to publish domain event", "type", eventType, "subject", subject, "error", err)
+	}
+}
+
+// RegisterEventPublisherHooks closes the publisher on shutdown so buffered events are flushed.
+func RegisterEventPublisherHooks(lc fx.Lifecycle, publisher eventbus.IPublisher) {
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			return publisher.Close()
+		},
+	})
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"go.uber.org/fx"
+)
+
+type IHealthSvc interface {
+	// Check pings Postgres and, unless CACHE_DRIVER=memory, Redis concurrently, each within
+	// HEALTH_CHECK_TIMEOUT_MS, and reports ok only when all of them answer.
+	Check(ctx context.Context) *aggregate.HealthResp
+	// Ready is Check gated on the app lifecycle: starting until every start hook has run, stopping once
+	// shutdown begins, so load balancers only route to an instance that can serve.
+	Ready(ctx context.Context) *aggregate.HealthResp
+	// MarkStarted and MarkStopping move the lifecycle state Ready reports; see RegisterHealthHooks.
+	MarkStarted()
+	MarkStopping()
+}
+
+// Lifecycle states of HealthSvc.
+const (
+	healthStarting int32 = iota
+	healthStarted
+	healthStopping
+)
+
+type HealthSvc struct {
+	cfg        *config.AppConfig
+	logger     logger.ILogger
+	cache      cache.ICache
+	healthRepo repository.IHealthRepository
+	timeout    time.Duration
+	state      atomic.Int32
+}
+
+func NewHealthSvc(cfg *config.AppConfig, logger logger.ILogger, cache cache.ICache, healthRepo repository.IHealthRepository) IHealthSvc {
+	timeout := constant.DefaultHealthCheckTimeout
+	if cfg.Health.CheckTimeoutMs > 0 {
+		timeout = time.Duration(cfg.Health.CheckTimeoutMs) * time.Millisecond
+	}
+	return &HealthSvc{
+		cfg:        cfg,
+		logger:     logger,
+		cache:      cache,
+		healthRepo: healthRepo,
+		timeout:    timeout,
+	}
+}
+
+func (s *HealthSvc) Check(ctx context.Context) *aggregate.HealthResp {
+	checks := map[string]func(ctx context.Context) error{
+		constant.HealthCheckPostgres: s.healthRepo.Ping,
+	}
+	if s.cfg.Cache.Driver != cache.DriverMemory {
+		checks[constant.HealthCheckRedis] = func(ctx context.Context) error { return s.cache.WithContext(ctx).Ping() }
+	}
+
+	resp := &aggregate.HealthResp{Status: constant.HealthStatusOK, Checks: make(map[string]aggregate.HealthCheckResp, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := s.run(ctx, check)
+			if result.Error != "" {
+				logger.WithContext(ctx, s.logger).Warn("[HealthSvc] dependency check failed", "dependency", name, "error", result.Error)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Checks[name] = result
+			if result.Status != constant.HealthStatusOK {
+				resp.Status = constant.HealthStatusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+	return resp
+}
+
+func (s *HealthSvc) Ready(ctx context.Context) *aggregate.HealthResp {
+	switch s.state.Load() {
+	case healthStarting:
+		return &aggregate.HealthResp{Status: constant.HealthStatusStarting, Checks: map[string]aggregate.HealthCheckResp{}}
+	case healthStopping:
+		return &aggregate.HealthResp{Status: constant.HealthStatusStopping, Checks: map[string]aggregate.HealthCheckResp{}}
+	}
+	return s.Check(ctx)
+}
+
+func (s *HealthSvc) MarkStarted() {
+	s.state.CompareAndSwap(healthStarting, healthStarted)
+}
+
+func (s *HealthSvc) MarkStopping() {
+	s.state.Store(healthStopping)
+}
+
+// run calls check within the timeout. A check that overruns it is reported unavailable without waiting
+// for it to return.
+func (s *HealthSvc) run(ctx context.Context, check func(ctx context.Context) error) aggregate.HealthCheckResp {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := aggregate.HealthCheckResp{Status: constant.HealthStatusOK, LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = constant.HealthStatusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// RegisterHealthHooks marks the app ready once every other start hook has run and not ready as soon as
+// shutdown begins. Invoke it last: fx runs start hooks in order and stop hooks in reverse.
+func RegisterHealthHooks(lc fx.Lifecycle, svc IHealthSvc) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			svc.MarkStarted()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			svc.MarkStopping()
+			return nil
+		},
+	})
+}
@@ -0,0 +1,359 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// IIPFilterSvc filters clients by network: the IP denylist managed through the API and the automatic IP
+// bans apply to every request, and ADMIN_ALLOWED_CIDRS to super-admin sign-in and the super-admin API.
+type IIPFilterSvc interface {
+	ListBlocks(ctx context.Context) ([]aggregate.IPBlockResp, error)
+	// CreateBlock adds a range to the denylist. It refuses a range that holds the caller's own address.
+	CreateBlock(ctx context.Context, req aggregate.CreateIPBlockReq) (*aggregate.IPBlockResp, error)
+	DeleteBlock(ctx context.Context, id string) error
+	// ListBans returns the IPs banned for failing sign-in too often, the latest to end first.
+	ListBans(ctx context.Context) ([]aggregate.IPBanResp, error)
+	// LiftBan ends the ban of ip before it expires and forgets its failures.
+	LiftBan(ctx context.Context, ip string) error
+	// RecordFailure counts a failed sign-in at route against the client IP, and bans the IP once it reaches
+	// IP_BAN_THRESHOLD within IP_BAN_WINDOW_SEC. Addresses in ADMIN_ALLOWED_CIDRS are never banned.
+	RecordFailure(ctx context.Context, route string)
+	// CheckBlocked returns ErrForbidden when ip is in an active block or banned. When the denylist or the
+	// bans cannot be read the request is allowed.
+	CheckBlocked(ctx context.Context, ip string) error
+	// CheckAdmin returns ErrForbidden when ADMIN_ALLOWED_CIDRS is set and does not contain ip.
+	CheckAdmin(ctx context.Context, ip string) error
+}
+
+type IPFilterSvc struct {
+	cfg        config.INotifier
+	logger     logger.ILogger
+	cache      cache.ICache
+	events     eventbus.IPublisher
+	blockRepo  repository.IIPBlockRepository
+	adminCIDRs atomic.Pointer[[]netip.Prefix]
+}
+
+// ipDenial is the cached form of an IP block.
+type ipDenial struct {
+	CIDR      string     `json:"cidr"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+func NewIPFilterSvc(
+	cfg config.INotifier,
+	logger logger.ILogger,
+	cache cache.ICache,
+	events eventbus.IPublisher,
+	blockRepo repository.IIPBlockRepository,
+) (IIPFilterSvc, error) {
+	adminCIDRs, err := parseCIDRList(cfg.Current().Admin.AllowedCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ADMIN_ALLOWED_CIDRS: %w", err)
+	}
+	s := &IPFilterSvc{
+		cfg:       cfg,
+		logger:    logger,
+		cache:     cache,
+		events:    events,
+		blockRepo: blockRepo,
+	}
+	s.adminCIDRs.Store(&adminCIDRs)
+	cfg.Subscribe(func(next *config.AppConfig) {
+		adminCIDRs, err := parseCIDRList(next.Admin.AllowedCIDRs)
+		if err != nil {
+			logger.Error("Ignoring reloaded ADMIN_ALLOWED_CIDRS, keeping the current ones", "error", err)
+			return
+		}
+		s.adminCIDRs.Store(&adminCIDRs)
+	})
+	return s, nil
+}
+
+func (s *IPFilterSvc) ListBlocks(ctx context.Context) ([]aggregate.IPBlockResp, error) {
+	blocks, err := s.blockRepo.List(ctx)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[IPFilterSvc] failed to list IP blocks", "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	resp := make([]aggregate.IPBlockResp, len(blocks))
+	for i := range blocks {
+		resp[i].FromModel(&blocks[i])
+	}
+	return resp, nil
+}
+
+func (s *IPFilterSvc) CreateBlock(ctx context.Context, req aggregate.CreateIPBlockReq) (*aggregate.IPBlockResp, error) {
+	prefix, err := parseCIDROrIP(req.CIDR)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrBadRequest, err.Error())
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errorx.New(errorx.ErrBadRequest, "expiresAt must be in the future")
+	}
+	if ip, err := netip.ParseAddr(clientIPFromContext(ctx)); err == nil && prefix.Contains(ip.Unmap()) {
+		return nil, errorx.New(errorx.ErrBadRequest, "the block would include your own address")
+	}
+	cidr := prefix.String()
+	if s.blockRepo.FindByCIDR(ctx, cidr) != nil {
+		return nil, errorx.New(errorx.ErrConflict, cidr+" is already blocked")
+	}
+
+	block := &model.IPBlock{CIDR: cidr, Reason: strings.TrimSpace(req.Reason), ExpiresAt: req.ExpiresAt}
+	if p := payloadFromContext(ctx); p != nil {
+		block.CreatedBy = p.UserID
+		block.UpdatedBy = p.UserID
+	}
+	created, err := s.blockRepo.Create(ctx, block)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[IPFilterSvc] failed to create IP block", "cidr", cidr, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	s.clearBlocksCache(ctx)
+	logger.WithContext(ctx, s.logger).Info("[IPFilterSvc] IP range blocked", "cidr", cidr, "expiresAt", req.ExpiresAt, "by", created.CreatedBy)
+
+	var resp aggregate.IPBlockResp
+	resp.FromModel(created)
+	return &resp, nil
+}
+
+func (s *IPFilterSvc) DeleteBlock(ctx context.Context, id string) error {
+	block := s.blockRepo.FindOneById(ctx, id)
+	if block == nil {
+		return errorx.Wrap(errorx.ErrIPBlockNotFound, nil)
+	}
+	if err := s.blockRepo.HardDeleteById(ctx, id); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[IPFilterSvc] failed to delete IP block", "id", id, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	s.clearBlocksCache(ctx)
+	return nil
+}
+
+func (s *IPFilterSvc) ListBans(ctx context.Context) ([]aggregate.IPBanResp, error) {
+	entries, err := s.cache.WithContext(ctx).GetTopN(constant.CacheKeyIPBans, constant.MaxIPBansListed)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[IPFilterSvc] failed to list IP bans", "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	bans := make([]aggregate.IPBanResp, 0, len(entries))
+	for _, entry := range entries {
+		ip, _ := entry.Member.(string)
+		var ban aggregate.IPBanResp
+		if err := s.cache.WithContext(ctx).Get(constant.CacheKeyPrefixIPBan+ip, &ban); err != nil {
+			// The ban expired; drop it from the index as well.
+			if errors.Is(err, cache.ErrCacheNil) {
+				_ = s.cache.WithContext(ctx).RemoveMember(constant.CacheKeyIPBans, ip)
+			}
+			continue
+		}
+		bans = append(bans, ban)
+	}
+	return bans, nil
+}
+
+func (s *IPFilterSvc) LiftBan(ctx context.Context, ip string) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return errorx.New(errorx.ErrBadRequest, fmt.Sprintf("invalid IP %q", ip))
+	}
+	ip = addr.Unmap().String()
+	var ban aggregate.IPBanResp
+	if err := s.cache.WithContext(ctx).Get(constant.CacheKeyPrefixIPBan+ip, &ban); err != nil {
+		if errors.Is(err, cache.ErrCacheNil) {
+			return errorx.Wrap(errorx.ErrIPBanNotFound, nil)
+		}
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	c := s.cache.WithContext(ctx)
+	for _, err := range []error{
+		c.Delete(constant.CacheKeyPrefixIPBan + ip),
+		c.Delete(constant.CacheKeyPrefixIPFailures + ip),
+		c.RemoveMember(constant.CacheKeyIPBans, ip),
+	} {
+		if err != nil {
+			logger.WithContext(ctx, s.logger).Error("[IPFilterSvc] failed to lift IP ban", "ip", ip, "error", err)
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+	}
+	var actorID string
+	if p := payloadFromContext(ctx); p != nil {
+		actorID = p.UserID
+	}
+	logger.WithContext(ctx, s.logger).Info("[IPFilterSvc] IP ban lifted", "ip", ip, "by", actorID)
+	return nil
+}
+
+func (s *IPFilterSvc) RecordFailure(ctx context.Context, route string) {
+	cfg := s.cfg.Current().IPBan
+	addr, err := netip.ParseAddr(clientIPFromContext(ctx))
+	if cfg.Threshold <= 0 || err != nil || s.adminAllowed(addr) {
+		return
+	}
+	ip := addr.Unmap().String()
+	window := constant.DefaultIPBanWindow
+	if cfg.WindowSec > 0 {
+		window = time.Duration(cfg.WindowSec) * time.Second
+	}
+	failures, err := s.cache.WithContext(ctx).Increment(constant.CacheKeyPrefixIPFailures+ip, &window)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[IPFilterSvc] failed to count sign-in failure", "ip", ip, "error", err)
+		return
+	}
+	if failures < int64(cfg.Threshold) {
+		return
+	}
+
+	duration := constant.DefaultIPBanDuration
+	if cfg.DurationSec > 0 {
+		duration = time.Duration(cfg.DurationSec) * time.Second
+	}
+	now := time.Now()
+	ban := aggregate.IPBanResp{IP: ip, Failures: failures, Route: route, BannedAt: now, ExpiresAt: now.Add(duration)}
+	c := s.cache.WithContext(ctx)
+	if err := c.Set(constant.CacheKeyPrefixIPBan+ip, ban, &duration); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[IPFilterSvc] failed to ban IP", "ip", ip, "error", err)
+		return
+	}
+	if err := c.AddScore(constant.CacheKeyIPBans, ip, float64(ban.ExpiresAt.Unix())); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[IPFilterSvc] failed to index IP ban", "ip", ip, "error", err)
+	}
+	// Start the next window from zero, so the IP is banned again only after as many new failures.
+	_ = c.Delete(constant.CacheKeyPrefixIPFailures + ip)
+
+	logger.WithContext(ctx, s.logger).Warn("[IPFilterSvc] IP banned after repeated sign-in failures", "ip", ip, "failures", failures, "route", route, "until", ban.ExpiresAt)
+	publishEvent(ctx, s.events, s.logger, constant.EventIPBanned, ip, IPBannedEvent{
+		IP:        ip,
+		Failures:  failures,
+		Route:     route,
+		ExpiresAt: ban.ExpiresAt,
+	})
+}
+
+func (s *IPFilterSvc) CheckBlocked(ctx context.Context, ip string) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+	var ban aggregate.IPBanResp
+	if err := s.cache.WithContext(ctx).Get(constant.CacheKeyPrefixIPBan+addr.String(), &ban); err == nil {
+		return errorx.New(errorx.ErrForbidden, "too many failed sign-ins from this address; try again later")
+	} else if !errors.Is(err, cache.ErrCacheNil) {
+		logger.WithContext(ctx, s.logger).Warn("[IPFilterSvc] failed to read IP ban, allowing the request", "ip", ip, "error", err)
+	}
+	denylist, err := s.denylist(ctx)
+	if err != nil {
+		// An unreadable denylist must not take the API down with it.
+		logger.WithContext(ctx, s.logger).Warn("[IPFilterSvc] failed to load IP blocks, allowing the request", "error", err)
+		return nil
+	}
+	now := time.Now()
+	for _, d := range denylist {
+		if d.ExpiresAt != nil && !now.Before(*d.ExpiresAt) {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(d.CIDR); err == nil && prefix.Contains(addr) {
+			logger.WithContext(ctx, s.logger).Warn("[IPFilterSvc] request from blocked network", "ip", ip, "cidr", d.CIDR)
+			return errorx.New(errorx.ErrForbidden, "access from this network is blocked")
+		}
+	}
+	return nil
+}
+
+func (s *IPFilterSvc) CheckAdmin(ctx context.Context, ip string) error {
+	if len(*s.adminCIDRs.Load()) == 0 {
+		return nil
+	}
+	if addr, err := netip.ParseAddr(ip); err == nil && s.adminAllowed(addr) {
+		return nil
+	}
+	logger.WithContext(ctx, s.logger).Warn("[IPFilterSvc] super-admin access outside ADMIN_ALLOWED_CIDRS", "ip", ip)
+	return errorx.New(errorx.ErrForbidden, "super-admin access is not allowed from this network")
+}
+
+// adminAllowed reports whether addr is in ADMIN_ALLOWED_CIDRS, which is false when it is empty.
+func (s *IPFilterSvc) adminAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range *s.adminCIDRs.Load() {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// denylist returns the IP blocks from the cache, loading them from the database on a miss.
+func (s *IPFilterSvc) denylist(ctx context.Context) ([]ipDenial, error) {
+	var denylist []ipDenial
+	if err := s.cache.WithContext(ctx).Get(constant.CacheKeyIPBlocks, &denylist); err == nil {
+		return denylist, nil
+	}
+
+	blocks, err := s.blockRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	denylist = make([]ipDenial, len(blocks))
+	for i, b := range blocks {
+		denylist[i] = ipDenial{CIDR: b.CIDR, ExpiresAt: b.ExpiresAt}
+	}
+
+	ttl := constant.CacheDefaultTTL
+	if err := s.cache.WithContext(ctx).Set(constant.CacheKeyIPBlocks, denylist, &ttl); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to cache IP blocks", "error", err)
+	}
+	return denylist, nil
+}
+
+func (s *IPFilterSvc) clearBlocksCache(ctx context.Context) {
+	if err := s.cache.WithContext(ctx).Delete(constant.CacheKeyIPBlocks); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to invalidate IP blocks cache", "error", err)
+	}
+}
+
+// parseCIDROrIP parses a CIDR, or a single address as its /32 or /128, in canonical form.
+func parseCIDROrIP(value string) (netip.Prefix, error) {
+	value = strings.TrimSpace(value)
+	if addr, err := netip.ParseAddr(value); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", value)
+	}
+	return prefix.Masked(), nil
+}
+
+// parseCIDRList parses a comma-separated list of CIDRs and addresses.
+func parseCIDRList(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range strings.Split(list, ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		prefix, err := parseCIDROrIP(value)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
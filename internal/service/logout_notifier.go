@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/oidc"
+	"github.com/hiamthach108/dreon-auth/pkg/background"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// logoutTokenTTL bounds how long a relying party may accept a logout token.
+const logoutTokenTTL = 2 * time.Minute
+
+// backchannelTimeout bounds each back-channel logout request.
+const backchannelTimeout = 5 * time.Second
+
+type ILogoutNotifier interface {
+	// NotifySessionEnded tells every registered client with a back-channel logout URI that the session ended.
+	// Delivery is best-effort and does not block the caller.
+	NotifySessionEnded(session model.Session)
+}
+
+type LogoutNotifier struct {
+	logger          logger.ILogger
+	jwtTokenManager jwt.IJwtTokenManager
+	clients         *oidc.ClientRegistry
+	issuer          string
+	httpClient      *http.Client
+	workers         *background.Group
+}
+
+func NewLogoutNotifier(
+	cfg *config.AppConfig,
+	logger logger.ILogger,
+	jwtTokenManager jwt.IJwtTokenManager,
+	clients *oidc.ClientRegistry,
+	workers *background.Group,
+) ILogoutNotifier {
+	// Logout tokens must carry the issuer of the ID tokens clients received.
+	issuer := strings.TrimRight(cfg.OIDC.IssuerURL, "/")
+	if issuer == "" {
+		issuer = cfg.App.Name
+	}
+	return &LogoutNotifier{
+		logger:          logger,
+		jwtTokenManager: jwtTokenManager,
+		clients:         clients,
+		issuer:          issuer,
+		httpClient:      &http.Client{Timeout: backchannelTimeout},
+		workers:         workers,
+	}
+}
+
+func (n *LogoutNotifier) NotifySessionEnded(session model.Session) {
+	for _, client := range n.clients.List() {
+		if client.BackchannelLogoutURI == "" {
+			continue
+		}
+		n.workers.Go(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), backchannelTimeout)
+			defer cancel()
+			if err := n.send(ctx, client, session); err != nil {
+				n.logger.Warn("Back-channel logout failed", "client", client.ClientID, "session", session.ID, "error", err)
+			}
+		})
+	}
+}
+
+// send posts an OIDC Back-Channel Logout 1.0 logout_token to the client.
+func (n *LogoutNotifier) send(ctx context.Context, client oidc.Client, session model.Session) error {
+	now := time.Now()
+	token, err := n.jwtTokenManager.SignClaims(ctx, gojwt.MapClaims{
+		"iss":    n.issuer,
+		"aud":    client.ClientID,
+		"iat":    now.Unix(),
+		"exp":    now.Add(logoutTokenTTL).Unix(),
+		"jti":    uuid.NewString(),
+		"sub":    session.UserID,
+		"sid":    session.ID,
+		"events": map[string]any{oidc.BackchannelLogoutEvent: map[string]any{}},
+	})
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{"logout_token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.BackchannelLogoutURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
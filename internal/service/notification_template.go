@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/notification"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/mailer"
+)
+
+// INotificationTemplateSvc manages the templates projects use in place of the built-in emails and SMS, and
+// renders notifications with them. A template is looked up for the recipient's locale, then its language
+// alone, then the project's template without a locale.
+type INotificationTemplateSvc interface {
+	List(ctx context.Context, projectID string) ([]aggregate.NotificationTemplateResp, error)
+	// Upsert creates or replaces the project's template for the request's notification and locale.
+	Upsert(ctx context.Context, projectID string, req aggregate.UpsertNotificationTemplateReq) (*aggregate.NotificationTemplateResp, error)
+	// Delete removes a template; the notification falls back to a less specific locale or the built-in one.
+	Delete(ctx context.Context, projectID, id string) error
+	// RenderEmail builds the email tmpl for to, from the project's template when it has one. It is sent from
+	// the project's mailFrom, if set.
+	RenderEmail(ctx context.Context, projectID, locale string, tmpl mailer.Template, to string, data mailer.TemplateData) (mailer.Message, error)
+	// RenderSMS returns the text of the named SMS from the project's template, or false when it has none.
+	RenderSMS(ctx context.Context, projectID, locale, name string, vars map[string]string) (string, bool)
+}
+
+type NotificationTemplateSvc struct {
+	logger      logger.ILogger
+	repo        repository.INotificationTemplateRepository
+	projectRepo repository.IProjectRepository
+}
+
+func NewNotificationTemplateSvc(
+	logger logger.ILogger,
+	repo repository.INotificationTemplateRepository,
+	projectRepo repository.IProjectRepository,
+) INotificationTemplateSvc {
+	return &NotificationTemplateSvc{
+		logger:      logger,
+		repo:        repo,
+		projectRepo: projectRepo,
+	}
+}
+
+func (s *NotificationTemplateSvc) List(ctx context.Context, projectID string) ([]aggregate.NotificationTemplateResp, error) {
+	if s.projectRepo.FindOneById(ctx, projectID) == nil {
+		return nil, errorx.Wrap(errorx.ErrProjectNotFound, nil)
+	}
+	templates, err := s.repo.FindByProjectID(ctx, projectID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	resp := make([]aggregate.NotificationTemplateResp, len(templates))
+	for i := range templates {
+		resp[i].FromModel(&templates[i])
+	}
+	return resp, nil
+}
+
+func (s *NotificationTemplateSvc) Upsert(ctx context.Context, projectID string, req aggregate.UpsertNotificationTemplateReq) (*aggregate.NotificationTemplateResp, error) {
+	if s.projectRepo.FindOneById(ctx, projectID) == nil {
+		return nil, errorx.Wrap(errorx.ErrProjectNotFound, nil)
+	}
+	if !notification.Supports(req.Channel, req.Name) {
+		return nil, errorx.New(errorx.ErrBadRequest, "unknown "+string(req.Channel)+" notification "+strconv.Quote(req.Name))
+	}
+	switch req.Channel {
+	case constant.NotificationChannelEmail:
+		if strings.TrimSpace(req.Subject) == "" {
+			return nil, errorx.New(errorx.ErrBadRequest, "subject is required for email templates")
+		}
+	case constant.NotificationChannelSMS:
+		if req.Subject != "" || req.HTML != "" {
+			return nil, errorx.New(errorx.ErrBadRequest, "SMS templates have no subject or html")
+		}
+	}
+	if err := notification.Check(req.Channel, req.Name, req.Subject, req.Text, req.HTML); err != nil {
+		return nil, errorx.New(errorx.ErrBadRequest, err.Error())
+	}
+
+	var actorID string
+	if p := payloadFromContext(ctx); p != nil {
+		actorID = p.UserID
+	}
+	locale := notification.NormalizeLocale(req.Locale)
+	tmpl := s.repo.FindOne(ctx, projectID, req.Channel, req.Name, locale)
+	if tmpl == nil {
+		tmpl = &model.NotificationTemplate{ProjectID: projectID, Channel: req.Channel, Name: req.Name, Locale: locale}
+		tmpl.CreatedBy = actorID
+	}
+	tmpl.Subject = req.Subject
+	tmpl.Text = req.Text
+	tmpl.HTML = req.HTML
+	tmpl.UpdatedBy = actorID
+
+	if tmpl.ID == "" {
+		created, err := s.repo.Create(ctx, tmpl)
+		if err != nil {
+			logger.WithContext(ctx, s.logger).Error("[NotificationTemplateSvc] failed to create template", "projectID", projectID, "name", req.Name, "error", err)
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		tmpl = created
+	} else if err := s.repo.Update(ctx, tmpl.ID, *tmpl, "subject", "text", "html", "updated_by"); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[NotificationTemplateSvc] failed to update template", "projectID", projectID, "name", req.Name, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	var resp aggregate.NotificationTemplateResp
+	resp.FromModel(tmpl)
+	return &resp, nil
+}
+
+func (s *NotificationTemplateSvc) Delete(ctx context.Context, projectID, id string) error {
+	tmpl := s.repo.FindOneById(ctx, id)
+	if tmpl == nil || tmpl.ProjectID != projectID {
+		return errorx.Wrap(errorx.ErrTemplateNotFound, nil)
+	}
+	if err := s.repo.DeleteByID(ctx, id); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[NotificationTemplateSvc] failed to delete template", "projectID", projectID, "id", id, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return nil
+}
+
+func (s *NotificationTemplateSvc) RenderEmail(ctx context.Context, projectID, locale string, tmpl mailer.Template, to string, data mailer.TemplateData) (mailer.Message, error) {
+	var from string
+	if projectID != "" {
+		if project := s.projectRepo.FindOneById(ctx, projectID); project != nil {
+			from = project.MailFrom
+		}
+	}
+
+	custom := s.find(ctx, projectID, constant.NotificationChannelEmail, string(tmpl), locale)
+	if custom == nil {
+		msg, err := mailer.Render(to, tmpl, data)
+		msg.From = from
+		return msg, err
+	}
+	vars := map[string]string{
+		"appName":  data.AppName,
+		"email":    to,
+		"intro":    data.Intro,
+		"link":     data.Link,
+		"event":    data.Event,
+		"device":   data.Device,
+		"ip":       data.IP,
+		"location": data.Location,
+	}
+	if data.ExpiresIn > 0 {
+		vars["expiresInMinutes"] = strconv.Itoa(int(data.ExpiresIn / time.Minute))
+	}
+	if !data.Time.IsZero() {
+		vars["time"] = data.Time.UTC().Format("2006-01-02 15:04 MST")
+	}
+	msg := mailer.Message{
+		From: from,
+		To:   to,
+		// Line breaks in the values would otherwise make the subject an invalid header.
+		Subject: strings.Join(strings.Fields(notification.Interpolate(custom.Subject, vars, false)), " "),
+		Text:    notification.Interpolate(custom.Text, vars, false),
+	}
+	if custom.HTML != "" {
+		msg.HTML = notification.Interpolate(custom.HTML, vars, true)
+	}
+	return msg, nil
+}
+
+func (s *NotificationTemplateSvc) RenderSMS(ctx context.Context, projectID, locale, name string, vars map[string]string) (string, bool) {
+	custom := s.find(ctx, projectID, constant.NotificationChannelSMS, name, locale)
+	if custom == nil {
+		return "", false
+	}
+	return notification.Interpolate(custom.Text, vars, false), true
+}
+
+// find returns the project's template for the notification in the closest locale, or nil. Lookup errors
+// are treated as no template, so a notification is never lost over its branding.
+func (s *NotificationTemplateSvc) find(ctx context.Context, projectID string, channel constant.NotificationChannel, name, locale string) *model.NotificationTemplate {
+	if projectID == "" {
+		return nil
+	}
+	for _, candidate := range notification.LocaleFallbacks(locale) {
+		if tmpl := s.repo.FindOne(ctx, projectID, channel, name, candidate); tmpl != nil {
+			return tmpl
+		}
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"golang.org/x/oauth2"
+)
+
+// OIDCProviderConfig is one upstream OpenID Connect (or plain OAuth2) issuer users can sign in with.
+type OIDCProviderConfig struct {
+	Key             string   `json:"key"` // referenced by LoginReq.Provider and the callback path
+	Issuer          string   `json:"issuer"`
+	ClientID        string   `json:"clientId"`
+	ClientSecret    string   `json:"clientSecret"`
+	ClientSecretEnv string   `json:"clientSecretEnv"` // read the secret from this env var instead of the file
+	Scopes          []string `json:"scopes"`          // defaults to openid, email, profile
+	RedirectURL     string   `json:"redirectUrl"`     // must point at /api/v1/auth/oidc/{key}/callback
+	GroupsClaim     string   `json:"groupsClaim"`     // userinfo claim with group membership, defaults to "groups"
+
+	// Endpoint overrides; when all three are set the issuer is not asked for its discovery document.
+	AuthURL     string `json:"authUrl"`
+	TokenURL    string `json:"tokenUrl"`
+	UserInfoURL string `json:"userInfoUrl"`
+}
+
+// IOAuthProvider is an external identity provider driving the sealed-state OAuth login flow.
+type IOAuthProvider interface {
+	Key() string
+	AuthCodeURL(ctx context.Context, state string) (string, error)
+	Exchange(ctx context.Context, code string) (*aggregate.OAuthUserData, error)
+}
+
+// IOAuthProviderRegistry looks up configured providers by key
+type IOAuthProviderRegistry interface {
+	Get(key string) (IOAuthProvider, bool)
+}
+
+// OAuthProviderRegistry holds configured providers keyed by provider key
+type OAuthProviderRegistry struct {
+	byKey map[string]IOAuthProvider
+}
+
+// NewOAuthProviderRegistry returns a registry serving the given providers
+func NewOAuthProviderRegistry(providers ...IOAuthProvider) *OAuthProviderRegistry {
+	byKey := make(map[string]IOAuthProvider, len(providers))
+	for _, p := range providers {
+		byKey[p.Key()] = p
+	}
+	return &OAuthProviderRegistry{byKey: byKey}
+}
+
+// Get returns the provider registered under key
+func (r *OAuthProviderRegistry) Get(key string) (IOAuthProvider, bool) {
+	if r == nil {
+		return nil, false
+	}
+	p, ok := r.byKey[key]
+	return p, ok
+}
+
+const defaultOIDCProvidersPath = "config/oidc_providers.json"
+
+// NewOAuthProviderRegistryFromConfig loads OIDC providers from AppConfig.OIDC.ProvidersFile (env: OIDC_PROVIDERS_FILE),
+// or default config/oidc_providers.json. A missing default file yields an empty registry.
+func NewOAuthProviderRegistryFromConfig(cfg *config.AppConfig) (IOAuthProviderRegistry, error) {
+	path := cfg.OIDC.ProvidersFile
+	if path == "" {
+		path = defaultOIDCProvidersPath
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return NewOAuthProviderRegistry(), nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read oidc providers config: %w", err)
+	}
+	var list []OIDCProviderConfig
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parse oidc providers config: %w", err)
+	}
+
+	providers := make([]IOAuthProvider, 0, len(list))
+	seen := make(map[string]bool, len(list))
+	for _, c := range list {
+		if c.Key == "" || c.ClientID == "" || c.RedirectURL == "" {
+			return nil, fmt.Errorf("oidc provider %q: key, clientId and redirectUrl are required", c.Key)
+		}
+		if c.Issuer == "" && (c.AuthURL == "" || c.TokenURL == "" || c.UserInfoURL == "") {
+			return nil, fmt.Errorf("oidc provider %q: issuer or all endpoint overrides are required", c.Key)
+		}
+		if seen[c.Key] {
+			return nil, fmt.Errorf("oidc provider %q: duplicate key", c.Key)
+		}
+		seen[c.Key] = true
+		if c.ClientSecret == "" && c.ClientSecretEnv != "" {
+			c.ClientSecret = os.Getenv(c.ClientSecretEnv)
+		}
+		providers = append(providers, newOIDCProvider(c))
+	}
+	return NewOAuthProviderRegistry(providers...), nil
+}
+
+// oidcProvider signs users in through a generic OIDC issuer. Endpoints come from the issuer's
+// discovery document, fetched on first use so a down issuer does not block startup.
+type oidcProvider struct {
+	cfg OIDCProviderConfig
+
+	mu          sync.Mutex
+	oauth2      *oauth2.Config
+	userInfoURL string
+}
+
+func newOIDCProvider(cfg OIDCProviderConfig) *oidcProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	return &oidcProvider{cfg: cfg}
+}
+
+func (p *oidcProvider) Key() string {
+	return p.cfg.Key
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state string) (string, error) {
+	conf, _, err := p.endpoints(ctx)
+	if err != nil {
+		return "", err
+	}
+	return conf.AuthCodeURL(state), nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code string) (*aggregate.OAuthUserData, error) {
+	conf, userInfoURL, err := p.endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := conf.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("%s token exchange: %w", p.cfg.Key, err)
+	}
+	claims, err := fetchJSON(ctx, userInfoURL, token.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s userinfo: %w", p.cfg.Key, err)
+	}
+
+	sub, _ := claims["sub"].(string)
+	email, _ := claims["email"].(string)
+	if sub == "" || email == "" {
+		return nil, fmt.Errorf("%s userinfo: sub and email claims are required", p.cfg.Key)
+	}
+	// Accounts are linked by email, so an address the issuer has not verified must not sign in.
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return nil, fmt.Errorf("%s userinfo: email %s is not verified", p.cfg.Key, email)
+	}
+	name, _ := claims["name"].(string)
+	return &aggregate.OAuthUserData{
+		Email:      email,
+		Name:       name,
+		ProviderID: sub,
+		Groups:     stringClaims(claims[p.cfg.GroupsClaim]),
+	}, nil
+}
+
+// endpoints returns the OAuth2 config and userinfo URL, running discovery once it succeeds.
+func (p *oidcProvider) endpoints(ctx context.Context) (*oauth2.Config, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth2 != nil {
+		return p.oauth2, p.userInfoURL, nil
+	}
+
+	authURL, tokenURL, userInfoURL := p.cfg.AuthURL, p.cfg.TokenURL, p.cfg.UserInfoURL
+	if authURL == "" || tokenURL == "" || userInfoURL == "" {
+		doc, err := fetchJSON(ctx, strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", "")
+		if err != nil {
+			return nil, "", fmt.Errorf("%s discovery: %w", p.cfg.Key, err)
+		}
+		if issuer, _ := doc["issuer"].(string); strings.TrimSuffix(issuer, "/") != strings.TrimSuffix(p.cfg.Issuer, "/") {
+			return nil, "", fmt.Errorf("%s discovery: issuer mismatch %q", p.cfg.Key, issuer)
+		}
+		if authURL == "" {
+			authURL, _ = doc["authorization_endpoint"].(string)
+		}
+		if tokenURL == "" {
+			tokenURL, _ = doc["token_endpoint"].(string)
+		}
+		if userInfoURL == "" {
+			userInfoURL, _ = doc["userinfo_endpoint"].(string)
+		}
+		if authURL == "" || tokenURL == "" || userInfoURL == "" {
+			return nil, "", fmt.Errorf("%s discovery: missing authorization, token or userinfo endpoint", p.cfg.Key)
+		}
+	}
+
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint:     oauth2.Endpoint{AuthURL: authURL, TokenURL: tokenURL},
+	}
+	p.userInfoURL = userInfoURL
+	return p.oauth2, p.userInfoURL, nil
+}
+
+func fetchJSON(ctx context.Context, rawURL, accessToken string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d", rawURL, resp.StatusCode)
+	}
+	var out map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// stringClaims accepts a claim sent either as a list of strings or a single string.
+func stringClaims(v any) []string {
+	switch c := v.(type) {
+	case string:
+		if c == "" {
+			return nil
+		}
+		return []string{c}
+	case []any:
+		out := make([]string, 0, len(c))
+		for _, item := range c {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+)
+
+// pageQuery is a PaginationReq resolved against the defaults: the page to read from the repository and the
+// page size the response is trimmed to.
+type pageQuery struct {
+	repo     repository.Page
+	page     int
+	pageSize int
+}
+
+// newPageQuery resolves req: pageSize defaults to 10 and is capped at 100, and a cursor takes precedence over
+// the page number. One row more than pageSize is read so the response knows whether a next page exists.
+func newPageQuery(req aggregate.PaginationReq) (pageQuery, error) {
+	q := pageQuery{page: req.Page, pageSize: req.PageSize}
+	if q.pageSize <= 0 {
+		q.pageSize = 10
+	}
+	if q.pageSize > 100 {
+		q.pageSize = 100
+	}
+	q.repo.Limit = q.pageSize + 1
+
+	if req.Cursor != nil && *req.Cursor != "" {
+		cursor, err := repository.DecodeCursor(*req.Cursor)
+		if err != nil {
+			return q, errorx.Wrap(errorx.ErrBadRequest, err)
+		}
+		q.repo.Cursor = cursor
+		q.page = 0
+		return q, nil
+	}
+	if q.page <= 0 {
+		q.page = 1
+	}
+	q.repo.Offset = (q.page - 1) * q.pageSize
+	return q, nil
+}
+
+// pageResp builds the response for rows read with q. Total and Page are only known when paging by number;
+// NextCursor is set whenever there is a next page, so a client may start by page and continue by cursor.
+func pageResp[M any, T any](q pageQuery, rows []M, total int64, base func(*M) *model.BaseModel, convert func(*M) T) *aggregate.PaginationResp[T] {
+	resp := &aggregate.PaginationResp[T]{
+		Total:    total,
+		Page:     q.page,
+		PageSize: q.pageSize,
+		HasNext:  len(rows) > q.pageSize,
+	}
+	if resp.HasNext {
+		rows = rows[:q.pageSize]
+		resp.NextCursor = repository.EncodeCursor(repository.CursorAfter(base(&rows[len(rows)-1])))
+	}
+	resp.Items = make([]T, 0, len(rows))
+	for i := range rows {
+		resp.Items = append(resp.Items, convert(&rows[i]))
+	}
+	return resp
+}
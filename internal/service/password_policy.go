@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/breach"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// IPasswordPolicySvc checks a password a user is about to set against the breached-password policy of the
+// project they set it for (PASSWORD_BREACH_POLICY outside a project, or when the project has none).
+type IPasswordPolicySvc interface {
+	// CheckNew returns ErrPasswordBreached when the policy rejects password, and the warnings to show the
+	// user when it only warns. A breach check that cannot be answered lets the password through.
+	CheckNew(ctx context.Context, projectID, password string) (warnings []string, err error)
+}
+
+type PasswordPolicySvc struct {
+	logger      logger.ILogger
+	checker     breach.IChecker
+	projectRepo repository.IProjectRepository
+	policy      constant.BreachedPasswordPolicy
+}
+
+func NewPasswordPolicySvc(
+	cfg *config.AppConfig,
+	logger logger.ILogger,
+	checker breach.IChecker,
+	projectRepo repository.IProjectRepository,
+) (IPasswordPolicySvc, error) {
+	policy := constant.BreachedPasswordPolicy(cfg.Password.BreachPolicy)
+	switch policy {
+	case "":
+		policy = constant.BreachedPasswordOff
+	case constant.BreachedPasswordOff, constant.BreachedPasswordWarn, constant.BreachedPasswordReject:
+	default:
+		return nil, fmt.Errorf("PASSWORD_BREACH_POLICY must be off, warn or reject, not %q", policy)
+	}
+	return &PasswordPolicySvc{
+		logger:      logger,
+		checker:     checker,
+		projectRepo: projectRepo,
+		policy:      policy,
+	}, nil
+}
+
+func (s *PasswordPolicySvc) CheckNew(ctx context.Context, projectID, password string) ([]string, error) {
+	policy := s.policy
+	if projectID != "" {
+		if project := s.projectRepo.FindOneById(ctx, projectID); project != nil && project.BreachedPasswordPolicy != "" {
+			policy = project.BreachedPasswordPolicy
+		}
+	}
+	if policy == constant.BreachedPasswordOff {
+		return nil, nil
+	}
+
+	breached, err := s.checker.IsBreached(ctx, password)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[PasswordPolicySvc] breach check unavailable, accepting password", "error", err)
+		return nil, nil
+	}
+	if !breached {
+		return nil, nil
+	}
+	if policy == constant.BreachedPasswordReject {
+		return nil, errorx.New(errorx.ErrPasswordBreached, errorx.GetErrorMessage(int(errorx.ErrPasswordBreached)))
+	}
+	return []string{"This password appears in a known data breach; consider changing it"}, nil
+}
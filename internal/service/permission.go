@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/permission"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"go.uber.org/fx"
+)
+
+type IPermissionSvc interface {
+	// Catalog CRUD. A nil projectID addresses the system permissions, otherwise the codes the project defines.
+	ListPermissions(ctx context.Context, projectID *string) ([]aggregate.PermissionResp, error)
+	GetPermission(ctx context.Context, projectID *string, code string) (*aggregate.PermissionResp, error)
+	CreatePermission(ctx context.Context, projectID *string, req aggregate.CreatePermissionReq) (*aggregate.PermissionResp, error)
+	UpdatePermission(ctx context.Context, projectID *string, code string, req aggregate.UpdatePermissionReq) (*aggregate.PermissionResp, error)
+	DeletePermission(ctx context.Context, projectID *string, code string) error
+
+	// ValidateCodes fails with ErrInvalidPermission on the first code that is neither a system permission nor
+	// defined by projectID.
+	ValidateCodes(ctx context.Context, projectID *string, codes []string) error
+	// EffectiveCodes drops the codes that only other projects define, so they take no effect within projectID.
+	EffectiveCodes(ctx context.Context, projectID *string, codes []string) ([]string, error)
+	// Seed adds the permissions of the JSON registry that the catalog does not have yet.
+	Seed(ctx context.Context) error
+}
+
+type PermissionSvc struct {
+	logger         logger.ILogger
+	permissionRepo repository.IPermissionRepository
+	projectRepo    repository.IProjectRepository
+	registry       *permission.Registry
+	cache          cache.ICache
+}
+
+func NewPermissionSvc(
+	logger logger.ILogger,
+	permissionRepo repository.IPermissionRepository,
+	projectRepo repository.IProjectRepository,
+	registry *permission.Registry,
+	cache cache.ICache,
+) IPermissionSvc {
+	return &PermissionSvc{
+		logger:         logger,
+		permissionRepo: permissionRepo,
+		projectRepo:    projectRepo,
+		registry:       registry,
+		cache:          cache,
+	}
+}
+
+// RegisterPermissionSeed seeds the permission catalog from the JSON registry on startup.
+func RegisterPermissionSeed(lc fx.Lifecycle, svc IPermissionSvc, logger logger.ILogger) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			if err := svc.Seed(ctx); err != nil {
+				logger.Error("Failed to seed permission catalog", "error", err)
+			}
+			return nil
+		},
+	})
+}
+
+// ListPermissions returns the system permissions, or the codes a project defines, ordered by code
+func (s *PermissionSvc) ListPermissions(ctx context.Context, projectID *string) ([]aggregate.PermissionResp, error) {
+	if err := s.checkProject(ctx, projectID); err != nil {
+		return nil, err
+	}
+	permissions, err := s.permissionRepo.FindByProjectID(ctx, projectID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	items := make([]aggregate.PermissionResp, len(permissions))
+	for i := range permissions {
+		items[i].FromModel(&permissions[i])
+	}
+	return items, nil
+}
+
+// GetPermission returns a catalog permission by code
+func (s *PermissionSvc) GetPermission(ctx context.Context, projectID *string, code string) (*aggregate.PermissionResp, error) {
+	p := s.permissionRepo.FindByCode(ctx, projectID, code)
+	if p == nil {
+		return nil, errorx.New(errorx.ErrPermissionNotFound, "Permission not found")
+	}
+	var resp aggregate.PermissionResp
+	resp.FromModel(p)
+	return &resp, nil
+}
+
+// CreatePermission adds a permission to the catalog; roles can use it immediately. A project cannot define
+// a code the system already has, and two projects may define the same code independently.
+func (s *PermissionSvc) CreatePermission(ctx context.Context, projectID *string, req aggregate.CreatePermissionReq) (*aggregate.PermissionResp, error) {
+	if err := s.checkProject(ctx, projectID); err != nil {
+		return nil, err
+	}
+	if s.permissionRepo.FindByCode(ctx, projectID, req.Code) != nil {
+		return nil, errorx.New(errorx.ErrPermissionConflict, "Permission with this code already exists")
+	}
+	if projectID != nil && s.permissionRepo.FindByCode(ctx, nil, req.Code) != nil {
+		return nil, errorx.New(errorx.ErrPermissionConflict, "A system permission with this code already exists")
+	}
+
+	created, err := s.permissionRepo.Create(ctx, &model.Permission{
+		ProjectID:   projectID,
+		Code:        req.Code,
+		Name:        req.Name,
+		Description: req.Description,
+	})
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	s.clearCatalogCache(ctx)
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Permission created: %s (scope: %s)", created.Code, permissionScope(projectID)))
+
+	var resp aggregate.PermissionResp
+	resp.FromModel(created)
+	return &resp, nil
+}
+
+// UpdatePermission changes the name and description of a catalog permission
+func (s *PermissionSvc) UpdatePermission(ctx context.Context, projectID *string, code string, req aggregate.UpdatePermissionReq) (*aggregate.PermissionResp, error) {
+	p := s.permissionRepo.FindByCode(ctx, projectID, code)
+	if p == nil {
+		return nil, errorx.New(errorx.ErrPermissionNotFound, "Permission not found")
+	}
+
+	p.Name = req.Name
+	p.Description = req.Description
+	if err := s.permissionRepo.Update(ctx, p.ID, *p, "name", "description", "updated_at"); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	var resp aggregate.PermissionResp
+	resp.FromModel(p)
+	return &resp, nil
+}
+
+// DeletePermission removes a permission from the catalog. Roles that already grant it keep the code, but
+// it can no longer be added to roles, API keys or service accounts.
+func (s *PermissionSvc) DeletePermission(ctx context.Context, projectID *string, code string) error {
+	p := s.permissionRepo.FindByCode(ctx, projectID, code)
+	if p == nil {
+		return errorx.New(errorx.ErrPermissionNotFound, "Permission not found")
+	}
+	if err := s.permissionRepo.DeleteById(ctx, p.ID); err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	s.clearCatalogCache(ctx)
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Permission deleted: %s (scope: %s)", code, permissionScope(projectID)))
+
+	return nil
+}
+
+// ValidateCodes checks codes against the system permissions and those of projectID. The catalog is cached
+// and reloaded after every change.
+func (s *PermissionSvc) ValidateCodes(ctx context.Context, projectID *string, codes []string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+	catalog, err := s.catalog(ctx)
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	scope := permissionScope(projectID)
+	for _, code := range codes {
+		if code == "" {
+			continue
+		}
+		if !catalog.defines(constant.SystemProjectID, code) && !catalog.defines(scope, code) {
+			return errorx.New(errorx.ErrInvalidPermission, fmt.Sprintf("invalid permission code: %s", code))
+		}
+	}
+	return nil
+}
+
+// EffectiveCodes keeps system codes, codes projectID defines, and codes no project defines (e.g. ones
+// deleted from the catalog after roles adopted them). A code only other projects define is dropped, so a
+// tenant's custom permission never grants anything in another tenant.
+func (s *PermissionSvc) EffectiveCodes(ctx context.Context, projectID *string, codes []string) ([]string, error) {
+	if len(codes) == 0 {
+		return codes, nil
+	}
+	catalog, err := s.catalog(ctx)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	scope := permissionScope(projectID)
+	effective := make([]string, 0, len(codes))
+	for _, code := range codes {
+		if catalog.defines(constant.SystemProjectID, code) || catalog.defines(scope, code) || !catalog.projectDefined(code) {
+			effective = append(effective, code)
+		}
+	}
+	return effective, nil
+}
+
+// Seed inserts the registry permissions missing from the catalog as system permissions. Existing entries
+// are left as they are, so names edited through the API survive restarts.
+func (s *PermissionSvc) Seed(ctx context.Context) error {
+	var missing []model.Permission
+	for _, p := range s.registry.List() {
+		if p.Code == "" || s.permissionRepo.FindByCode(ctx, nil, p.Code) != nil {
+			continue
+		}
+		missing = append(missing, model.Permission{Code: p.Code, Name: p.Name})
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if err := s.permissionRepo.BulkCreate(ctx, missing); err != nil {
+		return err
+	}
+	s.clearCatalogCache(ctx)
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Seeded %d permissions into the catalog", len(missing)))
+
+	return nil
+}
+
+func (s *PermissionSvc) checkProject(ctx context.Context, projectID *string) error {
+	if projectID != nil && s.projectRepo.FindOneById(ctx, *projectID) == nil {
+		return errorx.Wrap(errorx.ErrProjectNotFound, nil)
+	}
+	return nil
+}
+
+// permissionCatalog maps each scope (constant.SystemProjectID or a project ID) to its sorted codes
+type permissionCatalog map[string][]string
+
+func (c permissionCatalog) defines(scope, code string) bool {
+	_, ok := slices.BinarySearch(c[scope], code)
+	return ok
+}
+
+// projectDefined reports whether any project defines code
+func (c permissionCatalog) projectDefined(code string) bool {
+	for scope := range c {
+		if scope != constant.SystemProjectID && c.defines(scope, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// catalog returns the catalog codes grouped by scope
+func (s *PermissionSvc) catalog(ctx context.Context) (permissionCatalog, error) {
+	var catalog permissionCatalog
+	if err := s.cache.WithContext(ctx).Get(constant.CacheKeyPermissionCatalog, &catalog); err == nil {
+		return catalog, nil
+	}
+
+	permissions, err := s.permissionRepo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	catalog = make(permissionCatalog)
+	for _, p := range permissions {
+		scope := permissionScope(p.ProjectID)
+		catalog[scope] = append(catalog[scope], p.Code)
+	}
+	for _, codes := range catalog {
+		slices.Sort(codes)
+	}
+
+	ttl := constant.CacheDefaultTTL
+	if err := s.cache.WithContext(ctx).Set(constant.CacheKeyPermissionCatalog, catalog, &ttl); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to cache permission catalog", "error", err)
+	}
+	return catalog, nil
+}
+
+func (s *PermissionSvc) clearCatalogCache(ctx context.Context) {
+	if err := s.cache.WithContext(ctx).Delete(constant.CacheKeyPermissionCatalog); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to invalidate permission catalog cache", "error", err)
+	}
+}
+
+// permissionScope names the catalog scope of projectID: constant.SystemProjectID for system permissions and
+// system roles, otherwise the project ID.
+func permissionScope(projectID *string) string {
+	if projectID == nil {
+		return constant.SystemProjectID
+	}
+	return *projectID
+}
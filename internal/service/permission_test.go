@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+)
+
+func TestPermissionSvc_Catalog(t *testing.T) {
+	ctx := context.Background()
+	svc := NewPermissionSvc(testutil.NewLogger(), testutil.NewPermissionRepository(), testutil.NewProjectRepository(), nil, testutil.NewCache())
+
+	// Validation reads the catalog, so an unknown code is rejected until it is added.
+	wantCode(t, "ValidateCodes before create", svc.ValidateCodes(ctx, nil, []string{"audit.read"}), errorx.ErrInvalidPermission)
+
+	create := aggregate.CreatePermissionReq{Code: "audit.read", Name: "Audit Read"}
+	if _, err := svc.CreatePermission(ctx, nil, create); err != nil {
+		t.Fatalf("CreatePermission err = %v", err)
+	}
+	_, err := svc.CreatePermission(ctx, nil, create)
+	wantCode(t, "duplicate CreatePermission", err, errorx.ErrPermissionConflict)
+	if err := svc.ValidateCodes(ctx, nil, []string{"audit.read"}); err != nil {
+		t.Errorf("ValidateCodes after create err = %v", err)
+	}
+
+	updated, err := svc.UpdatePermission(ctx, nil, "audit.read", aggregate.UpdatePermissionReq{Name: "Read audit log"})
+	if err != nil || updated.Name != "Read audit log" || updated.Code != "audit.read" {
+		t.Errorf("UpdatePermission = %+v, %v", updated, err)
+	}
+	list, err := svc.ListPermissions(ctx, nil)
+	if err != nil || len(list) != 1 || list[0].Code != "audit.read" {
+		t.Errorf("ListPermissions = %+v, %v, want [audit.read]", list, err)
+	}
+
+	if err := svc.DeletePermission(ctx, nil, "audit.read"); err != nil {
+		t.Fatalf("DeletePermission err = %v", err)
+	}
+	wantCode(t, "ValidateCodes after delete", svc.ValidateCodes(ctx, nil, []string{"audit.read"}), errorx.ErrInvalidPermission)
+}
+
+func TestPermissionSvc_ProjectCodes(t *testing.T) {
+	ctx := context.Background()
+	permissions := testutil.NewPermissionRepository()
+	projects := testutil.NewProjectRepository()
+	svc := NewPermissionSvc(testutil.NewLogger(), permissions, projects, nil, testutil.NewCache())
+	acme, _ := projects.Create(ctx, &model.Project{Code: "acme", Name: "Acme"})
+	globex, _ := projects.Create(ctx, &model.Project{Code: "globex", Name: "Globex"})
+	permissions.Create(ctx, &model.Permission{Code: "audit.read", Name: "Audit Read"})
+
+	// Each tenant defines its own codes; the same code in two projects does not collide.
+	export := aggregate.CreatePermissionReq{Code: "reports.export", Name: "Export reports"}
+	for _, project := range []*model.Project{acme, globex} {
+		if _, err := svc.CreatePermission(ctx, &project.ID, export); err != nil {
+			t.Fatalf("CreatePermission in %s err = %v", project.Code, err)
+		}
+	}
+	_, err := svc.CreatePermission(ctx, &acme.ID, export)
+	wantCode(t, "duplicate project CreatePermission", err, errorx.ErrPermissionConflict)
+	_, err = svc.CreatePermission(ctx, &acme.ID, aggregate.CreatePermissionReq{Code: "audit.read", Name: "Audit"})
+	wantCode(t, "CreatePermission shadowing a system code", err, errorx.ErrPermissionConflict)
+	missing := "missing"
+	if _, err := svc.CreatePermission(ctx, &missing, export); err == nil {
+		t.Error("permission was created for an unknown project")
+	}
+	if _, err := svc.CreatePermission(ctx, &acme.ID, aggregate.CreatePermissionReq{Code: "acme.only", Name: "Acme only"}); err != nil {
+		t.Fatalf("CreatePermission acme.only err = %v", err)
+	}
+
+	list, _ := svc.ListPermissions(ctx, &acme.ID)
+	if len(list) != 2 || list[0].Code != "acme.only" || list[0].ProjectID == nil || *list[0].ProjectID != acme.ID {
+		t.Errorf("acme permissions = %+v, want [acme.only reports.export]", list)
+	}
+	list, _ = svc.ListPermissions(ctx, nil)
+	if len(list) != 1 || list[0].Code != "audit.read" {
+		t.Errorf("system permissions = %+v, want [audit.read]", list)
+	}
+
+	// A project may use system codes and its own codes, not another tenant's.
+	wantCode(t, "ValidateCodes with another project's code", svc.ValidateCodes(ctx, &globex.ID, []string{"acme.only"}), errorx.ErrInvalidPermission)
+	if err := svc.ValidateCodes(ctx, &acme.ID, []string{"acme.only", "audit.read"}); err != nil {
+		t.Errorf("ValidateCodes in acme err = %v", err)
+	}
+	codes, err := svc.EffectiveCodes(ctx, &globex.ID, []string{"acme.only", "audit.read"})
+	if err != nil || len(codes) != 1 || codes[0] != "audit.read" {
+		t.Errorf("EffectiveCodes in globex = %v, %v, want [audit.read]", codes, err)
+	}
+}
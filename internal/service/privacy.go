@@ -0,0 +1,343 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
+)
+
+// IPrivacySvc lets users take out their data and erase their account.
+type IPrivacySvc interface {
+	// ExportData returns what is stored about the calling user.
+	ExportData(ctx context.Context) (*aggregate.UserDataExport, error)
+	// EraseAccount deletes the calling user's sessions, logins, memberships, roles and relations and
+	// anonymizes the account. Their access denials are kept, anonymized, for the audit retention period.
+	EraseAccount(ctx context.Context, req aggregate.EraseAccountReq) error
+	// PurgeErased permanently deletes accounts erased before the given time, with their audit records.
+	PurgeErased(ctx context.Context, before time.Time) (int64, error)
+}
+
+type PrivacySvc struct {
+	logger       logger.ILogger
+	userRepo     repository.IUserRepository
+	sessionRepo  repository.ISessionRepository
+	identityRepo repository.IUserIdentityRepository
+	credRepo     repository.IUserCredentialRepository
+	deviceRepo   repository.ITrustedDeviceRepository
+	userDevices  repository.IUserDeviceRepository
+	memberRepo   repository.IProjectMemberRepository
+	userRoleRepo repository.IUserRoleRepository
+	scimRepo     repository.ISCIMUserRepository
+	denialRepo   repository.IAccessDenialRepository
+	txManager    repository.ITxManager
+	relationSvc  IRelationSvc
+	authSvc      IAuthSvc
+	access       IEffectiveAccessSvc
+	events       eventbus.IPublisher
+}
+
+func NewPrivacySvc(
+	logger logger.ILogger,
+	userRepo repository.IUserRepository,
+	sessionRepo repository.ISessionRepository,
+	identityRepo repository.IUserIdentityRepository,
+	credRepo repository.IUserCredentialRepository,
+	deviceRepo repository.ITrustedDeviceRepository,
+	userDevices repository.IUserDeviceRepository,
+	memberRepo repository.IProjectMemberRepository,
+	userRoleRepo repository.IUserRoleRepository,
+	scimRepo repository.ISCIMUserRepository,
+	denialRepo repository.IAccessDenialRepository,
+	txManager repository.ITxManager,
+	relationSvc IRelationSvc,
+	authSvc IAuthSvc,
+	access IEffectiveAccessSvc,
+	events eventbus.IPublisher,
+) IPrivacySvc {
+	return &PrivacySvc{
+		logger:       logger,
+		userRepo:     userRepo,
+		sessionRepo:  sessionRepo,
+		identityRepo: identityRepo,
+		credRepo:     credRepo,
+		deviceRepo:   deviceRepo,
+		userDevices:  userDevices,
+		memberRepo:   memberRepo,
+		userRoleRepo: userRoleRepo,
+		scimRepo:     scimRepo,
+		denialRepo:   denialRepo,
+		txManager:    txManager,
+		relationSvc:  relationSvc,
+		authSvc:      authSvc,
+		access:       access,
+		events:       events,
+	}
+}
+
+func (s *PrivacySvc) ExportData(ctx context.Context) (*aggregate.UserDataExport, error) {
+	ctx, span := tracing.Start(ctx, "PrivacySvc.ExportData")
+	defer span.End()
+	userID, err := callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u := s.userRepo.FindOneById(ctx, userID)
+	if u == nil {
+		return nil, errorx.Wrap(errorx.ErrUserNotFound, nil)
+	}
+
+	export := &aggregate.UserDataExport{ExportedAt: time.Now().UTC()}
+	export.Profile.FromModel(u)
+
+	identities, err := s.identityRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	export.Identities = make([]aggregate.UserIdentityResp, len(identities))
+	for i := range identities {
+		export.Identities[i].FromModel(&identities[i])
+	}
+
+	creds, err := s.credRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	export.Credentials = make([]aggregate.CredentialResp, len(creds))
+	for i := range creds {
+		export.Credentials[i].FromModel(&creds[i])
+	}
+
+	devices, err := s.deviceRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	export.TrustedDevices = make([]aggregate.TrustedDeviceResp, len(devices))
+	for i := range devices {
+		export.TrustedDevices[i].FromModel(&devices[i])
+	}
+
+	userDevices, err := s.userDevices.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	export.Devices = make([]aggregate.UserDeviceResp, len(userDevices))
+	for i := range userDevices {
+		export.Devices[i].FromModel(&userDevices[i])
+	}
+
+	sessions, err := s.sessionRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	export.Sessions = make([]aggregate.UserSessionResp, len(sessions))
+	for i := range sessions {
+		export.Sessions[i].FromModel(&sessions[i])
+	}
+
+	members, err := s.memberRepo.FindByUserID(ctx, userID, "")
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	export.Memberships = make([]aggregate.ProjectMemberResp, len(members))
+	for i := range members {
+		export.Memberships[i].FromModel(&members[i])
+	}
+
+	userRoles, err := s.userRoleRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	export.Roles = make([]aggregate.UserRoleResp, len(userRoles))
+	for i := range userRoles {
+		export.Roles[i] = *aggregate.UserRoleRespFromModel(&userRoles[i], &userRoles[i].Role)
+	}
+
+	if export.Relations, err = s.relationSvc.ListUserRelations(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	denials, err := s.denialRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	export.AccessDenials = make([]aggregate.AccessDenialResp, len(denials))
+	for i := range denials {
+		export.AccessDenials[i].FromModel(&denials[i])
+	}
+	return export, nil
+}
+
+func (s *PrivacySvc) EraseAccount(ctx context.Context, req aggregate.EraseAccountReq) error {
+	ctx, span := tracing.Start(ctx, "PrivacySvc.EraseAccount")
+	defer span.End()
+	userID, err := callerUserID(ctx)
+	if err != nil {
+		return err
+	}
+	u := s.userRepo.FindOneById(ctx, userID)
+	if u == nil {
+		return errorx.Wrap(errorx.ErrUserNotFound, nil)
+	}
+	if !strings.EqualFold(strings.TrimSpace(req.ConfirmEmail), u.Email) {
+		return errorx.New(errorx.ErrBadRequest, "confirmEmail does not match the account's email")
+	}
+
+	// End the sessions first so registered clients are told about the logout.
+	if err := s.authSvc.EndUserSessions(ctx, userID); err != nil {
+		return err
+	}
+	// Everything else is removed in one transaction, so a failure leaves the account as it was rather than
+	// half erased, and the user can try again.
+	err = withTx(ctx, s.txManager, func(ctx context.Context) error {
+		if err := s.removeUserData(ctx, userID); err != nil {
+			logger.WithContext(ctx, s.logger).Error("[PrivacySvc] failed to erase user data", "id", userID, "error", err)
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+		if _, err := s.relationSvc.RemoveUserRelations(ctx, userID); err != nil {
+			return err
+		}
+		if err := s.denialRepo.AnonymizeByUserID(ctx, userID); err != nil {
+			logger.WithContext(ctx, s.logger).Error("[PrivacySvc] failed to anonymize access denials", "id", userID, "error", err)
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+		return s.anonymizeUser(ctx, u)
+	})
+	if err != nil {
+		return err
+	}
+	s.access.RefreshUsers(ctx, userID)
+	logger.WithContext(ctx, s.logger).Info("[PrivacySvc] erased user", "id", userID)
+	publishEvent(ctx, s.events, s.logger, constant.EventUserErased, userID, nil)
+	return nil
+}
+
+// anonymizeUser replaces the account's personal data and soft-deletes it. The row stays so the kept audit
+// records still point at an account.
+func (s *PrivacySvc) anonymizeUser(ctx context.Context, u *model.User) error {
+	password, err := helper.GenerateRefreshToken()
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	hashed, err := helper.HashPassword(password)
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	now := time.Now()
+	u.Email = fmt.Sprintf("erased-%s@%s", u.ID, constant.ErasedUserEmailDomain)
+	u.Username = "erased-" + u.ID
+	u.Phone = ""
+	u.Password = hashed
+	u.Status = constant.UserStatusInactive
+	u.Attributes = nil
+	u.PasswordResetRequired = false
+	u.ErasedAt = &now
+	u.UpdatedBy = u.ID
+	if err := s.userRepo.Update(ctx, u.ID, *u,
+		"email", "username", "phone", "password", "status", "attributes", "password_reset_required", "erased_at", "updated_by",
+	); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[PrivacySvc] failed to anonymize user", "id", u.ID, "error", err)
+		return errorx.Wrap(errorx.ErrUpdateUser, err)
+	}
+	if err := s.userRepo.DeleteById(ctx, u.ID); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[PrivacySvc] failed to delete erased user", "id", u.ID, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return nil
+}
+
+// removeUserData deletes the records that exist only for the user: sessions, devices, linked logins,
+// passkeys, trusted devices, project memberships, role assignments and the SCIM link.
+func (s *PrivacySvc) removeUserData(ctx context.Context, userID string) error {
+	if err := s.sessionRepo.DeleteByUserID(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.userDevices.DeleteByUserID(ctx, userID); err != nil {
+		return err
+	}
+	identities, err := s.identityRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, identity := range identities {
+		if err := s.identityRepo.HardDeleteById(ctx, identity.ID); err != nil {
+			return err
+		}
+	}
+	creds, err := s.credRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, cred := range creds {
+		if err := s.credRepo.HardDeleteById(ctx, cred.ID); err != nil {
+			return err
+		}
+	}
+	if err := s.deviceRepo.DeleteByUserID(ctx, userID); err != nil {
+		return err
+	}
+	members, err := s.memberRepo.FindByUserID(ctx, userID, "")
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if err := s.memberRepo.HardDeleteById(ctx, member.ID); err != nil {
+			return err
+		}
+	}
+	userRoles, err := s.userRoleRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, len(userRoles))
+	for i, ur := range userRoles {
+		ids[i] = ur.ID
+	}
+	if len(ids) > 0 {
+		if err := s.userRoleRepo.DeleteByIDs(ctx, ids); err != nil {
+			return err
+		}
+	}
+	if link := s.scimRepo.FindByUserID(ctx, userID); link != nil {
+		if err := s.scimRepo.HardDeleteById(ctx, link.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PrivacySvc) PurgeErased(ctx context.Context, before time.Time) (int64, error) {
+	users, err := s.userRepo.FindErasedBefore(ctx, before)
+	if err != nil {
+		return 0, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if len(users) == 0 {
+		return 0, nil
+	}
+	ids := make([]string, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	if _, err := s.denialRepo.DeleteByUserIDs(ctx, ids); err != nil {
+		return 0, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	var purged int64
+	for _, id := range ids {
+		if err := s.userRepo.HardDeleteById(ctx, id); err != nil {
+			logger.WithContext(ctx, s.logger).Error("[PrivacySvc] failed to purge erased user", "id", id, "error", err)
+			return purged, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
@@ -2,11 +2,14 @@ package service
 
 import (
 	"context"
+	"net/mail"
 	"strings"
+	"time"
 
 	"github.com/hiamthach108/dreon-auth/internal/aggregate"
 	"github.com/hiamthach108/dreon-auth/internal/errorx"
 	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
 	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
 	"github.com/hiamthach108/dreon-auth/pkg/logger"
 )
@@ -18,6 +21,12 @@ type IProjectSvc interface {
 	List(ctx context.Context, page, pageSize int) (*aggregate.PaginationResp[aggregate.ProjectDto], error)
 	Update(ctx context.Context, id string, req aggregate.UpdateProjectReq) (*aggregate.ProjectDto, error)
 	Delete(ctx context.Context, id string) error
+	// Archive marks a project archived. Its data is kept, but logins, role assignments and relation writes
+	// scoped to it are rejected until it is restored.
+	Archive(ctx context.Context, id string) (*aggregate.ProjectDto, error)
+	Restore(ctx context.Context, id string) (*aggregate.ProjectDto, error)
+	// PurgeArchived deletes projects archived before the given time and returns how many were deleted.
+	PurgeArchived(ctx context.Context, before time.Time) (int64, error)
 }
 
 // ProjectSvc implements IProjectSvc.
@@ -36,12 +45,15 @@ func NewProjectSvc(logger logger.ILogger, repo repository.IProjectRepository) IP
 
 // Create creates a new project.
 func (s *ProjectSvc) Create(ctx context.Context, req aggregate.CreateProjectReq) (*aggregate.ProjectDto, error) {
+	if err := checkMailFrom(req.MailFrom); err != nil {
+		return nil, err
+	}
 
 	model := req.ToModel()
 	model.Code = s.generateCode(req.Name)
 	created, err := s.repo.Create(ctx, model)
 	if err != nil {
-		s.logger.Error("[ProjectSvc] failed to create project", "code", model.Code, "error", err)
+		logger.WithContext(ctx, s.logger).Error("[ProjectSvc] failed to create project", "code", model.Code, "error", err)
 		return nil, errorx.Wrap(errorx.ErrCreateProject, err)
 	}
 
@@ -73,7 +85,7 @@ func (s *ProjectSvc) List(ctx context.Context, page, pageSize int) (*aggregate.P
 
 	projects, total, err := s.repo.List(ctx, offset, pageSize)
 	if err != nil {
-		s.logger.Error("[ProjectSvc] failed to list projects", "error", err)
+		logger.WithContext(ctx, s.logger).Error("[ProjectSvc] failed to list projects", "error", err)
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
 
@@ -100,6 +112,11 @@ func (s *ProjectSvc) Update(ctx context.Context, id string, req aggregate.Update
 	if p == nil {
 		return nil, errorx.Wrap(errorx.ErrProjectNotFound, nil)
 	}
+	if req.MailFrom != nil {
+		if err := checkMailFrom(*req.MailFrom); err != nil {
+			return nil, err
+		}
+	}
 
 	updated, fields := req.ToModelAndFields()
 	if len(fields) == 0 {
@@ -109,7 +126,7 @@ func (s *ProjectSvc) Update(ctx context.Context, id string, req aggregate.Update
 	}
 
 	if err := s.repo.Update(ctx, id, *updated, fields...); err != nil {
-		s.logger.Error("[ProjectSvc] failed to update project", "id", id, "error", err)
+		logger.WithContext(ctx, s.logger).Error("[ProjectSvc] failed to update project", "id", id, "error", err)
 		return nil, errorx.Wrap(errorx.ErrUpdateProject, err)
 	}
 
@@ -131,12 +148,91 @@ func (s *ProjectSvc) Delete(ctx context.Context, id string) error {
 		return errorx.Wrap(errorx.ErrProjectNotFound, nil)
 	}
 	if err := s.repo.DeleteById(ctx, id); err != nil {
-		s.logger.Error("[ProjectSvc] failed to delete project", "id", id, "error", err)
+		logger.WithContext(ctx, s.logger).Error("[ProjectSvc] failed to delete project", "id", id, "error", err)
 		return errorx.Wrap(errorx.ErrInternal, err)
 	}
 	return nil
 }
 
+// Archive archives a project by ID; archiving an archived project is a no-op.
+func (s *ProjectSvc) Archive(ctx context.Context, id string) (*aggregate.ProjectDto, error) {
+	p := s.repo.FindOneById(ctx, id)
+	if p == nil {
+		return nil, errorx.Wrap(errorx.ErrProjectNotFound, nil)
+	}
+	if !p.IsArchived() {
+		now := time.Now()
+		p.ArchivedAt = &now
+		if err := s.repo.Update(ctx, id, *p, "archived_at"); err != nil {
+			logger.WithContext(ctx, s.logger).Error("[ProjectSvc] failed to archive project", "id", id, "error", err)
+			return nil, errorx.Wrap(errorx.ErrUpdateProject, err)
+		}
+		logger.WithContext(ctx, s.logger).Info("[ProjectSvc] archived project", "id", id)
+	}
+	var resp aggregate.ProjectDto
+	resp.FromModel(p)
+	return &resp, nil
+}
+
+// Restore un-archives a project by ID; restoring an active project is a no-op.
+func (s *ProjectSvc) Restore(ctx context.Context, id string) (*aggregate.ProjectDto, error) {
+	p := s.repo.FindOneById(ctx, id)
+	if p == nil {
+		return nil, errorx.Wrap(errorx.ErrProjectNotFound, nil)
+	}
+	if p.IsArchived() {
+		p.ArchivedAt = nil
+		if err := s.repo.Update(ctx, id, *p, "archived_at"); err != nil {
+			logger.WithContext(ctx, s.logger).Error("[ProjectSvc] failed to restore project", "id", id, "error", err)
+			return nil, errorx.Wrap(errorx.ErrUpdateProject, err)
+		}
+		logger.WithContext(ctx, s.logger).Info("[ProjectSvc] restored project", "id", id)
+	}
+	var resp aggregate.ProjectDto
+	resp.FromModel(p)
+	return &resp, nil
+}
+
+// PurgeArchived deletes projects archived before the given time.
+func (s *ProjectSvc) PurgeArchived(ctx context.Context, before time.Time) (int64, error) {
+	projects, err := s.repo.FindArchivedBefore(ctx, before)
+	if err != nil {
+		return 0, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	var count int64
+	for _, p := range projects {
+		if err := s.repo.DeleteById(ctx, p.ID); err != nil {
+			logger.WithContext(ctx, s.logger).Error("[ProjectSvc] failed to purge archived project", "id", p.ID, "error", err)
+			return count, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
 func (s *ProjectSvc) generateCode(name string) string {
 	return strings.ToUpper(helper.NormalizeSlug(name) + "-" + helper.RandomString(6))
 }
+
+// checkProjectNotArchived fails with a forbidden error if projectID names an archived project.
+// Unknown projects pass, so callers keep their own not-found handling.
+func checkProjectNotArchived(ctx context.Context, repo repository.IProjectRepository, projectID string) error {
+	if projectID == "" || projectID == constant.SystemProjectID {
+		return nil
+	}
+	if p := repo.FindOneById(ctx, projectID); p != nil && p.IsArchived() {
+		return errorx.New(errorx.ErrForbidden, "Project is archived")
+	}
+	return nil
+}
+
+// checkMailFrom rejects a project sender that is not a single address; empty means MAIL_FROM.
+func checkMailFrom(from string) error {
+	if from == "" {
+		return nil
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return errorx.New(errorx.ErrBadRequest, "mailFrom must be an email address, optionally with a name")
+	}
+	return nil
+}
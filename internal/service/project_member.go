@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// IProjectMemberSvc manages which users belong to a project. Users are invited, become active members
+// once they accept, and only then can be granted project-scoped roles and relations.
+type IProjectMemberSvc interface {
+	Invite(ctx context.Context, projectID string, req aggregate.InviteProjectMemberReq) (*aggregate.ProjectMemberResp, error)
+	// Accept makes the calling user an active member of a project they were invited to.
+	Accept(ctx context.Context, projectID string) (*aggregate.ProjectMemberResp, error)
+	List(ctx context.Context, projectID string, req aggregate.ListProjectMembersReq) ([]aggregate.ProjectMemberResp, error)
+	// Remove deletes the membership and the user's role assignments in the project.
+	Remove(ctx context.Context, projectID, userID string) error
+}
+
+type ProjectMemberSvc struct {
+	logger      logger.ILogger
+	repo        repository.IProjectMemberRepository
+	projectRepo repository.IProjectRepository
+	userRepo    repository.IUserRepository
+	roleSvc     IRoleSvc
+	events      eventbus.IPublisher
+}
+
+func NewProjectMemberSvc(
+	logger logger.ILogger,
+	repo repository.IProjectMemberRepository,
+	projectRepo repository.IProjectRepository,
+	userRepo repository.IUserRepository,
+	roleSvc IRoleSvc,
+	events eventbus.IPublisher,
+) IProjectMemberSvc {
+	return &ProjectMemberSvc{
+		logger:      logger,
+		repo:        repo,
+		projectRepo: projectRepo,
+		userRepo:    userRepo,
+		roleSvc:     roleSvc,
+		events:      events,
+	}
+}
+
+func (s *ProjectMemberSvc) Invite(ctx context.Context, projectID string, req aggregate.InviteProjectMemberReq) (*aggregate.ProjectMemberResp, error) {
+	if s.projectRepo.FindOneById(ctx, projectID) == nil {
+		return nil, errorx.Wrap(errorx.ErrProjectNotFound, nil)
+	}
+
+	var user *model.User
+	if req.UserID != "" {
+		user = s.userRepo.FindOneById(ctx, req.UserID)
+	} else {
+		found, err := s.userRepo.FindByEmail(ctx, req.Email)
+		if err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		user = found
+	}
+	if user == nil {
+		return nil, errorx.New(errorx.ErrUserNotFound, "User not found")
+	}
+	if s.repo.FindByProjectAndUser(ctx, projectID, user.ID) != nil {
+		return nil, errorx.New(errorx.ErrConflict, "User is already a member of the project or invited to it")
+	}
+
+	member := &model.ProjectMember{
+		ProjectID: projectID,
+		UserID:    user.ID,
+		Status:    constant.ProjectMemberInvited,
+	}
+	if caller := payloadFromContext(ctx); caller != nil {
+		member.InvitedBy = caller.UserID
+		member.CreatedBy = caller.UserID
+		member.UpdatedBy = caller.UserID
+	}
+	created, err := s.repo.Create(ctx, member)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[ProjectMemberSvc] failed to invite member", "projectID", projectID, "userID", user.ID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[ProjectMemberSvc] invited member", "projectID", projectID, "userID", user.ID)
+
+	resp := &aggregate.ProjectMemberResp{}
+	resp.FromModel(created)
+	publishEvent(ctx, s.events, s.logger, constant.EventMemberInvited, user.ID, resp)
+	return resp, nil
+}
+
+func (s *ProjectMemberSvc) Accept(ctx context.Context, projectID string) (*aggregate.ProjectMemberResp, error) {
+	caller := payloadFromContext(ctx)
+	if caller == nil || caller.UserID == "" || caller.IsMachine() {
+		return nil, errorx.New(errorx.ErrUnauthorized, "only users can accept project invitations")
+	}
+	member := s.repo.FindByProjectAndUser(ctx, projectID, caller.UserID)
+	if member == nil {
+		return nil, errorx.Wrap(errorx.ErrMemberNotFound, nil)
+	}
+
+	resp := &aggregate.ProjectMemberResp{}
+	if member.Status == constant.ProjectMemberActive {
+		resp.FromModel(member)
+		return resp, nil
+	}
+	now := time.Now()
+	member.Status = constant.ProjectMemberActive
+	member.AcceptedAt = &now
+	member.UpdatedBy = caller.UserID
+	if err := s.repo.Update(ctx, member.ID, *member, "status", "accepted_at", "updated_by"); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[ProjectMemberSvc] failed to accept invitation", "projectID", projectID, "userID", caller.UserID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[ProjectMemberSvc] member joined", "projectID", projectID, "userID", caller.UserID)
+
+	resp.FromModel(member)
+	publishEvent(ctx, s.events, s.logger, constant.EventMemberJoined, caller.UserID, resp)
+	return resp, nil
+}
+
+func (s *ProjectMemberSvc) List(ctx context.Context, projectID string, req aggregate.ListProjectMembersReq) ([]aggregate.ProjectMemberResp, error) {
+	members, err := s.repo.FindByProjectID(ctx, projectID, req.Status)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	out := make([]aggregate.ProjectMemberResp, len(members))
+	for i := range members {
+		out[i].FromModel(&members[i])
+	}
+	return out, nil
+}
+
+func (s *ProjectMemberSvc) Remove(ctx context.Context, projectID, userID string) error {
+	member := s.repo.FindByProjectAndUser(ctx, projectID, userID)
+	if member == nil {
+		return errorx.Wrap(errorx.ErrMemberNotFound, nil)
+	}
+
+	// Drop the user's roles in the project first, so a failure leaves them a member rather than a
+	// non-member who still holds project permissions.
+	if s.userRepo.FindOneById(ctx, userID) != nil {
+		roles, err := s.roleSvc.GetUserRoles(ctx, aggregate.GetUserRolesReq{UserID: userID, ProjectID: &projectID})
+		if err != nil {
+			return err
+		}
+		if len(roles) > 0 {
+			removals := make([]aggregate.RemoveRoleFromUserReq, len(roles))
+			for i, role := range roles {
+				removals[i] = aggregate.RemoveRoleFromUserReq{UserID: userID, RoleID: role.RoleID, ProjectID: &projectID}
+			}
+			if _, err := s.roleSvc.BulkRemoveRoles(ctx, aggregate.BulkRemoveRolesReq{Assignments: removals}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.repo.DeleteById(ctx, member.ID); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[ProjectMemberSvc] failed to remove member", "projectID", projectID, "userID", userID, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[ProjectMemberSvc] removed member", "projectID", projectID, "userID", userID)
+
+	resp := &aggregate.ProjectMemberResp{}
+	resp.FromModel(member)
+	publishEvent(ctx, s.events, s.logger, constant.EventMemberRemoved, userID, resp)
+	return nil
+}
+
+// checkProjectMember fails with a forbidden error unless userID is an active member of projectID.
+func checkProjectMember(ctx context.Context, repo repository.IProjectMemberRepository, projectID, userID string) error {
+	member := repo.FindByProjectAndUser(ctx, projectID, userID)
+	if member == nil || member.Status != constant.ProjectMemberActive {
+		return errorx.New(errorx.ErrForbidden, "User is not an active member of the project")
+	}
+	return nil
+}
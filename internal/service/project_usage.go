@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// projectUsageKinds are the metered request kinds, in the order usage reports them.
+var projectUsageKinds = []string{constant.ProjectUsageLogin, constant.ProjectUsageTokenValidation, constant.ProjectUsageRelationCheck}
+
+// IProjectUsageSvc meters the logins, token validations and relation checks made for a project against
+// its quota, on top of the UsageSvc counters.
+type IProjectUsageSvc interface {
+	// Record meters one request of kind for projectID and fails with ErrRateLimit once the project quota is
+	// exhausted. Requests outside a project (empty or system project) are not metered.
+	Record(ctx context.Context, projectID, kind string) error
+	GetUsage(ctx context.Context, projectID string) (*aggregate.ProjectUsageResp, error)
+}
+
+type ProjectUsageSvc struct {
+	logger      logger.ILogger
+	usageSvc    IUsageSvc
+	projectRepo repository.IProjectRepository
+	quota       aggregate.UsageQuota
+}
+
+func NewProjectUsageSvc(cfg *config.AppConfig, logger logger.ILogger, usageSvc IUsageSvc, projectRepo repository.IProjectRepository) IProjectUsageSvc {
+	return &ProjectUsageSvc{
+		logger:      logger,
+		usageSvc:    usageSvc,
+		projectRepo: projectRepo,
+		quota: aggregate.UsageQuota{
+			Daily:   int64(cfg.ProjectQuota.DailyRequests),
+			Monthly: int64(cfg.ProjectQuota.MonthlyRequests),
+		},
+	}
+}
+
+func (s *ProjectUsageSvc) Record(ctx context.Context, projectID, kind string) error {
+	if projectID == "" || projectID == constant.SystemProjectID {
+		return nil
+	}
+	if _, err := s.usageSvc.Consume(ctx, projectUsageSubject(projectID, ""), s.quota); err != nil {
+		if errorx.GetCode(err) == errorx.ErrRateLimit {
+			logger.WithContext(ctx, s.logger).Warn("[ProjectUsageSvc] project quota exceeded", "projectID", projectID, "kind", kind)
+		}
+		return err
+	}
+	// The breakdown by kind is informational; only the project total is limited.
+	if _, err := s.usageSvc.Consume(ctx, projectUsageSubject(projectID, kind), aggregate.UsageQuota{}); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[ProjectUsageSvc] failed to record usage", "projectID", projectID, "kind", kind, "error", err)
+	}
+	return nil
+}
+
+func (s *ProjectUsageSvc) GetUsage(ctx context.Context, projectID string) (*aggregate.ProjectUsageResp, error) {
+	if s.projectRepo.FindOneById(ctx, projectID) == nil {
+		return nil, errorx.Wrap(errorx.ErrProjectNotFound, nil)
+	}
+	total, err := s.usageSvc.GetUsage(ctx, projectUsageSubject(projectID, ""), s.quota)
+	if err != nil {
+		return nil, err
+	}
+	resp := &aggregate.ProjectUsageResp{
+		ProjectID: projectID,
+		Daily:     total.Daily,
+		Monthly:   total.Monthly,
+		ByKind:    make(map[string]aggregate.UsageCounts, len(projectUsageKinds)),
+	}
+	for _, kind := range projectUsageKinds {
+		usage, err := s.usageSvc.GetUsage(ctx, projectUsageSubject(projectID, kind), aggregate.UsageQuota{})
+		if err != nil {
+			return nil, err
+		}
+		resp.ByKind[kind] = aggregate.UsageCounts{Daily: usage.Daily.Used, Monthly: usage.Monthly.Used}
+	}
+	return resp, nil
+}
+
+// projectUsageSubject is the UsageSvc subject of a project's total requests, or of one kind of them.
+func projectUsageSubject(projectID, kind string) string {
+	if kind == "" {
+		return "project:" + projectID
+	}
+	return "project:" + projectID + ":" + kind
+}
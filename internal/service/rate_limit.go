@@ -0,0 +1,192 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// Default policies for the rate-limited routes, in the RATE_LIMIT_* format.
+const (
+	defaultLoginRateLimit    = "ip:10/1m,user:10/1m,apikey:300/1m"
+	defaultRegisterRateLimit = "ip:5/1m,apikey:100/1m"
+	defaultOTPRateLimit      = "ip:10/1m,user:10/1m,apikey:300/1m"
+)
+
+type IRateLimitSvc interface {
+	// Allow counts one request against the caller's bucket for route and fails with ErrRateLimit, still
+	// returning the bucket state, once it is empty. It returns nil when the route has no limit for the
+	// caller's identity.
+	Allow(ctx context.Context, route string, caller aggregate.RateLimitCaller) (*aggregate.RateLimitResp, error)
+	// Failures returns the most failed attempts at route counted against any of callers in their current
+	// window.
+	Failures(ctx context.Context, route string, callers ...aggregate.RateLimitCaller) (int64, error)
+	// RecordFailure counts a failed attempt at route against each of callers. A caller's count is dropped
+	// window after their first counted failure.
+	RecordFailure(ctx context.Context, route string, window time.Duration, callers ...aggregate.RateLimitCaller) error
+	// ResetFailures drops the failures counted against callers, e.g. once the account signs in.
+	ResetFailures(ctx context.Context, route string, callers ...aggregate.RateLimitCaller) error
+}
+
+// rateLimitPolicy holds a route's bucket for each identity it limits.
+type rateLimitPolicy map[string]cache.TokenBucket
+
+type RateLimitSvc struct {
+	logger   logger.ILogger
+	cache    cache.ICache
+	settings atomic.Pointer[rateLimitSettings]
+}
+
+// rateLimitSettings is the part of the configuration the limiter uses, swapped whole on a reload.
+type rateLimitSettings struct {
+	disabled bool
+	policies map[string]rateLimitPolicy
+}
+
+func NewRateLimitSvc(cfg config.INotifier, logger logger.ILogger, cache cache.ICache) (IRateLimitSvc, error) {
+	settings, err := newRateLimitSettings(cfg.Current())
+	if err != nil {
+		return nil, err
+	}
+	s := &RateLimitSvc{
+		logger: logger,
+		cache:  cache,
+	}
+	s.settings.Store(settings)
+	cfg.Subscribe(func(next *config.AppConfig) {
+		settings, err := newRateLimitSettings(next)
+		if err != nil {
+			logger.Error("Ignoring reloaded rate limits, keeping the current ones", "error", err)
+			return
+		}
+		s.settings.Store(settings)
+	})
+	return s, nil
+}
+
+func newRateLimitSettings(cfg *config.AppConfig) (*rateLimitSettings, error) {
+	specs := map[string]string{
+		constant.RateLimitRouteLogin:    cmp.Or(cfg.RateLimit.Login, defaultLoginRateLimit),
+		constant.RateLimitRouteRegister: cmp.Or(cfg.RateLimit.Register, defaultRegisterRateLimit),
+		constant.RateLimitRouteOTP:      cmp.Or(cfg.RateLimit.OTP, defaultOTPRateLimit),
+	}
+	policies := make(map[string]rateLimitPolicy, len(specs))
+	for route, spec := range specs {
+		policy, err := parseRateLimitPolicy(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit policy for %s: %w", route, err)
+		}
+		policies[route] = policy
+	}
+	return &rateLimitSettings{
+		disabled: cfg.RateLimit.Disabled,
+		policies: policies,
+	}, nil
+}
+
+func (s *RateLimitSvc) Allow(ctx context.Context, route string, caller aggregate.RateLimitCaller) (*aggregate.RateLimitResp, error) {
+	settings := s.settings.Load()
+	bucket, ok := settings.policies[route][caller.Kind]
+	if settings.disabled || !ok {
+		return nil, nil
+	}
+	key := constant.CacheKeyPrefixRateLimit + route + ":" + caller.Kind + ":" + caller.ID
+	result, err := s.cache.WithContext(ctx).TakeToken(key, bucket)
+	if err != nil {
+		// An unavailable limiter must not take sign-in down with it.
+		logger.WithContext(ctx, s.logger).Warn("Failed to check rate limit, allowing the request", "route", route, "error", err)
+		return nil, nil
+	}
+	resp := &aggregate.RateLimitResp{
+		Limit:      bucket.Burst,
+		Remaining:  result.Remaining,
+		RetryAfter: result.RetryAfter,
+	}
+	if !result.Allowed {
+		return resp, errorx.New(errorx.ErrRateLimit, "too many requests; try again later")
+	}
+	return resp, nil
+}
+
+func (s *RateLimitSvc) Failures(ctx context.Context, route string, callers ...aggregate.RateLimitCaller) (int64, error) {
+	var most int64
+	for _, caller := range callers {
+		var n int64
+		if err := s.cache.WithContext(ctx).Get(failuresKey(route, caller), &n); err != nil {
+			if errors.Is(err, cache.ErrCacheNil) {
+				continue
+			}
+			return 0, err
+		}
+		most = max(most, n)
+	}
+	return most, nil
+}
+
+func (s *RateLimitSvc) RecordFailure(ctx context.Context, route string, window time.Duration, callers ...aggregate.RateLimitCaller) error {
+	for _, caller := range callers {
+		if _, err := s.cache.WithContext(ctx).Increment(failuresKey(route, caller), &window); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RateLimitSvc) ResetFailures(ctx context.Context, route string, callers ...aggregate.RateLimitCaller) error {
+	for _, caller := range callers {
+		if err := s.cache.WithContext(ctx).Delete(failuresKey(route, caller)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func failuresKey(route string, caller aggregate.RateLimitCaller) string {
+	return constant.CacheKeyPrefixAuthFailures + route + ":" + caller.Kind + ":" + caller.ID
+}
+
+// parseRateLimitPolicy parses "identity:requests/period,...", e.g. "ip:10/1m,apikey:300/1m". Each bucket
+// holds requests tokens, refilled evenly over period. "off" is a policy without limits.
+func parseRateLimitPolicy(spec string) (rateLimitPolicy, error) {
+	policy := make(rateLimitPolicy)
+	if strings.TrimSpace(spec) == "off" {
+		return policy, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		identity, limit, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("%q is not identity:requests/period", entry)
+		}
+		switch identity {
+		case constant.RateLimitIdentityIP, constant.RateLimitIdentityUser, constant.RateLimitIdentityAPIKey:
+		default:
+			return nil, fmt.Errorf("unknown identity %q", identity)
+		}
+		count, period, ok := strings.Cut(limit, "/")
+		if !ok {
+			return nil, fmt.Errorf("%q is not identity:requests/period", entry)
+		}
+		requests, err := strconv.Atoi(count)
+		if err != nil || requests < 1 {
+			return nil, fmt.Errorf("invalid request count in %q", entry)
+		}
+		window, err := time.ParseDuration(period)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid period in %q", entry)
+		}
+		policy[identity] = cache.TokenBucket{Rate: float64(requests) / window.Seconds(), Burst: requests}
+	}
+	return policy, nil
+}
@@ -1,60 +1,113 @@
 package service
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"slices"
 	"time"
 
+	"github.com/hiamthach108/dreon-auth/config"
 	"github.com/hiamthach108/dreon-auth/internal/aggregate"
 	"github.com/hiamthach108/dreon-auth/internal/errorx"
 	"github.com/hiamthach108/dreon-auth/internal/model"
 	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/condition"
 	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/relationconfig"
 	"github.com/hiamthach108/dreon-auth/pkg/cache"
 	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
 )
 
 type IRelationSvc interface {
 	// Grant and revoke relations
 	GrantRelation(ctx context.Context, req aggregate.GrantRelationReq) (*aggregate.RelationTupleResp, error)
 	RevokeRelation(ctx context.Context, req aggregate.RevokeRelationReq) error
-	BulkGrantRelations(ctx context.Context, req aggregate.BulkGrantRelationReq) ([]aggregate.RelationTupleResp, error)
+	ExtendRelation(ctx context.Context, req aggregate.ExtendRelationReq) (*aggregate.RelationTupleResp, error)
+	BulkGrantRelations(ctx context.Context, req aggregate.BulkGrantRelationReq) (*aggregate.BulkGrantRelationResp, error)
 	BulkRevokeRelations(ctx context.Context, req aggregate.BulkRevokeRelationReq) error
 
+	// Move tuples between environments as NDJSON
+	ExportRelations(ctx context.Context, req aggregate.ExportRelationsReq, w io.Writer) (int, error)
+	ImportRelations(ctx context.Context, req aggregate.ImportRelationsReq, r io.Reader) (*aggregate.ImportRelationsResp, error)
+
 	// Check relations
 	CheckRelation(ctx context.Context, req aggregate.CheckRelationReq) (*aggregate.CheckRelationResp, error)
 
 	// List and expand relations
 	ListRelations(ctx context.Context, req aggregate.ListRelationsReq) (*aggregate.PaginationResp[aggregate.RelationTupleResp], error)
 	ExpandRelation(ctx context.Context, req aggregate.ExpandRelationReq) (*aggregate.ExpandRelationResp, error)
+	ListObjects(ctx context.Context, subjectNamespace, subjectID, namespace, relation string) (*aggregate.ListObjectsResp, error)
+	// ListUserRelations returns every stored tuple naming the user as subject or as object.
+	ListUserRelations(ctx context.Context, userID string) ([]aggregate.RelationTupleResp, error)
+	// RemoveUserRelations permanently deletes the tuples ListUserRelations returns, e.g. when the account is erased.
+	RemoveUserRelations(ctx context.Context, userID string) (int, error)
+
+	// Namespace configuration (userset rewrite rules)
+	ListNamespaces(ctx context.Context) ([]aggregate.RelationNamespaceResp, error)
+	GetNamespace(ctx context.Context, name string) (*aggregate.RelationNamespaceResp, error)
+	UpsertNamespace(ctx context.Context, name string, req aggregate.UpsertRelationNamespaceReq) (*aggregate.RelationNamespaceResp, error)
+	DeleteNamespace(ctx context.Context, name string) error
 
 	// Maintenance
 	CleanupExpiredRelations(ctx context.Context) (int64, error)
 }
 
 type RelationSvc struct {
-	logger    logger.ILogger
-	tupleRepo repository.IRelationTupleRepository
-	cache     cache.ICache
+	logger        logger.ILogger
+	tupleRepo     repository.IRelationTupleRepository
+	namespaceRepo repository.IRelationNamespaceRepository
+	memberRepo    repository.IProjectMemberRepository
+	projectRepo   repository.IProjectRepository
+	usageSvc      IProjectUsageSvc
+	access        IEffectiveAccessSvc
+	cache         cache.ICache
+	maxDepth      int
+	denyTTL       time.Duration
 }
 
 func NewRelationSvc(
+	cfg *config.AppConfig,
 	logger logger.ILogger,
 	tupleRepo repository.IRelationTupleRepository,
+	namespaceRepo repository.IRelationNamespaceRepository,
+	memberRepo repository.IProjectMemberRepository,
+	projectRepo repository.IProjectRepository,
+	usageSvc IProjectUsageSvc,
+	access IEffectiveAccessSvc,
 	cache cache.ICache,
 ) IRelationSvc {
 	return &RelationSvc{
-		logger:    logger,
-		tupleRepo: tupleRepo,
-		cache:     cache,
+		logger:        logger,
+		tupleRepo:     tupleRepo,
+		namespaceRepo: namespaceRepo,
+		memberRepo:    memberRepo,
+		projectRepo:   projectRepo,
+		usageSvc:      usageSvc,
+		access:        access,
+		cache:         cache,
+		maxDepth:      cfg.Relations.MaxCheckDepth,
+		denyTTL:       time.Duration(cfg.Relations.DenyCacheTTLSec) * time.Second,
 	}
 }
 
-// GrantRelation grants a relation by creating a relation tuple
+// GrantRelation grants a relation by creating a relation tuple. With req.Upsert a stored tuple is updated
+// and reactivated instead
 func (s *RelationSvc) GrantRelation(ctx context.Context, req aggregate.GrantRelationReq) (*aggregate.RelationTupleResp, error) {
+	ctx, span := tracing.Start(ctx, "RelationSvc.GrantRelation")
+	defer span.End()
 	if err := s.validateRelationRequest(req); err != nil {
 		return nil, errorx.Wrap(errorx.ErrInvalidPermission, err)
 	}
+	if err := s.checkProjectSubject(ctx, req); err != nil {
+		return nil, err
+	}
 
 	existing, err := s.tupleRepo.FindByTuple(
 		ctx,
@@ -69,35 +122,43 @@ func (s *RelationSvc) GrantRelation(ctx context.Context, req aggregate.GrantRela
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
 
+	if existing != nil && req.Upsert {
+		tuple := reactivateRelationTuple(*existing, newRelationTuple(req))
+		if err := s.tupleRepo.Update(ctx, tuple.ID, tuple, "condition", "expires_at", "is_active", "updated_at"); err != nil {
+			return nil, errorx.Wrap(errorx.ErrGrantPermission, err)
+		}
+		s.cacheRelationTuple(ctx, &tuple)
+		s.invalidateDenials(ctx, tuple)
+		s.access.RefreshRelations(ctx, tuple)
+		logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Relation renewed: %s", tuple.String()))
+		return s.toRelationTupleResp(&tuple), nil
+	}
 	if existing != nil && existing.IsValid() {
 		return nil, errorx.New(errorx.ErrPermissionConflict, "Relation already exists and is active")
 	}
 
-	tuple := &model.RelationTuple{
-		Namespace:        req.Namespace,
-		ObjectID:         req.ObjectID,
-		Relation:         req.Relation,
-		SubjectNamespace: req.SubjectNamespace,
-		SubjectObjectID:  req.SubjectObjectID,
-		SubjectRelation:  req.SubjectRelation,
-		IsActive:         true,
-		ExpiresAt:        req.ExpiresAt,
-	}
-
-	created, err := s.tupleRepo.Create(ctx, tuple)
+	tuple := newRelationTuple(req)
+	created, err := s.tupleRepo.Create(ctx, &tuple)
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrGrantPermission, err)
 	}
 
-	go s.clearRelationTupleCache(created)
+	s.cacheRelationTuple(ctx, created)
+	s.invalidateDenials(ctx, *created)
+	s.access.RefreshRelations(ctx, *created)
 
-	s.logger.Info(fmt.Sprintf("Relation granted: %s", created.String()))
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Relation granted: %s", created.String()))
 
 	return s.toRelationTupleResp(created), nil
 }
 
 // RevokeRelation revokes a relation by deleting the relation tuple
 func (s *RelationSvc) RevokeRelation(ctx context.Context, req aggregate.RevokeRelationReq) error {
+	ctx, span := tracing.Start(ctx, "RelationSvc.RevokeRelation")
+	defer span.End()
+	if err := s.checkProjectWritable(ctx, req.Namespace, req.ObjectID); err != nil {
+		return err
+	}
 	existing, err := s.tupleRepo.FindByTuple(
 		ctx,
 		req.Namespace,
@@ -128,46 +189,135 @@ func (s *RelationSvc) RevokeRelation(ctx context.Context, req aggregate.RevokeRe
 		return errorx.Wrap(errorx.ErrRevokePermission, err)
 	}
 
-	go s.clearRelationTupleCache(existing)
+	s.clearRelationTupleCache(ctx, existing)
+	s.invalidateDenials(ctx, *existing)
+	s.access.RefreshRelations(ctx, *existing)
 
-	s.logger.Info(fmt.Sprintf("Relation revoked: %s", existing.String()))
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Relation revoked: %s", existing.String()))
 
 	return nil
 }
 
-// BulkGrantRelations grants multiple relations in a single transaction
-func (s *RelationSvc) BulkGrantRelations(ctx context.Context, req aggregate.BulkGrantRelationReq) ([]aggregate.RelationTupleResp, error) {
-	results := make([]aggregate.RelationTupleResp, 0, len(req.Relations))
-	tuples := make([]model.RelationTuple, 0, len(req.Relations))
+// ExtendRelation moves the expiry of a stored tuple, reactivating it if it had expired
+func (s *RelationSvc) ExtendRelation(ctx context.Context, req aggregate.ExtendRelationReq) (*aggregate.RelationTupleResp, error) {
+	ctx, span := tracing.Start(ctx, "RelationSvc.ExtendRelation")
+	defer span.End()
+	if err := s.checkProjectWritable(ctx, req.Namespace, req.ObjectID); err != nil {
+		return nil, err
+	}
 
-	for _, relReq := range req.Relations {
+	expiresAt := req.ExpiresAt
+	if req.TTLSeconds > 0 {
+		if expiresAt != nil {
+			return nil, errorx.New(errorx.ErrBadRequest, "Set either expiresAt or ttlSeconds")
+		}
+		at := time.Now().Add(time.Duration(req.TTLSeconds) * time.Second)
+		expiresAt = &at
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, errorx.New(errorx.ErrBadRequest, "expiresAt must be in the future")
+	}
+
+	existing, err := s.tupleRepo.FindByTuple(
+		ctx,
+		req.Namespace,
+		req.ObjectID,
+		req.Relation,
+		req.SubjectNamespace,
+		req.SubjectObjectID,
+		req.SubjectRelation,
+	)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if existing == nil {
+		return nil, errorx.New(errorx.ErrPermissionNotFound, "Relation not found")
+	}
+
+	existing.ExpiresAt = expiresAt
+	if err := s.tupleRepo.Update(ctx, existing.ID, *existing, "expires_at", "updated_at"); err != nil {
+		return nil, errorx.Wrap(errorx.ErrGrantPermission, err)
+	}
+	if existing.IsActive {
+		s.cacheRelationTuple(ctx, existing)
+	}
+	s.invalidateDenials(ctx, *existing)
+	s.access.RefreshRelations(ctx, *existing)
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Relation extended: %s", existing.String()))
+
+	return s.toRelationTupleResp(existing), nil
+}
+
+// BulkGrantRelations grants multiple relations in a single transaction. Every item is validated and looked
+// up first: invalid items are reported as errors, items already active (or repeating an earlier item) are
+// skipped, and the rest are saved together. Revoked or expired tuples are reactivated.
+func (s *RelationSvc) BulkGrantRelations(ctx context.Context, req aggregate.BulkGrantRelationReq) (*aggregate.BulkGrantRelationResp, error) {
+	ctx, span := tracing.Start(ctx, "RelationSvc.BulkGrantRelations")
+	defer span.End()
+
+	resp := &aggregate.BulkGrantRelationResp{Results: make([]aggregate.BulkGrantRelationResult, len(req.Relations))}
+	var creates, updates []model.RelationTuple
+	var createdAt, updatedAt []int
+	seen := make(map[string]bool)
+	for i, relReq := range req.Relations {
+		result := &resp.Results[i]
+		result.Index = i
 		if err := s.validateRelationRequest(relReq); err != nil {
-			return nil, errorx.Wrap(errorx.ErrInvalidPermission, err)
-		}
-
-		tuples = append(tuples, model.RelationTuple{
-			Namespace:        relReq.Namespace,
-			ObjectID:         relReq.ObjectID,
-			Relation:         relReq.Relation,
-			SubjectNamespace: relReq.SubjectNamespace,
-			SubjectObjectID:  relReq.SubjectObjectID,
-			SubjectRelation:  relReq.SubjectRelation,
-			IsActive:         true,
-			ExpiresAt:        relReq.ExpiresAt,
-		})
+			result.Status, result.Error = constant.RelationGrantError, err.Error()
+			resp.Failed++
+			continue
+		}
+		if err := s.checkProjectSubject(ctx, relReq); err != nil {
+			result.Status, result.Error = constant.RelationGrantError, err.Error()
+			resp.Failed++
+			continue
+		}
+
+		tuple := newRelationTuple(relReq)
+		if seen[tuple.String()] {
+			result.Status = constant.RelationGrantSkipped
+			resp.Skipped++
+			continue
+		}
+		seen[tuple.String()] = true
+
+		existing, err := s.tupleRepo.FindByTuple(ctx, tuple.Namespace, tuple.ObjectID, tuple.Relation, tuple.SubjectNamespace, tuple.SubjectObjectID, tuple.SubjectRelation)
+		if err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		switch {
+		case existing == nil:
+			creates = append(creates, tuple)
+			createdAt = append(createdAt, i)
+		case existing.IsValid():
+			result.Status, result.Relation = constant.RelationGrantSkipped, s.toRelationTupleResp(existing)
+			resp.Skipped++
+		default:
+			updates = append(updates, reactivateRelationTuple(*existing, tuple))
+			updatedAt = append(updatedAt, i)
+		}
 	}
 
-	if err := s.tupleRepo.BulkCreate(ctx, tuples); err != nil {
+	if err := s.tupleRepo.SaveBatch(ctx, creates, updates); err != nil {
 		return nil, errorx.Wrap(errorx.ErrGrantPermission, err)
 	}
 
-	for i := range tuples {
-		results = append(results, *s.toRelationTupleResp(&tuples[i]))
+	for j, i := range createdAt {
+		s.cacheRelationTuple(ctx, &creates[j])
+		resp.Results[i].Status, resp.Results[i].Relation = constant.RelationGrantCreated, s.toRelationTupleResp(&creates[j])
+	}
+	for j, i := range updatedAt {
+		s.cacheRelationTuple(ctx, &updates[j])
+		resp.Results[i].Status, resp.Results[i].Relation = constant.RelationGrantCreated, s.toRelationTupleResp(&updates[j])
 	}
+	s.invalidateDenials(ctx, slices.Concat(creates, updates)...)
+	s.access.RefreshRelations(ctx, slices.Concat(creates, updates)...)
+	resp.Created = len(creates) + len(updates)
 
-	s.logger.Info(fmt.Sprintf("Bulk granted %d relations", len(tuples)))
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Bulk granted %d relations (%d skipped, %d failed)", resp.Created, resp.Skipped, resp.Failed))
 
-	return results, nil
+	return resp, nil
 }
 
 // BulkRevokeRelations revokes multiple relations
@@ -180,44 +330,191 @@ func (s *RelationSvc) BulkRevokeRelations(ctx context.Context, req aggregate.Bul
 		}
 	}
 
-	s.logger.Info(fmt.Sprintf("Bulk revoked %d relations", len(req.Relations)))
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Bulk revoked %d relations", len(req.Relations)))
 
 	return nil
 }
 
-// CheckRelation checks if a subject has a specific relation on an object
-func (s *RelationSvc) CheckRelation(ctx context.Context, req aggregate.CheckRelationReq) (*aggregate.CheckRelationResp, error) {
+// relationExportBatchSize is how many tuples ExportRelations reads per query.
+const relationExportBatchSize = 500
 
-	var allowed bool
-	cacheKey := s.buildCacheKey(&model.RelationTuple{
-		Namespace:        req.Namespace,
-		ObjectID:         req.ObjectID,
-		Relation:         req.Relation,
-		SubjectNamespace: req.SubjectNamespace,
-		SubjectObjectID:  req.SubjectObjectID,
+// ExportRelations writes the active, unexpired tuples matching req to w as NDJSON, one GrantRelationReq per
+// line, so the output can be fed to ImportRelations in another environment. It returns how many were written.
+func (s *RelationSvc) ExportRelations(ctx context.Context, req aggregate.ExportRelationsReq, w io.Writer) (int, error) {
+	ctx, span := tracing.Start(ctx, "RelationSvc.ExportRelations")
+	defer span.End()
+
+	filters := map[string]interface{}{
+		"namespace":         req.Namespace,
+		"relation":          req.Relation,
+		"subject_namespace": req.SubjectNamespace,
+	}
+	enc := json.NewEncoder(w)
+	count := 0
+	err := s.tupleRepo.FindInBatches(ctx, filters, relationExportBatchSize, func(tuples []model.RelationTuple) error {
+		for i := range tuples {
+			if !tuples[i].IsValid() {
+				continue
+			}
+			if err := enc.Encode(toGrantRelationReq(&tuples[i])); err != nil {
+				return err
+			}
+			count++
+		}
+		if f, ok := w.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+		return nil
 	})
+	if err != nil {
+		return count, errorx.Wrap(errorx.ErrInternal, err)
+	}
 
-	err := s.cache.Get(s.buildCacheKey(&model.RelationTuple{
-		Namespace:        req.Namespace,
-		ObjectID:         req.ObjectID,
-		Relation:         req.Relation,
-		SubjectNamespace: req.SubjectNamespace,
-		SubjectObjectID:  req.SubjectObjectID,
-	}), &allowed)
-	if err == nil {
-		return &aggregate.CheckRelationResp{Allowed: allowed}, nil
-	} else if err != cache.ErrCacheNil {
-		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Exported %d relations", count))
+
+	return count, nil
+}
+
+// ImportRelations grants the tuples read from r as NDJSON, one GrantRelationReq per line. Every line is
+// validated before anything is written: when a line is malformed, or conflicts with a stored tuple under the
+// "fail" strategy, the import is rejected as a whole and the report lists the offending lines. Repeated lines
+// are skipped. With DryRun the report is returned without writing.
+func (s *RelationSvc) ImportRelations(ctx context.Context, req aggregate.ImportRelationsReq, r io.Reader) (*aggregate.ImportRelationsResp, error) {
+	ctx, span := tracing.Start(ctx, "RelationSvc.ImportRelations")
+	defer span.End()
+
+	onConflict := req.OnConflict
+	if onConflict == "" {
+		onConflict = constant.RelationImportSkip
+	}
+	switch onConflict {
+	case constant.RelationImportSkip, constant.RelationImportOverwrite, constant.RelationImportFail:
+	default:
+		return nil, errorx.New(errorx.ErrBadRequest, "onConflict must be skip, overwrite or fail")
+	}
+
+	resp := &aggregate.ImportRelationsResp{DryRun: req.DryRun}
+	reject := func(line int, format string, args ...any) {
+		resp.Errors = append(resp.Errors, aggregate.ImportRelationLineErr{Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var creates, updates []model.RelationTuple
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		resp.Total++
+
+		var item aggregate.GrantRelationReq
+		if err := json.Unmarshal(text, &item); err != nil {
+			reject(line, "invalid JSON: %v", err)
+			continue
+		}
+		if err := s.validateRelationRequest(item); err != nil {
+			reject(line, "%v", err)
+			continue
+		}
+		if err := s.checkProjectSubject(ctx, item); err != nil {
+			reject(line, "%v", err)
+			continue
+		}
+
+		tuple := newRelationTuple(item)
+		if seen[tuple.String()] {
+			resp.Skipped++
+			continue
+		}
+		seen[tuple.String()] = true
+
+		existing, err := s.tupleRepo.FindByTuple(ctx, tuple.Namespace, tuple.ObjectID, tuple.Relation, tuple.SubjectNamespace, tuple.SubjectObjectID, tuple.SubjectRelation)
+		if err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		switch {
+		case existing == nil:
+			creates = append(creates, tuple)
+		case existing.IsValid() && onConflict == constant.RelationImportSkip:
+			resp.Skipped++
+		case existing.IsValid() && onConflict == constant.RelationImportFail:
+			reject(line, "relation already exists: %s", tuple.String())
+		default:
+			// Revoked or expired tuples are replaced like an overwrite.
+			updates = append(updates, reactivateRelationTuple(*existing, tuple))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errorx.New(errorx.ErrBadRequest, fmt.Sprintf("failed to read relations: %v", err))
+	}
+
+	resp.Created = len(creates)
+	resp.Updated = len(updates)
+	if req.DryRun || len(resp.Errors) > 0 {
+		return resp, nil
+	}
+
+	if err := s.tupleRepo.SaveBatch(ctx, creates, updates); err != nil {
+		return nil, errorx.Wrap(errorx.ErrGrantPermission, err)
+	}
+	for _, tuples := range [][]model.RelationTuple{creates, updates} {
+		for i := range tuples {
+			s.cacheRelationTuple(ctx, &tuples[i])
+		}
+	}
+	s.invalidateDenials(ctx, slices.Concat(creates, updates)...)
+	s.access.RefreshRelations(ctx, slices.Concat(creates, updates)...)
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Imported relations: %d created, %d updated, %d skipped", resp.Created, resp.Updated, resp.Skipped))
+
+	return resp, nil
+}
+
+// CheckRelation checks if a subject has a specific relation on an object, following the userset rewrite
+// rules configured for the object's namespace (see UpsertNamespace) and expanding userset subjects such as
+// group:eng#member, up to the configured max depth. Tuples with a condition only count when it holds for
+// req.Context; "ip" defaults to the caller's IP and "time" to now. When the effective access table is
+// served, a subject it lists is allowed without walking the tuples. With RELATION_DENY_CACHE_TTL_SEC set,
+// denials that no condition took part in are cached until a tuple on the same object or subject changes.
+func (s *RelationSvc) CheckRelation(ctx context.Context, req aggregate.CheckRelationReq) (*aggregate.CheckRelationResp, error) {
+	ctx, span := tracing.Start(ctx, "RelationSvc.CheckRelation")
+	defer span.End()
+	if caller := payloadFromContext(ctx); caller != nil {
+		if err := s.usageSvc.Record(ctx, caller.ProjectID, constant.ProjectUsageRelationCheck); err != nil {
+			return nil, err
+		}
+	}
+	if s.access.Serving() {
+		ok, err := s.access.HoldsRelation(ctx, req.Namespace, req.ObjectID, req.Relation, req.SubjectNamespace, req.SubjectObjectID)
+		if err != nil {
+			logger.WithContext(ctx, s.logger).Warn("Effective access lookup failed, checking the tuples", "error", err)
+		}
+		if ok {
+			return &aggregate.CheckRelationResp{Allowed: true}, nil
+		}
+	}
+	var denial *deniedCheck
+	if s.denyTTL > 0 {
+		var resp *aggregate.CheckRelationResp
+		if resp, denial = s.cachedDenial(ctx, req); resp != nil {
+			return resp, nil
+		}
 	}
 
-	allowed, err = s.tupleRepo.CheckPermission(
+	tuples := s.checkTuples(ctx, req.Context)
+	checker := &relationconfig.Checker{Tuples: tuples, Configs: s.namespaceConfigs(), MaxDepth: s.maxDepth}
+	allowed, err := checker.Check(
 		ctx,
-		req.Namespace,
-		req.ObjectID,
+		relationconfig.Object{Namespace: req.Namespace, ObjectID: req.ObjectID},
 		req.Relation,
-		req.SubjectNamespace,
-		req.SubjectObjectID,
+		relationconfig.Object{Namespace: req.SubjectNamespace, ObjectID: req.SubjectObjectID},
 	)
+	if errors.Is(err, relationconfig.ErrMaxDepthExceeded) {
+		logger.WithContext(ctx, s.logger).Warn("Relation check exceeded max depth", "namespace", req.Namespace, "objectId", req.ObjectID, "relation", req.Relation)
+		return &aggregate.CheckRelationResp{Reason: "Relation graph is too deep to resolve"}, nil
+	}
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
@@ -225,38 +522,348 @@ func (s *RelationSvc) CheckRelation(ctx context.Context, req aggregate.CheckRela
 	resp := &aggregate.CheckRelationResp{
 		Allowed: allowed,
 	}
-
-	// set cache for the relation tuple
-	ttl := constant.CacheDefaultTTL
-	if err := s.cache.Set(cacheKey, resp, &ttl); err != nil {
-		return nil, errorx.Wrap(errorx.ErrInternal, err)
-	}
-
 	if !allowed {
 		resp.Reason = "Relation not found or expired"
+		if tuples.missingContext != "" {
+			resp.Reason = "Condition requires context: " + tuples.missingContext
+		}
+		if denial != nil && !tuples.conditional {
+			denial.Reason = resp.Reason
+			s.cacheDenial(ctx, req, denial)
+		}
 	}
 
 	return resp, nil
 }
 
-// ListRelations lists relations with optional filters
-func (s *RelationSvc) ListRelations(ctx context.Context, req aggregate.ListRelationsReq) (*aggregate.PaginationResp[aggregate.RelationTupleResp], error) {
-	pageSize := req.PageSize
-	if pageSize <= 0 {
-		pageSize = 10
+// deniedCheck is a cached denial. It stands while the change stamps of its object and subject (see
+// invalidateDenials) are the ones read before the check ran.
+type deniedCheck struct {
+	ObjectStamp  int64  `json:"objectStamp"`
+	SubjectStamp int64  `json:"subjectStamp"`
+	Reason       string `json:"reason"`
+}
+
+// cachedDenial returns the cached denial of req if it still stands. Otherwise it returns the current stamps
+// to cache a new denial under, or nil when they could not be read.
+func (s *RelationSvc) cachedDenial(ctx context.Context, req aggregate.CheckRelationReq) (*aggregate.CheckRelationResp, *deniedCheck) {
+	objectStamp, err := s.changeStamp(ctx, req.Namespace, req.ObjectID)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to read relation change stamp", "error", err)
+		return nil, nil
+	}
+	subjectStamp, err := s.changeStamp(ctx, req.SubjectNamespace, req.SubjectObjectID)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to read relation change stamp", "error", err)
+		return nil, nil
+	}
+	current := &deniedCheck{ObjectStamp: objectStamp, SubjectStamp: subjectStamp}
+	var cached deniedCheck
+	if err := s.cache.WithContext(ctx).Get(deniedCheckKey(req), &cached); err == nil &&
+		cached.ObjectStamp == current.ObjectStamp && cached.SubjectStamp == current.SubjectStamp {
+		return &aggregate.CheckRelationResp{Reason: cached.Reason}, nil
+	}
+	return nil, current
+}
+
+func (s *RelationSvc) cacheDenial(ctx context.Context, req aggregate.CheckRelationReq, denial *deniedCheck) {
+	if err := s.cache.WithContext(ctx).Set(deniedCheckKey(req), denial, &s.denyTTL); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to cache relation denial", "error", err)
+	}
+}
+
+// changeStamp returns when a tuple naming namespace:objectID last changed, or 0 if none did within the deny
+// cache TTL.
+func (s *RelationSvc) changeStamp(ctx context.Context, namespace, objectID string) (int64, error) {
+	var stamp int64
+	err := s.cache.WithContext(ctx).Get(constant.CacheKeyPrefixRelationStamp+namespace+":"+objectID, &stamp)
+	if err == cache.ErrCacheNil {
+		return 0, nil
+	}
+	return stamp, err
+}
+
+// invalidateDenials stamps the objects and subjects of changed tuples, so the denials cached for checks on
+// them no longer stand. Stamps live as long as a denial, so one never comes back to a value a denial holds.
+func (s *RelationSvc) invalidateDenials(ctx context.Context, tuples ...model.RelationTuple) {
+	if s.denyTTL <= 0 {
+		return
+	}
+	stamp := time.Now().UnixNano()
+	seen := make(map[string]bool)
+	for _, tuple := range tuples {
+		for _, entity := range []string{tuple.Namespace + ":" + tuple.ObjectID, tuple.SubjectNamespace + ":" + tuple.SubjectObjectID} {
+			if seen[entity] {
+				continue
+			}
+			seen[entity] = true
+			if err := s.cache.WithContext(ctx).Set(constant.CacheKeyPrefixRelationStamp+entity, stamp, &s.denyTTL); err != nil {
+				// Without the stamp a cached denial would outlive the grant, so drop them all.
+				logger.WithContext(ctx, s.logger).Warn("Failed to stamp relation change", "entity", entity, "error", err)
+				s.clearDenials(ctx)
+				return
+			}
+		}
+	}
+}
+
+// clearDenials drops every cached denial, e.g. when rewrite rules change.
+func (s *RelationSvc) clearDenials(ctx context.Context) {
+	if s.denyTTL <= 0 {
+		return
+	}
+	if err := s.cache.WithContext(ctx).ClearWithPrefix(constant.CacheKeyPrefixRelationDeny); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to clear cached relation denials", "error", err)
+	}
+}
+
+func deniedCheckKey(req aggregate.CheckRelationReq) string {
+	return fmt.Sprintf("%s%s:%s#%s@%s:%s", constant.CacheKeyPrefixRelationDeny,
+		req.Namespace, req.ObjectID, req.Relation, req.SubjectNamespace, req.SubjectObjectID)
+}
+
+// directTuple is the cached state of one tuple for relation checks. Misses are cached too, so grants must
+// write through (cacheRelationTuple) and revokes invalidate (clearRelationTupleCache).
+type directTuple struct {
+	Found     bool       `json:"found"`
+	Condition string     `json:"condition,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// holds reports whether the cached tuple still grants access, leaving its condition aside.
+func (d directTuple) holds(now time.Time) bool {
+	return d.Found && (d.ExpiresAt == nil || d.ExpiresAt.After(now))
+}
+
+// relationTuples is the relationconfig.TupleReader of one check: tuples whose condition fails against vars
+// are left out, and the first context key a condition lacked is kept for the response.
+type relationTuples struct {
+	svc            *RelationSvc
+	vars           condition.Context
+	missingContext string
+	// conditional is set once a condition was evaluated, so the result depends on vars.
+	conditional bool
+}
+
+var _ relationconfig.TupleReader = (*relationTuples)(nil)
+
+func (s *RelationSvc) checkTuples(ctx context.Context, vars map[string]any) *relationTuples {
+	merged := condition.Context{}
+	if ip, ok := ctx.Value(constant.ContextKeyClientIP).(string); ok && ip != "" {
+		merged[condition.KeyIP] = ip
+	}
+	maps.Copy(merged, vars)
+	return &relationTuples{svc: s, vars: merged}
+}
+
+// holds evaluates a tuple condition. Malformed conditions and type errors count as not holding.
+func (t *relationTuples) holds(ctx context.Context, tuple, expr string) bool {
+	if expr == "" {
+		return true
+	}
+	t.conditional = true
+	ok, err := condition.Evaluate(expr, t.vars)
+	if key, missing := condition.IsMissingContext(err); missing {
+		if t.missingContext == "" {
+			t.missingContext = key
+		}
+	} else if err != nil {
+		logger.WithContext(ctx, t.svc.logger).Warn("Relation condition failed", "tuple", tuple, "error", err)
 	}
-	if pageSize > 100 {
-		pageSize = 100
+	return ok && err == nil
+}
+
+// HasTuple reports whether the tuple is stored directly and its condition holds. Lookups, including misses,
+// are cached per tuple for CacheDefaultTTL; grants and revokes keep the entry current.
+func (t *relationTuples) HasTuple(ctx context.Context, namespace, objectID, relation, subjectNamespace, subjectObjectID string) (bool, error) {
+	s := t.svc
+	key := &model.RelationTuple{
+		Namespace:        namespace,
+		ObjectID:         objectID,
+		Relation:         relation,
+		SubjectNamespace: subjectNamespace,
+		SubjectObjectID:  subjectObjectID,
+	}
+	cacheKey := s.buildCacheKey(key)
+
+	var direct directTuple
+	err := s.cache.WithContext(ctx).Get(cacheKey, &direct)
+	if err == cache.ErrCacheNil {
+		tuple, err := s.tupleRepo.FindByTuple(ctx, namespace, objectID, relation, subjectNamespace, subjectObjectID, "")
+		if err != nil {
+			return false, err
+		}
+		if tuple != nil && tuple.IsValid() {
+			direct = directTuple{Found: true, Condition: tuple.Condition, ExpiresAt: tuple.ExpiresAt}
+		}
+
+		// set cache for the relation tuple
+		ttl := constant.CacheDefaultTTL
+		if err := s.cache.WithContext(ctx).Set(cacheKey, direct, &ttl); err != nil {
+			logger.WithContext(ctx, s.logger).Warn("Failed to cache relation tuple", "tuple", key.String(), "error", err)
+		}
+	} else if err != nil {
+		return false, err
 	}
 
-	page := req.Page
-	if page <= 0 {
-		page = 1
+	return direct.holds(time.Now()) && t.holds(ctx, key.String(), direct.Condition), nil
+}
+
+// RelatedObjects returns the subjects of namespace:objectID#relation, which tuple-to-userset rewrites
+// treat as objects (e.g. the parent folder of a document).
+func (t *relationTuples) RelatedObjects(ctx context.Context, namespace, objectID, relation string) ([]relationconfig.Object, error) {
+	tuples, err := t.svc.tupleRepo.ExpandSubjects(ctx, namespace, objectID, relation)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]relationconfig.Object, 0, len(tuples))
+	for _, tuple := range tuples {
+		if t.holds(ctx, tuple.String(), tuple.Condition) {
+			objects = append(objects, relationconfig.Object{Namespace: tuple.SubjectNamespace, ObjectID: tuple.SubjectObjectID})
+		}
 	}
+	return objects, nil
+}
 
-	offset := (page - 1) * pageSize
-	if offset < 0 {
-		offset = 0
+// Usersets returns the userset subjects of namespace:objectID#relation, which checks expand transitively.
+func (t *relationTuples) Usersets(ctx context.Context, namespace, objectID, relation string) ([]relationconfig.Userset, error) {
+	tuples, err := t.svc.tupleRepo.ListUsersets(ctx, namespace, objectID, relation)
+	if err != nil {
+		return nil, err
+	}
+	usersets := make([]relationconfig.Userset, 0, len(tuples))
+	for _, tuple := range tuples {
+		if !t.holds(ctx, tuple.String(), tuple.Condition) {
+			continue
+		}
+		usersets = append(usersets, relationconfig.Userset{
+			Object:   relationconfig.Object{Namespace: tuple.SubjectNamespace, ObjectID: tuple.SubjectObjectID},
+			Relation: tuple.SubjectRelation,
+		})
+	}
+	return usersets, nil
+}
+
+// Containing returns the object relations granted directly to subject, which ListObjects walks backwards.
+// Conditions are left to the Check that confirms each candidate.
+func (t *relationTuples) Containing(ctx context.Context, subject relationconfig.Userset) ([]relationconfig.Userset, error) {
+	tuples, err := t.svc.tupleRepo.FindBySubject(ctx, subject.Namespace, subject.ObjectID, subject.Relation)
+	if err != nil {
+		return nil, err
+	}
+	usersets := make([]relationconfig.Userset, 0, len(tuples))
+	for _, tuple := range tuples {
+		usersets = append(usersets, relationconfig.Userset{
+			Object:   relationconfig.Object{Namespace: tuple.Namespace, ObjectID: tuple.ObjectID},
+			Relation: tuple.Relation,
+		})
+	}
+	return usersets, nil
+}
+
+// namespaceConfigs loads namespace configurations for one check, reading each namespace at most once.
+func (s *RelationSvc) namespaceConfigs() relationconfig.ConfigSource {
+	loaded := make(map[string]*relationconfig.Config)
+	return func(ctx context.Context, namespace string) (*relationconfig.Config, error) {
+		if cfg, ok := loaded[namespace]; ok {
+			return cfg, nil
+		}
+		var cfg *relationconfig.Config
+		if ns := s.namespaceRepo.FindByName(ctx, namespace); ns != nil {
+			cfg = ns.Rewrites()
+		}
+		loaded[namespace] = cfg
+		return cfg, nil
+	}
+}
+
+// ListNamespaces returns every configured namespace.
+func (s *RelationSvc) ListNamespaces(ctx context.Context) ([]aggregate.RelationNamespaceResp, error) {
+	namespaces, err := s.namespaceRepo.FindAll(ctx)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	items := make([]aggregate.RelationNamespaceResp, len(namespaces))
+	for i := range namespaces {
+		items[i].FromModel(&namespaces[i])
+	}
+	return items, nil
+}
+
+// GetNamespace returns the rewrite rules of a namespace.
+func (s *RelationSvc) GetNamespace(ctx context.Context, name string) (*aggregate.RelationNamespaceResp, error) {
+	ns := s.namespaceRepo.FindByName(ctx, name)
+	if ns == nil {
+		return nil, errorx.Wrap(errorx.ErrNamespaceNotFound, nil)
+	}
+	var resp aggregate.RelationNamespaceResp
+	resp.FromModel(ns)
+	return &resp, nil
+}
+
+// UpsertNamespace creates or replaces the rewrite rules of a namespace. Checks use them immediately.
+func (s *RelationSvc) UpsertNamespace(ctx context.Context, name string, req aggregate.UpsertRelationNamespaceReq) (*aggregate.RelationNamespaceResp, error) {
+	cfg := relationconfig.Config{Relations: req.Relations}
+	if err := cfg.Validate(); err != nil {
+		return nil, errorx.New(errorx.ErrBadRequest, err.Error())
+	}
+
+	var actorID string
+	if p := payloadFromContext(ctx); p != nil {
+		actorID = p.UserID
+	}
+
+	ns := s.namespaceRepo.FindByName(ctx, name)
+	if ns == nil {
+		ns = &model.RelationNamespace{Name: name}
+		ns.SetRewrites(cfg)
+		ns.CreatedBy = actorID
+		ns.UpdatedBy = actorID
+		created, err := s.namespaceRepo.Create(ctx, ns)
+		if err != nil {
+			logger.WithContext(ctx, s.logger).Error("[RelationSvc] failed to create namespace", "namespace", name, "error", err)
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		ns = created
+	} else {
+		ns.SetRewrites(cfg)
+		ns.UpdatedBy = actorID
+		if err := s.namespaceRepo.Update(ctx, ns.ID, *ns, "config", "updated_by", "updated_at"); err != nil {
+			logger.WithContext(ctx, s.logger).Error("[RelationSvc] failed to update namespace", "namespace", name, "error", err)
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+	}
+
+	s.clearDenials(ctx)
+	s.access.RefreshNamespace(ctx, name)
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Relation namespace configured: %s", name))
+
+	var resp aggregate.RelationNamespaceResp
+	resp.FromModel(ns)
+	return &resp, nil
+}
+
+// DeleteNamespace removes the rewrite rules of a namespace, so its relations only match stored tuples.
+func (s *RelationSvc) DeleteNamespace(ctx context.Context, name string) error {
+	ns := s.namespaceRepo.FindByName(ctx, name)
+	if ns == nil {
+		return errorx.Wrap(errorx.ErrNamespaceNotFound, nil)
+	}
+	if err := s.namespaceRepo.DeleteById(ctx, ns.ID); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[RelationSvc] failed to delete namespace", "namespace", name, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	s.clearDenials(ctx)
+	s.access.RefreshNamespace(ctx, name)
+	return nil
+}
+
+// ListRelations lists relations with optional filters
+func (s *RelationSvc) ListRelations(ctx context.Context, req aggregate.ListRelationsReq) (*aggregate.PaginationResp[aggregate.RelationTupleResp], error) {
+	q, err := newPageQuery(req.PaginationReq)
+	if err != nil {
+		return nil, err
 	}
 
 	filters := make(map[string]interface{})
@@ -276,30 +883,22 @@ func (s *RelationSvc) ListRelations(ctx context.Context, req aggregate.ListRelat
 		filters["subject_object_id"] = req.SubjectObjectID
 	}
 
-	tuples, total, err := s.tupleRepo.ListWithFilters(ctx, filters, pageSize, offset)
+	tuples, total, err := s.tupleRepo.ListWithFilters(repository.WithReplicaReads(ctx), filters, q.repo)
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
 
-	items := make([]aggregate.RelationTupleResp, 0, len(tuples))
-	for i := range tuples {
-		items = append(items, *s.toRelationTupleResp(&tuples[i]))
-	}
-
-	hasNext := int64(offset+pageSize) < total
-
-	return &aggregate.PaginationResp[aggregate.RelationTupleResp]{
-		Items:    items,
-		Total:    total,
-		Page:     page,
-		PageSize: pageSize,
-		HasNext:  hasNext,
-	}, nil
+	return pageResp(q, tuples, total,
+		func(m *model.RelationTuple) *model.BaseModel { return &m.BaseModel },
+		func(m *model.RelationTuple) aggregate.RelationTupleResp { return *s.toRelationTupleResp(m) },
+	), nil
 }
 
 // ExpandRelation expands a relation to get all subjects with that relation
 func (s *RelationSvc) ExpandRelation(ctx context.Context, req aggregate.ExpandRelationReq) (*aggregate.ExpandRelationResp, error) {
-	tuples, err := s.tupleRepo.ExpandSubjects(ctx, req.Namespace, req.ObjectID, req.Relation)
+	ctx, span := tracing.Start(ctx, "RelationSvc.ExpandRelation")
+	defer span.End()
+	tuples, err := s.tupleRepo.ExpandSubjects(repository.WithReplicaReads(ctx), req.Namespace, req.ObjectID, req.Relation)
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
@@ -319,6 +918,86 @@ func (s *RelationSvc) ExpandRelation(ctx context.Context, req aggregate.ExpandRe
 	}, nil
 }
 
+// ListObjects lists the objects of namespace on which the subject holds relation, including through
+// usersets and the namespace's rewrite rules
+func (s *RelationSvc) ListObjects(ctx context.Context, subjectNamespace, subjectID, namespace, relation string) (*aggregate.ListObjectsResp, error) {
+	ctx, span := tracing.Start(ctx, "RelationSvc.ListObjects")
+	defer span.End()
+
+	checker := &relationconfig.Checker{Tuples: s.checkTuples(ctx, nil), Configs: s.namespaceConfigs(), MaxDepth: s.maxDepth}
+	objectIDs, err := checker.ListObjects(ctx, namespace, relation, relationconfig.Object{Namespace: subjectNamespace, ObjectID: subjectID})
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	return &aggregate.ListObjectsResp{
+		ObjectIDs: objectIDs,
+		Count:     len(objectIDs),
+	}, nil
+}
+
+// ListUserRelations returns the tuples with the user as subject or as object, including expired and inactive ones
+func (s *RelationSvc) ListUserRelations(ctx context.Context, userID string) ([]aggregate.RelationTupleResp, error) {
+	tuples, err := s.userTuples(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]aggregate.RelationTupleResp, len(tuples))
+	for i := range tuples {
+		resp[i] = *s.toRelationTupleResp(&tuples[i])
+	}
+	return resp, nil
+}
+
+// RemoveUserRelations hard-deletes the tuples with the user as subject or as object and drops their cached checks
+func (s *RelationSvc) RemoveUserRelations(ctx context.Context, userID string) (int, error) {
+	ctx, span := tracing.Start(ctx, "RelationSvc.RemoveUserRelations")
+	defer span.End()
+	tuples, err := s.userTuples(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	for i := range tuples {
+		if err := s.tupleRepo.HardDeleteById(ctx, tuples[i].ID); err != nil {
+			return i, errorx.Wrap(errorx.ErrRevokePermission, err)
+		}
+		s.clearRelationTupleCache(ctx, &tuples[i])
+	}
+	s.invalidateDenials(ctx, tuples...)
+	s.access.RefreshRelations(ctx, tuples...)
+	if len(tuples) > 0 {
+		logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Removed %d relations of user %s", len(tuples), userID))
+	}
+	return len(tuples), nil
+}
+
+// userTuples loads all the tuples of user:<userID> on both sides
+func (s *RelationSvc) userTuples(ctx context.Context, userID string) ([]model.RelationTuple, error) {
+	asSubject, _, err := s.tupleRepo.ListWithFilters(ctx, map[string]interface{}{
+		"subject_namespace": constant.RelationNamespaceUser,
+		"subject_object_id": userID,
+	}, repository.Page{Limit: -1})
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	asObject, _, err := s.tupleRepo.ListWithFilters(ctx, map[string]interface{}{
+		"namespace": constant.RelationNamespaceUser,
+		"object_id": userID,
+	}, repository.Page{Limit: -1})
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	tuples := asSubject
+	for _, tuple := range asObject {
+		// user:<id> related to itself is already in asSubject
+		if tuple.SubjectNamespace == constant.RelationNamespaceUser && tuple.SubjectObjectID == userID {
+			continue
+		}
+		tuples = append(tuples, tuple)
+	}
+	return tuples, nil
+}
+
 // CleanupExpiredRelations removes expired relation tuples
 func (s *RelationSvc) CleanupExpiredRelations(ctx context.Context) (int64, error) {
 	count, err := s.tupleRepo.CleanupExpired(ctx)
@@ -327,7 +1006,7 @@ func (s *RelationSvc) CleanupExpiredRelations(ctx context.Context) (int64, error
 	}
 
 	if count > 0 {
-		s.logger.Info(fmt.Sprintf("Cleaned up %d expired relations", count))
+		logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Cleaned up %d expired relations", count))
 	}
 
 	return count, nil
@@ -353,9 +1032,35 @@ func (s *RelationSvc) validateRelationRequest(req aggregate.GrantRelationReq) er
 	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
 		return fmt.Errorf("expiresAt must be in the future")
 	}
+	if req.Condition != "" {
+		if _, err := condition.Parse(req.Condition); err != nil {
+			return fmt.Errorf("invalid condition: %w", err)
+		}
+	}
 	return nil
 }
 
+// checkProjectSubject requires a user granted a relation on a project to be an active member of it.
+// Userset subjects (e.g. team:eng#member) are not checked; their members are resolved at check time.
+func (s *RelationSvc) checkProjectSubject(ctx context.Context, req aggregate.GrantRelationReq) error {
+	if err := s.checkProjectWritable(ctx, req.Namespace, req.ObjectID); err != nil {
+		return err
+	}
+	if req.Namespace != constant.RelationNamespaceProject ||
+		req.SubjectNamespace != constant.RelationNamespaceUser || req.SubjectRelation != "" {
+		return nil
+	}
+	return checkProjectMember(ctx, s.memberRepo, req.ObjectID, req.SubjectObjectID)
+}
+
+// checkProjectWritable rejects relation writes on an archived project.
+func (s *RelationSvc) checkProjectWritable(ctx context.Context, namespace, objectID string) error {
+	if namespace != constant.RelationNamespaceProject {
+		return nil
+	}
+	return checkProjectNotArchived(ctx, s.projectRepo, objectID)
+}
+
 // toRelationTupleResp converts a relation tuple to a response
 func (s *RelationSvc) toRelationTupleResp(tuple *model.RelationTuple) *aggregate.RelationTupleResp {
 	return &aggregate.RelationTupleResp{
@@ -366,6 +1071,7 @@ func (s *RelationSvc) toRelationTupleResp(tuple *model.RelationTuple) *aggregate
 		SubjectNamespace: tuple.SubjectNamespace,
 		SubjectObjectID:  tuple.SubjectObjectID,
 		SubjectRelation:  tuple.SubjectRelation,
+		Condition:        tuple.Condition,
 		IsActive:         tuple.IsActive,
 		ExpiresAt:        tuple.ExpiresAt,
 		CreatedAt:        tuple.CreatedAt,
@@ -373,12 +1079,65 @@ func (s *RelationSvc) toRelationTupleResp(tuple *model.RelationTuple) *aggregate
 	}
 }
 
+// newRelationTuple builds the active tuple a validated grant request creates
+func newRelationTuple(req aggregate.GrantRelationReq) model.RelationTuple {
+	return model.RelationTuple{
+		Namespace:        req.Namespace,
+		ObjectID:         req.ObjectID,
+		Relation:         req.Relation,
+		SubjectNamespace: req.SubjectNamespace,
+		SubjectObjectID:  req.SubjectObjectID,
+		SubjectRelation:  req.SubjectRelation,
+		Condition:        req.Condition,
+		IsActive:         true,
+		ExpiresAt:        req.ExpiresAt,
+	}
+}
+
+// reactivateRelationTuple returns existing with the condition and expiry of granted, active again
+func reactivateRelationTuple(existing, granted model.RelationTuple) model.RelationTuple {
+	existing.Condition = granted.Condition
+	existing.ExpiresAt = granted.ExpiresAt
+	existing.IsActive = true
+	return existing
+}
+
+// toGrantRelationReq converts a relation tuple to the request that would grant it again
+func toGrantRelationReq(tuple *model.RelationTuple) aggregate.GrantRelationReq {
+	return aggregate.GrantRelationReq{
+		Namespace:        tuple.Namespace,
+		ObjectID:         tuple.ObjectID,
+		Relation:         tuple.Relation,
+		SubjectNamespace: tuple.SubjectNamespace,
+		SubjectObjectID:  tuple.SubjectObjectID,
+		SubjectRelation:  tuple.SubjectRelation,
+		ExpiresAt:        tuple.ExpiresAt,
+		Condition:        tuple.Condition,
+	}
+}
+
 // buildCacheKey builds a cache key for a relation tuple
 func (s *RelationSvc) buildCacheKey(tuple *model.RelationTuple) string {
 	return constant.CacheKeyPrefixRelationTuple + tuple.String()
 }
 
-func (s *RelationSvc) clearRelationTupleCache(tuple *model.RelationTuple) {
-	cacheKey := s.buildCacheKey(tuple)
-	_ = s.cache.Delete(cacheKey)
+// cacheRelationTuple writes a granted tuple through to the check cache, replacing a cached miss. Only tuples
+// with a plain subject are cached; userset subjects are read from the database on every check.
+func (s *RelationSvc) cacheRelationTuple(ctx context.Context, tuple *model.RelationTuple) {
+	if tuple.SubjectRelation != "" {
+		return
+	}
+	ttl := constant.CacheDefaultTTL
+	direct := directTuple{Found: true, Condition: tuple.Condition, ExpiresAt: tuple.ExpiresAt}
+	if err := s.cache.WithContext(ctx).Set(s.buildCacheKey(tuple), direct, &ttl); err != nil {
+		// A stale miss would deny the new grant until it expires, so fall back to dropping it.
+		s.clearRelationTupleCache(ctx, tuple)
+	}
+}
+
+// clearRelationTupleCache drops a tuple's cached check result, so the next check reads the database.
+func (s *RelationSvc) clearRelationTupleCache(ctx context.Context, tuple *model.RelationTuple) {
+	if err := s.cache.WithContext(ctx).Delete(s.buildCacheKey(tuple)); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("Failed to invalidate relation tuple cache", "tuple", tuple.String(), "error", err)
+	}
 }
@@ -0,0 +1,496 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/relationconfig"
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+)
+
+type relationFixture struct {
+	svc    IRelationSvc
+	tuples *testutil.RelationTupleRepository
+	cache  *testutil.Cache
+}
+
+// newRelationFixture returns a relation service over empty in-memory stores. cfg may be nil.
+func newRelationFixture(t *testing.T, cfg *config.AppConfig) *relationFixture {
+	t.Helper()
+	if cfg == nil {
+		cfg = &config.AppConfig{}
+	}
+	log := testutil.NewLogger()
+	f := &relationFixture{tuples: testutil.NewRelationTupleRepository(), cache: testutil.NewCache()}
+	namespaces := testutil.NewRelationNamespaceRepository()
+	projects := testutil.NewProjectRepository()
+	access, err := NewEffectiveAccessSvc(cfg, log, testutil.NewEffectiveAccessRepository(), testutil.NewUserRepository(),
+		testutil.NewUserRoleRepository(testutil.NewRoleRepository()), f.tuples, namespaces, testutil.TxManager{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := NewProjectUsageSvc(cfg, log, NewUsageSvc(cfg, log, f.cache), projects)
+	f.svc = NewRelationSvc(cfg, log, f.tuples, namespaces, testutil.NewProjectMemberRepository(), projects, usage, access, f.cache)
+	return f
+}
+
+func (f *relationFixture) grant(t *testing.T, reqs ...aggregate.GrantRelationReq) {
+	t.Helper()
+	for _, req := range reqs {
+		if _, err := f.svc.GrantRelation(context.Background(), req); err != nil {
+			t.Fatalf("GrantRelation(%+v) err = %v", req, err)
+		}
+	}
+}
+
+func (f *relationFixture) check(t *testing.T, req aggregate.CheckRelationReq) aggregate.CheckRelationResp {
+	t.Helper()
+	result, err := f.svc.CheckRelation(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CheckRelation(%+v) err = %v", req, err)
+	}
+	return *result
+}
+
+// seed stores a tuple such as "document:readme#viewer@group:eng#member" behind the service's back.
+func (f *relationFixture) seed(t *testing.T, tuple string) *model.RelationTuple {
+	t.Helper()
+	object, subject, _ := strings.Cut(tuple, "@")
+	object, relation, _ := strings.Cut(object, "#")
+	ns, id, _ := strings.Cut(object, ":")
+	subject, subjectRelation, _ := strings.Cut(subject, "#")
+	subjectNs, subjectID, _ := strings.Cut(subject, ":")
+	stored, err := f.tuples.Create(context.Background(), &model.RelationTuple{
+		Namespace: ns, ObjectID: id, Relation: relation,
+		SubjectNamespace: subjectNs, SubjectObjectID: subjectID, SubjectRelation: subjectRelation, IsActive: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+func wantCode(t *testing.T, what string, err error, code errorx.AppErrCode) {
+	t.Helper()
+	if err == nil || errorx.GetCode(err) != code {
+		t.Errorf("%s err = %v, want code %d", what, err, code)
+	}
+}
+
+func TestRelationSvc_NamespaceRewrites(t *testing.T) {
+	ctx := context.Background()
+	f := newRelationFixture(t, nil)
+	f.seed(t, "document:readme#parent@folder:docs")
+	f.seed(t, "folder:docs#viewer@user:carol")
+	viewer := func(subjectID string) bool {
+		t.Helper()
+		return f.check(t, aggregate.CheckRelationReq{
+			Namespace: "document", ObjectID: "readme", Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: subjectID,
+		}).Allowed
+	}
+
+	if viewer("carol") {
+		t.Fatal("folder viewer can view the document before rewrites are configured")
+	}
+	ns, err := f.svc.UpsertNamespace(ctx, "document", aggregate.UpsertRelationNamespaceReq{Relations: map[string]*relationconfig.Rewrite{
+		"parent": {},
+		"viewer": {Union: []*relationconfig.Rewrite{
+			{This: true},
+			{TupleToUserset: &relationconfig.TupleToUserset{Tupleset: "parent", ComputedUserset: "viewer"}},
+		}},
+	}})
+	if err != nil {
+		t.Fatalf("UpsertNamespace err = %v", err)
+	}
+	if ns.Name != "document" || ns.Relations["viewer"] == nil || len(ns.Relations["viewer"].Union) != 2 {
+		t.Fatalf("namespace = %+v", ns)
+	}
+	if !viewer("carol") {
+		t.Error("folder viewer cannot view the document through the parent rewrite")
+	}
+	if viewer("dave") {
+		t.Error("unrelated user can view the document")
+	}
+
+	_, err = f.svc.UpsertNamespace(ctx, "document", aggregate.UpsertRelationNamespaceReq{Relations: map[string]*relationconfig.Rewrite{
+		"viewer": {ComputedUserset: &relationconfig.ComputedUserset{Relation: "editor"}},
+	}})
+	wantCode(t, "UpsertNamespace(unknown relation)", err, errorx.ErrBadRequest)
+
+	if err := f.svc.DeleteNamespace(ctx, "document"); err != nil {
+		t.Fatalf("DeleteNamespace err = %v", err)
+	}
+	if viewer("carol") {
+		t.Error("rewrite still applies after the namespace was deleted")
+	}
+	if _, err := f.svc.GetNamespace(ctx, "document"); err == nil {
+		t.Error("deleted namespace is still returned")
+	}
+}
+
+func TestRelationSvc_Usersets(t *testing.T) {
+	grants := []aggregate.GrantRelationReq{
+		{Namespace: "team", ObjectID: "backend", Relation: "member", SubjectNamespace: "user", SubjectObjectID: "bob"},
+		{Namespace: "team", ObjectID: "engineering", Relation: "member", SubjectNamespace: "team", SubjectObjectID: "backend", SubjectRelation: "member"},
+		{Namespace: "project", ObjectID: "proj-001", Relation: "contributor", SubjectNamespace: "team", SubjectObjectID: "engineering", SubjectRelation: "member"},
+	}
+	contributor := func(f *relationFixture, subjectID string) aggregate.CheckRelationResp {
+		t.Helper()
+		return f.check(t, aggregate.CheckRelationReq{
+			Namespace: "project", ObjectID: "proj-001", Relation: "contributor", SubjectNamespace: "user", SubjectObjectID: subjectID,
+		})
+	}
+
+	f := newRelationFixture(t, nil)
+	f.grant(t, grants...)
+	if !contributor(f, "bob").Allowed {
+		t.Error("member of a nested team is not a contributor")
+	}
+	if contributor(f, "carol").Allowed {
+		t.Error("non-member is a contributor")
+	}
+
+	cfg := &config.AppConfig{}
+	cfg.Relations.MaxCheckDepth = 1
+	shallow := newRelationFixture(t, cfg)
+	shallow.grant(t, grants...)
+	if result := contributor(shallow, "bob"); result.Allowed || result.Reason == "" {
+		t.Errorf("check past RELATION_MAX_CHECK_DEPTH = %+v, want denied with a reason", result)
+	}
+}
+
+func TestRelationSvc_ListObjects(t *testing.T) {
+	f := newRelationFixture(t, nil)
+	f.grant(t,
+		aggregate.GrantRelationReq{Namespace: "document", ObjectID: "readme", Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: "alice"},
+		aggregate.GrantRelationReq{Namespace: "document", ObjectID: "roadmap", Relation: "viewer", SubjectNamespace: "team", SubjectObjectID: "eng", SubjectRelation: "member"},
+		aggregate.GrantRelationReq{Namespace: "team", ObjectID: "eng", Relation: "member", SubjectNamespace: "user", SubjectObjectID: "alice"},
+		aggregate.GrantRelationReq{Namespace: "document", ObjectID: "payroll", Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: "bob"},
+		aggregate.GrantRelationReq{Namespace: "document", ObjectID: "design", Relation: "editor", SubjectNamespace: "user", SubjectObjectID: "alice"},
+	)
+
+	result, err := f.svc.ListObjects(context.Background(), "user", "alice", "document", "viewer")
+	if err != nil || !slices.Equal(result.ObjectIDs, []string{"readme", "roadmap"}) || result.Count != 2 {
+		t.Errorf("ListObjects = %+v, %v; want [readme roadmap]", result, err)
+	}
+}
+
+func TestRelationSvc_Conditions(t *testing.T) {
+	ctx := context.Background()
+	f := newRelationFixture(t, nil)
+	grant := aggregate.GrantRelationReq{
+		Namespace: "document", ObjectID: "payroll", Relation: "viewer",
+		SubjectNamespace: "user", SubjectObjectID: "alice",
+		Condition: `ip_in_range("10.0.0.0/8")`,
+	}
+	tuple, err := f.svc.GrantRelation(ctx, grant)
+	if err != nil {
+		t.Fatalf("GrantRelation err = %v", err)
+	}
+	if tuple.Condition != grant.Condition {
+		t.Errorf("granted condition = %q, want %q", tuple.Condition, grant.Condition)
+	}
+
+	check := func(objectID string, context map[string]any) aggregate.CheckRelationResp {
+		t.Helper()
+		return f.check(t, aggregate.CheckRelationReq{
+			Namespace: "document", ObjectID: objectID, Relation: "viewer",
+			SubjectNamespace: "user", SubjectObjectID: "alice", Context: context,
+		})
+	}
+	if result := check("payroll", map[string]any{"ip": "10.1.2.3"}); !result.Allowed {
+		t.Errorf("check from 10.1.2.3 = %+v, want allowed", result)
+	}
+	if result := check("payroll", map[string]any{"ip": "192.168.1.1"}); result.Allowed {
+		t.Errorf("check from 192.168.1.1 = %+v, want denied", result)
+	}
+
+	grant.ObjectID, grant.Condition = "handbook", `tier == "gold"`
+	f.grant(t, grant)
+	if missing := check("handbook", nil); missing.Allowed || missing.Reason != "Condition requires context: tier" {
+		t.Errorf("check without context = %+v, want denied for missing tier", missing)
+	}
+
+	grant.Condition = `ip_in_range("not-a-cidr")`
+	if _, err := f.svc.GrantRelation(ctx, grant); err == nil {
+		t.Error("grant with invalid condition succeeded, want rejected")
+	}
+}
+
+func TestRelationSvc_ImportExport(t *testing.T) {
+	ctx := context.Background()
+	source := newRelationFixture(t, nil)
+	source.grant(t,
+		aggregate.GrantRelationReq{Namespace: "document", ObjectID: "readme", Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: "alice"},
+		aggregate.GrantRelationReq{Namespace: "document", ObjectID: "readme", Relation: "editor", SubjectNamespace: "team", SubjectObjectID: "eng", SubjectRelation: "member"},
+		aggregate.GrantRelationReq{Namespace: "team", ObjectID: "eng", Relation: "member", SubjectNamespace: "user", SubjectObjectID: "bob"},
+	)
+
+	var buf bytes.Buffer
+	n, err := source.svc.ExportRelations(ctx, aggregate.ExportRelationsReq{Namespace: "document"}, &buf)
+	export := buf.String()
+	if err != nil || n != 2 || strings.Count(export, "\n") != 2 {
+		t.Fatalf("ExportRelations = %d, %v, want the 2 document tuples:\n%s", n, err, export)
+	}
+
+	target := newRelationFixture(t, nil)
+	importRelations := func(req aggregate.ImportRelationsReq, body string) aggregate.ImportRelationsResp {
+		t.Helper()
+		result, err := target.svc.ImportRelations(ctx, req, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("ImportRelations(%+v) err = %v", req, err)
+		}
+		return *result
+	}
+
+	if result := importRelations(aggregate.ImportRelationsReq{DryRun: true}, export); !result.DryRun || result.Created != 2 || target.tuples.Len() != 0 {
+		t.Errorf("dry run = %+v with %d stored, want 2 to create and none stored", result, target.tuples.Len())
+	}
+	if result := importRelations(aggregate.ImportRelationsReq{}, export); result.Created != 2 || len(result.Errors) != 0 || target.tuples.Len() != 2 {
+		t.Errorf("import = %+v with %d stored, want 2 created", result, target.tuples.Len())
+	}
+	if result := importRelations(aggregate.ImportRelationsReq{}, export+export); result.Skipped != 4 || result.Created != 0 {
+		t.Errorf("re-import = %+v, want all 4 lines skipped", result)
+	}
+	if result := importRelations(aggregate.ImportRelationsReq{OnConflict: "fail"}, export); len(result.Errors) != 2 || result.Errors[0].Line != 1 {
+		t.Errorf("import with fail strategy = %+v, want both lines rejected", result)
+	}
+
+	result := importRelations(aggregate.ImportRelationsReq{}, export+"{\"namespace\":\"document\"}\nnot json\n")
+	if len(result.Errors) != 2 || result.Errors[0].Line != 3 || result.Errors[1].Line != 4 {
+		t.Errorf("malformed import errors = %+v, want lines 3 and 4", result.Errors)
+	}
+	if target.tuples.Len() != 2 {
+		t.Errorf("malformed import stored %d tuples, want none added", target.tuples.Len())
+	}
+}
+
+func TestRelationSvc_CheckCache(t *testing.T) {
+	ctx := context.Background()
+	f := newRelationFixture(t, nil)
+	grant := aggregate.GrantRelationReq{Namespace: "document", ObjectID: "readme", Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: "alice"}
+	revoke := aggregate.RevokeRelationReq{Namespace: grant.Namespace, ObjectID: grant.ObjectID, Relation: grant.Relation, SubjectNamespace: grant.SubjectNamespace, SubjectObjectID: grant.SubjectObjectID}
+	allowed := func() bool {
+		t.Helper()
+		return f.check(t, aggregate.CheckRelationReq{
+			Namespace: grant.Namespace, ObjectID: grant.ObjectID, Relation: grant.Relation,
+			SubjectNamespace: grant.SubjectNamespace, SubjectObjectID: grant.SubjectObjectID,
+		}).Allowed
+	}
+
+	if allowed() {
+		t.Fatal("check before grant allowed")
+	}
+	if cacheKey := constant.CacheKeyPrefixRelationTuple + "document:readme#viewer@user:alice"; !slices.Contains(f.cache.Keys(), cacheKey) {
+		t.Fatalf("check did not cache its result; keys = %v", f.cache.Keys())
+	}
+
+	// The cached miss must not outlive a bulk grant.
+	if _, err := f.svc.BulkGrantRelations(ctx, aggregate.BulkGrantRelationReq{Relations: []aggregate.GrantRelationReq{grant}}); err != nil {
+		t.Fatalf("BulkGrantRelations err = %v", err)
+	}
+	if !allowed() {
+		t.Error("check after bulk grant denied")
+	}
+	if err := f.svc.BulkRevokeRelations(ctx, aggregate.BulkRevokeRelationReq{Relations: []aggregate.RevokeRelationReq{revoke}}); err != nil {
+		t.Fatalf("BulkRevokeRelations err = %v", err)
+	}
+	if allowed() {
+		t.Error("check after bulk revoke allowed")
+	}
+
+	f.grant(t, grant)
+	if !allowed() {
+		t.Error("check after grant denied")
+	}
+	if err := f.svc.RevokeRelation(ctx, revoke); err != nil {
+		t.Fatalf("RevokeRelation err = %v", err)
+	}
+	if allowed() {
+		t.Error("check after revoke allowed")
+	}
+}
+
+func TestRelationSvc_DenyCache(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Relations.DenyCacheTTLSec = 60
+	f := newRelationFixture(t, cfg)
+	viewer := func(objectID, subjectID string) bool {
+		t.Helper()
+		return f.check(t, aggregate.CheckRelationReq{
+			Namespace: "document", ObjectID: objectID, Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: subjectID,
+		}).Allowed
+	}
+
+	if viewer("readme", "alice") {
+		t.Fatal("alice can view readme before any grant")
+	}
+	f.seed(t, "document:readme#viewer@group:eng#member")
+	f.seed(t, "group:eng#member@user:alice")
+	if viewer("readme", "alice") {
+		t.Error("denial was not cached")
+	}
+	// A grant on the same object drops the denial.
+	f.grant(t, aggregate.GrantRelationReq{Namespace: "document", ObjectID: "readme", Relation: "owner", SubjectNamespace: "user", SubjectObjectID: "zed"})
+	if !viewer("readme", "alice") {
+		t.Error("denial outlived a grant on its object")
+	}
+
+	if viewer("roadmap", "bob") {
+		t.Fatal("bob can view roadmap before any grant")
+	}
+	f.seed(t, "document:roadmap#viewer@group:eng#member")
+	f.seed(t, "group:eng#member@user:bob")
+	// So does a grant to the same subject.
+	f.grant(t, aggregate.GrantRelationReq{Namespace: "group", ObjectID: "ops", Relation: "member", SubjectNamespace: "user", SubjectObjectID: "bob"})
+	if !viewer("roadmap", "bob") {
+		t.Error("denial outlived a grant to its subject")
+	}
+
+	// Denials that depended on a condition are not cached.
+	f.grant(t, aggregate.GrantRelationReq{Namespace: "document", ObjectID: "plan", Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: "carol", Condition: `ip_in_range("10.0.0.0/8")`})
+	if viewer("plan", "carol") {
+		t.Fatal("conditional tuple allowed without a matching ip")
+	}
+	if keys := f.cache.Keys(); slices.ContainsFunc(keys, func(k string) bool { return strings.HasPrefix(k, constant.CacheKeyPrefixRelationDeny+"document:plan") }) {
+		t.Errorf("conditional denial was cached: %v", keys)
+	}
+}
+
+func TestRelationSvc_BulkGrantReport(t *testing.T) {
+	f := newRelationFixture(t, nil)
+	existing := aggregate.GrantRelationReq{Namespace: "document", ObjectID: "readme", Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: "alice"}
+	f.grant(t, existing)
+
+	fresh := aggregate.GrantRelationReq{Namespace: "document", ObjectID: "readme", Relation: "editor", SubjectNamespace: "user", SubjectObjectID: "bob"}
+	invalid := aggregate.GrantRelationReq{Namespace: "document", ObjectID: "readme", Relation: "viewer", SubjectNamespace: "user"}
+	report, err := f.svc.BulkGrantRelations(context.Background(), aggregate.BulkGrantRelationReq{
+		Relations: []aggregate.GrantRelationReq{existing, fresh, invalid, fresh},
+	})
+	if err != nil {
+		t.Fatalf("BulkGrantRelations err = %v", err)
+	}
+
+	want := []string{constant.RelationGrantSkipped, constant.RelationGrantCreated, constant.RelationGrantError, constant.RelationGrantSkipped}
+	if len(report.Results) != len(want) {
+		t.Fatalf("bulk grant results = %+v, want %d", report.Results, len(want))
+	}
+	for i, status := range want {
+		if got := report.Results[i]; got.Index != i || got.Status != status {
+			t.Errorf("result %d = %+v, want status %s", i, got, status)
+		}
+	}
+	if report.Results[1].Relation == nil || report.Results[1].Relation.ID == "" || report.Results[2].Error == "" {
+		t.Errorf("bulk grant results = %+v, want the created tuple and the validation error", report.Results)
+	}
+	if report.Created != 1 || report.Skipped != 2 || report.Failed != 1 {
+		t.Errorf("bulk grant totals = %d created, %d skipped, %d failed; want 1, 2, 1", report.Created, report.Skipped, report.Failed)
+	}
+	if n := f.tuples.Len(); n != 2 {
+		t.Errorf("stored tuples = %d, want 2", n)
+	}
+}
+
+func TestRelationSvc_ExtendAndUpsert(t *testing.T) {
+	ctx := context.Background()
+	f := newRelationFixture(t, nil)
+	expiresAt := time.Now().Add(time.Hour)
+	grant := aggregate.GrantRelationReq{
+		Namespace: "document", ObjectID: "readme", Relation: "viewer",
+		SubjectNamespace: "user", SubjectObjectID: "alice", ExpiresAt: &expiresAt,
+	}
+	f.grant(t, grant)
+	allowed := func() bool {
+		t.Helper()
+		return f.check(t, aggregate.CheckRelationReq{
+			Namespace: "document", ObjectID: "readme", Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: "alice",
+		}).Allowed
+	}
+
+	// Let the tuple lapse behind the service's back, then renew it.
+	stored := f.tuples.First(func(m *model.RelationTuple) bool { return m.ObjectID == "readme" })
+	lapsed := time.Now().Add(-time.Minute)
+	stored.ExpiresAt = &lapsed
+	if err := f.tuples.Update(ctx, stored.ID, *stored, "expires_at"); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.cache.Clear()
+	if allowed() {
+		t.Fatal("check on an expired tuple allowed")
+	}
+
+	extend := aggregate.ExtendRelationReq{
+		Namespace: "document", ObjectID: "readme", Relation: "viewer",
+		SubjectNamespace: "user", SubjectObjectID: "alice", TTLSeconds: 7200,
+	}
+	extended, err := f.svc.ExtendRelation(ctx, extend)
+	if err != nil {
+		t.Fatalf("ExtendRelation err = %v", err)
+	}
+	if extended.ExpiresAt == nil || extended.ExpiresAt.Before(time.Now().Add(time.Hour)) {
+		t.Errorf("extended expiresAt = %v, want about two hours out", extended.ExpiresAt)
+	}
+	if !allowed() {
+		t.Error("check after extend denied")
+	}
+
+	extend.ExpiresAt = &expiresAt
+	_, err = f.svc.ExtendRelation(ctx, extend)
+	wantCode(t, "ExtendRelation(expiresAt and ttlSeconds)", err, errorx.ErrBadRequest)
+	extend.ExpiresAt, extend.SubjectObjectID = nil, "bob"
+	if _, err := f.svc.ExtendRelation(ctx, extend); err == nil {
+		t.Error("extend of a missing tuple succeeded")
+	}
+
+	grant.ExpiresAt, grant.Condition = nil, `ip_in_range("10.0.0.0/8")`
+	if _, err := f.svc.GrantRelation(ctx, grant); err == nil {
+		t.Error("grant of an active tuple succeeded without upsert")
+	}
+	grant.Upsert = true
+	upserted, err := f.svc.GrantRelation(ctx, grant)
+	if err != nil {
+		t.Fatalf("upsert GrantRelation err = %v", err)
+	}
+	if upserted.ID != stored.ID || upserted.ExpiresAt != nil || upserted.Condition != grant.Condition {
+		t.Errorf("upsert = %+v, want tuple %s updated in place", upserted, stored.ID)
+	}
+	if n := f.tuples.Len(); n != 1 {
+		t.Errorf("stored tuples = %d, want 1", n)
+	}
+}
+
+func TestRelationSvc_ListRelationsFilters(t *testing.T) {
+	f := newRelationFixture(t, nil)
+	for _, tuple := range []string{"doc:a#viewer@user:u1", "doc:a#editor@user:u2", "doc:b#viewer@user:u1", "folder:x#viewer@group:eng"} {
+		f.seed(t, tuple)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		req   aggregate.ListRelationsReq
+		total int64
+		items int
+	}{
+		{"all", aggregate.ListRelationsReq{}, 4, 4},
+		{"namespace", aggregate.ListRelationsReq{Namespace: "doc"}, 3, 3},
+		{"object", aggregate.ListRelationsReq{Namespace: "doc", ObjectID: "a"}, 2, 2},
+		{"relation", aggregate.ListRelationsReq{Relation: "viewer"}, 3, 3},
+		{"subject", aggregate.ListRelationsReq{SubjectNamespace: "user", SubjectObjectID: "u1"}, 2, 2},
+		{"no match", aggregate.ListRelationsReq{Namespace: "doc", Relation: "editor", SubjectObjectID: "u1"}, 0, 0},
+		{"second page", aggregate.ListRelationsReq{Namespace: "doc", PaginationReq: aggregate.PaginationReq{Page: 2, PageSize: 2}}, 3, 1},
+	} {
+		result, err := f.svc.ListRelations(context.Background(), tc.req)
+		if err != nil || result.Total != tc.total || len(result.Items) != tc.items {
+			t.Errorf("%s: ListRelations = %+v, %v; want total %d, %d items", tc.name, result, err, tc.total, tc.items)
+		}
+	}
+}
@@ -2,71 +2,110 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/hiamthach108/dreon-auth/internal/aggregate"
 	"github.com/hiamthach108/dreon-auth/internal/errorx"
 	"github.com/hiamthach108/dreon-auth/internal/model"
 	"github.com/hiamthach108/dreon-auth/internal/repository"
 	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
-	"github.com/hiamthach108/dreon-auth/internal/shared/permission"
+	"github.com/hiamthach108/dreon-auth/internal/shared/rolemapping"
+	"github.com/hiamthach108/dreon-auth/pkg/background"
 	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
 	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
+	"gorm.io/datatypes"
 )
 
 type IRoleSvc interface {
 	// Role CRUD
-	CreateRole(ctx context.Context, req aggregate.CreateRoleReq, isSuperAdmin bool) (*aggregate.RoleResp, error)
+	CreateRole(ctx context.Context, req aggregate.CreateRoleReq) (*aggregate.RoleResp, error)
 	GetRole(ctx context.Context, roleID string) (*aggregate.RoleResp, error)
-	UpdateRole(ctx context.Context, roleID string, req aggregate.UpdateRoleReq, isSuperAdmin bool) (*aggregate.RoleResp, error)
-	DeleteRole(ctx context.Context, roleID string, isSuperAdmin bool) error
+	UpdateRole(ctx context.Context, roleID string, req aggregate.UpdateRoleReq) (*aggregate.RoleResp, error)
+	DeleteRole(ctx context.Context, roleID string) error
 	ListRoles(ctx context.Context, req aggregate.ListRolesReq) (*aggregate.PaginationResp[aggregate.RoleResp], error)
+	GetRoleHistory(ctx context.Context, roleID string, page, pageSize int) (*aggregate.PaginationResp[aggregate.RoleHistoryResp], error)
 
 	// User role assignment
-	AssignRoleToUser(ctx context.Context, req aggregate.AssignRoleToUserReq, isSuperAdmin bool) (*aggregate.UserRoleResp, error)
-	RemoveRoleFromUser(ctx context.Context, req aggregate.RemoveRoleFromUserReq, isSuperAdmin bool) error
+	AssignRoleToUser(ctx context.Context, req aggregate.AssignRoleToUserReq) (*aggregate.UserRoleResp, error)
+	RemoveRoleFromUser(ctx context.Context, req aggregate.RemoveRoleFromUserReq) error
+	BulkAssignRoles(ctx context.Context, req aggregate.BulkAssignRolesReq) (*aggregate.BulkRoleAssignmentResp, error)
+	BulkRemoveRoles(ctx context.Context, req aggregate.BulkRemoveRolesReq) (*aggregate.BulkRoleAssignmentResp, error)
 	GetUserRoles(ctx context.Context, req aggregate.GetUserRolesReq) ([]aggregate.UserRoleResp, error)
 	GetUserPermissions(ctx context.Context, userID string) (aggregate.UserPermissions, error)
+	// GetUsersPermissions is GetUserPermissions for several users in one query, keyed by user ID.
+	GetUsersPermissions(ctx context.Context, userIDs []string) (map[string]aggregate.UserPermissions, error)
+	CheckUserPermission(ctx context.Context, req aggregate.CheckPermissionReq) (*aggregate.CheckPermissionResp, error)
+	CleanupExpiredAssignments(ctx context.Context) (int64, error)
+
+	// External identity provider sync
+	SyncExternalRoles(ctx context.Context, userID string, provider constant.UserAuthType, claims []string) error
 }
 
 type RoleSvc struct {
-	logger             logger.ILogger
-	roleRepo           repository.IRoleRepository
-	userRoleRepo       repository.IUserRoleRepository
-	userRepo           repository.IUserRepository
-	permissionRegistry *permission.Registry
-	cache              cache.ICache
+	logger        logger.ILogger
+	roleRepo      repository.IRoleRepository
+	userRoleRepo  repository.IUserRoleRepository
+	historyRepo   repository.IRoleHistoryRepository
+	userRepo      repository.IUserRepository
+	txManager     repository.ITxManager
+	memberRepo    repository.IProjectMemberRepository
+	projectRepo   repository.IProjectRepository
+	permissionSvc IPermissionSvc
+	access        IEffectiveAccessSvc
+	roleMapping   *rolemapping.Table
+	cache         cache.ICache
+	events        eventbus.IPublisher
+	workers       *background.Group
 }
 
 func NewRoleSvc(
 	logger logger.ILogger,
 	roleRepo repository.IRoleRepository,
 	userRoleRepo repository.IUserRoleRepository,
+	historyRepo repository.IRoleHistoryRepository,
 	userRepo repository.IUserRepository,
-	permissionRegistry *permission.Registry,
+	txManager repository.ITxManager,
+	memberRepo repository.IProjectMemberRepository,
+	projectRepo repository.IProjectRepository,
+	permissionSvc IPermissionSvc,
+	access IEffectiveAccessSvc,
+	roleMapping *rolemapping.Table,
 	cache cache.ICache,
+	events eventbus.IPublisher,
+	workers *background.Group,
 ) IRoleSvc {
 	return &RoleSvc{
-		logger:             logger,
-		roleRepo:           roleRepo,
-		userRoleRepo:       userRoleRepo,
-		userRepo:           userRepo,
-		permissionRegistry: permissionRegistry,
-		cache:              cache,
+		logger:        logger,
+		roleRepo:      roleRepo,
+		userRoleRepo:  userRoleRepo,
+		historyRepo:   historyRepo,
+		userRepo:      userRepo,
+		txManager:     txManager,
+		memberRepo:    memberRepo,
+		projectRepo:   projectRepo,
+		permissionSvc: permissionSvc,
+		access:        access,
+		roleMapping:   roleMapping,
+		cache:         cache,
+		events:        events,
+		workers:       workers,
 	}
 }
 
 // CreateRole creates a new role
-func (s *RoleSvc) CreateRole(ctx context.Context, req aggregate.CreateRoleReq, isSuperAdmin bool) (*aggregate.RoleResp, error) {
+func (s *RoleSvc) CreateRole(ctx context.Context, req aggregate.CreateRoleReq) (*aggregate.RoleResp, error) {
 	// Validate system role creation
-	if req.ProjectID != nil && *req.ProjectID == constant.SystemProjectID && !isSuperAdmin {
+	if req.ProjectID != nil && *req.ProjectID == constant.SystemProjectID && !isSuperAdminFromContext(ctx) {
 		return nil, errorx.New(errorx.ErrSystemRoleProtected, "Only super admins can create system roles")
 	}
 
-	if s.permissionRegistry != nil {
-		if err := s.permissionRegistry.ValidateCodes(req.Permissions); err != nil {
-			return nil, errorx.New(errorx.ErrInvalidPermission, err.Error())
-		}
+	if err := s.permissionSvc.ValidateCodes(ctx, req.ProjectID, req.Permissions); err != nil {
+		return nil, err
 	}
 
 	// Check if role code already exists
@@ -78,13 +117,20 @@ func (s *RoleSvc) CreateRole(ctx context.Context, req aggregate.CreateRoleReq, i
 		return nil, errorx.New(errorx.ErrRoleConflict, "Role with this code already exists")
 	}
 
-	role := req.ToModel()
-	created, err := s.roleRepo.Create(ctx, role)
+	var created *model.Role
+	err = withTx(ctx, s.txManager, func(ctx context.Context) error {
+		var err error
+		created, err = s.roleRepo.Create(ctx, req.ToModel())
+		if err != nil {
+			return errorx.Wrap(errorx.ErrCreateRole, err)
+		}
+		return s.recordHistory(ctx, newRoleHistory(ctx, constant.RoleHistoryCreate, created, "", nil, roleSnapshot(created)))
+	})
 	if err != nil {
-		return nil, errorx.Wrap(errorx.ErrCreateRole, err)
+		return nil, err
 	}
 
-	s.logger.Info(fmt.Sprintf("Role created: %s (code: %s)", created.Name, created.Code))
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Role created: %s (code: %s)", created.Name, created.Code))
 	return aggregate.RoleRespFromModel(created), nil
 }
 
@@ -98,7 +144,7 @@ func (s *RoleSvc) GetRole(ctx context.Context, roleID string) (*aggregate.RoleRe
 }
 
 // UpdateRole updates an existing role
-func (s *RoleSvc) UpdateRole(ctx context.Context, roleID string, req aggregate.UpdateRoleReq, isSuperAdmin bool) (*aggregate.RoleResp, error) {
+func (s *RoleSvc) UpdateRole(ctx context.Context, roleID string, req aggregate.UpdateRoleReq) (*aggregate.RoleResp, error) {
 	// Check if role exists
 	role := s.roleRepo.FindOneById(ctx, roleID)
 	if role == nil {
@@ -106,33 +152,41 @@ func (s *RoleSvc) UpdateRole(ctx context.Context, roleID string, req aggregate.U
 	}
 
 	// Validate system role update
-	if role.ProjectID != nil && *role.ProjectID == constant.SystemProjectID && !isSuperAdmin {
+	if role.ProjectID != nil && *role.ProjectID == constant.SystemProjectID && !isSuperAdminFromContext(ctx) {
 		return nil, errorx.New(errorx.ErrSystemRoleProtected, "Only super admins can update system roles")
 	}
 
-	if s.permissionRegistry != nil {
-		if err := s.permissionRegistry.ValidateCodes(req.Permissions); err != nil {
-			return nil, errorx.New(errorx.ErrInvalidPermission, err.Error())
-		}
+	if err := s.permissionSvc.ValidateCodes(ctx, role.ProjectID, req.Permissions); err != nil {
+		return nil, err
 	}
 
 	updateFields := []string{"name", "description", "permissions", "updated_at"}
+	before := roleSnapshot(role)
 	req.ApplyTo(role)
 	if req.IsActive != nil {
 		updateFields = append(updateFields, "is_active")
 	}
 
-	if err := s.roleRepo.Update(ctx, roleID, *role, updateFields...); err != nil {
-		return nil, errorx.Wrap(errorx.ErrUpdateRole, err)
+	var updated *model.Role
+	err := withTx(ctx, s.txManager, func(ctx context.Context) error {
+		if err := s.roleRepo.Update(ctx, roleID, *role, updateFields...); err != nil {
+			return errorx.Wrap(errorx.ErrUpdateRole, err)
+		}
+		updated = s.roleRepo.FindOneById(ctx, roleID)
+		return s.recordHistory(ctx, newRoleHistory(ctx, constant.RoleHistoryUpdate, updated, "", before, roleSnapshot(updated)))
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	s.logger.Info(fmt.Sprintf("Role updated: %s (id: %s)", role.Name, roleID))
-	updated := s.roleRepo.FindOneById(ctx, roleID)
+	s.invalidateRoleHolders(ctx, roleID)
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Role updated: %s (id: %s)", role.Name, roleID))
 	return aggregate.RoleRespFromModel(updated), nil
 }
 
 // DeleteRole deletes a role
-func (s *RoleSvc) DeleteRole(ctx context.Context, roleID string, isSuperAdmin bool) error {
+func (s *RoleSvc) DeleteRole(ctx context.Context, roleID string) error {
 	// Check if role exists
 	role := s.roleRepo.FindOneById(ctx, roleID)
 	if role == nil {
@@ -140,157 +194,338 @@ func (s *RoleSvc) DeleteRole(ctx context.Context, roleID string, isSuperAdmin bo
 	}
 
 	// Validate system role deletion
-	if role.ProjectID != nil && *role.ProjectID == constant.SystemProjectID && !isSuperAdmin {
+	if role.ProjectID != nil && *role.ProjectID == constant.SystemProjectID && !isSuperAdminFromContext(ctx) {
 		return errorx.New(errorx.ErrSystemRoleProtected, "Only super admins can delete system roles")
 	}
 
-	if err := s.roleRepo.DeleteById(ctx, roleID); err != nil {
-		return errorx.Wrap(errorx.ErrDeleteRole, err)
+	err := withTx(ctx, s.txManager, func(ctx context.Context) error {
+		if err := s.roleRepo.DeleteById(ctx, roleID); err != nil {
+			return errorx.Wrap(errorx.ErrDeleteRole, err)
+		}
+		return s.recordHistory(ctx, newRoleHistory(ctx, constant.RoleHistoryDelete, role, "", roleSnapshot(role), nil))
+	})
+	if err != nil {
+		return err
 	}
 
-	s.logger.Info(fmt.Sprintf("Role deleted: %s (id: %s)", role.Name, roleID))
+	s.invalidateRoleHolders(ctx, roleID)
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Role deleted: %s (id: %s)", role.Name, roleID))
 
 	return nil
 }
 
 // ListRoles lists roles with filters
 func (s *RoleSvc) ListRoles(ctx context.Context, req aggregate.ListRolesReq) (*aggregate.PaginationResp[aggregate.RoleResp], error) {
-	pageSize := req.PageSize
-	if pageSize <= 0 {
-		pageSize = 10
-	}
-	if pageSize > 100 {
-		pageSize = 100
+	q, err := newPageQuery(req.PaginationReq)
+	if err != nil {
+		return nil, err
 	}
 
-	page := req.Page
-	if page <= 0 {
-		page = 1
+	roles, total, err := s.roleRepo.SearchRoles(repository.WithReplicaReads(ctx), req.Search, req.ProjectID, req.IsActive, q.repo)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
 
-	offset := (page - 1) * pageSize
+	return pageResp(q, roles, total,
+		func(m *model.Role) *model.BaseModel { return &m.BaseModel },
+		func(m *model.Role) aggregate.RoleResp { return *aggregate.RoleRespFromModel(m) },
+	), nil
+}
 
-	var roles []model.Role
-	var total int64
-	var err error
+// AssignRoleToUser assigns a role to a user
+func (s *RoleSvc) AssignRoleToUser(ctx context.Context, req aggregate.AssignRoleToUserReq) (*aggregate.UserRoleResp, error) {
+	ctx, span := tracing.Start(ctx, "RoleSvc.AssignRoleToUser")
+	defer span.End()
+	// The check and the write share a transaction holding the user's row, so concurrent assignments to
+	// the same user see each other instead of both passing the check.
+	var role *model.Role
+	var created *model.UserRole
+	err := withTx(ctx, s.txManager, func(ctx context.Context) error {
+		if err := s.userRepo.LockById(ctx, req.UserID); err != nil {
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+		var existing *model.UserRole
+		var err error
+		role, existing, err = s.checkAssignment(ctx, req)
+		if err != nil {
+			return err
+		}
+		// An expired assignment not yet cleaned up is renewed in place
+		if existing != nil && !existing.IsExpired() {
+			return errorx.New(errorx.ErrConflict, "User already has this role")
+		}
 
-	if req.Search != "" || req.ProjectID != nil || req.IsActive != nil {
-		roles, total, err = s.roleRepo.SearchRoles(ctx, req.Search, req.ProjectID, req.IsActive, pageSize, offset)
-	} else {
-		roles, err = s.roleRepo.FindAll(ctx)
-		total = int64(len(roles))
-		// Apply pagination manually
-		start := offset
-		end := offset + pageSize
-		if start > len(roles) {
-			roles = []model.Role{}
+		before := assignmentSnapshot(existing)
+		if existing != nil {
+			existing.ExpiresAt = req.ExpiresAt
+			if err := s.userRoleRepo.Update(ctx, existing.ID, *existing, "expires_at", "updated_at"); err != nil {
+				return errorx.Wrap(errorx.ErrRoleAssignment, err)
+			}
+			created = existing
 		} else {
-			if end > len(roles) {
-				end = len(roles)
+			// Create user role assignment
+			created, err = s.userRoleRepo.Create(ctx, &model.UserRole{
+				UserID:    req.UserID,
+				RoleID:    req.RoleID,
+				ProjectID: req.ProjectID,
+				ExpiresAt: req.ExpiresAt,
+			})
+			if err != nil {
+				return errorx.Wrap(errorx.ErrRoleAssignment, err)
 			}
-			roles = roles[start:end]
 		}
+		return s.recordHistory(ctx, newRoleHistory(ctx, constant.RoleHistoryAssign, role, req.UserID, before, assignmentSnapshot(created)))
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	s.invalidateUserPermissions(ctx, req.UserID)
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Role assigned: user=%s, role=%s", req.UserID, req.RoleID))
+	resp := aggregate.UserRoleRespFromModel(created, role)
+	publishEvent(ctx, s.events, s.logger, constant.EventRoleAssigned, req.UserID, resp)
+	return resp, nil
+}
+
+// RemoveRoleFromUser removes a role from a user
+func (s *RoleSvc) RemoveRoleFromUser(ctx context.Context, req aggregate.RemoveRoleFromUserReq) error {
+	ctx, span := tracing.Start(ctx, "RoleSvc.RemoveRoleFromUser")
+	defer span.End()
+	role, existing, err := s.checkRemoval(ctx, req)
 	if err != nil {
-		return nil, errorx.Wrap(errorx.ErrInternal, err)
+		return err
+	}
+	if existing == nil {
+		return errorx.New(errorx.ErrNotFound, "User role assignment not found")
 	}
 
-	items := make([]aggregate.RoleResp, 0, len(roles))
-	for i := range roles {
-		if r := aggregate.RoleRespFromModel(&roles[i]); r != nil {
-			items = append(items, *r)
+	err = withTx(ctx, s.txManager, func(ctx context.Context) error {
+		if err := s.userRoleRepo.DeleteByUserIDAndRoleID(ctx, req.UserID, req.RoleID, req.ProjectID); err != nil {
+			return errorx.Wrap(errorx.ErrRoleAssignment, err)
 		}
+		return s.recordHistory(ctx, newRoleHistory(ctx, constant.RoleHistoryRemove, role, req.UserID, assignmentSnapshot(existing), nil))
+	})
+	if err != nil {
+		return err
 	}
 
-	hasNext := int64(offset+pageSize) < total
+	s.invalidateUserPermissions(ctx, req.UserID)
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Role removed: user=%s, role=%s", req.UserID, req.RoleID))
+	publishEvent(ctx, s.events, s.logger, constant.EventRoleRemoved, req.UserID, req)
 
-	return &aggregate.PaginationResp[aggregate.RoleResp]{
-		Items:    items,
-		Total:    total,
-		Page:     page,
-		PageSize: pageSize,
-		HasNext:  hasNext,
-	}, nil
+	return nil
 }
 
-// AssignRoleToUser assigns a role to a user
-func (s *RoleSvc) AssignRoleToUser(ctx context.Context, req aggregate.AssignRoleToUserReq, isSuperAdmin bool) (*aggregate.UserRoleResp, error) {
+// BulkAssignRoles assigns every valid item in a single transaction and reports each item's outcome. Items
+// failing validation are reported without blocking the others; already held roles are skipped.
+func (s *RoleSvc) BulkAssignRoles(ctx context.Context, req aggregate.BulkAssignRolesReq) (*aggregate.BulkRoleAssignmentResp, error) {
+	ctx, span := tracing.Start(ctx, "RoleSvc.BulkAssignRoles")
+	defer span.End()
+
+	resp := &aggregate.BulkRoleAssignmentResp{Results: make([]aggregate.BulkRoleAssignmentResult, len(req.Assignments))}
+	var creates, renewals []model.UserRole
+	var createdAt, renewedAt []int
+	var renewedFrom []datatypes.JSON // the assignments before their renewal, for the history
+	roles := make(map[int]*model.Role)
+	seen := make(map[string]bool)
+	for i, item := range req.Assignments {
+		result := &resp.Results[i]
+		result.Index = i
+		role, existing, err := s.checkAssignment(ctx, item)
+		if err != nil {
+			if errorx.GetCode(err) == errorx.ErrInternal {
+				return nil, err
+			}
+			result.Status, result.Error = constant.RoleAssignmentError, err.Error()
+			resp.Failed++
+			continue
+		}
+
+		key := item.UserID + "/" + item.RoleID
+		if item.ProjectID != nil {
+			key += "/" + *item.ProjectID
+		}
+		if existing != nil && !existing.IsExpired() {
+			result.Status, result.Assignment = constant.RoleAssignmentSkipped, aggregate.UserRoleRespFromModel(existing, role)
+			resp.Skipped++
+			continue
+		}
+		if seen[key] {
+			result.Status = constant.RoleAssignmentSkipped
+			resp.Skipped++
+			continue
+		}
+		seen[key] = true
+		roles[i] = role
+
+		if existing != nil {
+			renewedFrom = append(renewedFrom, assignmentSnapshot(existing))
+			existing.ExpiresAt = item.ExpiresAt
+			renewals = append(renewals, *existing)
+			renewedAt = append(renewedAt, i)
+		} else {
+			creates = append(creates, model.UserRole{UserID: item.UserID, RoleID: item.RoleID, ProjectID: item.ProjectID, ExpiresAt: item.ExpiresAt})
+			createdAt = append(createdAt, i)
+		}
+	}
+
+	err := withTx(ctx, s.txManager, func(ctx context.Context) error {
+		if err := s.userRoleRepo.SaveBatch(ctx, creates, renewals); err != nil {
+			return errorx.Wrap(errorx.ErrRoleAssignment, err)
+		}
+		history := make([]model.RoleHistory, 0, len(creates)+len(renewals))
+		for j, i := range createdAt {
+			history = append(history, newRoleHistory(ctx, constant.RoleHistoryAssign, roles[i], creates[j].UserID, nil, assignmentSnapshot(&creates[j])))
+		}
+		for j, i := range renewedAt {
+			history = append(history, newRoleHistory(ctx, constant.RoleHistoryAssign, roles[i], renewals[j].UserID, renewedFrom[j], assignmentSnapshot(&renewals[j])))
+		}
+		return s.recordHistory(ctx, history...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []string
+	for j, i := range createdAt {
+		resp.Results[i].Status, resp.Results[i].Assignment = constant.RoleAssignmentAssigned, aggregate.UserRoleRespFromModel(&creates[j], roles[i])
+		userIDs = append(userIDs, creates[j].UserID)
+	}
+	for j, i := range renewedAt {
+		resp.Results[i].Status, resp.Results[i].Assignment = constant.RoleAssignmentAssigned, aggregate.UserRoleRespFromModel(&renewals[j], roles[i])
+		userIDs = append(userIDs, renewals[j].UserID)
+	}
+	resp.Succeeded = len(creates) + len(renewals)
+	s.invalidateUserPermissions(ctx, userIDs...)
+
+	for _, result := range resp.Results {
+		if result.Status == constant.RoleAssignmentAssigned {
+			publishEvent(ctx, s.events, s.logger, constant.EventRoleAssigned, result.Assignment.UserID, result.Assignment)
+		}
+	}
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Bulk assigned %d roles (%d skipped, %d failed)", resp.Succeeded, resp.Skipped, resp.Failed))
+
+	return resp, nil
+}
+
+// BulkRemoveRoles removes every matching assignment in one statement and reports each item's outcome.
+// Items that are not assigned are skipped.
+func (s *RoleSvc) BulkRemoveRoles(ctx context.Context, req aggregate.BulkRemoveRolesReq) (*aggregate.BulkRoleAssignmentResp, error) {
+	ctx, span := tracing.Start(ctx, "RoleSvc.BulkRemoveRoles")
+	defer span.End()
+
+	resp := &aggregate.BulkRoleAssignmentResp{Results: make([]aggregate.BulkRoleAssignmentResult, len(req.Assignments))}
+	var ids, userIDs []string
+	var removedAt []int
+	var history []model.RoleHistory
+	for i, item := range req.Assignments {
+		result := &resp.Results[i]
+		result.Index = i
+		role, existing, err := s.checkRemoval(ctx, item)
+		if err != nil {
+			if errorx.GetCode(err) == errorx.ErrInternal {
+				return nil, err
+			}
+			result.Status, result.Error = constant.RoleAssignmentError, err.Error()
+			resp.Failed++
+			continue
+		}
+		if existing == nil || slices.Contains(ids, existing.ID) {
+			result.Status = constant.RoleAssignmentSkipped
+			resp.Skipped++
+			continue
+		}
+		result.Assignment = aggregate.UserRoleRespFromModel(existing, nil)
+		ids = append(ids, existing.ID)
+		userIDs = append(userIDs, existing.UserID)
+		removedAt = append(removedAt, i)
+		history = append(history, newRoleHistory(ctx, constant.RoleHistoryRemove, role, existing.UserID, assignmentSnapshot(existing), nil))
+	}
+
+	err := withTx(ctx, s.txManager, func(ctx context.Context) error {
+		if err := s.userRoleRepo.DeleteByIDs(ctx, ids); err != nil {
+			return errorx.Wrap(errorx.ErrRoleAssignment, err)
+		}
+		return s.recordHistory(ctx, history...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range removedAt {
+		resp.Results[i].Status = constant.RoleAssignmentRemoved
+		publishEvent(ctx, s.events, s.logger, constant.EventRoleRemoved, req.Assignments[i].UserID, req.Assignments[i])
+	}
+	resp.Succeeded = len(ids)
+	s.invalidateUserPermissions(ctx, userIDs...)
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Bulk removed %d roles (%d skipped, %d failed)", resp.Succeeded, resp.Skipped, resp.Failed))
+
+	return resp, nil
+}
+
+// checkAssignment validates an assignment and returns its role and the stored assignment, which may have
+// expired, or nil when there is none
+func (s *RoleSvc) checkAssignment(ctx context.Context, req aggregate.AssignRoleToUserReq) (*model.Role, *model.UserRole, error) {
 	// Check if user exists
 	user := s.userRepo.FindOneById(ctx, req.UserID)
 	if user == nil {
-		return nil, errorx.New(errorx.ErrUserNotFound, "User not found")
+		return nil, nil, errorx.New(errorx.ErrUserNotFound, "User not found")
 	}
 
 	// Check if role exists
 	role := s.roleRepo.FindOneById(ctx, req.RoleID)
 	if role == nil {
-		return nil, errorx.New(errorx.ErrRoleNotFound, "Role not found")
+		return nil, nil, errorx.New(errorx.ErrRoleNotFound, "Role not found")
 	}
 
 	// Validate system role assignment
-	if role.ProjectID != nil && *role.ProjectID == constant.SystemProjectID && !isSuperAdmin {
-		return nil, errorx.New(errorx.ErrSystemRoleProtected, "Only super admins can assign system roles")
+	if role.ProjectID != nil && *role.ProjectID == constant.SystemProjectID && !isSuperAdminFromContext(ctx) {
+		return nil, nil, errorx.New(errorx.ErrSystemRoleProtected, "Only super admins can assign system roles")
 	}
 
-	// Check if assignment already exists
-	existing, err := s.userRoleRepo.FindByUserIDAndRoleID(ctx, req.UserID, req.RoleID, req.ProjectID)
-	if err != nil {
-		return nil, errorx.Wrap(errorx.ErrInternal, err)
-	}
-	if existing != nil {
-		return nil, errorx.New(errorx.ErrConflict, "User already has this role")
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, nil, errorx.New(errorx.ErrBadRequest, "expiresAt must be in the future")
 	}
 
-	// Create user role assignment
-	userRole := &model.UserRole{
-		UserID:    req.UserID,
-		RoleID:    req.RoleID,
-		ProjectID: req.ProjectID,
+	// Project-scoped roles are only granted to active members of the project
+	if req.ProjectID != nil && *req.ProjectID != constant.SystemProjectID {
+		if err := checkProjectNotArchived(ctx, s.projectRepo, *req.ProjectID); err != nil {
+			return nil, nil, err
+		}
+		if err := checkProjectMember(ctx, s.memberRepo, *req.ProjectID, req.UserID); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	created, err := s.userRoleRepo.Create(ctx, userRole)
+	existing, err := s.userRoleRepo.FindByUserIDAndRoleID(ctx, req.UserID, req.RoleID, req.ProjectID)
 	if err != nil {
-		return nil, errorx.Wrap(errorx.ErrRoleAssignment, err)
+		return nil, nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
-
-	go s.clearUserPermissionsCache(req.UserID)
-
-	s.logger.Info(fmt.Sprintf("Role assigned: user=%s, role=%s", req.UserID, req.RoleID))
-	return aggregate.UserRoleRespFromModel(created, role), nil
+	return role, existing, nil
 }
 
-// RemoveRoleFromUser removes a role from a user
-func (s *RoleSvc) RemoveRoleFromUser(ctx context.Context, req aggregate.RemoveRoleFromUserReq, isSuperAdmin bool) error {
+// checkRemoval validates a removal and returns its role and the stored assignment, or nil when there is none
+func (s *RoleSvc) checkRemoval(ctx context.Context, req aggregate.RemoveRoleFromUserReq) (*model.Role, *model.UserRole, error) {
 	// Check if role exists
 	role := s.roleRepo.FindOneById(ctx, req.RoleID)
 	if role == nil {
-		return errorx.New(errorx.ErrRoleNotFound, "Role not found")
+		return nil, nil, errorx.New(errorx.ErrRoleNotFound, "Role not found")
 	}
 
 	// Validate system role removal
-	if role.ProjectID != nil && *role.ProjectID == constant.SystemProjectID && !isSuperAdmin {
-		return errorx.New(errorx.ErrSystemRoleProtected, "Only super admins can remove system roles")
+	if role.ProjectID != nil && *role.ProjectID == constant.SystemProjectID && !isSuperAdminFromContext(ctx) {
+		return nil, nil, errorx.New(errorx.ErrSystemRoleProtected, "Only super admins can remove system roles")
 	}
 
-	// Check if assignment exists
 	existing, err := s.userRoleRepo.FindByUserIDAndRoleID(ctx, req.UserID, req.RoleID, req.ProjectID)
 	if err != nil {
-		return errorx.Wrap(errorx.ErrInternal, err)
-	}
-	if existing == nil {
-		return errorx.New(errorx.ErrNotFound, "User role assignment not found")
+		return nil, nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
-
-	if err := s.userRoleRepo.DeleteByUserIDAndRoleID(ctx, req.UserID, req.RoleID, req.ProjectID); err != nil {
-		return errorx.Wrap(errorx.ErrRoleAssignment, err)
-	}
-
-	go s.clearUserPermissionsCache(req.UserID)
-
-	s.logger.Info(fmt.Sprintf("Role removed: user=%s, role=%s", req.UserID, req.RoleID))
-
-	return nil
+	return role, existing, nil
 }
 
 // GetUserRoles retrieves all roles assigned to a user
@@ -318,35 +553,308 @@ func (s *RoleSvc) GetUserRoles(ctx context.Context, req aggregate.GetUserRolesRe
 
 // GetUserPermissions retrieves all permissions assigned to a user
 func (s *RoleSvc) GetUserPermissions(ctx context.Context, userID string) (aggregate.UserPermissions, error) {
-	// cache the permissions for the user
-	cacheKey := s.userPermissionsCacheKey(userID)
+	ctx, span := tracing.Start(ctx, "RoleSvc.GetUserPermissions")
+	defer span.End()
+	// cache the permissions for the user; concurrent misses share one load, which must not fail for all of
+	// them when the request that started it goes away
 	var permissions aggregate.UserPermissions
-	err := s.cache.Get(cacheKey, &permissions)
-	if err == nil {
-		return permissions, nil
-	} else if err != cache.ErrCacheNil {
-		return aggregate.UserPermissions{}, errorx.Wrap(errorx.ErrInternal, err)
+	err := s.cache.WithContext(ctx).GetOrLoad(s.userPermissionsCacheKey(userID), &permissions, func() (any, time.Duration, error) {
+		return s.loadUserPermissions(context.WithoutCancel(ctx), userID)
+	})
+	if err != nil {
+		if _, ok := err.(*errorx.AppError); ok {
+			return nil, err
+		}
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
+	return permissions, nil
+}
 
+// loadUserPermissions resolves the user's permissions from their role assignments, or from the effective
+// access table when it is served, with how long they may be cached.
+func (s *RoleSvc) loadUserPermissions(ctx context.Context, userID string) (aggregate.UserPermissions, time.Duration, error) {
+	if s.access.Serving() {
+		return s.loadEffectivePermissions(ctx, userID)
+	}
 	userRoles, err := s.userRoleRepo.FindByUserID(ctx, userID)
 	if err != nil {
-		return nil, errorx.Wrap(errorx.ErrInternal, err)
+		return nil, 0, errorx.Wrap(errorx.ErrInternal, err)
 	}
+	return s.permissionsFromAssignments(ctx, userRoles)
+}
 
-	// Get all permissions from the user roles and loop through each role permissions with the project ID
-	permissions = make(aggregate.UserPermissions)
+// permissionsFromAssignments collects the permissions granted by one user's role assignments.
+func (s *RoleSvc) permissionsFromAssignments(ctx context.Context, userRoles []model.UserRole) (aggregate.UserPermissions, time.Duration, error) {
+	// Get all permissions from the user roles and loop through each role permissions with the project ID.
+	// Codes another project defined take no effect outside it. The cache must not outlive the first
+	// assignment to expire.
+	permissions := make(aggregate.UserPermissions)
+	ttl := constant.CacheDefaultTTL
 	for _, userRole := range userRoles {
-		for _, permissionCode := range model.PermissionsFromJSON(userRole.Role.Permissions) {
+		if userRole.ExpiresAt != nil {
+			ttl = min(ttl, time.Until(*userRole.ExpiresAt))
+		}
+		codes, err := s.permissionSvc.EffectiveCodes(ctx, userRole.ProjectID, model.PermissionsFromJSON(userRole.Role.Permissions))
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, permissionCode := range codes {
 			permissions[s.buildPermissionKey(permissionCode, userRole.ProjectID)] = true
 		}
 	}
+	return permissions, ttl, nil
+}
 
+// loadEffectivePermissions is loadUserPermissions from the user's effective access rows: one indexed read,
+// with the codes filtered against the permission catalog as for role assignments.
+func (s *RoleSvc) loadEffectivePermissions(ctx context.Context, userID string) (aggregate.UserPermissions, time.Duration, error) {
+	rows, err := s.access.UserPermissions(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.permissionsFromRows(ctx, rows)
+}
+
+// permissionsFromRows collects the permissions in one user's effective access rows.
+func (s *RoleSvc) permissionsFromRows(ctx context.Context, rows []model.EffectiveAccess) (aggregate.UserPermissions, time.Duration, error) {
+	scopes := make(map[string][]string)
+	projects := make(map[string]*string)
 	ttl := constant.CacheDefaultTTL
-	if err := s.cache.Set(cacheKey, permissions, &ttl); err != nil {
-		return aggregate.UserPermissions{}, errorx.Wrap(errorx.ErrInternal, err)
+	for _, row := range rows {
+		if row.ExpiresAt != nil {
+			ttl = min(ttl, time.Until(*row.ExpiresAt))
+		}
+		scope := permissionScope(row.ProjectID)
+		scopes[scope] = append(scopes[scope], row.Resource)
+		projects[scope] = row.ProjectID
+	}
+	permissions := make(aggregate.UserPermissions)
+	for scope, codes := range scopes {
+		codes, err := s.permissionSvc.EffectiveCodes(ctx, projects[scope], codes)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, permissionCode := range codes {
+			permissions[s.buildPermissionKey(permissionCode, projects[scope])] = true
+		}
 	}
+	return permissions, ttl, nil
+}
 
-	return permissions, nil
+// GetUsersPermissions is GetUserPermissions for many users at once, for admin screens and batch jobs. The
+// cached maps are read in one round trip; the others are resolved with one query and cached in one more.
+// Every requested user gets an entry, empty when they hold nothing.
+func (s *RoleSvc) GetUsersPermissions(ctx context.Context, userIDs []string) (map[string]aggregate.UserPermissions, error) {
+	ctx, span := tracing.Start(ctx, "RoleSvc.GetUsersPermissions")
+	defer span.End()
+
+	userIDs = slices.Clone(userIDs)
+	slices.Sort(userIDs)
+	userIDs = slices.Compact(userIDs)
+	result := make(map[string]aggregate.UserPermissions, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	// A cache that cannot be read only costs the database reads it would have saved.
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = s.userPermissionsCacheKey(userID)
+	}
+	cached, err := s.cache.WithContext(ctx).GetMany(keys)
+	if err != nil {
+		s.logger.Warn("Failed to read cached user permissions", "error", err)
+	}
+	var missing []string
+	for i, userID := range userIDs {
+		var permissions aggregate.UserPermissions
+		if raw, ok := cached[keys[i]]; ok && json.Unmarshal(raw, &permissions) == nil {
+			result[userID] = permissions
+			continue
+		}
+		missing = append(missing, userID)
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	loaded, err := s.loadUsersPermissions(ctx, missing)
+	if err != nil {
+		if _, ok := err.(*errorx.AppError); ok {
+			return nil, err
+		}
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	entries := make([]cache.Entry, 0, len(missing))
+	for _, userID := range missing {
+		result[userID] = loaded[userID].permissions
+		entries = append(entries, cache.Entry{
+			Key:   s.userPermissionsCacheKey(userID),
+			Value: loaded[userID].permissions,
+			TTL:   loaded[userID].ttl,
+		})
+	}
+	if err := s.cache.WithContext(ctx).SetMany(entries); err != nil {
+		s.logger.Warn("Failed to cache user permissions", "count", len(entries), "error", err)
+	}
+	return result, nil
+}
+
+// loadedPermissions is one user's resolved permissions and how long they may be cached.
+type loadedPermissions struct {
+	permissions aggregate.UserPermissions
+	ttl         time.Duration
+}
+
+// loadUsersPermissions is loadUserPermissions for several users with a single query. Users holding nothing
+// get an empty map.
+func (s *RoleSvc) loadUsersPermissions(ctx context.Context, userIDs []string) (map[string]loadedPermissions, error) {
+	loaded := make(map[string]loadedPermissions, len(userIDs))
+	if s.access.Serving() {
+		rows, err := s.access.UsersPermissions(ctx, userIDs)
+		if err != nil {
+			return nil, err
+		}
+		byUser := make(map[string][]model.EffectiveAccess)
+		for _, row := range rows {
+			byUser[row.Subject] = append(byUser[row.Subject], row)
+		}
+		for _, userID := range userIDs {
+			permissions, ttl, err := s.permissionsFromRows(ctx, byUser[userID])
+			if err != nil {
+				return nil, err
+			}
+			loaded[userID] = loadedPermissions{permissions: permissions, ttl: ttl}
+		}
+		return loaded, nil
+	}
+
+	userRoles, err := s.userRoleRepo.FindByUserIDs(ctx, userIDs)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	byUser := make(map[string][]model.UserRole)
+	for _, userRole := range userRoles {
+		byUser[userRole.UserID] = append(byUser[userRole.UserID], userRole)
+	}
+	for _, userID := range userIDs {
+		permissions, ttl, err := s.permissionsFromAssignments(ctx, byUser[userID])
+		if err != nil {
+			return nil, err
+		}
+		loaded[userID] = loadedPermissions{permissions: permissions, ttl: ttl}
+	}
+	return loaded, nil
+}
+
+// CheckUserPermission reports whether a user holds a permission code in a project (the system scope when
+// ProjectID is nil), resolved the same way as GetUserPermissions, and lists the assigned roles granting it.
+func (s *RoleSvc) CheckUserPermission(ctx context.Context, req aggregate.CheckPermissionReq) (*aggregate.CheckPermissionResp, error) {
+	// Only the assignments come from a replica: the permission catalog below is cached, and a lagging read
+	// would keep serving stale entries after the lag is gone.
+	userRoles, err := s.userRoleRepo.FindByUserID(repository.WithReplicaReads(ctx), req.UserID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	want := s.buildPermissionKey(req.Permission, req.ProjectID)
+	resp := &aggregate.CheckPermissionResp{Roles: []aggregate.PermissionGrantRole{}}
+	for _, userRole := range userRoles {
+		if s.buildPermissionKey(req.Permission, userRole.ProjectID) != want {
+			continue
+		}
+		codes, err := s.permissionSvc.EffectiveCodes(ctx, userRole.ProjectID, model.PermissionsFromJSON(userRole.Role.Permissions))
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(codes, req.Permission) {
+			resp.Roles = append(resp.Roles, aggregate.PermissionGrantRole{
+				ID:        userRole.Role.ID,
+				Code:      userRole.Role.Code,
+				Name:      userRole.Role.Name,
+				ProjectID: userRole.ProjectID,
+			})
+		}
+	}
+	resp.Allowed = len(resp.Roles) > 0
+	return resp, nil
+}
+
+// CleanupExpiredAssignments removes expired role assignments and invalidates the affected users' permissions
+func (s *RoleSvc) CleanupExpiredAssignments(ctx context.Context) (int64, error) {
+	expired, err := s.userRoleRepo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return 0, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	userIDs := make([]string, len(expired))
+	for i := range expired {
+		userIDs[i] = expired[i].UserID
+	}
+	s.invalidateUserPermissions(ctx, userIDs...)
+
+	if len(expired) > 0 {
+		logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Cleaned up %d expired role assignments", len(expired)))
+	}
+
+	return int64(len(expired)), nil
+}
+
+// SyncExternalRoles reconciles the roles a provider manages (per the role mapping table) with the
+// group/role claims sent on login: mapped roles are assigned when a claim matches and removed when it no longer does.
+// Roles not referenced by the provider's mappings are left untouched.
+func (s *RoleSvc) SyncExternalRoles(ctx context.Context, userID string, provider constant.UserAuthType, claims []string) error {
+	targets := s.roleMapping.Targets(provider)
+	if len(targets) == 0 {
+		return nil
+	}
+
+	desired := make(map[string]bool)
+	for _, t := range s.roleMapping.Resolve(provider, claims) {
+		desired[rolemapping.TargetKey(t.RoleCode, t.ProjectID)] = true
+	}
+
+	changed := false
+	for _, t := range targets {
+		role, err := s.roleRepo.FindByCode(ctx, t.RoleCode)
+		if err != nil {
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+		if role == nil {
+			logger.WithContext(ctx, s.logger).Warn(fmt.Sprintf("Role mapping references unknown role: provider=%s, role=%s", provider, t.RoleCode))
+			continue
+		}
+
+		existing, err := s.userRoleRepo.FindByUserIDAndRoleID(ctx, userID, role.ID, t.ProjectID)
+		if err != nil {
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+
+		want := desired[rolemapping.TargetKey(t.RoleCode, t.ProjectID)]
+		switch {
+		case want && existing == nil:
+			if _, err := s.userRoleRepo.Create(ctx, &model.UserRole{
+				UserID:    userID,
+				RoleID:    role.ID,
+				ProjectID: t.ProjectID,
+			}); err != nil {
+				return errorx.Wrap(errorx.ErrRoleAssignment, err)
+			}
+			changed = true
+			logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Role synced from %s: user=%s, role=%s", provider, userID, role.ID))
+		case !want && existing != nil:
+			if err := s.userRoleRepo.DeleteByUserIDAndRoleID(ctx, userID, role.ID, t.ProjectID); err != nil {
+				return errorx.Wrap(errorx.ErrInternal, err)
+			}
+			changed = true
+			logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("Role revoked by %s sync: user=%s, role=%s", provider, userID, role.ID))
+		}
+	}
+
+	if changed {
+		s.invalidateUserPermissions(ctx, userID)
+	}
+	return nil
 }
 
 func (s *RoleSvc) buildPermissionKey(permissionCode string, projectID *string) string {
@@ -361,7 +869,85 @@ func (s *RoleSvc) userPermissionsCacheKey(userID string) string {
 	return fmt.Sprintf("user_permissions:%s", userID)
 }
 
-func (s *RoleSvc) clearUserPermissionsCache(userID string) {
-	cacheKey := s.userPermissionsCacheKey(userID)
-	_ = s.cache.Delete(cacheKey)
+// Cached permissions are deleted up to permissionInvalidationAttempts times, waiting
+// permissionInvalidationDelay before the second attempt and twice as long before each further one.
+const (
+	permissionInvalidationAttempts = 3
+	permissionInvalidationDelay    = 50 * time.Millisecond
+)
+
+// roleHolderBatchSize is how many holders of a changed role have their permissions invalidated at a time.
+const roleHolderBatchSize = 100
+
+// permissionRedeleteDelay is how long after a change its users' cached permissions are deleted again, to
+// drop what a load that read the old assignments wrote back in the meantime. Shutdown cuts it short.
+const permissionRedeleteDelay = time.Second
+
+// invalidateUserPermissions brings the effective access rows of each distinct user up to date, then drops
+// their cached permissions before returning, so the change is in force once the request that made it
+// completes. With a local cache tier, the delete also reaches the other instances over pub/sub. The change
+// is committed by then, so a cache that stays down is logged rather than failing the request; the entries
+// still expire within CacheDefaultTTL. Retries stop when the caller goes away, leaving the later delete to
+// catch up.
+func (s *RoleSvc) invalidateUserPermissions(ctx context.Context, userIDs ...string) {
+	userIDs = slices.Clone(userIDs)
+	slices.Sort(userIDs)
+	userIDs = slices.Compact(userIDs)
+	if len(userIDs) == 0 {
+		return
+	}
+	// A client hanging up must not leave the change half applied.
+	s.access.RefreshUsers(context.WithoutCancel(ctx), userIDs...)
+	for _, userID := range userIDs {
+		s.deleteUserPermissions(ctx, userID)
+	}
+	s.workers.Go(func() {
+		ctx, cancel := s.workers.Context(ctx)
+		defer cancel()
+		s.workers.Sleep(permissionRedeleteDelay)
+		for _, userID := range userIDs {
+			s.deleteUserPermissions(ctx, userID)
+		}
+	})
+}
+
+// invalidateRoleHolders invalidates the cached permissions of everyone holding the role before returning,
+// roleHolderBatchSize holders at a time, so UpdateRole and DeleteRole take effect when they return. Only the
+// delayed second delete of each batch runs in the background.
+func (s *RoleSvc) invalidateRoleHolders(ctx context.Context, roleID string) {
+	holders, err := s.userRoleRepo.FindByRoleID(ctx, roleID)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("Failed to list role holders to invalidate their permissions", "roleId", roleID, "error", err)
+		return
+	}
+	userIDs := make([]string, len(holders))
+	for i := range holders {
+		userIDs[i] = holders[i].UserID
+	}
+	for batch := range slices.Chunk(userIDs, roleHolderBatchSize) {
+		s.invalidateUserPermissions(ctx, batch...)
+	}
+}
+
+// deleteUserPermissions deletes the user's cached permissions, retrying a failed delete until ctx is done.
+func (s *RoleSvc) deleteUserPermissions(ctx context.Context, userID string) {
+	delay := permissionInvalidationDelay
+	for attempt := 1; ; attempt++ {
+		err := s.cache.WithContext(context.WithoutCancel(ctx)).Delete(s.userPermissionsCacheKey(userID))
+		if err == nil {
+			return
+		}
+		if attempt >= permissionInvalidationAttempts {
+			logger.WithContext(ctx, s.logger).Error("Failed to invalidate cached permissions", "userId", userID, "attempts", attempt, "error", err)
+			return
+		}
+		logger.WithContext(ctx, s.logger).Warn("Failed to invalidate cached permissions, retrying", "userId", userID, "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			logger.WithContext(ctx, s.logger).Error("Failed to invalidate cached permissions", "userId", userID, "attempts", attempt, "error", err)
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
 }
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"gorm.io/datatypes"
+)
+
+// GetRoleHistory lists the recorded changes to a role and its assignments, newest first. The history of a
+// deleted role stays available.
+func (s *RoleSvc) GetRoleHistory(ctx context.Context, roleID string, page, pageSize int) (*aggregate.PaginationResp[aggregate.RoleHistoryResp], error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+	offset := (page - 1) * pageSize
+
+	entries, total, err := s.historyRepo.ListByRoleID(ctx, roleID, offset, pageSize)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[RoleSvc] failed to list role history", "roleID", roleID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	items := make([]aggregate.RoleHistoryResp, len(entries))
+	for i := range entries {
+		items[i].FromModel(&entries[i])
+	}
+	return &aggregate.PaginationResp[aggregate.RoleHistoryResp]{
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+		HasNext:  int64(offset+len(entries)) < total,
+		Items:    items,
+	}, nil
+}
+
+// recordHistory saves entries made with newRoleHistory. Call it in the transaction of the change, so a change
+// is never left out of the history.
+func (s *RoleSvc) recordHistory(ctx context.Context, entries ...model.RoleHistory) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := s.historyRepo.BulkCreate(ctx, entries); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[RoleSvc] failed to record role history", "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return nil
+}
+
+// newRoleHistory returns the history entry of an action on role by the caller in ctx. userID is the user of an
+// assignment; before and after are the snapshots made with roleSnapshot or assignmentSnapshot.
+func newRoleHistory(ctx context.Context, action string, role *model.Role, userID string, before, after datatypes.JSON) model.RoleHistory {
+	actorID, actorType := actorFromContext(ctx)
+	metaJSON, _ := json.Marshal(map[string]any{"request_id": logger.RequestIDFromContext(ctx)})
+	return model.RoleHistory{
+		RoleID:    role.ID,
+		ProjectID: role.ProjectID,
+		Action:    action,
+		ActorID:   actorID,
+		ActorType: actorType,
+		UserID:    userID,
+		Before:    before,
+		After:     after,
+		BaseModel: model.BaseModel{Metadata: datatypes.JSON(metaJSON)},
+	}
+}
+
+// roleSnapshot returns the role as the API shows it, or nil for no role.
+func roleSnapshot(role *model.Role) datatypes.JSON {
+	if role == nil {
+		return nil
+	}
+	return snapshotJSON(aggregate.RoleRespFromModel(role))
+}
+
+// assignmentSnapshot returns the assignment as the API shows it, or nil for no assignment.
+func assignmentSnapshot(userRole *model.UserRole) datatypes.JSON {
+	if userRole == nil {
+		return nil
+	}
+	return snapshotJSON(aggregate.UserRoleRespFromModel(userRole, nil))
+}
+
+func snapshotJSON(v any) datatypes.JSON {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return datatypes.JSON(b)
+}
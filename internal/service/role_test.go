@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+	"github.com/hiamthach108/dreon-auth/pkg/background"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+type roleFixture struct {
+	svc         IRoleSvc
+	roles       *testutil.RoleRepository
+	userRoles   *testutil.UserRoleRepository
+	users       *testutil.UserRepository
+	projects    *testutil.ProjectRepository
+	permissions *testutil.PermissionRepository
+	cache       *testutil.Cache
+}
+
+// newRoleFixture returns a role service over empty in-memory stores.
+func newRoleFixture(t *testing.T) *roleFixture {
+	t.Helper()
+	log := testutil.NewLogger()
+	roles := testutil.NewRoleRepository()
+	f := &roleFixture{
+		roles:       roles,
+		userRoles:   testutil.NewUserRoleRepository(roles),
+		users:       testutil.NewUserRepository(),
+		projects:    testutil.NewProjectRepository(),
+		permissions: testutil.NewPermissionRepository(),
+		cache:       testutil.NewCache(),
+	}
+	access, err := NewEffectiveAccessSvc(&config.AppConfig{}, log, testutil.NewEffectiveAccessRepository(), f.users, f.userRoles,
+		testutil.NewRelationTupleRepository(), testutil.NewRelationNamespaceRepository(), testutil.TxManager{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workers := background.NewGroup()
+	t.Cleanup(func() {
+		workers.Stop()
+		workers.Wait(context.Background())
+	})
+	permissionSvc := NewPermissionSvc(log, f.permissions, f.projects, nil, f.cache)
+	f.svc = NewRoleSvc(log, roles, f.userRoles, testutil.NewRoleHistoryRepository(), f.users, testutil.TxManager{},
+		testutil.NewProjectMemberRepository(), f.projects, permissionSvc, access, nil, f.cache, testutil.NewEventPublisher(), workers)
+	return f
+}
+
+// role stores an active role granting permissions behind the service's back.
+func (f *roleFixture) role(t *testing.T, code string, projectID *string, permissions ...string) *model.Role {
+	t.Helper()
+	role, err := f.roles.Create(context.Background(), &model.Role{
+		Code: code, Name: code, ProjectID: projectID, IsActive: true, Permissions: model.PermissionsToJSON(permissions),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return role
+}
+
+// asAdmin returns a context authenticated as the super admin "admin".
+func asAdmin() context.Context {
+	return context.WithValue(context.Background(), constant.JWT_PAYLOAD_CONTEXT_KEY, &jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+}
+
+// downCache fails every delete and counts the attempts.
+type downCache struct {
+	*testutil.Cache
+	deletes int
+}
+
+func (c *downCache) WithContext(ctx context.Context) cache.ICache { return c }
+
+func (c *downCache) Delete(key string) error {
+	c.deletes++
+	return errors.New("cache down")
+}
+
+func TestRoleSvc_DeleteUserPermissionsRetries(t *testing.T) {
+	c := &downCache{Cache: testutil.NewCache()}
+	svc := &RoleSvc{logger: testutil.NewLogger(), cache: c}
+
+	svc.deleteUserPermissions(context.Background(), "u1")
+	if c.deletes != permissionInvalidationAttempts {
+		t.Errorf("deletes = %d, want %d", c.deletes, permissionInvalidationAttempts)
+	}
+
+	// Once the caller is gone the delete is still tried, but not waited on again.
+	c.deletes = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	svc.deleteUserPermissions(ctx, "u1")
+	if c.deletes != 1 || time.Since(start) >= permissionInvalidationDelay {
+		t.Errorf("canceled caller: deletes = %d after %v, want 1 without waiting", c.deletes, time.Since(start))
+	}
+}
+
+func TestRoleSvc_CheckUserPermission(t *testing.T) {
+	ctx := context.Background()
+	f := newRoleFixture(t)
+	project, _ := f.projects.Create(ctx, &model.Project{Code: "acme", Name: "Acme"})
+	viewer := f.role(t, "viewer", nil, "users.view")
+	editor := f.role(t, "editor", nil, "users.view", "users.update")
+	f.userRoles.Create(ctx, &model.UserRole{UserID: "erin", RoleID: viewer.ID, ProjectID: &project.ID})
+	f.userRoles.Create(ctx, &model.UserRole{UserID: "erin", RoleID: editor.ID, ProjectID: &project.ID})
+	f.userRoles.Create(ctx, &model.UserRole{UserID: "erin", RoleID: viewer.ID})
+
+	check := func(req aggregate.CheckPermissionReq) aggregate.CheckPermissionResp {
+		t.Helper()
+		result, err := f.svc.CheckUserPermission(ctx, req)
+		if err != nil {
+			t.Fatalf("CheckUserPermission(%+v) err = %v", req, err)
+		}
+		return *result
+	}
+	result := check(aggregate.CheckPermissionReq{UserID: "erin", Permission: "users.view", ProjectID: &project.ID})
+	if !result.Allowed || len(result.Roles) != 2 || result.Roles[0].Code != "viewer" || result.Roles[1].Code != "editor" {
+		t.Errorf("users.view in project = %+v, want allowed by viewer and editor", result)
+	}
+	result = check(aggregate.CheckPermissionReq{UserID: "erin", Permission: "users.update"})
+	if result.Allowed || len(result.Roles) != 0 {
+		t.Errorf("users.update in system scope = %+v, want denied", result)
+	}
+	result = check(aggregate.CheckPermissionReq{UserID: "erin", Permission: "users.view"})
+	if !result.Allowed || len(result.Roles) != 1 || result.Roles[0].ProjectID != nil {
+		t.Errorf("users.view in system scope = %+v, want allowed by the system assignment", result)
+	}
+}
+
+func TestRoleSvc_AssignmentExpiry(t *testing.T) {
+	ctx := asAdmin()
+	f := newRoleFixture(t)
+	user, _ := f.users.Create(ctx, &model.User{Username: "frank", Email: "frank@example.com"})
+	oncall := f.role(t, "oncall", nil, "users.update")
+	auditor := f.role(t, "auditor", nil, "audit.read")
+
+	past := time.Now().Add(-time.Minute)
+	_, err := f.svc.AssignRoleToUser(ctx, aggregate.AssignRoleToUserReq{UserID: user.ID, RoleID: oncall.ID, ExpiresAt: &past})
+	wantCode(t, "AssignRoleToUser with past expiresAt", err, errorx.ErrBadRequest)
+	future := time.Now().Add(time.Hour)
+	assigned, err := f.svc.AssignRoleToUser(ctx, aggregate.AssignRoleToUserReq{UserID: user.ID, RoleID: oncall.ID, ExpiresAt: &future})
+	if err != nil || assigned.ExpiresAt == nil {
+		t.Fatalf("AssignRoleToUser temporary = %+v, %v", assigned, err)
+	}
+
+	// An assignment past its expiry grants nothing, even before the cleanup job removes it.
+	f.userRoles.Create(ctx, &model.UserRole{UserID: user.ID, RoleID: auditor.ID, ExpiresAt: &past})
+	permissions, err := f.svc.GetUserPermissions(ctx, user.ID)
+	if err != nil || !permissions["system/users.update"] || permissions["system/audit.read"] {
+		t.Errorf("GetUserPermissions = %v, %v, want only system/users.update", permissions, err)
+	}
+
+	// Assigning the role again renews the expired assignment.
+	if _, err := f.svc.AssignRoleToUser(ctx, aggregate.AssignRoleToUserReq{UserID: user.ID, RoleID: auditor.ID}); err != nil {
+		t.Fatalf("reassign expired role err = %v", err)
+	}
+	if renewed, _ := f.userRoles.FindByUserIDAndRoleID(ctx, user.ID, auditor.ID, nil); renewed == nil || renewed.ExpiresAt != nil {
+		t.Errorf("renewed assignment = %+v, want permanent", renewed)
+	}
+}
+
+func TestRoleSvc_History(t *testing.T) {
+	ctx := asAdmin()
+	f := newRoleFixture(t)
+	user, _ := f.users.Create(ctx, &model.User{Username: "hana", Email: "hana@example.com"})
+	f.permissions.BulkCreate(ctx, []model.Permission{{Code: "users.view", Name: "User View"}, {Code: "users.update", Name: "User Update"}})
+
+	role, err := f.svc.CreateRole(ctx, aggregate.CreateRoleReq{Code: "support", Name: "Support", Permissions: []string{"users.view"}})
+	if err != nil {
+		t.Fatalf("CreateRole err = %v", err)
+	}
+	if _, err := f.svc.UpdateRole(ctx, role.ID, aggregate.UpdateRoleReq{Name: "Support", Permissions: []string{"users.view", "users.update"}}); err != nil {
+		t.Fatalf("UpdateRole err = %v", err)
+	}
+	if _, err := f.svc.AssignRoleToUser(ctx, aggregate.AssignRoleToUserReq{UserID: user.ID, RoleID: role.ID}); err != nil {
+		t.Fatalf("AssignRoleToUser err = %v", err)
+	}
+	if err := f.svc.RemoveRoleFromUser(ctx, aggregate.RemoveRoleFromUserReq{UserID: user.ID, RoleID: role.ID}); err != nil {
+		t.Fatalf("RemoveRoleFromUser err = %v", err)
+	}
+	if err := f.svc.DeleteRole(ctx, role.ID); err != nil {
+		t.Fatalf("DeleteRole err = %v", err)
+	}
+
+	// The history outlives the role.
+	history, err := f.svc.GetRoleHistory(ctx, role.ID, 1, 20)
+	if err != nil || history.Total != 5 {
+		t.Fatalf("GetRoleHistory = %+v, %v, want 5 entries", history, err)
+	}
+	wantActions := []string{constant.RoleHistoryDelete, constant.RoleHistoryRemove, constant.RoleHistoryAssign, constant.RoleHistoryUpdate, constant.RoleHistoryCreate}
+	for i, action := range wantActions {
+		entry := history.Items[i]
+		if entry.Action != action || entry.ActorID != "admin" || entry.ActorType != constant.ActorTypeUser {
+			t.Errorf("entry %d = %s by %s %s, want %s by user admin", i, entry.Action, entry.ActorType, entry.ActorID, action)
+		}
+	}
+
+	var before, after aggregate.RoleResp
+	updated := history.Items[3]
+	if err := json.Unmarshal(updated.Before, &before); err != nil {
+		t.Fatalf("update before snapshot: %v", err)
+	}
+	if err := json.Unmarshal(updated.After, &after); err != nil {
+		t.Fatalf("update after snapshot: %v", err)
+	}
+	if len(before.Permissions) != 1 || len(after.Permissions) != 2 {
+		t.Errorf("update snapshots: before %v, after %v", before.Permissions, after.Permissions)
+	}
+	if created := history.Items[4]; len(created.Before) != 0 || created.After == nil {
+		t.Errorf("create snapshots: before %s, after %s", created.Before, created.After)
+	}
+	var assignment aggregate.UserRoleResp
+	assigned := history.Items[2]
+	if err := json.Unmarshal(assigned.After, &assignment); err != nil || assigned.UserID != user.ID || assignment.UserID != user.ID {
+		t.Errorf("assign entry = %+v", assigned)
+	}
+	if removed := history.Items[1]; removed.Before == nil || len(removed.After) != 0 {
+		t.Errorf("remove snapshots: before %s, after %s", removed.Before, removed.After)
+	}
+}
+
+func TestRoleSvc_ListRolesPages(t *testing.T) {
+	ctx := asAdmin()
+	f := newRoleFixture(t)
+	for i := range 5 {
+		f.roles.Create(ctx, &model.Role{Code: fmt.Sprintf("role-%d", i), Name: fmt.Sprintf("Role %d", i), IsActive: i != 0})
+	}
+
+	// Without filters the roles are still counted and paged by the repository, inactive ones included.
+	inactive := false
+	for _, tc := range []struct {
+		name    string
+		req     aggregate.ListRolesReq
+		total   int64
+		items   int
+		hasNext bool
+	}{
+		{"page 2", aggregate.ListRolesReq{PaginationReq: aggregate.PaginationReq{Page: 2, PageSize: 2}}, 5, 2, true},
+		{"page 3", aggregate.ListRolesReq{PaginationReq: aggregate.PaginationReq{Page: 3, PageSize: 2}}, 5, 1, false},
+		{"page 4", aggregate.ListRolesReq{PaginationReq: aggregate.PaginationReq{Page: 4, PageSize: 2}}, 5, 0, false},
+		{"inactive", aggregate.ListRolesReq{IsActive: &inactive}, 1, 1, false},
+	} {
+		result, err := f.svc.ListRoles(ctx, tc.req)
+		if err != nil || result.Total != tc.total || len(result.Items) != tc.items || result.HasNext != tc.hasNext {
+			t.Errorf("%s: %+v, %v", tc.name, result, err)
+		}
+	}
+}
+
+func TestRoleSvc_GetUsersPermissions(t *testing.T) {
+	ctx := asAdmin()
+	f := newRoleFixture(t)
+	ada, _ := f.users.Create(ctx, &model.User{Username: "ada", Email: "ada@example.com"})
+	bob, _ := f.users.Create(ctx, &model.User{Username: "bob", Email: "bob@example.com"})
+	role := f.role(t, "support", nil, "users.view")
+	if _, err := f.svc.AssignRoleToUser(ctx, aggregate.AssignRoleToUserReq{UserID: ada.ID, RoleID: role.ID}); err != nil {
+		t.Fatalf("AssignRoleToUser err = %v", err)
+	}
+	lookup := func(userIDs ...string) map[string]aggregate.UserPermissions {
+		t.Helper()
+		result, err := f.svc.GetUsersPermissions(ctx, userIDs)
+		if err != nil {
+			t.Fatalf("GetUsersPermissions err = %v", err)
+		}
+		return result
+	}
+
+	// Every user gets an entry, duplicates once, and each map is cached where GetUserPermissions reads it.
+	got := lookup(ada.ID, bob.ID, ada.ID)
+	if len(got) != 2 || !got[ada.ID]["system/users.view"] || len(got[bob.ID]) != 0 {
+		t.Fatalf("permissions = %v, want users.view for ada and nothing for bob", got)
+	}
+	keys := f.cache.Keys()
+	for _, userID := range []string{ada.ID, bob.ID} {
+		if !slices.Contains(keys, "user_permissions:"+userID) {
+			t.Errorf("cache keys = %v, want the permissions of %s", keys, userID)
+		}
+	}
+
+	// Cached maps are served as they are: an assignment written behind the service's back is not seen
+	// until the cache is invalidated.
+	f.userRoles.Create(ctx, &model.UserRole{UserID: bob.ID, RoleID: role.ID})
+	if got := lookup(bob.ID); len(got[bob.ID]) != 0 {
+		t.Errorf("cached permissions = %v, want the cached empty map", got)
+	}
+	f.cache.Delete("user_permissions:" + bob.ID)
+	if got := lookup(ada.ID, bob.ID); !got[bob.ID]["system/users.view"] || !got[ada.ID]["system/users.view"] {
+		t.Errorf("permissions after invalidation = %v, want users.view for both", got)
+	}
+}
+
+func TestRoleSvc_UserPermissionsMultipleRoles(t *testing.T) {
+	ctx := asAdmin()
+	f := newRoleFixture(t)
+	user, _ := f.users.Create(ctx, &model.User{Username: "iris", Email: "iris@example.com"})
+	for _, role := range []*model.Role{
+		f.role(t, "viewer", nil, "users.view"),
+		f.role(t, "editor", nil, "users.view", "users.update"),
+		f.role(t, "auditor", nil, "projects.view"),
+	} {
+		if _, err := f.svc.AssignRoleToUser(ctx, aggregate.AssignRoleToUserReq{UserID: user.ID, RoleID: role.ID}); err != nil {
+			t.Fatalf("AssignRoleToUser %s err = %v", role.Code, err)
+		}
+	}
+
+	// The permissions are the union of every assigned role's, whether read one user or many at a time.
+	want := aggregate.UserPermissions{"system/users.view": true, "system/users.update": true, "system/projects.view": true}
+	if got, err := f.svc.GetUserPermissions(ctx, user.ID); err != nil || !maps.Equal(got, want) {
+		t.Errorf("GetUserPermissions = %v, %v, want %v", got, err, want)
+	}
+	f.cache.Delete("user_permissions:" + user.ID)
+	if bulk, err := f.svc.GetUsersPermissions(ctx, []string{user.ID}); err != nil || !maps.Equal(bulk[user.ID], want) {
+		t.Errorf("GetUsersPermissions = %v, %v, want %v", bulk[user.ID], err, want)
+	}
+}
+
+func TestRoleSvc_UpdateRoleInvalidatesHolders(t *testing.T) {
+	ctx := asAdmin()
+	f := newRoleFixture(t)
+	f.permissions.BulkCreate(ctx, []model.Permission{{Code: "users.view", Name: "User View"}, {Code: "users.update", Name: "User Update"}})
+	role := f.role(t, "support", nil, "users.view")
+	for i := range roleHolderBatchSize + 1 {
+		f.userRoles.Create(ctx, &model.UserRole{UserID: fmt.Sprintf("u%d", i), RoleID: role.ID})
+	}
+	if _, err := f.svc.GetUsersPermissions(ctx, []string{"u0", fmt.Sprintf("u%d", roleHolderBatchSize)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every batch of holders is invalidated by the time UpdateRole returns, not later in the background.
+	if _, err := f.svc.UpdateRole(ctx, role.ID, aggregate.UpdateRoleReq{Name: "Support", Permissions: []string{"users.update"}}); err != nil {
+		t.Fatalf("UpdateRole err = %v", err)
+	}
+	for _, key := range f.cache.Keys() {
+		if strings.HasPrefix(key, "user_permissions:") {
+			t.Errorf("%s still cached after UpdateRole", key)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	gosaml "github.com/crewjam/saml"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/internal/shared/saml"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/statetoken"
+)
+
+// ISAMLSvc manages per-project SAML identity providers and runs the service provider side of SSO.
+type ISAMLSvc interface {
+	GetConnection(ctx context.Context, projectID string) (*aggregate.SAMLConnectionResp, error)
+	UpsertConnection(ctx context.Context, projectID string, req aggregate.UpsertSAMLConnectionReq) (*aggregate.SAMLConnectionResp, error)
+	DeleteConnection(ctx context.Context, projectID string) error
+	// Metadata returns the project's SP metadata XML for the IdP administrator.
+	Metadata(ctx context.Context, projectID string) ([]byte, error)
+	// StartLogin returns the IdP URL that begins SP-initiated SSO for the project.
+	StartLogin(ctx context.Context, projectID string, req aggregate.StartSAMLLoginReq) (string, error)
+	// ConsumeResponse validates an IdP response posted to the ACS and returns the frontend
+	// redirect URL carrying a refreshState, exactly like the OAuth callbacks.
+	ConsumeResponse(ctx context.Context, projectID, samlResponse, relayState string) (string, error)
+}
+
+// samlRequest is cached under the RelayState while the user is at the IdP. RelayState is limited
+// to 80 bytes by the SAML bindings, so it carries only a random key instead of a sealed state.
+type samlRequest struct {
+	ProjectID   string `json:"projectId"`
+	RequestID   string `json:"requestId"`
+	RedirectURL string `json:"redirectUrl"`
+	// CodeChallenge is the client's PKCE challenge, passed on to the refresh state.
+	CodeChallenge string `json:"codeChallenge,omitempty"`
+	ClientBinding string `json:"clientBinding,omitempty"`
+}
+
+type SAMLSvc struct {
+	logger      logger.ILogger
+	cache       cache.ICache
+	stateSealer statetoken.ISealer
+	connRepo    repository.ISAMLConnectionRepository
+	projectRepo repository.IProjectRepository
+	spConfig    saml.SPConfig
+	cfg         *config.AppConfig
+}
+
+func NewSAMLSvc(
+	logger logger.ILogger,
+	cfg *config.AppConfig,
+	cache cache.ICache,
+	stateSealer statetoken.ISealer,
+	connRepo repository.ISAMLConnectionRepository,
+	projectRepo repository.IProjectRepository,
+) (ISAMLSvc, error) {
+	spConfig := saml.SPConfig{BaseURL: cfg.SAML.BaseURL}
+	if cfg.SAML.CertFile != "" || cfg.SAML.KeyFile != "" {
+		certPEM, err := os.ReadFile(cfg.SAML.CertFile)
+		if err != nil {
+			return nil, fmt.Errorf("read saml sp certificate: %w", err)
+		}
+		block, _ := pem.Decode(certPEM)
+		if block == nil {
+			return nil, errors.New("saml sp certificate is not PEM")
+		}
+		if spConfig.Certificate, err = x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, fmt.Errorf("parse saml sp certificate: %w", err)
+		}
+		keyPEM, err := os.ReadFile(cfg.SAML.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read saml sp key: %w", err)
+		}
+		if spConfig.Key, err = gojwt.ParseRSAPrivateKeyFromPEM(keyPEM); err != nil {
+			return nil, fmt.Errorf("parse saml sp key: %w", err)
+		}
+	}
+	return &SAMLSvc{
+		logger:      logger,
+		cache:       cache,
+		stateSealer: stateSealer,
+		connRepo:    connRepo,
+		projectRepo: projectRepo,
+		spConfig:    spConfig,
+		cfg:         cfg,
+	}, nil
+}
+
+func (s *SAMLSvc) GetConnection(ctx context.Context, projectID string) (*aggregate.SAMLConnectionResp, error) {
+	conn := s.connRepo.FindByProjectID(ctx, projectID)
+	if conn == nil {
+		return nil, errorx.Wrap(errorx.ErrSAMLNotFound, nil)
+	}
+	return s.toResp(conn), nil
+}
+
+func (s *SAMLSvc) UpsertConnection(ctx context.Context, projectID string, req aggregate.UpsertSAMLConnectionReq) (*aggregate.SAMLConnectionResp, error) {
+	if s.projectRepo.FindOneById(ctx, projectID) == nil {
+		return nil, errorx.Wrap(errorx.ErrProjectNotFound, nil)
+	}
+	idp, err := saml.ParseIdPMetadata([]byte(req.IdPMetadataXML))
+	if err != nil {
+		return nil, errorx.New(errorx.ErrBadRequest, err.Error())
+	}
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+	domains := make([]string, len(req.AllowedDomains))
+	for i, d := range req.AllowedDomains {
+		domains[i] = strings.ToLower(d)
+	}
+
+	var actorID string
+	if p := payloadFromContext(ctx); p != nil {
+		actorID = p.UserID
+	}
+
+	conn := s.connRepo.FindByProjectID(ctx, projectID)
+	if conn == nil {
+		conn = &model.SAMLConnection{ProjectID: projectID}
+		conn.CreatedBy = actorID
+	}
+	conn.IdPMetadataXML = req.IdPMetadataXML
+	conn.IdPEntityID = idp.EntityID
+	conn.EmailAttribute = req.EmailAttribute
+	conn.NameAttribute = req.NameAttribute
+	conn.GroupsAttribute = req.GroupsAttribute
+	conn.SetDomains(domains)
+	conn.IsActive = isActive
+	conn.UpdatedBy = actorID
+
+	if conn.ID == "" {
+		created, err := s.connRepo.Create(ctx, conn)
+		if err != nil {
+			logger.WithContext(ctx, s.logger).Error("[SAMLSvc] failed to create connection", "projectID", projectID, "error", err)
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+		conn = created
+	} else if err := s.connRepo.Update(ctx, conn.ID, *conn,
+		"idp_metadata_xml", "idp_entity_id", "email_attribute", "name_attribute", "groups_attribute", "allowed_domains", "is_active", "updated_by",
+	); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[SAMLSvc] failed to update connection", "projectID", projectID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return s.toResp(conn), nil
+}
+
+func (s *SAMLSvc) DeleteConnection(ctx context.Context, projectID string) error {
+	conn := s.connRepo.FindByProjectID(ctx, projectID)
+	if conn == nil {
+		return errorx.Wrap(errorx.ErrSAMLNotFound, nil)
+	}
+	if err := s.connRepo.DeleteById(ctx, conn.ID); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[SAMLSvc] failed to delete connection", "projectID", projectID, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return nil
+}
+
+func (s *SAMLSvc) Metadata(ctx context.Context, projectID string) ([]byte, error) {
+	sp, _, err := s.serviceProvider(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	out, err := xml.MarshalIndent(sp.Metadata(), "", "  ")
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return out, nil
+}
+
+func (s *SAMLSvc) StartLogin(ctx context.Context, projectID string, req aggregate.StartSAMLLoginReq) (string, error) {
+	if req.RedirectURL == "" {
+		return "", errorx.New(errorx.ErrBadRequest, "redirectUrl is required for SAML login")
+	}
+	if err := checkRedirectURL(ctx, s.logger, s.projectRepo, s.cfg.OAuth.RedirectURLs, projectID, req.RedirectURL); err != nil {
+		return "", err
+	}
+	if err := validateCodeChallenge(req.CodeChallenge, req.CodeChallengeMethod); err != nil {
+		return "", err
+	}
+	sp, _, err := s.serviceProvider(ctx, projectID)
+	if err != nil {
+		return "", err
+	}
+	ssoURL := sp.GetSSOBindingLocation(gosaml.HTTPRedirectBinding)
+	if ssoURL == "" {
+		return "", errorx.New(errorx.ErrBadRequest, "IdP metadata has no HTTP-Redirect SingleSignOnService")
+	}
+	authnReq, err := sp.MakeAuthenticationRequest(ssoURL, gosaml.HTTPRedirectBinding, gosaml.HTTPPostBinding)
+	if err != nil {
+		return "", errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	relayState, err := helper.GenerateRefreshToken()
+	if err != nil {
+		return "", errorx.Wrap(errorx.ErrInternal, err)
+	}
+	ttl := constant.SAMLRequestTTL
+	if err := s.cache.Set(constant.CacheKeyPrefixSAMLRequest+relayState, samlRequest{
+		ProjectID:     projectID,
+		RequestID:     authnReq.ID,
+		RedirectURL:   req.RedirectURL,
+		CodeChallenge: req.CodeChallenge,
+		ClientBinding: clientBinding(ctx, s.stateSealer, s.cfg.OAuth.StateBinding),
+	}, &ttl); err != nil {
+		return "", errorx.Wrap(errorx.ErrInternal, err)
+	}
+	authURL, err := authnReq.Redirect(relayState, sp)
+	if err != nil {
+		return "", errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return authURL.String(), nil
+}
+
+func (s *SAMLSvc) ConsumeResponse(ctx context.Context, projectID, samlResponse, relayState string) (string, error) {
+	if samlResponse == "" || relayState == "" {
+		return "", errorx.New(errorx.ErrBadRequest, "SAMLResponse and RelayState are required")
+	}
+	key := constant.CacheKeyPrefixSAMLRequest + relayState
+	var pending samlRequest
+	if err := s.cache.Get(key, &pending); err != nil {
+		return "", errorx.New(errorx.ErrInvalidRefreshState, "SAML request expired or already used")
+	}
+	if err := s.cache.Delete(key); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[SAMLSvc] failed to consume SAML request", "projectID", projectID, "error", err)
+	}
+	if pending.ProjectID != projectID {
+		return "", errorx.New(errorx.ErrInvalidRefreshState, "SAML request was not issued for this project")
+	}
+	// Checked again in case the project's redirect URLs changed while the user was at the IdP.
+	if err := checkRedirectURL(ctx, s.logger, s.projectRepo, s.cfg.OAuth.RedirectURLs, projectID, pending.RedirectURL); err != nil {
+		return "", err
+	}
+
+	sp, conn, err := s.serviceProvider(ctx, projectID)
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return "", errorx.New(errorx.ErrBadRequest, "SAMLResponse is not base64")
+	}
+	assertion, err := sp.ParseXMLResponse(raw, []string{pending.RequestID}, sp.AcsURL)
+	if err != nil {
+		reason := err
+		var invalid *gosaml.InvalidResponseError
+		if errors.As(err, &invalid) && invalid.PrivateErr != nil {
+			reason = invalid.PrivateErr
+		}
+		logger.WithContext(ctx, s.logger).Warn("[SAMLSvc] rejected SAML response", "projectID", projectID, "error", reason)
+		return "", errorx.New(errorx.ErrUnauthorized, "invalid SAML response")
+	}
+
+	identity, err := saml.IdentityFromAssertion(assertion, saml.Mapping{
+		EmailAttribute:  conn.EmailAttribute,
+		NameAttribute:   conn.NameAttribute,
+		GroupsAttribute: conn.GroupsAttribute,
+	})
+	if err != nil {
+		return "", errorx.New(errorx.ErrUnauthorized, err.Error())
+	}
+	// Accounts are linked by email, so a tenant's IdP may only sign in addresses from its own domains.
+	if !saml.EmailDomainAllowed(identity.Email, conn.Domains()) {
+		logger.WithContext(ctx, s.logger).Warn("[SAMLSvc] asserted email outside allowed domains", "projectID", projectID, "email", identity.Email)
+		return "", errorx.New(errorx.ErrForbidden, "email domain is not allowed for this SAML connection")
+	}
+
+	return sealRefreshStateRedirect(s.stateSealer, pending.RedirectURL, aggregate.OAuthRefreshState{
+		AuthType: constant.UserAuthTypeSAML,
+		Provider: samlMappingProvider(projectID),
+		UserData: aggregate.OAuthUserData{
+			Email:      identity.Email,
+			Name:       identity.Name,
+			ProviderID: identity.NameID,
+			Groups:     identity.Groups,
+		},
+		ProjectID:     projectID,
+		CodeChallenge: pending.CodeChallenge,
+		ClientBinding: pending.ClientBinding,
+	})
+}
+
+// serviceProvider builds the SP for an active connection.
+func (s *SAMLSvc) serviceProvider(ctx context.Context, projectID string) (*gosaml.ServiceProvider, *model.SAMLConnection, error) {
+	conn := s.connRepo.FindByProjectID(ctx, projectID)
+	if conn == nil || !conn.IsActive {
+		return nil, nil, errorx.Wrap(errorx.ErrSAMLNotFound, nil)
+	}
+	idp, err := saml.ParseIdPMetadata([]byte(conn.IdPMetadataXML))
+	if err != nil {
+		return nil, nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	sp, err := saml.NewServiceProvider(s.spConfig, projectID, idp)
+	if err != nil {
+		return nil, nil, errorx.New(errorx.ErrBadRequest, err.Error())
+	}
+	return sp, conn, nil
+}
+
+func (s *SAMLSvc) toResp(conn *model.SAMLConnection) *aggregate.SAMLConnectionResp {
+	var resp aggregate.SAMLConnectionResp
+	resp.FromModel(conn)
+	if s.spConfig.BaseURL != "" {
+		resp.SPEntityID = saml.MetadataURL(s.spConfig.BaseURL, conn.ProjectID)
+		resp.ACSURL = saml.ACSURL(s.spConfig.BaseURL, conn.ProjectID)
+	}
+	return &resp
+}
+
+// samlMappingProvider is the role-mapping provider key for a project's IdP. Group names are
+// chosen by each tenant, so mappings are scoped per connection rather than shared across SAML.
+func samlMappingProvider(projectID string) string {
+	return "saml:" + projectID
+}
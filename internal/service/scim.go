@@ -0,0 +1,867 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/internal/shared/scim"
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
+)
+
+const (
+	// scimMaxResults caps a SCIM list page; it is also the page size when count is not sent.
+	scimMaxResults = 100
+	// scimRoleCodePrefix starts the code of every role created for a SCIM group.
+	scimRoleCodePrefix = "scim-"
+)
+
+// ISCIMSvc implements SCIM 2.0 provisioning for one project at a time. Users it creates belong to the
+// project that provisioned them; groups are the project's roles and group members the users holding them.
+// Protocol failures are *scim.Error values.
+type ISCIMSvc interface {
+	ListUsers(ctx context.Context, projectID string, req aggregate.SCIMListReq) (*aggregate.SCIMListResp[aggregate.SCIMUser], error)
+	GetUser(ctx context.Context, projectID, userID string) (*aggregate.SCIMUser, error)
+	CreateUser(ctx context.Context, projectID string, req aggregate.SCIMUser) (*aggregate.SCIMUser, error)
+	ReplaceUser(ctx context.Context, projectID, userID string, req aggregate.SCIMUser) (*aggregate.SCIMUser, error)
+	PatchUser(ctx context.Context, projectID, userID string, req aggregate.SCIMPatchReq) (*aggregate.SCIMUser, error)
+	// DeleteUser removes the user's roles in the project, ends its sessions and deletes the account.
+	DeleteUser(ctx context.Context, projectID, userID string) error
+
+	ListGroups(ctx context.Context, projectID string, req aggregate.SCIMListReq) (*aggregate.SCIMListResp[aggregate.SCIMGroup], error)
+	GetGroup(ctx context.Context, projectID, groupID string) (*aggregate.SCIMGroup, error)
+	CreateGroup(ctx context.Context, projectID string, req aggregate.SCIMGroup) (*aggregate.SCIMGroup, error)
+	ReplaceGroup(ctx context.Context, projectID, groupID string, req aggregate.SCIMGroup) (*aggregate.SCIMGroup, error)
+	PatchGroup(ctx context.Context, projectID, groupID string, req aggregate.SCIMPatchReq) (*aggregate.SCIMGroup, error)
+	DeleteGroup(ctx context.Context, projectID, groupID string) error
+}
+
+type SCIMSvc struct {
+	logger       logger.ILogger
+	userRepo     repository.IUserRepository
+	scimUserRepo repository.ISCIMUserRepository
+	roleRepo     repository.IRoleRepository
+	userRoleRepo repository.IUserRoleRepository
+	memberRepo   repository.IProjectMemberRepository
+	txManager    repository.ITxManager
+	roleSvc      IRoleSvc
+	authSvc      IAuthSvc
+	events       eventbus.IPublisher
+}
+
+func NewSCIMSvc(
+	logger logger.ILogger,
+	userRepo repository.IUserRepository,
+	scimUserRepo repository.ISCIMUserRepository,
+	roleRepo repository.IRoleRepository,
+	userRoleRepo repository.IUserRoleRepository,
+	memberRepo repository.IProjectMemberRepository,
+	txManager repository.ITxManager,
+	roleSvc IRoleSvc,
+	authSvc IAuthSvc,
+	events eventbus.IPublisher,
+) ISCIMSvc {
+	return &SCIMSvc{
+		logger:       logger,
+		userRepo:     userRepo,
+		scimUserRepo: scimUserRepo,
+		roleRepo:     roleRepo,
+		userRoleRepo: userRoleRepo,
+		memberRepo:   memberRepo,
+		txManager:    txManager,
+		roleSvc:      roleSvc,
+		authSvc:      authSvc,
+		events:       events,
+	}
+}
+
+// scimUserChanges are the stored user attributes a PUT or PATCH sets; nil fields are left alone.
+type scimUserChanges struct {
+	userName   *string
+	email      *string
+	externalID *string
+	active     *bool
+}
+
+// ListUsers lists the project's users, or looks one up by userName, emails.value, externalId or id.
+func (s *SCIMSvc) ListUsers(ctx context.Context, projectID string, req aggregate.SCIMListReq) (*aggregate.SCIMListResp[aggregate.SCIMUser], error) {
+	ctx, span := tracing.Start(ctx, "SCIMSvc.ListUsers")
+	defer span.End()
+	filter, err := scim.ParseFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	startIndex, count := scimPage(req)
+
+	var links []model.SCIMUser
+	var total int64
+	if filter == (scim.Filter{}) {
+		links, total, err = s.scimUserRepo.FindByProjectID(ctx, projectID, count, startIndex-1)
+		if err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+	} else {
+		link, err := s.findUserByFilter(ctx, projectID, filter)
+		if err != nil {
+			return nil, err
+		}
+		if link != nil {
+			links, total = []model.SCIMUser{*link}, 1
+		}
+	}
+
+	resources := make([]aggregate.SCIMUser, 0, len(links))
+	for i := range links {
+		resources = append(resources, *aggregate.SCIMUserFromModel(&links[i]))
+	}
+	return scimList(resources, total, startIndex), nil
+}
+
+func (s *SCIMSvc) GetUser(ctx context.Context, projectID, userID string) (*aggregate.SCIMUser, error) {
+	link := s.findUserLink(ctx, projectID, userID)
+	if link == nil {
+		return nil, scim.NotFound("user " + userID + " not found")
+	}
+	return aggregate.SCIMUserFromModel(link), nil
+}
+
+// CreateUser provisions a new account with an unusable random password; the user signs in through the
+// identity provider. An existing account with the same userName or email is not taken over.
+func (s *SCIMSvc) CreateUser(ctx context.Context, projectID string, req aggregate.SCIMUser) (*aggregate.SCIMUser, error) {
+	ctx, span := tracing.Start(ctx, "SCIMSvc.CreateUser")
+	defer span.End()
+	email := req.Email()
+	if email == "" {
+		return nil, scim.BadRequest(scim.ErrTypeInvalidValue, "an email address is required in emails or userName")
+	}
+	if err := s.checkUserAvailable(ctx, "", req.UserName, email); err != nil {
+		return nil, err
+	}
+
+	randomPass, err := helper.GenerateRefreshToken()
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	hashed, err := helper.HashPassword(randomPass)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	status := constant.UserStatusActive
+	if req.Active != nil && !*req.Active {
+		status = constant.UserStatusInactive
+	}
+	// The account, its SCIM link and its membership are created together: an account left without its link
+	// would be invisible to the identity provider yet block the userName from being provisioned again.
+	var user *model.User
+	var link *model.SCIMUser
+	err = withTx(ctx, s.txManager, func(ctx context.Context) error {
+		var err error
+		user, err = s.userRepo.Create(ctx, &model.User{
+			Username: req.UserName,
+			Email:    email,
+			Password: hashed,
+			Status:   status,
+		})
+		if err != nil {
+			return errorx.Wrap(errorx.ErrCreateUser, err)
+		}
+		link, err = s.scimUserRepo.Create(ctx, &model.SCIMUser{
+			ProjectID:  projectID,
+			UserID:     user.ID,
+			ExternalID: req.ExternalID,
+		})
+		if err != nil {
+			return errorx.Wrap(errorx.ErrCreateUser, err)
+		}
+		// The identity provider decides who belongs to the project, so provisioned users join without an invitation
+		now := time.Now()
+		if _, err := s.memberRepo.Create(ctx, &model.ProjectMember{
+			ProjectID:  projectID,
+			UserID:     user.ID,
+			Status:     constant.ProjectMemberActive,
+			AcceptedAt: &now,
+		}); err != nil {
+			return errorx.Wrap(errorx.ErrCreateUser, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	link.User = *user
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("SCIM user provisioned: %s (project: %s)", user.ID, projectID))
+	s.publishUser(ctx, constant.EventUserCreated, user)
+	return aggregate.SCIMUserFromModel(link), nil
+}
+
+// ReplaceUser applies a full User resource; an omitted active attribute means active.
+func (s *SCIMSvc) ReplaceUser(ctx context.Context, projectID, userID string, req aggregate.SCIMUser) (*aggregate.SCIMUser, error) {
+	ctx, span := tracing.Start(ctx, "SCIMSvc.ReplaceUser")
+	defer span.End()
+	link := s.findUserLink(ctx, projectID, userID)
+	if link == nil {
+		return nil, scim.NotFound("user " + userID + " not found")
+	}
+	active := req.Active == nil || *req.Active
+	changes := scimUserChanges{userName: &req.UserName, externalID: &req.ExternalID, active: &active}
+	if email := req.Email(); email != "" {
+		changes.email = &email
+	}
+	return s.applyUserChanges(ctx, link, changes)
+}
+
+// PatchUser applies add and replace operations on userName, emails, externalId and active. Operations on
+// attributes dreon-auth does not store are ignored.
+func (s *SCIMSvc) PatchUser(ctx context.Context, projectID, userID string, req aggregate.SCIMPatchReq) (*aggregate.SCIMUser, error) {
+	ctx, span := tracing.Start(ctx, "SCIMSvc.PatchUser")
+	defer span.End()
+	link := s.findUserLink(ctx, projectID, userID)
+	if link == nil {
+		return nil, scim.NotFound("user " + userID + " not found")
+	}
+	var changes scimUserChanges
+	for _, op := range req.Operations {
+		if err := changes.applyPatchOp(op); err != nil {
+			return nil, err
+		}
+	}
+	return s.applyUserChanges(ctx, link, changes)
+}
+
+func (s *SCIMSvc) DeleteUser(ctx context.Context, projectID, userID string) error {
+	ctx, span := tracing.Start(ctx, "SCIMSvc.DeleteUser")
+	defer span.End()
+	link := s.findUserLink(ctx, projectID, userID)
+	if link == nil {
+		return scim.NotFound("user " + userID + " not found")
+	}
+
+	userRoles, err := s.userRoleRepo.FindByUserIDAndProjectID(ctx, userID, &projectID)
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	for _, ur := range userRoles {
+		if err := s.roleSvc.RemoveRoleFromUser(ctx, aggregate.RemoveRoleFromUserReq{UserID: userID, RoleID: ur.RoleID, ProjectID: &projectID}); err != nil {
+			return err
+		}
+	}
+	if err := s.authSvc.EndUserSessions(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.scimUserRepo.DeleteById(ctx, link.ID); err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if member := s.memberRepo.FindByProjectAndUser(ctx, projectID, userID); member != nil {
+		if err := s.memberRepo.DeleteById(ctx, member.ID); err != nil {
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+	}
+	if err := s.userRepo.DeleteById(ctx, userID); err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	logger.WithContext(ctx, s.logger).Info(fmt.Sprintf("SCIM user deprovisioned: %s (project: %s)", userID, projectID))
+	publishEvent(ctx, s.events, s.logger, constant.EventUserDeleted, userID, nil)
+	return nil
+}
+
+// ListGroups lists the project's roles as groups, or looks them up by displayName.
+func (s *SCIMSvc) ListGroups(ctx context.Context, projectID string, req aggregate.SCIMListReq) (*aggregate.SCIMListResp[aggregate.SCIMGroup], error) {
+	ctx, span := tracing.Start(ctx, "SCIMSvc.ListGroups")
+	defer span.End()
+	filter, err := scim.ParseFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if filter != (scim.Filter{}) && !filter.Is("displayName") {
+		return nil, scim.BadRequest(scim.ErrTypeInvalidFilter, "unsupported filter attribute: "+filter.Attribute)
+	}
+	startIndex, count := scimPage(req)
+
+	var roles []model.Role
+	var total int64
+	if filter == (scim.Filter{}) {
+		roles, total, err = s.roleRepo.FindByProjectID(ctx, &projectID, count, startIndex-1)
+		if err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+	} else {
+		roles, err = s.findRolesByName(ctx, projectID, filter.Value)
+		if err != nil {
+			return nil, err
+		}
+		total = int64(len(roles))
+	}
+
+	withMembers := !strings.Contains(strings.ToLower(req.ExcludedAttributes), "members")
+	resources := make([]aggregate.SCIMGroup, 0, len(roles))
+	for i := range roles {
+		var members []model.UserRole
+		if withMembers {
+			if members, err = s.groupMembers(ctx, projectID, roles[i].ID); err != nil {
+				return nil, err
+			}
+		}
+		group := aggregate.SCIMGroupFromModel(&roles[i], members)
+		if !withMembers {
+			group.Members = nil
+		}
+		resources = append(resources, *group)
+	}
+	return scimList(resources, total, startIndex), nil
+}
+
+func (s *SCIMSvc) GetGroup(ctx context.Context, projectID, groupID string) (*aggregate.SCIMGroup, error) {
+	role := s.findGroupRole(ctx, projectID, groupID)
+	if role == nil {
+		return nil, scim.NotFound("group " + groupID + " not found")
+	}
+	return s.groupResource(ctx, projectID, role)
+}
+
+// CreateGroup creates a project role without permissions for the group and assigns it to its members.
+// Permissions are granted to the role through the roles API.
+func (s *SCIMSvc) CreateGroup(ctx context.Context, projectID string, req aggregate.SCIMGroup) (*aggregate.SCIMGroup, error) {
+	ctx, span := tracing.Start(ctx, "SCIMSvc.CreateGroup")
+	defer span.End()
+	if err := s.checkGroupNameAvailable(ctx, projectID, "", req.DisplayName); err != nil {
+		return nil, err
+	}
+	memberIDs, err := s.memberIDs(ctx, projectID, req.Members)
+	if err != nil {
+		return nil, err
+	}
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	created, err := s.roleSvc.CreateRole(ctx, aggregate.CreateRoleReq{
+		Code:        scimRoleCodePrefix + hex.EncodeToString(suffix),
+		Name:        req.DisplayName,
+		Description: "Provisioned by SCIM",
+		ProjectID:   &projectID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setGroupMembers(ctx, projectID, created.ID, memberIDs); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(ctx, projectID, created.ID)
+}
+
+// ReplaceGroup renames the group and makes members its exact membership.
+func (s *SCIMSvc) ReplaceGroup(ctx context.Context, projectID, groupID string, req aggregate.SCIMGroup) (*aggregate.SCIMGroup, error) {
+	ctx, span := tracing.Start(ctx, "SCIMSvc.ReplaceGroup")
+	defer span.End()
+	role := s.findGroupRole(ctx, projectID, groupID)
+	if role == nil {
+		return nil, scim.NotFound("group " + groupID + " not found")
+	}
+	memberIDs, err := s.memberIDs(ctx, projectID, req.Members)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.renameGroup(ctx, projectID, role, req.DisplayName); err != nil {
+		return nil, err
+	}
+	if err := s.setGroupMembers(ctx, projectID, role.ID, memberIDs); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(ctx, projectID, role.ID)
+}
+
+// PatchGroup applies add, remove and replace operations on members and displayName. All member IDs are
+// checked before anything changes.
+func (s *SCIMSvc) PatchGroup(ctx context.Context, projectID, groupID string, req aggregate.SCIMPatchReq) (*aggregate.SCIMGroup, error) {
+	ctx, span := tracing.Start(ctx, "SCIMSvc.PatchGroup")
+	defer span.End()
+	role := s.findGroupRole(ctx, projectID, groupID)
+	if role == nil {
+		return nil, scim.NotFound("group " + groupID + " not found")
+	}
+
+	current, err := s.groupMembers(ctx, projectID, role.ID)
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[string]bool, len(current))
+	for _, m := range current {
+		members[m.UserID] = true
+	}
+	var displayName *string
+	for _, op := range req.Operations {
+		if err := s.applyGroupPatchOp(ctx, projectID, op, members, &displayName); err != nil {
+			return nil, err
+		}
+	}
+
+	if displayName != nil {
+		if err := s.renameGroup(ctx, projectID, role, *displayName); err != nil {
+			return nil, err
+		}
+	}
+	memberIDs := make([]string, 0, len(members))
+	for id := range members {
+		memberIDs = append(memberIDs, id)
+	}
+	if err := s.setGroupMembers(ctx, projectID, role.ID, memberIDs); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(ctx, projectID, role.ID)
+}
+
+// DeleteGroup removes the role from its members and deletes it.
+func (s *SCIMSvc) DeleteGroup(ctx context.Context, projectID, groupID string) error {
+	ctx, span := tracing.Start(ctx, "SCIMSvc.DeleteGroup")
+	defer span.End()
+	role := s.findGroupRole(ctx, projectID, groupID)
+	if role == nil {
+		return scim.NotFound("group " + groupID + " not found")
+	}
+	if err := s.setGroupMembers(ctx, projectID, role.ID, nil); err != nil {
+		return err
+	}
+	return s.roleSvc.DeleteRole(ctx, role.ID)
+}
+
+// findUserLink returns the SCIM link of a user provisioned by projectID, or nil.
+func (s *SCIMSvc) findUserLink(ctx context.Context, projectID, userID string) *model.SCIMUser {
+	link := s.scimUserRepo.FindByUserID(ctx, userID)
+	if link == nil || link.ProjectID != projectID {
+		return nil
+	}
+	return link
+}
+
+func (s *SCIMSvc) findUserByFilter(ctx context.Context, projectID string, filter scim.Filter) (*model.SCIMUser, error) {
+	var user *model.User
+	var err error
+	switch {
+	case filter.Is("id"):
+		return s.findUserLink(ctx, projectID, filter.Value), nil
+	case filter.Is("externalId"):
+		return s.scimUserRepo.FindByExternalID(ctx, projectID, filter.Value), nil
+	case filter.Is("userName"):
+		user, err = s.userRepo.FindByUsername(ctx, filter.Value)
+	case filter.Is("emails.value"), filter.Is("emails"):
+		user, err = s.userRepo.FindByEmail(ctx, filter.Value)
+	default:
+		return nil, scim.BadRequest(scim.ErrTypeInvalidFilter, "unsupported filter attribute: "+filter.Attribute)
+	}
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+	return s.findUserLink(ctx, projectID, user.ID), nil
+}
+
+// checkUserAvailable rejects a userName or email already used by an account other than userID.
+func (s *SCIMSvc) checkUserAvailable(ctx context.Context, userID, userName, email string) error {
+	if userName != "" {
+		existing, err := s.userRepo.FindByUsername(ctx, userName)
+		if err != nil {
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+		if existing != nil && existing.ID != userID {
+			return scim.NewError(http.StatusConflict, scim.ErrTypeUniqueness, "userName is already in use")
+		}
+	}
+	if email != "" {
+		existing, err := s.userRepo.FindByEmail(ctx, email)
+		if err != nil {
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+		if existing != nil && existing.ID != userID {
+			return scim.NewError(http.StatusConflict, scim.ErrTypeUniqueness, "email is already in use")
+		}
+	}
+	return nil
+}
+
+// applyUserChanges stores changes on the linked user. Deactivating the user ends all of its sessions.
+func (s *SCIMSvc) applyUserChanges(ctx context.Context, link *model.SCIMUser, changes scimUserChanges) (*aggregate.SCIMUser, error) {
+	user := link.User
+	var fields []string
+	var userName, email string
+	if changes.userName != nil && *changes.userName != "" && *changes.userName != user.Username {
+		userName = *changes.userName
+		user.Username = userName
+		fields = append(fields, "username")
+	}
+	if changes.email != nil && *changes.email != "" && *changes.email != user.Email {
+		email = *changes.email
+		user.Email = email
+		fields = append(fields, "email")
+	}
+	if err := s.checkUserAvailable(ctx, user.ID, userName, email); err != nil {
+		return nil, err
+	}
+	deactivated := false
+	if changes.active != nil {
+		status := constant.UserStatusActive
+		if !*changes.active {
+			status = constant.UserStatusInactive
+		}
+		if status != user.Status {
+			deactivated = !*changes.active
+			user.Status = status
+			fields = append(fields, "status")
+		}
+	}
+
+	if len(fields) > 0 {
+		if err := s.userRepo.Update(ctx, user.ID, user, append(fields, "updated_at")...); err != nil {
+			return nil, errorx.Wrap(errorx.ErrUpdateUser, err)
+		}
+	}
+	if changes.externalID != nil && *changes.externalID != link.ExternalID {
+		link.ExternalID = *changes.externalID
+		if err := s.scimUserRepo.Update(ctx, link.ID, *link, "external_id"); err != nil {
+			return nil, errorx.Wrap(errorx.ErrUpdateUser, err)
+		}
+	}
+	if deactivated {
+		if err := s.authSvc.EndUserSessions(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	updated := s.findUserLink(ctx, link.ProjectID, user.ID)
+	if updated == nil {
+		return nil, scim.NotFound("user " + user.ID + " not found")
+	}
+	if len(fields) > 0 {
+		s.publishUser(ctx, constant.EventUserUpdated, &updated.User)
+	}
+	return aggregate.SCIMUserFromModel(updated), nil
+}
+
+// applyPatchOp records the attributes one PATCH operation sets. Without a path the value is an object
+// of attributes, as Okta sends.
+func (c *scimUserChanges) applyPatchOp(op aggregate.SCIMPatchOp) error {
+	switch strings.ToLower(op.Op) {
+	case "add", "replace":
+	case "remove":
+		attr, _, err := scim.ParsePath(op.Path)
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(attr) {
+		case "username", "emails", "emails.value", "active":
+			return scim.BadRequest(scim.ErrTypeMutability, attr+" cannot be removed")
+		case "externalid":
+			empty := ""
+			c.externalID = &empty
+		}
+		return nil
+	default:
+		return scim.BadRequest(scim.ErrTypeInvalidSyntax, "unsupported patch op: "+op.Op)
+	}
+
+	if op.Path == "" {
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return scim.BadRequest(scim.ErrTypeInvalidValue, "patch value must be an object when path is omitted")
+		}
+		for attr, value := range attrs {
+			if err := c.set(attr, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	attr, _, err := scim.ParsePath(op.Path)
+	if err != nil {
+		return err
+	}
+	return c.set(attr, op.Value)
+}
+
+func (c *scimUserChanges) set(attr string, value json.RawMessage) error {
+	invalid := scim.BadRequest(scim.ErrTypeInvalidValue, "invalid value for "+attr)
+	switch strings.ToLower(attr) {
+	case "username":
+		var v string
+		if json.Unmarshal(value, &v) != nil || v == "" {
+			return invalid
+		}
+		c.userName = &v
+	case "externalid":
+		var v string
+		if json.Unmarshal(value, &v) != nil {
+			return invalid
+		}
+		c.externalID = &v
+	case "emails.value":
+		var v string
+		if json.Unmarshal(value, &v) != nil || v == "" {
+			return invalid
+		}
+		c.email = &v
+	case "emails":
+		var emails []aggregate.SCIMEmail
+		if json.Unmarshal(value, &emails) != nil {
+			return invalid
+		}
+		if email := (&aggregate.SCIMUser{Emails: emails}).Email(); email != "" {
+			c.email = &email
+		}
+	case "active":
+		// Azure AD sends booleans as the strings "True" and "False".
+		var v bool
+		if err := json.Unmarshal(value, &v); err != nil {
+			var str string
+			if json.Unmarshal(value, &str) != nil {
+				return invalid
+			}
+			if v, err = strconv.ParseBool(str); err != nil {
+				return invalid
+			}
+		}
+		c.active = &v
+	}
+	return nil
+}
+
+func (s *SCIMSvc) publishUser(ctx context.Context, eventType string, user *model.User) {
+	var dto aggregate.UserDto
+	dto.FromModel(user)
+	publishEvent(ctx, s.events, s.logger, eventType, user.ID, dto)
+}
+
+// findGroupRole returns the role backing a group of projectID, or nil.
+func (s *SCIMSvc) findGroupRole(ctx context.Context, projectID, roleID string) *model.Role {
+	role := s.roleRepo.FindOneById(ctx, roleID)
+	if role == nil || role.ProjectID == nil || *role.ProjectID != projectID {
+		return nil
+	}
+	return role
+}
+
+func (s *SCIMSvc) findRolesByName(ctx context.Context, projectID, name string) ([]model.Role, error) {
+	roles, _, err := s.roleRepo.FindByProjectID(ctx, &projectID, -1, 0)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	matches := make([]model.Role, 0, 1)
+	for _, role := range roles {
+		if role.Name == name {
+			matches = append(matches, role)
+		}
+	}
+	return matches, nil
+}
+
+// checkGroupNameAvailable rejects a displayName used by another role of the project, since identity
+// providers match existing groups by name.
+func (s *SCIMSvc) checkGroupNameAvailable(ctx context.Context, projectID, roleID, name string) error {
+	roles, err := s.findRolesByName(ctx, projectID, name)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if role.ID != roleID {
+			return scim.NewError(http.StatusConflict, scim.ErrTypeUniqueness, "displayName is already in use")
+		}
+	}
+	return nil
+}
+
+func (s *SCIMSvc) renameGroup(ctx context.Context, projectID string, role *model.Role, name string) error {
+	if name == "" || name == role.Name {
+		return nil
+	}
+	if err := s.checkGroupNameAvailable(ctx, projectID, role.ID, name); err != nil {
+		return err
+	}
+	role.Name = name
+	if err := s.roleRepo.Update(ctx, role.ID, *role, "name", "updated_at"); err != nil {
+		return errorx.Wrap(errorx.ErrUpdateRole, err)
+	}
+	return nil
+}
+
+// groupMembers returns the role's assignments in projectID.
+func (s *SCIMSvc) groupMembers(ctx context.Context, projectID, roleID string) ([]model.UserRole, error) {
+	userRoles, err := s.userRoleRepo.FindByRoleID(ctx, roleID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	members := make([]model.UserRole, 0, len(userRoles))
+	for _, ur := range userRoles {
+		if ur.ProjectID != nil && *ur.ProjectID == projectID {
+			members = append(members, ur)
+		}
+	}
+	return members, nil
+}
+
+func (s *SCIMSvc) groupResource(ctx context.Context, projectID string, role *model.Role) (*aggregate.SCIMGroup, error) {
+	members, err := s.groupMembers(ctx, projectID, role.ID)
+	if err != nil {
+		return nil, err
+	}
+	return aggregate.SCIMGroupFromModel(role, members), nil
+}
+
+// memberIDs checks that every member is a user provisioned by projectID and returns their IDs.
+func (s *SCIMSvc) memberIDs(ctx context.Context, projectID string, members []aggregate.SCIMGroupMember) ([]string, error) {
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		if s.findUserLink(ctx, projectID, m.Value) == nil {
+			return nil, scim.BadRequest(scim.ErrTypeInvalidValue, "member "+m.Value+" is not a provisioned user")
+		}
+		ids = append(ids, m.Value)
+	}
+	return ids, nil
+}
+
+// setGroupMembers assigns the role to userIDs and removes it from every other member.
+func (s *SCIMSvc) setGroupMembers(ctx context.Context, projectID, roleID string, userIDs []string) error {
+	current, err := s.groupMembers(ctx, projectID, roleID)
+	if err != nil {
+		return err
+	}
+	want := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		want[id] = true
+	}
+	for _, ur := range current {
+		if want[ur.UserID] {
+			delete(want, ur.UserID)
+			continue
+		}
+		if err := s.roleSvc.RemoveRoleFromUser(ctx, aggregate.RemoveRoleFromUserReq{UserID: ur.UserID, RoleID: roleID, ProjectID: &projectID}); err != nil {
+			return err
+		}
+	}
+	for id := range want {
+		if _, err := s.roleSvc.AssignRoleToUser(ctx, aggregate.AssignRoleToUserReq{UserID: id, RoleID: roleID, ProjectID: &projectID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyGroupPatchOp applies one PATCH operation to the pending membership set and display name.
+func (s *SCIMSvc) applyGroupPatchOp(ctx context.Context, projectID string, op aggregate.SCIMPatchOp, members map[string]bool, displayName **string) error {
+	opName := strings.ToLower(op.Op)
+	if opName != "add" && opName != "replace" && opName != "remove" {
+		return scim.BadRequest(scim.ErrTypeInvalidSyntax, "unsupported patch op: "+op.Op)
+	}
+	attr, filter, err := scim.ParsePath(op.Path)
+	if err != nil {
+		return err
+	}
+
+	if attr == "" {
+		// Without a path the value is an object of attributes, e.g. Okta's {"id": ..., "displayName": ...}.
+		if opName == "remove" {
+			return scim.BadRequest(scim.ErrTypeNoTarget, "remove requires a path")
+		}
+		var attrs struct {
+			DisplayName *string                     `json:"displayName"`
+			Members     []aggregate.SCIMGroupMember `json:"members"`
+		}
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return scim.BadRequest(scim.ErrTypeInvalidValue, "patch value must be an object when path is omitted")
+		}
+		if attrs.DisplayName != nil {
+			*displayName = attrs.DisplayName
+		}
+		if attrs.Members == nil {
+			return nil
+		}
+		return s.patchMembers(ctx, projectID, opName, attrs.Members, members)
+	}
+
+	switch strings.ToLower(attr) {
+	case "displayname":
+		var name string
+		if opName == "remove" || json.Unmarshal(op.Value, &name) != nil || name == "" {
+			return scim.BadRequest(scim.ErrTypeInvalidValue, "displayName must be a non-empty string")
+		}
+		*displayName = &name
+		return nil
+	case "members":
+		var values []aggregate.SCIMGroupMember
+		switch {
+		case filter.Is("value"):
+			values = []aggregate.SCIMGroupMember{{Value: filter.Value}}
+		case filter != (scim.Filter{}):
+			return scim.BadRequest(scim.ErrTypeInvalidPath, "members can only be filtered by value")
+		case len(op.Value) > 0:
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				return scim.BadRequest(scim.ErrTypeInvalidValue, "members must be a list of {\"value\": \"<user id>\"}")
+			}
+		case opName == "remove":
+			// Removing "members" without a value empties the group.
+			clear(members)
+			return nil
+		}
+		return s.patchMembers(ctx, projectID, opName, values, members)
+	case "externalid":
+		return nil
+	}
+	return scim.BadRequest(scim.ErrTypeInvalidPath, "unsupported path: "+op.Path)
+}
+
+func (s *SCIMSvc) patchMembers(ctx context.Context, projectID, opName string, values []aggregate.SCIMGroupMember, members map[string]bool) error {
+	if opName == "remove" {
+		for _, m := range values {
+			delete(members, m.Value)
+		}
+		return nil
+	}
+	ids, err := s.memberIDs(ctx, projectID, values)
+	if err != nil {
+		return err
+	}
+	if opName == "replace" {
+		clear(members)
+	}
+	for _, id := range ids {
+		members[id] = true
+	}
+	return nil
+}
+
+// scimPage returns the 1-based start index and page size of a list request.
+func scimPage(req aggregate.SCIMListReq) (int, int) {
+	startIndex, count := req.StartIndex, req.Count
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count <= 0 || count > scimMaxResults {
+		count = scimMaxResults
+	}
+	return startIndex, count
+}
+
+func scimList[T any](resources []T, total int64, startIndex int) *aggregate.SCIMListResp[T] {
+	return &aggregate.SCIMListResp[T]{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
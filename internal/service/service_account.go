@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/internal/shared/oidc"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
+)
+
+const (
+	// serviceAccountClientIDPrefix starts every service account client_id, so it cannot collide with OIDC clients.
+	serviceAccountClientIDPrefix = "sa_"
+	grantClientCredentials       = "client_credentials"
+)
+
+// IServiceAccountSvc manages project service accounts and implements the client_credentials grant for them.
+type IServiceAccountSvc interface {
+	Create(ctx context.Context, projectID string, req aggregate.CreateServiceAccountReq) (*aggregate.CreateServiceAccountResp, error)
+	List(ctx context.Context, projectID string) ([]aggregate.ServiceAccountResp, error)
+	Delete(ctx context.Context, projectID, accountID string) error
+	// IssueToken authenticates the client and returns a short-lived access token carrying the account's
+	// project and the requested permissions. Failures are *oidc.Error values in the OAuth 2.0 format.
+	IssueToken(ctx context.Context, req aggregate.ClientCredentialsTokenReq) (*aggregate.OIDCTokenResp, error)
+}
+
+type ServiceAccountSvc struct {
+	logger          logger.ILogger
+	jwtTokenManager jwt.IJwtTokenManager
+	repo            repository.IServiceAccountRepository
+	projectRepo     repository.IProjectRepository
+	permissionSvc   IPermissionSvc
+}
+
+func NewServiceAccountSvc(
+	logger logger.ILogger,
+	jwtTokenManager jwt.IJwtTokenManager,
+	repo repository.IServiceAccountRepository,
+	projectRepo repository.IProjectRepository,
+	permissionSvc IPermissionSvc,
+) IServiceAccountSvc {
+	return &ServiceAccountSvc{
+		logger:          logger,
+		jwtTokenManager: jwtTokenManager,
+		repo:            repo,
+		projectRepo:     projectRepo,
+		permissionSvc:   permissionSvc,
+	}
+}
+
+func (s *ServiceAccountSvc) Create(ctx context.Context, projectID string, req aggregate.CreateServiceAccountReq) (*aggregate.CreateServiceAccountResp, error) {
+	if s.projectRepo.FindOneById(ctx, projectID) == nil {
+		return nil, errorx.Wrap(errorx.ErrProjectNotFound, nil)
+	}
+	if err := s.permissionSvc.ValidateCodes(ctx, &projectID, req.Permissions); err != nil {
+		return nil, err
+	}
+
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	secret, err := helper.GenerateRefreshToken()
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	account := &model.ServiceAccount{
+		ProjectID:        projectID,
+		Name:             req.Name,
+		ClientID:         serviceAccountClientIDPrefix + hex.EncodeToString(idBytes),
+		ClientSecretHash: helper.HashRefreshToken(secret),
+	}
+	codes := req.Permissions
+	if codes == nil {
+		codes = []string{}
+	}
+	account.SetPermissions(codes)
+	if caller := payloadFromContext(ctx); caller != nil {
+		account.CreatedBy = caller.UserID
+		account.UpdatedBy = caller.UserID
+	}
+
+	created, err := s.repo.Create(ctx, account)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[ServiceAccountSvc] failed to create service account", "projectID", projectID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[ServiceAccountSvc] created service account", "projectID", projectID, "clientID", created.ClientID)
+	resp := &aggregate.CreateServiceAccountResp{ClientSecret: secret}
+	resp.FromModel(created)
+	return resp, nil
+}
+
+func (s *ServiceAccountSvc) List(ctx context.Context, projectID string) ([]aggregate.ServiceAccountResp, error) {
+	accounts, err := s.repo.FindByProjectID(ctx, projectID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	out := make([]aggregate.ServiceAccountResp, len(accounts))
+	for i := range accounts {
+		out[i].FromModel(&accounts[i])
+	}
+	return out, nil
+}
+
+func (s *ServiceAccountSvc) Delete(ctx context.Context, projectID, accountID string) error {
+	account := s.repo.FindOneById(ctx, accountID)
+	if account == nil || account.ProjectID != projectID {
+		return errorx.Wrap(errorx.ErrSvcAccountNotFound, nil)
+	}
+	if err := s.repo.DeleteById(ctx, account.ID); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[ServiceAccountSvc] failed to delete service account", "accountID", accountID, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[ServiceAccountSvc] deleted service account", "projectID", projectID, "clientID", account.ClientID)
+	return nil
+}
+
+func (s *ServiceAccountSvc) IssueToken(ctx context.Context, req aggregate.ClientCredentialsTokenReq) (*aggregate.OIDCTokenResp, error) {
+	ctx, span := tracing.Start(ctx, "ServiceAccountSvc.IssueToken")
+	defer span.End()
+	if req.GrantType == "" {
+		return nil, oidc.NewError(oidc.ErrCodeInvalidRequest, "grant_type is required")
+	}
+	if req.GrantType != grantClientCredentials {
+		return nil, oidc.NewError(oidc.ErrCodeUnsupportedGrantType, "")
+	}
+	invalidClient := oidc.NewError(oidc.ErrCodeInvalidClient, "client authentication failed")
+	if req.ClientID == "" || req.ClientSecret == "" {
+		return nil, invalidClient
+	}
+	account := s.repo.FindByClientID(ctx, req.ClientID)
+	if account == nil {
+		return nil, invalidClient
+	}
+	if subtle.ConstantTimeCompare([]byte(helper.HashRefreshToken(req.ClientSecret)), []byte(account.ClientSecretHash)) != 1 {
+		return nil, invalidClient
+	}
+
+	granted := account.PermissionList()
+	if req.Scope != "" {
+		requested := strings.Fields(req.Scope)
+		for _, code := range requested {
+			if !slices.Contains(granted, code) {
+				return nil, oidc.NewError(oidc.ErrCodeInvalidScope, "scope not granted to this client: "+code)
+			}
+		}
+		granted = requested
+	}
+
+	payload := jwt.Payload{
+		ProjectID:        account.ProjectID,
+		ServiceAccountID: account.ID,
+		Scopes:           granted,
+	}
+	accessToken, err := s.jwtTokenManager.Generate(ctx, payload, constant.ServiceAccountTokenTTL)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[ServiceAccountSvc] failed to sign access token", "clientID", account.ClientID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	now := time.Now()
+	if err := s.repo.Update(ctx, account.ID, model.ServiceAccount{LastTokenAt: &now}, "last_token_at"); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[ServiceAccountSvc] failed to record token issue", "clientID", account.ClientID, "error", err)
+	}
+	return &aggregate.OIDCTokenResp{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(constant.ServiceAccountTokenTTL / time.Second),
+		Scope:       strings.Join(granted, " "),
+	}, nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/background"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/database"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+)
+
+// RegisterBackgroundHooks waits on shutdown, up to SHUTDOWN_TIMEOUT_SEC, for the goroutines the services
+// started with workers: permission cache invalidations, back-channel logout deliveries and user imports.
+// Those pausing in workers.Sleep are woken first. Invoke it before the servers' hooks so it runs once they no longer accept requests.
+func RegisterBackgroundHooks(lc fx.Lifecycle, cfg *config.AppConfig, workers *background.Group, logger logger.ILogger) {
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			timeout := constant.DefaultShutdownTimeout
+			if cfg.Server.ShutdownTimeoutSec > 0 {
+				timeout = time.Duration(cfg.Server.ShutdownTimeoutSec) * time.Second
+			}
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			workers.Stop()
+			if err := workers.Wait(ctx); err != nil {
+				logger.Warn("Shutting down without waiting for background work", "error", err)
+			}
+			return nil
+		},
+	})
+}
+
+// RegisterConnectionHooks closes the Redis client and the database pools on shutdown. Invoke it before any
+// other hook so it runs last, once nothing uses them any more.
+func RegisterConnectionHooks(lc fx.Lifecycle, db *gorm.DB, cache cache.ICache, logger logger.ILogger) {
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			if err := cache.Close(); err != nil {
+				logger.Error("Failed to close cache", "error", err)
+			}
+			if err := database.Close(db); err != nil {
+				logger.Error("Failed to close database", "error", err)
+			}
+			logger.Info("Closed database and cache connections")
+			return nil
+		},
+	})
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/statetoken"
+	"go.uber.org/fx"
+)
+
+const (
+	SigningKeySourceConfig  = "config"
+	SigningKeySourceManaged = "managed"
+
+	signingKeyBits = 2048
+)
+
+// ISigningKeySvc publishes the JWT verification keys and rotates managed signing keys.
+// Keys from config (JWT_PRIVATE_KEY, JWT_VERIFY_PUBLIC_KEYS) are always accepted; keys created by
+// Rotate are stored in the database and picked up by every replica through Reload, which Rotate and Retire
+// trigger on all of them over the cache's invalidation bus.
+type ISigningKeySvc interface {
+	JWKS() jwt.JWKS
+	List(ctx context.Context) ([]aggregate.SigningKeyResp, error)
+	// Rotate creates a key that is published at once and signs tokens after SigningKeyActivationDelay.
+	Rotate(ctx context.Context) (*aggregate.SigningKeyResp, error)
+	// Retire stops accepting tokens signed by a managed key.
+	Retire(ctx context.Context, kid string) error
+	// Reload rebuilds the key set from config and the unretired managed keys.
+	Reload(ctx context.Context) error
+}
+
+type SigningKeySvc struct {
+	logger     logger.ILogger
+	keys       jwt.IKeySet
+	configKeys []jwt.Key
+	sealer     statetoken.ISealer
+	repo       repository.ISigningKeyRepository
+	cache      cache.ICache
+}
+
+// NewSigningKeySvc manages keys on top of keys, whose keys at construction time are the config keys.
+func NewSigningKeySvc(
+	logger logger.ILogger,
+	keys jwt.IKeySet,
+	sealer statetoken.ISealer,
+	repo repository.ISigningKeyRepository,
+	cache cache.ICache,
+) ISigningKeySvc {
+	return &SigningKeySvc{
+		logger:     logger,
+		keys:       keys,
+		configKeys: keys.Keys(),
+		sealer:     sealer,
+		repo:       repo,
+		cache:      cache,
+	}
+}
+
+// RegisterSigningKeyReload loads managed keys on start, then reloads them whenever a replica rotates or
+// retires one, and every SigningKeyReloadInterval in case that announcement was lost. A key rotated on one
+// replica is thus known to all of them before it starts signing, and a retired one is refused everywhere
+// at once.
+func RegisterSigningKeyReload(lc fx.Lifecycle, svc ISigningKeySvc, cache cache.ICache, logger logger.ILogger) {
+	stop := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			if err := svc.Reload(ctx); err != nil {
+				logger.Error("Failed to load signing keys", "error", err)
+			}
+			err := cache.OnBroadcast(constant.CacheChannelSigningKeys, func(string) {
+				if err := svc.Reload(context.Background()); err != nil {
+					logger.Error("Failed to reload signing keys", "error", err)
+				}
+			})
+			if err != nil {
+				logger.Warn("Failed to listen for signing key changes, reloading on the interval only", "error", err)
+			}
+			go func() {
+				ticker := time.NewTicker(constant.SigningKeyReloadInterval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ticker.C:
+						if err := svc.Reload(context.Background()); err != nil {
+							logger.Error("Failed to reload signing keys", "error", err)
+						}
+					case <-stop:
+						return
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			close(stop)
+			return nil
+		},
+	})
+}
+
+func (s *SigningKeySvc) JWKS() jwt.JWKS {
+	return s.keys.JWKS()
+}
+
+func (s *SigningKeySvc) List(ctx context.Context) ([]aggregate.SigningKeyResp, error) {
+	stored, err := s.repo.FindUnretired(ctx)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[SigningKeySvc] failed to list signing keys", "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	byKID := make(map[string]model.SigningKey, len(stored))
+	for _, k := range stored {
+		byKID[k.KID] = k
+	}
+
+	signingKID := s.keys.SigningKey().ID
+	keys := s.keys.Keys()
+	out := make([]aggregate.SigningKeyResp, 0, len(keys))
+	for _, k := range keys {
+		resp := aggregate.SigningKeyResp{KID: k.ID, Source: SigningKeySourceConfig, Signing: k.ID == signingKID}
+		if m, ok := byKID[k.ID]; ok {
+			resp.Source = SigningKeySourceManaged
+			resp.ActivatesAt = &m.ActivatesAt
+			resp.CreatedAt = &m.CreatedAt
+		}
+		out = append(out, resp)
+	}
+	return out, nil
+}
+
+func (s *SigningKeySvc) Rotate(ctx context.Context) (*aggregate.SigningKeyResp, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, signingKeyBits)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	sealed, err := s.sealer.Seal(base64.StdEncoding.EncodeToString(der), 0)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	key := &model.SigningKey{
+		KID:         jwt.KeyID(&privateKey.PublicKey),
+		PublicKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		PrivateKey:  sealed,
+		ActivatesAt: time.Now().Add(constant.SigningKeyActivationDelay),
+	}
+	created, err := s.repo.Create(ctx, key)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[SigningKeySvc] failed to store signing key", "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if err := s.Reload(ctx); err != nil {
+		return nil, err
+	}
+	s.announce(ctx, created.KID)
+	logger.WithContext(ctx, s.logger).Info("[SigningKeySvc] rotated signing key", "kid", created.KID, "activatesAt", created.ActivatesAt)
+	return &aggregate.SigningKeyResp{
+		KID:         created.KID,
+		Source:      SigningKeySourceManaged,
+		ActivatesAt: &created.ActivatesAt,
+		CreatedAt:   &created.CreatedAt,
+	}, nil
+}
+
+func (s *SigningKeySvc) Retire(ctx context.Context, kid string) error {
+	key := s.repo.FindByKID(ctx, kid)
+	if key == nil || key.RetiredAt != nil {
+		for _, k := range s.configKeys {
+			if k.ID == kid {
+				return errorx.New(errorx.ErrBadRequest, "keys from config are retired by removing them from JWT_* settings")
+			}
+		}
+		return errorx.Wrap(errorx.ErrSigningKeyNotFound, nil)
+	}
+	now := time.Now()
+	if err := s.repo.Update(ctx, key.ID, model.SigningKey{RetiredAt: &now}, "retired_at"); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[SigningKeySvc] failed to retire signing key", "kid", kid, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[SigningKeySvc] retired signing key", "kid", kid)
+	if err := s.Reload(ctx); err != nil {
+		return err
+	}
+	s.announce(ctx, kid)
+	return nil
+}
+
+// announce tells the other replicas to reload their keys. If it fails they catch up within
+// SigningKeyReloadInterval.
+func (s *SigningKeySvc) announce(ctx context.Context, kid string) {
+	if err := s.cache.WithContext(ctx).Broadcast(constant.CacheChannelSigningKeys, kid); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[SigningKeySvc] failed to announce signing key change", "kid", kid, "error", err)
+	}
+}
+
+func (s *SigningKeySvc) Reload(ctx context.Context) error {
+	stored, err := s.repo.FindUnretired(ctx)
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	// The newest active managed key signs; until one is active the config key keeps signing.
+	signing := s.configKeys[0]
+	verify := append([]jwt.Key(nil), s.configKeys[1:]...)
+	now := time.Now()
+	var active *jwt.Key
+	for _, m := range stored {
+		key, err := s.openKey(m)
+		if err != nil {
+			logger.WithContext(ctx, s.logger).Error("[SigningKeySvc] skipping unreadable signing key", "kid", m.KID, "error", err)
+			continue
+		}
+		if !m.ActivatesAt.After(now) {
+			if active != nil {
+				verify = append(verify, *active)
+			}
+			active = &key
+			continue
+		}
+		verify = append(verify, key)
+	}
+	if active != nil {
+		verify = append([]jwt.Key{signing}, verify...)
+		signing = *active
+	}
+	if err := s.keys.SetKeys(signing, verify...); err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return nil
+}
+
+func (s *SigningKeySvc) openKey(m model.SigningKey) (jwt.Key, error) {
+	var encoded string
+	if err := s.sealer.Open(m.PrivateKey, &encoded); err != nil {
+		return jwt.Key{}, err
+	}
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return jwt.Key{}, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return jwt.Key{}, err
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return jwt.Key{}, jwt.ErrInvalidKey
+	}
+	return jwt.Key{ID: m.KID, PrivateKey: privateKey, PublicKey: &privateKey.PublicKey}, nil
+}
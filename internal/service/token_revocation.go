@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
+)
+
+// ITokenRevocationSvc blacklists access tokens by jti so they stop verifying before they expire, and tells
+// whether the session a token was issued for has ended, e.g. because the user was blocked or logged out.
+// Revocations and sessions live in the database for durability and in the cache for the per-request check.
+type ITokenRevocationSvc interface {
+	// Revoke blacklists the token described by payload until its expiry.
+	Revoke(ctx context.Context, payload jwt.Payload) error
+	// IsRevoked reports whether jti was revoked. On error callers should treat the token as unusable.
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	// SessionEnded records in the cache that sessionID has ended, so its access tokens stop working at once.
+	// Call it after the session was deactivated or deleted in the database.
+	SessionEnded(ctx context.Context, sessionID string)
+	// IsSessionEnded reports whether sessionID was ended or no longer exists. On error callers should treat
+	// the token as unusable.
+	IsSessionEnded(ctx context.Context, sessionID string) (bool, error)
+	// PurgeExpired deletes revocations of tokens that have expired anyway.
+	PurgeExpired(ctx context.Context) error
+}
+
+type TokenRevocationSvc struct {
+	logger      logger.ILogger
+	cache       cache.ICache
+	repo        repository.IRevokedTokenRepository
+	sessionRepo repository.ISessionRepository
+}
+
+func NewTokenRevocationSvc(
+	logger logger.ILogger,
+	cache cache.ICache,
+	repo repository.IRevokedTokenRepository,
+	sessionRepo repository.ISessionRepository,
+) ITokenRevocationSvc {
+	return &TokenRevocationSvc{
+		logger:      logger,
+		cache:       cache,
+		repo:        repo,
+		sessionRepo: sessionRepo,
+	}
+}
+
+func (s *TokenRevocationSvc) Revoke(ctx context.Context, payload jwt.Payload) error {
+	ctx, span := tracing.Start(ctx, "TokenRevocationSvc.Revoke")
+	defer span.End()
+	if payload.TokenID == "" {
+		return errorx.New(errorx.ErrBadRequest, "token has no jti and cannot be revoked")
+	}
+	ttl := time.Until(payload.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	revoked, err := s.repo.ExistsByJTI(ctx, payload.TokenID)
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if !revoked {
+		entry := &model.RevokedToken{JTI: payload.TokenID, UserID: payload.UserID, ExpiresAt: payload.ExpiresAt}
+		if caller := payloadFromContext(ctx); caller != nil {
+			entry.CreatedBy = caller.UserID
+			entry.UpdatedBy = caller.UserID
+		}
+		if _, err := s.repo.Create(ctx, entry); err != nil {
+			logger.WithContext(ctx, s.logger).Error("[TokenRevocationSvc] failed to store revocation", "jti", payload.TokenID, "error", err)
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+	}
+	if err := s.cache.Set(constant.CacheKeyPrefixRevokedToken+payload.TokenID, true, &ttl); err != nil {
+		// The database entry is authoritative; the negative cache entry expires within a minute.
+		logger.WithContext(ctx, s.logger).Warn("[TokenRevocationSvc] failed to cache revocation", "jti", payload.TokenID, "error", err)
+	}
+	// An opaque access token stops resolving as soon as its entry is gone; JWTs have none.
+	if err := s.cache.Delete(jwt.OpaqueTokenKey(payload.TokenID)); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[TokenRevocationSvc] failed to delete opaque token", "jti", payload.TokenID, "error", err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[TokenRevocationSvc] revoked access token", "jti", payload.TokenID, "userID", payload.UserID)
+	return nil
+}
+
+func (s *TokenRevocationSvc) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "TokenRevocationSvc.IsRevoked")
+	defer span.End()
+	key := constant.CacheKeyPrefixRevokedToken + jti
+	var revoked bool
+	err := s.cache.WithContext(ctx).Get(key, &revoked)
+	if err == nil {
+		return revoked, nil
+	}
+	if !errors.Is(err, cache.ErrCacheNil) {
+		logger.WithContext(ctx, s.logger).Warn("[TokenRevocationSvc] cache lookup failed, asking the database", "error", err)
+	}
+
+	revoked, err = s.repo.ExistsByJTI(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+	ttl := constant.RevocationNegativeCacheTTL
+	if revoked {
+		ttl = constant.CacheDefaultTTL
+	}
+	if err := s.cache.WithContext(ctx).Set(key, revoked, &ttl); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[TokenRevocationSvc] failed to cache revocation status", "error", err)
+	}
+	return revoked, nil
+}
+
+func (s *TokenRevocationSvc) SessionEnded(ctx context.Context, sessionID string) {
+	ttl := constant.CacheDefaultTTL
+	if err := s.cache.WithContext(ctx).Set(constant.CacheKeyPrefixEndedSession+sessionID, true, &ttl); err != nil {
+		// The session row is authoritative; a cached "still active" expires within a minute.
+		logger.WithContext(ctx, s.logger).Warn("[TokenRevocationSvc] failed to cache ended session", "sessionID", sessionID, "error", err)
+	}
+}
+
+func (s *TokenRevocationSvc) IsSessionEnded(ctx context.Context, sessionID string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "TokenRevocationSvc.IsSessionEnded")
+	defer span.End()
+	key := constant.CacheKeyPrefixEndedSession + sessionID
+	var ended bool
+	err := s.cache.WithContext(ctx).Get(key, &ended)
+	if err == nil {
+		return ended, nil
+	}
+	if !errors.Is(err, cache.ErrCacheNil) {
+		logger.WithContext(ctx, s.logger).Warn("[TokenRevocationSvc] cache lookup failed, asking the database", "error", err)
+	}
+
+	active, err := s.sessionRepo.IsActive(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	ended = !active
+	ttl := constant.RevocationNegativeCacheTTL
+	if ended {
+		ttl = constant.CacheDefaultTTL
+	}
+	if err := s.cache.WithContext(ctx).Set(key, ended, &ttl); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[TokenRevocationSvc] failed to cache session status", "error", err)
+	}
+	return ended, nil
+}
+
+func (s *TokenRevocationSvc) PurgeExpired(ctx context.Context) error {
+	if err := s.repo.DeleteExpired(ctx, time.Now()); err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+)
+
+func TestTokenRevocationSvc_IsSessionEnded(t *testing.T) {
+	ctx := context.Background()
+	sessions := testutil.NewSessionRepository()
+	svc := NewTokenRevocationSvc(testutil.NewLogger(), testutil.NewCache(), testutil.NewRevokedTokenRepository(), sessions)
+	session, err := sessions.Create(ctx, &model.Session{UserID: "u1", IsActive: true, ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ended, err := svc.IsSessionEnded(ctx, session.ID); err != nil || ended {
+		t.Fatalf("IsSessionEnded(active) = %v, %v; want false", ended, err)
+	}
+	if ended, err := svc.IsSessionEnded(ctx, "missing"); err != nil || !ended {
+		t.Errorf("IsSessionEnded(missing) = %v, %v; want true", ended, err)
+	}
+
+	// Ending the session replaces the cached "active" at once.
+	session.IsActive = false
+	if err := sessions.Update(ctx, session.ID, *session, "is_active"); err != nil {
+		t.Fatal(err)
+	}
+	svc.SessionEnded(ctx, session.ID)
+	if ended, err := svc.IsSessionEnded(ctx, session.ID); err != nil || !ended {
+		t.Errorf("IsSessionEnded(ended) = %v, %v; want true", ended, err)
+	}
+}
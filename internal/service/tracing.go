@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
+	"go.uber.org/fx"
+)
+
+// RegisterTracingHooks shuts the tracer provider down on stop so spans still buffered are exported.
+func RegisterTracingHooks(lc fx.Lifecycle, provider tracing.IProvider) {
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			return provider.Shutdown(ctx)
+		},
+	})
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// defaultTrustedDeviceDays applies when MFA_TRUSTED_DEVICE_DAYS is not set.
+const defaultTrustedDeviceDays = 30
+
+// ITrustedDeviceSvc remembers devices that completed MFA so later logins from them can skip it.
+type ITrustedDeviceSvc interface {
+	// TrustDevice issues a device token for the user's current device. Call it only after MFA succeeded.
+	TrustDevice(ctx context.Context, userID string) (*aggregate.TrustedDeviceTokenResp, error)
+	// IsTrusted reports whether deviceToken is an unexpired trusted device of userID, and records its use.
+	IsTrusted(ctx context.Context, userID, deviceToken string) bool
+	ListDevices(ctx context.Context) ([]aggregate.TrustedDeviceResp, error)
+	RevokeDevice(ctx context.Context, id string) error
+	RevokeAllDevices(ctx context.Context) error
+}
+
+type TrustedDeviceSvc struct {
+	logger     logger.ILogger
+	deviceRepo repository.ITrustedDeviceRepository
+	ttl        time.Duration
+}
+
+func NewTrustedDeviceSvc(cfg *config.AppConfig, logger logger.ILogger, deviceRepo repository.ITrustedDeviceRepository) ITrustedDeviceSvc {
+	days := cfg.MFA.TrustedDeviceDays
+	if days <= 0 {
+		days = defaultTrustedDeviceDays
+	}
+	return &TrustedDeviceSvc{
+		logger:     logger,
+		deviceRepo: deviceRepo,
+		ttl:        time.Duration(days) * 24 * time.Hour,
+	}
+}
+
+func (s *TrustedDeviceSvc) TrustDevice(ctx context.Context, userID string) (*aggregate.TrustedDeviceTokenResp, error) {
+	token, err := helper.GenerateRefreshToken()
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	str := func(k constant.ContextKey) string { v, _ := ctx.Value(k).(string); return v }
+	device := &model.TrustedDevice{
+		UserID:    userID,
+		TokenHash: helper.HashRefreshToken(token),
+		Name:      str(constant.ContextKeyUserAgent),
+		IP:        str(constant.ContextKeyClientIP),
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	device.CreatedBy = userID
+	device.UpdatedBy = userID
+	if _, err := s.deviceRepo.Create(ctx, device); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[TrustedDeviceSvc] failed to trust device", "userID", userID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return &aggregate.TrustedDeviceTokenResp{DeviceToken: token, ExpiresAt: device.ExpiresAt}, nil
+}
+
+func (s *TrustedDeviceSvc) IsTrusted(ctx context.Context, userID, deviceToken string) bool {
+	if deviceToken == "" {
+		return false
+	}
+	device := s.deviceRepo.FindByTokenHash(ctx, helper.HashRefreshToken(deviceToken))
+	if device == nil || device.UserID != userID || time.Now().After(device.ExpiresAt) {
+		return false
+	}
+	now := time.Now()
+	device.LastUsedAt = &now
+	if err := s.deviceRepo.Update(ctx, device.ID, *device, "last_used_at"); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[TrustedDeviceSvc] failed to record device use", "id", device.ID, "error", err)
+	}
+	return true
+}
+
+func (s *TrustedDeviceSvc) ListDevices(ctx context.Context) ([]aggregate.TrustedDeviceResp, error) {
+	payload := payloadFromContext(ctx)
+	if payload == nil {
+		return nil, errorx.New(errorx.ErrUnauthorized, "missing authentication")
+	}
+	devices, err := s.deviceRepo.FindByUserID(ctx, payload.UserID)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[TrustedDeviceSvc] failed to list devices", "userID", payload.UserID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	result := make([]aggregate.TrustedDeviceResp, 0, len(devices))
+	for i := range devices {
+		if time.Now().After(devices[i].ExpiresAt) {
+			continue
+		}
+		var d aggregate.TrustedDeviceResp
+		d.FromModel(&devices[i])
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+func (s *TrustedDeviceSvc) RevokeDevice(ctx context.Context, id string) error {
+	payload := payloadFromContext(ctx)
+	if payload == nil {
+		return errorx.New(errorx.ErrUnauthorized, "missing authentication")
+	}
+	device := s.deviceRepo.FindOneById(ctx, id)
+	if device == nil || device.UserID != payload.UserID {
+		return errorx.Wrap(errorx.ErrDeviceNotFound, nil)
+	}
+	if err := s.deviceRepo.DeleteById(ctx, id); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[TrustedDeviceSvc] failed to revoke device", "id", id, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return nil
+}
+
+func (s *TrustedDeviceSvc) RevokeAllDevices(ctx context.Context) error {
+	payload := payloadFromContext(ctx)
+	if payload == nil {
+		return errorx.New(errorx.ErrUnauthorized, "missing authentication")
+	}
+	if err := s.deviceRepo.DeleteByUserID(ctx, payload.UserID); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[TrustedDeviceSvc] failed to revoke devices", "userID", payload.UserID, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return nil
+}
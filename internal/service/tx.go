@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+)
+
+// withTx runs fn in a transaction. An error from fn rolls it back and is returned as it is; a failed commit
+// is reported as ErrInternal.
+func withTx(ctx context.Context, txManager repository.ITxManager, fn func(ctx context.Context) error) error {
+	var fnErr error
+	err := txManager.WithTx(ctx, func(ctx context.Context) error {
+		fnErr = fn(ctx)
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return nil
+}
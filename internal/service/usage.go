@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
+)
+
+type IUsageSvc interface {
+	// Consume meters one request for subjectID and fails with ErrRateLimit once a quota window is exhausted.
+	Consume(ctx context.Context, subjectID string, quota aggregate.UsageQuota) (*aggregate.UsageResp, error)
+	// GetUsage returns current counters without metering a request.
+	GetUsage(ctx context.Context, subjectID string, quota aggregate.UsageQuota) (*aggregate.UsageResp, error)
+	// DefaultQuota is the quota applied when a subject has no override.
+	DefaultQuota() aggregate.UsageQuota
+}
+
+type UsageSvc struct {
+	logger       logger.ILogger
+	cache        cache.ICache
+	defaultQuota aggregate.UsageQuota
+	now          func() time.Time
+}
+
+func NewUsageSvc(cfg *config.AppConfig, logger logger.ILogger, cache cache.ICache) IUsageSvc {
+	return &UsageSvc{
+		logger: logger,
+		cache:  cache,
+		defaultQuota: aggregate.UsageQuota{
+			Daily:   int64(cfg.Quota.DailyRequests),
+			Monthly: int64(cfg.Quota.MonthlyRequests),
+		},
+		now: time.Now,
+	}
+}
+
+func (s *UsageSvc) DefaultQuota() aggregate.UsageQuota {
+	return s.defaultQuota
+}
+
+func (s *UsageSvc) Consume(ctx context.Context, subjectID string, quota aggregate.UsageQuota) (*aggregate.UsageResp, error) {
+	ctx, span := tracing.Start(ctx, "UsageSvc.Consume")
+	defer span.End()
+	dayKey, dayReset, monthKey, monthReset := s.windows(subjectID)
+
+	// Counters live a little past their reset so late readers still see the final count.
+	dayTTL := time.Until(dayReset) + time.Hour
+	daily, err := s.cache.WithContext(ctx).Increment(dayKey, &dayTTL)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	monthTTL := time.Until(monthReset) + time.Hour
+	monthly, err := s.cache.WithContext(ctx).Increment(monthKey, &monthTTL)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	usage := &aggregate.UsageResp{
+		SubjectID: subjectID,
+		Daily:     aggregate.UsageWindow{Used: daily, Limit: quota.Daily, ResetsAt: dayReset},
+		Monthly:   aggregate.UsageWindow{Used: monthly, Limit: quota.Monthly, ResetsAt: monthReset},
+	}
+	if usage.Exceeded() {
+		return usage, errorx.New(errorx.ErrRateLimit, "Request quota exceeded")
+	}
+	return usage, nil
+}
+
+func (s *UsageSvc) GetUsage(ctx context.Context, subjectID string, quota aggregate.UsageQuota) (*aggregate.UsageResp, error) {
+	dayKey, dayReset, monthKey, monthReset := s.windows(subjectID)
+	daily, err := s.readCounter(dayKey)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	monthly, err := s.readCounter(monthKey)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	return &aggregate.UsageResp{
+		SubjectID: subjectID,
+		Daily:     aggregate.UsageWindow{Used: daily, Limit: quota.Daily, ResetsAt: dayReset},
+		Monthly:   aggregate.UsageWindow{Used: monthly, Limit: quota.Monthly, ResetsAt: monthReset},
+	}, nil
+}
+
+func (s *UsageSvc) readCounter(key string) (int64, error) {
+	var n int64
+	if err := s.cache.Get(key, &n); err != nil {
+		if err == cache.ErrCacheNil {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return n, nil
+}
+
+// windows returns the cache keys and reset times of the current UTC day and month.
+func (s *UsageSvc) windows(subjectID string) (dayKey string, dayReset time.Time, monthKey string, monthReset time.Time) {
+	now := s.now().UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	dayKey = fmt.Sprintf("usage:%s:day:%s", subjectID, dayStart.Format("20060102"))
+	monthKey = fmt.Sprintf("usage:%s:month:%s", subjectID, monthStart.Format("200601"))
+	return dayKey, dayStart.AddDate(0, 0, 1), monthKey, monthStart.AddDate(0, 1, 0)
+}
@@ -2,68 +2,124 @@ package service
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/hiamthach108/dreon-auth/internal/aggregate"
 	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
 	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
 	"github.com/hiamthach108/dreon-auth/pkg/logger"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/hiamthach108/dreon-auth/pkg/password"
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
 )
 
 // IUserSvc defines the contract for user operations.
 type IUserSvc interface {
 	Create(ctx context.Context, req aggregate.CreateUserReq) (*aggregate.UserDto, error)
 	GetByID(ctx context.Context, id string) (*aggregate.UserDto, error)
-	List(ctx context.Context, page, pageSize int) (*aggregate.PaginationResp[aggregate.UserDto], error)
+	List(ctx context.Context, req aggregate.PaginationReq) (*aggregate.PaginationResp[aggregate.UserDto], error)
 	Update(ctx context.Context, id string, req aggregate.UpdateUserReq) (*aggregate.UserDto, error)
-	Delete(ctx context.Context, id string) error
+	// Delete soft-deletes a user; with hard, which only super admins may ask for, the row is removed for good.
+	Delete(ctx context.Context, id string, hard bool) error
+	// Restore brings back a soft-deleted user. Accounts erased by their owner cannot be restored.
+	Restore(ctx context.Context, id string) (*aggregate.UserDto, error)
+	// UpdateStatus activates, deactivates or blocks a user; deactivating or blocking ends their sessions.
+	UpdateStatus(ctx context.Context, id string, req aggregate.UpdateUserStatusReq) (*aggregate.UserDto, error)
+	// EndSessions ends every active session of a user, so their refresh tokens stop working.
+	EndSessions(ctx context.Context, id string) error
+	// ListSessions lists a user's sessions, newest first, active or not.
+	ListSessions(ctx context.Context, id string, req aggregate.PaginationReq) (*aggregate.PaginationResp[aggregate.UserSessionResp], error)
+	// UpdateAttributes merges custom attributes into a user's, checked against the schemas of the user's projects.
+	UpdateAttributes(ctx context.Context, id string, req aggregate.UpdateUserAttributesReq) (*aggregate.UserDto, error)
+
+	// GetProfile, UpdateProfile and ChangePassword act on the calling user.
+	GetProfile(ctx context.Context) (*aggregate.UserDto, error)
+	UpdateProfile(ctx context.Context, req aggregate.UpdateProfileReq) (*aggregate.UserDto, error)
+	// ChangePassword checks the current password, sets the new one and ends all of the user's sessions.
+	// Users required to reset their password need not give the current one.
+	ChangePassword(ctx context.Context, req aggregate.ChangePasswordReq) (*aggregate.ChangePasswordResp, error)
 }
 
 // UserSvc implements IUserSvc.
 type UserSvc struct {
-	logger logger.ILogger
-	repo   repository.IUserRepository
+	logger      logger.ILogger
+	repo        repository.IUserRepository
+	projectRepo repository.IProjectRepository
+	memberRepo  repository.IProjectMemberRepository
+	sessionRepo repository.ISessionRepository
+	authSvc     IAuthSvc
+	hasher      password.IPasswordHasher
+	policySvc   IPasswordPolicySvc
+	events      eventbus.IPublisher
 }
 
 // NewUserSvc creates a new user service.
-func NewUserSvc(logger logger.ILogger, repo repository.IUserRepository) IUserSvc {
+func NewUserSvc(
+	logger logger.ILogger,
+	repo repository.IUserRepository,
+	projectRepo repository.IProjectRepository,
+	memberRepo repository.IProjectMemberRepository,
+	sessionRepo repository.ISessionRepository,
+	authSvc IAuthSvc,
+	hasher password.IPasswordHasher,
+	policySvc IPasswordPolicySvc,
+	events eventbus.IPublisher,
+) IUserSvc {
 	return &UserSvc{
-		logger: logger,
-		repo:   repo,
+		logger:      logger,
+		repo:        repo,
+		projectRepo: projectRepo,
+		memberRepo:  memberRepo,
+		sessionRepo: sessionRepo,
+		authSvc:     authSvc,
+		hasher:      hasher,
+		policySvc:   policySvc,
+		events:      events,
 	}
 }
 
 // Create creates a new user with hashed password.
 func (s *UserSvc) Create(ctx context.Context, req aggregate.CreateUserReq) (*aggregate.UserDto, error) {
+	ctx, span := tracing.Start(ctx, "UserSvc.Create")
+	defer span.End()
 	existing, err := s.repo.FindByEmail(ctx, req.Email)
 	if err != nil {
-		s.logger.Error("[UserSvc] failed to check email", "email", req.Email, "error", err)
+		logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to check email", "email", req.Email, "error", err)
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
 	if existing != nil {
 		return nil, errorx.New(errorx.ErrUserConflict, "email already registered")
 	}
+	if err := s.checkPhoneAvailable(ctx, "", req.Phone); err != nil {
+		return nil, err
+	}
 
-	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashed, pepperVersion, err := s.hasher.Hash(req.Password)
 	if err != nil {
-		s.logger.Error("[UserSvc] failed to hash password", "error", err)
+		logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to hash password", "error", err)
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
 
-	model := req.ToModel(string(hashed))
+	model := req.ToModel(hashed)
+	model.PasswordPepperVersion = pepperVersion
 	created, err := s.repo.Create(ctx, model)
 	if err != nil {
-		s.logger.Error("[UserSvc] failed to create user", "email", req.Email, "error", err)
+		logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to create user", "email", req.Email, "error", err)
 		return nil, errorx.Wrap(errorx.ErrCreateUser, err)
 	}
 
 	var resp aggregate.UserDto
 	resp.FromModel(created)
+	publishEvent(ctx, s.events, s.logger, constant.EventUserCreated, resp.ID, resp)
 	return &resp, nil
 }
 
 // GetByID returns a user by ID.
 func (s *UserSvc) GetByID(ctx context.Context, id string) (*aggregate.UserDto, error) {
+	ctx, span := tracing.Start(ctx, "UserSvc.GetByID")
+	defer span.End()
 	u := s.repo.FindOneById(ctx, id)
 	if u == nil {
 		return nil, errorx.Wrap(errorx.ErrUserNotFound, nil)
@@ -74,40 +130,34 @@ func (s *UserSvc) GetByID(ctx context.Context, id string) (*aggregate.UserDto, e
 }
 
 // List returns a paginated list of users.
-func (s *UserSvc) List(ctx context.Context, page, pageSize int) (*aggregate.PaginationResp[aggregate.UserDto], error) {
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 10
+func (s *UserSvc) List(ctx context.Context, req aggregate.PaginationReq) (*aggregate.PaginationResp[aggregate.UserDto], error) {
+	ctx, span := tracing.Start(ctx, "UserSvc.List")
+	defer span.End()
+	q, err := newPageQuery(req)
+	if err != nil {
+		return nil, err
 	}
-	offset := (page - 1) * pageSize
 
-	users, total, err := s.repo.List(ctx, offset, pageSize)
+	users, total, err := s.repo.List(repository.WithReplicaReads(ctx), q.repo)
 	if err != nil {
-		s.logger.Error("[UserSvc] failed to list users", "error", err)
+		logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to list users", "error", err)
 		return nil, errorx.Wrap(errorx.ErrInternal, err)
 	}
 
-	items := make([]aggregate.UserDto, 0, len(users))
-	for i := range users {
-		var d aggregate.UserDto
-		d.FromModel(&users[i])
-		items = append(items, d)
-	}
-
-	hasNext := int64(offset+len(users)) < total
-	return &aggregate.PaginationResp[aggregate.UserDto]{
-		Total:    total,
-		Page:     page,
-		PageSize: pageSize,
-		HasNext:  hasNext,
-		Items:    items,
-	}, nil
+	return pageResp(q, users, total,
+		func(m *model.User) *model.BaseModel { return &m.BaseModel },
+		func(m *model.User) aggregate.UserDto {
+			var d aggregate.UserDto
+			d.FromModel(m)
+			return d
+		},
+	), nil
 }
 
 // Update updates a user by ID (partial update).
 func (s *UserSvc) Update(ctx context.Context, id string, req aggregate.UpdateUserReq) (*aggregate.UserDto, error) {
+	ctx, span := tracing.Start(ctx, "UserSvc.Update")
+	defer span.End()
 	u := s.repo.FindOneById(ctx, id)
 	if u == nil {
 		return nil, errorx.Wrap(errorx.ErrUserNotFound, nil)
@@ -120,44 +170,330 @@ func (s *UserSvc) Update(ctx context.Context, id string, req aggregate.UpdateUse
 		return &resp, nil
 	}
 
+	if err := s.checkPhoneAvailable(ctx, id, updated.Phone); err != nil {
+		return nil, err
+	}
+
 	// Hash password if it's being updated
 	for _, f := range fields {
 		if f == "password" {
-			hashed, err := bcrypt.GenerateFromPassword([]byte(updated.Password), bcrypt.DefaultCost)
+			hashed, pepperVersion, err := s.hasher.Hash(updated.Password)
 			if err != nil {
-				s.logger.Error("[UserSvc] failed to hash password", "error", err)
+				logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to hash password", "error", err)
 				return nil, errorx.Wrap(errorx.ErrInternal, err)
 			}
-			updated.Password = string(hashed)
+			updated.Password = hashed
+			updated.PasswordPepperVersion = pepperVersion
+			fields = append(fields, "password_pepper_version")
 			break
 		}
 	}
 
 	if err := s.repo.Update(ctx, id, *updated, fields...); err != nil {
-		s.logger.Error("[UserSvc] failed to update user", "id", id, "error", err)
+		logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to update user", "id", id, "error", err)
 		return nil, errorx.Wrap(errorx.ErrUpdateUser, err)
 	}
 
 	updatedUser := s.repo.FindOneById(ctx, id)
 	if updatedUser == nil {
-		var resp aggregate.UserDto
-		resp.FromModel(u)
-		return &resp, nil
+		updatedUser = u
 	}
 	var resp aggregate.UserDto
 	resp.FromModel(updatedUser)
+	publishEvent(ctx, s.events, s.logger, constant.EventUserUpdated, id, resp)
 	return &resp, nil
 }
 
-// Delete deletes a user by ID.
-func (s *UserSvc) Delete(ctx context.Context, id string) error {
+// Delete soft-deletes a user by ID, or hard-deletes it (including an already soft-deleted user) when hard is set.
+func (s *UserSvc) Delete(ctx context.Context, id string, hard bool) error {
+	ctx, span := tracing.Start(ctx, "UserSvc.Delete")
+	defer span.End()
+	if hard && !isSuperAdminFromContext(ctx) {
+		return errorx.New(errorx.ErrForbidden, "Only super admins can permanently delete users")
+	}
 	u := s.repo.FindOneById(ctx, id)
+	if u == nil && hard {
+		u = s.repo.FindDeletedById(ctx, id)
+	}
 	if u == nil {
 		return errorx.Wrap(errorx.ErrUserNotFound, nil)
 	}
-	if err := s.repo.DeleteById(ctx, id); err != nil {
-		s.logger.Error("[UserSvc] failed to delete user", "id", id, "error", err)
+
+	deleteFn := s.repo.DeleteById
+	if hard {
+		deleteFn = s.repo.HardDeleteById
+	}
+	if err := deleteFn(ctx, id); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to delete user", "id", id, "hard", hard, "error", err)
 		return errorx.Wrap(errorx.ErrInternal, err)
 	}
+	logger.WithContext(ctx, s.logger).Info("[UserSvc] deleted user", "id", id, "hard", hard)
+	publishEvent(ctx, s.events, s.logger, constant.EventUserDeleted, id, map[string]bool{"hard": hard})
+	return nil
+}
+
+// Restore restores a soft-deleted user by ID.
+func (s *UserSvc) Restore(ctx context.Context, id string) (*aggregate.UserDto, error) {
+	ctx, span := tracing.Start(ctx, "UserSvc.Restore")
+	defer span.End()
+	deleted := s.repo.FindDeletedById(ctx, id)
+	if deleted == nil {
+		return nil, errorx.Wrap(errorx.ErrUserNotFound, nil)
+	}
+	if deleted.ErasedAt != nil {
+		return nil, errorx.New(errorx.ErrBadRequest, "The account was erased by its owner and cannot be restored")
+	}
+	if err := s.repo.RestoreById(ctx, id); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to restore user", "id", id, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	u := s.repo.FindOneById(ctx, id)
+	if u == nil {
+		return nil, errorx.Wrap(errorx.ErrUserNotFound, nil)
+	}
+	logger.WithContext(ctx, s.logger).Info("[UserSvc] restored user", "id", id)
+
+	var resp aggregate.UserDto
+	resp.FromModel(u)
+	publishEvent(ctx, s.events, s.logger, constant.EventUserRestored, id, resp)
+	return &resp, nil
+}
+
+// UpdateStatus moves a user to req.Status if the transition is allowed.
+func (s *UserSvc) UpdateStatus(ctx context.Context, id string, req aggregate.UpdateUserStatusReq) (*aggregate.UserDto, error) {
+	ctx, span := tracing.Start(ctx, "UserSvc.UpdateStatus")
+	defer span.End()
+	u := s.repo.FindOneById(ctx, id)
+	if u == nil {
+		return nil, errorx.Wrap(errorx.ErrUserNotFound, nil)
+	}
+	current := u.Status.Normalize()
+
+	var resp aggregate.UserDto
+	if current == req.Status {
+		resp.FromModel(u)
+		return &resp, nil
+	}
+	if !current.CanTransitionTo(req.Status) {
+		return nil, errorx.New(errorx.ErrBadRequest, fmt.Sprintf("Cannot change user status from %s to %s", current, req.Status))
+	}
+
+	u.Status = req.Status
+	if caller := payloadFromContext(ctx); caller != nil {
+		u.UpdatedBy = caller.UserID
+	}
+	if err := s.repo.Update(ctx, id, *u, "status", "updated_by"); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to update user status", "id", id, "status", req.Status, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[UserSvc] updated user status", "id", id, "from", current, "to", req.Status)
+	if u.IsDisabled() {
+		if err := s.authSvc.EndUserSessions(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	resp.FromModel(u)
+	publishEvent(ctx, s.events, s.logger, constant.EventUserStatus, id, resp)
+	return &resp, nil
+}
+
+// UpdateAttributes merges req.Attributes into the user's attributes. The result must match the schema of
+// every project the user is an active member of; attributes no schema declares are stored as given.
+func (s *UserSvc) UpdateAttributes(ctx context.Context, id string, req aggregate.UpdateUserAttributesReq) (*aggregate.UserDto, error) {
+	ctx, span := tracing.Start(ctx, "UserSvc.UpdateAttributes")
+	defer span.End()
+	u := s.repo.FindOneById(ctx, id)
+	if u == nil {
+		return nil, errorx.Wrap(errorx.ErrUserNotFound, nil)
+	}
+
+	attributes := u.AttributeMap()
+	for key, value := range req.Attributes {
+		if key == "" {
+			return nil, errorx.New(errorx.ErrBadRequest, "Attribute keys must not be empty")
+		}
+		if value == nil {
+			delete(attributes, key)
+			continue
+		}
+		attributes[key] = value
+	}
+	if err := s.checkAttributes(ctx, id, attributes); err != nil {
+		return nil, err
+	}
+
+	u.SetAttributes(attributes)
+	if caller := payloadFromContext(ctx); caller != nil {
+		u.UpdatedBy = caller.UserID
+	}
+	if err := s.repo.Update(ctx, id, *u, "attributes", "updated_by"); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to update user attributes", "id", id, "error", err)
+		return nil, errorx.Wrap(errorx.ErrUpdateUser, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[UserSvc] updated user attributes", "id", id)
+
+	var resp aggregate.UserDto
+	resp.FromModel(u)
+	publishEvent(ctx, s.events, s.logger, constant.EventUserUpdated, id, resp)
+	return &resp, nil
+}
+
+// EndSessions ends a user's sessions without changing their status.
+func (s *UserSvc) EndSessions(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "UserSvc.EndSessions")
+	defer span.End()
+	if s.repo.FindOneById(ctx, id) == nil {
+		return errorx.Wrap(errorx.ErrUserNotFound, nil)
+	}
+	if err := s.authSvc.EndUserSessions(ctx, id); err != nil {
+		return err
+	}
+	logger.WithContext(ctx, s.logger).Info("[UserSvc] ended user sessions", "id", id)
+	return nil
+}
+
+func (s *UserSvc) ListSessions(ctx context.Context, id string, req aggregate.PaginationReq) (*aggregate.PaginationResp[aggregate.UserSessionResp], error) {
+	ctx, span := tracing.Start(ctx, "UserSvc.ListSessions")
+	defer span.End()
+	q, err := newPageQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	ctx = repository.WithReplicaReads(ctx)
+	if s.repo.FindOneById(ctx, id) == nil {
+		return nil, errorx.Wrap(errorx.ErrUserNotFound, nil)
+	}
+
+	sessions, total, err := s.sessionRepo.ListByUserID(ctx, id, q.repo)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to list user sessions", "id", id, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+
+	return pageResp(q, sessions, total,
+		func(m *model.Session) *model.BaseModel { return &m.BaseModel },
+		func(m *model.Session) aggregate.UserSessionResp {
+			var r aggregate.UserSessionResp
+			r.FromModel(m)
+			return r
+		},
+	), nil
+}
+
+// checkAttributes rejects attributes whose type does not match the schema of a project the user belongs to.
+func (s *UserSvc) checkAttributes(ctx context.Context, userID string, attributes map[string]any) error {
+	members, err := s.memberRepo.FindByUserID(ctx, userID, constant.ProjectMemberActive)
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	projectIDs := make([]string, 0, len(members))
+	for _, m := range members {
+		projectIDs = append(projectIDs, m.ProjectID)
+	}
+	projects, err := s.projectRepo.FindByIds(ctx, projectIDs)
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	for _, p := range projects {
+		for _, def := range p.AttributeSchemaList() {
+			if value, ok := attributes[def.Key]; ok && !def.Type.Accepts(value) {
+				return errorx.New(errorx.ErrBadRequest, fmt.Sprintf("Attribute %q must be of type %s (project %s)", def.Key, def.Type, p.Code))
+			}
+		}
+	}
+	return nil
+}
+
+// GetProfile returns the calling user.
+func (s *UserSvc) GetProfile(ctx context.Context) (*aggregate.UserDto, error) {
+	userID, err := callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, userID)
+}
+
+// UpdateProfile updates the calling user's username and phone.
+func (s *UserSvc) UpdateProfile(ctx context.Context, req aggregate.UpdateProfileReq) (*aggregate.UserDto, error) {
+	userID, err := callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.Update(ctx, userID, aggregate.UpdateUserReq{Username: req.Username, Phone: req.Phone})
+}
+
+// ChangePassword changes the calling user's password after checking the current one. The new password
+// is checked against the breached-password policy of the project the caller is signed in to.
+func (s *UserSvc) ChangePassword(ctx context.Context, req aggregate.ChangePasswordReq) (*aggregate.ChangePasswordResp, error) {
+	ctx, span := tracing.Start(ctx, "UserSvc.ChangePassword")
+	defer span.End()
+	userID, err := callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u := s.repo.FindOneById(ctx, userID)
+	if u == nil {
+		return nil, errorx.Wrap(errorx.ErrUserNotFound, nil)
+	}
+	// A user who must reset their password proved who they are by signing in another way.
+	if !u.PasswordResetRequired {
+		if err := s.hasher.Compare(u.Password, u.PasswordPepperVersion, req.CurrentPassword); err != nil {
+			return nil, errorx.New(errorx.ErrBadRequest, "Current password is incorrect")
+		}
+	}
+	warnings, err := s.policySvc.CheckNew(ctx, payloadFromContext(ctx).ProjectID, req.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	hashed, pepperVersion, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to hash password", "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	u.Password = hashed
+	u.PasswordPepperVersion = pepperVersion
+	u.PasswordResetRequired = false
+	u.UpdatedBy = userID
+	if err := s.repo.Update(ctx, userID, *u, "password", "password_pepper_version", "password_reset_required", "updated_by"); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to change password", "id", userID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrUpdateUser, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[UserSvc] changed password", "id", userID)
+
+	// Sessions opened with the old password must not outlive it.
+	if err := s.authSvc.EndUserSessions(ctx, userID); err != nil {
+		return nil, err
+	}
+	return &aggregate.ChangePasswordResp{Warnings: warnings}, nil
+}
+
+// callerUserID returns the ID of the user making the request for self-service endpoints; super admins
+// and machine callers are not users.
+func callerUserID(ctx context.Context) (string, error) {
+	caller := payloadFromContext(ctx)
+	if caller == nil || caller.UserID == "" || caller.IsSuperAdmin || caller.IsMachine() {
+		return "", errorx.New(errorx.ErrUnauthorized, "only users can manage their own account")
+	}
+	return caller.UserID, nil
+}
+
+// checkPhoneAvailable rejects a phone number already registered to a user other than userID.
+func (s *UserSvc) checkPhoneAvailable(ctx context.Context, userID, phone string) error {
+	if phone == "" {
+		return nil
+	}
+	existing, err := s.repo.FindByPhone(ctx, phone)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserSvc] failed to check phone", "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if existing != nil && existing.ID != userID {
+		return errorx.New(errorx.ErrUserConflict, "phone already registered")
+	}
 	return nil
 }
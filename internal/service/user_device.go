@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// IUserDeviceSvc lets users see the devices they signed in from, name them and sign a device out. Devices
+// are recorded by AuthSvc whenever it starts a session.
+type IUserDeviceSvc interface {
+	// ListDevices returns the caller's devices, most recently seen first.
+	ListDevices(ctx context.Context) ([]aggregate.UserDeviceResp, error)
+	RenameDevice(ctx context.Context, id string, req aggregate.RenameUserDeviceReq) (*aggregate.UserDeviceResp, error)
+	// SignOutDevice ends every active session of one of the caller's devices.
+	SignOutDevice(ctx context.Context, id string) error
+}
+
+type UserDeviceSvc struct {
+	logger      logger.ILogger
+	deviceRepo  repository.IUserDeviceRepository
+	sessionRepo repository.ISessionRepository
+	authSvc     IAuthSvc
+}
+
+func NewUserDeviceSvc(
+	logger logger.ILogger,
+	deviceRepo repository.IUserDeviceRepository,
+	sessionRepo repository.ISessionRepository,
+	authSvc IAuthSvc,
+) IUserDeviceSvc {
+	return &UserDeviceSvc{
+		logger:      logger,
+		deviceRepo:  deviceRepo,
+		sessionRepo: sessionRepo,
+		authSvc:     authSvc,
+	}
+}
+
+func (s *UserDeviceSvc) ListDevices(ctx context.Context) ([]aggregate.UserDeviceResp, error) {
+	userID, err := callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := s.deviceRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserDeviceSvc] failed to list devices", "userID", userID, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	sessions, err := s.sessionRepo.FindActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	active := map[string]int{}
+	current := ""
+	callerSession := payloadFromContext(ctx).SessionID
+	for _, session := range sessions {
+		if session.ExpiresAt.After(time.Now()) {
+			active[session.DeviceID]++
+		}
+		if session.ID == callerSession {
+			current = session.DeviceID
+		}
+	}
+
+	out := make([]aggregate.UserDeviceResp, len(devices))
+	for i := range devices {
+		out[i].FromModel(&devices[i])
+		out[i].ActiveSessions = active[devices[i].ID]
+		out[i].Current = current != "" && devices[i].ID == current
+	}
+	return out, nil
+}
+
+func (s *UserDeviceSvc) RenameDevice(ctx context.Context, id string, req aggregate.RenameUserDeviceReq) (*aggregate.UserDeviceResp, error) {
+	userID, err := callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	device := s.deviceRepo.FindOneById(ctx, id)
+	if device == nil || device.UserID != userID {
+		return nil, errorx.Wrap(errorx.ErrUserDeviceNotFound, nil)
+	}
+	device.Name = strings.TrimSpace(req.Name)
+	device.UpdatedBy = userID
+	if err := s.deviceRepo.Update(ctx, id, model.UserDevice{Name: device.Name}, "name"); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserDeviceSvc] failed to rename device", "id", id, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	resp := &aggregate.UserDeviceResp{}
+	resp.FromModel(device)
+	return resp, nil
+}
+
+func (s *UserDeviceSvc) SignOutDevice(ctx context.Context, id string) error {
+	userID, err := callerUserID(ctx)
+	if err != nil {
+		return err
+	}
+	device := s.deviceRepo.FindOneById(ctx, id)
+	if device == nil || device.UserID != userID {
+		return errorx.Wrap(errorx.ErrUserDeviceNotFound, nil)
+	}
+	if err := s.authSvc.EndDeviceSessions(ctx, userID, id); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserDeviceSvc] failed to sign out device", "id", id, "error", err)
+		return err
+	}
+	logger.WithContext(ctx, s.logger).Info("[UserDeviceSvc] signed out device", "id", id, "userID", userID)
+	return nil
+}
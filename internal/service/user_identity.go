@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/statetoken"
+)
+
+// IUserIdentitySvc manages the external logins (OAuth, OIDC, SAML) linked to users. An external login
+// that matches an existing account only by email is not linked until the account owner confirms it.
+type IUserIdentitySvc interface {
+	// FindUser returns the user the external login is linked to, or nil, and records the use.
+	FindUser(ctx context.Context, state aggregate.OAuthRefreshState) *model.User
+	// Link links the external login to userID without confirmation.
+	Link(ctx context.Context, userID string, state aggregate.OAuthRefreshState) error
+	// LinksWithoutConfirmation reports whether the external login may be linked to user, who matched it
+	// by email, without the owner confirming it.
+	LinksWithoutConfirmation(ctx context.Context, user *model.User, state aggregate.OAuthRefreshState) bool
+	// ProposeLink returns a single-use token the owner of userID confirms with ConfirmLink.
+	ProposeLink(ctx context.Context, userID string, state aggregate.OAuthRefreshState) (string, error)
+	// ConfirmLink, List and Unlink act on the calling user.
+	ConfirmLink(ctx context.Context, req aggregate.ConfirmIdentityLinkReq) (*aggregate.UserIdentityResp, error)
+	List(ctx context.Context) ([]aggregate.UserIdentityResp, error)
+	Unlink(ctx context.Context, id string) error
+}
+
+type UserIdentitySvc struct {
+	logger      logger.ILogger
+	repo        repository.IUserIdentityRepository
+	userRepo    repository.IUserRepository
+	scimRepo    repository.ISCIMUserRepository
+	cache       cache.ICache
+	stateSealer statetoken.ISealer
+	events      eventbus.IPublisher
+}
+
+func NewUserIdentitySvc(
+	logger logger.ILogger,
+	repo repository.IUserIdentityRepository,
+	userRepo repository.IUserRepository,
+	scimRepo repository.ISCIMUserRepository,
+	cache cache.ICache,
+	stateSealer statetoken.ISealer,
+	events eventbus.IPublisher,
+) IUserIdentitySvc {
+	return &UserIdentitySvc{
+		logger:      logger,
+		repo:        repo,
+		userRepo:    userRepo,
+		scimRepo:    scimRepo,
+		cache:       cache,
+		stateSealer: stateSealer,
+		events:      events,
+	}
+}
+
+func (s *UserIdentitySvc) FindUser(ctx context.Context, state aggregate.OAuthRefreshState) *model.User {
+	authType, provider, subject := identitySubject(state)
+	fields := []string{"last_used_at"}
+	identity := s.repo.FindBySubject(ctx, authType, provider, subject)
+	if identity == nil && provider != "" {
+		// Identities migrated from the user row do not record the OIDC issuer or SAML connection;
+		// the first login through one claims it.
+		if identity = s.repo.FindBySubject(ctx, authType, "", subject); identity != nil {
+			identity.Provider = provider
+			fields = append(fields, "provider")
+		}
+	}
+	if identity == nil {
+		return nil
+	}
+	user := s.userRepo.FindOneById(ctx, identity.UserID)
+	if user == nil {
+		return nil
+	}
+	now := time.Now()
+	identity.LastUsedAt = &now
+	if err := s.repo.Update(ctx, identity.ID, *identity, fields...); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[UserIdentitySvc] failed to record identity use", "id", identity.ID, "error", err)
+	}
+	return user
+}
+
+func (s *UserIdentitySvc) Link(ctx context.Context, userID string, state aggregate.OAuthRefreshState) error {
+	authType, provider, subject := identitySubject(state)
+	_, err := s.link(ctx, userID, authType, provider, subject, state.UserData.Email)
+	return err
+}
+
+// LinksWithoutConfirmation allows SAML logins, whose connections only assert addresses from the tenant's
+// own domains, and users provisioned through SCIM, who sign in through their IdP.
+func (s *UserIdentitySvc) LinksWithoutConfirmation(ctx context.Context, user *model.User, state aggregate.OAuthRefreshState) bool {
+	if state.AuthType == constant.UserAuthTypeSAML {
+		return true
+	}
+	return s.scimRepo.FindByUserID(ctx, user.ID) != nil
+}
+
+func (s *UserIdentitySvc) ProposeLink(ctx context.Context, userID string, state aggregate.OAuthRefreshState) (string, error) {
+	authType, provider, subject := identitySubject(state)
+	pending := aggregate.PendingIdentityLink{
+		ID:             uuid.NewString(),
+		UserID:         userID,
+		AuthType:       authType,
+		Provider:       provider,
+		ProviderUserID: subject,
+		Email:          state.UserData.Email,
+	}
+	ttl := constant.IdentityLinkTTL
+	token, err := s.stateSealer.Seal(pending, ttl)
+	if err != nil {
+		return "", errorx.Wrap(errorx.ErrInternal, err)
+	}
+	if err := s.cache.Set(constant.CacheKeyPrefixIdentityLink+pending.ID, time.Now(), &ttl); err != nil {
+		return "", errorx.Wrap(errorx.ErrInternal, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[UserIdentitySvc] identity link pending confirmation", "userID", userID, "authType", authType, "provider", provider)
+	return token, nil
+}
+
+func (s *UserIdentitySvc) ConfirmLink(ctx context.Context, req aggregate.ConfirmIdentityLinkReq) (*aggregate.UserIdentityResp, error) {
+	userID, err := callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	invalid := errorx.New(errorx.ErrBadRequest, "invalid or expired link token")
+	var pending aggregate.PendingIdentityLink
+	if err := s.stateSealer.Open(req.LinkToken, &pending); err != nil || pending.ID == "" {
+		return nil, invalid
+	}
+	// Only the owner of the matched account can accept the link, by confirming while signed in to it.
+	if pending.UserID != userID {
+		return nil, errorx.New(errorx.ErrForbidden, "link token was issued for another account")
+	}
+	key := constant.CacheKeyPrefixIdentityLink + pending.ID
+	var issuedAt time.Time
+	if err := s.cache.Get(key, &issuedAt); err != nil {
+		return nil, invalid
+	}
+	if err := s.cache.Delete(key); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[UserIdentitySvc] failed to delete used link token", "error", err)
+	}
+
+	identity, err := s.link(ctx, userID, pending.AuthType, pending.Provider, pending.ProviderUserID, pending.Email)
+	if err != nil {
+		return nil, err
+	}
+	resp := &aggregate.UserIdentityResp{}
+	resp.FromModel(identity)
+	return resp, nil
+}
+
+func (s *UserIdentitySvc) List(ctx context.Context) ([]aggregate.UserIdentityResp, error) {
+	userID, err := callerUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := s.repo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	out := make([]aggregate.UserIdentityResp, len(identities))
+	for i := range identities {
+		out[i].FromModel(&identities[i])
+	}
+	return out, nil
+}
+
+func (s *UserIdentitySvc) Unlink(ctx context.Context, id string) error {
+	userID, err := callerUserID(ctx)
+	if err != nil {
+		return err
+	}
+	identity := s.repo.FindOneById(ctx, id)
+	if identity == nil || identity.UserID != userID {
+		return errorx.Wrap(errorx.ErrIdentityNotFound, nil)
+	}
+	// Removed for good, so the external account can be linked again later.
+	if err := s.repo.HardDeleteById(ctx, id); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserIdentitySvc] failed to unlink identity", "id", id, "error", err)
+		return errorx.Wrap(errorx.ErrInternal, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[UserIdentitySvc] unlinked identity", "id", id, "userID", userID)
+
+	resp := &aggregate.UserIdentityResp{}
+	resp.FromModel(identity)
+	publishEvent(ctx, s.events, s.logger, constant.EventIdentityUnlinked, userID, resp)
+	return nil
+}
+
+// link stores the identity for userID. An identity left behind by a deleted user is replaced; one that
+// belongs to another user is a conflict.
+func (s *UserIdentitySvc) link(ctx context.Context, userID string, authType constant.UserAuthType, provider, subject, email string) (*model.UserIdentity, error) {
+	if existing := s.repo.FindBySubject(ctx, authType, provider, subject); existing != nil {
+		if existing.UserID == userID {
+			return existing, nil
+		}
+		if s.userRepo.FindOneById(ctx, existing.UserID) != nil {
+			return nil, errorx.New(errorx.ErrConflict, "This login is already linked to another account")
+		}
+		if err := s.repo.HardDeleteById(ctx, existing.ID); err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, err)
+		}
+	}
+
+	now := time.Now()
+	identity, err := s.repo.Create(ctx, &model.UserIdentity{
+		UserID:         userID,
+		AuthType:       authType,
+		Provider:       provider,
+		ProviderUserID: subject,
+		Email:          email,
+		LastUsedAt:     &now,
+	})
+	if err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserIdentitySvc] failed to link identity", "userID", userID, "authType", authType, "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[UserIdentitySvc] linked identity", "userID", userID, "authType", authType, "provider", provider)
+
+	resp := &aggregate.UserIdentityResp{}
+	resp.FromModel(identity)
+	publishEvent(ctx, s.events, s.logger, constant.EventIdentityLinked, userID, resp)
+	return identity, nil
+}
+
+// identitySubject returns the key of an external login. Providers that report no stable user ID are
+// keyed by email.
+func identitySubject(state aggregate.OAuthRefreshState) (constant.UserAuthType, string, string) {
+	subject := state.UserData.ProviderID
+	if subject == "" {
+		subject = strings.ToLower(state.UserData.Email)
+	}
+	return state.AuthType, state.Provider, subject
+}
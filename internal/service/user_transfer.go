@@ -0,0 +1,395 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/pkg/background"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/password"
+)
+
+// IUserTransferSvc moves users in and out in bulk, for migrations from and to other auth systems.
+type IUserTransferSvc interface {
+	// StartImport reads a CSV or NDJSON file of users and imports them in the background. With
+	// forcePasswordReset, imported users must set a new password even when the file carries their hash.
+	StartImport(ctx context.Context, format string, forcePasswordReset bool, file io.Reader) (*aggregate.UserImportJob, error)
+	// GetImport returns the progress of an import job.
+	GetImport(ctx context.Context, id string) (*aggregate.UserImportJob, error)
+	// Export writes every user to w as CSV or NDJSON, batch by batch.
+	Export(ctx context.Context, format string, withPasswordHashes bool, w io.Writer) error
+}
+
+type UserTransferSvc struct {
+	logger   logger.ILogger
+	userRepo repository.IUserRepository
+	cache    cache.ICache
+	events   eventbus.IPublisher
+	workers  *background.Group
+	hasher   password.IPasswordHasher
+}
+
+func NewUserTransferSvc(
+	logger logger.ILogger,
+	userRepo repository.IUserRepository,
+	cache cache.ICache,
+	events eventbus.IPublisher,
+	workers *background.Group,
+	hasher password.IPasswordHasher,
+) IUserTransferSvc {
+	return &UserTransferSvc{
+		logger:   logger,
+		userRepo: userRepo,
+		cache:    cache,
+		events:   events,
+		workers:  workers,
+		hasher:   hasher,
+	}
+}
+
+// userCSVColumns is the header of CSV exports; imports accept any subset that includes email.
+var userCSVColumns = []string{"id", "email", "username", "phone", "status", "passwordHash", "passwordPepperVersion", "attributes", "createdAt"}
+
+// importLine is a parsed record of an import file, or why it could not be parsed.
+type importLine struct {
+	line   int
+	record aggregate.UserImportRecord
+	err    error
+}
+
+func (s *UserTransferSvc) StartImport(ctx context.Context, format string, forcePasswordReset bool, file io.Reader) (*aggregate.UserImportJob, error) {
+	// The file is read before the request ends; the records are imported after it.
+	data, err := io.ReadAll(io.LimitReader(file, constant.UserImportMaxBytes+1))
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, err)
+	}
+	if len(data) > constant.UserImportMaxBytes {
+		return nil, errorx.New(errorx.ErrBadRequest, fmt.Sprintf("Import file is larger than %d bytes", constant.UserImportMaxBytes))
+	}
+	var lines []importLine
+	switch format {
+	case constant.UserTransferCSV:
+		lines, err = parseUserCSV(data)
+	case constant.UserTransferNDJSON:
+		lines, err = parseUserNDJSON(data)
+	default:
+		return nil, errorx.New(errorx.ErrBadRequest, "Import format must be csv or ndjson")
+	}
+	if err != nil {
+		return nil, errorx.New(errorx.ErrBadRequest, err.Error())
+	}
+
+	job := &aggregate.UserImportJob{
+		ID:                 uuid.NewString(),
+		Status:             constant.UserImportRunning,
+		ForcePasswordReset: forcePasswordReset,
+		Total:              len(lines),
+		Errors:             []aggregate.UserImportError{},
+		StartedAt:          time.Now(),
+	}
+	if err := s.saveJob(job); err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[UserTransferSvc] started user import", "job", job.ID, "format", format, "records", job.Total)
+
+	snapshot := *job
+	s.workers.Go(func() { s.runImport(context.WithoutCancel(ctx), job, lines) })
+	return &snapshot, nil
+}
+
+func (s *UserTransferSvc) GetImport(ctx context.Context, id string) (*aggregate.UserImportJob, error) {
+	var job aggregate.UserImportJob
+	if err := s.cache.Get(constant.CacheKeyPrefixUserImport+id, &job); err != nil || job.ID == "" {
+		return nil, errorx.New(errorx.ErrNotFound, "Import job not found")
+	}
+	return &job, nil
+}
+
+func (s *UserTransferSvc) Export(ctx context.Context, format string, withPasswordHashes bool, w io.Writer) error {
+	var write func(*aggregate.UserExportRecord) error
+	var flush func() error
+	switch format {
+	case constant.UserTransferNDJSON:
+		enc := json.NewEncoder(w)
+		write = func(r *aggregate.UserExportRecord) error { return enc.Encode(r) }
+		flush = func() error { return nil }
+	case constant.UserTransferCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(userCSVColumns); err != nil {
+			return err
+		}
+		write = func(r *aggregate.UserExportRecord) error { return cw.Write(userCSVRow(r)) }
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		return errorx.New(errorx.ErrBadRequest, "Export format must be csv or ndjson")
+	}
+
+	count := 0
+	afterID := ""
+	for {
+		users, err := s.userRepo.ListAfter(ctx, afterID, constant.UserExportBatchSize)
+		if err != nil {
+			logger.WithContext(ctx, s.logger).Error("[UserTransferSvc] failed to read users for export", "error", err)
+			return errorx.Wrap(errorx.ErrInternal, err)
+		}
+		for i := range users {
+			var record aggregate.UserExportRecord
+			record.FromModel(&users[i], withPasswordHashes)
+			if err := write(&record); err != nil {
+				return err
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		// Send each batch on, so large exports stream instead of building up in memory.
+		if f, ok := w.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+		count += len(users)
+		if len(users) < constant.UserExportBatchSize {
+			break
+		}
+		afterID = users[len(users)-1].ID
+	}
+	logger.WithContext(ctx, s.logger).Info("[UserTransferSvc] exported users", "format", format, "count", count, "passwordHashes", withPasswordHashes)
+	return nil
+}
+
+// runImport creates the users of an import job, saving its progress every hundred records.
+func (s *UserTransferSvc) runImport(ctx context.Context, job *aggregate.UserImportJob, lines []importLine) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.WithContext(ctx, s.logger).Error("[UserTransferSvc] user import crashed", "job", job.ID, "panic", r)
+			s.finishImport(ctx, job, constant.UserImportFailed)
+		}
+	}()
+	for i, l := range lines {
+		err := l.err
+		if err == nil {
+			err = s.importUser(ctx, l.record, job.ForcePasswordReset)
+		}
+		if err != nil {
+			job.Skipped++
+			if len(job.Errors) < constant.UserImportMaxErrors {
+				job.Errors = append(job.Errors, aggregate.UserImportError{Line: l.line, Email: l.record.Email, Message: err.Error()})
+			}
+		} else {
+			job.Imported++
+		}
+		if (i+1)%100 == 0 {
+			if err := s.saveJob(job); err != nil {
+				logger.WithContext(ctx, s.logger).Warn("[UserTransferSvc] failed to save import progress", "job", job.ID, "error", err)
+			}
+		}
+	}
+	s.finishImport(ctx, job, constant.UserImportCompleted)
+}
+
+func (s *UserTransferSvc) finishImport(ctx context.Context, job *aggregate.UserImportJob, status string) {
+	now := time.Now()
+	job.Status = status
+	job.FinishedAt = &now
+	if err := s.saveJob(job); err != nil {
+		logger.WithContext(ctx, s.logger).Error("[UserTransferSvc] failed to save import result", "job", job.ID, "error", err)
+	}
+	logger.WithContext(ctx, s.logger).Info("[UserTransferSvc] finished user import", "job", job.ID, "status", status, "imported", job.Imported, "skipped", job.Skipped)
+	publishEvent(ctx, s.events, s.logger, constant.EventUserImported, job.ID, job)
+}
+
+// importUser creates one user. Records without a password hash, or all records when forcePasswordReset
+// is set, get an unusable random password and must set a new one.
+func (s *UserTransferSvc) importUser(ctx context.Context, r aggregate.UserImportRecord, forcePasswordReset bool) error {
+	email := strings.TrimSpace(r.Email)
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return fmt.Errorf("invalid email %q", r.Email)
+	}
+	username := strings.TrimSpace(r.Username)
+	if username == "" {
+		username = email
+	}
+	status := r.Status.Normalize()
+	switch status {
+	case constant.UserStatusActive, constant.UserStatusInactive, constant.UserStatusPending, constant.UserStatusBlocked:
+	default:
+		return fmt.Errorf("invalid status %q", r.Status)
+	}
+
+	password, pepperVersion := r.PasswordHash, r.PasswordPepperVersion
+	if password != "" {
+		if !s.hasher.IsHash(password) {
+			return fmt.Errorf("passwordHash is not a bcrypt or argon2id hash")
+		}
+		if pepperVersion < 0 {
+			return fmt.Errorf("invalid passwordPepperVersion %d", pepperVersion)
+		}
+	}
+	resetRequired := forcePasswordReset || password == ""
+	if password == "" {
+		random, err := helper.GenerateRefreshToken()
+		if err != nil {
+			return err
+		}
+		if password, pepperVersion, err = s.hasher.Hash(random); err != nil {
+			return err
+		}
+	}
+
+	if existing, err := s.userRepo.FindByEmail(ctx, email); err != nil {
+		return err
+	} else if existing != nil {
+		return fmt.Errorf("email already registered")
+	}
+	if existing, err := s.userRepo.FindByUsername(ctx, username); err != nil {
+		return err
+	} else if existing != nil {
+		return fmt.Errorf("username already taken")
+	}
+	if r.Phone != "" {
+		if existing, err := s.userRepo.FindByPhone(ctx, r.Phone); err != nil {
+			return err
+		} else if existing != nil {
+			return fmt.Errorf("phone already registered")
+		}
+	}
+
+	user := &model.User{
+		Username:              username,
+		Email:                 email,
+		Phone:                 r.Phone,
+		Password:              password,
+		PasswordPepperVersion: pepperVersion,
+		Status:                status,
+		PasswordResetRequired: resetRequired,
+	}
+	if len(r.Attributes) > 0 {
+		user.SetAttributes(r.Attributes)
+	}
+	if _, err := s.userRepo.Create(ctx, user); err != nil {
+		logger.WithContext(ctx, s.logger).Warn("[UserTransferSvc] failed to import user", "email", email, "error", err)
+		return fmt.Errorf("could not create user")
+	}
+	return nil
+}
+
+func (s *UserTransferSvc) saveJob(job *aggregate.UserImportJob) error {
+	ttl := constant.UserImportJobTTL
+	return s.cache.Set(constant.CacheKeyPrefixUserImport+job.ID, job, &ttl)
+}
+
+// parseUserNDJSON parses one JSON record per line; blank lines are skipped.
+func parseUserNDJSON(data []byte) ([]importLine, error) {
+	var lines []importLine
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		l := importLine{line: n}
+		if err := json.Unmarshal(text, &l.record); err != nil {
+			l.err = fmt.Errorf("invalid JSON: %v", err)
+		}
+		lines = append(lines, l)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading NDJSON: %v", err)
+	}
+	return lines, nil
+}
+
+// parseUserCSV parses a CSV file whose header names the record keys.
+func parseUserCSV(data []byte) ([]importLine, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %v", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, fmt.Errorf("CSV header has no email column")
+	}
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var lines []importLine
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("reading CSV: %v", err)
+			}
+			lines = append(lines, importLine{line: parseErr.StartLine, err: fmt.Errorf("invalid CSV row: %v", parseErr.Err)})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		l := importLine{line: line}
+		l.record = aggregate.UserImportRecord{
+			Email:        field(row, "email"),
+			Username:     field(row, "username"),
+			Phone:        field(row, "phone"),
+			PasswordHash: field(row, "passwordHash"),
+			Status:       constant.UserStatus(field(row, "status")),
+		}
+		if raw := field(row, "passwordPepperVersion"); raw != "" {
+			if l.record.PasswordPepperVersion, err = strconv.Atoi(raw); err != nil {
+				l.err = fmt.Errorf("passwordPepperVersion is not a number")
+			}
+		}
+		if raw := field(row, "attributes"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &l.record.Attributes); err != nil {
+				l.err = fmt.Errorf("attributes is not a JSON object")
+			}
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+func userCSVRow(r *aggregate.UserExportRecord) []string {
+	attributes := ""
+	if len(r.Attributes) > 0 {
+		data, _ := json.Marshal(r.Attributes)
+		attributes = string(data)
+	}
+	pepperVersion := ""
+	if r.PasswordPepperVersion > 0 {
+		pepperVersion = strconv.Itoa(r.PasswordPepperVersion)
+	}
+	return []string{r.ID, r.Email, r.Username, r.Phone, r.Status, r.PasswordHash, pepperVersion, attributes, r.CreatedAt.Format(time.RFC3339)}
+}
@@ -0,0 +1,98 @@
+// Package accesspolicy evaluates per-project network conditions (allowed CIDRs, blocked countries,
+// MFA outside the corporate network) against a request's client IP and country.
+package accesspolicy
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Reason explains why a request was denied. The zero value means the request is allowed.
+type Reason string
+
+const (
+	ReasonNone           Reason = ""
+	ReasonIPNotAllowed   Reason = "IP_NOT_ALLOWED"
+	ReasonCountryBlocked Reason = "COUNTRY_BLOCKED"
+	ReasonCountryUnknown Reason = "COUNTRY_UNKNOWN"
+	ReasonMFARequired    Reason = "MFA_REQUIRED"
+)
+
+// Policy is the set of network conditions a project enforces. Empty lists impose no restriction.
+type Policy struct {
+	AllowedCIDRs             []string
+	BlockedCountries         []string // ISO 3166-1 alpha-2 codes
+	CorporateCIDRs           []string
+	RequireMFAOutsideNetwork bool
+}
+
+// Request is what a policy is evaluated against.
+type Request struct {
+	IP          string
+	Country     string // ISO 3166-1 alpha-2, empty when unknown
+	MFAVerified bool
+}
+
+// Validate reports the first malformed CIDR or country code.
+func (p *Policy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, cidrs := range [][]string{p.AllowedCIDRs, p.CorporateCIDRs} {
+		for _, c := range cidrs {
+			if _, err := netip.ParsePrefix(c); err != nil {
+				return fmt.Errorf("invalid CIDR %q: %w", c, err)
+			}
+		}
+	}
+	for _, c := range p.BlockedCountries {
+		if len(c) != 2 {
+			return fmt.Errorf("invalid country code %q", c)
+		}
+	}
+	if p.RequireMFAOutsideNetwork && len(p.CorporateCIDRs) == 0 {
+		return fmt.Errorf("requiring MFA outside the corporate network needs at least one corporate CIDR")
+	}
+	return nil
+}
+
+// Evaluate returns ReasonNone when r satisfies the policy. A nil policy allows everything.
+// An unparsable IP never matches a CIDR, so it is denied whenever a CIDR condition applies.
+func (p *Policy) Evaluate(r Request) Reason {
+	if p == nil {
+		return ReasonNone
+	}
+	ip, err := netip.ParseAddr(r.IP)
+	if err == nil {
+		ip = ip.Unmap()
+	}
+	valid := err == nil
+
+	if len(p.AllowedCIDRs) > 0 && !(valid && containsIP(p.AllowedCIDRs, ip)) {
+		return ReasonIPNotAllowed
+	}
+	// A request whose country is unknown might come from any of them, so it is denied too.
+	if len(p.BlockedCountries) > 0 && r.Country == "" {
+		return ReasonCountryUnknown
+	}
+	for _, c := range p.BlockedCountries {
+		if strings.EqualFold(c, r.Country) {
+			return ReasonCountryBlocked
+		}
+	}
+	if p.RequireMFAOutsideNetwork && !r.MFAVerified && !(valid && containsIP(p.CorporateCIDRs, ip)) {
+		return ReasonMFARequired
+	}
+	return ReasonNone
+}
+
+func containsIP(cidrs []string, ip netip.Addr) bool {
+	for _, c := range cidrs {
+		prefix, err := netip.ParsePrefix(c)
+		if err == nil && prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
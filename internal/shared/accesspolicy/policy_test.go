@@ -0,0 +1,61 @@
+package accesspolicy
+
+import "testing"
+
+func TestPolicy_Evaluate(t *testing.T) {
+	policy := &Policy{
+		AllowedCIDRs:             []string{"10.0.0.0/8", "203.0.113.0/24"},
+		BlockedCountries:         []string{"KP"},
+		CorporateCIDRs:           []string{"10.1.0.0/16"},
+		RequireMFAOutsideNetwork: true,
+	}
+
+	tests := []struct {
+		name string
+		req  Request
+		want Reason
+	}{
+		{"corporate network", Request{IP: "10.1.2.3", Country: "DE"}, ReasonNone},
+		{"allowed but outside corporate", Request{IP: "203.0.113.7", Country: "DE"}, ReasonMFARequired},
+		{"outside corporate with mfa", Request{IP: "203.0.113.7", Country: "DE", MFAVerified: true}, ReasonNone},
+		{"not in allow list", Request{IP: "198.51.100.1", Country: "DE", MFAVerified: true}, ReasonIPNotAllowed},
+		{"ipv4-mapped ipv6", Request{IP: "::ffff:10.1.2.3", Country: "DE"}, ReasonNone},
+		{"blocked country", Request{IP: "10.1.2.3", Country: "kp"}, ReasonCountryBlocked},
+		{"unknown country", Request{IP: "10.1.2.3"}, ReasonCountryUnknown},
+		{"unparsable ip", Request{IP: "not-an-ip", Country: "DE"}, ReasonIPNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Evaluate(tt.req); got != tt.want {
+				t.Errorf("Evaluate(%+v) = %q, want %q", tt.req, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_Evaluate_nilAllowsAll(t *testing.T) {
+	var policy *Policy
+	if got := policy.Evaluate(Request{IP: "198.51.100.1"}); got != ReasonNone {
+		t.Errorf("nil policy Evaluate = %q, want allowed", got)
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{"valid", Policy{AllowedCIDRs: []string{"10.0.0.0/8"}, BlockedCountries: []string{"RU"}}, false},
+		{"bare ip is not a cidr", Policy{AllowedCIDRs: []string{"10.0.0.1"}}, true},
+		{"bad country", Policy{BlockedCountries: []string{"RUS"}}, true},
+		{"mfa without corporate network", Policy{RequireMFAOutsideNetwork: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,577 @@
+// Package condition parses and evaluates the small expression language of relation tuple conditions
+// (caveats). An expression combines context comparisons and built-in functions:
+//
+//	ip_in_range("10.0.0.0/8") && time_of_day("09:00", "18:00", "Europe/Paris")
+//	department == "finance" || !(clearance < 3)
+//
+// Identifiers are read from the context the check supplies; dotted names (request.country) look up nested
+// maps, or the flat key when it exists.
+package condition
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Context is the data a condition is evaluated against.
+type Context map[string]any
+
+// Context keys the built-in functions read.
+const (
+	KeyIP   = "ip"   // client IP for ip_in_range
+	KeyTime = "time" // time.Time or RFC 3339 string for time_of_day; the current time when missing
+)
+
+// MissingContextError reports a context key the condition needs but the check did not supply.
+type MissingContextError struct {
+	Key string
+}
+
+func (e *MissingContextError) Error() string {
+	return "condition requires context: " + e.Key
+}
+
+// Expr is a parsed condition.
+type Expr struct {
+	source string
+	root   node
+}
+
+// String returns the source expression.
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Parse parses source and checks function names and argument counts.
+func Parse(source string) (*Expr, error) {
+	p := &parser{lex: lexer{src: source}}
+	p.next()
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.tok.text, p.tok.pos)
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+// Evaluate parses and evaluates source. An empty source is always true.
+func Evaluate(source string, ctx Context) (bool, error) {
+	if strings.TrimSpace(source) == "" {
+		return true, nil
+	}
+	expr, err := Parse(source)
+	if err != nil {
+		return false, err
+	}
+	return expr.Eval(ctx)
+}
+
+// Eval evaluates the condition. It returns a *MissingContextError when ctx lacks a key the result depends on.
+func (e *Expr) Eval(ctx Context) (bool, error) {
+	return e.root.eval(ctx)
+}
+
+type node interface {
+	eval(ctx Context) (bool, error)
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(ctx Context) (bool, error) {
+	ok, err := n.left.eval(ctx)
+	if err != nil || !ok {
+		return false, err
+	}
+	return n.right.eval(ctx)
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(ctx Context) (bool, error) {
+	ok, err := n.left.eval(ctx)
+	if ok {
+		return true, nil
+	}
+	right, rightErr := n.right.eval(ctx)
+	if right {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, rightErr
+}
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(ctx Context) (bool, error) {
+	ok, err := n.operand.eval(ctx)
+	return !ok && err == nil, err
+}
+
+// identNode is a bare identifier used as a boolean (e.g. mfa_verified).
+type identNode struct{ name string }
+
+func (n identNode) eval(ctx Context) (bool, error) {
+	v, err := lookup(ctx, n.name)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s is not a boolean", n.name)
+	}
+	return b, nil
+}
+
+type compareNode struct {
+	name  string
+	op    string
+	value any
+}
+
+func (n compareNode) eval(ctx Context) (bool, error) {
+	v, err := lookup(ctx, n.name)
+	if err != nil {
+		return false, err
+	}
+	switch want := n.value.(type) {
+	case string:
+		got, ok := v.(string)
+		if !ok {
+			got = fmt.Sprint(v)
+		}
+		switch n.op {
+		case "==":
+			return got == want, nil
+		case "!=":
+			return got != want, nil
+		}
+		return false, fmt.Errorf("operator %s does not apply to strings", n.op)
+	case bool:
+		got, ok := v.(bool)
+		if !ok {
+			return false, fmt.Errorf("%s is not a boolean", n.name)
+		}
+		switch n.op {
+		case "==":
+			return got == want, nil
+		case "!=":
+			return got != want, nil
+		}
+		return false, fmt.Errorf("operator %s does not apply to booleans", n.op)
+	case float64:
+		got, ok := toFloat(v)
+		if !ok {
+			return false, fmt.Errorf("%s is not a number", n.name)
+		}
+		switch n.op {
+		case "==":
+			return got == want, nil
+		case "!=":
+			return got != want, nil
+		case "<":
+			return got < want, nil
+		case "<=":
+			return got <= want, nil
+		case ">":
+			return got > want, nil
+		case ">=":
+			return got >= want, nil
+		}
+	}
+	return false, fmt.Errorf("unsupported comparison on %s", n.name)
+}
+
+type callNode struct {
+	name string
+	args []string
+}
+
+var functions = map[string]struct {
+	minArgs, maxArgs int
+	check            func(args []string) error
+	eval             func(ctx Context, args []string) (bool, error)
+}{
+	"ip_in_range": {1, -1, checkCIDRs, ipInRange},
+	"time_of_day": {2, 3, checkTimeOfDay, timeOfDay},
+}
+
+func (n callNode) eval(ctx Context) (bool, error) {
+	return functions[n.name].eval(ctx, n.args)
+}
+
+// ipInRange reports whether the context IP is in any of the CIDRs.
+func ipInRange(ctx Context, cidrs []string) (bool, error) {
+	v, err := lookup(ctx, KeyIP)
+	if err != nil {
+		return false, err
+	}
+	s, _ := v.(string)
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return false, nil
+	}
+	addr = addr.Unmap()
+	for _, c := range cidrs {
+		prefix, err := netip.ParsePrefix(c)
+		if err != nil {
+			return false, fmt.Errorf("ip_in_range: invalid CIDR %q", c)
+		}
+		if prefix.Contains(addr) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func checkCIDRs(cidrs []string) error {
+	for _, c := range cidrs {
+		if _, err := netip.ParsePrefix(c); err != nil {
+			return fmt.Errorf("ip_in_range: invalid CIDR %q", c)
+		}
+	}
+	return nil
+}
+
+func checkTimeOfDay(args []string) error {
+	for _, clock := range args[:2] {
+		if _, err := parseClock(clock); err != nil {
+			return err
+		}
+	}
+	if len(args) == 3 {
+		if _, err := time.LoadLocation(args[2]); err != nil {
+			return fmt.Errorf("time_of_day: unknown time zone %q", args[2])
+		}
+	}
+	return nil
+}
+
+// timeOfDay reports whether the context time falls in [from, to), both "HH:MM", in the optional IANA time
+// zone (UTC by default). A range where from is after to spans midnight.
+func timeOfDay(ctx Context, args []string) (bool, error) {
+	now := time.Now()
+	if v, ok := ctx[KeyTime]; ok {
+		switch t := v.(type) {
+		case time.Time:
+			now = t
+		case string:
+			parsed, err := time.Parse(time.RFC3339, t)
+			if err != nil {
+				return false, fmt.Errorf("time_of_day: context time %q is not RFC 3339", t)
+			}
+			now = parsed
+		}
+	}
+	loc := time.UTC
+	if len(args) == 3 {
+		l, err := time.LoadLocation(args[2])
+		if err != nil {
+			return false, fmt.Errorf("time_of_day: unknown time zone %q", args[2])
+		}
+		loc = l
+	}
+	from, err := parseClock(args[0])
+	if err != nil {
+		return false, err
+	}
+	to, err := parseClock(args[1])
+	if err != nil {
+		return false, err
+	}
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if from <= to {
+		return minute >= from && minute < to, nil
+	}
+	return minute >= from || minute < to, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("time_of_day: %q is not HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func lookup(ctx Context, name string) (any, error) {
+	if v, ok := ctx[name]; ok {
+		return v, nil
+	}
+	var cur any = map[string]any(ctx)
+	for _, part := range strings.Split(name, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, &MissingContextError{Key: name}
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, &MissingContextError{Key: name}
+		}
+	}
+	return cur, nil
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// IsMissingContext reports whether err is a *MissingContextError and returns its key.
+func IsMissingContext(err error) (string, bool) {
+	var missing *MissingContextError
+	if errors.As(err, &missing) {
+		return missing.Key, true
+	}
+	return "", false
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && unicode.IsSpace(rune(l.src[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case c == '"':
+		l.pos++
+		for l.pos < len(l.src) && l.src[l.pos] != '"' {
+			if l.src[l.pos] == '\\' {
+				l.pos++
+			}
+			l.pos++
+		}
+		if l.pos >= len(l.src) {
+			return token{}, fmt.Errorf("unterminated string at offset %d", start)
+		}
+		l.pos++
+		s, err := strconv.Unquote(l.src[start:l.pos])
+		if err != nil {
+			return token{}, fmt.Errorf("invalid string at offset %d", start)
+		}
+		return token{kind: tokString, text: s, pos: start}, nil
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || l.src[l.pos] == '.' ||
+			unicode.IsLetter(rune(l.src[l.pos])) || unicode.IsDigit(rune(l.src[l.pos]))) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}, nil
+	case c == '-' || unicode.IsDigit(rune(c)):
+		l.pos++
+		for l.pos < len(l.src) && (l.src[l.pos] == '.' || unicode.IsDigit(rune(l.src[l.pos]))) {
+			l.pos++
+		}
+		return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}, nil
+	}
+	for _, op := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", ","} {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokOp, text: op, pos: start}, nil
+		}
+	}
+	return token{}, fmt.Errorf("unexpected %q at offset %d", string(c), start)
+}
+
+type parser struct {
+	lex lexer
+	tok token
+	err error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lex.next()
+}
+
+func (p *parser) isOp(op string) bool {
+	return p.err == nil && p.tok.kind == tokOp && p.tok.text == op
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, p.err
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, p.err
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.isOp("!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	if p.isOp("(") {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, p.unexpected("expected )")
+		}
+		p.next()
+		return inner, p.err
+	}
+	if p.tok.kind != tokIdent {
+		return nil, p.unexpected("expected an identifier")
+	}
+	name := p.tok.text
+	p.next()
+
+	switch {
+	case p.isOp("("):
+		return p.parseCall(name)
+	case p.tok.kind == tokOp && strings.ContainsAny(p.tok.text, "=<>"):
+		op := p.tok.text
+		p.next()
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		if _, isNum := value.(float64); !isNum && op != "==" && op != "!=" {
+			return nil, fmt.Errorf("operator %s needs a number", op)
+		}
+		return compareNode{name: name, op: op, value: value}, nil
+	}
+	return identNode{name: name}, p.err
+}
+
+func (p *parser) parseCall(name string) (node, error) {
+	fn, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+	p.next() // (
+	var args []string
+	for !p.isOp(")") {
+		if p.err != nil {
+			return nil, p.err
+		}
+		if p.tok.kind != tokString {
+			return nil, p.unexpected(name + " takes string arguments")
+		}
+		args = append(args, p.tok.text)
+		p.next()
+		if p.isOp(",") {
+			p.next()
+		} else if !p.isOp(")") {
+			return nil, p.unexpected("expected , or )")
+		}
+	}
+	p.next() // )
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("%s: wrong number of arguments", name)
+	}
+	if err := fn.check(args); err != nil {
+		return nil, err
+	}
+	return callNode{name: name, args: args}, p.err
+}
+
+func (p *parser) parseLiteral() (any, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	tok := p.tok
+	p.next()
+	switch {
+	case tok.kind == tokString:
+		return tok.text, nil
+	case tok.kind == tokNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok.text)
+		}
+		return f, nil
+	case tok.kind == tokIdent && (tok.text == "true" || tok.text == "false"):
+		return tok.text == "true", nil
+	}
+	return nil, fmt.Errorf("expected a literal at offset %d", tok.pos)
+}
+
+func (p *parser) unexpected(msg string) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.tok.kind == tokEOF {
+		return fmt.Errorf("%s at end of expression", msg)
+	}
+	return fmt.Errorf("%s at offset %d, got %q", msg, p.tok.pos, p.tok.text)
+}
@@ -0,0 +1,77 @@
+package condition
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	noon := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	ctx := Context{
+		"ip":         "10.1.2.3",
+		"time":       noon.Format(time.RFC3339),
+		"department": "finance",
+		"clearance":  float64(3),
+		"mfa":        true,
+		"request":    map[string]any{"country": "VN"},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{``, true},
+		{`ip_in_range("10.0.0.0/8")`, true},
+		{`ip_in_range("192.168.0.0/16", "10.1.0.0/16")`, true},
+		{`ip_in_range("192.168.0.0/16")`, false},
+		{`time_of_day("09:00", "18:00")`, true},
+		{`time_of_day("13:00", "18:00")`, false},
+		{`time_of_day("22:00", "13:00")`, true},
+		{`time_of_day("09:00", "12:00", "Asia/Ho_Chi_Minh")`, false},
+		{`department == "finance" && clearance >= 3`, true},
+		{`department != "finance" || clearance < 3`, false},
+		{`!(clearance > 5) && mfa`, true},
+		{`mfa == false`, false},
+		{`request.country == "VN"`, true},
+		{`missing == "x" || department == "finance"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := Evaluate(tt.expr, ctx)
+			if err != nil || got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, %v; want %v", tt.expr, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_missingContext(t *testing.T) {
+	for _, expr := range []string{`ip_in_range("10.0.0.0/8")`, `department == "finance"`, `request.team == "x"`} {
+		_, err := Evaluate(expr, Context{"request": map[string]any{}})
+		if _, ok := IsMissingContext(err); !ok {
+			t.Errorf("Evaluate(%q) error = %v, want missing context", expr, err)
+		}
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	for _, expr := range []string{
+		`unknown_fn("x")`,
+		`ip_in_range()`,
+		`time_of_day("09:00")`,
+		`department ==`,
+		`department < "x"`,
+		`(mfa`,
+		`mfa &&`,
+		`"unterminated`,
+		`mfa mfa`,
+		`ip_in_range(10)`,
+		`ip_in_range("10.0.0.0/33")`,
+		`time_of_day("9am", "18:00")`,
+		`time_of_day("09:00", "18:00", "Mars/Olympus")`,
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) = nil error", expr)
+		}
+	}
+}
@@ -1,10 +1,129 @@
 package constant
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
-// RefreshStateTTL is how long a Google OAuth refresh state is valid in cache.
+// RefreshStateTTL is how long a sealed OAuth state or refresh state stays valid.
 const RefreshStateTTL = 10 * time.Minute
 
+// What an external login's refreshState is bound to when the login sent no PKCE codeChallenge
+// (OAUTH_STATE_BINDING). Only the client that started the login can then exchange the state.
+const (
+	OAuthStateBindingUserAgent = "user-agent" // the default
+	OAuthStateBindingIP        = "ip"         // the client IP and the user agent
+	OAuthStateBindingNone      = "none"
+)
+
+// MFAChallengeTTL is how long a login MFA challenge token stays valid.
+const MFAChallengeTTL = 5 * time.Minute
+
+// MFAMaxAttempts is how many codes may be tried against one challenge.
+const MFAMaxAttempts = 5
+
+// MagicLinkTTL is how long an emailed sign-in link stays valid.
+const MagicLinkTTL = 15 * time.Minute
+
+// Anomalies a login can be flagged with when compared to the user's recent sessions.
+const (
+	LoginAnomalyNewDevice   = "new_device"
+	LoginAnomalyNewLocation = "new_location"
+)
+
+// IdentityLinkTTL is how long a user has to confirm linking an external login to their existing account.
+const IdentityLinkTTL = 15 * time.Minute
+
+// MagicLinkMaxSends is how many links may be requested per email address within MagicLinkTTL.
+const MagicLinkMaxSends = 5
+
+// PhoneOTPTTL is how long an SMS login code stays valid.
+const PhoneOTPTTL = 5 * time.Minute
+
+// PhoneOTPResendCooldown is the minimum wait between two codes sent to the same number.
+const PhoneOTPResendCooldown = time.Minute
+
+// PhoneOTPMaxSends is how many codes may be sent to one number within PhoneOTPSendWindow.
+const PhoneOTPMaxSends = 5
+
+// PhoneOTPSendWindow is the window PhoneOTPMaxSends applies to.
+const PhoneOTPSendWindow = time.Hour
+
+// PhoneOTPMaxAttempts is how many codes may be tried against one sent code.
+const PhoneOTPMaxAttempts = 5
+
+// RevocationNegativeCacheTTL is how long a "not revoked" lookup is cached before the database is asked again.
+// Revoking writes the cache directly, so this only bounds how long a lost cache entry goes unnoticed.
+const RevocationNegativeCacheTTL = time.Minute
+
+// APIKeyLastUsedInterval is how stale an API key's recorded last use may get before a request updates it.
+const APIKeyLastUsedInterval = time.Minute
+
+// ServiceAccountTokenTTL is how long a client_credentials access token stays valid. Service accounts
+// have no refresh tokens, so deleting one cuts off its callers within this window.
+const ServiceAccountTokenTTL = 15 * time.Minute
+
+// ImpersonationTTL is how long an impersonation token stays valid. It has no refresh token, so the
+// impersonation cannot be extended without asking again.
+const ImpersonationTTL = 15 * time.Minute
+
+// Session stats cover the last DefaultSessionStatsWindow unless a range is given, and at most
+// MaxSessionStatsWindow.
+const (
+	DefaultSessionStatsWindow = 30 * 24 * time.Hour
+	MaxSessionStatsWindow     = 366 * 24 * time.Hour
+)
+
+// DefaultCleanupInterval is how often the cleanup jobs run when CLEANUP_INTERVAL_MINUTES is unset.
+const DefaultCleanupInterval = time.Hour
+
+// DefaultSessionRetention is how long expired or ended sessions are kept when SESSION_RETENTION_DAYS is unset.
+const DefaultSessionRetention = 7 * 24 * time.Hour
+
+// UserImportJobTTL is how long the status of a bulk user import stays readable after its last update.
+const UserImportJobTTL = 24 * time.Hour
+
+// UserImportMaxBytes caps the size of a bulk user import file.
+const UserImportMaxBytes = 64 << 20
+
+// UserImportMaxErrors is how many rejected records an import job reports in detail.
+const UserImportMaxErrors = 100
+
+// UserExportBatchSize is how many users an export reads from the database at a time.
+const UserExportBatchSize = 500
+
+// DefaultProjectRetention is how long archived projects are kept when PROJECT_RETENTION_DAYS is unset.
+const DefaultProjectRetention = 30 * 24 * time.Hour
+
+// DefaultErasedAuditRetention is how long the audit records of an erased account are kept when
+// ERASED_AUDIT_RETENTION_DAYS is unset.
+const DefaultErasedAuditRetention = 90 * 24 * time.Hour
+
+// ErasedUserEmailDomain is the reserved domain of the placeholder email an erased account is left with.
+const ErasedUserEmailDomain = "erased.invalid"
+
+// SigningKeyActivationDelay is how long a rotated JWT key is only published before it signs tokens,
+// so every replica and JWKS consumer knows it by the time tokens carrying its kid appear.
+const SigningKeyActivationDelay = 10 * time.Minute
+
+// SigningKeyReloadInterval is how often replicas reload managed signing keys from the database.
+const SigningKeyReloadInterval = time.Minute
+
+// SAMLRequestTTL is how long the IdP has to answer a SAML AuthnRequest.
+const SAMLRequestTTL = 10 * time.Minute
+
+// OIDCAuthRequestTTL is how long the user has to sign in and approve an OIDC authorization request.
+const OIDCAuthRequestTTL = 10 * time.Minute
+
+// OIDCAuthCodeTTL is how long an authorization code may wait to be exchanged at the token endpoint.
+const OIDCAuthCodeTTL = time.Minute
+
+// DeviceCodeTTL is how long the user has to enter and approve a device authorization's user code.
+const DeviceCodeTTL = 10 * time.Minute
+
+// DeviceCodePollInterval is how long a device must wait between polls of /auth/device/token.
+const DeviceCodePollInterval = 5 * time.Second
+
 type UserStatus string
 
 const (
@@ -18,6 +137,68 @@ func (s UserStatus) String() string {
 	return string(s)
 }
 
+// Normalize returns s in upper case. Rows created before statuses were set explicitly carry the column
+// default "active" or no status at all; both are ACTIVE.
+func (s UserStatus) Normalize() UserStatus {
+	if s == "" {
+		return UserStatusActive
+	}
+	return UserStatus(strings.ToUpper(string(s)))
+}
+
+// CanTransitionTo reports whether an admin may move a user from status s to next. Blocked users must be
+// reactivated before they can be deactivated; pending users may move to any status.
+func (s UserStatus) CanTransitionTo(next UserStatus) bool {
+	switch s {
+	case UserStatusBlocked:
+		return next == UserStatusActive
+	case UserStatusActive, UserStatusInactive, UserStatusPending:
+		return next == UserStatusActive || next == UserStatusInactive || next == UserStatusBlocked
+	}
+	return false
+}
+
+// File formats of bulk user imports and exports.
+const (
+	UserTransferCSV    = "csv"
+	UserTransferNDJSON = "ndjson"
+)
+
+// Bulk user import job statuses.
+const (
+	UserImportRunning   = "running"
+	UserImportCompleted = "completed"
+	UserImportFailed    = "failed"
+)
+
+// UserAttributeType is the JSON type a project's schema requires of a user attribute.
+type UserAttributeType string
+
+const (
+	UserAttributeString  UserAttributeType = "string"
+	UserAttributeNumber  UserAttributeType = "number"
+	UserAttributeBoolean UserAttributeType = "boolean"
+	UserAttributeObject  UserAttributeType = "object"
+	UserAttributeArray   UserAttributeType = "array"
+)
+
+// Accepts reports whether a value decoded from JSON has type t.
+func (t UserAttributeType) Accepts(value any) bool {
+	switch value.(type) {
+	case string:
+		return t == UserAttributeString
+	case float64:
+		return t == UserAttributeNumber
+	case bool:
+		return t == UserAttributeBoolean
+	case map[string]any:
+		return t == UserAttributeObject
+	case []any:
+		return t == UserAttributeArray
+	}
+	return false
+}
+
 type UserAuthType string
 
 const (
@@ -26,6 +207,11 @@ const (
 	UserAuthTypeGoogle     UserAuthType = "GOOGLE"
 	UserAuthTypeFacebook   UserAuthType = "FACEBOOK"
 	UserAuthTypeApple      UserAuthType = "APPLE"
+	UserAuthTypeOIDC       UserAuthType = "OIDC"
+	UserAuthTypeSAML       UserAuthType = "SAML"
+	UserAuthTypeMagicLink  UserAuthType = "MAGIC_LINK"
+	UserAuthTypePhoneOTP   UserAuthType = "PHONE_OTP"
+	UserAuthTypeSCIM       UserAuthType = "SCIM" // provisioned by a project's identity provider
 )
 
 func (a UserAuthType) String() string {
@@ -43,6 +229,15 @@ const (
 	ContextKeyClientIP  ContextKey = "ip"
 	ContextKeyUserAgent ContextKey = "user_agent"
 	ContextKeyReferer   ContextKey = "referer"
+	ContextKeyCountry   ContextKey = "country"
+
+	// Low-entropy User-Agent client hints, sent by Chromium browsers with every secure request
+	ContextKeyClientHintBrands   ContextKey = "sec_ch_ua"
+	ContextKeyClientHintPlatform ContextKey = "sec_ch_ua_platform"
+	ContextKeyClientHintMobile   ContextKey = "sec_ch_ua_mobile"
+
+	// The UserAuthType of the login being completed, recorded in the metadata of the session it starts
+	ContextKeyLoginMethod ContextKey = "login_method"
 )
 
 // Role codes for system roles
@@ -53,3 +248,22 @@ const (
 )
 
 const SystemProjectID = "system"
+
+// CredentialType identifies the kind of authenticator stored for a user.
+type CredentialType string
+
+const (
+	CredentialTypeWebAuthn CredentialType = "WEBAUTHN"
+	CredentialTypeTOTP     CredentialType = "TOTP"
+)
+
+func (t CredentialType) String() string {
+	return string(t)
+}
+
+// Stages at which a project access policy is enforced, recorded on denials.
+const (
+	AccessStageLogin   = "LOGIN"
+	AccessStageRefresh = "REFRESH"
+	AccessStageToken   = "TOKEN"
+)
@@ -0,0 +1,17 @@
+package constant
+
+import "time"
+
+const (
+	// BreakGlassMinJustificationLength forces a real explanation rather than "test" or "urgent".
+	BreakGlassMinJustificationLength = 20
+	// BreakGlassDefaultSessionTTL and BreakGlassMaxSessionTTL bound the emergency session.
+	BreakGlassDefaultSessionTTL = 30 * time.Minute
+	BreakGlassMaxSessionTTL     = 4 * time.Hour
+)
+
+// Break-glass activation outcomes recorded in the audit log.
+const (
+	BreakGlassOutcomeGranted  = "GRANTED"
+	BreakGlassOutcomeRejected = "REJECTED"
+)
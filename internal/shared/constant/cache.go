@@ -8,4 +8,40 @@ const (
 
 	// Cache key prefixes
 	CacheKeyPrefixRelationTuple = "relation_tuples:"
+	CacheKeyPrefixRelationDeny  = "relation_denied:"
+	CacheKeyPrefixRelationStamp = "relation_changed:"
+	CacheKeyPrefixMFAChallenge  = "mfa_challenge:"
+	CacheKeyPrefixSAMLRequest   = "saml_request:"
+	CacheKeyPrefixMagicLink     = "magic_link:"
+	CacheKeyPrefixMagicLinkSend = "magic_link_send:"
+	CacheKeyPrefixPhoneOTP      = "phone_otp:"
+	CacheKeyPrefixPhoneOTPTry   = "phone_otp_try:"
+	CacheKeyPrefixPhoneOTPWait  = "phone_otp_wait:"
+	CacheKeyPrefixPhoneOTPSend  = "phone_otp_send:"
+	CacheKeyPrefixRevokedToken  = "revoked_token:"
+	CacheKeyPrefixEndedSession  = "ended_session:"
+	CacheKeyPrefixOIDCAuthCode  = "oidc_code:"
+	CacheKeyPrefixDeviceCode    = "device_code:"
+	CacheKeyPrefixUserCode      = "device_user_code:"
+	CacheKeyPrefixDevicePoll    = "device_poll:"
+	CacheKeyPrefixIdentityLink  = "identity_link:"
+	CacheKeyPrefixUserImport    = "user_import:"
+	CacheKeyPrefixRateLimit     = "rate_limit:"
+	CacheKeyPrefixAuthFailures  = "auth_failures:"
+	CacheKeyPrefixIPFailures    = "ip_failures:"
+	CacheKeyPrefixIPBan         = "ip_ban:"
+
+	// CacheKeyPermissionCatalog holds the codes of the permission catalog, grouped by owning project
+	CacheKeyPermissionCatalog = "permission_catalog"
+
+	// CacheKeyIPBlocks holds the IP denylist
+	CacheKeyIPBlocks = "ip_blocks"
+	// CacheKeyIPBans is a sorted set of the banned IPs, scored by when their ban ends
+	CacheKeyIPBans = "ip_bans"
+)
+
+// Invalidation bus channels (cache.ICache.Broadcast)
+const (
+	// CacheChannelSigningKeys announces a rotated or retired signing key, so every replica reloads its keys
+	CacheChannelSigningKeys = "signing_keys"
 )
@@ -0,0 +1,27 @@
+package constant
+
+import "time"
+
+// DefaultConfigReloadInterval is how often the .env and permissions files are checked for changes when
+// CONFIG_RELOAD_INTERVAL_SEC is unset.
+const DefaultConfigReloadInterval = 10 * time.Second
+
+// Defaults for CAPTCHA_FAILURE_THRESHOLD and CAPTCHA_FAILURE_WINDOW_SEC: sign-in and registration need a
+// captcha after this many failures from the same IP or account within the window.
+const (
+	DefaultCaptchaFailureThreshold = 5
+	DefaultCaptchaFailureWindow    = 15 * time.Minute
+)
+
+// Defaults for IP_BAN_WINDOW_SEC and IP_BAN_DURATION_SEC.
+const (
+	DefaultIPBanWindow   = 10 * time.Minute
+	DefaultIPBanDuration = time.Hour
+)
+
+// MaxIPBansListed caps how many IP bans GET /ip-bans returns.
+const MaxIPBansListed = 1000
+
+// DefaultLoginAnomalyHistory is how many of the user's recent sessions a login is compared with when
+// LOGIN_ANOMALY_HISTORY_SIZE is unset.
+const DefaultLoginAnomalyHistory = 20
@@ -0,0 +1,15 @@
+package constant
+
+// Modes of EFFECTIVE_ACCESS_MODE, the materialized effective access table. Enable it in two steps: maintain
+// while `dreonctl effective-access rebuild` fills the table, then serve.
+const (
+	EffectiveAccessOff      = "off"      // the default: the table is neither written nor read
+	EffectiveAccessMaintain = "maintain" // role and relation changes keep the table current, checks ignore it
+	EffectiveAccessServe    = "serve"    // maintained, and read by relation checks and user permission lookups
+)
+
+// Kinds of effective access rows.
+const (
+	EffectiveAccessPermission = "permission" // a permission code a user holds through a role assignment
+	EffectiveAccessRelation   = "relation"   // a relation a subject holds through stored tuples
+)
@@ -0,0 +1,24 @@
+package constant
+
+// Domain event types published to the event bus (see pkg/eventbus).
+const (
+	EventUserRegistered   = "user.registered" // self-service sign-up
+	EventUserCreated      = "user.created"    // created through the admin API
+	EventUserUpdated      = "user.updated"
+	EventUserDeleted      = "user.deleted"
+	EventUserRestored     = "user.restored"
+	EventUserStatus       = "user.status_changed"
+	EventUserImported     = "user.import_finished" // one per bulk import job, not per user
+	EventUserErased       = "user.erased"          // the user erased their own account
+	EventUserImpersonated = "user.impersonated"    // a super admin signed in as the user
+	EventSessionEnded     = "session.ended"
+	EventLoginSuspicious  = "login.suspicious" // a login from a new device or location
+	EventIdentityLinked   = "user.identity_linked"
+	EventIdentityUnlinked = "user.identity_unlinked"
+	EventRoleAssigned     = "role.assigned"
+	EventRoleRemoved      = "role.removed"
+	EventMemberInvited    = "project.member_invited"
+	EventMemberJoined     = "project.member_joined"
+	EventMemberRemoved    = "project.member_removed"
+	EventIPBanned         = "ip.banned" // a client IP failed sign-in too often and was banned
+)
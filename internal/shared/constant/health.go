@@ -0,0 +1,27 @@
+package constant
+
+import "time"
+
+// Health statuses reported by /healthz and /readyz, overall and per dependency.
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+	// HealthStatusStarting and HealthStatusStopping mark an instance that must not get traffic yet, or any more.
+	HealthStatusStarting = "starting"
+	HealthStatusStopping = "stopping"
+)
+
+// Dependencies checked by the readiness probe.
+const (
+	HealthCheckPostgres = "postgres"
+	HealthCheckRedis    = "redis"
+)
+
+// DefaultShutdownTimeout bounds each shutdown step when SHUTDOWN_TIMEOUT_SEC is unset.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// AppStopTimeout bounds the whole shutdown, every step included.
+const AppStopTimeout = 2 * time.Minute
+
+// DefaultHealthCheckTimeout bounds each dependency check when HEALTH_CHECK_TIMEOUT_MS is unset.
+const DefaultHealthCheckTimeout = time.Second
@@ -0,0 +1,19 @@
+package constant
+
+// NotificationChannel is how a notification reaches the user.
+type NotificationChannel string
+
+const (
+	NotificationChannelEmail NotificationChannel = "email"
+	NotificationChannelSMS   NotificationChannel = "sms"
+)
+
+// Notifications a project can replace with its own template. The email ones share their names with the
+// built-in templates of pkg/mailer.
+const (
+	NotificationMagicLink     = "magic_link"
+	NotificationVerification  = "verification"
+	NotificationPasswordReset = "password_reset"
+	NotificationSecurityAlert = "security_alert"
+	NotificationPhoneOTP      = "phone_otp"
+)
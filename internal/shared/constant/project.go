@@ -0,0 +1,23 @@
+package constant
+
+// Project membership statuses.
+const (
+	ProjectMemberInvited = "invited" // waiting for the user to accept
+	ProjectMemberActive  = "active"
+)
+
+// Kinds of project requests metered against the project quota.
+const (
+	ProjectUsageLogin           = "login"
+	ProjectUsageTokenValidation = "token_validation"
+	ProjectUsageRelationCheck   = "relation_check"
+)
+
+// BreachedPasswordPolicy is what happens to a new password found in a known data breach.
+type BreachedPasswordPolicy string
+
+const (
+	BreachedPasswordOff    BreachedPasswordPolicy = "off"
+	BreachedPasswordWarn   BreachedPasswordPolicy = "warn"   // accepted, with a warning in the response
+	BreachedPasswordReject BreachedPasswordPolicy = "reject" // refused with ErrPasswordBreached
+)
@@ -0,0 +1,16 @@
+package constant
+
+// Routes with their own rate limit policy (RATE_LIMIT_*).
+const (
+	RateLimitRouteLogin    = "login"
+	RateLimitRouteRegister = "register"
+	RateLimitRouteOTP      = "otp"
+)
+
+// Identities a rate limit policy can set a limit for. A caller is counted as their API key, else their
+// user, else their IP.
+const (
+	RateLimitIdentityIP     = "ip"
+	RateLimitIdentityUser   = "user"
+	RateLimitIdentityAPIKey = "apikey"
+)
@@ -7,6 +7,21 @@ const (
 )
 
 const (
-	RelationNamespaceSystem = "system"
-	RelationNamespaceUser   = "user"
+	RelationNamespaceSystem  = "system"
+	RelationNamespaceUser    = "user"
+	RelationNamespaceProject = "project" // relations on it require the user subject to be a project member
+)
+
+// Conflict strategies for relation imports, applied when a tuple being imported is already stored and active.
+const (
+	RelationImportSkip      = "skip"      // keep the stored tuple
+	RelationImportOverwrite = "overwrite" // replace its condition and expiry with the imported ones
+	RelationImportFail      = "fail"      // reject the whole import
+)
+
+// Outcomes of an item in a bulk grant.
+const (
+	RelationGrantCreated = "created"
+	RelationGrantSkipped = "skipped"
+	RelationGrantError   = "error"
 )
@@ -0,0 +1,25 @@
+package constant
+
+// Outcomes of an item in a bulk role assignment or removal.
+const (
+	RoleAssignmentAssigned = "assigned"
+	RoleAssignmentRemoved  = "removed"
+	RoleAssignmentSkipped  = "skipped" // already assigned, or not assigned when removing
+	RoleAssignmentError    = "error"
+)
+
+// Changes recorded in a role's history.
+const (
+	RoleHistoryCreate = "create"
+	RoleHistoryUpdate = "update"
+	RoleHistoryDelete = "delete"
+	RoleHistoryAssign = "assign"
+	RoleHistoryRemove = "remove"
+)
+
+// Kinds of caller recorded as the actor of a role change.
+const (
+	ActorTypeUser           = "user"
+	ActorTypeAPIKey         = "api_key"
+	ActorTypeServiceAccount = "service_account"
+)
@@ -5,6 +5,8 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"fmt"
+	"math/big"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -39,6 +41,16 @@ func GenerateRefreshToken() (string, error) {
 	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
+// GenerateNumericCode returns a uniformly random code of n decimal digits, e.g. for SMS one-time codes.
+func GenerateNumericCode(n int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+	v, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", n, v), nil
+}
+
 // HashRefreshToken returns a SHA256 hex digest of the token for storage and lookup.
 // Use this when storing the refresh token in the session table and when looking up by token.
 func HashRefreshToken(token string) string {
@@ -71,6 +71,23 @@ func TestGenerateRefreshToken(t *testing.T) {
 	}
 }
 
+func TestGenerateNumericCode(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		code, err := GenerateNumericCode(6)
+		if err != nil {
+			t.Fatalf("GenerateNumericCode() err = %v", err)
+		}
+		if len(code) != 6 {
+			t.Fatalf("GenerateNumericCode(6) = %q, want 6 digits", code)
+		}
+		for _, r := range code {
+			if r < '0' || r > '9' {
+				t.Fatalf("GenerateNumericCode(6) = %q, want digits only", code)
+			}
+		}
+	}
+}
+
 func TestHashRefreshToken(t *testing.T) {
 	input := "my-refresh-token"
 	got := HashRefreshToken(input)
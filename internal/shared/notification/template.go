@@ -0,0 +1,88 @@
+// Package notification checks and fills in the templates projects write for their emails and SMS.
+// Templates are plain text with {{variable}} placeholders; unlike the built-in templates of pkg/mailer
+// they cannot run code, so they are safe to accept from tenants.
+package notification
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+)
+
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z][A-Za-z0-9_]*)\s*\}\}`)
+
+// Variables lists the placeholders each customizable notification offers, by channel and name.
+var Variables = map[constant.NotificationChannel]map[string][]string{
+	constant.NotificationChannelEmail: {
+		constant.NotificationMagicLink:     {"appName", "email", "intro", "link", "expiresInMinutes"},
+		constant.NotificationVerification:  {"appName", "email", "intro", "link", "expiresInMinutes"},
+		constant.NotificationPasswordReset: {"appName", "email", "intro", "link", "expiresInMinutes"},
+		constant.NotificationSecurityAlert: {"appName", "email", "intro", "link", "event", "time", "device", "ip", "location"},
+	},
+	constant.NotificationChannelSMS: {
+		constant.NotificationPhoneOTP: {"appName", "code", "expiresInMinutes"},
+	},
+}
+
+// Supports reports whether name is a notification of the channel.
+func Supports(channel constant.NotificationChannel, name string) bool {
+	_, ok := Variables[channel][name]
+	return ok
+}
+
+// Check returns an error for the first placeholder in texts that the notification does not offer, and for
+// braces that do not form a placeholder.
+func Check(channel constant.NotificationChannel, name string, texts ...string) error {
+	allowed, ok := Variables[channel][name]
+	if !ok {
+		return fmt.Errorf("unknown %s notification %q", channel, name)
+	}
+	for _, text := range texts {
+		for _, match := range placeholder.FindAllStringSubmatch(text, -1) {
+			if !slices.Contains(allowed, match[1]) {
+				return fmt.Errorf("unknown variable %q; %s offers %s", match[1], name, strings.Join(allowed, ", "))
+			}
+		}
+		if strings.Contains(placeholder.ReplaceAllString(text, ""), "{{") {
+			return fmt.Errorf("malformed placeholder; use {{variable}}")
+		}
+	}
+	return nil
+}
+
+// Interpolate replaces the placeholders of text with their values in vars; placeholders without a value
+// become empty. With escape set the values are HTML-escaped, for HTML bodies.
+func Interpolate(text string, vars map[string]string, escape bool) string {
+	return placeholder.ReplaceAllStringFunc(text, func(match string) string {
+		value := vars[placeholder.FindStringSubmatch(match)[1]]
+		if escape {
+			return html.EscapeString(value)
+		}
+		return value
+	})
+}
+
+// NormalizeLocale lower-cases a BCP 47 tag and accepts "_" as the separator, so "pt_BR" and "pt-br" are the same.
+func NormalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// LocaleFallbacks returns the locales to look a template up by, most specific first: the locale itself, each
+// shorter prefix of it, and "" for the project's default. "pt-BR" gives "pt-br", "pt" and "".
+func LocaleFallbacks(locale string) []string {
+	locale = NormalizeLocale(locale)
+	var fallbacks []string
+	for locale != "" {
+		fallbacks = append(fallbacks, locale)
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return append(fallbacks, "")
+}
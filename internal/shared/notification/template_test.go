@@ -0,0 +1,58 @@
+package notification
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+)
+
+func TestCheck(t *testing.T) {
+	email, sms := constant.NotificationChannelEmail, constant.NotificationChannelSMS
+	tests := []struct {
+		channel constant.NotificationChannel
+		name    string
+		texts   []string
+		wantErr bool
+	}{
+		{email, constant.NotificationMagicLink, []string{"Sign in to {{appName}}", "{{ intro }} {{link}}"}, false},
+		{email, constant.NotificationMagicLink, []string{"No placeholders at all"}, false},
+		{sms, constant.NotificationPhoneOTP, []string{"{{code}} is your {{appName}} code"}, false},
+		{sms, constant.NotificationPhoneOTP, []string{"{{link}}"}, true},
+		{email, constant.NotificationMagicLink, []string{"ok", "{{ code }}"}, true},
+		{email, constant.NotificationMagicLink, []string{"{{link"}, true},
+		{email, constant.NotificationMagicLink, []string{"{{ 1link }}"}, true},
+		{email, constant.NotificationPhoneOTP, nil, true},
+		{sms, constant.NotificationMagicLink, nil, true},
+	}
+	for _, tt := range tests {
+		err := Check(tt.channel, tt.name, tt.texts...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Check(%s, %s, %q) = %v, want error %v", tt.channel, tt.name, tt.texts, err, tt.wantErr)
+		}
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	vars := map[string]string{"appName": "Acme & Co", "link": "https://x.test/?a=1&b=2"}
+	if got := Interpolate("Sign in to {{appName}}: {{ link }}{{missing}}", vars, false); got != "Sign in to Acme & Co: https://x.test/?a=1&b=2" {
+		t.Errorf("Interpolate() = %q", got)
+	}
+	if got := Interpolate(`<a href="{{link}}">{{appName}}</a>`, vars, true); got != `<a href="https://x.test/?a=1&amp;b=2">Acme &amp; Co</a>` {
+		t.Errorf("Interpolate(escape) = %q", got)
+	}
+}
+
+func TestLocaleFallbacks(t *testing.T) {
+	tests := map[string][]string{
+		"":           {""},
+		"vi":         {"vi", ""},
+		"pt_BR":      {"pt-br", "pt", ""},
+		"zh-Hant-TW": {"zh-hant-tw", "zh-hant", "zh", ""},
+	}
+	for locale, want := range tests {
+		if got := LocaleFallbacks(locale); !slices.Equal(got, want) {
+			t.Errorf("LocaleFallbacks(%q) = %q, want %q", locale, got, want)
+		}
+	}
+}
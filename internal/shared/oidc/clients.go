@@ -0,0 +1,134 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/hiamthach108/dreon-auth/config"
+)
+
+// BackchannelLogoutEvent is the event key required in OIDC back-channel logout tokens.
+const BackchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// Client is a relying party registered for logout propagation and, when it has redirect URIs,
+// for signing users in through the authorization code flow.
+type Client struct {
+	ClientID               string   `json:"clientId"`
+	ClientSecret           string   `json:"clientSecret"`           // empty for public clients, which must use PKCE
+	ProjectID              string   `json:"projectId"`              // project users sign in to; its access policy applies
+	RedirectURIs           []string `json:"redirectUris"`           // allowed redirect_uri values for /oauth2/authorize
+	BackchannelLogoutURI   string   `json:"backchannelLogoutUri"`   // receives logout_token POSTs when a session ends
+	PostLogoutRedirectURIs []string `json:"postLogoutRedirectUris"` // allowed targets for RP-initiated logout
+}
+
+// IClientRegistry is the interface for registered OIDC clients
+type IClientRegistry interface {
+	List() []Client
+	Get(clientID string) (Client, bool)
+	IsRedirectAllowed(clientID, uri string) bool
+	IsPostLogoutRedirectAllowed(clientID, uri string) bool
+}
+
+// ClientRegistry holds registered clients keyed by client ID
+type ClientRegistry struct {
+	list []Client
+	byID map[string]Client
+}
+
+// NewClientRegistry loads clients from a JSON file and returns a ClientRegistry
+func NewClientRegistry(path string) (*ClientRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read oidc clients config: %w", err)
+	}
+
+	var list []Client
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parse oidc clients config: %w", err)
+	}
+	return NewClientRegistryFromList(list), nil
+}
+
+// NewClientRegistryFromList returns a ClientRegistry over list; clients without an ID are ignored.
+func NewClientRegistryFromList(list []Client) *ClientRegistry {
+	byID := make(map[string]Client, len(list))
+	for _, c := range list {
+		if c.ClientID == "" {
+			continue
+		}
+		byID[c.ClientID] = c
+	}
+	return &ClientRegistry{list: list, byID: byID}
+}
+
+// List returns all registered clients
+func (r *ClientRegistry) List() []Client {
+	if r == nil {
+		return nil
+	}
+	return r.list
+}
+
+// Get returns the client registered as clientID
+func (r *ClientRegistry) Get(clientID string) (Client, bool) {
+	if r == nil {
+		return Client{}, false
+	}
+	c, ok := r.byID[clientID]
+	return c, ok
+}
+
+// IsRedirectAllowed reports whether uri exactly matches a redirect URI registered for clientID.
+func (r *ClientRegistry) IsRedirectAllowed(clientID, uri string) bool {
+	c, ok := r.Get(clientID)
+	if !ok || uri == "" {
+		return false
+	}
+	for _, allowed := range c.RedirectURIs {
+		if allowed == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPostLogoutRedirectAllowed reports whether uri is registered for clientID,
+// or for any client when clientID is empty.
+func (r *ClientRegistry) IsPostLogoutRedirectAllowed(clientID, uri string) bool {
+	if r == nil || uri == "" {
+		return false
+	}
+	candidates := r.list
+	if clientID != "" {
+		c, ok := r.byID[clientID]
+		if !ok {
+			return false
+		}
+		candidates = []Client{c}
+	}
+	for _, c := range candidates {
+		for _, allowed := range c.PostLogoutRedirectURIs {
+			if allowed == uri {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+const defaultClientsPath = "config/oidc_clients.json"
+
+// NewClientRegistryFromConfig loads clients from AppConfig.OIDC.ClientsFile (env: OIDC_CLIENTS_FILE),
+// or default config/oidc_clients.json. A missing default file yields an empty registry.
+func NewClientRegistryFromConfig(cfg *config.AppConfig) (*ClientRegistry, error) {
+	path := cfg.OIDC.ClientsFile
+	if path == "" {
+		path = defaultClientsPath
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return &ClientRegistry{}, nil
+		}
+	}
+	return NewClientRegistry(path)
+}
@@ -0,0 +1,75 @@
+package oidc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewClientRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clients.json")
+	err := os.WriteFile(path, []byte(`[
+		{"clientId": "web", "backchannelLogoutUri": "https://web.example.com/logout", "postLogoutRedirectUris": ["https://web.example.com/"]},
+		{"clientId": "admin", "postLogoutRedirectUris": ["https://admin.example.com/bye"]}
+	]`), 0644)
+	if err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+
+	r, err := NewClientRegistry(path)
+	if err != nil {
+		t.Fatalf("NewClientRegistry() err = %v", err)
+	}
+	if len(r.List()) != 2 {
+		t.Fatalf("List() len = %d, want 2", len(r.List()))
+	}
+
+	tests := []struct {
+		clientID, uri string
+		want          bool
+	}{
+		{"web", "https://web.example.com/", true},
+		{"web", "https://admin.example.com/bye", false},
+		{"", "https://admin.example.com/bye", true},
+		{"", "https://evil.example.com/", false},
+		{"unknown", "https://web.example.com/", false},
+		{"web", "", false},
+	}
+	for _, tt := range tests {
+		if got := r.IsPostLogoutRedirectAllowed(tt.clientID, tt.uri); got != tt.want {
+			t.Errorf("IsPostLogoutRedirectAllowed(%q, %q) = %v, want %v", tt.clientID, tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestClientRegistry_IsRedirectAllowed(t *testing.T) {
+	r := NewClientRegistryFromList([]Client{
+		{ClientID: "web", RedirectURIs: []string{"https://web.example.com/callback"}},
+		{ClientID: "logout-only"},
+	})
+	tests := []struct {
+		clientID, uri string
+		want          bool
+	}{
+		{"web", "https://web.example.com/callback", true},
+		{"web", "https://web.example.com/callback?x=1", false},
+		{"web", "https://web.example.com/", false},
+		{"logout-only", "https://web.example.com/callback", false},
+		{"", "https://web.example.com/callback", false},
+	}
+	for _, tt := range tests {
+		if got := r.IsRedirectAllowed(tt.clientID, tt.uri); got != tt.want {
+			t.Errorf("IsRedirectAllowed(%q, %q) = %v, want %v", tt.clientID, tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestClientRegistry_Nil(t *testing.T) {
+	var r *ClientRegistry
+	if r.List() != nil || r.IsPostLogoutRedirectAllowed("", "https://x") || r.IsRedirectAllowed("web", "https://x") {
+		t.Error("nil registry should be empty")
+	}
+	if _, ok := r.Get("web"); ok {
+		t.Error("nil registry Get() ok = true")
+	}
+}
@@ -0,0 +1,75 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+// CodeChallengeMethodS256 is the only PKCE method accepted; "plain" offers no protection.
+const CodeChallengeMethodS256 = "S256"
+
+// OAuth 2.0 error codes (RFC 6749 sections 4.1.2.1 and 5.2).
+const (
+	ErrCodeInvalidRequest          = "invalid_request"
+	ErrCodeInvalidClient           = "invalid_client"
+	ErrCodeInvalidGrant            = "invalid_grant"
+	ErrCodeInvalidScope            = "invalid_scope"
+	ErrCodeUnsupportedGrantType    = "unsupported_grant_type"
+	ErrCodeUnsupportedResponseType = "unsupported_response_type"
+)
+
+// Device authorization grant error codes (RFC 8628 section 3.5).
+const (
+	ErrCodeAuthorizationPending = "authorization_pending"
+	ErrCodeSlowDown             = "slow_down"
+	ErrCodeAccessDenied         = "access_denied"
+	ErrCodeExpiredToken         = "expired_token"
+)
+
+// Error is an OAuth 2.0 error response. Token endpoint errors are returned as JSON with Status;
+// authorization errors are sent back to the client's redirect_uri.
+type Error struct {
+	Status      int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// NewError returns an Error with status 400, or 401 for invalid_client.
+func NewError(code, description string) *Error {
+	status := http.StatusBadRequest
+	if code == ErrCodeInvalidClient {
+		status = http.StatusUnauthorized
+	}
+	return &Error{Status: status, Code: code, Description: description}
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// IDTokenClaims are the claims of an ID token issued to a registered client.
+type IDTokenClaims struct {
+	gojwt.RegisteredClaims
+	Nonce             string `json:"nonce,omitempty"`
+	SessionID         string `json:"sid,omitempty"`
+	Email             string `json:"email,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	PhoneNumber       string `json:"phone_number,omitempty"`
+}
+
+// VerifyCodeChallenge reports whether verifier matches an S256 PKCE code challenge (RFC 7636).
+func VerifyCodeChallenge(challenge, verifier string) bool {
+	if challenge == "" || verifier == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
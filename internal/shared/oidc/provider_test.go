@@ -0,0 +1,35 @@
+package oidc
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// Example from RFC 7636 appendix B.
+	const (
+		verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+	if !VerifyCodeChallenge(challenge, verifier) {
+		t.Error("VerifyCodeChallenge(RFC 7636 example) = false, want true")
+	}
+	if VerifyCodeChallenge(challenge, verifier+"x") {
+		t.Error("VerifyCodeChallenge(wrong verifier) = true, want false")
+	}
+	if VerifyCodeChallenge("", "") {
+		t.Error("VerifyCodeChallenge(empty) = true, want false")
+	}
+}
+
+func TestNewError_status(t *testing.T) {
+	if got := NewError(ErrCodeInvalidClient, "").Status; got != http.StatusUnauthorized {
+		t.Errorf("invalid_client status = %d, want 401", got)
+	}
+	if got := NewError(ErrCodeInvalidGrant, "").Status; got != http.StatusBadRequest {
+		t.Errorf("invalid_grant status = %d, want 400", got)
+	}
+	if got := NewError(ErrCodeInvalidGrant, "code expired").Error(); got != "invalid_grant: code expired" {
+		t.Errorf("Error() = %q", got)
+	}
+}
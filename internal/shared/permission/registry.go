@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"sync/atomic"
 
 	"github.com/hiamthach108/dreon-auth/config"
 )
@@ -20,14 +22,30 @@ type IRegistry interface {
 	ValidateCodes(codes []string) error
 }
 
-// Registry holds loaded permissions and validates permission codes
+// Registry holds loaded permissions and validates permission codes. Reload swaps its contents atomically,
+// so readers always see one complete version of the file.
 type Registry struct {
+	path  string
+	state atomic.Pointer[registryState]
+}
+
+type registryState struct {
 	list   []Permission
 	byCode map[string]Permission
 }
 
 // NewRegistry loads permissions from a JSON file and returns a Registry
 func NewRegistry(path string) (*Registry, error) {
+	state, err := loadRegistryState(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Registry{path: path}
+	r.state.Store(state)
+	return r, nil
+}
+
+func loadRegistryState(path string) (*registryState, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read permissions config: %w", err)
@@ -46,18 +64,43 @@ func NewRegistry(path string) (*Registry, error) {
 		byCode[p.Code] = p
 	}
 
-	return &Registry{
+	return &registryState{
 		list:   list,
 		byCode: byCode,
 	}, nil
 }
 
+// Path returns the file the registry is loaded from
+func (r *Registry) Path() string {
+	if r == nil {
+		return ""
+	}
+	return r.path
+}
+
+// Reload reads the file again and swaps in its permissions, reporting whether they changed. On error the
+// loaded permissions are kept.
+func (r *Registry) Reload() (bool, error) {
+	if r == nil {
+		return false, nil
+	}
+	state, err := loadRegistryState(r.path)
+	if err != nil {
+		return false, err
+	}
+	if slices.Equal(state.list, r.state.Load().list) {
+		return false, nil
+	}
+	r.state.Store(state)
+	return true, nil
+}
+
 // List returns all permissions
 func (r *Registry) List() []Permission {
 	if r == nil {
 		return nil
 	}
-	return r.list
+	return r.state.Load().list
 }
 
 // ValidateCodes returns an error if any code is not in the registry
@@ -65,11 +108,12 @@ func (r *Registry) ValidateCodes(codes []string) error {
 	if r == nil {
 		return nil
 	}
+	byCode := r.state.Load().byCode
 	for _, code := range codes {
 		if code == "" {
 			continue
 		}
-		if _, ok := r.byCode[code]; !ok {
+		if _, ok := byCode[code]; !ok {
 			return fmt.Errorf("invalid permission code: %s", code)
 		}
 	}
@@ -81,7 +125,7 @@ func (r *Registry) GetByCode(code string) (Permission, bool) {
 	if r == nil {
 		return Permission{}, false
 	}
-	p, ok := r.byCode[code]
+	p, ok := r.state.Load().byCode[code]
 	return p, ok
 }
 
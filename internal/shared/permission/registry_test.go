@@ -210,3 +210,40 @@ func TestNewRegistryFromConfig(t *testing.T) {
 		}
 	})
 }
+
+func TestRegistry_Reload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "perms.json")
+	if err := os.WriteFile(path, []byte(`[{"name": "View", "code": "view"}]`), 0644); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+	r, err := NewRegistry(path)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+
+	if changed, err := r.Reload(); err != nil || changed {
+		t.Errorf("Reload(unchanged file) = %v, %v, want false, nil", changed, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"name": "View", "code": "view"}, {"name": "Edit", "code": "edit"}]`), 0644); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+	if changed, err := r.Reload(); err != nil || !changed {
+		t.Fatalf("Reload(changed file) = %v, %v, want true, nil", changed, err)
+	}
+	if _, ok := r.GetByCode("edit"); !ok {
+		t.Error("GetByCode(edit) after reload: ok = false")
+	}
+
+	// A broken file keeps the permissions loaded last.
+	if err := os.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+	if _, err := r.Reload(); err == nil {
+		t.Fatal("Reload(invalid JSON) err = nil, want non-nil")
+	}
+	if len(r.List()) != 2 {
+		t.Errorf("List() len after failed reload = %d, want 2", len(r.List()))
+	}
+}
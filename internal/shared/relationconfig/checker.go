@@ -0,0 +1,217 @@
+package relationconfig
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// DefaultMaxDepth bounds how many rewrites, tuple hops and userset expansions a single check may follow.
+const DefaultMaxDepth = 25
+
+// ErrMaxDepthExceeded is returned when a check needs more than MaxDepth hops. Loops in the tuples (e.g. two
+// groups that are members of each other) are detected and skipped, so this means the graph is too deep.
+var ErrMaxDepthExceeded = errors.New("relation check exceeded max depth")
+
+// Object identifies namespace:objectID.
+type Object struct {
+	Namespace string
+	ObjectID  string
+}
+
+// Userset identifies namespace:objectID#relation, the subjects holding relation on an object
+// (e.g. group:eng#member).
+type Userset struct {
+	Object
+	Relation string
+}
+
+// TupleReader is the tuple store a Checker evaluates against. Only active, unexpired tuples count.
+type TupleReader interface {
+	// HasTuple reports whether namespace:objectID#relation@subjectNamespace:subjectObjectID is stored.
+	HasTuple(ctx context.Context, namespace, objectID, relation, subjectNamespace, subjectObjectID string) (bool, error)
+	// RelatedObjects returns the subjects stored for namespace:objectID#relation, read as objects.
+	RelatedObjects(ctx context.Context, namespace, objectID, relation string) ([]Object, error)
+	// Usersets returns the userset subjects stored for namespace:objectID#relation
+	// (e.g. group:eng#member in document:readme#viewer@group:eng#member).
+	Usersets(ctx context.Context, namespace, objectID, relation string) ([]Userset, error)
+	// Containing returns the object#relation of every tuple whose subject is exactly subject; an empty
+	// subject.Relation matches plain subjects such as user:alice or folder:docs.
+	Containing(ctx context.Context, subject Userset) ([]Userset, error)
+}
+
+// ConfigSource returns the configuration of a namespace, or nil when it has none.
+type ConfigSource func(ctx context.Context, namespace string) (*Config, error)
+
+// Checker answers "does subject hold relation on object?" by evaluating each namespace's rewrites.
+type Checker struct {
+	Tuples   TupleReader
+	Configs  ConfigSource
+	MaxDepth int // DefaultMaxDepth when zero
+}
+
+// Check reports whether subject holds relation on object, following rewrites and userset subjects
+// recursively.
+func (c *Checker) Check(ctx context.Context, object Object, relation string, subject Object) (bool, error) {
+	maxDepth := c.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	w := &walk{Checker: c, subject: subject, maxDepth: maxDepth, path: make(map[string]bool)}
+	return w.check(ctx, object, relation, 0)
+}
+
+// ListObjects returns the IDs of the objects in namespace on which subject holds relation, sorted.
+// It walks the tuples backwards from subject, up to MaxDepth hops, to find candidate objects, then confirms
+// each with Check so rewrites apply exactly as they do for single checks.
+func (c *Checker) ListObjects(ctx context.Context, namespace, relation string, subject Object) ([]string, error) {
+	maxDepth := c.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+
+	seen := map[Userset]bool{{Object: subject}: true}
+	var candidates []string
+	frontier := []Userset{{Object: subject}}
+	for depth := 0; depth <= maxDepth && len(frontier) > 0; depth++ {
+		var next []Userset
+		for _, node := range frontier {
+			containing, err := c.Tuples.Containing(ctx, node)
+			if err != nil {
+				return nil, err
+			}
+			for _, userset := range containing {
+				if userset.Namespace == namespace && !slices.Contains(candidates, userset.ObjectID) {
+					candidates = append(candidates, userset.ObjectID)
+				}
+				// Follow the userset (group:eng#member) and the bare object (folder:docs), which
+				// tuple-to-userset rewrites reference as a subject.
+				for _, n := range []Userset{userset, {Object: userset.Object}} {
+					if !seen[n] {
+						seen[n] = true
+						next = append(next, n)
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+
+	objects := make([]string, 0, len(candidates))
+	for _, objectID := range candidates {
+		ok, err := c.Check(ctx, Object{Namespace: namespace, ObjectID: objectID}, relation, subject)
+		if errors.Is(err, ErrMaxDepthExceeded) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			objects = append(objects, objectID)
+		}
+	}
+	slices.Sort(objects)
+	return objects, nil
+}
+
+// walk is the state of one Check. path holds the object#relation pairs being resolved, so a userset or
+// rewrite leading back to one of them is a cycle and contributes nothing.
+type walk struct {
+	*Checker
+	subject  Object
+	maxDepth int
+	path     map[string]bool
+}
+
+func (w *walk) check(ctx context.Context, object Object, relation string, depth int) (bool, error) {
+	if depth > w.maxDepth {
+		return false, ErrMaxDepthExceeded
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	key := object.Namespace + ":" + object.ObjectID + "#" + relation
+	if w.path[key] {
+		return false, nil
+	}
+	w.path[key] = true
+	defer delete(w.path, key)
+
+	cfg, err := w.Configs(ctx, object.Namespace)
+	if err != nil {
+		return false, err
+	}
+	return w.eval(ctx, object, relation, cfg.Rewrite(relation), depth)
+}
+
+func (w *walk) eval(ctx context.Context, object Object, relation string, rw *Rewrite, depth int) (bool, error) {
+	switch {
+	case rw.Direct():
+		return w.direct(ctx, object, relation, depth)
+
+	case rw.ComputedUserset != nil:
+		return w.check(ctx, object, rw.ComputedUserset.Relation, depth+1)
+
+	case rw.TupleToUserset != nil:
+		related, err := w.Tuples.RelatedObjects(ctx, object.Namespace, object.ObjectID, rw.TupleToUserset.Tupleset)
+		if err != nil {
+			return false, err
+		}
+		for _, next := range related {
+			ok, err := w.check(ctx, next, rw.TupleToUserset.ComputedUserset, depth+1)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case rw.Union != nil:
+		for _, child := range rw.Union {
+			ok, err := w.eval(ctx, object, relation, child, depth)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case rw.Intersection != nil:
+		for _, child := range rw.Intersection {
+			ok, err := w.eval(ctx, object, relation, child, depth)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return len(rw.Intersection) > 0, nil
+
+	case rw.Exclusion != nil:
+		ok, err := w.eval(ctx, object, relation, rw.Exclusion.Base, depth)
+		if err != nil || !ok {
+			return false, err
+		}
+		excluded, err := w.eval(ctx, object, relation, rw.Exclusion.Subtract, depth)
+		if err != nil {
+			return false, err
+		}
+		return !excluded, nil
+	}
+	return false, nil
+}
+
+// direct matches tuples stored for object#relation: the subject itself, or a userset the subject belongs to.
+func (w *walk) direct(ctx context.Context, object Object, relation string, depth int) (bool, error) {
+	ok, err := w.Tuples.HasTuple(ctx, object.Namespace, object.ObjectID, relation, w.subject.Namespace, w.subject.ObjectID)
+	if err != nil || ok {
+		return ok, err
+	}
+	usersets, err := w.Tuples.Usersets(ctx, object.Namespace, object.ObjectID, relation)
+	if err != nil {
+		return false, err
+	}
+	for _, userset := range usersets {
+		ok, err := w.check(ctx, userset.Object, userset.Relation, depth+1)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,267 @@
+package relationconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// tuples is a TupleReader over "ns:obj#rel@subjectNs:subjectObj[#subjectRel]" strings.
+type tuples []string
+
+func (t tuples) HasTuple(_ context.Context, namespace, objectID, relation, subjectNamespace, subjectObjectID string) (bool, error) {
+	want := namespace + ":" + objectID + "#" + relation + "@" + subjectNamespace + ":" + subjectObjectID
+	for _, tuple := range t {
+		if tuple == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (t tuples) RelatedObjects(_ context.Context, namespace, objectID, relation string) ([]Object, error) {
+	prefix := namespace + ":" + objectID + "#" + relation + "@"
+	var related []Object
+	for _, tuple := range t {
+		if subject, ok := strings.CutPrefix(tuple, prefix); ok {
+			subject, _, _ = strings.Cut(subject, "#")
+			ns, id, _ := strings.Cut(subject, ":")
+			related = append(related, Object{Namespace: ns, ObjectID: id})
+		}
+	}
+	return related, nil
+}
+
+func (t tuples) Usersets(_ context.Context, namespace, objectID, relation string) ([]Userset, error) {
+	prefix := namespace + ":" + objectID + "#" + relation + "@"
+	var usersets []Userset
+	for _, tuple := range t {
+		subject, ok := strings.CutPrefix(tuple, prefix)
+		if !ok {
+			continue
+		}
+		if object, rel, ok := strings.Cut(subject, "#"); ok {
+			ns, id, _ := strings.Cut(object, ":")
+			usersets = append(usersets, Userset{Object: Object{Namespace: ns, ObjectID: id}, Relation: rel})
+		}
+	}
+	return usersets, nil
+}
+
+func (t tuples) Containing(_ context.Context, subject Userset) ([]Userset, error) {
+	want := subject.Namespace + ":" + subject.ObjectID
+	if subject.Relation != "" {
+		want += "#" + subject.Relation
+	}
+	var containing []Userset
+	for _, tuple := range t {
+		object, s, _ := strings.Cut(tuple, "@")
+		if s != want {
+			continue
+		}
+		object, rel, _ := strings.Cut(object, "#")
+		ns, id, _ := strings.Cut(object, ":")
+		containing = append(containing, Userset{Object: Object{Namespace: ns, ObjectID: id}, Relation: rel})
+	}
+	return containing, nil
+}
+
+func configs(byNamespace map[string]*Config) ConfigSource {
+	return func(_ context.Context, namespace string) (*Config, error) {
+		return byNamespace[namespace], nil
+	}
+}
+
+var documentConfig = &Config{Relations: map[string]*Rewrite{
+	"parent": {},
+	"owner":  {},
+	"banned": {},
+	"editor": {Union: []*Rewrite{{This: true}, {ComputedUserset: &ComputedUserset{Relation: "owner"}}}},
+	"viewer": {Union: []*Rewrite{
+		{This: true},
+		{ComputedUserset: &ComputedUserset{Relation: "editor"}},
+		{TupleToUserset: &TupleToUserset{Tupleset: "parent", ComputedUserset: "viewer"}},
+	}},
+	"commenter": {Exclusion: &Exclusion{
+		Base:     &Rewrite{ComputedUserset: &ComputedUserset{Relation: "viewer"}},
+		Subtract: &Rewrite{ComputedUserset: &ComputedUserset{Relation: "banned"}},
+	}},
+	"auditor": {Intersection: []*Rewrite{
+		{ComputedUserset: &ComputedUserset{Relation: "viewer"}},
+		{ComputedUserset: &ComputedUserset{Relation: "owner"}},
+	}},
+}}
+
+var folderConfig = &Config{Relations: map[string]*Rewrite{
+	"parent": {},
+	"viewer": {Union: []*Rewrite{{This: true}, {TupleToUserset: &TupleToUserset{Tupleset: "parent", ComputedUserset: "viewer"}}}},
+}}
+
+func TestChecker_Check(t *testing.T) {
+	checker := &Checker{
+		Tuples: tuples{
+			"document:readme#parent@folder:docs",
+			"folder:docs#parent@folder:root",
+			"folder:root#viewer@user:carol",
+			"document:readme#owner@user:alice",
+			"document:readme#viewer@user:bob",
+			"document:readme#banned@user:bob",
+			"document:notes#viewer@user:dave",
+		},
+		Configs: configs(map[string]*Config{"document": documentConfig, "folder": folderConfig}),
+	}
+	readme := Object{Namespace: "document", ObjectID: "readme"}
+
+	tests := []struct {
+		name     string
+		object   Object
+		relation string
+		subject  string
+		want     bool
+	}{
+		{name: "direct", object: readme, relation: "viewer", subject: "bob", want: true},
+		{name: "computed userset", object: readme, relation: "editor", subject: "alice", want: true},
+		{name: "nested computed userset", object: readme, relation: "viewer", subject: "alice", want: true},
+		{name: "tuple to userset through two folders", object: readme, relation: "viewer", subject: "carol", want: true},
+		{name: "rewrite does not widen other relations", object: readme, relation: "editor", subject: "carol", want: false},
+		{name: "exclusion keeps base", object: readme, relation: "commenter", subject: "carol", want: true},
+		{name: "exclusion removes subtract", object: readme, relation: "commenter", subject: "bob", want: false},
+		{name: "intersection needs every operand", object: readme, relation: "auditor", subject: "carol", want: false},
+		{name: "intersection", object: readme, relation: "auditor", subject: "alice", want: true},
+		{name: "other object", object: Object{Namespace: "document", ObjectID: "notes"}, relation: "viewer", subject: "carol", want: false},
+		{name: "unconfigured namespace is direct only", object: Object{Namespace: "team", ObjectID: "eng"}, relation: "member", subject: "alice", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checker.Check(context.Background(), tt.object, tt.relation, Object{Namespace: "user", ObjectID: tt.subject})
+			if err != nil || got != tt.want {
+				t.Errorf("Check(%s#%s@user:%s) = %v, %v; want %v", tt.object.ObjectID, tt.relation, tt.subject, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecker_Usersets(t *testing.T) {
+	checker := &Checker{
+		Tuples: tuples{
+			"document:readme#viewer@group:eng#member",
+			"group:eng#member@group:backend#member",
+			"group:backend#member@user:alice",
+			"group:backend#admin@user:bob",
+			// eng and backend are members of each other; the loop must not hang or fail the check.
+			"group:backend#member@group:eng#member",
+			"folder:docs#viewer@group:backend#member",
+			"document:notes#parent@folder:docs",
+		},
+		Configs: configs(map[string]*Config{"document": documentConfig, "folder": folderConfig}),
+	}
+
+	tests := []struct {
+		name    string
+		object  Object
+		subject string
+		want    bool
+	}{
+		{name: "nested group", object: Object{Namespace: "document", ObjectID: "readme"}, subject: "alice", want: true},
+		{name: "other relation of the group", object: Object{Namespace: "document", ObjectID: "readme"}, subject: "bob", want: false},
+		{name: "group through parent folder", object: Object{Namespace: "document", ObjectID: "notes"}, subject: "alice", want: true},
+		{name: "not a member", object: Object{Namespace: "document", ObjectID: "notes"}, subject: "carol", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checker.Check(context.Background(), tt.object, "viewer", Object{Namespace: "user", ObjectID: tt.subject})
+			if err != nil || got != tt.want {
+				t.Errorf("Check(%s#viewer@user:%s) = %v, %v; want %v", tt.object.ObjectID, tt.subject, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecker_ListObjects(t *testing.T) {
+	checker := &Checker{
+		Tuples: tuples{
+			"document:readme#owner@user:alice",
+			"document:notes#parent@folder:docs",
+			"document:draft#parent@folder:private",
+			"folder:docs#viewer@group:eng#member",
+			"group:eng#member@user:alice",
+			"document:spec#banned@user:alice",
+			"document:spec#viewer@user:alice",
+		},
+		Configs: configs(map[string]*Config{"document": documentConfig, "folder": folderConfig}),
+	}
+	alice := Object{Namespace: "user", ObjectID: "alice"}
+
+	got, err := checker.ListObjects(context.Background(), "document", "viewer", alice)
+	if err != nil || !slices.Equal(got, []string{"notes", "readme", "spec"}) {
+		t.Errorf("ListObjects(viewer) = %v, %v; want [notes readme spec]", got, err)
+	}
+	got, err = checker.ListObjects(context.Background(), "document", "commenter", alice)
+	if err != nil || !slices.Equal(got, []string{"notes", "readme"}) {
+		t.Errorf("ListObjects(commenter) = %v, %v; want [notes readme]", got, err)
+	}
+	got, err = checker.ListObjects(context.Background(), "folder", "viewer", alice)
+	if err != nil || !slices.Equal(got, []string{"docs"}) {
+		t.Errorf("ListObjects(folder viewer) = %v, %v; want [docs]", got, err)
+	}
+}
+
+func TestChecker_MaxDepth(t *testing.T) {
+	chain := tuples{"group:g5#member@user:alice"}
+	for i := 0; i < 5; i++ {
+		chain = append(chain, fmt.Sprintf("group:g%d#member@group:g%d#member", i, i+1))
+	}
+	checker := &Checker{Tuples: chain, Configs: configs(nil), MaxDepth: 3}
+	group := Object{Namespace: "group", ObjectID: "g0"}
+	alice := Object{Namespace: "user", ObjectID: "alice"}
+
+	if _, err := checker.Check(context.Background(), group, "member", alice); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("Check past MaxDepth error = %v, want ErrMaxDepthExceeded", err)
+	}
+	checker.MaxDepth = 5
+	if ok, err := checker.Check(context.Background(), group, "member", alice); err != nil || !ok {
+		t.Errorf("Check within MaxDepth = %v, %v; want true", ok, err)
+	}
+
+	loop := &Checker{
+		Tuples:  tuples{"folder:a#parent@folder:b", "folder:b#parent@folder:a"},
+		Configs: configs(map[string]*Config{"folder": folderConfig}),
+	}
+	if ok, err := loop.Check(context.Background(), Object{Namespace: "folder", ObjectID: "a"}, "viewer", alice); err != nil || ok {
+		t.Errorf("Check on a parent loop = %v, %v; want false", ok, err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := documentConfig.Validate(); err != nil {
+		t.Errorf("Validate(document) = %v", err)
+	}
+	invalid := map[string]*Config{
+		"undefined computed relation": {Relations: map[string]*Rewrite{
+			"viewer": {ComputedUserset: &ComputedUserset{Relation: "editor"}},
+		}},
+		"several operations": {Relations: map[string]*Rewrite{
+			"owner":  {},
+			"viewer": {This: true, ComputedUserset: &ComputedUserset{Relation: "owner"}},
+		}},
+		"empty union": {Relations: map[string]*Rewrite{"viewer": {Union: []*Rewrite{}}}},
+		"incomplete tuple to userset": {Relations: map[string]*Rewrite{
+			"viewer": {TupleToUserset: &TupleToUserset{Tupleset: "parent"}},
+		}},
+		"exclusion without subtract": {Relations: map[string]*Rewrite{
+			"viewer": {Exclusion: &Exclusion{Base: &Rewrite{This: true}}},
+		}},
+		"computed loop": {Relations: map[string]*Rewrite{
+			"viewer": {Union: []*Rewrite{{This: true}, {ComputedUserset: &ComputedUserset{Relation: "editor"}}}},
+			"editor": {ComputedUserset: &ComputedUserset{Relation: "viewer"}},
+		}},
+	}
+	for name, cfg := range invalid {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate(%s) = nil, want error", name)
+		}
+	}
+}
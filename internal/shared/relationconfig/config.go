@@ -0,0 +1,174 @@
+// Package relationconfig holds Zanzibar-style namespace configuration: per-relation userset rewrite rules
+// (computed usersets, tuple-to-userset, union, intersection, exclusion) and the checker that evaluates them
+// against stored relation tuples.
+package relationconfig
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Config is the configuration of one namespace. Relations maps each relation name to its rewrite; a
+// relation missing from the map, or mapped to an empty rewrite, only matches tuples stored for it directly.
+type Config struct {
+	Relations map[string]*Rewrite `json:"relations"`
+}
+
+// Rewrite is a userset rewrite rule. At most one field is set; an empty rewrite is the same as This.
+type Rewrite struct {
+	// This matches tuples stored directly for the relation being checked.
+	This bool `json:"this,omitempty"`
+	// ComputedUserset matches subjects holding another relation on the same object (e.g. editors are viewers).
+	ComputedUserset *ComputedUserset `json:"computedUserset,omitempty"`
+	// TupleToUserset follows a relation to other objects and matches subjects holding a relation there
+	// (e.g. viewers of a document's parent folder are viewers of the document).
+	TupleToUserset *TupleToUserset `json:"tupleToUserset,omitempty"`
+	Union          []*Rewrite      `json:"union,omitempty"`
+	Intersection   []*Rewrite      `json:"intersection,omitempty"`
+	Exclusion      *Exclusion      `json:"exclusion,omitempty"`
+}
+
+// ComputedUserset points at another relation of the same object.
+type ComputedUserset struct {
+	Relation string `json:"relation"`
+}
+
+// TupleToUserset reads the objects related through Tupleset (e.g. "parent") and checks ComputedUserset
+// (e.g. "viewer") on each of them, in their own namespace.
+type TupleToUserset struct {
+	Tupleset        string `json:"tupleset"`
+	ComputedUserset string `json:"computedUserset"`
+}
+
+// Exclusion matches subjects in Base that are not in Subtract.
+type Exclusion struct {
+	Base     *Rewrite `json:"base"`
+	Subtract *Rewrite `json:"subtract"`
+}
+
+// Rewrite returns the rewrite configured for relation, or nil when tuples are only matched directly.
+// It is safe to call on a nil config.
+func (c *Config) Rewrite(relation string) *Rewrite {
+	if c == nil {
+		return nil
+	}
+	return c.Relations[relation]
+}
+
+// Direct reports whether rw only matches tuples stored for the relation itself.
+func (rw *Rewrite) Direct() bool {
+	return rw == nil || rw.This || (rw.ComputedUserset == nil && rw.TupleToUserset == nil &&
+		rw.Union == nil && rw.Intersection == nil && rw.Exclusion == nil)
+}
+
+// Validate reports the first malformed rewrite: a rule with several operations, an empty operand, a
+// computed userset naming a relation the namespace does not define, or relations that compute each other
+// in a loop.
+func (c *Config) Validate() error {
+	if c == nil {
+		return nil
+	}
+	for name, rw := range c.Relations {
+		if name == "" {
+			return fmt.Errorf("relation name is required")
+		}
+		if err := c.validateRewrite(name, rw); err != nil {
+			return fmt.Errorf("relation %q: %w", name, err)
+		}
+	}
+	for name := range c.Relations {
+		if err := c.checkComputedLoop(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateRewrite(relation string, rw *Rewrite) error {
+	if rw == nil {
+		return nil
+	}
+	ops := 0
+	for _, set := range []bool{rw.This, rw.ComputedUserset != nil, rw.TupleToUserset != nil,
+		rw.Union != nil, rw.Intersection != nil, rw.Exclusion != nil} {
+		if set {
+			ops++
+		}
+	}
+	if ops > 1 {
+		return fmt.Errorf("a rewrite must set exactly one operation")
+	}
+
+	switch {
+	case rw.ComputedUserset != nil:
+		target := rw.ComputedUserset.Relation
+		if target == "" {
+			return fmt.Errorf("computedUserset.relation is required")
+		}
+		if _, ok := c.Relations[target]; !ok {
+			return fmt.Errorf("computedUserset references undefined relation %q", target)
+		}
+	case rw.TupleToUserset != nil:
+		if rw.TupleToUserset.Tupleset == "" || rw.TupleToUserset.ComputedUserset == "" {
+			return fmt.Errorf("tupleToUserset requires tupleset and computedUserset")
+		}
+		if rw.TupleToUserset.Tupleset == relation {
+			return fmt.Errorf("tupleToUserset cannot read the relation it defines")
+		}
+	case rw.Union != nil || rw.Intersection != nil:
+		children := rw.Union
+		if rw.Intersection != nil {
+			children = rw.Intersection
+		}
+		if len(children) == 0 {
+			return fmt.Errorf("union and intersection need at least one operand")
+		}
+		for _, child := range children {
+			if err := c.validateRewrite(relation, child); err != nil {
+				return err
+			}
+		}
+	case rw.Exclusion != nil:
+		if rw.Exclusion.Base == nil || rw.Exclusion.Subtract == nil {
+			return fmt.Errorf("exclusion requires base and subtract")
+		}
+		if err := c.validateRewrite(relation, rw.Exclusion.Base); err != nil {
+			return err
+		}
+		return c.validateRewrite(relation, rw.Exclusion.Subtract)
+	}
+	return nil
+}
+
+// checkComputedLoop follows computed usersets from relation and fails when one leads back to a relation
+// already on path, which would never resolve.
+func (c *Config) checkComputedLoop(relation string, path []string) error {
+	if slices.Contains(path, relation) {
+		return fmt.Errorf("computed usersets loop: %v", append(path, relation))
+	}
+	path = append(path, relation)
+	for _, target := range computedTargets(c.Relations[relation]) {
+		if err := c.checkComputedLoop(target, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func computedTargets(rw *Rewrite) []string {
+	if rw == nil {
+		return nil
+	}
+	var targets []string
+	if rw.ComputedUserset != nil {
+		targets = append(targets, rw.ComputedUserset.Relation)
+	}
+	for _, child := range append(slices.Clone(rw.Union), rw.Intersection...) {
+		targets = append(targets, computedTargets(child)...)
+	}
+	if rw.Exclusion != nil {
+		targets = append(targets, computedTargets(rw.Exclusion.Base)...)
+		targets = append(targets, computedTargets(rw.Exclusion.Subtract)...)
+	}
+	return targets
+}
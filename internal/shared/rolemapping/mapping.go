@@ -0,0 +1,121 @@
+package rolemapping
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+)
+
+// Rule maps one identity-provider group/role claim to a local role assignment.
+type Rule struct {
+	Provider  constant.UserAuthType `json:"provider"`  // e.g. GOOGLE
+	Claim     string                `json:"claim"`     // group/role value as sent by the provider
+	RoleCode  string                `json:"roleCode"`  // local role code
+	ProjectID *string               `json:"projectId"` // project scope of the assignment, nil for system user roles
+}
+
+// Target is a local role assignment produced by one or more rules.
+type Target struct {
+	RoleCode  string
+	ProjectID *string
+}
+
+// ITable is the interface for the IdP claim to role mapping table
+type ITable interface {
+	// Targets returns every assignment the provider manages, matched or not.
+	Targets(provider constant.UserAuthType) []Target
+	// Resolve returns the assignments the given claims map to.
+	Resolve(provider constant.UserAuthType, claims []string) []Target
+}
+
+// Table holds loaded mapping rules grouped by provider
+type Table struct {
+	byProvider map[constant.UserAuthType][]Rule
+}
+
+// NewTable loads mapping rules from a JSON file and returns a Table
+func NewTable(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read role mapping config: %w", err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse role mapping config: %w", err)
+	}
+
+	byProvider := make(map[constant.UserAuthType][]Rule)
+	for _, r := range rules {
+		if r.Provider == "" || r.Claim == "" || r.RoleCode == "" {
+			continue
+		}
+		byProvider[r.Provider] = append(byProvider[r.Provider], r)
+	}
+
+	return &Table{byProvider: byProvider}, nil
+}
+
+// Targets returns the distinct assignments managed by the provider
+func (t *Table) Targets(provider constant.UserAuthType) []Target {
+	if t == nil {
+		return nil
+	}
+	return dedupe(t.byProvider[provider], func(Rule) bool { return true })
+}
+
+// Resolve returns the distinct assignments matched by the claims
+func (t *Table) Resolve(provider constant.UserAuthType, claims []string) []Target {
+	if t == nil || len(claims) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(claims))
+	for _, c := range claims {
+		set[c] = true
+	}
+	return dedupe(t.byProvider[provider], func(r Rule) bool { return set[r.Claim] })
+}
+
+func dedupe(rules []Rule, match func(Rule) bool) []Target {
+	seen := make(map[string]bool, len(rules))
+	var targets []Target
+	for _, r := range rules {
+		if !match(r) {
+			continue
+		}
+		key := TargetKey(r.RoleCode, r.ProjectID)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		targets = append(targets, Target{RoleCode: r.RoleCode, ProjectID: r.ProjectID})
+	}
+	return targets
+}
+
+// TargetKey identifies an assignment by role code and project scope.
+func TargetKey(roleCode string, projectID *string) string {
+	if projectID == nil {
+		return roleCode
+	}
+	return *projectID + "/" + roleCode
+}
+
+const defaultRoleMappingPath = "config/idp_role_mappings.json"
+
+// NewTableFromConfig loads the table from AppConfig.OAuth.RoleMappingFile (env: OAUTH_ROLE_MAPPING_FILE),
+// or default config/idp_role_mappings.json. A missing default file yields an empty table so syncing is opt-in.
+func NewTableFromConfig(cfg *config.AppConfig) (*Table, error) {
+	path := cfg.OAuth.RoleMappingFile
+	if path == "" {
+		path = defaultRoleMappingPath
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return &Table{}, nil
+		}
+	}
+	return NewTable(path)
+}
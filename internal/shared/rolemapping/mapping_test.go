@@ -0,0 +1,82 @@
+package rolemapping
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+)
+
+func writeTable(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mappings.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+	return path
+}
+
+func TestNewTable(t *testing.T) {
+	path := writeTable(t, `[
+		{"provider": "GOOGLE", "claim": "eng", "roleCode": "developer", "projectId": "p1"},
+		{"provider": "GOOGLE", "claim": "eng-leads", "roleCode": "developer", "projectId": "p1"},
+		{"provider": "GOOGLE", "claim": "example.com", "roleCode": "member"},
+		{"provider": "GOOGLE", "claim": "", "roleCode": "ignored"}
+	]`)
+
+	tbl, err := NewTable(path)
+	if err != nil {
+		t.Fatalf("NewTable() err = %v", err)
+	}
+
+	targets := tbl.Targets(constant.UserAuthTypeGoogle)
+	if len(targets) != 2 {
+		t.Fatalf("Targets() len = %d, want 2", len(targets))
+	}
+
+	resolved := tbl.Resolve(constant.UserAuthTypeGoogle, []string{"eng", "eng-leads"})
+	if len(resolved) != 1 || resolved[0].RoleCode != "developer" || resolved[0].ProjectID == nil || *resolved[0].ProjectID != "p1" {
+		t.Errorf("Resolve() = %+v, want single developer in p1", resolved)
+	}
+
+	if got := tbl.Resolve(constant.UserAuthTypeGoogle, []string{"unknown"}); len(got) != 0 {
+		t.Errorf("Resolve(unknown) = %+v, want empty", got)
+	}
+	if got := tbl.Targets(constant.UserAuthTypeFacebook); len(got) != 0 {
+		t.Errorf("Targets(FACEBOOK) = %+v, want empty", got)
+	}
+}
+
+func TestNewTable_InvalidJSON(t *testing.T) {
+	path := writeTable(t, `{not json`)
+	if _, err := NewTable(path); err == nil {
+		t.Error("NewTable() expected error for invalid JSON")
+	}
+}
+
+func TestNewTableFromConfig_MissingDefault(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	tbl, err := NewTableFromConfig(&config.AppConfig{})
+	if err != nil {
+		t.Fatalf("NewTableFromConfig() err = %v", err)
+	}
+	if got := tbl.Targets(constant.UserAuthTypeGoogle); len(got) != 0 {
+		t.Errorf("Targets() = %+v, want empty", got)
+	}
+}
+
+func TestNewTableFromConfig_MissingExplicitPath(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.OAuth.RoleMappingFile = filepath.Join(t.TempDir(), "missing.json")
+	if _, err := NewTableFromConfig(cfg); err == nil {
+		t.Error("NewTableFromConfig() expected error for missing explicit file")
+	}
+}
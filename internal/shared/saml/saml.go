@@ -0,0 +1,148 @@
+// Package saml builds per-project SAML 2.0 service providers and turns validated assertions
+// into the identity dreon-auth signs users in with.
+package saml
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	gosaml "github.com/crewjam/saml"
+	xrv "github.com/mattermost/xml-roundtrip-validator"
+)
+
+// SPConfig is the deployment-wide service provider setup shared by every project.
+type SPConfig struct {
+	BaseURL     string // public URL of the API, e.g. https://auth.example.com/api/v1
+	Key         crypto.Signer
+	Certificate *x509.Certificate
+}
+
+// Mapping names the assertion attributes that carry user details. Empty EmailAttribute means the NameID is the email.
+type Mapping struct {
+	EmailAttribute  string
+	NameAttribute   string
+	GroupsAttribute string
+}
+
+// Identity is the user asserted by the IdP.
+type Identity struct {
+	NameID string
+	Email  string
+	Name   string
+	Groups []string
+}
+
+// ParseIdPMetadata validates IdP metadata XML and returns its entity descriptor.
+func ParseIdPMetadata(data []byte) (*gosaml.EntityDescriptor, error) {
+	if err := xrv.Validate(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("invalid idp metadata: %w", err)
+	}
+	var entity gosaml.EntityDescriptor
+	if err := xml.Unmarshal(data, &entity); err != nil {
+		return nil, fmt.Errorf("parse idp metadata: %w", err)
+	}
+	if entity.EntityID == "" || len(entity.IDPSSODescriptors) == 0 {
+		return nil, errors.New("idp metadata has no entityID or IDPSSODescriptor")
+	}
+	return &entity, nil
+}
+
+// MetadataURL is the project's SP metadata URL, which also serves as its entity ID.
+func MetadataURL(baseURL, projectID string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/saml/" + url.PathEscape(projectID) + "/metadata"
+}
+
+// ACSURL is the project's assertion consumer service URL.
+func ACSURL(baseURL, projectID string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/saml/" + url.PathEscape(projectID) + "/acs"
+}
+
+// NewServiceProvider returns the SP for one project, trusting only the given IdP. IdP-initiated
+// responses are refused, so every assertion must answer an AuthnRequest this SP issued.
+func NewServiceProvider(cfg SPConfig, projectID string, idp *gosaml.EntityDescriptor) (*gosaml.ServiceProvider, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("saml base url is not configured")
+	}
+	metadataURL, err := url.Parse(MetadataURL(cfg.BaseURL, projectID))
+	if err != nil {
+		return nil, err
+	}
+	acsURL, err := url.Parse(ACSURL(cfg.BaseURL, projectID))
+	if err != nil {
+		return nil, err
+	}
+	return &gosaml.ServiceProvider{
+		EntityID:          metadataURL.String(),
+		Key:               cfg.Key,
+		Certificate:       cfg.Certificate,
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		IDPMetadata:       idp,
+		AuthnNameIDFormat: gosaml.EmailAddressNameIDFormat,
+		AllowIDPInitiated: false,
+	}, nil
+}
+
+// IdentityFromAssertion extracts the user from a validated assertion.
+func IdentityFromAssertion(a *gosaml.Assertion, m Mapping) (*Identity, error) {
+	if a == nil || a.Subject == nil || a.Subject.NameID == nil || a.Subject.NameID.Value == "" {
+		return nil, errors.New("assertion has no subject NameID")
+	}
+	id := &Identity{NameID: a.Subject.NameID.Value}
+	if m.EmailAttribute == "" {
+		id.Email = id.NameID
+	} else if values := attributeValues(a, m.EmailAttribute); len(values) > 0 {
+		id.Email = values[0]
+	}
+	if !strings.Contains(id.Email, "@") {
+		return nil, fmt.Errorf("assertion has no email address (attribute %q)", m.EmailAttribute)
+	}
+	id.Email = strings.ToLower(id.Email)
+	if values := attributeValues(a, m.NameAttribute); len(values) > 0 {
+		id.Name = values[0]
+	}
+	id.Groups = attributeValues(a, m.GroupsAttribute)
+	return id, nil
+}
+
+// EmailDomainAllowed reports whether email belongs to one of domains (case-insensitive, exact match).
+func EmailDomainAllowed(email string, domains []string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, d := range domains {
+		if strings.EqualFold(domain, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// attributeValues returns the values of the attribute whose Name or FriendlyName is name.
+func attributeValues(a *gosaml.Assertion, name string) []string {
+	if name == "" {
+		return nil
+	}
+	var out []string
+	for _, stmt := range a.AttributeStatements {
+		for _, attr := range stmt.Attributes {
+			if attr.Name != name && attr.FriendlyName != name {
+				continue
+			}
+			for _, v := range attr.Values {
+				if v.Value != "" {
+					out = append(out, v.Value)
+				}
+			}
+		}
+	}
+	return out
+}
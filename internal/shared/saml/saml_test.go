@@ -0,0 +1,100 @@
+package saml
+
+import (
+	"testing"
+
+	gosaml "github.com/crewjam/saml"
+)
+
+const idpMetadata = `<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com/metadata">
+  <IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>
+  </IDPSSODescriptor>
+</EntityDescriptor>`
+
+func TestParseIdPMetadata(t *testing.T) {
+	entity, err := ParseIdPMetadata([]byte(idpMetadata))
+	if err != nil {
+		t.Fatalf("ParseIdPMetadata() err = %v", err)
+	}
+	if entity.EntityID != "https://idp.example.com/metadata" {
+		t.Errorf("EntityID = %q", entity.EntityID)
+	}
+
+	for _, bad := range []string{"", "<EntityDescriptor/>", "not xml"} {
+		if _, err := ParseIdPMetadata([]byte(bad)); err == nil {
+			t.Errorf("ParseIdPMetadata(%q) err = nil, want error", bad)
+		}
+	}
+}
+
+func TestNewServiceProvider(t *testing.T) {
+	idp, _ := ParseIdPMetadata([]byte(idpMetadata))
+	sp, err := NewServiceProvider(SPConfig{BaseURL: "https://auth.example.com/api/v1/"}, "p1", idp)
+	if err != nil {
+		t.Fatalf("NewServiceProvider() err = %v", err)
+	}
+	if sp.EntityID != "https://auth.example.com/api/v1/saml/p1/metadata" || sp.AcsURL.String() != "https://auth.example.com/api/v1/saml/p1/acs" {
+		t.Errorf("EntityID = %q, AcsURL = %q", sp.EntityID, sp.AcsURL.String())
+	}
+	if sp.AllowIDPInitiated {
+		t.Error("IdP-initiated responses must be refused")
+	}
+	if sso := sp.GetSSOBindingLocation(gosaml.HTTPRedirectBinding); sso != "https://idp.example.com/sso" {
+		t.Errorf("SSO location = %q", sso)
+	}
+
+	if _, err := NewServiceProvider(SPConfig{}, "p1", idp); err == nil {
+		t.Error("NewServiceProvider() without a base URL err = nil, want error")
+	}
+}
+
+func TestIdentityFromAssertion(t *testing.T) {
+	assertion := &gosaml.Assertion{
+		Subject: &gosaml.Subject{NameID: &gosaml.NameID{Value: "Ann@Corp.example"}},
+		AttributeStatements: []gosaml.AttributeStatement{{Attributes: []gosaml.Attribute{
+			{Name: "urn:oid:0.9.2342.19200300.100.1.3", FriendlyName: "mail", Values: []gosaml.AttributeValue{{Value: "ann.lee@corp.example"}}},
+			{Name: "displayName", Values: []gosaml.AttributeValue{{Value: "Ann Lee"}}},
+			{Name: "groups", Values: []gosaml.AttributeValue{{Value: "eng"}, {Value: "admins"}}},
+		}}},
+	}
+
+	id, err := IdentityFromAssertion(assertion, Mapping{})
+	if err != nil || id.Email != "ann@corp.example" || id.Groups != nil {
+		t.Fatalf("NameID mapping = %+v, %v", id, err)
+	}
+
+	id, err = IdentityFromAssertion(assertion, Mapping{EmailAttribute: "mail", NameAttribute: "displayName", GroupsAttribute: "groups"})
+	if err != nil {
+		t.Fatalf("IdentityFromAssertion() err = %v", err)
+	}
+	if id.Email != "ann.lee@corp.example" || id.Name != "Ann Lee" || len(id.Groups) != 2 || id.NameID != "Ann@Corp.example" {
+		t.Errorf("attribute mapping = %+v", id)
+	}
+
+	if _, err := IdentityFromAssertion(assertion, Mapping{EmailAttribute: "missing"}); err == nil {
+		t.Error("missing email attribute err = nil, want error")
+	}
+	if _, err := IdentityFromAssertion(&gosaml.Assertion{}, Mapping{}); err == nil {
+		t.Error("assertion without subject err = nil, want error")
+	}
+}
+
+func TestEmailDomainAllowed(t *testing.T) {
+	domains := []string{"corp.example", "Sub.Corp.Example"}
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"ann@corp.example", true},
+		{"ann@sub.corp.example", true},
+		{"ann@evil-corp.example", false},
+		{"ann@corp.example.evil", false},
+		{"corp.example", false},
+	}
+	for _, tt := range tests {
+		if got := EmailDomainAllowed(tt.email, domains); got != tt.want {
+			t.Errorf("EmailDomainAllowed(%q) = %v, want %v", tt.email, got, tt.want)
+		}
+	}
+}
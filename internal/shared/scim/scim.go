@@ -0,0 +1,134 @@
+// Package scim holds the protocol pieces of SCIM 2.0 (RFC 7643, RFC 7644) used by the provisioning API:
+// schema URNs, error responses and the small filter subset identity providers send.
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of SCIM requests and responses.
+const ContentType = "application/scim+json"
+
+// ProvisionScope is the permission code an API key needs to call the SCIM API.
+const ProvisionScope = "scim.provision"
+
+// Schema URNs (RFC 7643 section 8.7, RFC 7644 section 3).
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// Error types (RFC 7644 section 3.12).
+const (
+	ErrTypeInvalidFilter = "invalidFilter"
+	ErrTypeInvalidSyntax = "invalidSyntax"
+	ErrTypeInvalidPath   = "invalidPath"
+	ErrTypeInvalidValue  = "invalidValue"
+	ErrTypeUniqueness    = "uniqueness"
+	ErrTypeMutability    = "mutability"
+	ErrTypeNoTarget      = "noTarget"
+)
+
+// Error is a SCIM error response. Status is sent both as the HTTP status and, as a string, in the body.
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+// NewError returns an Error; scimType may be empty.
+func NewError(status int, scimType, detail string) *Error {
+	return &Error{Status: status, ScimType: scimType, Detail: detail}
+}
+
+// NotFound returns the 404 error for a missing resource.
+func NotFound(detail string) *Error {
+	return NewError(http.StatusNotFound, "", detail)
+}
+
+// BadRequest returns a 400 error of the given type.
+func BadRequest(scimType, detail string) *Error {
+	return NewError(http.StatusBadRequest, scimType, detail)
+}
+
+func (e *Error) Error() string {
+	if e.ScimType == "" {
+		return e.Detail
+	}
+	return e.ScimType + ": " + e.Detail
+}
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"`
+		ScimType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail,omitempty"`
+	}{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(e.Status),
+		ScimType: e.ScimType,
+		Detail:   e.Detail,
+	})
+}
+
+// Filter is an attribute equality filter, the only form identity providers use to look resources up
+// (e.g. `userName eq "ada@example.com"`). Attribute keeps the case it was sent with.
+type Filter struct {
+	Attribute string
+	Value     string
+}
+
+// Is reports whether the filter targets attr, compared case-insensitively as SCIM attribute names are.
+func (f Filter) Is(attr string) bool {
+	return strings.EqualFold(f.Attribute, attr)
+}
+
+// ParseFilter parses `<attribute> eq "<value>"`. An empty expression returns a zero Filter.
+// Other operators and compound expressions are rejected with invalidFilter.
+func ParseFilter(expr string) (Filter, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return Filter{}, nil
+	}
+	attr, rest, ok := strings.Cut(expr, " ")
+	if !ok {
+		return Filter{}, BadRequest(ErrTypeInvalidFilter, "unsupported filter: "+expr)
+	}
+	op, value, ok := strings.Cut(strings.TrimSpace(rest), " ")
+	if !ok || !strings.EqualFold(op, "eq") {
+		return Filter{}, BadRequest(ErrTypeInvalidFilter, "only the eq operator is supported")
+	}
+	unquoted, err := strconv.Unquote(strings.TrimSpace(value))
+	if err != nil || !strings.HasPrefix(strings.TrimSpace(value), `"`) {
+		return Filter{}, BadRequest(ErrTypeInvalidFilter, "filter value must be a quoted string")
+	}
+	return Filter{Attribute: attr, Value: unquoted}, nil
+}
+
+// ParsePath splits a PATCH path into its attribute and value filter: `members[value eq "123"]` gives
+// "members", and `emails[type eq "work"].value` gives "emails.value". Paths without a filter return a
+// zero Filter.
+func ParsePath(path string) (string, Filter, error) {
+	path = strings.TrimSpace(path)
+	attr, rest, ok := strings.Cut(path, "[")
+	if !ok {
+		return path, Filter{}, nil
+	}
+	expr, sub, ok := strings.Cut(rest, "]")
+	if !ok {
+		return "", Filter{}, BadRequest(ErrTypeInvalidPath, "unterminated value filter in path: "+path)
+	}
+	filter, err := ParseFilter(expr)
+	if err != nil {
+		return "", Filter{}, BadRequest(ErrTypeInvalidPath, "unsupported value filter in path: "+path)
+	}
+	return attr + sub, filter, nil
+}
@@ -0,0 +1,76 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    Filter
+		wantErr bool
+	}{
+		{expr: "", want: Filter{}},
+		{expr: `userName eq "ada@example.com"`, want: Filter{Attribute: "userName", Value: "ada@example.com"}},
+		{expr: `emails.value EQ "a \"quoted\" name"`, want: Filter{Attribute: "emails.value", Value: `a "quoted" name`}},
+		{expr: `displayName eq "Engineering Team"`, want: Filter{Attribute: "displayName", Value: "Engineering Team"}},
+		{expr: `userName co "ada"`, wantErr: true},
+		{expr: `userName eq ada`, wantErr: true},
+		{expr: `userName eq "a" and active eq "true"`, wantErr: true},
+		{expr: "userName", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := ParseFilter(tt.expr)
+			if tt.wantErr {
+				var scimErr *Error
+				if !errors.As(err, &scimErr) || scimErr.ScimType != ErrTypeInvalidFilter {
+					t.Fatalf("ParseFilter(%q) error = %v, want invalidFilter", tt.expr, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseFilter(%q) = %+v, %v; want %+v", tt.expr, got, err, tt.want)
+			}
+		})
+	}
+	if !(Filter{Attribute: "USERNAME"}).Is("userName") {
+		t.Error("Is should compare attribute names case-insensitively")
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	attr, filter, err := ParsePath(`members[value eq "u1"]`)
+	if err != nil || attr != "members" || filter != (Filter{Attribute: "value", Value: "u1"}) {
+		t.Errorf("ParsePath(members filter) = %q, %+v, %v", attr, filter, err)
+	}
+	attr, filter, err = ParsePath(`emails[type eq "work"].value`)
+	if err != nil || attr != "emails.value" || filter != (Filter{Attribute: "type", Value: "work"}) {
+		t.Errorf("ParsePath(emails sub-attribute) = %q, %+v, %v", attr, filter, err)
+	}
+	attr, filter, err = ParsePath("active")
+	if err != nil || attr != "active" || filter != (Filter{}) {
+		t.Errorf("ParsePath(active) = %q, %+v, %v", attr, filter, err)
+	}
+	if _, _, err := ParsePath(`members[value eq "u1"`); err == nil {
+		t.Error("ParsePath accepted an unterminated filter")
+	}
+}
+
+func TestError_json(t *testing.T) {
+	b, err := json.Marshal(NewError(http.StatusConflict, ErrTypeUniqueness, "userName taken"))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var got map[string]any
+	_ = json.Unmarshal(b, &got)
+	if got["status"] != "409" || got["scimType"] != "uniqueness" || got["detail"] != "userName taken" {
+		t.Errorf("body = %s", b)
+	}
+	if schemas, _ := got["schemas"].([]any); len(schemas) != 1 || schemas[0] != SchemaError {
+		t.Errorf("schemas = %v", got["schemas"])
+	}
+}
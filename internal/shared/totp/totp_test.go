@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 appendix B SHA-1 test key "12345678901234567890".
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; the last 6 digits are the 6-digit codes.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code() err = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(t=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current, _ := Code(rfcSecret, Step(now))
+	previous, _ := Code(rfcSecret, Step(now)-1)
+	stale, _ := Code(rfcSecret, Step(now)-3)
+
+	if step, ok := Validate(rfcSecret, current, now); !ok || step != Step(now) {
+		t.Errorf("Validate(current) = %d, %v; want %d, true", step, ok, Step(now))
+	}
+	if _, ok := Validate(rfcSecret, previous, now); !ok {
+		t.Error("Validate(previous step) = false, want accepted within skew")
+	}
+	if _, ok := Validate(rfcSecret, stale, now); ok {
+		t.Error("Validate(3 steps old) = true, want rejected")
+	}
+	if _, ok := Validate(rfcSecret, "12345", now); ok {
+		t.Error("Validate(short code) = true, want rejected")
+	}
+}
+
+func TestGenerateSecretAndURL(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil || len(secret) != 32 {
+		t.Fatalf("GenerateSecret() = %q, %v; want 32 base32 chars", secret, err)
+	}
+	u := URL("Dreon Auth", "alice@example.com", secret)
+	if !strings.HasPrefix(u, "otpauth://totp/Dreon%20Auth:alice@example.com?") || !strings.Contains(u, "secret="+secret) {
+		t.Errorf("URL() = %s", u)
+	}
+}
@@ -0,0 +1,394 @@
+// Package apitest wires the real HTTP server, handlers, middleware and services on top of the
+// in-memory fakes from testutil, so API behaviour can be tested end to end without Postgres or Redis.
+package apitest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/oidc"
+	"github.com/hiamthach108/dreon-auth/internal/shared/permission"
+	"github.com/hiamthach108/dreon-auth/internal/shared/rolemapping"
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+	"github.com/hiamthach108/dreon-auth/pkg/background"
+	"github.com/hiamthach108/dreon-auth/pkg/breach"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+	"github.com/hiamthach108/dreon-auth/pkg/captcha"
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/mailer"
+	"github.com/hiamthach108/dreon-auth/pkg/password"
+	"github.com/hiamthach108/dreon-auth/pkg/sms"
+	"github.com/hiamthach108/dreon-auth/pkg/statetoken"
+	httpserver "github.com/hiamthach108/dreon-auth/presentation/http"
+	"github.com/hiamthach108/dreon-auth/presentation/http/handler"
+	echomw "github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+// Harness is a running test server plus the fakes behind it. Seed data through the fakes,
+// then call the API through Do.
+type Harness struct {
+	Server *httptest.Server
+	HTTP   *httpserver.HttpServer
+	Config *config.AppConfig
+
+	Cache   *testutil.Cache
+	Jwt     *testutil.JwtTokenManager
+	Mailer  *testutil.Mailer
+	SMS     *testutil.SMSSender
+	Captcha *testutil.CaptchaVerifier
+	Breach  *testutil.BreachChecker
+	Events  *testutil.EventPublisher
+
+	Users           *testutil.UserRepository
+	SuperAdmins     *testutil.SuperAdminRepository
+	Projects        *testutil.ProjectRepository
+	Sessions        *testutil.SessionRepository
+	Roles           *testutil.RoleRepository
+	UserRoles       *testutil.UserRoleRepository
+	RoleHistory     *testutil.RoleHistoryRepository
+	RelationTuple   *testutil.RelationTupleRepository
+	Namespaces      *testutil.RelationNamespaceRepository
+	Permissions     *testutil.PermissionRepository
+	Credentials     *testutil.UserCredentialRepository
+	AccessPolicies  *testutil.AccessPolicyRepository
+	AccessDenials   *testutil.AccessDenialRepository
+	TrustedDevices  *testutil.TrustedDeviceRepository
+	UserDevices     *testutil.UserDeviceRepository
+	Impersonations  *testutil.ImpersonationRepository
+	SAMLConnections *testutil.SAMLConnectionRepository
+	SigningKeys     *testutil.SigningKeyRepository
+	RevokedTokens   *testutil.RevokedTokenRepository
+	APIKeys         *testutil.APIKeyRepository
+	ProjectMembers  *testutil.ProjectMemberRepository
+	Templates       *testutil.NotificationTemplateRepository
+	UserIdentities  *testutil.UserIdentityRepository
+	ServiceAccounts *testutil.ServiceAccountRepository
+	SCIMUsers       *testutil.SCIMUserRepository
+	IPBlocks        *testutil.IPBlockRepository
+	EffectiveAccess *testutil.EffectiveAccessRepository
+
+	// Database answers the readiness probe's Postgres check; set its Err to fail it.
+	Database *testutil.HealthRepository
+
+	// OIDCClients are the registered OIDC clients; nil means none.
+	OIDCClients *oidc.ClientRegistry
+
+	// Keys backs the JWKS endpoint and key rotation; access tokens still come from Jwt.
+	Keys *jwt.KeySet
+
+	// Relations builds RequireRelation guards backed by the server's relation service.
+	Relations *echomw.RelationMiddleware
+
+	// Privacy is the server's privacy service, for running the erased-account purge on demand.
+	Privacy service.IPrivacySvc
+
+	// EffectiveAccessSvc is the server's effective access service, for rebuilding the table on demand.
+	EffectiveAccessSvc service.IEffectiveAccessSvc
+
+	// ClaimsEnrichers are the claims hooks AuthSvc runs; see WithClaimsEnricher.
+	ClaimsEnrichers service.ClaimsEnrichers
+}
+
+// Option customizes the harness before the server is built.
+type Option func(*Harness)
+
+// WithConfig lets a test adjust the AppConfig the services are built with.
+func WithConfig(fn func(*config.AppConfig)) Option {
+	return func(h *Harness) { fn(h.Config) }
+}
+
+// WithClaimsEnricher registers a claims hook, as a host would with service.AsClaimsEnricher.
+func WithClaimsEnricher(enricher jwt.IClaimsEnricher) Option {
+	return func(h *Harness) { h.ClaimsEnrichers = append(h.ClaimsEnrichers, enricher) }
+}
+
+// New starts a server wired like main.go, but with in-memory fakes for every external dependency.
+// The server is shut down when the test ends.
+func New(t testing.TB, opts ...Option) *Harness {
+	t.Helper()
+
+	roles := testutil.NewRoleRepository()
+	users := testutil.NewUserRepository()
+	h := &Harness{
+		Config:          defaultConfig(),
+		Cache:           testutil.NewCache(),
+		Jwt:             testutil.NewJwtTokenManager(),
+		Mailer:          testutil.NewMailer(),
+		SMS:             testutil.NewSMSSender(),
+		Captcha:         testutil.NewCaptchaVerifier(),
+		Breach:          testutil.NewBreachChecker(),
+		Events:          testutil.NewEventPublisher(),
+		Users:           users,
+		SuperAdmins:     testutil.NewSuperAdminRepository(),
+		Projects:        testutil.NewProjectRepository(),
+		Sessions:        testutil.NewSessionRepository(),
+		Roles:           roles,
+		UserRoles:       testutil.NewUserRoleRepository(roles),
+		RoleHistory:     testutil.NewRoleHistoryRepository(),
+		RelationTuple:   testutil.NewRelationTupleRepository(),
+		Namespaces:      testutil.NewRelationNamespaceRepository(),
+		Permissions:     testutil.NewPermissionRepository(),
+		Credentials:     testutil.NewUserCredentialRepository(),
+		AccessPolicies:  testutil.NewAccessPolicyRepository(),
+		AccessDenials:   testutil.NewAccessDenialRepository(),
+		TrustedDevices:  testutil.NewTrustedDeviceRepository(),
+		UserDevices:     testutil.NewUserDeviceRepository(),
+		Impersonations:  testutil.NewImpersonationRepository(),
+		SAMLConnections: testutil.NewSAMLConnectionRepository(),
+		SigningKeys:     testutil.NewSigningKeyRepository(),
+		RevokedTokens:   testutil.NewRevokedTokenRepository(),
+		APIKeys:         testutil.NewAPIKeyRepository(),
+		ProjectMembers:  testutil.NewProjectMemberRepository(),
+		Templates:       testutil.NewNotificationTemplateRepository(),
+		UserIdentities:  testutil.NewUserIdentityRepository(),
+		ServiceAccounts: testutil.NewServiceAccountRepository(),
+		SCIMUsers:       testutil.NewSCIMUserRepository(users),
+		IPBlocks:        testutil.NewIPBlockRepository(),
+		EffectiveAccess: testutil.NewEffectiveAccessRepository(),
+		Database:        &testutil.HealthRepository{},
+		Keys:            newKeySet(t),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	var server *httpserver.HttpServer
+	app := fxtest.New(t,
+		fx.NopLogger,
+		fx.Provide(
+			func() *config.AppConfig { return h.Config },
+			config.NewNotifier,
+			func() logger.ILogger { return testutil.NewLogger() },
+			func() cache.ICache { return h.Cache },
+			func() jwt.IJwtTokenManager { return h.Jwt },
+			func() jwt.IKeySet { return h.Keys },
+			func() mailer.IMailer { return h.Mailer },
+			func() sms.ISender { return h.SMS },
+			func() captcha.IVerifier { return h.Captcha },
+			func() breach.IChecker { return h.Breach },
+			func() eventbus.IPublisher { return h.Events },
+			background.NewGroup,
+			statetoken.NewSealerFromConfig,
+			password.NewHasher,
+			func() *permission.Registry { return nil },
+			func() *rolemapping.Table { return nil },
+			func() *oidc.ClientRegistry { return h.OIDCClients },
+			echomw.NewVerifyJWTMiddleware,
+			echomw.NewAuthorizeMiddleware,
+			echomw.NewAPIKeyMiddleware,
+			echomw.NewSCIMAuthMiddleware,
+			echomw.NewRelationMiddleware,
+			echomw.NewRateLimitMiddleware,
+			echomw.NewIPFilterMiddleware,
+			httpserver.NewHttpServer,
+
+			handler.NewUserHandler,
+			handler.NewAuthHandler,
+			handler.NewProjectHandler,
+			handler.NewRelationHandler,
+			handler.NewRoleHandler,
+			handler.NewPermissionHandler,
+			handler.NewCredentialHandler,
+			handler.NewAccessPolicyHandler,
+			handler.NewTrustedDeviceHandler,
+			handler.NewUserDeviceHandler,
+			handler.NewSessionHandler,
+			handler.NewSAMLHandler,
+			handler.NewSigningKeyHandler,
+			handler.NewOIDCProviderHandler,
+			handler.NewAPIKeyHandler,
+			handler.NewProjectMemberHandler,
+			handler.NewNotificationTemplateHandler,
+			handler.NewUserIdentityHandler,
+			handler.NewServiceAccountHandler,
+			handler.NewSCIMHandler,
+			handler.NewHealthHandler,
+			handler.NewIPBlockHandler,
+
+			service.NewUserSvc,
+			service.NewPasswordPolicySvc,
+			service.NewAuthSvc,
+			func() service.ClaimsEnrichers { return h.ClaimsEnrichers },
+			service.NewProjectSvc,
+			service.NewRelationSvc,
+			service.NewRoleSvc,
+			service.NewCredentialSvc,
+			service.NewAccessPolicySvc,
+			service.NewTrustedDeviceSvc,
+			service.NewUserDeviceSvc,
+			service.NewSessionSvc,
+			service.NewSAMLSvc,
+			service.NewLogoutNotifier,
+			service.NewOAuthProviderRegistryFromConfig,
+			service.NewUsageSvc,
+			service.NewProjectUsageSvc,
+			service.NewSigningKeySvc,
+			service.NewTokenRevocationSvc,
+			service.NewAPIKeySvc,
+			service.NewProjectMemberSvc,
+			service.NewNotificationTemplateSvc,
+			service.NewUserIdentitySvc,
+			service.NewPrivacySvc,
+			service.NewUserTransferSvc,
+			service.NewServiceAccountSvc,
+			service.NewSCIMSvc,
+			service.NewPermissionSvc,
+			service.NewRateLimitSvc,
+			service.NewHealthSvc,
+			service.NewIPFilterSvc,
+			service.NewEffectiveAccessSvc,
+
+			func() repository.IUserRepository { return h.Users },
+			func() repository.ISuperAdminRepository { return h.SuperAdmins },
+			func() repository.IProjectRepository { return h.Projects },
+			func() repository.ISessionRepository { return h.Sessions },
+			func() repository.IRoleRepository { return h.Roles },
+			func() repository.IUserRoleRepository { return h.UserRoles },
+			func() repository.IRoleHistoryRepository { return h.RoleHistory },
+			func() repository.IRelationTupleRepository { return h.RelationTuple },
+			func() repository.IRelationNamespaceRepository { return h.Namespaces },
+			func() repository.IPermissionRepository { return h.Permissions },
+			func() repository.IUserCredentialRepository { return h.Credentials },
+			func() repository.IAccessPolicyRepository { return h.AccessPolicies },
+			func() repository.IAccessDenialRepository { return h.AccessDenials },
+			func() repository.ITrustedDeviceRepository { return h.TrustedDevices },
+			func() repository.IUserDeviceRepository { return h.UserDevices },
+			func() repository.IImpersonationRepository { return h.Impersonations },
+			func() repository.ISAMLConnectionRepository { return h.SAMLConnections },
+			func() repository.ISigningKeyRepository { return h.SigningKeys },
+			func() repository.IRevokedTokenRepository { return h.RevokedTokens },
+			func() repository.IAPIKeyRepository { return h.APIKeys },
+			func() repository.IProjectMemberRepository { return h.ProjectMembers },
+			func() repository.INotificationTemplateRepository { return h.Templates },
+			func() repository.IUserIdentityRepository { return h.UserIdentities },
+			func() repository.IServiceAccountRepository { return h.ServiceAccounts },
+			func() repository.ISCIMUserRepository { return h.SCIMUsers },
+			func() repository.ITxManager { return testutil.TxManager{} },
+			func() repository.IHealthRepository { return h.Database },
+			func() repository.IIPBlockRepository { return h.IPBlocks },
+			func() repository.IEffectiveAccessRepository { return h.EffectiveAccess },
+		),
+		fx.Invoke(service.RegisterSigningKeyReload),
+		fx.Invoke(service.RegisterBackgroundHooks),
+		fx.Invoke(service.RegisterHealthHooks),
+		fx.Populate(&server, &h.Relations, &h.Privacy, &h.EffectiveAccessSvc),
+	)
+	app.RequireStart()
+	t.Cleanup(app.RequireStop)
+
+	h.HTTP = server
+	h.Server = httptest.NewServer(server)
+	t.Cleanup(h.Server.Close)
+	return h
+}
+
+// Token issues an access token for payload that the server will accept.
+func (h *Harness) Token(payload jwt.Payload) string {
+	return h.Jwt.Issue(payload)
+}
+
+// Do sends a request to the server. body is JSON-encoded when non-nil; token is sent as a Bearer token when non-empty.
+func (h *Harness) Do(t testing.TB, method, path string, body any, token string) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal request body: %v", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, h.Server.URL+path, reader)
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := h.Server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+// Decode reads a BaseResp envelope and unmarshals its data into out (which may be nil).
+func Decode(t testing.TB, resp *http.Response, out any) handler.BaseResp {
+	t.Helper()
+	var envelope struct {
+		handler.BaseResp
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if out != nil && len(envelope.Data) > 0 {
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			t.Fatalf("decode response data: %v", err)
+		}
+	}
+	return envelope.BaseResp
+}
+
+// Eventually fails the test unless cond holds within a second, for changes applied in the background.
+func Eventually(t testing.TB, cond func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func defaultConfig() *config.AppConfig {
+	cfg := &config.AppConfig{}
+	cfg.App.Name = "dreon-auth-test"
+	cfg.Jwt.AccessTokenExpiresIn = 3600
+	cfg.Jwt.RefreshTokenExpiresIn = 86400
+	cfg.OAuth.StateSecret = "test-state-secret"
+	// Where project-less external logins in tests send the browser back to
+	cfg.OAuth.RedirectURLs = "https://app.example.com/cb"
+	// Tests sign in many times from one address; rate limit tests turn it back on
+	cfg.RateLimit.Disabled = true
+	// Requests reach the test server from loopback, which stands in for the proxy: tests pick the client IP
+	// with X-Forwarded-For
+	cfg.Server.TrustedProxyCIDRs = "127.0.0.1"
+	return cfg
+}
+
+var (
+	keySetKeyOnce sync.Once
+	keySetKey     *rsa.PrivateKey
+)
+
+// newKeySet returns a fresh key set over an RSA key generated once per test binary.
+func newKeySet(t testing.TB) *jwt.KeySet {
+	t.Helper()
+	keySetKeyOnce.Do(func() { keySetKey, _ = rsa.GenerateKey(rand.Reader, 2048) })
+	keys, err := jwt.NewKeySet(jwt.Key{PrivateKey: keySetKey})
+	if err != nil {
+		t.Fatalf("key set: %v", err)
+	}
+	return keys
+}
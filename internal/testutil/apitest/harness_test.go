@@ -0,0 +1,207 @@
+package apitest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestHarness_RegisterAndGetSession(t *testing.T) {
+	h := New(t)
+
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{
+		Email:    "alice@example.com",
+		Password: "password123",
+	}, "")
+	var tokens aggregate.TokenResp
+	if body := Decode(t, resp, &tokens); resp.StatusCode != http.StatusOK {
+		t.Fatalf("register status = %d, body = %+v", resp.StatusCode, body)
+	}
+	if tokens.AccessToken == "" || h.Users.Len() != 1 || h.Sessions.Len() != 1 {
+		t.Fatalf("register did not persist user/session: tokens=%+v users=%d sessions=%d", tokens, h.Users.Len(), h.Sessions.Len())
+	}
+
+	resp = h.Do(t, http.MethodGet, "/api/v1/auth/session", nil, tokens.AccessToken)
+	var session aggregate.SessionResp
+	Decode(t, resp, &session)
+	if resp.StatusCode != http.StatusOK || session.Email != "alice@example.com" {
+		t.Errorf("session status = %d, session = %+v", resp.StatusCode, session)
+	}
+}
+
+func TestHarness_CursorPagination(t *testing.T) {
+	h := New(t)
+	ctx := context.Background()
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	user, _ := h.Users.Create(ctx, &model.User{Username: "ivan", Email: "ivan@example.com"})
+	for i := range 5 {
+		h.Roles.Create(ctx, &model.Role{Code: fmt.Sprintf("role-%d", i), Name: fmt.Sprintf("Role %d", i), IsActive: true})
+		h.Sessions.Create(ctx, &model.Session{UserID: user.ID, IsActive: true, ExpiresAt: time.Now().Add(time.Hour)})
+		h.RelationTuple.Create(ctx, &model.RelationTuple{Namespace: "doc", ObjectID: fmt.Sprintf("doc-%d", i), Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: user.ID})
+	}
+
+	type item struct {
+		ID string `json:"id"`
+	}
+	list := func(url string) aggregate.PaginationResp[item] {
+		t.Helper()
+		resp := h.Do(t, http.MethodGet, url, nil, admin)
+		var result aggregate.PaginationResp[item]
+		Decode(t, resp, &result)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: status %d", url, resp.StatusCode)
+		}
+		return result
+	}
+	lists := []struct {
+		path, filter string
+		want         int
+	}{
+		{"/api/v1/roles", "", 5},
+		{"/api/v1/users", "", 1},
+		{"/api/v1/users/" + user.ID + "/sessions", "", 5},
+		{"/api/v1/relations/list", "&namespace=doc&relation=viewer", 5},
+	}
+	for _, l := range lists {
+		// Start by page number and follow nextCursor to the end; the walk must see every item once, in the
+		// order of a single large page.
+		all := list(l.path + "?page=1&pageSize=100" + l.filter)
+		if int(all.Total) != l.want || all.HasNext || all.NextCursor != "" {
+			t.Errorf("%s: total %d, hasNext %v, want %d items on one page", l.path, all.Total, all.HasNext, l.want)
+		}
+		var walked []item
+		page := list(l.path + "?page=1&pageSize=2" + l.filter)
+		for range l.want {
+			walked = append(walked, page.Items...)
+			if page.HasNext != (page.NextCursor != "") {
+				t.Fatalf("%s: hasNext %v with nextCursor %q", l.path, page.HasNext, page.NextCursor)
+			}
+			if !page.HasNext {
+				break
+			}
+			page = list(l.path + "?pageSize=2&cursor=" + page.NextCursor + l.filter)
+		}
+		if !slices.Equal(walked, all.Items) {
+			t.Errorf("%s: cursor walk %v, want %v", l.path, walked, all.Items)
+		}
+	}
+
+	if resp := h.Do(t, http.MethodGet, "/api/v1/roles?cursor=not-a-cursor", nil, admin); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid cursor: status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestHarness_EffectiveAccess(t *testing.T) {
+	h := New(t, WithConfig(func(cfg *config.AppConfig) { cfg.EffectiveAccess.Mode = constant.EffectiveAccessServe }))
+	ctx := context.Background()
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	user, _ := h.Users.Create(ctx, &model.User{Username: "gina", Email: "gina@example.com"})
+	viewer, _ := h.Roles.Create(ctx, &model.Role{Code: "viewer", Name: "Viewer", Permissions: model.PermissionsToJSON([]string{"users.view"})})
+	auditor, _ := h.Roles.Create(ctx, &model.Role{Code: "auditor", Name: "Auditor", Permissions: model.PermissionsToJSON([]string{"audit.read"})})
+
+	permissions := func() aggregate.UserPermissions {
+		t.Helper()
+		var permissions aggregate.UserPermissions
+		Decode(t, h.Do(t, http.MethodGet, "/api/v1/roles/user/"+user.ID+"/permissions", nil, admin), &permissions)
+		return permissions
+	}
+
+	// Assignments written straight to the store have no rows until the table is rebuilt.
+	h.UserRoles.Create(ctx, &model.UserRole{UserID: user.ID, RoleID: viewer.ID})
+	if rows, _ := h.EffectiveAccessSvc.UserPermissions(ctx, user.ID); len(rows) != 0 {
+		t.Fatalf("rows before rebuild = %+v, want none", rows)
+	}
+	stale, _ := h.EffectiveAccess.Create(ctx, &model.EffectiveAccess{Kind: constant.EffectiveAccessRelation, Subject: "user:ghost", Resource: "document:gone#viewer"})
+	result, err := h.EffectiveAccessSvc.Rebuild(ctx)
+	if err != nil || result.Users != 1 || result.Removed != 1 {
+		t.Fatalf("rebuild = %+v, %v, want one user and the stale row removed", result, err)
+	}
+	if h.EffectiveAccess.First(func(m *model.EffectiveAccess) bool { return m.ID == stale.ID }) != nil {
+		t.Error("stale row survived the rebuild")
+	}
+	if got := permissions(); !got["system/users.view"] {
+		t.Errorf("permissions after rebuild = %v, want system/users.view", got)
+	}
+
+	assign := aggregate.BulkAssignRolesReq{Assignments: []aggregate.AssignRoleToUserReq{{UserID: user.ID, RoleID: auditor.ID}}}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/roles/bulk-assign", assign, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("bulk assign: status %d", resp.StatusCode)
+	}
+	if got := permissions(); !got["system/users.view"] || !got["system/audit.read"] {
+		t.Errorf("permissions after assign = %v, want users.view and audit.read", got)
+	}
+
+	grant := func(tuple string) {
+		t.Helper()
+		object, subject, _ := strings.Cut(tuple, "@")
+		object, relation, _ := strings.Cut(object, "#")
+		ns, id, _ := strings.Cut(object, ":")
+		subject, subjectRelation, _ := strings.Cut(subject, "#")
+		subjectNs, subjectID, _ := strings.Cut(subject, ":")
+		resp := h.Do(t, http.MethodPost, "/api/v1/relations/grant", aggregate.GrantRelationReq{
+			Namespace: ns, ObjectID: id, Relation: relation, SubjectNamespace: subjectNs, SubjectObjectID: subjectID, SubjectRelation: subjectRelation,
+		}, admin)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("grant %s: status %d", tuple, resp.StatusCode)
+		}
+	}
+	held := func(resource, subject string) bool {
+		return h.EffectiveAccess.First(func(m *model.EffectiveAccess) bool {
+			return m.Kind == constant.EffectiveAccessRelation && m.Resource == resource && m.Subject == subject
+		}) != nil
+	}
+	check := func(subjectID string) bool {
+		t.Helper()
+		var result aggregate.CheckRelationResp
+		Decode(t, h.Do(t, http.MethodPost, "/api/v1/relations/check", aggregate.CheckRelationReq{
+			Namespace: "document", ObjectID: "readme", Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: subjectID,
+		}, admin), &result)
+		return result.Allowed
+	}
+
+	// Granting the userset first, then its member, still reaches the document's row.
+	grant("document:readme#viewer@group:eng#member")
+	grant("group:eng#member@user:hana")
+	if !held("document:readme#viewer", "user:hana") || !check("hana") {
+		t.Error("group member has no row or check for the document")
+	}
+
+	resp := h.Do(t, http.MethodPost, "/api/v1/relations/revoke", aggregate.RevokeRelationReq{
+		Namespace: "group", ObjectID: "eng", Relation: "member", SubjectNamespace: "user", SubjectObjectID: "hana",
+	}, admin)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("revoke: status %d", resp.StatusCode)
+	}
+	if held("document:readme#viewer", "user:hana") || check("hana") {
+		t.Error("revoked member still holds the document")
+	}
+
+	// A rewrite rule moves the relation to the full check.
+	grant("document:readme#viewer@user:ivan")
+	config := map[string]any{"relations": map[string]any{
+		"editor": map[string]any{},
+		"viewer": map[string]any{"union": []any{
+			map[string]any{"this": true},
+			map[string]any{"computedUserset": map[string]any{"relation": "editor"}},
+		}},
+	}}
+	if resp := h.Do(t, http.MethodPut, "/api/v1/relations/namespaces/document", config, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("upsert namespace: status %d", resp.StatusCode)
+	}
+	if held("document:readme#viewer", "user:ivan") {
+		t.Error("relation with a rewrite rule kept its rows")
+	}
+	if !check("ivan") {
+		t.Error("direct viewer denied once the full check decides")
+	}
+}
@@ -0,0 +1,47 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hiamthach108/dreon-auth/pkg/breach"
+)
+
+// BreachChecker is an in-memory breach.IChecker that knows the passwords added to it.
+type BreachChecker struct {
+	mu       sync.Mutex
+	breached map[string]bool
+	err      error
+}
+
+var _ breach.IChecker = (*BreachChecker)(nil)
+
+// NewBreachChecker returns a checker that knows no breached passwords.
+func NewBreachChecker() *BreachChecker {
+	return &BreachChecker{breached: map[string]bool{}}
+}
+
+func (c *BreachChecker) IsBreached(ctx context.Context, password string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return false, c.err
+	}
+	return c.breached[password], nil
+}
+
+// Add marks passwords as breached.
+func (c *BreachChecker) Add(passwords ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range passwords {
+		c.breached[p] = true
+	}
+}
+
+// SetErr makes every check fail with err, as when no breach source answers; nil restores it.
+func (c *BreachChecker) SetErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
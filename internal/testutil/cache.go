@@ -0,0 +1,40 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+)
+
+// Cache is the in-memory cache.ICache the harness runs on. Keys and SetClock let tests inspect and age
+// what services cached; PingErr makes the store look unreachable to the readiness probe, and SetNXErr fails
+// conditional writes.
+type Cache struct {
+	*cache.MemoryCache
+	PingErr  error
+	SetNXErr error
+}
+
+var _ cache.ICache = (*Cache)(nil)
+
+// NewCache returns an empty in-memory cache.
+func NewCache() *Cache {
+	return &Cache{MemoryCache: cache.NewMemoryCache()}
+}
+
+func (c *Cache) Ping() error {
+	return c.PingErr
+}
+
+func (c *Cache) SetNX(key string, value any, expireTime *time.Duration) (bool, error) {
+	if c.SetNXErr != nil {
+		return false, c.SetNXErr
+	}
+	return c.MemoryCache.SetNX(key, value, expireTime)
+}
+
+// WithContext returns c, so PingErr and SetNXErr still apply.
+func (c *Cache) WithContext(ctx context.Context) cache.ICache {
+	return c
+}
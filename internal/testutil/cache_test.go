@@ -0,0 +1,42 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+)
+
+func TestCache_SetGetExpire(t *testing.T) {
+	c := NewCache()
+	now := time.Now()
+	c.SetClock(func() time.Time { return now })
+
+	ttl := time.Minute
+	if err := c.Set("perm:u1", map[string]bool{"system/view": true}, &ttl); err != nil {
+		t.Fatalf("Set() err = %v", err)
+	}
+	var got map[string]bool
+	if err := c.Get("perm:u1", &got); err != nil || !got["system/view"] {
+		t.Fatalf("Get() = %v, %v", got, err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := c.Get("perm:u1", &got); err != cache.ErrCacheNil {
+		t.Errorf("Get() after expiry err = %v, want ErrCacheNil", err)
+	}
+}
+
+func TestCache_ClearWithPrefix(t *testing.T) {
+	c := NewCache()
+	ttl := time.Minute
+	_ = c.Set("a:1", 1, &ttl)
+	_ = c.Set("a:2", 2, &ttl)
+	_ = c.Set("b:1", 3, &ttl)
+	if err := c.ClearWithPrefix("a:"); err != nil {
+		t.Fatalf("ClearWithPrefix() err = %v", err)
+	}
+	if keys := c.Keys(); len(keys) != 1 || keys[0] != "b:1" {
+		t.Errorf("Keys() = %v, want [b:1]", keys)
+	}
+}
@@ -0,0 +1,41 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hiamthach108/dreon-auth/pkg/captcha"
+)
+
+// CaptchaToken is the only token CaptchaVerifier accepts.
+const CaptchaToken = "captcha-ok"
+
+// CaptchaVerifier is an in-memory captcha.IVerifier that accepts CaptchaToken and counts the checks.
+type CaptchaVerifier struct {
+	mu     sync.Mutex
+	checks int
+}
+
+var _ captcha.IVerifier = (*CaptchaVerifier)(nil)
+
+// NewCaptchaVerifier returns a verifier that has not checked any token yet.
+func NewCaptchaVerifier() *CaptchaVerifier {
+	return &CaptchaVerifier{}
+}
+
+func (v *CaptchaVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.checks++
+	if token != CaptchaToken {
+		return captcha.ErrRejected
+	}
+	return nil
+}
+
+// Checks returns how many tokens were checked so far.
+func (v *CaptchaVerifier) Checks() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.checks
+}
@@ -0,0 +1,9 @@
+// Package testutil provides in-memory fakes for the cache, JWT manager, logger and repositories
+// so services and handlers can be tested without Postgres or Redis.
+//
+// The fakes mirror the behaviour of the real implementations where tests are likely to depend on it:
+// repositories return nil (not an error) when a record is missing, enforce unique columns,
+// and honour the field list passed to Update; the cache returns cache.ErrCacheNil on a miss.
+//
+// See the apitest subpackage for an httptest harness that wires the full Echo server on top of these fakes.
+package testutil
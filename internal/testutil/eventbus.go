@@ -0,0 +1,45 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
+)
+
+// EventPublisher is an in-memory eventbus.IPublisher that records every event instead of publishing it.
+type EventPublisher struct {
+	mu        sync.Mutex
+	published []eventbus.Event
+}
+
+var _ eventbus.IPublisher = (*EventPublisher)(nil)
+
+// NewEventPublisher returns an empty recording publisher.
+func NewEventPublisher() *EventPublisher {
+	return &EventPublisher{}
+}
+
+func (p *EventPublisher) Publish(ctx context.Context, event eventbus.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.published = append(p.published, event)
+	return nil
+}
+
+func (p *EventPublisher) Close() error {
+	return nil
+}
+
+// Published returns the events of the given type published so far, oldest first; all events when eventType is empty.
+func (p *EventPublisher) Published(eventType string) []eventbus.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []eventbus.Event
+	for _, e := range p.published {
+		if eventType == "" || e.Type == eventType {
+			out = append(out, e)
+		}
+	}
+	return out
+}
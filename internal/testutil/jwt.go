@@ -0,0 +1,115 @@
+package testutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+// JwtTokenManager is an in-memory jwt.IJwtTokenManager. Tokens are opaque ids mapped to their payload;
+// unknown or expired tokens fail verification with jwt.ErrInvalidToken, like the RS256 manager.
+type JwtTokenManager struct {
+	mu     sync.Mutex
+	seq    int
+	tokens map[string]issuedToken
+	signed []gojwt.Claims
+	// signedTokens holds every token string returned by SignClaims.
+	signedTokens map[string]bool
+	now          func() time.Time
+}
+
+type issuedToken struct {
+	payload   jwt.Payload
+	expiresAt time.Time
+}
+
+var _ jwt.IJwtTokenManager = (*JwtTokenManager)(nil)
+
+// NewJwtTokenManager returns an empty fake token manager.
+func NewJwtTokenManager() *JwtTokenManager {
+	return &JwtTokenManager{
+		tokens:       make(map[string]issuedToken),
+		signedTokens: make(map[string]bool),
+		now:          time.Now,
+	}
+}
+
+// Generate issues a new opaque token for payload.
+func (m *JwtTokenManager) Generate(ctx context.Context, payload jwt.Payload, expiry time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	token := fmt.Sprintf("test-token-%d", m.seq)
+	payload.TokenID = fmt.Sprintf("jti-%d", m.seq)
+	payload.ExpiresAt = m.now().Add(expiry)
+	m.tokens[token] = issuedToken{payload: payload, expiresAt: payload.ExpiresAt}
+	return token, nil
+}
+
+// Verify returns the payload of a token previously issued by Generate.
+func (m *JwtTokenManager) Verify(ctx context.Context, tokenString string) (*jwt.Payload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokens[tokenString]
+	if !ok || !m.now().Before(t.expiresAt) {
+		return nil, jwt.ErrInvalidToken
+	}
+	p := t.payload
+	return &p, nil
+}
+
+// SignClaims returns the claims JSON-encoded; they are not verifiable by Verify.
+// Signed claims are recorded and can be inspected with SignedClaims.
+func (m *JwtTokenManager) SignClaims(ctx context.Context, claims gojwt.Claims) (string, error) {
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.signed = append(m.signed, claims)
+	m.signedTokens[string(b)] = true
+	return string(b), nil
+}
+
+// VerifyClaims decodes a token returned by SignClaims, checking its exp claim against the clock.
+func (m *JwtTokenManager) VerifyClaims(ctx context.Context, tokenString string, claims gojwt.Claims) error {
+	m.mu.Lock()
+	signed, now := m.signedTokens[tokenString], m.now()
+	m.mu.Unlock()
+	if !signed {
+		return jwt.ErrInvalidToken
+	}
+	if err := json.Unmarshal([]byte(tokenString), claims); err != nil {
+		return jwt.ErrInvalidToken
+	}
+	if exp, err := claims.GetExpirationTime(); err != nil || (exp != nil && !now.Before(exp.Time)) {
+		return jwt.ErrInvalidToken
+	}
+	return nil
+}
+
+// SignedClaims returns every claims value passed to SignClaims, in order.
+func (m *JwtTokenManager) SignedClaims() []gojwt.Claims {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]gojwt.Claims(nil), m.signed...)
+}
+
+// Issue is a convenience wrapper around Generate with a one hour expiry.
+func (m *JwtTokenManager) Issue(payload jwt.Payload) string {
+	token, _ := m.Generate(context.Background(), payload, time.Hour)
+	return token
+}
+
+// SetClock overrides the clock used to check expiry.
+func (m *JwtTokenManager) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
@@ -0,0 +1,30 @@
+package testutil
+
+import (
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Logger is a logger.ILogger that discards everything.
+type Logger struct{}
+
+var _ logger.ILogger = Logger{}
+
+// NewLogger returns a no-op logger.
+func NewLogger() logger.ILogger {
+	return Logger{}
+}
+
+func (Logger) Debug(msg string, fields ...any) {}
+func (Logger) Info(msg string, fields ...any)  {}
+func (Logger) Warn(msg string, fields ...any)  {}
+func (Logger) Error(msg string, fields ...any) {}
+func (Logger) Fatal(msg string, fields ...any) {}
+
+func (l Logger) With(fields ...any) logger.ILogger {
+	return l
+}
+
+func (Logger) GetZapLogger() *zap.Logger {
+	return zap.NewNop()
+}
@@ -0,0 +1,35 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hiamthach108/dreon-auth/pkg/mailer"
+)
+
+// Mailer is an in-memory mailer.IMailer that records every message instead of sending it.
+type Mailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+var _ mailer.IMailer = (*Mailer)(nil)
+
+// NewMailer returns an empty recording mailer.
+func NewMailer() *Mailer {
+	return &Mailer{}
+}
+
+func (m *Mailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Sent returns the messages sent so far, oldest first.
+func (m *Mailer) Sent() []mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mailer.Message(nil), m.sent...)
+}
@@ -0,0 +1,1034 @@
+package testutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+)
+
+// UserRepository is an in-memory repository.IUserRepository.
+type UserRepository struct {
+	*Store[model.User]
+}
+
+var _ repository.IUserRepository = (*UserRepository)(nil)
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{Store: NewStore(func(m *model.User) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *UserRepository) List(ctx context.Context, page repository.Page) ([]model.User, int64, error) {
+	all, _ := r.FindAll(ctx)
+	return r.Page(all, page), int64(len(all)), nil
+}
+
+func (r *UserRepository) ListAfter(ctx context.Context, afterID string, limit int) ([]model.User, error) {
+	users := r.Filter(func(m *model.User) bool { return m.ID > afterID })
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return paginate(users, 0, limit), nil
+}
+
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.First(func(m *model.User) bool { return m.Email == email }), nil
+}
+
+func (r *UserRepository) FindByPhone(ctx context.Context, phone string) (*model.User, error) {
+	return r.First(func(m *model.User) bool { return m.Phone == phone }), nil
+}
+
+func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
+	return r.First(func(m *model.User) bool { return m.Username == username }), nil
+}
+
+func (r *UserRepository) FindErasedBefore(ctx context.Context, before time.Time) ([]model.User, error) {
+	return r.FilterUnscoped(func(m *model.User) bool { return m.ErasedAt != nil && m.ErasedAt.Before(before) }), nil
+}
+
+// SuperAdminRepository is an in-memory repository.ISuperAdminRepository.
+type SuperAdminRepository struct {
+	*Store[model.SuperAdmin]
+}
+
+var _ repository.ISuperAdminRepository = (*SuperAdminRepository)(nil)
+
+func NewSuperAdminRepository() *SuperAdminRepository {
+	return &SuperAdminRepository{Store: NewStore(func(m *model.SuperAdmin) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *SuperAdminRepository) FindByEmail(ctx context.Context, email string) (*model.SuperAdmin, error) {
+	return r.First(func(m *model.SuperAdmin) bool { return m.Email == email && m.IsActive }), nil
+}
+
+// ProjectRepository is an in-memory repository.IProjectRepository.
+type ProjectRepository struct {
+	*Store[model.Project]
+}
+
+var _ repository.IProjectRepository = (*ProjectRepository)(nil)
+
+func NewProjectRepository() *ProjectRepository {
+	return &ProjectRepository{Store: NewStore(func(m *model.Project) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *ProjectRepository) List(ctx context.Context, offset, limit int) ([]model.Project, int64, error) {
+	all, _ := r.FindAll(ctx)
+	return paginate(all, offset, limit), int64(len(all)), nil
+}
+
+func (r *ProjectRepository) FindByCode(ctx context.Context, code string) (*model.Project, error) {
+	return r.First(func(m *model.Project) bool { return m.Code == code }), nil
+}
+
+func (r *ProjectRepository) FindArchivedBefore(ctx context.Context, before time.Time) ([]model.Project, error) {
+	return r.Filter(func(m *model.Project) bool { return m.ArchivedAt != nil && m.ArchivedAt.Before(before) }), nil
+}
+
+// SessionRepository is an in-memory repository.ISessionRepository.
+type SessionRepository struct {
+	*Store[model.Session]
+}
+
+var _ repository.ISessionRepository = (*SessionRepository)(nil)
+
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{Store: NewStore(func(m *model.Session) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *SessionRepository) IsActive(ctx context.Context, id string) (bool, error) {
+	session := r.FindOneById(ctx, id)
+	return session != nil && session.IsActive, nil
+}
+
+func (r *SessionRepository) FindByRefreshTokenHash(ctx context.Context, refreshTokenHash string) *model.Session {
+	return r.First(func(m *model.Session) bool { return m.RefreshTokenHash == refreshTokenHash })
+}
+
+func (r *SessionRepository) FindActiveByUserID(ctx context.Context, userID string) ([]model.Session, error) {
+	return r.Filter(func(m *model.Session) bool { return m.UserID == userID && m.IsActive }), nil
+}
+
+func (r *SessionRepository) FindByUserID(ctx context.Context, userID string) ([]model.Session, error) {
+	sessions := r.Filter(func(m *model.Session) bool { return m.UserID == userID })
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+func (r *SessionRepository) ListByUserID(ctx context.Context, userID string, page repository.Page) ([]model.Session, int64, error) {
+	sessions := r.Filter(func(m *model.Session) bool { return m.UserID == userID })
+	return r.Page(sessions, page), int64(len(sessions)), nil
+}
+
+func (r *SessionRepository) Search(ctx context.Context, filter repository.SessionFilter, page repository.Page) ([]model.Session, int64, error) {
+	now := time.Now()
+	sessions := r.Filter(func(m *model.Session) bool {
+		var meta struct {
+			IP string `json:"ip"`
+		}
+		_ = json.Unmarshal(m.Metadata, &meta)
+		active := m.IsActive && m.ExpiresAt.After(now)
+		return (filter.UserID == "" || m.UserID == filter.UserID) &&
+			(filter.IP == "" || meta.IP == filter.IP) &&
+			(filter.From.IsZero() || !m.CreatedAt.Before(filter.From)) &&
+			(filter.To.IsZero() || m.CreatedAt.Before(filter.To)) &&
+			(filter.Active == nil || *filter.Active == active)
+	})
+	return r.Page(sessions, page), int64(len(sessions)), nil
+}
+
+func (r *SessionRepository) CountDailyUsers(ctx context.Context, from, to time.Time) ([]repository.DailyCount, error) {
+	users := map[time.Time]map[string]bool{}
+	for _, m := range r.Filter(func(m *model.Session) bool {
+		return !m.IsSuperAdmin && !m.CreatedAt.Before(from) && m.CreatedAt.Before(to)
+	}) {
+		day := m.CreatedAt.UTC().Truncate(24 * time.Hour)
+		if users[day] == nil {
+			users[day] = map[string]bool{}
+		}
+		users[day][m.UserID] = true
+	}
+	results := make([]repository.DailyCount, 0, len(users))
+	for day, ids := range users {
+		results = append(results, repository.DailyCount{Day: day, Count: int64(len(ids))})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Day.Before(results[j].Day) })
+	return results, nil
+}
+
+func (r *SessionRepository) CountLoginsByMethod(ctx context.Context, from, to time.Time) (map[string]int64, error) {
+	results := map[string]int64{}
+	for _, m := range r.Filter(func(m *model.Session) bool { return !m.CreatedAt.Before(from) && m.CreatedAt.Before(to) }) {
+		var meta struct {
+			LoginMethod string `json:"login_method"`
+		}
+		if json.Unmarshal(m.Metadata, &meta) == nil && meta.LoginMethod != "" {
+			results[meta.LoginMethod]++
+		}
+	}
+	return results, nil
+}
+
+func (r *SessionRepository) DeleteByUserID(ctx context.Context, userID string) error {
+	r.DeleteWhere(func(m *model.Session) bool { return m.UserID == userID })
+	return nil
+}
+
+func (r *SessionRepository) DeleteStale(ctx context.Context, t time.Time) (int64, error) {
+	return r.DeleteWhere(func(m *model.Session) bool {
+		return m.ExpiresAt.Before(t) || (!m.IsActive && m.UpdatedAt.Before(t))
+	}), nil
+}
+
+// RoleRepository is an in-memory repository.IRoleRepository.
+type RoleRepository struct {
+	*Store[model.Role]
+}
+
+var _ repository.IRoleRepository = (*RoleRepository)(nil)
+
+func NewRoleRepository() *RoleRepository {
+	return &RoleRepository{Store: NewStore(func(m *model.Role) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *RoleRepository) FindByCode(ctx context.Context, code string) (*model.Role, error) {
+	return r.First(func(m *model.Role) bool { return m.Code == code }), nil
+}
+
+func (r *RoleRepository) FindByProjectID(ctx context.Context, projectID *string, limit, offset int) ([]model.Role, int64, error) {
+	roles := r.Filter(func(m *model.Role) bool { return sameProject(m.ProjectID, projectID) })
+	return paginate(roles, offset, limit), int64(len(roles)), nil
+}
+
+func (r *RoleRepository) FindSystemRoles(ctx context.Context, limit, offset int) ([]model.Role, int64, error) {
+	system := constant.SystemProjectID
+	return r.FindByProjectID(ctx, &system, limit, offset)
+}
+
+func (r *RoleRepository) SearchRoles(ctx context.Context, search string, projectID *string, isActive *bool, page repository.Page) ([]model.Role, int64, error) {
+	needle := strings.ToLower(search)
+	roles := r.Filter(func(m *model.Role) bool {
+		if search != "" && !strings.Contains(strings.ToLower(m.Code), needle) && !strings.Contains(strings.ToLower(m.Name), needle) {
+			return false
+		}
+		if projectID != nil && !sameProject(m.ProjectID, projectID) {
+			return false
+		}
+		if isActive != nil && m.IsActive != *isActive {
+			return false
+		}
+		return true
+	})
+	return r.Page(roles, page), int64(len(roles)), nil
+}
+
+func (r *RoleRepository) IsSystemRole(ctx context.Context, roleID string) (bool, error) {
+	role := r.FindOneById(ctx, roleID)
+	return role != nil && role.ProjectID != nil && *role.ProjectID == constant.SystemProjectID, nil
+}
+
+// UserRoleRepository is an in-memory repository.IUserRoleRepository.
+// When built with a RoleRepository, Role is populated on reads that preload it in SQL.
+type UserRoleRepository struct {
+	*Store[model.UserRole]
+	roles *RoleRepository
+}
+
+var _ repository.IUserRoleRepository = (*UserRoleRepository)(nil)
+
+func NewUserRoleRepository(roles *RoleRepository) *UserRoleRepository {
+	return &UserRoleRepository{
+		Store: NewStore(func(m *model.UserRole) *model.BaseModel { return &m.BaseModel }),
+		roles: roles,
+	}
+}
+
+func (r *UserRoleRepository) FindByUserID(ctx context.Context, userID string) ([]model.UserRole, error) {
+	now := time.Now()
+	return r.withRole(ctx, r.Filter(func(m *model.UserRole) bool { return m.UserID == userID && assignmentActive(m, now) })), nil
+}
+
+func (r *UserRoleRepository) FindByUserIDs(ctx context.Context, userIDs []string) ([]model.UserRole, error) {
+	now := time.Now()
+	return r.withRole(ctx, r.Filter(func(m *model.UserRole) bool {
+		return slices.Contains(userIDs, m.UserID) && assignmentActive(m, now)
+	})), nil
+}
+
+func (r *UserRoleRepository) FindByUserIDAndProjectID(ctx context.Context, userID string, projectID *string) ([]model.UserRole, error) {
+	return r.Filter(func(m *model.UserRole) bool {
+		return m.UserID == userID && sameProject(m.ProjectID, projectID)
+	}), nil
+}
+
+func (r *UserRoleRepository) FindByUserIDAndRoleID(ctx context.Context, userID, roleID string, projectID *string) (*model.UserRole, error) {
+	return r.First(func(m *model.UserRole) bool {
+		return m.UserID == userID && m.RoleID == roleID && sameProject(m.ProjectID, projectID)
+	}), nil
+}
+
+func (r *UserRoleRepository) DeleteByUserIDAndRoleID(ctx context.Context, userID, roleID string, projectID *string) error {
+	r.DeleteWhere(func(m *model.UserRole) bool {
+		return m.UserID == userID && m.RoleID == roleID && sameProject(m.ProjectID, projectID)
+	})
+	return nil
+}
+
+func (r *UserRoleRepository) FindWithRole(ctx context.Context, userID string, projectID *string) ([]model.UserRole, error) {
+	now := time.Now()
+	userRoles := r.Filter(func(m *model.UserRole) bool {
+		return m.UserID == userID && (projectID == nil || sameProject(m.ProjectID, projectID)) && assignmentActive(m, now)
+	})
+	return r.withRole(ctx, userRoles), nil
+}
+
+// FindByRoleID returns the role's assignments; unlike SQL it does not populate User.
+func (r *UserRoleRepository) FindByRoleID(ctx context.Context, roleID string) ([]model.UserRole, error) {
+	return r.Filter(func(m *model.UserRole) bool { return m.RoleID == roleID }), nil
+}
+
+// SaveBatch is not atomic; the services check every assignment before calling it.
+func (r *UserRoleRepository) SaveBatch(ctx context.Context, creates, renewals []model.UserRole) error {
+	if err := r.BulkCreate(ctx, creates); err != nil {
+		return err
+	}
+	for i := range renewals {
+		if err := r.Update(ctx, renewals[i].ID, renewals[i], "expires_at", "updated_at"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *UserRoleRepository) DeleteByIDs(ctx context.Context, ids []string) error {
+	r.DeleteWhere(func(m *model.UserRole) bool { return slices.Contains(ids, m.ID) })
+	return nil
+}
+
+func (r *UserRoleRepository) DeleteExpired(ctx context.Context, t time.Time) ([]model.UserRole, error) {
+	expired := r.Filter(func(m *model.UserRole) bool { return !assignmentActive(m, t) })
+	r.DeleteWhere(func(m *model.UserRole) bool { return !assignmentActive(m, t) })
+	return expired, nil
+}
+
+func assignmentActive(m *model.UserRole, now time.Time) bool {
+	return m.ExpiresAt == nil || m.ExpiresAt.After(now)
+}
+
+func (r *UserRoleRepository) withRole(ctx context.Context, userRoles []model.UserRole) []model.UserRole {
+	if r.roles == nil {
+		return userRoles
+	}
+	for i := range userRoles {
+		if role := r.roles.FindOneById(ctx, userRoles[i].RoleID); role != nil {
+			userRoles[i].Role = *role
+		}
+	}
+	return userRoles
+}
+
+// RelationTupleRepository is an in-memory repository.IRelationTupleRepository.
+type RelationTupleRepository struct {
+	*Store[model.RelationTuple]
+}
+
+var _ repository.IRelationTupleRepository = (*RelationTupleRepository)(nil)
+
+func NewRelationTupleRepository() *RelationTupleRepository {
+	return &RelationTupleRepository{Store: NewStore(func(m *model.RelationTuple) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *RelationTupleRepository) FindByTuple(ctx context.Context, namespace, objectID, relation, subjectNamespace, subjectObjectID, subjectRelation string) (*model.RelationTuple, error) {
+	return r.First(matchTuple(namespace, objectID, relation, subjectNamespace, subjectObjectID, subjectRelation)), nil
+}
+
+func (r *RelationTupleRepository) CheckPermission(ctx context.Context, namespace, objectID, relation, subjectNamespace, subjectObjectID string) (bool, error) {
+	now := time.Now()
+	found := r.First(func(m *model.RelationTuple) bool {
+		return m.Namespace == namespace && m.ObjectID == objectID && m.Relation == relation &&
+			m.SubjectNamespace == subjectNamespace && m.SubjectObjectID == subjectObjectID &&
+			m.IsActive && notExpired(m, now)
+	})
+	return found != nil, nil
+}
+
+func (r *RelationTupleRepository) ListUsersets(ctx context.Context, namespace, objectID, relation string) ([]model.RelationTuple, error) {
+	now := time.Now()
+	return r.Filter(func(m *model.RelationTuple) bool {
+		return m.Namespace == namespace && m.ObjectID == objectID && m.Relation == relation &&
+			m.SubjectRelation != "" && m.IsActive && notExpired(m, now)
+	}), nil
+}
+
+func (r *RelationTupleRepository) FindBySubject(ctx context.Context, subjectNamespace, subjectObjectID, subjectRelation string) ([]model.RelationTuple, error) {
+	now := time.Now()
+	return r.Filter(func(m *model.RelationTuple) bool {
+		return m.SubjectNamespace == subjectNamespace && m.SubjectObjectID == subjectObjectID &&
+			m.SubjectRelation == subjectRelation && m.IsActive && notExpired(m, now)
+	}), nil
+}
+
+func (r *RelationTupleRepository) ListByObject(ctx context.Context, namespace, objectID string, limit, offset int) ([]model.RelationTuple, int64, error) {
+	tuples := r.Filter(func(m *model.RelationTuple) bool { return m.Namespace == namespace && m.ObjectID == objectID })
+	return paginate(tuples, offset, limit), int64(len(tuples)), nil
+}
+
+func (r *RelationTupleRepository) ListBySubject(ctx context.Context, subjectNamespace, subjectObjectID string, limit, offset int) ([]model.RelationTuple, int64, error) {
+	tuples := r.Filter(func(m *model.RelationTuple) bool {
+		return m.SubjectNamespace == subjectNamespace && m.SubjectObjectID == subjectObjectID
+	})
+	return paginate(tuples, offset, limit), int64(len(tuples)), nil
+}
+
+func (r *RelationTupleRepository) ListByRelation(ctx context.Context, namespace, relation string, limit, offset int) ([]model.RelationTuple, int64, error) {
+	tuples := r.Filter(func(m *model.RelationTuple) bool { return m.Namespace == namespace && m.Relation == relation })
+	return paginate(tuples, offset, limit), int64(len(tuples)), nil
+}
+
+// ListWithFilters matches each non-empty filter (keyed by column name) for equality.
+func (r *RelationTupleRepository) ListWithFilters(ctx context.Context, filters map[string]interface{}, page repository.Page) ([]model.RelationTuple, int64, error) {
+	for key := range filters {
+		if r.schema.LookUpField(key) == nil {
+			return nil, 0, fmt.Errorf("testutil: unknown column %q on %s", key, r.schema.Table)
+		}
+	}
+	tuples := r.Filter(func(m *model.RelationTuple) bool {
+		v := reflect.ValueOf(m).Elem()
+		for key, value := range filters {
+			if value == "" || value == nil {
+				continue
+			}
+			if fmt.Sprint(r.schema.LookUpField(key).ReflectValueOf(ctx, v).Interface()) != fmt.Sprint(value) {
+				return false
+			}
+		}
+		return true
+	})
+	return r.Page(tuples, page), int64(len(tuples)), nil
+}
+
+func (r *RelationTupleRepository) FindInBatches(ctx context.Context, filters map[string]interface{}, batchSize int, fn func([]model.RelationTuple) error) error {
+	tuples, _, err := r.ListWithFilters(ctx, filters, repository.Page{Limit: -1})
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(tuples); start += batchSize {
+		if err := fn(tuples[start:min(start+batchSize, len(tuples))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveBatch is not atomic; the services check every tuple before calling it.
+func (r *RelationTupleRepository) SaveBatch(ctx context.Context, creates, updates []model.RelationTuple) error {
+	if err := r.BulkCreate(ctx, creates); err != nil {
+		return err
+	}
+	for i := range updates {
+		if err := r.Update(ctx, updates[i].ID, updates[i], "condition", "expires_at", "is_active", "updated_at"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RelationTupleRepository) ExpandSubjects(ctx context.Context, namespace, objectID, relation string) ([]model.RelationTuple, error) {
+	now := time.Now()
+	return r.Filter(func(m *model.RelationTuple) bool {
+		return m.Namespace == namespace && m.ObjectID == objectID && m.Relation == relation &&
+			m.IsActive && notExpired(m, now)
+	}), nil
+}
+
+func (r *RelationTupleRepository) DeleteByTuple(ctx context.Context, namespace, objectID, relation, subjectNamespace, subjectObjectID, subjectRelation string) error {
+	r.DeleteWhere(matchTuple(namespace, objectID, relation, subjectNamespace, subjectObjectID, subjectRelation))
+	return nil
+}
+
+func (r *RelationTupleRepository) CleanupExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
+	return r.DeleteWhere(func(m *model.RelationTuple) bool { return !notExpired(m, now) }), nil
+}
+
+func matchTuple(namespace, objectID, relation, subjectNamespace, subjectObjectID, subjectRelation string) func(*model.RelationTuple) bool {
+	return func(m *model.RelationTuple) bool {
+		return m.Namespace == namespace && m.ObjectID == objectID && m.Relation == relation &&
+			m.SubjectNamespace == subjectNamespace && m.SubjectObjectID == subjectObjectID &&
+			m.SubjectRelation == subjectRelation
+	}
+}
+
+func notExpired(m *model.RelationTuple, now time.Time) bool {
+	return m.ExpiresAt == nil || m.ExpiresAt.After(now)
+}
+
+// sameProject compares project scopes the way the SQL repositories do: nil matches only NULL.
+func sameProject(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// UserCredentialRepository is an in-memory repository.IUserCredentialRepository.
+type UserCredentialRepository struct {
+	*Store[model.UserCredential]
+}
+
+var _ repository.IUserCredentialRepository = (*UserCredentialRepository)(nil)
+
+func NewUserCredentialRepository() *UserCredentialRepository {
+	return &UserCredentialRepository{Store: NewStore(func(m *model.UserCredential) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *UserCredentialRepository) FindByUserID(ctx context.Context, userID string) ([]model.UserCredential, error) {
+	return r.Filter(func(m *model.UserCredential) bool { return m.UserID == userID }), nil
+}
+
+func (r *UserCredentialRepository) FindByUserIDAndID(ctx context.Context, userID, id string) *model.UserCredential {
+	return r.First(func(m *model.UserCredential) bool { return m.UserID == userID && m.ID == id })
+}
+
+// RelationNamespaceRepository is an in-memory repository.IRelationNamespaceRepository.
+type RelationNamespaceRepository struct {
+	*Store[model.RelationNamespace]
+}
+
+var _ repository.IRelationNamespaceRepository = (*RelationNamespaceRepository)(nil)
+
+func NewRelationNamespaceRepository() *RelationNamespaceRepository {
+	return &RelationNamespaceRepository{Store: NewStore(func(m *model.RelationNamespace) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *RelationNamespaceRepository) FindByName(ctx context.Context, name string) *model.RelationNamespace {
+	return r.First(func(m *model.RelationNamespace) bool { return m.Name == name })
+}
+
+// PermissionRepository is an in-memory repository.IPermissionRepository.
+type PermissionRepository struct {
+	*Store[model.Permission]
+}
+
+var _ repository.IPermissionRepository = (*PermissionRepository)(nil)
+
+func NewPermissionRepository() *PermissionRepository {
+	return &PermissionRepository{Store: NewStore(func(m *model.Permission) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *PermissionRepository) FindByCode(ctx context.Context, projectID *string, code string) *model.Permission {
+	return r.First(func(m *model.Permission) bool { return sameProject(m.ProjectID, projectID) && m.Code == code })
+}
+
+func (r *PermissionRepository) FindByProjectID(ctx context.Context, projectID *string) ([]model.Permission, error) {
+	permissions := r.Filter(func(m *model.Permission) bool { return sameProject(m.ProjectID, projectID) })
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].Code < permissions[j].Code })
+	return permissions, nil
+}
+
+// AccessPolicyRepository is an in-memory repository.IAccessPolicyRepository.
+type AccessPolicyRepository struct {
+	*Store[model.AccessPolicy]
+}
+
+var _ repository.IAccessPolicyRepository = (*AccessPolicyRepository)(nil)
+
+func NewAccessPolicyRepository() *AccessPolicyRepository {
+	return &AccessPolicyRepository{Store: NewStore(func(m *model.AccessPolicy) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *AccessPolicyRepository) FindByProjectID(ctx context.Context, projectID string) *model.AccessPolicy {
+	return r.First(func(m *model.AccessPolicy) bool { return m.ProjectID == projectID })
+}
+
+func (r *AccessPolicyRepository) HasActive(ctx context.Context) (bool, error) {
+	return r.First(func(m *model.AccessPolicy) bool { return m.IsActive }) != nil, nil
+}
+
+// AccessDenialRepository is an in-memory repository.IAccessDenialRepository.
+type AccessDenialRepository struct {
+	*Store[model.AccessDenial]
+}
+
+var _ repository.IAccessDenialRepository = (*AccessDenialRepository)(nil)
+
+func NewAccessDenialRepository() *AccessDenialRepository {
+	return &AccessDenialRepository{Store: NewStore(func(m *model.AccessDenial) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *AccessDenialRepository) ListByProjectID(ctx context.Context, projectID string, offset, limit int) ([]model.AccessDenial, int64, error) {
+	all := r.Filter(func(m *model.AccessDenial) bool { return m.ProjectID == projectID })
+	sort.SliceStable(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+	return paginate(all, offset, limit), int64(len(all)), nil
+}
+
+func (r *AccessDenialRepository) FindByUserID(ctx context.Context, userID string) ([]model.AccessDenial, error) {
+	all := r.Filter(func(m *model.AccessDenial) bool { return m.UserID == userID })
+	sort.SliceStable(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+	return all, nil
+}
+
+func (r *AccessDenialRepository) CountByStage(ctx context.Context, stage string, from, to time.Time) (int64, error) {
+	return int64(len(r.Filter(func(m *model.AccessDenial) bool {
+		return m.Stage == stage && !m.CreatedAt.Before(from) && m.CreatedAt.Before(to)
+	}))), nil
+}
+
+func (r *AccessDenialRepository) AnonymizeByUserID(ctx context.Context, userID string) error {
+	for _, denial := range r.Filter(func(m *model.AccessDenial) bool { return m.UserID == userID }) {
+		if err := r.Update(ctx, denial.ID, model.AccessDenial{}, "email", "ip", "country"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *AccessDenialRepository) DeleteByUserIDs(ctx context.Context, userIDs []string) (int64, error) {
+	return r.DeleteWhere(func(m *model.AccessDenial) bool { return slices.Contains(userIDs, m.UserID) }), nil
+}
+
+// RoleHistoryRepository is an in-memory repository.IRoleHistoryRepository.
+type RoleHistoryRepository struct {
+	*Store[model.RoleHistory]
+}
+
+var _ repository.IRoleHistoryRepository = (*RoleHistoryRepository)(nil)
+
+func NewRoleHistoryRepository() *RoleHistoryRepository {
+	return &RoleHistoryRepository{Store: NewStore(func(m *model.RoleHistory) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *RoleHistoryRepository) ListByRoleID(ctx context.Context, roleID string, offset, limit int) ([]model.RoleHistory, int64, error) {
+	all := r.Filter(func(m *model.RoleHistory) bool { return m.RoleID == roleID })
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].CreatedAt.Equal(all[j].CreatedAt) {
+			return all[i].ID > all[j].ID
+		}
+		return all[i].CreatedAt.After(all[j].CreatedAt)
+	})
+	return paginate(all, offset, limit), int64(len(all)), nil
+}
+
+// BreakGlassCredentialRepository is an in-memory repository.IBreakGlassCredentialRepository.
+type BreakGlassCredentialRepository struct {
+	*Store[model.BreakGlassCredential]
+}
+
+var _ repository.IBreakGlassCredentialRepository = (*BreakGlassCredentialRepository)(nil)
+
+func NewBreakGlassCredentialRepository() *BreakGlassCredentialRepository {
+	return &BreakGlassCredentialRepository{Store: NewStore(func(m *model.BreakGlassCredential) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *BreakGlassCredentialRepository) FindByLabel(ctx context.Context, label string) *model.BreakGlassCredential {
+	return r.First(func(m *model.BreakGlassCredential) bool { return m.Label == label })
+}
+
+func (r *BreakGlassCredentialRepository) MarkUsed(ctx context.Context, id string, usedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credential, ok := r.items[id]
+	if !ok || credential.UsedAt != nil {
+		return false, nil
+	}
+	credential.UsedAt = &usedAt
+	r.items[id] = credential
+	return true, nil
+}
+
+// BreakGlassActivationRepository is an in-memory repository.IBreakGlassActivationRepository.
+type BreakGlassActivationRepository struct {
+	*Store[model.BreakGlassActivation]
+}
+
+var _ repository.IBreakGlassActivationRepository = (*BreakGlassActivationRepository)(nil)
+
+func NewBreakGlassActivationRepository() *BreakGlassActivationRepository {
+	return &BreakGlassActivationRepository{Store: NewStore(func(m *model.BreakGlassActivation) *model.BaseModel { return &m.BaseModel })}
+}
+
+// TrustedDeviceRepository is an in-memory repository.ITrustedDeviceRepository.
+type TrustedDeviceRepository struct {
+	*Store[model.TrustedDevice]
+}
+
+var _ repository.ITrustedDeviceRepository = (*TrustedDeviceRepository)(nil)
+
+func NewTrustedDeviceRepository() *TrustedDeviceRepository {
+	return &TrustedDeviceRepository{Store: NewStore(func(m *model.TrustedDevice) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *TrustedDeviceRepository) FindByUserID(ctx context.Context, userID string) ([]model.TrustedDevice, error) {
+	all := r.Filter(func(m *model.TrustedDevice) bool { return m.UserID == userID })
+	sort.SliceStable(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+	return all, nil
+}
+
+func (r *TrustedDeviceRepository) FindByTokenHash(ctx context.Context, tokenHash string) *model.TrustedDevice {
+	return r.First(func(m *model.TrustedDevice) bool { return m.TokenHash == tokenHash })
+}
+
+func (r *TrustedDeviceRepository) DeleteByUserID(ctx context.Context, userID string) error {
+	r.DeleteWhere(func(m *model.TrustedDevice) bool { return m.UserID == userID })
+	return nil
+}
+
+// UserDeviceRepository is an in-memory repository.IUserDeviceRepository.
+type UserDeviceRepository struct {
+	*Store[model.UserDevice]
+}
+
+var _ repository.IUserDeviceRepository = (*UserDeviceRepository)(nil)
+
+func NewUserDeviceRepository() *UserDeviceRepository {
+	return &UserDeviceRepository{Store: NewStore(func(m *model.UserDevice) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *UserDeviceRepository) FindByUserID(ctx context.Context, userID string) ([]model.UserDevice, error) {
+	all := r.Filter(func(m *model.UserDevice) bool { return m.UserID == userID })
+	sort.SliceStable(all, func(i, j int) bool { return all[i].LastSeenAt.After(all[j].LastSeenAt) })
+	return all, nil
+}
+
+func (r *UserDeviceRepository) FindByFingerprint(ctx context.Context, userID, fingerprint string) *model.UserDevice {
+	return r.First(func(m *model.UserDevice) bool { return m.UserID == userID && m.Fingerprint == fingerprint })
+}
+
+func (r *UserDeviceRepository) DeleteByUserID(ctx context.Context, userID string) error {
+	r.DeleteWhere(func(m *model.UserDevice) bool { return m.UserID == userID })
+	return nil
+}
+
+// ImpersonationRepository is an in-memory repository.IImpersonationRepository.
+type ImpersonationRepository struct {
+	*Store[model.Impersonation]
+}
+
+var _ repository.IImpersonationRepository = (*ImpersonationRepository)(nil)
+
+func NewImpersonationRepository() *ImpersonationRepository {
+	return &ImpersonationRepository{Store: NewStore(func(m *model.Impersonation) *model.BaseModel { return &m.BaseModel })}
+}
+
+// SigningKeyRepository is an in-memory repository.ISigningKeyRepository.
+type SigningKeyRepository struct {
+	*Store[model.SigningKey]
+}
+
+var _ repository.ISigningKeyRepository = (*SigningKeyRepository)(nil)
+
+func NewSigningKeyRepository() *SigningKeyRepository {
+	return &SigningKeyRepository{Store: NewStore(func(m *model.SigningKey) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *SigningKeyRepository) FindUnretired(ctx context.Context) ([]model.SigningKey, error) {
+	return r.Filter(func(m *model.SigningKey) bool { return m.RetiredAt == nil }), nil
+}
+
+func (r *SigningKeyRepository) FindByKID(ctx context.Context, kid string) *model.SigningKey {
+	return r.First(func(m *model.SigningKey) bool { return m.KID == kid })
+}
+
+// RevokedTokenRepository is an in-memory repository.IRevokedTokenRepository.
+type RevokedTokenRepository struct {
+	*Store[model.RevokedToken]
+}
+
+var _ repository.IRevokedTokenRepository = (*RevokedTokenRepository)(nil)
+
+func NewRevokedTokenRepository() *RevokedTokenRepository {
+	return &RevokedTokenRepository{Store: NewStore(func(m *model.RevokedToken) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *RevokedTokenRepository) ExistsByJTI(ctx context.Context, jti string) (bool, error) {
+	return r.First(func(m *model.RevokedToken) bool { return m.JTI == jti }) != nil, nil
+}
+
+func (r *RevokedTokenRepository) DeleteExpired(ctx context.Context, t time.Time) error {
+	r.DeleteWhere(func(m *model.RevokedToken) bool { return m.ExpiresAt.Before(t) })
+	return nil
+}
+
+// SAMLConnectionRepository is an in-memory repository.ISAMLConnectionRepository.
+type SAMLConnectionRepository struct {
+	*Store[model.SAMLConnection]
+}
+
+var _ repository.ISAMLConnectionRepository = (*SAMLConnectionRepository)(nil)
+
+func NewSAMLConnectionRepository() *SAMLConnectionRepository {
+	return &SAMLConnectionRepository{Store: NewStore(func(m *model.SAMLConnection) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *SAMLConnectionRepository) FindByProjectID(ctx context.Context, projectID string) *model.SAMLConnection {
+	return r.First(func(m *model.SAMLConnection) bool { return m.ProjectID == projectID })
+}
+
+// APIKeyRepository is an in-memory repository.IAPIKeyRepository.
+type APIKeyRepository struct {
+	*Store[model.APIKey]
+}
+
+var _ repository.IAPIKeyRepository = (*APIKeyRepository)(nil)
+
+func NewAPIKeyRepository() *APIKeyRepository {
+	return &APIKeyRepository{Store: NewStore(func(m *model.APIKey) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *APIKeyRepository) FindByPrefix(ctx context.Context, prefix string) *model.APIKey {
+	return r.First(func(m *model.APIKey) bool { return m.Prefix == prefix })
+}
+
+func (r *APIKeyRepository) FindByProjectID(ctx context.Context, projectID string) ([]model.APIKey, error) {
+	return r.Filter(func(m *model.APIKey) bool { return m.ProjectID == projectID }), nil
+}
+
+// ProjectMemberRepository is an in-memory repository.IProjectMemberRepository.
+type ProjectMemberRepository struct {
+	*Store[model.ProjectMember]
+}
+
+var _ repository.IProjectMemberRepository = (*ProjectMemberRepository)(nil)
+
+func NewProjectMemberRepository() *ProjectMemberRepository {
+	return &ProjectMemberRepository{Store: NewStore(func(m *model.ProjectMember) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *ProjectMemberRepository) FindByProjectAndUser(ctx context.Context, projectID, userID string) *model.ProjectMember {
+	return r.First(func(m *model.ProjectMember) bool { return m.ProjectID == projectID && m.UserID == userID })
+}
+
+func (r *ProjectMemberRepository) FindByProjectID(ctx context.Context, projectID, status string) ([]model.ProjectMember, error) {
+	return r.Filter(func(m *model.ProjectMember) bool {
+		return m.ProjectID == projectID && (status == "" || m.Status == status)
+	}), nil
+}
+
+func (r *ProjectMemberRepository) FindByUserID(ctx context.Context, userID, status string) ([]model.ProjectMember, error) {
+	return r.Filter(func(m *model.ProjectMember) bool {
+		return m.UserID == userID && (status == "" || m.Status == status)
+	}), nil
+}
+
+// NotificationTemplateRepository is an in-memory repository.INotificationTemplateRepository.
+type NotificationTemplateRepository struct {
+	*Store[model.NotificationTemplate]
+}
+
+var _ repository.INotificationTemplateRepository = (*NotificationTemplateRepository)(nil)
+
+func NewNotificationTemplateRepository() *NotificationTemplateRepository {
+	return &NotificationTemplateRepository{Store: NewStore(func(m *model.NotificationTemplate) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *NotificationTemplateRepository) FindByProjectID(ctx context.Context, projectID string) ([]model.NotificationTemplate, error) {
+	all := r.Filter(func(m *model.NotificationTemplate) bool { return m.ProjectID == projectID })
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if a.Channel != b.Channel {
+			return a.Channel < b.Channel
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Locale < b.Locale
+	})
+	return all, nil
+}
+
+func (r *NotificationTemplateRepository) FindOne(ctx context.Context, projectID string, channel constant.NotificationChannel, name, locale string) *model.NotificationTemplate {
+	return r.First(func(m *model.NotificationTemplate) bool {
+		return m.ProjectID == projectID && m.Channel == channel && m.Name == name && m.Locale == locale
+	})
+}
+
+func (r *NotificationTemplateRepository) DeleteByID(ctx context.Context, id string) error {
+	r.DeleteWhere(func(m *model.NotificationTemplate) bool { return m.ID == id })
+	return nil
+}
+
+// IPBlockRepository is an in-memory repository.IIPBlockRepository.
+type IPBlockRepository struct {
+	*Store[model.IPBlock]
+}
+
+var _ repository.IIPBlockRepository = (*IPBlockRepository)(nil)
+
+func NewIPBlockRepository() *IPBlockRepository {
+	return &IPBlockRepository{Store: NewStore(func(m *model.IPBlock) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *IPBlockRepository) List(ctx context.Context) ([]model.IPBlock, error) {
+	all := r.Filter(func(*model.IPBlock) bool { return true })
+	sort.SliceStable(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+	return all, nil
+}
+
+func (r *IPBlockRepository) FindByCIDR(ctx context.Context, cidr string) *model.IPBlock {
+	return r.First(func(m *model.IPBlock) bool { return m.CIDR == cidr })
+}
+
+// EffectiveAccessRepository is an in-memory repository.IEffectiveAccessRepository.
+type EffectiveAccessRepository struct {
+	*Store[model.EffectiveAccess]
+}
+
+var _ repository.IEffectiveAccessRepository = (*EffectiveAccessRepository)(nil)
+
+func NewEffectiveAccessRepository() *EffectiveAccessRepository {
+	return &EffectiveAccessRepository{Store: NewStore(func(m *model.EffectiveAccess) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *EffectiveAccessRepository) FindBySubject(ctx context.Context, kind, subject string) ([]model.EffectiveAccess, error) {
+	now := time.Now()
+	return r.Filter(func(m *model.EffectiveAccess) bool {
+		return m.Kind == kind && m.Subject == subject && (m.ExpiresAt == nil || m.ExpiresAt.After(now))
+	}), nil
+}
+
+func (r *EffectiveAccessRepository) FindBySubjects(ctx context.Context, kind string, subjects []string) ([]model.EffectiveAccess, error) {
+	now := time.Now()
+	return r.Filter(func(m *model.EffectiveAccess) bool {
+		return m.Kind == kind && slices.Contains(subjects, m.Subject) && (m.ExpiresAt == nil || m.ExpiresAt.After(now))
+	}), nil
+}
+
+func (r *EffectiveAccessRepository) Holds(ctx context.Context, kind, resource, subject string) (bool, error) {
+	now := time.Now()
+	found := r.First(func(m *model.EffectiveAccess) bool {
+		return m.Kind == kind && m.Resource == resource && m.Subject == subject && (m.ExpiresAt == nil || m.ExpiresAt.After(now))
+	})
+	return found != nil, nil
+}
+
+func (r *EffectiveAccessRepository) ReplaceSubject(ctx context.Context, kind, subject string, rows []model.EffectiveAccess) error {
+	r.DeleteWhere(func(m *model.EffectiveAccess) bool { return m.Kind == kind && m.Subject == subject })
+	return r.BulkCreate(ctx, rows)
+}
+
+func (r *EffectiveAccessRepository) ReplaceResource(ctx context.Context, kind, resource string, rows []model.EffectiveAccess) error {
+	r.DeleteWhere(func(m *model.EffectiveAccess) bool { return m.Kind == kind && m.Resource == resource })
+	return r.BulkCreate(ctx, rows)
+}
+
+func (r *EffectiveAccessRepository) DeleteCreatedBefore(ctx context.Context, kind string, t time.Time) (int64, error) {
+	return r.DeleteWhere(func(m *model.EffectiveAccess) bool { return m.Kind == kind && m.CreatedAt.Before(t) }), nil
+}
+
+// Lock does nothing: the store has no transactions to serialize.
+func (r *EffectiveAccessRepository) Lock(ctx context.Context, key string) error {
+	return nil
+}
+
+// ServiceAccountRepository is an in-memory repository.IServiceAccountRepository.
+type ServiceAccountRepository struct {
+	*Store[model.ServiceAccount]
+}
+
+var _ repository.IServiceAccountRepository = (*ServiceAccountRepository)(nil)
+
+func NewServiceAccountRepository() *ServiceAccountRepository {
+	return &ServiceAccountRepository{Store: NewStore(func(m *model.ServiceAccount) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *ServiceAccountRepository) FindByClientID(ctx context.Context, clientID string) *model.ServiceAccount {
+	return r.First(func(m *model.ServiceAccount) bool { return m.ClientID == clientID })
+}
+
+func (r *ServiceAccountRepository) FindByProjectID(ctx context.Context, projectID string) ([]model.ServiceAccount, error) {
+	return r.Filter(func(m *model.ServiceAccount) bool { return m.ProjectID == projectID }), nil
+}
+
+// SCIMUserRepository is an in-memory repository.ISCIMUserRepository.
+// When built with a UserRepository, User is populated on reads that preload it in SQL.
+type SCIMUserRepository struct {
+	*Store[model.SCIMUser]
+	users *UserRepository
+}
+
+var _ repository.ISCIMUserRepository = (*SCIMUserRepository)(nil)
+
+func NewSCIMUserRepository(users *UserRepository) *SCIMUserRepository {
+	return &SCIMUserRepository{
+		Store: NewStore(func(m *model.SCIMUser) *model.BaseModel { return &m.BaseModel }),
+		users: users,
+	}
+}
+
+func (r *SCIMUserRepository) FindByUserID(ctx context.Context, userID string) *model.SCIMUser {
+	return r.withUser(ctx, r.First(func(m *model.SCIMUser) bool { return m.UserID == userID }))
+}
+
+func (r *SCIMUserRepository) FindByExternalID(ctx context.Context, projectID, externalID string) *model.SCIMUser {
+	return r.withUser(ctx, r.First(func(m *model.SCIMUser) bool {
+		return m.ProjectID == projectID && m.ExternalID == externalID
+	}))
+}
+
+func (r *SCIMUserRepository) FindByProjectID(ctx context.Context, projectID string, limit, offset int) ([]model.SCIMUser, int64, error) {
+	links := r.Filter(func(m *model.SCIMUser) bool { return m.ProjectID == projectID })
+	page := paginate(links, offset, limit)
+	for i := range page {
+		page[i] = *r.withUser(ctx, &page[i])
+	}
+	return page, int64(len(links)), nil
+}
+
+func (r *SCIMUserRepository) withUser(ctx context.Context, link *model.SCIMUser) *model.SCIMUser {
+	if link == nil || r.users == nil {
+		return link
+	}
+	if user := r.users.FindOneById(ctx, link.UserID); user != nil {
+		link.User = *user
+	}
+	return link
+}
+
+// UserIdentityRepository is an in-memory repository.IUserIdentityRepository.
+type UserIdentityRepository struct {
+	*Store[model.UserIdentity]
+}
+
+var _ repository.IUserIdentityRepository = (*UserIdentityRepository)(nil)
+
+func NewUserIdentityRepository() *UserIdentityRepository {
+	return &UserIdentityRepository{Store: NewStore(func(m *model.UserIdentity) *model.BaseModel { return &m.BaseModel })}
+}
+
+func (r *UserIdentityRepository) FindBySubject(ctx context.Context, authType constant.UserAuthType, provider, providerUserID string) *model.UserIdentity {
+	return r.First(func(m *model.UserIdentity) bool {
+		return m.AuthType == authType && m.Provider == provider && m.ProviderUserID == providerUserID
+	})
+}
+
+func (r *UserIdentityRepository) FindByUserID(ctx context.Context, userID string) ([]model.UserIdentity, error) {
+	return r.Filter(func(m *model.UserIdentity) bool { return m.UserID == userID }), nil
+}
+
+// TxManager is a repository.ITxManager for the in-memory repositories. It runs fn directly: the stores
+// cannot roll back, so the writes of a failed fn are kept.
+type TxManager struct{}
+
+var _ repository.ITxManager = TxManager{}
+
+func (TxManager) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// HealthRepository is a repository.IHealthRepository whose Ping returns Err.
+type HealthRepository struct {
+	Err error
+}
+
+var _ repository.IHealthRepository = (*HealthRepository)(nil)
+
+func (r *HealthRepository) Ping(ctx context.Context) error {
+	return r.Err
+}
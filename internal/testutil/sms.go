@@ -0,0 +1,41 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hiamthach108/dreon-auth/pkg/sms"
+)
+
+// SMSMessage is one text recorded by SMSSender.
+type SMSMessage struct {
+	To   string
+	Body string
+}
+
+// SMSSender is an in-memory sms.ISender that records every message instead of sending it.
+type SMSSender struct {
+	mu   sync.Mutex
+	sent []SMSMessage
+}
+
+var _ sms.ISender = (*SMSSender)(nil)
+
+// NewSMSSender returns an empty recording SMS sender.
+func NewSMSSender() *SMSSender {
+	return &SMSSender{}
+}
+
+func (s *SMSSender) Send(ctx context.Context, to, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, SMSMessage{To: to, Body: body})
+	return nil
+}
+
+// Sent returns the messages sent so far, oldest first.
+func (s *SMSSender) Sent() []SMSMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SMSMessage(nil), s.sent...)
+}
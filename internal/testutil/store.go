@@ -0,0 +1,330 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Store is an in-memory repository.IRepository[T] for models that embed model.BaseModel.
+// Records are copied in and out, so mutating a returned value does not change the store.
+// DeleteById soft-deletes like GORM: the record leaves every query but still holds its unique values.
+type Store[T any] struct {
+	mu      sync.RWMutex
+	items   map[string]T
+	deleted map[string]T
+	order   []string
+	schema  *schema.Schema
+	base    func(*T) *model.BaseModel
+}
+
+var _ repository.IRepository[model.User] = (*Store[model.User])(nil)
+
+// NewStore returns an empty store. base returns the embedded BaseModel of a record.
+func NewStore[T any](base func(*T) *model.BaseModel) *Store[T] {
+	sch, err := schema.Parse(new(T), &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		panic(fmt.Sprintf("testutil: parse schema: %v", err))
+	}
+	return &Store[T]{
+		items:   make(map[string]T),
+		deleted: make(map[string]T),
+		schema:  sch,
+		base:    base,
+	}
+}
+
+func (s *Store[T]) FindAll(ctx context.Context) ([]T, error) {
+	return s.Filter(func(*T) bool { return true }), nil
+}
+
+func (s *Store[T]) FindOneById(ctx context.Context, id string) *T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.items[id]
+	if !ok {
+		return nil
+	}
+	return &item
+}
+
+func (s *Store[T]) FindByIds(ctx context.Context, ids []string) ([]T, error) {
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	return s.Filter(func(item *T) bool { return want[s.base(item).ID] }), nil
+}
+
+func (s *Store[T]) Create(ctx context.Context, value *T) (*T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.insert(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (s *Store[T]) BulkCreate(ctx context.Context, inputs []T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range inputs {
+		if err := s.insert(&inputs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update copies the named columns (DB or Go field names) from value into the stored record.
+// With no fields it copies every non-zero field, matching GORM's Updates(struct) behaviour.
+// Updating a missing id is a no-op, as with GORM.
+func (s *Store[T]) Update(ctx context.Context, id string, value T, fields ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.items[id]
+	if !ok {
+		return nil
+	}
+	dst := reflect.ValueOf(&current).Elem()
+	src := reflect.ValueOf(&value).Elem()
+
+	if len(fields) == 0 {
+		for _, f := range s.schema.Fields {
+			if f.DBName == "" || f.PrimaryKey {
+				continue
+			}
+			if v := f.ReflectValueOf(ctx, src); !v.IsZero() {
+				f.ReflectValueOf(ctx, dst).Set(v)
+			}
+		}
+	} else {
+		for _, name := range fields {
+			f := s.schema.LookUpField(name)
+			if f == nil {
+				return fmt.Errorf("testutil: unknown column %q on %s", name, s.schema.Table)
+			}
+			f.ReflectValueOf(ctx, dst).Set(f.ReflectValueOf(ctx, src))
+		}
+	}
+	s.base(&current).UpdatedAt = time.Now()
+	s.items[id] = current
+	return nil
+}
+
+func (s *Store[T]) DeleteById(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return nil
+	}
+	s.base(&item).DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	s.deleted[id] = item
+	s.remove(id)
+	return nil
+}
+
+func (s *Store[T]) FindDeletedById(ctx context.Context, id string) *T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.deleted[id]
+	if !ok {
+		return nil
+	}
+	return &item
+}
+
+func (s *Store[T]) RestoreById(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.deleted[id]
+	if !ok {
+		return nil
+	}
+	delete(s.deleted, id)
+	s.base(&item).DeletedAt = gorm.DeletedAt{}
+	s.items[id] = item
+	s.order = append(s.order, id)
+	return nil
+}
+
+func (s *Store[T]) HardDeleteById(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.deleted, id)
+	s.remove(id)
+	return nil
+}
+
+// LockById does nothing: the store has no transactions to serialize.
+func (s *Store[T]) LockById(ctx context.Context, id string) error {
+	return nil
+}
+
+// remove drops id from the live records. Caller must hold s.mu.
+func (s *Store[T]) remove(id string) {
+	if _, ok := s.items[id]; !ok {
+		return
+	}
+	delete(s.items, id)
+	for i, existing := range s.order {
+		if existing == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Filter returns copies of all records matching pred, in insertion order.
+func (s *Store[T]) Filter(pred func(*T) bool) []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var results []T
+	for _, id := range s.order {
+		item := s.items[id]
+		if pred(&item) {
+			results = append(results, item)
+		}
+	}
+	return results
+}
+
+// FilterUnscoped is Filter over live and soft-deleted records, like GORM's Unscoped.
+func (s *Store[T]) FilterUnscoped(pred func(*T) bool) []T {
+	results := s.Filter(pred)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, item := range s.deleted {
+		if pred(&item) {
+			results = append(results, item)
+		}
+	}
+	return results
+}
+
+// First returns a copy of the first record matching pred, or nil.
+func (s *Store[T]) First(pred func(*T) bool) *T {
+	if results := s.Filter(pred); len(results) > 0 {
+		return &results[0]
+	}
+	return nil
+}
+
+// DeleteWhere removes every record matching pred and returns how many were removed.
+func (s *Store[T]) DeleteWhere(pred func(*T) bool) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed int64
+	kept := s.order[:0]
+	for _, id := range s.order {
+		item := s.items[id]
+		if pred(&item) {
+			delete(s.items, id)
+			removed++
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+	return removed
+}
+
+// Len returns the number of stored records, not counting soft-deleted ones.
+func (s *Store[T]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.items)
+}
+
+// insert assigns an id and timestamps like BaseModel.BeforeCreate and enforces unique columns.
+// Caller must hold s.mu.
+func (s *Store[T]) insert(value *T) error {
+	b := s.base(value)
+	if b.ID == "" {
+		id, err := uuid.NewV6()
+		if err != nil {
+			return err
+		}
+		b.ID = id.String()
+	}
+	if _, exists := s.items[b.ID]; exists {
+		return gorm.ErrDuplicatedKey
+	}
+	if _, exists := s.deleted[b.ID]; exists {
+		return gorm.ErrDuplicatedKey
+	}
+	if err := s.checkUnique(value); err != nil {
+		return err
+	}
+	now := time.Now()
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = now
+	}
+	b.UpdatedAt = now
+	s.items[b.ID] = *value
+	s.order = append(s.order, b.ID)
+	return nil
+}
+
+func (s *Store[T]) checkUnique(value *T) error {
+	ctx := context.Background()
+	v := reflect.ValueOf(value).Elem()
+	for _, f := range s.schema.Fields {
+		if !f.Unique {
+			continue
+		}
+		want := f.ReflectValueOf(ctx, v).Interface()
+		for _, records := range []map[string]T{s.items, s.deleted} {
+			for _, item := range records {
+				if reflect.DeepEqual(f.ReflectValueOf(ctx, reflect.ValueOf(&item).Elem()).Interface(), want) {
+					return gorm.ErrDuplicatedKey
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Page returns the items on page, ordered newest first and cut after the cursor or at the offset the way
+// repository.Page is applied in SQL.
+func (s *Store[T]) Page(items []T, page repository.Page) []T {
+	items = slices.Clone(items)
+	newer := func(a, b *model.BaseModel) bool {
+		if a.CreatedAt.Equal(b.CreatedAt) {
+			return a.ID > b.ID
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return newer(s.base(&items[i]), s.base(&items[j])) })
+	if page.Cursor == nil {
+		return paginate(items, page.Offset, page.Limit)
+	}
+	cursor := &model.BaseModel{ID: page.Cursor.ID, CreatedAt: page.Cursor.CreatedAt}
+	start := sort.Search(len(items), func(i int) bool { return newer(cursor, s.base(&items[i])) })
+	return paginate(items, start, page.Limit)
+}
+
+// paginate applies offset/limit the way the SQL repositories do (a negative limit means no limit).
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	if offset > 0 {
+		items = items[offset:]
+	}
+	if limit >= 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
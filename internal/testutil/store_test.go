@@ -0,0 +1,81 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"gorm.io/gorm"
+)
+
+func TestStore_CreateAssignsIDAndEnforcesUnique(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+
+	created, err := repo.Create(ctx, &model.User{Username: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("Create() err = %v", err)
+	}
+	if created.ID == "" || created.CreatedAt.IsZero() {
+		t.Errorf("Create() did not set ID/CreatedAt: %+v", created.BaseModel)
+	}
+
+	_, err = repo.Create(ctx, &model.User{Username: "alice2", Email: "alice@example.com"})
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Errorf("Create() duplicate email err = %v, want ErrDuplicatedKey", err)
+	}
+
+	found, _ := repo.FindByEmail(ctx, "alice@example.com")
+	if found == nil || found.ID != created.ID {
+		t.Errorf("FindByEmail() = %+v, want %s", found, created.ID)
+	}
+	if missing := repo.FindOneById(ctx, "nope"); missing != nil {
+		t.Errorf("FindOneById(missing) = %+v, want nil", missing)
+	}
+}
+
+func TestStore_UpdateFields(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSessionRepository()
+	s, _ := repo.Create(ctx, &model.Session{UserID: "u1", RefreshTokenHash: "rt", IsActive: true})
+
+	// Named column: zero values are written.
+	if err := repo.Update(ctx, s.ID, model.Session{IsActive: false, UserID: "ignored"}, "is_active"); err != nil {
+		t.Fatalf("Update() err = %v", err)
+	}
+	got := repo.FindOneById(ctx, s.ID)
+	if got.IsActive || got.UserID != "u1" {
+		t.Errorf("Update(is_active) = %+v, want inactive with UserID u1", got)
+	}
+
+	// No fields: only non-zero values are written.
+	if err := repo.Update(ctx, s.ID, model.Session{Email: "a@example.com"}); err != nil {
+		t.Fatalf("Update() err = %v", err)
+	}
+	got = repo.FindOneById(ctx, s.ID)
+	if got.Email != "a@example.com" || got.RefreshTokenHash != "rt" {
+		t.Errorf("Update() = %+v, want email set and refresh token kept", got)
+	}
+
+	if err := repo.Update(ctx, s.ID, model.Session{}, "no_such_column"); err == nil {
+		t.Error("Update() expected error for unknown column")
+	}
+}
+
+func TestUserRoleRepository_PreloadsRole(t *testing.T) {
+	ctx := context.Background()
+	roles := NewRoleRepository()
+	userRoles := NewUserRoleRepository(roles)
+	role, _ := roles.Create(ctx, &model.Role{Code: "viewer", Name: "Viewer"})
+	project := "p1"
+	_, _ = userRoles.Create(ctx, &model.UserRole{UserID: "u1", RoleID: role.ID, ProjectID: &project})
+
+	got, _ := userRoles.FindByUserID(ctx, "u1")
+	if len(got) != 1 || got[0].Role.Code != "viewer" {
+		t.Fatalf("FindByUserID() = %+v, want viewer role preloaded", got)
+	}
+	if ur, _ := userRoles.FindByUserIDAndRoleID(ctx, "u1", role.ID, nil); ur != nil {
+		t.Errorf("FindByUserIDAndRoleID(nil project) = %+v, want nil", ur)
+	}
+}
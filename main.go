@@ -1,14 +1,11 @@
 package main
 
 import (
-	"github.com/hiamthach108/dreon-auth/config"
-	"github.com/hiamthach108/dreon-auth/internal/repository"
+	"github.com/hiamthach108/dreon-auth/internal/app"
 	"github.com/hiamthach108/dreon-auth/internal/service"
-	"github.com/hiamthach108/dreon-auth/internal/shared/permission"
-	"github.com/hiamthach108/dreon-auth/pkg/cache"
-	"github.com/hiamthach108/dreon-auth/pkg/database"
-	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
 	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/scheduler"
 	grpcserver "github.com/hiamthach108/dreon-auth/presentation/grpc"
 	"github.com/hiamthach108/dreon-auth/presentation/http"
 	"github.com/hiamthach108/dreon-auth/presentation/http/handler"
@@ -18,20 +15,24 @@ import (
 )
 
 func main() {
-	app := fx.New(
+	application := fx.New(
+		// Each shutdown step has its own SHUTDOWN_TIMEOUT_SEC; this only bounds them all together.
+		fx.StopTimeout(constant.AppStopTimeout),
 		fx.WithLogger(func(appLogger logger.ILogger) fxevent.Logger {
 			return &fxevent.ZapLogger{Logger: appLogger.GetZapLogger()}
 		}),
+		app.Core,
+		app.Services,
+		app.Repositories,
 		fx.Provide(
-			// Core
-			config.NewAppConfig,
-			logger.NewLogger,
-			cache.NewAppCache,
-			database.NewDbClient,
-			jwt.NewJwtTokenManagerFromConfig,
 			echomw.NewVerifyJWTMiddleware,
-			echomw.NewVerifySuperAdminMiddleware,
-			permission.NewRegistryFromConfig,
+			echomw.NewAuthorizeMiddleware,
+			echomw.NewAPIKeyMiddleware,
+			echomw.NewSCIMAuthMiddleware,
+			echomw.NewRelationMiddleware,
+			echomw.NewRateLimitMiddleware,
+			echomw.NewIPFilterMiddleware,
+			scheduler.NewScheduler,
 			http.NewHttpServer,
 
 			// Handlers
@@ -41,30 +42,42 @@ func main() {
 			handler.NewRelationHandler,
 			handler.NewRoleHandler,
 			handler.NewPermissionHandler,
-
-			// Services
-			service.NewUserSvc,
-			service.NewAuthSvc,
-			service.NewProjectSvc,
-			service.NewRelationSvc,
-			service.NewRoleSvc,
-
-			// Repositories
-			repository.NewUserRepository,
-			repository.NewSuperAdminRepository,
-			repository.NewProjectRepository,
-			repository.NewSessionRepository,
-			repository.NewRelationTupleRepository,
-			repository.NewRoleRepository,
-			repository.NewUserRoleRepository,
+			handler.NewCredentialHandler,
+			handler.NewAccessPolicyHandler,
+			handler.NewTrustedDeviceHandler,
+			handler.NewUserDeviceHandler,
+			handler.NewSessionHandler,
+			handler.NewSAMLHandler,
+			handler.NewSigningKeyHandler,
+			handler.NewOIDCProviderHandler,
+			handler.NewAPIKeyHandler,
+			handler.NewProjectMemberHandler,
+			handler.NewNotificationTemplateHandler,
+			handler.NewUserIdentityHandler,
+			handler.NewServiceAccountHandler,
+			handler.NewSCIMHandler,
+			handler.NewHealthHandler,
+			handler.NewIPBlockHandler,
 
 			// gRPC server (AuthInternal: relation tuples + permission checks)
 			grpcserver.NewAuthInternalServer,
 			grpcserver.NewGRPCServer,
 		),
+		// fx runs stop hooks in reverse: the servers stop taking requests first, then the background work they
+		// started drains, the scheduler stops, events and spans are flushed and the connections close.
+		fx.Invoke(service.RegisterConnectionHooks),
+		fx.Invoke(service.RegisterTracingHooks),
+		fx.Invoke(service.RegisterEventPublisherHooks),
+		fx.Invoke(service.RegisterSigningKeyReload),
+		fx.Invoke(service.RegisterPermissionSeed),
+		fx.Invoke(service.RegisterConfigReload),
+		fx.Invoke(service.RegisterCleanupJobs),
+		fx.Invoke(service.RegisterBackgroundHooks),
 		fx.Invoke(http.RegisterHooks),
 		fx.Invoke(grpcserver.RegisterHooks),
+		// Last, so the instance only reports ready once everything above has started
+		fx.Invoke(service.RegisterHealthHooks),
 	)
 
-	app.Run()
+	application.Run()
 }
@@ -0,0 +1,169 @@
+// Package authmw lets other Go services accept dreon-auth access tokens: it verifies them locally against
+// dreon-auth's JWKS (or configured public keys) and enforces permission codes, as Echo or net/http middleware.
+//
+//	verifier := authmw.NewVerifier(authmw.NewJWKS("https://auth.example.com/.well-known/jwks.json"),
+//		authmw.WithIssuer("dreon-auth"))
+//	mw := authmw.New(verifier, authmw.NewAuthorizer(nil))
+//	g := e.Group("/orders", mw.Echo())
+//	g.GET("", listOrders, mw.EchoRequirePermission("orders.view", "projectId"))
+package authmw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/labstack/echo/v4"
+)
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying payload.
+func NewContext(ctx context.Context, payload *jwt.Payload) context.Context {
+	return context.WithValue(ctx, contextKey{}, payload)
+}
+
+// PayloadFromContext returns the payload set by the middleware, or nil.
+func PayloadFromContext(ctx context.Context) *jwt.Payload {
+	p, _ := ctx.Value(contextKey{}).(*jwt.Payload)
+	return p
+}
+
+// ProjectFunc picks the project a permission is checked in from the request; an empty result means SystemProject.
+type ProjectFunc func(r *http.Request) string
+
+// PathValue reads the project ID from a net/http path wildcard, e.g. PathValue("projectId") for
+// a route registered as "GET /projects/{projectId}/orders".
+func PathValue(name string) ProjectFunc {
+	return func(r *http.Request) string { return r.PathValue(name) }
+}
+
+// Middleware authenticates requests with a Verifier and guards routes with an Authorizer.
+type Middleware struct {
+	verifier   *Verifier
+	authorizer *Authorizer
+}
+
+// New returns the middleware. authorizer may be nil when no route needs a permission.
+func New(verifier *Verifier, authorizer *Authorizer) *Middleware {
+	if authorizer == nil {
+		authorizer = NewAuthorizer(nil)
+	}
+	return &Middleware{verifier: verifier, authorizer: authorizer}
+}
+
+// Handler returns net/http middleware that requires a valid "Authorization: Bearer <token>" and stores its
+// payload for PayloadFromContext. It answers 401 for missing or invalid tokens, and 503 while the JWKS
+// cannot be fetched.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := m.authenticate(r)
+		if err != nil {
+			writeError(w, authStatus(err), err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), payload)))
+	})
+}
+
+// RequirePermission returns net/http middleware, used inside Handler, that answers 403 unless the caller
+// holds code in the project chosen by project (nil checks SystemProject).
+func (m *Middleware) RequirePermission(code string, project ProjectFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			projectID := ""
+			if project != nil {
+				projectID = project(r)
+			}
+			status, message := m.authorize(r.Context(), projectID, code)
+			if status != http.StatusOK {
+				writeError(w, status, message)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Echo returns the Handler check as Echo middleware. Errors are *echo.HTTPError in dreon-auth's
+// {"code", "message"} shape.
+func (m *Middleware) Echo() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			payload, err := m.authenticate(c.Request())
+			if err != nil {
+				return httpError(authStatus(err), err.Error())
+			}
+			c.SetRequest(c.Request().WithContext(NewContext(c.Request().Context(), payload)))
+			return next(c)
+		}
+	}
+}
+
+// EchoRequirePermission returns Echo middleware, used after Echo, that answers 403 unless the caller holds
+// code in the project named by the projectParam path param (SystemProject when projectParam is empty).
+func (m *Middleware) EchoRequirePermission(code, projectParam string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			projectID := ""
+			if projectParam != "" {
+				projectID = c.Param(projectParam)
+			}
+			if status, message := m.authorize(c.Request().Context(), projectID, code); status != http.StatusOK {
+				return httpError(status, message)
+			}
+			return next(c)
+		}
+	}
+}
+
+func (m *Middleware) authenticate(r *http.Request) (*jwt.Payload, error) {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) || strings.TrimSpace(auth[len(prefix):]) == "" {
+		return nil, ErrMissingToken
+	}
+	return m.verifier.Verify(r.Context(), strings.TrimSpace(auth[len(prefix):]))
+}
+
+// authorize returns http.StatusOK when the caller in ctx holds code, or the status and message to fail with.
+func (m *Middleware) authorize(ctx context.Context, projectID, code string) (int, string) {
+	payload := PayloadFromContext(ctx)
+	if payload == nil {
+		return http.StatusUnauthorized, "missing payload"
+	}
+	allowed, err := m.authorizer.Allowed(ctx, payload, projectID, code)
+	if err != nil {
+		return http.StatusInternalServerError, "failed to resolve permissions"
+	}
+	if !allowed {
+		return http.StatusForbidden, "missing permission: " + code
+	}
+	return http.StatusOK, ""
+}
+
+func authStatus(err error) int {
+	if errors.Is(err, ErrKeysUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusUnauthorized
+}
+
+func httpError(status int, message string) *echo.HTTPError {
+	return echo.NewHTTPError(status, echo.Map{
+		"message": message,
+		"code":    status,
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"message": message,
+		"code":    status,
+	})
+}
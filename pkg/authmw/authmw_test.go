@@ -0,0 +1,205 @@
+package authmw
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/labstack/echo/v4"
+)
+
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+// jwksServer serves keys' JWKS and counts fetches.
+func jwksServer(t *testing.T, keys jwt.IKeySet) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_ = json.NewEncoder(w).Encode(keys.JWKS())
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &fetches
+}
+
+func issue(t *testing.T, keys jwt.IKeySet, payload jwt.Payload, expiry time.Duration) string {
+	t.Helper()
+	m, _ := jwt.NewJwtTokenManagerWithKeySet(keys, jwt.WithIssuer("dreon-auth"))
+	token, err := m.Generate(context.Background(), payload, expiry)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	return token
+}
+
+func do(t *testing.T, h http.Handler, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/projects/p1/orders", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_verifiesWithJWKS(t *testing.T) {
+	keys, _ := jwt.NewKeySet(jwt.Key{PrivateKey: testRSAKey(t)})
+	srv, fetches := jwksServer(t, keys)
+	mw := New(NewVerifier(NewJWKS(srv.URL), WithIssuer("dreon-auth")), nil)
+
+	var got *jwt.Payload
+	h := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = PayloadFromContext(r.Context())
+	}))
+
+	if rec := do(t, h, issue(t, keys, jwt.Payload{UserID: "u1"}, time.Minute)); rec.Code != http.StatusOK {
+		t.Fatalf("valid token status = %d, want 200", rec.Code)
+	}
+	if got == nil || got.UserID != "u1" || got.TokenID == "" {
+		t.Errorf("payload = %+v", got)
+	}
+	do(t, h, issue(t, keys, jwt.Payload{UserID: "u1"}, time.Minute))
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1 (cached)", n)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "missing", token: ""},
+		{name: "expired", token: issue(t, keys, jwt.Payload{UserID: "u1"}, -time.Minute)},
+		{name: "garbage", token: "not-a-jwt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := do(t, h, tt.token); rec.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_rejectsOtherIssuer(t *testing.T) {
+	keys, _ := jwt.NewKeySet(jwt.Key{PrivateKey: testRSAKey(t)})
+	mw := New(NewVerifier(StaticKeys(keys.SigningKey().PublicKey), WithIssuer("someone-else")), nil)
+	h := mw.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	if rec := do(t, h, issue(t, keys, jwt.Payload{UserID: "u1"}, time.Minute)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", rec.Code)
+	}
+}
+
+func TestJWKS_refetchesOnRotation(t *testing.T) {
+	oldKey, newKey := testRSAKey(t), testRSAKey(t)
+	keys, _ := jwt.NewKeySet(jwt.Key{PrivateKey: oldKey})
+	srv, fetches := jwksServer(t, keys)
+	jwks := NewJWKS(srv.URL)
+	now := time.Now()
+	jwks.now = func() time.Time { return now }
+	verifier := NewVerifier(jwks)
+
+	if _, err := verifier.Verify(context.Background(), issue(t, keys, jwt.Payload{UserID: "u1"}, time.Minute)); err != nil {
+		t.Fatalf("Verify before rotation: %v", err)
+	}
+	if err := keys.SetKeys(jwt.Key{PrivateKey: newKey}, jwt.Key{PublicKey: &oldKey.PublicKey}); err != nil {
+		t.Fatalf("SetKeys: %v", err)
+	}
+	rotated := issue(t, keys, jwt.Payload{UserID: "u1"}, time.Minute)
+
+	// Within the refresh throttle an unknown kid is rejected without another fetch.
+	if _, err := verifier.Verify(context.Background(), rotated); err == nil {
+		t.Fatal("unknown kid accepted before the JWKS was refetched")
+	}
+	now = now.Add(DefaultJWKSMinRefresh)
+	if _, err := verifier.Verify(context.Background(), rotated); err != nil {
+		t.Fatalf("Verify after rotation: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", n)
+	}
+}
+
+func TestHandler_jwksUnavailable(t *testing.T) {
+	keys, _ := jwt.NewKeySet(jwt.Key{PrivateKey: testRSAKey(t)})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(srv.Close)
+	mw := New(NewVerifier(NewJWKS(srv.URL)), nil)
+	h := mw.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	if rec := do(t, h, issue(t, keys, jwt.Payload{UserID: "u1"}, time.Minute)); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	keys, _ := jwt.NewKeySet(jwt.Key{PrivateKey: testRSAKey(t)})
+	userPermissions := func(ctx context.Context, userID string) (map[string]bool, error) {
+		if userID == "viewer" {
+			return map[string]bool{"p1/orders.view": true}, nil
+		}
+		return map[string]bool{}, nil
+	}
+	mw := New(NewVerifier(StaticKeys(keys.SigningKey().PublicKey)), NewAuthorizer(userPermissions))
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /projects/{projectId}/orders", mw.Handler(
+		mw.RequirePermission("orders.view", PathValue("projectId"))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})),
+	))
+
+	tests := []struct {
+		name    string
+		payload jwt.Payload
+		want    int
+	}{
+		{name: "user with permission", payload: jwt.Payload{UserID: "viewer"}, want: http.StatusOK},
+		{name: "user without permission", payload: jwt.Payload{UserID: "stranger"}, want: http.StatusForbidden},
+		{name: "super admin", payload: jwt.Payload{UserID: "root", IsSuperAdmin: true}, want: http.StatusOK},
+		{name: "service account with scope", payload: jwt.Payload{ServiceAccountID: "sa", ProjectID: "p1", Scopes: []string{"orders.view"}}, want: http.StatusOK},
+		{name: "service account in another project", payload: jwt.Payload{ServiceAccountID: "sa", ProjectID: "p2", Scopes: []string{"orders.view"}}, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := do(t, mux, issue(t, keys, tt.payload, time.Minute)); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestEcho(t *testing.T) {
+	keys, _ := jwt.NewKeySet(jwt.Key{PrivateKey: testRSAKey(t)})
+	mw := New(NewVerifier(StaticKeys(keys.SigningKey().PublicKey)), nil)
+
+	e := echo.New()
+	g := e.Group("/projects/:projectId", mw.Echo())
+	g.GET("/orders", func(c echo.Context) error {
+		return c.String(http.StatusOK, PayloadFromContext(c.Request().Context()).UserID)
+	}, mw.EchoRequirePermission("orders.view", "projectId"))
+
+	if rec := do(t, e, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("missing token status = %d, want 401", rec.Code)
+	}
+	if rec := do(t, e, issue(t, keys, jwt.Payload{UserID: "u1"}, time.Minute)); rec.Code != http.StatusInternalServerError {
+		t.Errorf("user without permission lookup status = %d, want 500", rec.Code)
+	}
+	rec := do(t, e, issue(t, keys, jwt.Payload{UserID: "root", IsSuperAdmin: true}, time.Minute))
+	if rec.Code != http.StatusOK || rec.Body.String() != "root" {
+		t.Errorf("super admin status = %d, body %q", rec.Code, rec.Body.String())
+	}
+}
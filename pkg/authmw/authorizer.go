@@ -0,0 +1,54 @@
+package authmw
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+// SystemProject is the project permissions are checked in when a route is not scoped to one,
+// matching dreon-auth's own system project.
+const SystemProject = "system"
+
+// ErrNoUserPermissions is returned when a user (not a machine or super admin) needs a permission
+// but the Authorizer was built without a way to look user permissions up.
+var ErrNoUserPermissions = errors.New("authmw: no user permission lookup configured")
+
+// UserPermissionsFunc returns a user's permissions keyed "<projectID>/<code>", the shape returned by
+// dreon-auth's GetUserPermissions gRPC call and GET /api/v1/roles/user/:userId/permissions.
+type UserPermissionsFunc func(ctx context.Context, userID string) (map[string]bool, error)
+
+// Authorizer decides whether a verified caller holds a permission code, with the same rules as
+// dreon-auth's authorize middleware: super admins hold every permission, API keys and service
+// accounts hold their token scopes in their own project, and users hold what RBAC grants them.
+type Authorizer struct {
+	userPermissions UserPermissionsFunc
+}
+
+// NewAuthorizer returns an Authorizer. userPermissions may be nil when only machine callers and
+// super admins reach the guarded routes; user permissions are not in the token.
+func NewAuthorizer(userPermissions UserPermissionsFunc) *Authorizer {
+	return &Authorizer{userPermissions: userPermissions}
+}
+
+// Allowed reports whether payload holds code in projectID (SystemProject when empty).
+func (a *Authorizer) Allowed(ctx context.Context, payload *jwt.Payload, projectID, code string) (bool, error) {
+	if projectID == "" {
+		projectID = SystemProject
+	}
+	switch {
+	case payload.IsSuperAdmin:
+		return true, nil
+	case payload.IsMachine():
+		return projectID == payload.ProjectID && slices.Contains(payload.Scopes, code), nil
+	case a.userPermissions == nil:
+		return false, ErrNoUserPermissions
+	}
+	permissions, err := a.userPermissions(ctx, payload.UserID)
+	if err != nil {
+		return false, err
+	}
+	return permissions[projectID+"/"+code], nil
+}
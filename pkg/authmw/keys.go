@@ -0,0 +1,170 @@
+package authmw
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+const (
+	// DefaultJWKSCacheTTL is how long fetched keys are used before the JWKS is fetched again.
+	DefaultJWKSCacheTTL = time.Hour
+	// DefaultJWKSMinRefresh limits how often a token with an unknown kid can trigger a fetch.
+	DefaultJWKSMinRefresh = time.Minute
+)
+
+// staticKeys is a KeySource over a fixed list of public keys.
+type staticKeys struct {
+	first *rsa.PublicKey
+	byID  map[string]*rsa.PublicKey
+}
+
+// StaticKeys returns a KeySource over keys, identified by their RFC 7638 thumbprint as dreon-auth does
+// when JWT_KEY_ID is unset. Tokens without a kid are checked against the first key. Use it with keys
+// from jwt.ParseRSAPublicKeys when the service should not reach dreon-auth at all.
+func StaticKeys(keys ...*rsa.PublicKey) KeySource {
+	s := &staticKeys{byID: make(map[string]*rsa.PublicKey, len(keys))}
+	for _, k := range keys {
+		if s.first == nil {
+			s.first = k
+		}
+		s.byID[jwt.KeyID(k)] = k
+	}
+	return s
+}
+
+func (s *staticKeys) PublicKey(_ context.Context, kid string) (*rsa.PublicKey, error) {
+	if kid == "" && s.first != nil {
+		return s.first, nil
+	}
+	if pub, ok := s.byID[kid]; ok {
+		return pub, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// JWKS is a KeySource that fetches dreon-auth's /.well-known/jwks.json and caches it. A token signed
+// with a key it has not seen triggers a refetch, so signing key rotation needs no restart here.
+type JWKS struct {
+	url        string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+	now        func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	fetchErr  error
+}
+
+// JWKSOption configures a JWKS.
+type JWKSOption func(*JWKS)
+
+// WithHTTPClient sets the client used to fetch the JWKS; the default times out after 10 seconds.
+func WithHTTPClient(client *http.Client) JWKSOption {
+	return func(j *JWKS) { j.client = client }
+}
+
+// WithCacheTTL sets how long fetched keys are used before refetching (default DefaultJWKSCacheTTL).
+func WithCacheTTL(ttl time.Duration) JWKSOption {
+	return func(j *JWKS) { j.ttl = ttl }
+}
+
+// NewJWKS returns a KeySource backed by the JWKS at url, e.g. https://auth.example.com/.well-known/jwks.json.
+// Keys are fetched on first use.
+func NewJWKS(url string, opts ...JWKSOption) *JWKS {
+	j := &JWKS{
+		url:        url,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		ttl:        DefaultJWKSCacheTTL,
+		minRefresh: DefaultJWKSMinRefresh,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// PublicKey returns the key named kid, refetching the JWKS when the cache is stale or kid is new.
+// When a refetch fails, keys already cached keep working. Tokens without a kid are accepted only
+// while the JWKS holds a single key.
+func (j *JWKS) PublicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := j.now()
+	pub, known := j.lookup(kid)
+	age := now.Sub(j.fetchedAt)
+	if age >= j.ttl || (!known && age >= j.minRefresh) {
+		j.fetchErr = j.refresh(ctx)
+		// Count failed attempts too, so an unreachable dreon-auth is not hammered by every request.
+		j.fetchedAt = now
+		if j.fetchErr == nil {
+			pub, known = j.lookup(kid)
+		}
+	}
+	if known {
+		return pub, nil
+	}
+	if j.keys == nil && j.fetchErr != nil {
+		return nil, j.fetchErr
+	}
+	return nil, ErrUnknownKey
+}
+
+func (j *JWKS) lookup(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" {
+		if len(j.keys) != 1 {
+			return nil, false
+		}
+		for _, pub := range j.keys {
+			return pub, true
+		}
+	}
+	pub, ok := j.keys[kid]
+	return pub, ok
+}
+
+func (j *JWKS) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrKeysUnavailable, err)
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrKeysUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: unexpected status %d", ErrKeysUnavailable, resp.StatusCode)
+	}
+	var doc jwt.JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("%w: %w", ErrKeysUnavailable, err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.RSAPublicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("%w: no usable RSA keys at %s", ErrKeysUnavailable, j.url)
+	}
+	j.keys = keys
+	return nil
+}
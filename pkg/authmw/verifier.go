@@ -0,0 +1,125 @@
+package authmw
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"slices"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+var (
+	ErrMissingToken = errors.New("authmw: missing bearer token")
+	ErrInvalidToken = errors.New("authmw: invalid token")
+	ErrUnknownKey   = errors.New("authmw: unknown signing key")
+	// ErrKeysUnavailable means the JWKS could not be fetched, so no token can be checked yet.
+	ErrKeysUnavailable = errors.New("authmw: signing keys unavailable")
+)
+
+// KeySource resolves the public key a token was signed with. kid is empty for tokens issued before
+// dreon-auth added key IDs.
+type KeySource interface {
+	PublicKey(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// Verifier checks dreon-auth access tokens without calling dreon-auth. It cannot see revocations,
+// so a revoked token stays valid here until it expires; keep access tokens short-lived.
+type Verifier struct {
+	keys     KeySource
+	issuer   string
+	audience []string
+	leeway   time.Duration
+	decrypt  *rsa.PrivateKey
+}
+
+// VerifierOption configures a Verifier.
+type VerifierOption func(*Verifier)
+
+// WithIssuer requires the iss claim, which dreon-auth sets to its JWT_ISSUER, or APP_NAME by default.
+func WithIssuer(issuer string) VerifierOption {
+	return func(v *Verifier) { v.issuer = issuer }
+}
+
+// WithAudience accepts tokens minted for one of audience in addition to tokens without an audience.
+// Tokens for any other audience, such as OIDC ID tokens, are always rejected.
+func WithAudience(audience ...string) VerifierOption {
+	return func(v *Verifier) { v.audience = audience }
+}
+
+// WithLeeway tolerates clock skew between dreon-auth and this service when checking exp and nbf.
+func WithLeeway(leeway time.Duration) VerifierOption {
+	return func(v *Verifier) { v.leeway = leeway }
+}
+
+// WithDecryptionKey decrypts access tokens dreon-auth encrypts with JWT_ENCRYPTION_KEY, which key must
+// be. Without it encrypted tokens are rejected.
+func WithDecryptionKey(key *rsa.PrivateKey) VerifierOption {
+	return func(v *Verifier) { v.decrypt = key }
+}
+
+// NewVerifier returns a Verifier that checks signatures against keys (see NewJWKS and StaticKeys).
+func NewVerifier(keys KeySource, opts ...VerifierOption) *Verifier {
+	v := &Verifier{keys: keys}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Verify checks tokenString's signature, time claims, issuer and audience and returns its payload.
+// Like pkg/jwt, it fills in TokenID and ExpiresAt from the jti and exp claims.
+func (v *Verifier) Verify(ctx context.Context, tokenString string) (*jwt.Payload, error) {
+	parserOpts := []gojwt.ParserOption{
+		gojwt.WithValidMethods([]string{jwt.SigningMethodAlg}),
+		gojwt.WithExpirationRequired(),
+		gojwt.WithLeeway(v.leeway),
+	}
+	if v.issuer != "" {
+		parserOpts = append(parserOpts, gojwt.WithIssuer(v.issuer))
+	}
+
+	if jwt.IsEncrypted(tokenString) {
+		signed, err := jwt.Decrypt(tokenString, func(string) (*rsa.PrivateKey, bool) { return v.decrypt, v.decrypt != nil })
+		if err != nil {
+			return nil, ErrInvalidToken
+		}
+		tokenString = signed
+	}
+
+	claims := &jwt.Claims{}
+	_, err := gojwt.ParseWithClaims(tokenString, claims, func(t *gojwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.PublicKey(ctx, kid)
+	}, parserOpts...)
+	if err != nil {
+		if errors.Is(err, ErrKeysUnavailable) {
+			return nil, err
+		}
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+	if !v.acceptsAudience(claims.Audience) {
+		return nil, ErrInvalidToken
+	}
+
+	payload := claims.Payload
+	payload.TokenID = claims.ID
+	if exp := claims.RegisteredClaims.ExpiresAt; exp != nil {
+		payload.ExpiresAt = exp.Time
+	}
+	return &payload, nil
+}
+
+func (v *Verifier) acceptsAudience(aud gojwt.ClaimStrings) bool {
+	if len(aud) == 0 {
+		return true
+	}
+	for _, a := range aud {
+		if slices.Contains(v.audience, a) {
+			return true
+		}
+	}
+	return false
+}
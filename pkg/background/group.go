@@ -0,0 +1,119 @@
+// Package background tracks the fire-and-forget goroutines requests leave behind (cache invalidations,
+// webhook deliveries, import jobs) so shutdown can wait for them instead of cutting them off.
+package background
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Group counts the goroutines started with Go. The zero value is ready to use.
+type Group struct {
+	mu      sync.Mutex
+	running int
+	idle    chan struct{} // closed once running drops back to 0
+	stop    chan struct{} // closed by Stop
+	stopped bool
+}
+
+// NewGroup returns an empty group.
+func NewGroup() *Group {
+	return &Group{}
+}
+
+// Go runs fn in a new goroutine that Wait waits for.
+func (g *Group) Go(fn func()) {
+	g.mu.Lock()
+	if g.running == 0 {
+		g.idle = make(chan struct{})
+	}
+	g.running++
+	g.mu.Unlock()
+
+	go func() {
+		defer g.done()
+		fn()
+	}()
+}
+
+// Wait blocks until no goroutine started with Go is running, or ctx is done, in which case it reports how
+// many were left behind. Goroutines started while it waits are waited for too.
+func (g *Group) Wait(ctx context.Context) error {
+	g.mu.Lock()
+	running, idle := g.running, g.idle
+	g.mu.Unlock()
+	if running == 0 {
+		return nil
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return fmt.Errorf("%d background tasks still running: %w", g.running, ctx.Err())
+	}
+}
+
+// Stop tells the goroutines pausing in Sleep that shutdown has begun, so they finish without waiting out
+// their delay. Call it before Wait.
+func (g *Group) Stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.stopped {
+		close(g.stopChan())
+		g.stopped = true
+	}
+}
+
+// Sleep pauses a goroutine started with Go for d, or until Stop is called. It reports whether the whole d
+// passed.
+func (g *Group) Sleep(d time.Duration) bool {
+	g.mu.Lock()
+	stop := g.stopChan()
+	g.mu.Unlock()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// Context returns a context with ctx's values that outlives ctx's cancellation, for a goroutine started with
+// Go after the request that started it has returned, and is done once Stop is called.
+func (g *Group) Context(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	g.mu.Lock()
+	stop := g.stopChan()
+	g.mu.Unlock()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// stopChan returns the channel Stop closes. g.mu must be held.
+func (g *Group) stopChan() chan struct{} {
+	if g.stop == nil {
+		g.stop = make(chan struct{})
+	}
+	return g.stop
+}
+
+func (g *Group) done() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.running--
+	if g.running == 0 {
+		close(g.idle)
+	}
+}
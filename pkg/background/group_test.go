@@ -0,0 +1,84 @@
+package background
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_WaitsForRunningTasks(t *testing.T) {
+	g := NewGroup()
+	var finished atomic.Int32
+	for range 3 {
+		g.Go(func() {
+			time.Sleep(20 * time.Millisecond)
+			finished.Add(1)
+		})
+	}
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if got := finished.Load(); got != 3 {
+		t.Errorf("finished = %d, want 3", got)
+	}
+
+	// An idle group, including one that was busy before, returns at once.
+	if err := g.Wait(context.Background()); err != nil {
+		t.Errorf("Wait() on idle group error = %v", err)
+	}
+}
+
+func TestGroup_WaitDeadline(t *testing.T) {
+	g := NewGroup()
+	release := make(chan struct{})
+	defer close(release)
+	g.Go(func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := g.Wait(ctx); err == nil {
+		t.Fatal("Wait() should fail once the deadline passes with a task still running")
+	}
+}
+
+func TestGroup_StopEndsSleep(t *testing.T) {
+	g := NewGroup()
+	slept := make(chan bool, 1)
+	g.Go(func() { slept <- g.Sleep(time.Hour) })
+
+	g.Stop()
+	g.Stop()
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if <-slept {
+		t.Error("Sleep() = true, want false once stopped")
+	}
+	if g.Sleep(time.Hour) {
+		t.Error("Sleep() after Stop = true, want it to return at once")
+	}
+	if !NewGroup().Sleep(time.Millisecond) {
+		t.Error("Sleep() = false, want true when the delay passes")
+	}
+}
+
+type ctxKey struct{}
+
+func TestGroup_ContextEndsOnStop(t *testing.T) {
+	g := NewGroup()
+	parent, cancelParent := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "v"))
+	ctx, cancel := g.Context(parent)
+	defer cancel()
+
+	cancelParent()
+	if ctx.Err() != nil || ctx.Value(ctxKey{}) != "v" {
+		t.Fatalf("Context() after the parent is canceled: err = %v, value = %v", ctx.Err(), ctx.Value(ctxKey{}))
+	}
+	g.Stop()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Context() not done after Stop")
+	}
+}
@@ -0,0 +1,120 @@
+package breach
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// bloomMagic starts a Bloom filter file, followed by a format version byte.
+const bloomMagic = "DRBF"
+
+// BloomFilter is a set of SHA-1 password hashes that may report false positives but never false negatives.
+// It lets passwords be checked without network access, e.g. against a filter built from the downloadable
+// Pwned Passwords list with dreonctl breach-bloom.
+type BloomFilter struct {
+	bits   []uint64
+	m      uint64 // number of bits
+	hashes uint32 // bits set per entry
+}
+
+// NewBloomFilter sizes a filter for n entries at the given false positive rate, e.g. 0.001.
+func NewBloomFilter(n uint64, falsePositiveRate float64) (*BloomFilter, error) {
+	if n == 0 || falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, errors.New("breach: a Bloom filter needs entries and a false positive rate between 0 and 1")
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) / 64 * 64
+	hashes := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomFilter{bits: make([]uint64, m/64), m: m, hashes: hashes}, nil
+}
+
+// Add inserts a SHA-1 digest.
+func (f *BloomFilter) Add(digest [20]byte) {
+	h1, h2 := split(digest)
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Test reports whether the SHA-1 digest may have been added.
+func (f *BloomFilter) Test(digest [20]byte) bool {
+	h1, h2 := split(digest)
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// AddHashes adds the hashes of a Pwned Passwords download, one "SHA1HEX:count" line each; the counts are
+// ignored. It returns how many lines were added.
+func (f *BloomFilter) AddHashes(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	added := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		var digest [20]byte
+		if n, err := hex.Decode(digest[:], []byte(hash)); err != nil || n != len(digest) {
+			return added, fmt.Errorf("breach: line %d is not a SHA-1 hash", added+1)
+		}
+		f.Add(digest)
+		added++
+	}
+	return added, scanner.Err()
+}
+
+// WriteTo writes the filter in the format ReadBloomFilter reads.
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 0, len(bloomMagic)+13)
+	header = append(header, bloomMagic...)
+	header = append(header, 1)
+	header = binary.BigEndian.AppendUint32(header, f.hashes)
+	header = binary.BigEndian.AppendUint64(header, f.m)
+	if _, err := bw.Write(header); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(bw, binary.BigEndian, f.bits); err != nil {
+		return 0, err
+	}
+	return int64(len(header) + len(f.bits)*8), bw.Flush()
+}
+
+// ReadBloomFilter reads a filter written by WriteTo.
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(bloomMagic)+13)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(bloomMagic)]) != bloomMagic || header[len(bloomMagic)] != 1 {
+		return nil, errors.New("breach: not a Bloom filter file")
+	}
+	f := &BloomFilter{
+		hashes: binary.BigEndian.Uint32(header[len(bloomMagic)+1:]),
+		m:      binary.BigEndian.Uint64(header[len(bloomMagic)+5:]),
+	}
+	if f.hashes == 0 || f.m == 0 || f.m%64 != 0 {
+		return nil, errors.New("breach: corrupt Bloom filter header")
+	}
+	f.bits = make([]uint64, f.m/64)
+	if err := binary.Read(br, binary.BigEndian, f.bits); err != nil {
+		return nil, fmt.Errorf("breach: truncated Bloom filter: %w", err)
+	}
+	return f, nil
+}
+
+// split derives the two hashes of double hashing from a SHA-1 digest, which is already uniform.
+func split(digest [20]byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(digest[0:8]), binary.BigEndian.Uint64(digest[8:16]) | 1
+}
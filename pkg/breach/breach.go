@@ -0,0 +1,111 @@
+package breach
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+)
+
+// DefaultAPIURL is the Pwned Passwords range API.
+const DefaultAPIURL = "https://api.pwnedpasswords.com"
+
+// ErrUnavailable is returned by IsBreached when the API failed and there is no Bloom filter to fall back to.
+var ErrUnavailable = errors.New("breach: no breach source available")
+
+// NewChecker returns a checker that asks the Pwned Passwords API (env: PASSWORD_BREACH_API_URL) and falls
+// back to the Bloom filter in PASSWORD_BREACH_BLOOM_FILE, if set, when the API fails. With
+// PASSWORD_BREACH_OFFLINE only the filter is used.
+func NewChecker(cfg *config.AppConfig) (IChecker, error) {
+	var filter *BloomFilter
+	if path := cfg.Password.BreachBloomFile; path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("breach: open PASSWORD_BREACH_BLOOM_FILE: %w", err)
+		}
+		defer func() { _ = file.Close() }()
+		if filter, err = ReadBloomFilter(file); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Password.BreachOffline {
+		if filter == nil {
+			return nil, errors.New("breach: PASSWORD_BREACH_OFFLINE requires PASSWORD_BREACH_BLOOM_FILE")
+		}
+		return NewRangeChecker("", filter), nil
+	}
+	apiURL := cfg.Password.BreachAPIURL
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	return NewRangeChecker(apiURL, filter), nil
+}
+
+// RangeChecker checks passwords with the k-anonymity range API of Pwned Passwords: only the first five hex
+// digits of the password's SHA-1 leave the server, and the response is padded so its size gives nothing
+// away either.
+type RangeChecker struct {
+	apiURL string
+	filter *BloomFilter
+	client *http.Client
+}
+
+// NewRangeChecker returns a checker for the range API at apiURL, or only filter when apiURL is empty.
+// filter may be nil.
+func NewRangeChecker(apiURL string, filter *BloomFilter) *RangeChecker {
+	return &RangeChecker{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		filter: filter,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (c *RangeChecker) IsBreached(ctx context.Context, password string) (bool, error) {
+	digest := sha1.Sum([]byte(password))
+	if c.apiURL == "" {
+		return c.filter.Test(digest), nil
+	}
+	breached, err := c.queryRange(ctx, strings.ToUpper(hex.EncodeToString(digest[:])))
+	if err != nil {
+		if c.filter != nil {
+			return c.filter.Test(digest), nil
+		}
+		return false, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return breached, nil
+}
+
+func (c *RangeChecker) queryRange(ctx context.Context, hash string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiURL+"/range/"+hash[:5], nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("range request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("range API returned %d", resp.StatusCode)
+	}
+	// Lines are SUFFIX:COUNT; padding lines have a count of 0.
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, 4<<20))
+	for scanner.Scan() {
+		suffix, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(suffix, hash[5:]) {
+			return count != "0", nil
+		}
+	}
+	return false, scanner.Err()
+}
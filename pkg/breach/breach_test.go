@@ -0,0 +1,141 @@
+package breach
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/config"
+)
+
+func sha1Hex(password string) string {
+	digest := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(digest[:]))
+}
+
+// rangeServer answers range queries as Pwned Passwords does, knowing only "password123" plus padding.
+func rangeServer(t *testing.T, requests *[]string) *httptest.Server {
+	t.Helper()
+	breached := sha1Hex("password123")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.Path+" padding="+r.Header.Get("Add-Padding"))
+		prefix := strings.TrimPrefix(r.URL.Path, "/range/")
+		fmt.Fprintln(w, "0018A45C4D1DEF81644B54AB7F969B88D65:3")
+		if prefix == breached[:5] {
+			fmt.Fprintf(w, "%s:248\r\n", strings.ToLower(breached[5:]))
+		}
+		fmt.Fprintf(w, "%s:0\n", sha1Hex("padding only")[5:])
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRangeChecker(t *testing.T) {
+	var requests []string
+	c := NewRangeChecker(rangeServer(t, &requests).URL, nil)
+	for password, want := range map[string]bool{"password123": true, "correct horse battery staple": false, "padding only": false} {
+		got, err := c.IsBreached(context.Background(), password)
+		if err != nil || got != want {
+			t.Errorf("IsBreached(%q) = %v, %v, want %v", password, got, err, want)
+		}
+	}
+	for _, r := range requests {
+		if len(r) != len("/range/ABCDE padding=true") || !strings.HasSuffix(r, " padding=true") {
+			t.Errorf("request %q, want a five-digit prefix with padding", r)
+		}
+	}
+}
+
+func TestRangeChecker_FallsBackToBloomFilter(t *testing.T) {
+	filter, _ := NewBloomFilter(10, 0.001)
+	filter.Add(sha1.Sum([]byte("letmein")))
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	if got, err := NewRangeChecker(down.URL, filter).IsBreached(context.Background(), "letmein"); err != nil || !got {
+		t.Errorf("IsBreached with the API down = %v, %v, want the filter's true", got, err)
+	}
+	if _, err := NewRangeChecker(down.URL, nil).IsBreached(context.Background(), "letmein"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("IsBreached without a filter err = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestBloomFilter_RoundTrip(t *testing.T) {
+	var list strings.Builder
+	for i := range 1000 {
+		fmt.Fprintf(&list, "%s:%d\n", sha1Hex(fmt.Sprint("pw", i)), i+1)
+	}
+	filter, err := NewBloomFilter(1000, 0.001)
+	if err != nil {
+		t.Fatalf("NewBloomFilter: %v", err)
+	}
+	if n, err := filter.AddHashes(strings.NewReader(list.String())); err != nil || n != 1000 {
+		t.Fatalf("AddHashes = %d, %v", n, err)
+	}
+	var buf bytes.Buffer
+	if _, err := filter.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	read, err := ReadBloomFilter(&buf)
+	if err != nil {
+		t.Fatalf("ReadBloomFilter: %v", err)
+	}
+	falsePositives := 0
+	for i := range 1000 {
+		if !read.Test(sha1.Sum([]byte(fmt.Sprint("pw", i)))) {
+			t.Fatalf("pw%d is missing from the filter", i)
+		}
+		if read.Test(sha1.Sum([]byte(fmt.Sprint("other", i)))) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 10 {
+		t.Errorf("%d false positives in 1000, want about 1", falsePositives)
+	}
+
+	if _, err := ReadBloomFilter(strings.NewReader("not a filter at all")); err == nil {
+		t.Error("ReadBloomFilter(garbage) err = nil")
+	}
+	if _, err := filter.AddHashes(strings.NewReader("nothex:1\n")); err == nil {
+		t.Error("AddHashes(bad line) err = nil")
+	}
+}
+
+func TestNewChecker(t *testing.T) {
+	cfg := &config.AppConfig{}
+	c, err := NewChecker(cfg)
+	if err != nil || c.(*RangeChecker).apiURL != DefaultAPIURL {
+		t.Fatalf("NewChecker(default) = %+v, %v", c, err)
+	}
+
+	cfg.Password.BreachOffline = true
+	if _, err := NewChecker(cfg); err == nil {
+		t.Error("NewChecker(offline without a filter) err = nil")
+	}
+
+	filter, _ := NewBloomFilter(10, 0.01)
+	filter.Add(sha1.Sum([]byte("letmein")))
+	path := filepath.Join(t.TempDir(), "pwned.bloom")
+	file, _ := os.Create(path)
+	_, _ = filter.WriteTo(file)
+	_ = file.Close()
+	cfg.Password.BreachBloomFile = path
+	c, err = NewChecker(cfg)
+	if err != nil {
+		t.Fatalf("NewChecker(offline) err = %v", err)
+	}
+	if got, err := c.IsBreached(context.Background(), "letmein"); err != nil || !got {
+		t.Errorf("offline IsBreached = %v, %v, want true", got, err)
+	}
+}
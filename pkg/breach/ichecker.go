@@ -0,0 +1,9 @@
+package breach
+
+import "context"
+
+// IChecker tells whether a password appears in a known data breach.
+type IChecker interface {
+	// IsBreached returns an error only when no source could answer; callers usually let the password through then.
+	IsBreached(ctx context.Context, password string) (bool, error)
+}
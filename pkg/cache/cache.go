@@ -5,26 +5,59 @@ import (
 	"context"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hiamthach108/dreon-auth/config"
 	"github.com/hiamthach108/dreon-auth/pkg/logger"
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
 	ErrCacheNil = redis.Nil
 )
 
+// Cache drivers for CACHE_DRIVER.
+const (
+	DriverRedis  = "redis"
+	DriverMemory = "memory"
+)
+
+// defaultLocalTTL caps how long the local tier keeps an entry when CACHE_LOCAL_TTL_SEC is not set.
+const defaultLocalTTL = 30 * time.Second
+
 type appCache struct {
 	serviceName string
 	logger      logger.ILogger
 	redisClient *redis.Client
+	flights     *singleflight.Group
+	listeners   *listeners
+	ctx         context.Context
+}
+
+// listeners are the OnBroadcast subscriptions of a client, shared by its WithContext copies and closed with
+// it.
+type listeners struct {
+	mu   sync.Mutex
+	subs []*redis.PubSub
 }
 
+// NewAppCache returns the cache CACHE_DRIVER selects: Redis (the default), with an in-process LRU tier in
+// front of it when CACHE_LOCAL_SIZE is set, or a MemoryCache.
 func NewAppCache(config *config.AppConfig, logger logger.ILogger) (ICache, error) {
+	switch config.Cache.Driver {
+	case "", DriverRedis:
+	case DriverMemory:
+		logger.Warn("Using the in-memory cache: cached state is not shared between instances")
+		return NewMemoryCache(), nil
+	default:
+		return nil, fmt.Errorf("unknown cache driver %q", config.Cache.Driver)
+	}
+
 	redisClient := redis.NewClient(&redis.Options{
 		Addr:     config.Cache.RedisHost + ":" + config.Cache.RedisPort,
 		Password: config.Cache.RedisPassword,
@@ -39,13 +72,24 @@ func NewAppCache(config *config.AppConfig, logger logger.ILogger) (ICache, error
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
+	redisClient.AddHook(tracingHook{})
 	logger.Info("Connected to Redis successfully")
 
-	return &appCache{
+	remote := &appCache{
 		serviceName: config.App.Name,
 		logger:      logger,
 		redisClient: redisClient,
-	}, nil
+		flights:     &singleflight.Group{},
+		listeners:   &listeners{},
+	}
+	if config.Cache.LocalSize <= 0 {
+		return remote, nil
+	}
+	localTTL := time.Duration(config.Cache.LocalTTLSec) * time.Second
+	if localTTL <= 0 {
+		localTTL = defaultLocalTTL
+	}
+	return newLayeredCache(remote, config.Cache.LocalSize, localTTL)
 }
 
 // =============================
@@ -70,12 +114,24 @@ func (c *appCache) Set(key string, value any, expireTime *time.Duration) error {
 		data = jsonData
 	}
 
-	return c.redisClient.Set(context.Background(), rKey, data, *expireTime).Err()
+	return c.redisClient.Set(c.requestContext(), rKey, data, *expireTime).Err()
+}
+
+func (c *appCache) SetNX(key string, value any, expireTime *time.Duration) (bool, error) {
+	data, err := encodeValue(value)
+	if err != nil {
+		return false, err
+	}
+	var ttl time.Duration
+	if expireTime != nil {
+		ttl = *expireTime
+	}
+	return c.redisClient.SetNX(c.requestContext(), c.prefixedKey(key), data, ttl).Result()
 }
 
 func (c *appCache) Get(key string, data any) error {
 	rKey := c.prefixedKey(key)
-	val, err := c.redisClient.Get(context.Background(), rKey).Result()
+	val, err := c.redisClient.Get(c.requestContext(), rKey).Result()
 	if err != nil {
 		return err
 	}
@@ -87,17 +143,85 @@ func (c *appCache) Get(key string, data any) error {
 	return nil
 }
 
+// getRaw returns the stored bytes and their remaining TTL, negative when the key does not expire.
+func (c *appCache) getRaw(key string) ([]byte, time.Duration, error) {
+	rKey := c.prefixedKey(key)
+	ctx := c.requestContext()
+	pipe := c.redisClient.Pipeline()
+	get := pipe.Get(ctx, rKey)
+	ttl := pipe.PTTL(ctx, rKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, 0, err
+	}
+	return []byte(get.Val()), ttl.Val(), nil
+}
+
+func (c *appCache) GetMany(keys []string) (map[string][]byte, error) {
+	values, _, err := c.getManyRaw(keys)
+	return values, err
+}
+
+// getManyRaw is getRaw for several keys in one pipeline. Missing keys are left out of both maps.
+func (c *appCache) getManyRaw(keys []string) (map[string][]byte, map[string]time.Duration, error) {
+	values := make(map[string][]byte, len(keys))
+	ttls := make(map[string]time.Duration, len(keys))
+	if len(keys) == 0 {
+		return values, ttls, nil
+	}
+	ctx := c.requestContext()
+	pipe := c.redisClient.Pipeline()
+	gets := make([]*redis.StringCmd, len(keys))
+	pttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		gets[i] = pipe.Get(ctx, c.prefixedKey(key))
+		pttls[i] = pipe.PTTL(ctx, c.prefixedKey(key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, nil, err
+	}
+	for i, key := range keys {
+		if gets[i].Err() != nil {
+			continue
+		}
+		values[key] = []byte(gets[i].Val())
+		ttls[key] = pttls[i].Val()
+	}
+	return values, ttls, nil
+}
+
+func (c *appCache) SetMany(entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	ctx := c.requestContext()
+	pipe := c.redisClient.Pipeline()
+	for _, entry := range entries {
+		data, err := encodeValue(entry.Value)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, c.prefixedKey(entry.Key), data, entry.TTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *appCache) GetOrLoad(key string, data any, load LoadFunc) error {
+	return getOrLoad(c, c.flights, c.prefixedKey(key), key, data, load)
+}
+
 func (c *appCache) Delete(key string) error {
 	rKey := c.prefixedKey(key)
-	return c.redisClient.Del(context.Background(), rKey).Err()
+	c.flights.Forget(rKey)
+	return c.redisClient.Del(c.requestContext(), rKey).Err()
 }
 
 func (c *appCache) Clear() error {
-	return c.redisClient.FlushAll(context.Background()).Err()
+	return c.redisClient.FlushAll(c.requestContext()).Err()
 }
 
 func (c *appCache) ClearWithPrefix(prefix string) error {
-	ctx := context.Background()
+	ctx := c.requestContext()
 	pattern := c.prefixedKey(fmt.Sprintf("%s*", prefix))
 	keys, err := c.redisClient.Keys(ctx, pattern).Result()
 	if err != nil {
@@ -109,6 +233,57 @@ func (c *appCache) ClearWithPrefix(prefix string) error {
 	return nil
 }
 
+func (c *appCache) Increment(key string, expireTime *time.Duration) (int64, error) {
+	ctx := c.requestContext()
+	rKey := c.prefixedKey(key)
+	pipe := c.redisClient.TxPipeline()
+	incr := pipe.Incr(ctx, rKey)
+	if expireTime != nil {
+		pipe.ExpireNX(ctx, rKey, *expireTime)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// takeTokenScript keeps a bucket as a hash of its tokens and the time they were counted, using the Redis
+// clock so that every instance agrees. It returns whether a token was taken, the whole tokens left and the
+// milliseconds until the next one.
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1)
+return {allowed, math.floor(tokens), wait}
+`)
+
+func (c *appCache) TakeToken(key string, bucket TokenBucket) (TokenResult, error) {
+	res, err := takeTokenScript.Run(c.requestContext(), c.redisClient, []string{c.prefixedKey(key)}, bucket.Rate, bucket.Burst).Int64Slice()
+	if err != nil {
+		return TokenResult{}, err
+	}
+	return TokenResult{
+		Allowed:    res[0] == 1,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
 // =============================
 // 🔹 Leaderboard (Sorted Set)
 // =============================
@@ -116,7 +291,7 @@ func (c *appCache) ClearWithPrefix(prefix string) error {
 // AddScore adds or updates a member’s score in a leaderboard.
 func (c *appCache) AddScore(boardKey, member string, score float64) error {
 	rKey := c.prefixedKey(boardKey)
-	return c.redisClient.ZAdd(context.Background(), rKey, redis.Z{
+	return c.redisClient.ZAdd(c.requestContext(), rKey, redis.Z{
 		Score:  score,
 		Member: member,
 	}).Err()
@@ -125,7 +300,7 @@ func (c *appCache) AddScore(boardKey, member string, score float64) error {
 // GetTopN retrieves top N members with their scores in descending order.
 func (c *appCache) GetTopN(boardKey string, n int64) ([]LeaderboardEntry, error) {
 	rKey := c.prefixedKey(boardKey)
-	zResult, err := c.redisClient.ZRevRangeWithScores(context.Background(), rKey, 0, n-1).Result()
+	zResult, err := c.redisClient.ZRevRangeWithScores(c.requestContext(), rKey, 0, n-1).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -144,12 +319,12 @@ func (c *appCache) GetTopN(boardKey string, n int64) ([]LeaderboardEntry, error)
 // GetRank retrieves the rank (1-based) and score of a specific member.
 func (c *appCache) GetRank(boardKey, member string) (rank int64, score float64, err error) {
 	rKey := c.prefixedKey(boardKey)
-	rank, err = c.redisClient.ZRevRank(context.Background(), rKey, member).Result()
+	rank, err = c.redisClient.ZRevRank(c.requestContext(), rKey, member).Result()
 	if err != nil {
 		return 0, 0, err
 	}
 
-	score, err = c.redisClient.ZScore(context.Background(), rKey, member).Result()
+	score, err = c.redisClient.ZScore(c.requestContext(), rKey, member).Result()
 	if err != nil {
 		return 0, 0, err
 	}
@@ -160,13 +335,13 @@ func (c *appCache) GetRank(boardKey, member string) (rank int64, score float64,
 // RemoveMember removes a player from the leaderboard.
 func (c *appCache) RemoveMember(boardKey, member string) error {
 	rKey := c.prefixedKey(boardKey)
-	return c.redisClient.ZRem(context.Background(), rKey, member).Err()
+	return c.redisClient.ZRem(c.requestContext(), rKey, member).Err()
 }
 
 // GetAroundMember gets a window of players around a given member (for user’s local rank view)
 func (c *appCache) GetAroundMember(boardKey, member string, radius int64) ([]LeaderboardEntry, error) {
 	rKey := c.prefixedKey(boardKey)
-	rank, err := c.redisClient.ZRevRank(context.Background(), rKey, member).Result()
+	rank, err := c.redisClient.ZRevRank(c.requestContext(), rKey, member).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -177,7 +352,7 @@ func (c *appCache) GetAroundMember(boardKey, member string, radius int64) ([]Lea
 	}
 	end := rank + radius
 
-	zResult, err := c.redisClient.ZRevRangeWithScores(context.Background(), rKey, start, end).Result()
+	zResult, err := c.redisClient.ZRevRangeWithScores(c.requestContext(), rKey, start, end).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -208,7 +383,7 @@ func (c *appCache) Publish(stream string, message any) error {
 	}
 
 	// Store as binary data field
-	return c.redisClient.XAdd(context.Background(), &redis.XAddArgs{
+	return c.redisClient.XAdd(c.requestContext(), &redis.XAddArgs{
 		Stream: rKey,
 		Values: map[string]any{
 			"data": buf.Bytes(),
@@ -220,7 +395,7 @@ func (c *appCache) EnsureGroup(stream, group string) error {
 	rKey := c.prefixedKey(stream)
 
 	err := c.redisClient.
-		XGroupCreateMkStream(context.Background(), rKey, group, "$").
+		XGroupCreateMkStream(c.requestContext(), rKey, group, "$").
 		Err()
 
 	// If group already exists → ignore
@@ -245,6 +420,9 @@ func (c *appCache) Subscribe(stream string, group string, handler ConsumerHandle
 				Count:    1,
 				Block:    0,
 			}).Result()
+			if errors.Is(err, redis.ErrClosed) {
+				return
+			}
 			if err != nil {
 				c.logger.Error("Failed to read from stream", "stream", stream, "group", group, "error", err)
 				continue
@@ -263,6 +441,80 @@ func (c *appCache) Subscribe(stream string, group string, handler ConsumerHandle
 	return nil
 }
 
+// =============================
+// 🔹 Invalidation Bus
+// =============================
+
+// busChannel is the Redis pub/sub channel, under the service prefix, of a Broadcast channel.
+func (c *appCache) busChannel(channel string) string {
+	return c.prefixedKey("bus:" + channel)
+}
+
+func (c *appCache) Broadcast(channel, message string) error {
+	return c.redisClient.Publish(c.requestContext(), c.busChannel(channel), message).Err()
+}
+
+func (c *appCache) OnBroadcast(channel string, fn func(message string)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub := c.redisClient.Subscribe(ctx, c.busChannel(channel))
+	if _, err := sub.Receive(ctx); err != nil {
+		_ = sub.Close()
+		return err
+	}
+	c.listeners.mu.Lock()
+	c.listeners.subs = append(c.listeners.subs, sub)
+	c.listeners.mu.Unlock()
+
+	go func() {
+		for msg := range sub.ChannelWithSubscriptions() {
+			switch m := msg.(type) {
+			case *redis.Subscription:
+				if m.Kind == "subscribe" {
+					fn("")
+				}
+			case *redis.Message:
+				fn(m.Payload)
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *appCache) Ping() error {
+	return c.redisClient.Ping(c.requestContext()).Err()
+}
+
+// Close ends the OnBroadcast subscriptions, then closes the Redis client.
+func (c *appCache) Close() error {
+	if c.listeners != nil {
+		c.listeners.mu.Lock()
+		for _, sub := range c.listeners.subs {
+			if err := sub.Close(); err != nil {
+				c.logger.Warn("Failed to close broadcast subscription", "error", err)
+			}
+		}
+		c.listeners.subs = nil
+		c.listeners.mu.Unlock()
+	}
+	return c.redisClient.Close()
+}
+
+// WithContext returns a copy of the cache whose commands run with ctx, so they are traced as part of the
+// caller's request. Stream subscriptions always run in the background.
+func (c *appCache) WithContext(ctx context.Context) ICache {
+	cp := *c
+	cp.ctx = ctx
+	return &cp
+}
+
+func (c *appCache) requestContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
 func (c *appCache) prefixedKey(key string) string {
 	return fmt.Sprintf("%s:%s", c.serviceName, key)
 }
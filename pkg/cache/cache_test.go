@@ -66,6 +66,7 @@ func TestNewAppCache(t *testing.T) {
 				App: struct {
 					Name    string `env:"APP_NAME"`
 					Version string `env:"APP_VERSION"`
+					Env     string `env:"APP_ENV"`
 				}{
 					Name: "test-service",
 				},
@@ -76,6 +77,9 @@ func TestNewAppCache(t *testing.T) {
 					RedisPort            string `env:"REDIS_PORT"`
 					RedisPassword        string `env:"REDIS_PASSWORD"`
 					RedisDB              int    `env:"REDIS_DB"`
+					Driver               string `env:"CACHE_DRIVER"`
+					LocalSize            int    `env:"CACHE_LOCAL_SIZE"`
+					LocalTTLSec          int    `env:"CACHE_LOCAL_TTL_SEC"`
 				}{
 					RedisHost:     "localhost",
 					RedisPort:     "6379",
@@ -187,6 +191,21 @@ func TestAppCache_Integration(t *testing.T) {
 		assert.Equal(t, value, result)
 	})
 
+	t.Run("SetNX", func(t *testing.T) {
+		expireTime := 5 * time.Minute
+		ok, err := cache.SetNX("test-nx-key", map[string]string{"owner": "first"}, &expireTime)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = cache.SetNX("test-nx-key", map[string]string{"owner": "second"}, &expireTime)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		var result map[string]string
+		assert.NoError(t, cache.Get("test-nx-key", &result))
+		assert.Equal(t, "first", result["owner"])
+	})
+
 	t.Run("Set and Get struct", func(t *testing.T) {
 		type TestStruct struct {
 			Name  string `json:"name"`
@@ -1040,6 +1059,25 @@ func TestAppCache_Expiration(t *testing.T) {
 		redisClient: redisClient,
 	}
 
+	t.Run("Increment keeps the first expiry", func(t *testing.T) {
+		key := "test-counter"
+		expireTime := 1 * time.Second
+
+		n, err := cache.Increment(key, &expireTime)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		longer := time.Hour
+		n, err = cache.Increment(key, &longer)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+
+		time.Sleep(1100 * time.Millisecond)
+		n, err = cache.Increment(key, &expireTime)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	})
+
 	t.Run("Key expires after TTL", func(t *testing.T) {
 		key := "test-expiring-key"
 		value := "test-value"
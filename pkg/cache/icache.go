@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"time"
 )
 
@@ -18,12 +19,52 @@ type ConsumerHandler struct {
 	Handler  func(message any)
 }
 
+// TokenBucket is a rate limit: up to Burst requests at once, refilled at Rate requests per second.
+type TokenBucket struct {
+	Rate  float64
+	Burst int
+}
+
+// TokenResult is the outcome of TakeToken.
+type TokenResult struct {
+	Allowed    bool
+	Remaining  int           // whole tokens left after this request
+	RetryAfter time.Duration // until the next token, when not allowed
+}
+
+// Entry is one value written by SetMany.
+type Entry struct {
+	Key   string
+	Value any
+	TTL   time.Duration
+}
+
 type ICache interface {
 	Set(key string, value any, expireTime *time.Duration) error
 	Get(key string, data any) error
+	// SetNX stores value under key only if the key does not exist, and reports whether it did. The check and
+	// the write are one atomic step, so of several callers racing for the same key exactly one wins.
+	SetNX(key string, value any, expireTime *time.Duration) (bool, error)
+	// GetOrLoad reads key into data like Get. On a miss it calls load, caches the value for the TTL load
+	// returns and decodes it into data. Concurrent misses for the same key in this process share one load
+	// call, so a hot key that expires does not send every waiting request to the database. Errors from load
+	// are returned as they are. Delete ends the sharing: misses after it start a new load rather than wait for
+	// one that may have read the data before it changed.
+	GetOrLoad(key string, data any, load LoadFunc) error
+	// GetMany reads keys in one round trip and returns the stored bytes of those found, by key, for the caller
+	// to decode as JSON. Missing keys are left out.
+	GetMany(keys []string) (map[string][]byte, error)
+	// SetMany writes entries in one round trip, each with its own TTL.
+	SetMany(entries []Entry) error
 	Delete(key string) error
 	Clear() error
 	ClearWithPrefix(prefix string) error
+	// Increment atomically adds 1 to an integer counter and returns the new value.
+	// The expiry is only set when the counter is created, so windows are fixed rather than sliding.
+	Increment(key string, expireTime *time.Duration) (int64, error)
+	// TakeToken atomically takes one token from the bucket at key, first adding those earned since the last
+	// call. Every process using the same store shares the bucket, which expires once it would be full again.
+	TakeToken(key string, bucket TokenBucket) (TokenResult, error)
 	// Leaderboard (Sorted Set) methods
 	AddScore(boardKey, member string, score float64) error
 	GetTopN(boardKey string, n int64) ([]LeaderboardEntry, error)
@@ -35,4 +76,19 @@ type ICache interface {
 	Publish(stream string, message any) error
 	EnsureGroup(stream string, group string) error
 	Subscribe(stream string, group string, handler ConsumerHandler) error
+
+	// Invalidation bus: Broadcast sends message on channel to every instance listening to it with
+	// OnBroadcast, this one included, so each can drop or reload state it keeps in process. Delivery is at
+	// most once, so pair it with a periodic reload. Listeners are also called with an empty message when
+	// their subscription reconnects, since messages sent while it was down are lost.
+	Broadcast(channel, message string) error
+	OnBroadcast(channel string, fn func(message string)) error
+
+	// Ping checks that the store answers, for the readiness probe.
+	Ping() error
+	// Close releases the connections and ends the subscriptions. Call it once, on shutdown.
+	Close() error
+
+	// WithContext returns a cache whose commands carry ctx, so they show up in the caller's trace.
+	WithContext(ctx context.Context) ICache
 }
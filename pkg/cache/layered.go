@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// invalidationChannel is the Redis pub/sub channel, under the service prefix, on which instances announce
+// the keys they changed so the others drop their local copies.
+const invalidationChannel = "cache:invalidate"
+
+// layeredCache keeps recently read values in an in-process LRU in front of Redis. Only Get hits are kept
+// locally: writes go to Redis and evict the key on every instance, and misses are never cached, so a value
+// set anywhere is seen at once. Counters, leaderboards and streams always use Redis.
+//
+// Invalidations are best effort. An instance that misses one, for example while its subscription
+// reconnects, serves the old value until the local entry expires, which is what localTTL bounds.
+type layeredCache struct {
+	*appCache
+	local    *lru
+	localTTL time.Duration
+	origin   string // tells this instance's invalidations from the others'
+	flights  *singleflight.Group
+	pubsub   *redis.PubSub
+}
+
+type invalidation struct {
+	Origin string   `json:"origin"`
+	Key    string   `json:"key,omitempty"`
+	Keys   []string `json:"keys,omitempty"`
+	Prefix string   `json:"prefix,omitempty"`
+	All    bool     `json:"all,omitempty"`
+}
+
+func newLayeredCache(remote *appCache, size int, localTTL time.Duration) (*layeredCache, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	c := &layeredCache{
+		appCache: remote,
+		local:    newLRU(size),
+		localTTL: localTTL,
+		origin:   hex.EncodeToString(id),
+		flights:  &singleflight.Group{},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pubsub := remote.redisClient.Subscribe(ctx, remote.prefixedKey(invalidationChannel))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+	c.pubsub = pubsub
+	go c.listen(pubsub)
+	return c, nil
+}
+
+func (c *layeredCache) Set(key string, value any, expireTime *time.Duration) error {
+	if err := c.appCache.Set(key, value, expireTime); err != nil {
+		return err
+	}
+	c.local.delete(key)
+	c.broadcast(invalidation{Key: key})
+	return nil
+}
+
+func (c *layeredCache) SetNX(key string, value any, expireTime *time.Duration) (bool, error) {
+	ok, err := c.appCache.SetNX(key, value, expireTime)
+	if err != nil || !ok {
+		return ok, err
+	}
+	c.local.delete(key)
+	c.broadcast(invalidation{Key: key})
+	return true, nil
+}
+
+func (c *layeredCache) Get(key string, data any) error {
+	if raw, ok := c.local.get(key); ok {
+		return decodeValue(raw, data)
+	}
+	version := c.local.currentVersion()
+	raw, ttl, err := c.appCache.getRaw(key)
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 || ttl > c.localTTL {
+		ttl = c.localTTL
+	}
+	c.local.setUnlessChanged(key, raw, ttl, version)
+	return decodeValue(raw, data)
+}
+
+// GetMany serves what it can from the local tier and reads the rest from Redis in one pipeline, keeping
+// those locally as Get does.
+func (c *layeredCache) GetMany(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	var remote []string
+	for _, key := range keys {
+		if raw, ok := c.local.get(key); ok {
+			values[key] = raw
+		} else {
+			remote = append(remote, key)
+		}
+	}
+	if len(remote) == 0 {
+		return values, nil
+	}
+	version := c.local.currentVersion()
+	found, ttls, err := c.appCache.getManyRaw(remote)
+	if err != nil {
+		return nil, err
+	}
+	for key, raw := range found {
+		ttl := ttls[key]
+		if ttl <= 0 || ttl > c.localTTL {
+			ttl = c.localTTL
+		}
+		c.local.setUnlessChanged(key, raw, ttl, version)
+		values[key] = raw
+	}
+	return values, nil
+}
+
+// SetMany writes entries to Redis and evicts them everywhere with a single invalidation.
+func (c *layeredCache) SetMany(entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := c.appCache.SetMany(entries); err != nil {
+		return err
+	}
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+		c.local.delete(entry.Key)
+	}
+	c.broadcast(invalidation{Keys: keys})
+	return nil
+}
+
+func (c *layeredCache) GetOrLoad(key string, data any, load LoadFunc) error {
+	return getOrLoad(c, c.flights, key, key, data, load)
+}
+
+func (c *layeredCache) Delete(key string) error {
+	c.flights.Forget(key)
+	if err := c.appCache.Delete(key); err != nil {
+		return err
+	}
+	c.local.delete(key)
+	c.broadcast(invalidation{Key: key})
+	return nil
+}
+
+func (c *layeredCache) Clear() error {
+	if err := c.appCache.Clear(); err != nil {
+		return err
+	}
+	c.local.clear()
+	c.broadcast(invalidation{All: true})
+	return nil
+}
+
+func (c *layeredCache) ClearWithPrefix(prefix string) error {
+	if err := c.appCache.ClearWithPrefix(prefix); err != nil {
+		return err
+	}
+	c.local.deletePrefix(prefix)
+	c.broadcast(invalidation{Prefix: prefix, All: prefix == ""})
+	return nil
+}
+
+// WithContext returns a copy of the cache whose Redis commands run with ctx. The copy shares the local tier.
+func (c *layeredCache) WithContext(ctx context.Context) ICache {
+	cp := *c
+	cp.appCache = c.appCache.WithContext(ctx).(*appCache)
+	return &cp
+}
+
+// Close ends the invalidation subscription, then closes the Redis client.
+func (c *layeredCache) Close() error {
+	if err := c.pubsub.Close(); err != nil {
+		c.logger.Warn("Failed to close cache invalidation subscription", "error", err)
+	}
+	return c.appCache.Close()
+}
+
+// broadcast tells the other instances to evict what changed. A failure is only logged: their entries still
+// expire after localTTL.
+func (c *layeredCache) broadcast(msg invalidation) {
+	msg.Origin = c.origin
+	payload, err := json.Marshal(msg)
+	if err == nil {
+		err = c.redisClient.Publish(c.requestContext(), c.prefixedKey(invalidationChannel), payload).Err()
+	}
+	if err != nil {
+		c.logger.Warn("Failed to broadcast cache invalidation", "error", err)
+	}
+}
+
+// listen applies the other instances' invalidations until the subscription is closed. Messages sent while
+// the subscription was down are lost, so the local tier is emptied whenever it (re)subscribes.
+func (c *layeredCache) listen(pubsub *redis.PubSub) {
+	for msg := range pubsub.ChannelWithSubscriptions() {
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind == "subscribe" {
+				c.local.clear()
+			}
+		case *redis.Message:
+			var inv invalidation
+			if err := json.Unmarshal([]byte(m.Payload), &inv); err != nil || inv.Origin == c.origin {
+				continue
+			}
+			switch {
+			case inv.All:
+				c.local.clear()
+			case inv.Prefix != "":
+				c.local.deletePrefix(inv.Prefix)
+			default:
+				// Misses after another instance's change must not wait for a load that may predate it.
+				for _, key := range append(inv.Keys, inv.Key) {
+					c.flights.Forget(key)
+					c.local.delete(key)
+				}
+			}
+		}
+	}
+}
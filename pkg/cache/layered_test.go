@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLayeredCache(t *testing.T) *layeredCache {
+	t.Helper()
+	cfg := &config.AppConfig{}
+	cfg.App.Name = "test-service"
+	cfg.Cache.RedisHost = "localhost"
+	cfg.Cache.RedisPort = "6379"
+	cfg.Cache.RedisDB = 1
+	cfg.Cache.LocalSize = 100
+	c, err := NewAppCache(cfg, &MockLogger{})
+	if err != nil {
+		t.Skip("Redis not available, skipping test")
+	}
+	require.IsType(t, &layeredCache{}, c)
+	return c.(*layeredCache)
+}
+
+func TestLayeredCache_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+	a := newTestLayeredCache(t)
+	b := newTestLayeredCache(t)
+	defer a.Clear()
+
+	ttl := time.Minute
+	require.NoError(t, a.Set("perm:u1", map[string]bool{"system/view": true}, &ttl))
+
+	t.Run("Get fills the local tier", func(t *testing.T) {
+		var got map[string]bool
+		require.NoError(t, b.Get("perm:u1", &got))
+		assert.True(t, got["system/view"])
+		_, ok := b.local.get("perm:u1")
+		assert.True(t, ok)
+	})
+
+	t.Run("writes on another instance evict the local copy", func(t *testing.T) {
+		require.NoError(t, a.Set("perm:u1", map[string]bool{"system/update": true}, &ttl))
+		assert.Eventually(t, func() bool {
+			var got map[string]bool
+			return b.Get("perm:u1", &got) == nil && got["system/update"]
+		}, time.Second, 10*time.Millisecond)
+
+		require.NoError(t, a.Delete("perm:u1"))
+		assert.Eventually(t, func() bool {
+			var got map[string]bool
+			return b.Get("perm:u1", &got) == ErrCacheNil
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("misses are not cached locally", func(t *testing.T) {
+		var got map[string]bool
+		assert.Equal(t, ErrCacheNil, b.Get("perm:u2", &got))
+		require.NoError(t, a.Set("perm:u2", map[string]bool{"system/view": true}, &ttl))
+		require.NoError(t, b.Get("perm:u2", &got))
+		assert.True(t, got["system/view"])
+	})
+
+	t.Run("bulk reads and writes", func(t *testing.T) {
+		require.NoError(t, a.SetMany([]Entry{
+			{Key: "perm:u3", Value: map[string]bool{"system/view": true}, TTL: ttl},
+			{Key: "perm:u4", Value: map[string]bool{"system/update": true}, TTL: ttl},
+		}))
+		values, err := b.GetMany([]string{"perm:u3", "perm:u4", "perm:missing"})
+		require.NoError(t, err)
+		assert.Len(t, values, 2)
+		_, ok := b.local.get("perm:u3")
+		assert.True(t, ok)
+
+		require.NoError(t, a.SetMany([]Entry{{Key: "perm:u3", Value: map[string]bool{}, TTL: ttl}}))
+		assert.Eventually(t, func() bool {
+			_, ok := b.local.get("perm:u3")
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("broadcasts reach every instance", func(t *testing.T) {
+		got := make(chan string, 2)
+		require.NoError(t, b.OnBroadcast("keys", func(message string) { got <- message }))
+		require.NoError(t, a.Broadcast("keys", "kid-1"))
+		select {
+		case message := <-got:
+			assert.Equal(t, "kid-1", message)
+		case <-time.After(time.Second):
+			t.Fatal("broadcast not received")
+		}
+	})
+
+	t.Run("counters bypass the local tier", func(t *testing.T) {
+		n, err := a.Increment("otp:send", &ttl)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		n, err = b.Increment("otp:send", &ttl)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+	})
+}
+
+func TestNewAppCache_MemoryDriver(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.Cache.Driver = DriverMemory
+	c, err := NewAppCache(cfg, &MockLogger{})
+	require.NoError(t, err)
+	assert.IsType(t, &MemoryCache{}, c)
+
+	cfg.Cache.Driver = "memcached"
+	_, err = NewAppCache(cfg, &MockLogger{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// LoadFunc produces the value to cache on a GetOrLoad miss and how long to keep it.
+type LoadFunc func() (value any, ttl time.Duration, err error)
+
+// getOrLoad implements GetOrLoad on top of c's Get and Set. Concurrent misses on flightKey share one call to
+// load; each caller then decodes its own copy of the value into data.
+func getOrLoad(c ICache, flights *singleflight.Group, flightKey, key string, data any, load LoadFunc) error {
+	err := c.Get(key, data)
+	if err != ErrCacheNil {
+		return err
+	}
+	loaded, err, _ := flights.Do(flightKey, func() (any, error) {
+		value, ttl, err := load()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.Set(key, value, &ttl); err != nil {
+			return nil, err
+		}
+		return json.Marshal(value)
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(loaded.([]byte), data)
+}
+
+// encodeValue returns value the way Redis stores it: primitives as their string form, everything else as
+// JSON.
+func encodeValue(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case string, int, int64, float64, bool:
+		return []byte(fmt.Sprint(v)), nil
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal value: %w", err)
+		}
+		return data, nil
+	}
+}
+
+func decodeValue(raw []byte, data any) error {
+	return json.Unmarshal(raw, data)
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lru is a size-bounded map of encoded values with per-entry expiry. Reads move an entry to the front and
+// the least recently used entry is evicted when the map is full. A maxEntries of 0 means no bound.
+type lru struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List // front is most recently used
+	items      map[string]*list.Element
+	now        func() time.Time
+	// version counts the calls that drop entries, so a reader can tell whether what it fetched elsewhere
+	// was invalidated before it got to store it.
+	version uint64
+}
+
+type lruEntry struct {
+	key       string
+	data      []byte
+	expiresAt time.Time // zero means no expiry
+}
+
+func newLRU(maxEntries int) *lru {
+	return &lru{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func (l *lru) get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	return e.data, true
+}
+
+// set stores data under key for ttl; a ttl of 0 or less keeps it until it is evicted.
+func (l *lru) set(key string, data []byte, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.store(key, data, ttl)
+}
+
+// setUnlessChanged is set, skipped if anything was dropped since currentVersion returned version.
+func (l *lru) setUnlessChanged(key string, data []byte, ttl time.Duration, version uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.version == version {
+		l.store(key, data, ttl)
+	}
+}
+
+func (l *lru) currentVersion() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.version
+}
+
+func (l *lru) delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.version++
+	if el, ok := l.items[key]; ok {
+		l.remove(el)
+	}
+}
+
+func (l *lru) deletePrefix(prefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.version++
+	for key, el := range l.items {
+		if strings.HasPrefix(key, prefix) {
+			l.remove(el)
+		}
+	}
+}
+
+func (l *lru) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.version++
+	l.order.Init()
+	l.items = make(map[string]*list.Element)
+}
+
+// keys returns the live keys, in no particular order.
+func (l *lru) keys() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	keys := make([]string, 0, len(l.items))
+	for key := range l.items {
+		if _, ok := l.lookup(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// lookup returns a live entry and marks it used, evicting it if expired. Caller must hold l.mu.
+func (l *lru) lookup(key string) (*lruEntry, bool) {
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if !e.expiresAt.IsZero() && !l.now().Before(e.expiresAt) {
+		l.remove(el)
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return e, true
+}
+
+// store inserts or replaces an entry, evicting the least recently used one if the map is full. Caller must
+// hold l.mu.
+func (l *lru) store(key string, data []byte, ttl time.Duration) {
+	e := &lruEntry{key: key, data: data}
+	if ttl > 0 {
+		e.expiresAt = l.now().Add(ttl)
+	}
+	if el, ok := l.items[key]; ok {
+		el.Value = e
+		l.order.MoveToFront(el)
+		return
+	}
+	l.items[key] = l.order.PushFront(e)
+	if l.maxEntries > 0 && l.order.Len() > l.maxEntries {
+		l.remove(l.order.Back())
+	}
+}
+
+// remove drops an entry. Caller must hold l.mu.
+func (l *lru) remove(el *list.Element) {
+	l.order.Remove(el)
+	delete(l.items, el.Value.(*lruEntry).key)
+}
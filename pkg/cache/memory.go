@@ -0,0 +1,318 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// MemoryCache is an ICache kept in process memory, for local development and tests without Redis
+// (CACHE_DRIVER=memory). Nothing is shared between processes, so do not use it with several replicas.
+// Values are stored the way the Redis cache stores them (primitives as their string form, everything else as
+// JSON) so Get round-trips behave the same.
+type MemoryCache struct {
+	entries *lru
+	flights *singleflight.Group
+
+	mu          sync.Mutex
+	boards      map[string]map[string]float64
+	subscribers map[string][]ConsumerHandler
+	listeners   map[string][]func(message string)
+}
+
+var _ ICache = (*MemoryCache)(nil)
+
+// NewMemoryCache returns an empty in-memory cache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		entries:     newLRU(0),
+		flights:     &singleflight.Group{},
+		boards:      make(map[string]map[string]float64),
+		subscribers: make(map[string][]ConsumerHandler),
+		listeners:   make(map[string][]func(message string)),
+	}
+}
+
+// SetClock overrides the clock used to expire entries.
+func (c *MemoryCache) SetClock(now func() time.Time) {
+	c.entries.mu.Lock()
+	defer c.entries.mu.Unlock()
+	c.entries.now = now
+}
+
+// Keys returns the live keys, sorted. Useful for asserting what a service cached.
+func (c *MemoryCache) Keys() []string {
+	keys := c.entries.keys()
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *MemoryCache) Set(key string, value any, expireTime *time.Duration) error {
+	data, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	var ttl time.Duration
+	if expireTime != nil {
+		ttl = *expireTime
+	}
+	c.entries.set(key, data, ttl)
+	return nil
+}
+
+func (c *MemoryCache) SetNX(key string, value any, expireTime *time.Duration) (bool, error) {
+	data, err := encodeValue(value)
+	if err != nil {
+		return false, err
+	}
+	var ttl time.Duration
+	if expireTime != nil {
+		ttl = *expireTime
+	}
+	l := c.entries
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.lookup(key); ok {
+		return false, nil
+	}
+	l.store(key, data, ttl)
+	return true, nil
+}
+
+func (c *MemoryCache) Get(key string, data any) error {
+	raw, ok := c.entries.get(key)
+	if !ok {
+		return ErrCacheNil
+	}
+	return decodeValue(raw, data)
+}
+
+func (c *MemoryCache) GetMany(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if raw, ok := c.entries.get(key); ok {
+			values[key] = raw
+		}
+	}
+	return values, nil
+}
+
+func (c *MemoryCache) SetMany(entries []Entry) error {
+	for _, entry := range entries {
+		if err := c.Set(entry.Key, entry.Value, &entry.TTL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *MemoryCache) GetOrLoad(key string, data any, load LoadFunc) error {
+	return getOrLoad(c, c.flights, key, key, data, load)
+}
+
+func (c *MemoryCache) Delete(key string) error {
+	c.flights.Forget(key)
+	c.entries.delete(key)
+	return nil
+}
+
+func (c *MemoryCache) Clear() error {
+	c.entries.clear()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.boards = make(map[string]map[string]float64)
+	return nil
+}
+
+func (c *MemoryCache) ClearWithPrefix(prefix string) error {
+	c.entries.deletePrefix(prefix)
+	return nil
+}
+
+func (c *MemoryCache) Increment(key string, expireTime *time.Duration) (int64, error) {
+	l := c.entries
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var n int64
+	if e, ok := l.lookup(key); ok {
+		if _, err := fmt.Sscan(string(e.data), &n); err != nil {
+			return 0, fmt.Errorf("value is not an integer: %w", err)
+		}
+		n++
+		e.data = []byte(fmt.Sprint(n))
+		return n, nil
+	}
+	var ttl time.Duration
+	if expireTime != nil {
+		ttl = *expireTime
+	}
+	l.store(key, []byte("1"), ttl)
+	return 1, nil
+}
+
+func (c *MemoryCache) TakeToken(key string, bucket TokenBucket) (TokenResult, error) {
+	l := c.entries
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var state struct {
+		Tokens float64   `json:"tokens"`
+		At     time.Time `json:"at"`
+	}
+	if e, ok := l.lookup(key); ok {
+		if err := decodeValue(e.data, &state); err != nil {
+			return TokenResult{}, err
+		}
+	} else {
+		state.Tokens, state.At = float64(bucket.Burst), now
+	}
+	state.Tokens = min(float64(bucket.Burst), state.Tokens+max(0, now.Sub(state.At).Seconds())*bucket.Rate)
+	state.At = now
+
+	var result TokenResult
+	if state.Tokens >= 1 {
+		state.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1-state.Tokens)/bucket.Rate*1000)) * time.Millisecond
+	}
+	result.Remaining = int(state.Tokens)
+
+	data, err := encodeValue(state)
+	if err != nil {
+		return TokenResult{}, err
+	}
+	refill := time.Duration((float64(bucket.Burst)-state.Tokens)/bucket.Rate*float64(time.Second)) + time.Millisecond
+	l.store(key, data, refill)
+	return result, nil
+}
+
+func (c *MemoryCache) AddScore(boardKey, member string, score float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.boards[boardKey] == nil {
+		c.boards[boardKey] = make(map[string]float64)
+	}
+	c.boards[boardKey][member] = score
+	return nil
+}
+
+func (c *MemoryCache) GetTopN(boardKey string, n int64) ([]LeaderboardEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ranked := c.ranked(boardKey)
+	if n >= 0 && int64(len(ranked)) > n {
+		ranked = ranked[:n]
+	}
+	return ranked, nil
+}
+
+func (c *MemoryCache) GetRank(boardKey, member string) (rank int64, score float64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, e := range c.ranked(boardKey) {
+		if e.Member == member {
+			return int64(i) + 1, e.Score, nil
+		}
+	}
+	return 0, 0, ErrCacheNil
+}
+
+func (c *MemoryCache) RemoveMember(boardKey, member string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.boards[boardKey], member)
+	return nil
+}
+
+func (c *MemoryCache) GetAroundMember(boardKey, member string, radius int64) ([]LeaderboardEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ranked := c.ranked(boardKey)
+	for i, e := range ranked {
+		if e.Member != member {
+			continue
+		}
+		start := max(int64(i)-radius, 0)
+		end := min(int64(i)+radius+1, int64(len(ranked)))
+		return ranked[start:end], nil
+	}
+	return nil, ErrCacheNil
+}
+
+// Publish delivers message synchronously to every handler subscribed to stream.
+// Handlers receive the same shape as the Redis implementation: a map with the message under "data".
+func (c *MemoryCache) Publish(stream string, message any) error {
+	c.mu.Lock()
+	handlers := append([]ConsumerHandler(nil), c.subscribers[stream]...)
+	c.mu.Unlock()
+	for _, h := range handlers {
+		h.Handler(map[string]any{"data": message})
+	}
+	return nil
+}
+
+func (c *MemoryCache) EnsureGroup(stream string, group string) error {
+	return nil
+}
+
+func (c *MemoryCache) Subscribe(stream string, group string, handler ConsumerHandler) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribers[stream] = append(c.subscribers[stream], handler)
+	return nil
+}
+
+// Broadcast calls this process's listeners on channel before returning: there are no other instances.
+func (c *MemoryCache) Broadcast(channel, message string) error {
+	c.mu.Lock()
+	listeners := append([]func(string){}, c.listeners[channel]...)
+	c.mu.Unlock()
+	for _, fn := range listeners {
+		fn(message)
+	}
+	return nil
+}
+
+func (c *MemoryCache) OnBroadcast(channel string, fn func(message string)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners[channel] = append(c.listeners[channel], fn)
+	return nil
+}
+
+// Ping always succeeds: the store is in process.
+func (c *MemoryCache) Ping() error {
+	return nil
+}
+
+// Close is a no-op: there are no connections to release.
+func (c *MemoryCache) Close() error {
+	return nil
+}
+
+// WithContext returns c: the in-memory cache has nothing to trace.
+func (c *MemoryCache) WithContext(ctx context.Context) ICache {
+	return c
+}
+
+// ranked returns the board entries by descending score. Caller must hold c.mu.
+func (c *MemoryCache) ranked(boardKey string) []LeaderboardEntry {
+	board := c.boards[boardKey]
+	entries := make([]LeaderboardEntry, 0, len(board))
+	for m, s := range board {
+		entries = append(entries, LeaderboardEntry{Member: m, Score: s})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		return entries[i].Member.(string) > entries[j].Member.(string)
+	})
+	return entries
+}
@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCache_SetGetExpire(t *testing.T) {
+	c := NewMemoryCache()
+	now := time.Now()
+	c.SetClock(func() time.Time { return now })
+
+	ttl := time.Minute
+	require.NoError(t, c.Set("perm:u1", map[string]bool{"system/view": true}, &ttl))
+	var got map[string]bool
+	require.NoError(t, c.Get("perm:u1", &got))
+	assert.True(t, got["system/view"])
+
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, ErrCacheNil, c.Get("perm:u1", &got))
+	assert.Empty(t, c.Keys())
+}
+
+func TestMemoryCache_Increment(t *testing.T) {
+	c := NewMemoryCache()
+	now := time.Now()
+	c.SetClock(func() time.Time { return now })
+
+	window := time.Minute
+	for want := int64(1); want <= 3; want++ {
+		n, err := c.Increment("otp:send", &window)
+		require.NoError(t, err)
+		assert.Equal(t, want, n)
+	}
+
+	// The window is fixed from the first increment.
+	now = now.Add(window)
+	n, err := c.Increment("otp:send", &window)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestMemoryCache_SetNX(t *testing.T) {
+	c := NewMemoryCache()
+	now := time.Now()
+	c.SetClock(func() time.Time { return now })
+
+	// Of several callers racing for a key exactly one stores it.
+	ttl := time.Minute
+	var wins atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := c.SetNX("state:s1", i, &ttl)
+			assert.NoError(t, err)
+			if ok {
+				wins.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), wins.Load())
+
+	// An expired key is free again.
+	now = now.Add(ttl)
+	ok, err := c.SetNX("state:s1", "again", &ttl)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestMemoryCache_GetOrLoad(t *testing.T) {
+	c := NewMemoryCache()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func() (any, time.Duration, error) {
+		calls.Add(1)
+		<-release
+		return map[string]bool{"system/view": true}, time.Minute, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]map[string]bool, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, c.GetOrLoad("perm:u1", &results[i], load))
+		}()
+	}
+	// Give the callers time to pile up on the miss before the load finishes.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, got := range results {
+		assert.True(t, got["system/view"])
+	}
+	assert.Equal(t, []string{"perm:u1"}, c.Keys())
+
+	// A hit does not call load.
+	var got map[string]bool
+	require.NoError(t, c.GetOrLoad("perm:u1", &got, func() (any, time.Duration, error) {
+		t.Fatal("load called on a hit")
+		return nil, 0, nil
+	}))
+}
+
+func TestMemoryCache_GetOrLoad_Error(t *testing.T) {
+	c := NewMemoryCache()
+	errLoad := errors.New("database down")
+
+	var got map[string]bool
+	err := c.GetOrLoad("perm:u1", &got, func() (any, time.Duration, error) {
+		return nil, 0, errLoad
+	})
+	assert.Same(t, errLoad, err)
+	assert.Empty(t, c.Keys(), "failed loads are not cached")
+}
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	l := newLRU(2)
+	l.set("a", []byte("1"), 0)
+	l.set("b", []byte("2"), 0)
+	_, _ = l.get("a") // b is now the least recently used
+	l.set("c", []byte("3"), 0)
+
+	assert.ElementsMatch(t, []string{"a", "c"}, l.keys())
+}
+
+func TestLRU_SetUnlessChanged(t *testing.T) {
+	l := newLRU(0)
+	version := l.currentVersion()
+	l.delete("a") // an invalidation while the value was being fetched
+	l.setUnlessChanged("a", []byte("stale"), 0, version)
+	_, ok := l.get("a")
+	assert.False(t, ok)
+
+	l.setUnlessChanged("a", []byte("fresh"), 0, l.currentVersion())
+	got, ok := l.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "fresh", string(got))
+}
+
+func TestMemoryCache_TakeToken(t *testing.T) {
+	c := NewMemoryCache()
+	now := time.Now()
+	c.SetClock(func() time.Time { return now })
+	bucket := TokenBucket{Rate: 1, Burst: 2}
+
+	for want := 1; want >= 0; want-- {
+		result, err := c.TakeToken("login:ip:192.0.2.1", bucket)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, want, result.Remaining)
+	}
+	result, err := c.TakeToken("login:ip:192.0.2.1", bucket)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+
+	now = now.Add(1500 * time.Millisecond)
+	result, err = c.TakeToken("login:ip:192.0.2.1", bucket)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// A full bucket is dropped rather than kept around.
+	now = now.Add(time.Minute)
+	assert.Empty(t, c.Keys())
+}
+
+func TestMemoryCache_GetOrLoad_DeleteStartsNewLoad(t *testing.T) {
+	c := NewMemoryCache()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+	stale := func() (any, time.Duration, error) {
+		calls.Add(1)
+		close(started)
+		<-release
+		return map[string]bool{"system/view": true}, time.Minute, nil
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var got map[string]bool
+		assert.NoError(t, c.GetOrLoad("perm:u1", &got, stale))
+	}()
+	<-started
+
+	// The permissions changed while the first load was reading them: a miss after the Delete must not wait
+	// for that load's result.
+	require.NoError(t, c.Delete("perm:u1"))
+	var got map[string]bool
+	require.NoError(t, c.GetOrLoad("perm:u1", &got, func() (any, time.Duration, error) {
+		calls.Add(1)
+		return map[string]bool{}, time.Minute, nil
+	}))
+	assert.Empty(t, got)
+	assert.Equal(t, int32(2), calls.Load())
+
+	close(release)
+	<-done
+}
+
+func TestMemoryCache_Broadcast(t *testing.T) {
+	c := NewMemoryCache()
+	var got []string
+	require.NoError(t, c.OnBroadcast("keys", func(message string) { got = append(got, "a:"+message) }))
+	require.NoError(t, c.OnBroadcast("keys", func(message string) { got = append(got, "b:"+message) }))
+	require.NoError(t, c.OnBroadcast("other", func(message string) { got = append(got, "other:"+message) }))
+
+	require.NoError(t, c.Broadcast("keys", "kid-1"))
+	assert.Equal(t, []string{"a:kid-1", "b:kid-1"}, got)
+}
+
+func TestMemoryCache_GetManySetMany(t *testing.T) {
+	c := NewMemoryCache()
+	now := time.Now()
+	c.SetClock(func() time.Time { return now })
+
+	require.NoError(t, c.SetMany([]Entry{
+		{Key: "perm:u1", Value: map[string]bool{"system/view": true}, TTL: time.Minute},
+		{Key: "perm:u2", Value: map[string]bool{"system/update": true}, TTL: time.Hour},
+	}))
+	values, err := c.GetMany([]string{"perm:u1", "perm:u2", "perm:u3"})
+	require.NoError(t, err)
+	assert.Len(t, values, 2)
+	assert.JSONEq(t, `{"system/view":true}`, string(values["perm:u1"]))
+
+	now = now.Add(2 * time.Minute)
+	values, err = c.GetMany([]string{"perm:u1", "perm:u2"})
+	require.NoError(t, err)
+	assert.NotContains(t, values, "perm:u1")
+	assert.Contains(t, values, "perm:u2")
+}
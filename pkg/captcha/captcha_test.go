@@ -0,0 +1,65 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/config"
+)
+
+func TestSiteVerifier_Verify(t *testing.T) {
+	var gotForm map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		gotForm = r.PostForm
+		if r.PostForm.Get("response") == "good" {
+			_, _ = w.Write([]byte(`{"success": true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+	}))
+	defer srv.Close()
+
+	v := NewSiteVerifier(srv.URL, "secret")
+	if err := v.Verify(context.Background(), "good", "203.0.113.7"); err != nil {
+		t.Fatalf("Verify(good) err = %v", err)
+	}
+	if gotForm["secret"][0] != "secret" || gotForm["remoteip"][0] != "203.0.113.7" {
+		t.Errorf("form = %v", gotForm)
+	}
+	err := v.Verify(context.Background(), "bad", "")
+	if !errors.Is(err, ErrRejected) {
+		t.Errorf("Verify(bad) err = %v, want ErrRejected", err)
+	}
+}
+
+func TestSiteVerifier_Unavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	err := NewSiteVerifier(srv.URL, "secret").Verify(context.Background(), "good", "")
+	if err == nil || errors.Is(err, ErrRejected) {
+		t.Errorf("Verify() err = %v, want an error other than ErrRejected", err)
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	var cfg config.AppConfig
+	cfg.Captcha.Provider = ProviderTurnstile
+	if _, err := NewVerifier(&cfg); err == nil {
+		t.Error("turnstile without a secret key: want an error")
+	}
+	cfg.Captcha.SecretKey = "secret"
+	if v, err := NewVerifier(&cfg); err != nil || v.(*SiteVerifier).url != verifyURLs[ProviderTurnstile] {
+		t.Errorf("NewVerifier() = %v, %v", v, err)
+	}
+	cfg.Captcha.Provider = "other"
+	if _, err := NewVerifier(&cfg); err == nil {
+		t.Error("unknown provider: want an error")
+	}
+}
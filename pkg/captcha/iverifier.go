@@ -0,0 +1,9 @@
+package captcha
+
+import "context"
+
+// IVerifier checks the token a CAPTCHA widget gave the client. remoteIP is the client's IP, passed on to
+// the provider as a hint; it may be empty.
+type IVerifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
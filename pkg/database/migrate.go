@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"gorm.io/gorm"
+)
+
+// MigrationsDir is where the migration files live in the source tree, for creating new ones.
+const MigrationsDir = "pkg/database/migrations"
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// NewMigrator returns a goose provider over the embedded SQL migrations. Concurrent runs, such as several
+// replicas starting at once, wait for each other on a Postgres advisory lock. Do not close the provider:
+// that would close the connection pool it shares with db.
+func NewMigrator(db *gorm.DB) (*goose.Provider, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := fs.Sub(embeddedMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectPostgres, sqlDB, migrations,
+		goose.WithSessionLocker(locker),
+		goose.WithDisableGlobalRegistry(true),
+	)
+}
+
+// Migrate applies the pending migrations.
+func Migrate(ctx context.Context, db *gorm.DB, logger logger.ILogger) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	results, err := migrator.Up(ctx)
+	if err != nil {
+		logger.Error("Failed to migrate database", "error", err)
+		return err
+	}
+	for _, result := range results {
+		logger.Info("Applied database migration", "version", result.Source.Version, "file", result.Source.Path, "duration", result.Duration)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestMigrations_AreSequential(t *testing.T) {
+	// The pool connects lazily, so listing the migrations needs no server.
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=test dbname=test sslmode=disable"), &gorm.Config{DisableAutomaticPing: true})
+	require.NoError(t, err)
+
+	migrator, err := NewMigrator(db)
+	require.NoError(t, err)
+	sources := migrator.ListSources()
+	require.NotEmpty(t, sources)
+	for i, source := range sources {
+		assert.Equal(t, int64(i+1), source.Version, source.Path)
+	}
+}
+
+func TestMigrations_AreReversible(t *testing.T) {
+	files, err := fs.Glob(embeddedMigrations, "migrations/*.sql")
+	require.NoError(t, err)
+	for _, file := range files {
+		data, err := embeddedMigrations.ReadFile(file)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "-- +goose Up", file)
+		assert.Contains(t, string(data), "-- +goose Down", file)
+	}
+}
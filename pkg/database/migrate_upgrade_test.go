@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The models as the last release before versioned migrations had them, for building the schema its
+// AutoMigrate created.
+type legacyBase struct {
+	ID        string         `gorm:"primaryKey;type:varchar(36)"`
+	Metadata  datatypes.JSON `gorm:"type:jsonb"`
+	CreatedAt time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	CreatedBy string         `gorm:"type:varchar(36)"`
+	UpdatedBy string         `gorm:"type:varchar(36)"`
+}
+
+type legacyUser struct {
+	legacyBase
+	Username    string    `gorm:"type:varchar(255);not null;unique"`
+	Email       string    `gorm:"type:varchar(255);not null;unique"`
+	Password    string    `gorm:"type:varchar(255);not null"`
+	Status      string    `gorm:"type:varchar(50);default:active"`
+	AuthType    string    `gorm:"type:varchar(50);default:email"`
+	AuthTypeID  string    `gorm:"type:varchar(100);"`
+	LastLoginAt time.Time `gorm:"type:timestamp;default:null"`
+}
+
+func (legacyUser) TableName() string { return "users" }
+
+type legacySuperAdmin struct {
+	legacyBase
+	Name     string `gorm:"type:varchar(255);not null"`
+	Email    string `gorm:"type:varchar(255);not null;unique"`
+	Password string `gorm:"type:varchar(255);not null"`
+	IsActive bool   `gorm:"type:boolean;default:false"`
+}
+
+func (legacySuperAdmin) TableName() string { return "super_admins" }
+
+type legacyProject struct {
+	legacyBase
+	Code        string `gorm:"type:varchar(255);not null;unique"`
+	Name        string `gorm:"type:varchar(255);not null"`
+	Description string `gorm:"type:text"`
+}
+
+func (legacyProject) TableName() string { return "projects" }
+
+type legacySession struct {
+	legacyBase
+	UserID       string    `gorm:"type:varchar(36);not null"`
+	Email        string    `gorm:"type:varchar(255);default:null"`
+	RefreshToken string    `gorm:"type:varchar(255);not null"`
+	ExpiresAt    time.Time `gorm:"type:timestamp;not null"`
+	IsActive     bool      `gorm:"type:boolean;default:true"`
+	IsSuperAdmin bool      `gorm:"type:boolean;default:false"`
+}
+
+func (legacySession) TableName() string { return "sessions" }
+
+type legacyRelationTuple struct {
+	legacyBase
+	Namespace        string     `gorm:"type:varchar(255);not null;index:idx_object"`
+	ObjectID         string     `gorm:"type:varchar(255);not null;index:idx_object"`
+	Relation         string     `gorm:"type:varchar(255);not null;index:idx_relation"`
+	SubjectNamespace string     `gorm:"type:varchar(255);not null;index:idx_subject"`
+	SubjectObjectID  string     `gorm:"type:varchar(255);not null;index:idx_subject"`
+	SubjectRelation  string     `gorm:"type:varchar(255);index:idx_subject"`
+	IsActive         bool       `gorm:"type:boolean;default:true;index"`
+	ExpiresAt        *time.Time `gorm:"index"`
+}
+
+func (legacyRelationTuple) TableName() string { return "relation_tuples" }
+
+type legacyRole struct {
+	legacyBase
+	Code        string         `gorm:"type:varchar(255);not null;unique"`
+	Name        string         `gorm:"type:varchar(255);not null"`
+	Description string         `gorm:"type:text"`
+	IsActive    bool           `gorm:"type:boolean;default:true"`
+	ProjectID   *string        `gorm:"type:varchar(36)"`
+	Permissions datatypes.JSON `gorm:"type:jsonb"`
+}
+
+func (legacyRole) TableName() string { return "roles" }
+
+type legacyUserRole struct {
+	legacyBase
+	UserID    string  `gorm:"type:varchar(36);not null"`
+	RoleID    string  `gorm:"type:varchar(36);not null"`
+	ProjectID *string `gorm:"type:varchar(36)"`
+
+	User legacyUser `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Role legacyRole `gorm:"foreignKey:RoleID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (legacyUserRole) TableName() string { return "user_roles" }
+
+// openTestSchema connects to the Postgres server in POSTGRES_TEST_DSN, or a local one, inside a new empty
+// schema that is dropped after the test. The test is skipped when no server answers.
+func openTestSchema(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		dsn = "host=localhost port=5432 user=postgres password=postgres dbname=postgres sslmode=disable"
+	}
+	quiet := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	admin, err := gorm.Open(postgres.Open(dsn), quiet)
+	if err != nil {
+		t.Skip("Postgres not available, skipping test")
+	}
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	require.NoError(t, admin.Exec(`CREATE SCHEMA "`+schema+`"`).Error)
+	t.Cleanup(func() {
+		admin.Exec(`DROP SCHEMA "` + schema + `" CASCADE`)
+		if sqlDB, err := admin.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schema), quiet)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestMigrations_UpgradeAutoMigrateSchema(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+	db := openTestSchema(t)
+	ctx := context.Background()
+	require.NoError(t, db.AutoMigrate(&legacyUser{}, &legacySuperAdmin{}, &legacyProject{}, &legacySession{},
+		&legacyRelationTuple{}, &legacyRole{}, &legacyUserRole{}))
+
+	rawToken := strings.Repeat("r", 43)
+	require.NoError(t, db.Create(&legacyUser{legacyBase: legacyBase{ID: "u-google"}, Username: "gina", Email: "gina@example.com", AuthType: "GOOGLE", AuthTypeID: "g-1"}).Error)
+	require.NoError(t, db.Create(&legacyUser{legacyBase: legacyBase{ID: "u-email"}, Username: "emil", Email: "emil@example.com", AuthType: "email"}).Error)
+	require.NoError(t, db.Create(&legacySession{legacyBase: legacyBase{ID: "s-1"}, UserID: "u-google", RefreshToken: rawToken, ExpiresAt: time.Now().Add(time.Hour)}).Error)
+
+	migrator, err := NewMigrator(db)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	var stored string
+	require.NoError(t, db.Raw(`SELECT refresh_token FROM sessions WHERE id = 's-1'`).Scan(&stored).Error)
+	digest := sha256.Sum256([]byte(rawToken))
+	assert.Equal(t, hex.EncodeToString(digest[:]), stored)
+
+	type identity struct {
+		UserID, AuthType, ProviderUserID, Email string
+	}
+	var identities []identity
+	require.NoError(t, db.Raw(`SELECT user_id, auth_type, provider_user_id, email FROM user_identities`).Scan(&identities).Error)
+	assert.Equal(t, []identity{{UserID: "u-google", AuthType: "GOOGLE", ProviderUserID: "g-1", Email: "gina@example.com"}}, identities)
+
+	for _, column := range []struct{ table, name string }{
+		{"users", "phone"}, {"users", "attributes"}, {"users", "erased_at"}, {"users", "password_pepper_version"},
+		{"projects", "archived_at"}, {"projects", "attribute_schema"}, {"sessions", "mfa_verified"},
+		{"sessions", "device_id"}, {"relation_tuples", "condition"}, {"user_roles", "expires_at"},
+	} {
+		assert.True(t, db.Migrator().HasColumn(column.table, column.name), "%s.%s", column.table, column.name)
+	}
+	assert.False(t, db.Migrator().HasColumn("users", "auth_type_id"))
+	assert.True(t, db.Migrator().HasIndex("users", "idx_users_phone"))
+
+	// The users kept the column default "active", which is stored in upper case from 00016 on.
+	var statuses []string
+	require.NoError(t, db.Raw(`SELECT DISTINCT status FROM users`).Scan(&statuses).Error)
+	assert.Equal(t, []string{"ACTIVE"}, statuses)
+
+	// A second run finds nothing to do, and the migrations roll back cleanly.
+	results, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	_, err = migrator.DownTo(ctx, 0)
+	require.NoError(t, err)
+}
+
+func TestMigrations_FreshSchema(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+	db := openTestSchema(t)
+	migrator, err := NewMigrator(db)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	assert.True(t, db.Migrator().HasIndex("user_roles", "idx_user_roles_expires_at"))
+	assert.False(t, db.Migrator().HasColumn("users", "auth_type"))
+}
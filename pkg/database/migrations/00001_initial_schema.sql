@@ -0,0 +1,476 @@
+-- +goose Up
+-- The schema as GORM's AutoMigrate created it before versioned migrations. Everything is created only if
+-- missing, so a database AutoMigrate set up adopts this version unchanged. The tables of older releases
+-- lack columns added since, so the indexes on those columns are left to 00015, which adds them.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "username" varchar(255) NOT NULL,
+    "email" varchar(255) NOT NULL,
+    "phone" varchar(20) NOT NULL DEFAULT '',
+    "password" varchar(255) NOT NULL,
+    "status" varchar(50) DEFAULT 'active',
+    "last_login_at" timestamp DEFAULT null,
+    "attributes" JSONB,
+    "password_reset_required" boolean NOT NULL DEFAULT false,
+    "erased_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_users_username" UNIQUE ("username"),
+    CONSTRAINT "uni_users_email" UNIQUE ("email")
+);
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "super_admins" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "name" varchar(255) NOT NULL,
+    "email" varchar(255) NOT NULL,
+    "password" varchar(255) NOT NULL,
+    "is_active" boolean DEFAULT false,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_super_admins_email" UNIQUE ("email")
+);
+CREATE INDEX IF NOT EXISTS "idx_super_admins_deleted_at" ON "super_admins" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "projects" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "code" varchar(255) NOT NULL,
+    "name" varchar(255) NOT NULL,
+    "description" text,
+    "redirect_urls" JSONB,
+    "archived_at" timestamptz,
+    "attribute_schema" JSONB,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_projects_code" UNIQUE ("code")
+);
+CREATE INDEX IF NOT EXISTS "idx_projects_deleted_at" ON "projects" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "sessions" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "user_id" varchar(36) NOT NULL,
+    "email" varchar(255) DEFAULT null,
+    "refresh_token" varchar(255) NOT NULL,
+    "expires_at" timestamp NOT NULL,
+    "is_active" boolean DEFAULT true,
+    "is_super_admin" boolean DEFAULT false,
+    "project_id" varchar(36) DEFAULT null,
+    "mfa_verified" boolean DEFAULT false,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_sessions_refresh_token_hash" ON "sessions" ("refresh_token");
+CREATE INDEX IF NOT EXISTS "idx_sessions_deleted_at" ON "sessions" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "relation_tuples" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "namespace" varchar(255) NOT NULL,
+    "object_id" varchar(255) NOT NULL,
+    "relation" varchar(255) NOT NULL,
+    "subject_namespace" varchar(255) NOT NULL,
+    "subject_object_id" varchar(255) NOT NULL,
+    "subject_relation" varchar(255),
+    "condition" text,
+    "is_active" boolean DEFAULT true,
+    "expires_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_relation_tuples_expires_at" ON "relation_tuples" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_relation_tuples_is_active" ON "relation_tuples" ("is_active");
+CREATE INDEX IF NOT EXISTS "idx_subject" ON "relation_tuples" ("subject_namespace","subject_object_id","subject_relation");
+CREATE INDEX IF NOT EXISTS "idx_relation" ON "relation_tuples" ("relation");
+CREATE INDEX IF NOT EXISTS "idx_object" ON "relation_tuples" ("namespace","object_id");
+CREATE INDEX IF NOT EXISTS "idx_relation_tuples_deleted_at" ON "relation_tuples" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "relation_namespaces" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "name" varchar(255) NOT NULL,
+    "config" JSONB,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_relation_namespaces_name" UNIQUE ("name")
+);
+CREATE INDEX IF NOT EXISTS "idx_relation_namespaces_deleted_at" ON "relation_namespaces" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "roles" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "code" varchar(255) NOT NULL,
+    "name" varchar(255) NOT NULL,
+    "description" text,
+    "is_active" boolean DEFAULT true,
+    "project_id" varchar(36),
+    "permissions" JSONB,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_roles_code" UNIQUE ("code")
+);
+CREATE INDEX IF NOT EXISTS "idx_roles_deleted_at" ON "roles" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "permissions" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "project_id" varchar(36),
+    "code" varchar(255) NOT NULL,
+    "name" varchar(255) NOT NULL,
+    "description" text,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_permissions_project_code" ON "permissions" ("project_id","code");
+CREATE INDEX IF NOT EXISTS "idx_permissions_deleted_at" ON "permissions" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "user_roles" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "user_id" varchar(36) NOT NULL,
+    "role_id" varchar(36) NOT NULL,
+    "project_id" varchar(36),
+    "expires_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_user_roles_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_user_roles_role" FOREIGN KEY ("role_id") REFERENCES "roles"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_user_roles_deleted_at" ON "user_roles" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "user_credentials" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "user_id" varchar(36) NOT NULL,
+    "type" varchar(20) NOT NULL,
+    "name" varchar(255) NOT NULL,
+    "external_id" varchar(255) DEFAULT null,
+    "data" JSONB,
+    "last_used_at" timestamp,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_user_credentials_user_id" ON "user_credentials" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_user_credentials_deleted_at" ON "user_credentials" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "access_policies" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "project_id" varchar(36) NOT NULL,
+    "allowed_cidrs" JSONB,
+    "blocked_countries" JSONB,
+    "corporate_cidrs" JSONB,
+    "require_mfa_outside_network" boolean DEFAULT false,
+    "is_active" boolean NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_access_policies_project_id" UNIQUE ("project_id")
+);
+CREATE INDEX IF NOT EXISTS "idx_access_policies_deleted_at" ON "access_policies" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "access_denials" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "project_id" varchar(36) NOT NULL,
+    "user_id" varchar(36),
+    "email" varchar(255),
+    "stage" varchar(20) NOT NULL,
+    "reason" varchar(50) NOT NULL,
+    "ip" varchar(45),
+    "country" varchar(2),
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_access_denials_project_id" ON "access_denials" ("project_id");
+CREATE INDEX IF NOT EXISTS "idx_access_denials_deleted_at" ON "access_denials" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "break_glass_credentials" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "label" varchar(100) NOT NULL,
+    "secret_hash" varchar(255) NOT NULL,
+    "used_at" timestamp,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_break_glass_credentials_label" UNIQUE ("label")
+);
+CREATE INDEX IF NOT EXISTS "idx_break_glass_credentials_deleted_at" ON "break_glass_credentials" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "break_glass_activations" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "credential_id" varchar(36),
+    "label" varchar(100) NOT NULL,
+    "operator" varchar(255) NOT NULL,
+    "justification" text NOT NULL,
+    "outcome" varchar(20) NOT NULL,
+    "reason" varchar(255),
+    "session_id" varchar(36),
+    "expires_at" timestamp,
+    "alert_delivered" boolean NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_break_glass_activations_deleted_at" ON "break_glass_activations" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "trusted_devices" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "user_id" varchar(36) NOT NULL,
+    "token_hash" varchar(64) NOT NULL,
+    "name" varchar(255),
+    "ip" varchar(45),
+    "expires_at" timestamp NOT NULL,
+    "last_used_at" timestamp,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_trusted_devices_token_hash" UNIQUE ("token_hash")
+);
+CREATE INDEX IF NOT EXISTS "idx_trusted_devices_user_id" ON "trusted_devices" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_trusted_devices_deleted_at" ON "trusted_devices" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "saml_connections" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "project_id" varchar(36) NOT NULL,
+    "idp_metadata_xml" text NOT NULL,
+    "idp_entity_id" varchar(512) NOT NULL,
+    "email_attribute" varchar(255),
+    "name_attribute" varchar(255),
+    "groups_attribute" varchar(255),
+    "allowed_domains" JSONB,
+    "is_active" boolean NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_saml_connections_project_id" UNIQUE ("project_id")
+);
+CREATE INDEX IF NOT EXISTS "idx_saml_connections_deleted_at" ON "saml_connections" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "signing_keys" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "kid" varchar(64) NOT NULL,
+    "public_key" text NOT NULL,
+    "private_key" text NOT NULL,
+    "activates_at" timestamp NOT NULL,
+    "retired_at" timestamp,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_signing_keys_kid" UNIQUE ("kid")
+);
+CREATE INDEX IF NOT EXISTS "idx_signing_keys_deleted_at" ON "signing_keys" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "revoked_tokens" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "jti" varchar(64) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "expires_at" timestamp NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_revoked_tokens_jti" UNIQUE ("jti")
+);
+CREATE INDEX IF NOT EXISTS "idx_revoked_tokens_expires_at" ON "revoked_tokens" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_revoked_tokens_user_id" ON "revoked_tokens" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_revoked_tokens_deleted_at" ON "revoked_tokens" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "project_id" varchar(36) NOT NULL,
+    "name" varchar(255) NOT NULL,
+    "prefix" varchar(32) NOT NULL,
+    "secret_hash" varchar(64) NOT NULL,
+    "scopes" JSONB,
+    "expires_at" timestamp,
+    "last_used_at" timestamp,
+    "revoked_at" timestamp,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_api_keys_prefix" UNIQUE ("prefix")
+);
+CREATE INDEX IF NOT EXISTS "idx_api_keys_project_id" ON "api_keys" ("project_id");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_deleted_at" ON "api_keys" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "project_members" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "project_id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "status" varchar(20) NOT NULL,
+    "invited_by" varchar(36),
+    "accepted_at" timestamp,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_project_members_user_id" ON "project_members" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_project_members_project_user" ON "project_members" ("project_id","user_id");
+CREATE INDEX IF NOT EXISTS "idx_project_members_deleted_at" ON "project_members" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "user_identities" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "user_id" varchar(36) NOT NULL,
+    "auth_type" varchar(50) NOT NULL,
+    "provider" varchar(255) NOT NULL DEFAULT '',
+    "provider_user_id" varchar(255) NOT NULL,
+    "email" varchar(255),
+    "last_used_at" timestamp,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_identities_subject" ON "user_identities" ("auth_type","provider","provider_user_id");
+CREATE INDEX IF NOT EXISTS "idx_user_identities_user_id" ON "user_identities" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_user_identities_deleted_at" ON "user_identities" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "service_accounts" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "project_id" varchar(36) NOT NULL,
+    "name" varchar(255) NOT NULL,
+    "client_id" varchar(64) NOT NULL,
+    "client_secret_hash" varchar(64) NOT NULL,
+    "permissions" JSONB,
+    "last_token_at" timestamp,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_service_accounts_client_id" UNIQUE ("client_id")
+);
+CREATE INDEX IF NOT EXISTS "idx_service_accounts_project_id" ON "service_accounts" ("project_id");
+CREATE INDEX IF NOT EXISTS "idx_service_accounts_deleted_at" ON "service_accounts" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "scim_users" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "project_id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "external_id" varchar(255),
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_scim_users_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "uni_scim_users_user_id" UNIQUE ("user_id")
+);
+CREATE INDEX IF NOT EXISTS "idx_scim_users_external_id" ON "scim_users" ("external_id");
+CREATE INDEX IF NOT EXISTS "idx_scim_users_project_id" ON "scim_users" ("project_id");
+CREATE INDEX IF NOT EXISTS "idx_scim_users_deleted_at" ON "scim_users" ("deleted_at");
+
+-- +goose Down
+DROP TABLE IF EXISTS "scim_users";
+DROP TABLE IF EXISTS "service_accounts";
+DROP TABLE IF EXISTS "user_identities";
+DROP TABLE IF EXISTS "project_members";
+DROP TABLE IF EXISTS "api_keys";
+DROP TABLE IF EXISTS "revoked_tokens";
+DROP TABLE IF EXISTS "signing_keys";
+DROP TABLE IF EXISTS "saml_connections";
+DROP TABLE IF EXISTS "trusted_devices";
+DROP TABLE IF EXISTS "break_glass_activations";
+DROP TABLE IF EXISTS "break_glass_credentials";
+DROP TABLE IF EXISTS "access_denials";
+DROP TABLE IF EXISTS "access_policies";
+DROP TABLE IF EXISTS "user_credentials";
+DROP TABLE IF EXISTS "user_roles";
+DROP TABLE IF EXISTS "permissions";
+DROP TABLE IF EXISTS "roles";
+DROP TABLE IF EXISTS "relation_namespaces";
+DROP TABLE IF EXISTS "relation_tuples";
+DROP TABLE IF EXISTS "sessions";
+DROP TABLE IF EXISTS "projects";
+DROP TABLE IF EXISTS "super_admins";
+DROP TABLE IF EXISTS "users";
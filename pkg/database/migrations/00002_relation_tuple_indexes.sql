@@ -0,0 +1,16 @@
+-- +goose NO TRANSACTION
+-- The indexes are built concurrently so writes to a large tuple table are not blocked, which rules out a transaction.
+
+-- +goose Up
+-- Checks, userset lookups and grants match on the object and relation, then the subject.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_relation_tuples_check"
+    ON "relation_tuples" ("namespace", "object_id", "relation", "subject_namespace", "subject_object_id", "subject_relation")
+    WHERE deleted_at IS NULL;
+-- Expanding a relation and listing tuples by relation match on the namespace and relation.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_relation_tuples_namespace_relation"
+    ON "relation_tuples" ("namespace", "relation")
+    WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS "idx_relation_tuples_namespace_relation";
+DROP INDEX CONCURRENTLY IF EXISTS "idx_relation_tuples_check";
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS "role_history" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "role_id" varchar(36) NOT NULL,
+    "project_id" varchar(36),
+    "action" varchar(20) NOT NULL,
+    "actor_id" varchar(36),
+    "actor_type" varchar(20),
+    "user_id" varchar(36),
+    "before" JSONB,
+    "after" JSONB,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_role_history_role_id" ON "role_history" ("role_id");
+CREATE INDEX IF NOT EXISTS "idx_role_history_user_id" ON "role_history" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_role_history_deleted_at" ON "role_history" ("deleted_at");
+
+-- +goose Down
+DROP TABLE IF EXISTS "role_history";
@@ -0,0 +1,24 @@
+-- +goose NO TRANSACTION
+-- The indexes are built concurrently so writes to large tables are not blocked, which rules out a transaction.
+
+-- +goose Up
+-- Listings are ordered newest first by (created_at, id) and cursor pages seek past the last row of the previous one.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_users_created_at_id"
+    ON "users" ("created_at", "id")
+    WHERE deleted_at IS NULL;
+CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_roles_created_at_id"
+    ON "roles" ("created_at", "id")
+    WHERE deleted_at IS NULL;
+CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_relation_tuples_created_at_id"
+    ON "relation_tuples" ("created_at", "id")
+    WHERE deleted_at IS NULL;
+-- Session listings are always for one user.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_sessions_user_id_created_at_id"
+    ON "sessions" ("user_id", "created_at", "id")
+    WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS "idx_sessions_user_id_created_at_id";
+DROP INDEX CONCURRENTLY IF EXISTS "idx_relation_tuples_created_at_id";
+DROP INDEX CONCURRENTLY IF EXISTS "idx_roles_created_at_id";
+DROP INDEX CONCURRENTLY IF EXISTS "idx_users_created_at_id";
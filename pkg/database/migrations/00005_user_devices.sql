@@ -0,0 +1,33 @@
+-- +goose NO TRANSACTION
+-- The sessions index is built concurrently so logins are not blocked, which rules out a transaction.
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS "user_devices" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "user_id" varchar(36) NOT NULL,
+    "fingerprint" varchar(64) NOT NULL,
+    "name" varchar(100),
+    "platform" varchar(64),
+    "user_agent" text,
+    "last_ip" varchar(45),
+    "last_country" varchar(8),
+    "last_seen_at" timestamp NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_devices_user_fingerprint" ON "user_devices" ("user_id", "fingerprint");
+CREATE INDEX IF NOT EXISTS "idx_user_devices_deleted_at" ON "user_devices" ("deleted_at");
+
+-- Existing sessions have no device; new ones point to the device they were issued to.
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS "device_id" varchar(36) DEFAULT null;
+CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_sessions_device_id" ON "sessions" ("device_id");
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS "idx_sessions_device_id";
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS "device_id";
+DROP TABLE IF EXISTS "user_devices";
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS "impersonations" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "impersonator_id" varchar(36) NOT NULL,
+    "user_id" varchar(36) NOT NULL,
+    "project_id" varchar(36),
+    "session_id" varchar(36) NOT NULL,
+    "reason" text NOT NULL,
+    "expires_at" timestamp NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_impersonations_user_id" ON "impersonations" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_impersonations_deleted_at" ON "impersonations" ("deleted_at");
+
+-- +goose Down
+DROP TABLE IF EXISTS "impersonations";
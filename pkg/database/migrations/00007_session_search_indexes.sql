@@ -0,0 +1,16 @@
+-- +goose NO TRANSACTION
+-- The indexes are built concurrently so logins are not blocked, which rules out a transaction.
+
+-- +goose Up
+-- Session search across users and the sign-in stats scan sessions by when they were issued.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_sessions_created_at_id"
+    ON "sessions" ("created_at", "id")
+    WHERE deleted_at IS NULL;
+-- Denied logins are counted per stage over a time range.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_access_denials_stage_created_at"
+    ON "access_denials" ("stage", "created_at")
+    WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS "idx_access_denials_stage_created_at";
+DROP INDEX CONCURRENTLY IF EXISTS "idx_sessions_created_at_id";
@@ -0,0 +1,6 @@
+-- +goose Up
+-- The sender of the email sent for a project; empty falls back to MAIL_FROM.
+ALTER TABLE "projects" ADD COLUMN IF NOT EXISTS "mail_from" varchar(255);
+
+-- +goose Down
+ALTER TABLE "projects" DROP COLUMN IF EXISTS "mail_from";
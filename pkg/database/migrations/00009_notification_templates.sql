@@ -0,0 +1,24 @@
+-- +goose Up
+-- Project replacements for the built-in emails and SMS, per locale; an empty locale applies to all.
+CREATE TABLE IF NOT EXISTS "notification_templates" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "project_id" varchar(36) NOT NULL,
+    "channel" varchar(10) NOT NULL,
+    "name" varchar(50) NOT NULL,
+    "locale" varchar(35) NOT NULL DEFAULT '',
+    "subject" varchar(255),
+    "text" text NOT NULL,
+    "html" text,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_notification_templates_key" ON "notification_templates" ("project_id", "channel", "name", "locale");
+CREATE INDEX IF NOT EXISTS "idx_notification_templates_deleted_at" ON "notification_templates" ("deleted_at");
+
+-- +goose Down
+DROP TABLE IF EXISTS "notification_templates";
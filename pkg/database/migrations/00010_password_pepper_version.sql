@@ -0,0 +1,8 @@
+-- +goose Up
+-- The PASSWORD_PEPPERS version each password was hashed with; existing hashes have no pepper.
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "password_pepper_version" integer NOT NULL DEFAULT 0;
+ALTER TABLE "super_admins" ADD COLUMN IF NOT EXISTS "password_pepper_version" integer NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE "super_admins" DROP COLUMN IF EXISTS "password_pepper_version";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "password_pepper_version";
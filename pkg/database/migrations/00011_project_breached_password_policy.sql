@@ -0,0 +1,6 @@
+-- +goose Up
+-- What happens to new passwords found in a known breach for the project; empty inherits PASSWORD_BREACH_POLICY.
+ALTER TABLE "projects" ADD COLUMN IF NOT EXISTS "breached_password_policy" varchar(16);
+
+-- +goose Down
+ALTER TABLE "projects" DROP COLUMN IF EXISTS "breached_password_policy";
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Network ranges denied access to the whole API; managed through /ip-blocks.
+CREATE TABLE IF NOT EXISTS "ip_blocks" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "cidr" varchar(43) NOT NULL,
+    "reason" text,
+    "expires_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_ip_blocks_cidr" ON "ip_blocks" ("cidr");
+CREATE INDEX IF NOT EXISTS "idx_ip_blocks_deleted_at" ON "ip_blocks" ("deleted_at");
+
+-- +goose Down
+DROP TABLE IF EXISTS "ip_blocks";
@@ -0,0 +1,19 @@
+-- +goose NO TRANSACTION
+-- The indexes are built and dropped concurrently so writes to a large tuple table are not blocked, which rules
+-- out a transaction.
+
+-- +goose Up
+-- Relation checks match the object, relation and subject of active tuples, then test the expiry. Covering the
+-- expiry and subject relation lets them run as an index-only scan on this one index, instead of merging the
+-- per-column indexes. The queries must spell the predicate as `is_active`, not a bound parameter, for the
+-- planner to use it.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_relation_tuples_active_check"
+    ON "relation_tuples" ("namespace", "object_id", "relation", "subject_namespace", "subject_object_id")
+    INCLUDE ("expires_at", "subject_relation")
+    WHERE is_active AND deleted_at IS NULL;
+-- Nearly every tuple is active, so the boolean index never narrows a query and only tempts bitmap merges.
+DROP INDEX CONCURRENTLY IF EXISTS "idx_relation_tuples_is_active";
+
+-- +goose Down
+CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_relation_tuples_is_active" ON "relation_tuples" ("is_active");
+DROP INDEX CONCURRENTLY IF EXISTS "idx_relation_tuples_active_check";
@@ -0,0 +1,30 @@
+-- +goose Up
+-- Grants precomputed from role assignments and relation tuples when EFFECTIVE_ACCESS_MODE is maintain or
+-- serve; `dreonctl effective-access rebuild` recreates them.
+CREATE TABLE IF NOT EXISTS "effective_access" (
+    "id" varchar(36),
+    "metadata" JSONB,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "created_by" varchar(36),
+    "updated_by" varchar(36),
+    "kind" varchar(20) NOT NULL,
+    "subject" varchar(511) NOT NULL,
+    "resource" varchar(767) NOT NULL,
+    "project_id" varchar(36),
+    "expires_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+-- A user's permissions are read, and replaced, by subject.
+CREATE INDEX IF NOT EXISTS "idx_effective_access_subject"
+    ON "effective_access" ("kind", "subject") INCLUDE ("resource", "project_id", "expires_at")
+    WHERE deleted_at IS NULL;
+-- A relation check is one lookup of the resource and subject; a relation's rows are replaced by resource.
+CREATE INDEX IF NOT EXISTS "idx_effective_access_resource"
+    ON "effective_access" ("kind", "resource", "subject") INCLUDE ("expires_at")
+    WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS "idx_effective_access_deleted_at" ON "effective_access" ("deleted_at");
+
+-- +goose Down
+DROP TABLE IF EXISTS "effective_access";
@@ -0,0 +1,58 @@
+-- +goose Up
+-- Brings tables that releases before versioned migrations created through AutoMigrate, and 00001 adopted as
+-- they were, up to the 00001 schema, then runs the data migrations those releases ran at startup. On a
+-- database 00001 created every statement changes nothing.
+
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS "phone" varchar(20) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS "attributes" JSONB,
+    ADD COLUMN IF NOT EXISTS "password_reset_required" boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS "erased_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_users_erased_at" ON "users" ("erased_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_phone" ON "users" ("phone") WHERE phone <> '';
+
+ALTER TABLE "projects"
+    ADD COLUMN IF NOT EXISTS "redirect_urls" JSONB,
+    ADD COLUMN IF NOT EXISTS "archived_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "attribute_schema" JSONB;
+CREATE INDEX IF NOT EXISTS "idx_projects_archived_at" ON "projects" ("archived_at");
+
+ALTER TABLE "sessions"
+    ADD COLUMN IF NOT EXISTS "project_id" varchar(36) DEFAULT null,
+    ADD COLUMN IF NOT EXISTS "mfa_verified" boolean DEFAULT false;
+
+ALTER TABLE "relation_tuples" ADD COLUMN IF NOT EXISTS "condition" text;
+
+ALTER TABLE "user_roles" ADD COLUMN IF NOT EXISTS "expires_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_user_roles_expires_at" ON "user_roles" ("expires_at");
+
+-- Refresh tokens are looked up by their SHA256 hex digest; older releases stored them in plaintext. Digests
+-- are 64 hex characters and raw tokens 43, so rows already hashed are left alone.
+UPDATE "sessions" SET "refresh_token" = encode(sha256(convert_to("refresh_token", 'UTF8')), 'hex')
+WHERE length("refresh_token") <> 64;
+
+-- Older releases kept a user's external login on the user row (auth_type, auth_type_id). Copy it into
+-- user_identities and drop those columns. The rows carry no provider; the first OIDC or SAML login through
+-- one claims it.
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'auth_type_id'
+    ) THEN
+        INSERT INTO "user_identities" ("id", "created_at", "updated_at", "user_id", "auth_type", "provider", "provider_user_id", "email")
+        SELECT gen_random_uuid(), now(), now(), "id", upper("auth_type"), '', "auth_type_id", "email" FROM "users"
+        WHERE "auth_type_id" <> '' AND upper("auth_type") NOT IN ('EMAIL', 'SCIM')
+        ON CONFLICT DO NOTHING;
+        ALTER TABLE "users" DROP COLUMN IF EXISTS "auth_type", DROP COLUMN "auth_type_id";
+    END IF;
+END $$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- The columns belong to 00001 and the data migrations cannot be undone, so only the indexes go.
+DROP INDEX IF EXISTS "idx_user_roles_expires_at";
+DROP INDEX IF EXISTS "idx_projects_archived_at";
+DROP INDEX IF EXISTS "idx_users_phone";
+DROP INDEX IF EXISTS "idx_users_erased_at";
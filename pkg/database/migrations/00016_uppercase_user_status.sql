@@ -0,0 +1,9 @@
+-- +goose Up
+-- Users created without an explicit status got the column default "active", while the code compares
+-- against the upper-case constants. Store every status in upper case and default new rows to ACTIVE.
+UPDATE "users" SET "status" = upper("status") WHERE "status" <> upper("status");
+UPDATE "users" SET "status" = 'ACTIVE' WHERE "status" IS NULL OR "status" = '';
+ALTER TABLE "users" ALTER COLUMN "status" SET DEFAULT 'ACTIVE';
+
+-- +goose Down
+ALTER TABLE "users" ALTER COLUMN "status" SET DEFAULT 'active';
@@ -1,16 +1,38 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	"github.com/hiamthach108/dreon-auth/config"
-	"github.com/hiamthach108/dreon-auth/internal/model"
 	"github.com/hiamthach108/dreon-auth/pkg/logger"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
+// NewDbClient connects to Postgres and, unless POSTGRES_SKIP_MIGRATIONS is set, applies the pending migrations.
 func NewDbClient(config *config.AppConfig, logger logger.ILogger) (*gorm.DB, error) {
+	db, err := Connect(config, logger)
+	if err != nil {
+		return nil, err
+	}
+	if config.Postgres.SkipMigrations {
+		logger.Info("Skipping database migrations")
+		return db, nil
+	}
+	if err := Migrate(context.Background(), db, logger); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Connect opens the connection pool without touching the schema.
+func Connect(config *config.AppConfig, logger logger.ILogger) (*gorm.DB, error) {
 	dialector := getPostgresSQLDialector(
 		config.Postgres.ConnectionName,
 		config.Postgres.Host,
@@ -25,12 +47,19 @@ func NewDbClient(config *config.AppConfig, logger logger.ILogger) (*gorm.DB, err
 		logger.Error("Failed to connect to database", "error", err)
 		return nil, err
 	}
+	if err := db.Use(tracingPlugin{}); err != nil {
+		return nil, err
+	}
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
 	}
 	sqlDB.SetMaxIdleConns(config.Postgres.MaxIdleConns)
 	sqlDB.SetMaxOpenConns(config.Postgres.MaxOpenConns)
+	if err := useReplicas(db, config); err != nil {
+		logger.Error("Failed to connect to database replicas", "error", err)
+		return nil, err
+	}
 
 	// ping to verify connection
 	if err := sqlDB.Ping(); err != nil {
@@ -38,15 +67,68 @@ func NewDbClient(config *config.AppConfig, logger logger.ILogger) (*gorm.DB, err
 		return nil, err
 	}
 
-	// Auto migrate your models here if needed
-	if err := autoMigration(db, logger); err != nil {
-		return nil, err
-	}
-
 	logger.Info("Connected to PostgreSQL database successfully")
 	return db, nil
 }
 
+// Close closes the connection pools of the primary and of every replica. Queries already running finish first.
+func Close(db *gorm.DB) error {
+	var errs []error
+	if resolver, ok := db.Config.Plugins[(&dbresolver.DBResolver{}).Name()].(*dbresolver.DBResolver); ok {
+		_ = resolver.Call(func(pool gorm.ConnPool) error {
+			if closer, ok := pool.(interface{ Close() error }); ok {
+				errs = append(errs, closer.Close())
+			}
+			return nil
+		})
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	errs = append(errs, sqlDB.Close())
+	return errors.Join(errs...)
+}
+
+// useReplicas registers the POSTGRES_REPLICA_HOSTS replicas with dbresolver, which sends each read to a
+// random one. Writes, transactions and db.DB() (and so migrations) always use the primary.
+func useReplicas(db *gorm.DB, config *config.AppConfig) error {
+	var replicas []gorm.Dialector
+	for _, entry := range strings.Split(config.Postgres.ReplicaHosts, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		connectionName, host, port := "", entry, config.Postgres.Port
+		if config.Postgres.ConnectionName != "" {
+			connectionName = entry
+		} else if h, p, err := net.SplitHostPort(entry); err == nil {
+			if port, err = strconv.Atoi(p); err != nil {
+				return fmt.Errorf("invalid replica port in %q", entry)
+			}
+			host = h
+		}
+		replicas = append(replicas, getPostgresSQLDialector(
+			connectionName,
+			host,
+			port,
+			config.Postgres.Username,
+			config.Postgres.Password,
+			config.Postgres.DBName,
+			config.Postgres.SSL,
+		))
+	}
+	if len(replicas) == 0 {
+		return nil
+	}
+	return db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}).
+		SetMaxIdleConns(config.Postgres.MaxIdleConns).
+		SetMaxOpenConns(config.Postgres.MaxOpenConns))
+}
+
 func getPostgresSQLDialector(connectionName string, host string, port int,
 	username string, password string, dbname string, ssl bool) gorm.Dialector {
 
@@ -71,22 +153,3 @@ func getPostgresSQLDialector(connectionName string, host string, port int,
 	)
 	return postgres.Open(dsn)
 }
-
-func autoMigration(db *gorm.DB, logger logger.ILogger) error {
-	logger.Info("Starting database auto migration")
-
-	if err := db.AutoMigrate(
-		&model.User{},
-		&model.SuperAdmin{},
-		&model.Project{},
-		&model.Session{},
-		&model.RelationTuple{},
-		&model.Role{},
-		&model.UserRole{},
-	); err != nil {
-		logger.Error("Failed to auto migrate database", "error", err)
-		return err
-	}
-
-	return nil
-}
@@ -0,0 +1,65 @@
+// Package eventbus publishes domain events to an optional message bus (NATS or Kafka)
+// so other services can react to auth changes asynchronously.
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+const (
+	DriverNATS  = "nats"
+	DriverKafka = "kafka"
+
+	// DefaultTopic is the NATS subject prefix and Kafka topic used when EVENT_BUS_TOPIC is unset.
+	DefaultTopic = "dreon.auth"
+)
+
+// NewEvent returns an event of eventType about subject, with a fresh ID and the current time.
+func NewEvent(eventType, subject string, data any) Event {
+	return Event{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		Subject:    subject,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+// NewPublisher returns the bus selected by AppConfig.EventBus.Driver (env: EVENT_BUS_DRIVER).
+// Without a driver, events are dropped so local setups run without a broker.
+func NewPublisher(cfg *config.AppConfig, logger logger.ILogger) (IPublisher, error) {
+	topic := cfg.EventBus.Topic
+	if topic == "" {
+		topic = DefaultTopic
+	}
+	switch cfg.EventBus.Driver {
+	case "":
+		logger.Info("EVENT_BUS_DRIVER is not set; domain events will not be published")
+		return NopPublisher{}, nil
+	case DriverNATS:
+		if cfg.EventBus.URL == "" {
+			return nil, fmt.Errorf("eventbus: nats requires EVENT_BUS_URL")
+		}
+		return NewNATSPublisher(cfg.EventBus.URL, topic, logger)
+	case DriverKafka:
+		if cfg.EventBus.URL == "" {
+			return nil, fmt.Errorf("eventbus: kafka requires EVENT_BUS_URL")
+		}
+		return NewKafkaPublisher(strings.Split(cfg.EventBus.URL, ","), topic, logger), nil
+	default:
+		return nil, fmt.Errorf("eventbus: unknown driver %q", cfg.EventBus.Driver)
+	}
+}
+
+// NopPublisher drops every event.
+type NopPublisher struct{}
+
+func (NopPublisher) Publish(ctx context.Context, event Event) error { return nil }
+func (NopPublisher) Close() error                                   { return nil }
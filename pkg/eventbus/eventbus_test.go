@@ -0,0 +1,58 @@
+package eventbus_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+	"github.com/hiamthach108/dreon-auth/pkg/eventbus"
+)
+
+func TestNewEvent_setsIDAndTime(t *testing.T) {
+	a := eventbus.NewEvent("user.created", "u1", nil)
+	b := eventbus.NewEvent("user.created", "u1", nil)
+	if a.ID == "" || a.ID == b.ID {
+		t.Errorf("event IDs = %q, %q, want unique non-empty IDs", a.ID, b.ID)
+	}
+	if a.Type != "user.created" || a.Subject != "u1" || a.OccurredAt.IsZero() {
+		t.Errorf("event = %+v", a)
+	}
+}
+
+func TestNewPublisher_noDriver_returnsNop(t *testing.T) {
+	p, err := eventbus.NewPublisher(&config.AppConfig{}, testutil.NewLogger())
+	if err != nil {
+		t.Fatalf("NewPublisher: %v", err)
+	}
+	if _, ok := p.(eventbus.NopPublisher); !ok {
+		t.Fatalf("publisher = %T, want NopPublisher", p)
+	}
+	if err := p.Publish(context.Background(), eventbus.NewEvent("user.created", "u1", nil)); err != nil {
+		t.Errorf("Publish: %v", err)
+	}
+}
+
+func TestNewPublisher_invalidConfig_returnsError(t *testing.T) {
+	for _, driver := range []string{eventbus.DriverNATS, eventbus.DriverKafka, "rabbitmq"} {
+		cfg := &config.AppConfig{}
+		cfg.EventBus.Driver = driver
+		if _, err := eventbus.NewPublisher(cfg, testutil.NewLogger()); err == nil {
+			t.Errorf("NewPublisher(driver=%q, no URL) err = nil, want error", driver)
+		}
+	}
+}
+
+func TestNewPublisher_kafka_doesNotDialUntilPublish(t *testing.T) {
+	cfg := &config.AppConfig{}
+	cfg.EventBus.Driver = eventbus.DriverKafka
+	cfg.EventBus.URL = "localhost:9092,localhost:9093"
+	p, err := eventbus.NewPublisher(cfg, testutil.NewLogger())
+	if err != nil {
+		t.Fatalf("NewPublisher: %v", err)
+	}
+	defer p.Close()
+	if _, ok := p.(*eventbus.KafkaPublisher); !ok {
+		t.Fatalf("publisher = %T, want *KafkaPublisher", p)
+	}
+}
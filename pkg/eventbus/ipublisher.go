@@ -0,0 +1,23 @@
+package eventbus
+
+import (
+	"context"
+	"time"
+)
+
+// Event is a domain event as it appears on the bus, JSON-encoded.
+type Event struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`    // e.g. "user.created"
+	Subject    string    `json:"subject"` // ID of the entity the event is about
+	OccurredAt time.Time `json:"occurredAt"`
+	Data       any       `json:"data,omitempty"`
+}
+
+// IPublisher sends domain events to a message bus. Publish does not wait for the broker to
+// acknowledge the event, so a nil error means the event was accepted for delivery, not delivered.
+type IPublisher interface {
+	Publish(ctx context.Context, event Event) error
+	// Close flushes pending events and releases the connection.
+	Close() error
+}
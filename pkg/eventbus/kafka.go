@@ -0,0 +1,49 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaPublisher writes every event to one topic, keyed by subject so events about the same
+// entity stay in order on one partition. The event type is also sent as the "type" header.
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaPublisher(brokers []string, topic string, logger logger.ILogger) *KafkaPublisher {
+	return &KafkaPublisher{writer: &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+		// Async keeps request latency independent of the brokers; failures are only logged.
+		Async: true,
+		Completion: func(messages []kafka.Message, err error) {
+			if err != nil {
+				logger.Error("Failed to deliver events to Kafka", "count", len(messages), "error", err)
+			}
+		},
+	}}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("eventbus: encode event: %w", err)
+	}
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:     []byte(event.Subject),
+		Value:   data,
+		Headers: []kafka.Header{{Key: "type", Value: []byte(event.Type)}},
+	})
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
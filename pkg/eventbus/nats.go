@@ -0,0 +1,47 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/nats-io/nats.go"
+)
+
+// NATSPublisher publishes each event to "<prefix>.<event type>", e.g. "dreon.auth.user.created",
+// so consumers can subscribe to one type or to all of them with "dreon.auth.>".
+type NATSPublisher struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+func NewNATSPublisher(url, prefix string, logger logger.ILogger) (*NATSPublisher, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("dreon-auth"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			logger.Warn("Disconnected from NATS", "error", err)
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			logger.Info("Reconnected to NATS", "url", c.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("eventbus: connect to nats: %w", err)
+	}
+	return &NATSPublisher{conn: conn, prefix: prefix}, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("eventbus: encode event: %w", err)
+	}
+	// Publish only buffers; while disconnected the client keeps events in its reconnect buffer.
+	return p.conn.Publish(p.prefix+"."+event.Type, data)
+}
+
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,11 @@
+package jwt
+
+import "context"
+
+// IClaimsEnricher adds deployment-specific claims, such as a tenant ID, plan or feature flags, to the access
+// tokens of user sessions. It is given the payload about to be signed and returns the claims to merge into
+// its Custom map; on conflicting keys the enricher registered last wins. An error fails the sign-in or
+// refresh, so return no claims rather than an error when they are optional.
+type IClaimsEnricher interface {
+	EnrichClaims(ctx context.Context, payload Payload) (map[string]any, error)
+}
@@ -0,0 +1,114 @@
+package jwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// JWE algorithms of encrypted access tokens: the content key is wrapped with RSA-OAEP and the signed
+// JWT is encrypted with AES-256-GCM (RFC 7516, RFC 7518).
+const (
+	KeyEncryptionAlg     = "RSA-OAEP"
+	ContentEncryptionAlg = "A256GCM"
+)
+
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Cty string `json:"cty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// IsEncrypted reports whether token is in JWE compact serialization, five dot-separated parts, rather than
+// a signed JWT's three.
+func IsEncrypted(token string) bool {
+	return strings.Count(token, ".") == 4
+}
+
+// Encrypt nests the signed JWT token in a JWE for pub, with kid naming the key that decrypts it.
+func Encrypt(token string, pub *rsa.PublicKey, kid string) (string, error) {
+	header, err := json.Marshal(jweHeader{Alg: KeyEncryptionAlg, Enc: ContentEncryptionAlg, Cty: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+	cek := make([]byte, 32)
+	if _, err := rand.Read(cek); err != nil {
+		return "", err
+	}
+	// RSA-OAEP is defined with SHA-1 and MGF1-SHA-1; RSA-OAEP-256 would use SHA-256.
+	encryptedKey, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, cek, nil)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+	sealed := gcm.Seal(nil, iv, []byte(token), []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return strings.Join([]string{
+		protected,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, "."), nil
+}
+
+// Decrypt returns the signed JWT nested in token, decrypting it with the private key key returns for
+// the JWE's kid. The JWT's signature is not checked.
+func Decrypt(token string, key func(kid string) (*rsa.PrivateKey, bool)) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return "", ErrInvalidToken
+	}
+	var raw [5][]byte
+	for i, part := range parts {
+		decoded, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return "", ErrInvalidToken
+		}
+		raw[i] = decoded
+	}
+	var header jweHeader
+	if err := json.Unmarshal(raw[0], &header); err != nil || header.Alg != KeyEncryptionAlg || header.Enc != ContentEncryptionAlg {
+		return "", ErrInvalidToken
+	}
+	priv, ok := key(header.Kid)
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	cek, err := rsa.DecryptOAEP(sha1.New(), nil, priv, raw[1], nil)
+	if err != nil || len(cek) != 32 {
+		return "", ErrInvalidToken
+	}
+	gcm, err := newGCM(cek)
+	if err != nil || len(raw[2]) != gcm.NonceSize() || len(raw[4]) != gcm.Overhead() {
+		return "", ErrInvalidToken
+	}
+	plaintext, err := gcm.Open(nil, raw[2], append(raw[3], raw[4]...), []byte(parts[0]))
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -5,9 +5,15 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
+	"errors"
+	"strings"
 	"testing"
 	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/hiamthach108/dreon-auth/config"
 )
 
 // testKeyPair generates a 2048-bit RSA key pair and returns PEM-encoded bytes.
@@ -167,6 +173,48 @@ func TestVerify_expiredToken_returnsError(t *testing.T) {
 	}
 }
 
+func TestWithLeeway_toleratesClockSkew(t *testing.T) {
+	privatePEM, publicPEM := testKeyPair(t)
+	strict, _ := NewManagerFromPEM(privatePEM, publicPEM)
+	lenient, _ := NewManagerFromPEM(privatePEM, publicPEM, WithLeeway(time.Minute))
+	ctx := context.Background()
+	now := time.Now()
+
+	sign := func(issuedAt, expiresAt time.Time) string {
+		token, err := strict.SignClaims(ctx, &Claims{
+			RegisteredClaims: gojwt.RegisteredClaims{
+				IssuedAt:  gojwt.NewNumericDate(issuedAt),
+				NotBefore: gojwt.NewNumericDate(issuedAt),
+				ExpiresAt: gojwt.NewNumericDate(expiresAt),
+			},
+			Payload: Payload{UserID: "u1"},
+		})
+		if err != nil {
+			t.Fatalf("SignClaims: %v", err)
+		}
+		return token
+	}
+	tests := map[string]struct {
+		token  string
+		strict bool
+	}{
+		"issued by a server ahead": {sign(now.Add(30*time.Second), now.Add(time.Hour)), false},
+		"just expired":             {sign(now.Add(-time.Hour), now.Add(-30*time.Second)), false},
+		"valid":                    {sign(now.Add(-time.Minute), now.Add(time.Hour)), true},
+	}
+	for name, tt := range tests {
+		if _, err := strict.Verify(ctx, tt.token); (err == nil) != tt.strict {
+			t.Errorf("%s: strict Verify err = %v", name, err)
+		}
+		if _, err := lenient.Verify(ctx, tt.token); err != nil {
+			t.Errorf("%s: Verify with leeway err = %v", name, err)
+		}
+	}
+	if _, err := lenient.Verify(ctx, sign(now.Add(2*time.Minute), now.Add(time.Hour))); err == nil {
+		t.Error("Verify(issued beyond the leeway) succeeded")
+	}
+}
+
 func TestVerify_tokenSignedWithDifferentKey_returnsError(t *testing.T) {
 	priv1, pub1 := testKeyPair(t)
 	priv2, pub2 := testKeyPair(t)
@@ -211,6 +259,120 @@ func TestWithIssuer_and_WithAudience_setInToken(t *testing.T) {
 	}
 }
 
+func TestVerify_enforcesIssuerAndAudience(t *testing.T) {
+	privatePEM, publicPEM := testKeyPair(t)
+	m, _ := NewManagerFromPEM(privatePEM, publicPEM, WithIssuer("auth"), WithAudience("api", "admin"))
+	ctx := context.Background()
+
+	sign := func(issuer string, audience ...string) string {
+		token, err := m.SignClaims(ctx, &Claims{RegisteredClaims: gojwt.RegisteredClaims{
+			Issuer:    issuer,
+			Audience:  audience,
+			ExpiresAt: gojwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}})
+		if err != nil {
+			t.Fatalf("SignClaims: %v", err)
+		}
+		return token
+	}
+	tests := map[string]struct {
+		token string
+		want  error
+	}{
+		"expected":        {sign("auth", "admin"), nil},
+		"other issuer":    {sign("someone-else", "api"), ErrIssuerMismatch},
+		"no issuer":       {sign("", "api"), ErrIssuerMismatch},
+		"other audience":  {sign("auth", "web"), ErrAudienceMismatch},
+		"no audience":     {sign("auth"), ErrAudienceMismatch},
+		"several, one ok": {sign("auth", "web", "api"), nil},
+	}
+	for name, tt := range tests {
+		_, err := m.Verify(ctx, tt.token)
+		if err != tt.want {
+			t.Errorf("%s: Verify err = %v, want %v", name, err, tt.want)
+		}
+		if tt.want != nil && !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: Verify err = %v, want it to match ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestNewJwtTokenManagerFromConfig_issuerAndAudience(t *testing.T) {
+	privatePEM, _ := testKeyPair(t)
+	priv, _ := parseRSAPrivateKeyFromPEM(privatePEM)
+	keys, err := NewKeySet(Key{PrivateKey: priv})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	cfg := &config.AppConfig{}
+	cfg.App.Name = "dreon-auth"
+	cfg.Jwt.Audience = " api, ,admin"
+	m, err := NewJwtTokenManagerFromConfig(cfg, keys)
+	if err != nil {
+		t.Fatalf("NewJwtTokenManagerFromConfig: %v", err)
+	}
+	if got := m.(*JwtTokenManager); got.issuer != "dreon-auth" || len(got.audience) != 2 || got.audience[0] != "api" || got.audience[1] != "admin" {
+		t.Errorf("issuer, audience = %q, %q", got.issuer, got.audience)
+	}
+	cfg.Jwt.Issuer = "https://auth.example.com"
+	m, _ = NewJwtTokenManagerFromConfig(cfg, keys)
+	if got := m.(*JwtTokenManager).issuer; got != "https://auth.example.com" {
+		t.Errorf("issuer = %q, want JWT_ISSUER", got)
+	}
+}
+
+func TestSignClaims_verifiableWithPublicKey(t *testing.T) {
+	privatePEM, publicPEM := testKeyPair(t)
+	m, err := NewManagerFromPEM(privatePEM, publicPEM)
+	if err != nil {
+		t.Fatalf("NewManagerFromPEM: %v", err)
+	}
+	token, err := m.SignClaims(context.Background(), gojwt.MapClaims{"sub": "u1", "sid": "s1"})
+	if err != nil {
+		t.Fatalf("SignClaims: %v", err)
+	}
+	publicKey, err := gojwt.ParseRSAPublicKeyFromPEM(publicPEM)
+	if err != nil {
+		t.Fatalf("parse public key: %v", err)
+	}
+	claims := gojwt.MapClaims{}
+	if _, err := gojwt.ParseWithClaims(token, claims, func(*gojwt.Token) (interface{}, error) { return publicKey, nil }); err != nil {
+		t.Fatalf("parse signed claims: %v", err)
+	}
+	if claims["sid"] != "s1" {
+		t.Errorf("sid = %v, want s1", claims["sid"])
+	}
+}
+
+func TestVerifyClaims_andAudience(t *testing.T) {
+	privatePEM, publicPEM := testKeyPair(t)
+	m, err := NewManagerFromPEM(privatePEM, publicPEM)
+	if err != nil {
+		t.Fatalf("NewManagerFromPEM: %v", err)
+	}
+	ctx := context.Background()
+	idToken, err := m.SignClaims(ctx, &gojwt.RegisteredClaims{
+		Subject:   "u1",
+		Audience:  gojwt.ClaimStrings{"web"},
+		ExpiresAt: gojwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	if err != nil {
+		t.Fatalf("SignClaims: %v", err)
+	}
+
+	var claims gojwt.RegisteredClaims
+	if err := m.VerifyClaims(ctx, idToken, &claims); err != nil || claims.Subject != "u1" {
+		t.Errorf("VerifyClaims = %+v, %v", claims, err)
+	}
+	// A token for another audience must not pass as an access token.
+	if _, err := m.Verify(ctx, idToken); err == nil {
+		t.Error("Verify(token with foreign audience) want error, got nil")
+	}
+	if err := m.VerifyClaims(ctx, idToken+"x", &gojwt.RegisteredClaims{}); err == nil {
+		t.Error("VerifyClaims(tampered) want error, got nil")
+	}
+}
+
 // parseRSAPrivateKeyFromPEM and parseRSAPublicKeyFromPEM are used only in tests
 // to get *rsa.PrivateKey/*rsa.PublicKey from PEM for NewJwtTokenManager(nil key) tests.
 func parseRSAPrivateKeyFromPEM(pemBytes []byte) (*rsa.PrivateKey, error) {
@@ -231,3 +393,83 @@ func parseRSAPublicKeyFromPEM(pemBytes []byte) (*rsa.PublicKey, error) {
 	}
 	return pub.(*rsa.PublicKey), nil
 }
+
+func TestGenerate_setsUniqueJTI(t *testing.T) {
+	m := testManager(t)
+	ctx := context.Background()
+	a, _ := m.Generate(ctx, Payload{UserID: "u1"}, time.Hour)
+	b, _ := m.Generate(ctx, Payload{UserID: "u1"}, time.Hour)
+
+	pa, err := m.Verify(ctx, a)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	pb, _ := m.Verify(ctx, b)
+	if pa.TokenID == "" || pa.TokenID == pb.TokenID {
+		t.Errorf("TokenID = %q and %q, want distinct non-empty jtis", pa.TokenID, pb.TokenID)
+	}
+	if time.Until(pa.ExpiresAt) <= 0 || time.Until(pa.ExpiresAt) > time.Hour {
+		t.Errorf("ExpiresAt = %v, want about an hour from now", pa.ExpiresAt)
+	}
+}
+
+func TestWithEncryptionKey_encryptsAccessTokens(t *testing.T) {
+	privatePEM, publicPEM := testKeyPair(t)
+	encryptionKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	m, err := NewManagerFromPEM(privatePEM, publicPEM, WithEncryptionKey(encryptionKey))
+	if err != nil {
+		t.Fatalf("NewManagerFromPEM: %v", err)
+	}
+	ctx := context.Background()
+
+	token, err := m.Generate(ctx, Payload{UserID: "u1", Email: "a@b.c"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !IsEncrypted(token) {
+		t.Fatalf("Generate() = %q, want a JWE", token)
+	}
+	got, err := m.Verify(ctx, token)
+	if err != nil || got.UserID != "u1" || got.Email != "a@b.c" {
+		t.Fatalf("Verify() = %+v, %v", got, err)
+	}
+
+	// Signed-only tokens, issued before encryption was turned on, still verify.
+	plain, err := NewManagerFromPEM(privatePEM, publicPEM)
+	if err != nil {
+		t.Fatalf("NewManagerFromPEM: %v", err)
+	}
+	signed, _ := plain.Generate(ctx, Payload{UserID: "u2"}, time.Hour)
+	if _, err := m.Verify(ctx, signed); err != nil {
+		t.Errorf("Verify(signed) err = %v", err)
+	}
+	if _, err := plain.Verify(ctx, token); err == nil {
+		t.Error("Verify(encrypted) without the encryption key succeeded")
+	}
+
+	parts := strings.Split(token, ".")
+	ciphertext, _ := base64.RawURLEncoding.DecodeString(parts[3])
+	ciphertext[0] ^= 1
+	parts[3] = base64.RawURLEncoding.EncodeToString(ciphertext)
+	if _, err := m.Verify(ctx, strings.Join(parts, ".")); err == nil {
+		t.Error("Verify(tampered ciphertext) succeeded")
+	}
+}
+
+func TestDecrypt_wrongKey_returnsError(t *testing.T) {
+	a, _ := rsa.GenerateKey(rand.Reader, 2048)
+	b, _ := rsa.GenerateKey(rand.Reader, 2048)
+	token, err := Encrypt("header.payload.signature", &a.PublicKey, "a")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if got, err := Decrypt(token, func(string) (*rsa.PrivateKey, bool) { return a, true }); err != nil || got != "header.payload.signature" {
+		t.Errorf("Decrypt() = %q, %v", got, err)
+	}
+	if _, err := Decrypt(token, func(string) (*rsa.PrivateKey, bool) { return b, true }); err != ErrInvalidToken {
+		t.Errorf("Decrypt(wrong key) err = %v, want ErrInvalidToken", err)
+	}
+}
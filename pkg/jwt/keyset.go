@@ -0,0 +1,231 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/hiamthach108/dreon-auth/config"
+)
+
+// Key is one RSA key of a key set. PrivateKey is nil for keys that only verify tokens.
+type Key struct {
+	ID         string
+	PrivateKey *rsa.PrivateKey
+	PublicKey  *rsa.PublicKey
+}
+
+// JWK is an RSA public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// RSAPublicKey decodes the key's modulus and exponent.
+func (k JWK) RSAPublicKey() (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, ErrInvalidKey
+	}
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil || len(n) == 0 {
+		return nil, ErrInvalidKey
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, ErrInvalidKey
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// IKeySet holds the key new tokens are signed with and every key tokens are still accepted from.
+// Rotating is a matter of calling SetKeys: publish the next key as a verification key first, then
+// make it the signing key once downstream services have fetched it, and drop the old key after the
+// longest token lifetime has passed.
+type IKeySet interface {
+	// SigningKey returns the key new tokens are signed with.
+	SigningKey() Key
+	// PublicKey returns the verification key with the given kid.
+	PublicKey(kid string) (*rsa.PublicKey, bool)
+	// Keys returns every key, signing key first.
+	Keys() []Key
+	// JWKS returns the public keys for publication.
+	JWKS() JWKS
+	// SetKeys atomically replaces the key set.
+	SetKeys(signing Key, verify ...Key) error
+}
+
+// KeySet is a concurrency-safe IKeySet.
+type KeySet struct {
+	mu      sync.RWMutex
+	signing Key
+	keys    []Key
+	byID    map[string]*rsa.PublicKey
+}
+
+// NewKeySet returns a key set signing with signing and also accepting tokens signed by verify.
+// Keys without an ID get their RFC 7638 thumbprint.
+func NewKeySet(signing Key, verify ...Key) (*KeySet, error) {
+	s := &KeySet{}
+	if err := s.SetKeys(signing, verify...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewKeySetFromConfig builds the key set from JWT_PRIVATE_KEY / JWT_PUBLIC_KEY (kid JWT_KEY_ID,
+// defaulting to the key's thumbprint) plus any public keys in JWT_VERIFY_PUBLIC_KEYS.
+func NewKeySetFromConfig(cfg *config.AppConfig) (IKeySet, error) {
+	privateKey, err := parseRSAPrivateKeyFromString(cfg.Jwt.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := parseRSAPublicKeyFromString(cfg.Jwt.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	verify, err := ParseRSAPublicKeys(cfg.Jwt.VerifyPublicKeys)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]Key, 0, len(verify))
+	for _, pub := range verify {
+		keys = append(keys, Key{PublicKey: pub})
+	}
+	return NewKeySet(Key{ID: cfg.Jwt.KeyID, PrivateKey: privateKey, PublicKey: publicKey}, keys...)
+}
+
+// ParseRSAPublicKeys parses a list of RSA public keys: concatenated PEM blocks, or comma-separated
+// base64 DER. An empty string yields no keys.
+func ParseRSAPublicKeys(s string) ([]*rsa.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	var parts []string
+	if strings.Contains(s, "-----BEGIN") {
+		rest := []byte(s)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			parts = append(parts, string(pem.EncodeToMemory(block)))
+		}
+	} else {
+		parts = strings.Split(s, ",")
+	}
+	keys := make([]*rsa.PublicKey, 0, len(parts))
+	for _, part := range parts {
+		key, err := parseRSAPublicKeyFromString(part)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// KeyID returns the RFC 7638 JWK thumbprint of pub, base64url-encoded.
+func KeyID(pub *rsa.PublicKey) string {
+	// Members in lexicographic order with no whitespace, as the RFC requires.
+	data, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{E: encodeExponent(pub.E), Kty: "RSA", N: base64.RawURLEncoding.EncodeToString(pub.N.Bytes())})
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (s *KeySet) SigningKey() Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.signing
+}
+
+func (s *KeySet) PublicKey(kid string) (*rsa.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pub, ok := s.byID[kid]
+	return pub, ok
+}
+
+func (s *KeySet) Keys() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Key(nil), s.keys...)
+}
+
+func (s *KeySet) JWKS() JWKS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := JWKS{Keys: make([]JWK, 0, len(s.keys))}
+	for _, k := range s.keys {
+		out.Keys = append(out.Keys, JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: SigningMethodAlg,
+			Kid: k.ID,
+			N:   base64.RawURLEncoding.EncodeToString(k.PublicKey.N.Bytes()),
+			E:   encodeExponent(k.PublicKey.E),
+		})
+	}
+	return out
+}
+
+func (s *KeySet) SetKeys(signing Key, verify ...Key) error {
+	if signing.PrivateKey == nil {
+		return ErrInvalidKey
+	}
+	if signing.PublicKey == nil {
+		signing.PublicKey = &signing.PrivateKey.PublicKey
+	}
+	if !signing.PrivateKey.PublicKey.Equal(signing.PublicKey) {
+		return ErrInvalidKey
+	}
+
+	keys := make([]Key, 0, len(verify)+1)
+	byID := make(map[string]*rsa.PublicKey, len(verify)+1)
+	for _, k := range append([]Key{signing}, verify...) {
+		if k.PublicKey == nil {
+			return ErrInvalidKey
+		}
+		if k.ID == "" {
+			k.ID = KeyID(k.PublicKey)
+		}
+		if _, dup := byID[k.ID]; dup {
+			continue
+		}
+		byID[k.ID] = k.PublicKey
+		keys = append(keys, k)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signing = keys[0]
+	s.keys = keys
+	s.byID = byID
+	return nil
+}
+
+func encodeExponent(e int) string {
+	return base64.RawURLEncoding.EncodeToString(big.NewInt(int64(e)).Bytes())
+}
@@ -0,0 +1,127 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func TestKeySet_rotation(t *testing.T) {
+	oldKey, newKey := testRSAKey(t), testRSAKey(t)
+	keys, err := NewKeySet(Key{PrivateKey: oldKey})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	m, _ := NewJwtTokenManagerWithKeySet(keys)
+	ctx := context.Background()
+
+	oldToken, err := m.Generate(ctx, Payload{UserID: "u1"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	parsed, _, _ := gojwt.NewParser().ParseUnverified(oldToken, gojwt.MapClaims{})
+	if parsed.Header["kid"] != KeyID(&oldKey.PublicKey) {
+		t.Errorf("kid = %v, want the key's thumbprint", parsed.Header["kid"])
+	}
+
+	// Promote the new key while still accepting the old one.
+	if err := keys.SetKeys(Key{ID: "next", PrivateKey: newKey}, Key{PublicKey: &oldKey.PublicKey}); err != nil {
+		t.Fatalf("SetKeys: %v", err)
+	}
+	newToken, _ := m.Generate(ctx, Payload{UserID: "u2"}, time.Hour)
+	for _, token := range []string{oldToken, newToken} {
+		if _, err := m.Verify(ctx, token); err != nil {
+			t.Errorf("Verify after rotation: %v", err)
+		}
+	}
+	if jwks := keys.JWKS(); len(jwks.Keys) != 2 || jwks.Keys[0].Kid != "next" || jwks.Keys[0].E != "AQAB" {
+		t.Errorf("JWKS = %+v, want signing key first", jwks)
+	}
+
+	// Retire the old key.
+	if err := keys.SetKeys(Key{ID: "next", PrivateKey: newKey}); err != nil {
+		t.Fatalf("SetKeys: %v", err)
+	}
+	if _, err := m.Verify(ctx, oldToken); err == nil {
+		t.Error("Verify(token from retired key) want error, got nil")
+	}
+}
+
+func TestKeySet_tokenWithoutKid(t *testing.T) {
+	key := testRSAKey(t)
+	keys, _ := NewKeySet(Key{PrivateKey: key})
+	m, _ := NewJwtTokenManagerWithKeySet(keys)
+
+	legacy, err := gojwt.NewWithClaims(gojwt.SigningMethodRS256, &Claims{
+		RegisteredClaims: gojwt.RegisteredClaims{ExpiresAt: gojwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Payload:          Payload{UserID: "u1"},
+	}).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if got, err := m.Verify(context.Background(), legacy); err != nil || got.UserID != "u1" {
+		t.Errorf("Verify(token without kid) = %+v, %v", got, err)
+	}
+}
+
+func TestKeySet_SetKeys_rejectsMismatchedPair(t *testing.T) {
+	a, b := testRSAKey(t), testRSAKey(t)
+	if _, err := NewKeySet(Key{PrivateKey: a, PublicKey: &b.PublicKey}); err != ErrInvalidKey {
+		t.Errorf("NewKeySet(mismatched pair) err = %v, want ErrInvalidKey", err)
+	}
+	if _, err := NewKeySet(Key{PublicKey: &a.PublicKey}); err != ErrInvalidKey {
+		t.Errorf("NewKeySet(verify-only signing key) err = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestParseRSAPublicKeys(t *testing.T) {
+	_, pub1 := testKeyPair(t)
+	_, pub2 := testKeyPair(t)
+	keys, err := ParseRSAPublicKeys(string(pub1) + "\n" + string(pub2))
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("ParseRSAPublicKeys(two PEM blocks) = %d keys, %v", len(keys), err)
+	}
+	if KeyID(keys[0]) == KeyID(keys[1]) {
+		t.Error("distinct keys share a kid")
+	}
+	if keys, err := ParseRSAPublicKeys(" "); err != nil || len(keys) != 0 {
+		t.Errorf("ParseRSAPublicKeys(empty) = %v, %v", keys, err)
+	}
+	if _, err := ParseRSAPublicKeys("not-a-key"); err == nil {
+		t.Error("ParseRSAPublicKeys(garbage) want error, got nil")
+	}
+}
+
+func TestJWK_RSAPublicKey_roundTrip(t *testing.T) {
+	key := testRSAKey(t)
+	keys, err := NewKeySet(Key{PrivateKey: key})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	jwk := keys.JWKS().Keys[0]
+	pub, err := jwk.RSAPublicKey()
+	if err != nil {
+		t.Fatalf("RSAPublicKey: %v", err)
+	}
+	if !pub.Equal(&key.PublicKey) {
+		t.Error("decoded key does not match the published key")
+	}
+
+	jwk.Kty = "EC"
+	if _, err := jwk.RSAPublicKey(); err != ErrInvalidKey {
+		t.Errorf("non-RSA key err = %v, want ErrInvalidKey", err)
+	}
+}
@@ -0,0 +1,115 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+)
+
+// Access token formats selected by JWT_ACCESS_TOKEN_FORMAT.
+const (
+	AccessTokenFormatJWT    = "jwt"
+	AccessTokenFormatOpaque = "opaque"
+)
+
+const (
+	// OpaqueTokenPrefix starts every opaque access token, so they are told from JWTs and API keys at a glance.
+	OpaqueTokenPrefix = "dat_"
+	// OpaqueTokenKeyPrefix is the cache key prefix of opaque tokens' payloads; the key ends with the token's jti.
+	OpaqueTokenKeyPrefix = "opaque_token:"
+)
+
+// OpaqueTokenManager issues access tokens that are random strings and keeps their payload in the cache
+// until they expire. Resolving one costs a cache lookup, but deleting its entry (see OpaqueTokenKey) revokes
+// it everywhere at once, and clients learn nothing from the token itself. The jti is the SHA-256 of the
+// token, so the cache, logs and revocation list never hold a usable token.
+//
+// JWTs from the wrapped manager still verify, so tokens issued before the switch keep working, and
+// SignClaims and VerifyClaims are the wrapped manager's.
+type OpaqueTokenManager struct {
+	IJwtTokenManager
+	store cache.ICache
+}
+
+type opaqueEntry struct {
+	Payload   Payload   `json:"payload"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+// NewOpaqueTokenManager returns a manager that issues opaque access tokens stored in store and verifies
+// JWTs with jwt.
+func NewOpaqueTokenManager(jwt IJwtTokenManager, store cache.ICache) IJwtTokenManager {
+	return &OpaqueTokenManager{IJwtTokenManager: jwt, store: store}
+}
+
+// NewAccessTokenManagerFromConfig returns the JWT manager (see NewJwtTokenManagerFromConfig), wrapped in an
+// OpaqueTokenManager when JWT_ACCESS_TOKEN_FORMAT is "opaque".
+func NewAccessTokenManagerFromConfig(cfg *config.AppConfig, keys IKeySet, store cache.ICache) (IJwtTokenManager, error) {
+	m, err := NewJwtTokenManagerFromConfig(cfg, keys)
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.Jwt.AccessTokenFormat {
+	case "", AccessTokenFormatJWT:
+		return m, nil
+	case AccessTokenFormatOpaque:
+		return NewOpaqueTokenManager(m, store), nil
+	default:
+		return nil, errors.New("jwt: unknown access token format " + cfg.Jwt.AccessTokenFormat)
+	}
+}
+
+// OpaqueTokenKey returns the cache key of the opaque token with the given jti.
+func OpaqueTokenKey(jti string) string {
+	return OpaqueTokenKeyPrefix + jti
+}
+
+// Generate issues a random token and stores payload under its jti until expiry.
+func (m *OpaqueTokenManager) Generate(ctx context.Context, payload Payload, expiry time.Duration) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := OpaqueTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	payload.TokenID, payload.ExpiresAt = "", time.Time{}
+	entry := opaqueEntry{Payload: payload, ExpiresAt: time.Now().Add(expiry)}
+	if err := m.store.WithContext(ctx).Set(OpaqueTokenKey(opaqueTokenID(token)), entry, &expiry); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Verify resolves an opaque token from the cache, and verifies any other token as a JWT.
+func (m *OpaqueTokenManager) Verify(ctx context.Context, tokenString string) (*Payload, error) {
+	if !strings.HasPrefix(tokenString, OpaqueTokenPrefix) {
+		return m.IJwtTokenManager.Verify(ctx, tokenString)
+	}
+	jti := opaqueTokenID(tokenString)
+	var entry opaqueEntry
+	if err := m.store.WithContext(ctx).Get(OpaqueTokenKey(jti), &entry); err != nil {
+		if errors.Is(err, cache.ErrCacheNil) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !time.Now().Before(entry.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	payload := entry.Payload
+	payload.TokenID = jti
+	payload.ExpiresAt = entry.ExpiresAt
+	return &payload, nil
+}
+
+func opaqueTokenID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
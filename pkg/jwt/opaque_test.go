@@ -0,0 +1,90 @@
+package jwt
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/pkg/cache"
+)
+
+func TestOpaqueTokenManager(t *testing.T) {
+	store := cache.NewMemoryCache()
+	m := NewOpaqueTokenManager(testManager(t), store)
+	ctx := context.Background()
+
+	token, err := m.Generate(ctx, Payload{UserID: "u1", SessionID: "s1", Roles: []string{"admin"}}, time.Hour)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !strings.HasPrefix(token, OpaqueTokenPrefix) || strings.Count(token, ".") != 0 {
+		t.Fatalf("Generate() = %q, want an opaque token", token)
+	}
+	got, err := m.Verify(ctx, token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got.UserID != "u1" || got.SessionID != "s1" || len(got.Roles) != 1 || len(got.TokenID) != 64 {
+		t.Errorf("Verify() = %+v", got)
+	}
+	if time.Until(got.ExpiresAt) <= 0 || time.Until(got.ExpiresAt) > time.Hour {
+		t.Errorf("ExpiresAt = %v, want about an hour from now", got.ExpiresAt)
+	}
+	if keys := store.Keys(); len(keys) != 1 || keys[0] != OpaqueTokenKey(got.TokenID) || strings.Contains(keys[0], token) {
+		t.Errorf("cache keys = %q, want only the token's jti", keys)
+	}
+
+	if _, err := m.Verify(ctx, token+"x"); err != ErrInvalidToken {
+		t.Errorf("Verify(unknown) err = %v, want ErrInvalidToken", err)
+	}
+	_ = store.Delete(OpaqueTokenKey(got.TokenID))
+	if _, err := m.Verify(ctx, token); err != ErrInvalidToken {
+		t.Errorf("Verify(deleted) err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestOpaqueTokenManager_expiredAndJWT(t *testing.T) {
+	store := cache.NewMemoryCache()
+	jwtManager := testManager(t)
+	m := NewOpaqueTokenManager(jwtManager, store)
+	ctx := context.Background()
+
+	now := time.Now()
+	token, _ := m.Generate(ctx, Payload{UserID: "u1"}, time.Minute)
+	store.SetClock(func() time.Time { return now.Add(2 * time.Minute) })
+	if _, err := m.Verify(ctx, token); err != ErrInvalidToken {
+		t.Errorf("Verify(expired) err = %v, want ErrInvalidToken", err)
+	}
+
+	signed, _ := jwtManager.Generate(ctx, Payload{UserID: "u2"}, time.Hour)
+	if got, err := m.Verify(ctx, signed); err != nil || got.UserID != "u2" {
+		t.Errorf("Verify(JWT) = %+v, %v", got, err)
+	}
+}
+
+func TestNewAccessTokenManagerFromConfig(t *testing.T) {
+	privatePEM, _ := testKeyPair(t)
+	priv, _ := parseRSAPrivateKeyFromPEM(privatePEM)
+	keys, err := NewKeySet(Key{PrivateKey: priv})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	for format, want := range map[string]bool{"": false, AccessTokenFormatJWT: false, AccessTokenFormatOpaque: true} {
+		cfg := &config.AppConfig{}
+		cfg.Jwt.AccessTokenFormat = format
+		m, err := NewAccessTokenManagerFromConfig(cfg, keys, cache.NewMemoryCache())
+		if err != nil {
+			t.Fatalf("NewAccessTokenManagerFromConfig(%q): %v", format, err)
+		}
+		if _, opaque := m.(*OpaqueTokenManager); opaque != want {
+			t.Errorf("NewAccessTokenManagerFromConfig(%q) = %T", format, m)
+		}
+	}
+	cfg := &config.AppConfig{}
+	cfg.Jwt.AccessTokenFormat = "paseto"
+	if _, err := NewAccessTokenManagerFromConfig(cfg, keys, cache.NewMemoryCache()); err == nil {
+		t.Error("NewAccessTokenManagerFromConfig(paseto) succeeded")
+	}
+}
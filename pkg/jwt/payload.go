@@ -1,12 +1,51 @@
 package jwt
 
-import gojwt "github.com/golang-jwt/jwt/v5"
+import (
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
 
 // Payload holds application-specific claims (no expiry/audience — use Claims for full JWT).
 type Payload struct {
 	UserID       string `json:"userId"`
 	IsSuperAdmin bool   `json:"isSuperAdmin"`
 	Email        string `json:"email"`
+	SessionID    string `json:"sid,omitempty"` // session the token was issued for
+	ProjectID    string `json:"pid,omitempty"` // project the user signed in to, whose access policy applies
+	MFA          bool   `json:"mfa,omitempty"` // a second factor (or a trusted device) was verified for this session
+
+	// APIKeyID is set instead of UserID when the caller authenticated with a project API key.
+	APIKeyID string `json:"akid,omitempty"`
+	// ServiceAccountID is set instead of UserID on tokens issued by the client_credentials grant.
+	ServiceAccountID string `json:"said,omitempty"`
+	// Scopes are the permission codes a machine caller holds in ProjectID; user permissions come from RBAC.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Roles and Permissions are embedded at sign-in when JWT_EMBED_ROLES / JWT_EMBED_PERMISSIONS are set:
+	// role codes and "<project or system>/<code>" permission keys, limited to ProjectID and system when set.
+	// They are a snapshot; changes apply from the next refresh.
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
+	// Attributes are the user attributes ProjectID's schema marks as claims, also a snapshot.
+	Attributes map[string]any `json:"attrs,omitempty"`
+	// Custom holds the claims of the host's IClaimsEnricher hooks, under their own claim so they cannot
+	// shadow the standard ones.
+	Custom map[string]any `json:"ext,omitempty"`
+
+	// Impersonator is set on tokens a super admin obtained to act as UserID (the RFC 8693 act claim), so
+	// clients can show that the session is impersonated.
+	Impersonator *Impersonator `json:"act,omitempty"`
+
+	// TokenID and ExpiresAt mirror the jti and exp claims. Verify fills them in; Generate ignores them.
+	TokenID   string    `json:"-"`
+	ExpiresAt time.Time `json:"-"`
+}
+
+// Impersonator identifies the super admin behind an impersonation token.
+type Impersonator struct {
+	ID    string `json:"sub"`
+	Email string `json:"email,omitempty"`
 }
 
 // Claims embeds standard registered claims (exp, iat, nbf, iss, sub, jti) and Payload for JWT signing/verification.
@@ -14,3 +53,8 @@ type Claims struct {
 	gojwt.RegisteredClaims
 	Payload
 }
+
+// IsMachine reports whether the caller is an API key or service account rather than a user.
+func (p *Payload) IsMachine() bool {
+	return p.APIKeyID != "" || p.ServiceAccountID != ""
+}
@@ -107,6 +107,7 @@ func TestNewLogger(t *testing.T) {
 				App: struct {
 					Name    string `env:"APP_NAME"`
 					Version string `env:"APP_VERSION"`
+					Env     string `env:"APP_ENV"`
 				}{
 					Name: "test-service",
 				},
@@ -124,6 +125,7 @@ func TestNewLogger(t *testing.T) {
 				App: struct {
 					Name    string `env:"APP_NAME"`
 					Version string `env:"APP_VERSION"`
+					Env     string `env:"APP_ENV"`
 				}{
 					Name: "test-service",
 				},
@@ -141,6 +143,7 @@ func TestNewLogger(t *testing.T) {
 				App: struct {
 					Name    string `env:"APP_NAME"`
 					Version string `env:"APP_VERSION"`
+					Env     string `env:"APP_ENV"`
 				}{
 					Name: "test-service",
 				},
@@ -158,6 +161,7 @@ func TestNewLogger(t *testing.T) {
 				App: struct {
 					Name    string `env:"APP_NAME"`
 					Version string `env:"APP_VERSION"`
+					Env     string `env:"APP_ENV"`
 				}{
 					Name: "test-service",
 				},
@@ -175,6 +179,7 @@ func TestNewLogger(t *testing.T) {
 				App: struct {
 					Name    string `env:"APP_NAME"`
 					Version string `env:"APP_VERSION"`
+					Env     string `env:"APP_ENV"`
 				}{
 					Name: "test-service",
 				},
@@ -418,6 +423,7 @@ func TestNewLoggerWithConfigLevel(t *testing.T) {
 		App: struct {
 			Name    string `env:"APP_NAME"`
 			Version string `env:"APP_VERSION"`
+			Env     string `env:"APP_ENV"`
 		}{
 			Name: "test-app",
 		},
@@ -462,6 +468,7 @@ func TestGetZapLogger(t *testing.T) {
 		App: struct {
 			Name    string `env:"APP_NAME"`
 			Version string `env:"APP_VERSION"`
+			Env     string `env:"APP_ENV"`
 		}{
 			Name: "test-app",
 		},
@@ -0,0 +1,26 @@
+package logger
+
+import "context"
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns ctx carrying the ID of the request it serves.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID in ctx, or "" outside of a request.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithContext returns l with the request_id and the trace fields (see WithTrace) found in ctx, so every
+// line logged while serving a request can be matched to it. Log through it wherever a ctx is at hand.
+func WithContext(ctx context.Context, l ILogger) ILogger {
+	l = WithTrace(ctx, l)
+	if id := RequestIDFromContext(ctx); id != "" {
+		l = l.With("request_id", id)
+	}
+	return l
+}
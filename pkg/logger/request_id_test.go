@@ -0,0 +1,31 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestWithContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := createTestLogger(&buf)
+
+	if got := WithContext(context.Background(), logger); got != logger {
+		t.Error("WithContext() outside of a request should return the logger unchanged")
+	}
+
+	ctx := ContextWithRequestID(context.Background(), "req-123")
+	if got := RequestIDFromContext(ctx); got != "req-123" {
+		t.Errorf("RequestIDFromContext() = %q", got)
+	}
+	WithContext(ctx, logger).Info("served")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to parse log entry: %v", err)
+	}
+	if entry["request_id"] != "req-123" {
+		t.Errorf("request_id = %v", entry["request_id"])
+	}
+}
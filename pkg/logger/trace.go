@@ -0,0 +1,17 @@
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTrace returns l with the trace_id and span_id of the span in ctx, so log lines can be matched
+// to their trace. Without a span in ctx, l is returned unchanged.
+func WithTrace(ctx context.Context, l ILogger) ILogger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return l
+	}
+	return l.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+}
@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestWithTrace(t *testing.T) {
+	var buf bytes.Buffer
+	logger := createTestLogger(&buf)
+
+	if got := WithTrace(context.Background(), logger); got != logger {
+		t.Error("WithTrace() without a span should return the logger unchanged")
+	}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	WithTrace(ctx, logger).Info("traced")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to parse log entry: %v", err)
+	}
+	if entry["trace_id"] != traceID.String() || entry["span_id"] != spanID.String() {
+		t.Errorf("trace fields = %v / %v", entry["trace_id"], entry["span_id"])
+	}
+}
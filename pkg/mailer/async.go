@@ -0,0 +1,62 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/pkg/background"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+// defaultRetryDelay is the wait before the second attempt; it doubles with every further attempt.
+const defaultRetryDelay = 2 * time.Second
+
+// AsyncMailer validates a message and hands it to the wrapped mailer in the background, so a slow or
+// briefly unavailable provider does not hold up the request. Failed deliveries are retried with a growing
+// delay; shutdown waits for pending ones through the background group.
+type AsyncMailer struct {
+	next        IMailer
+	workers     *background.Group
+	logger      logger.ILogger
+	maxAttempts int
+	retryDelay  time.Duration
+}
+
+func NewAsyncMailer(next IMailer, workers *background.Group, logger logger.ILogger, maxAttempts int) *AsyncMailer {
+	return &AsyncMailer{
+		next:        next,
+		workers:     workers,
+		logger:      logger,
+		maxAttempts: max(maxAttempts, 1),
+		retryDelay:  defaultRetryDelay,
+	}
+}
+
+// Send only fails for messages that cannot be delivered at all; delivery errors are logged.
+func (m *AsyncMailer) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+	m.workers.Go(func() { m.deliver(ctx, msg) })
+	return nil
+}
+
+func (m *AsyncMailer) deliver(ctx context.Context, msg Message) {
+	delay := m.retryDelay
+	for attempt := 1; ; attempt++ {
+		err := m.next.Send(ctx, msg)
+		if err == nil {
+			return
+		}
+		// The body is never logged, since it may carry sign-in links.
+		if errors.Is(err, ErrInvalidMessage) || attempt >= m.maxAttempts {
+			logger.WithContext(ctx, m.logger).Error("Failed to send email", "to", msg.To, "subject", msg.Subject, "attempts", attempt, "error", err)
+			return
+		}
+		logger.WithContext(ctx, m.logger).Warn("Failed to send email, retrying", "to", msg.To, "attempt", attempt, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
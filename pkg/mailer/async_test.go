@@ -0,0 +1,90 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/pkg/background"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, fields ...any)     {}
+func (nopLogger) Info(msg string, fields ...any)      {}
+func (nopLogger) Warn(msg string, fields ...any)      {}
+func (nopLogger) Error(msg string, fields ...any)     {}
+func (nopLogger) Fatal(msg string, fields ...any)     {}
+func (l nopLogger) With(fields ...any) logger.ILogger { return l }
+func (nopLogger) GetZapLogger() *zap.Logger           { return nil }
+
+// flakyMailer fails the first failures sends.
+type flakyMailer struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	sent     []Message
+}
+
+func (m *flakyMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attempts++
+	if m.attempts <= m.failures {
+		return errors.New("relay unavailable")
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestAsyncMailer_RetriesUntilDelivered(t *testing.T) {
+	workers := background.NewGroup()
+	next := &flakyMailer{failures: 2}
+	m := NewAsyncMailer(next, workers, nopLogger{}, 3)
+	m.retryDelay = time.Millisecond
+
+	// A cancelled request does not stop the delivery.
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Send(ctx, Message{To: "ann@example.com", Subject: "Hi", Text: "x"}); err != nil {
+		t.Fatalf("Send() err = %v", err)
+	}
+	cancel()
+	if err := workers.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if next.attempts != 3 || len(next.sent) != 1 {
+		t.Errorf("attempts = %d, sent = %d; want delivery on the third attempt", next.attempts, len(next.sent))
+	}
+}
+
+func TestAsyncMailer_GivesUp(t *testing.T) {
+	workers := background.NewGroup()
+	next := &flakyMailer{failures: 5}
+	m := NewAsyncMailer(next, workers, nopLogger{}, 2)
+	m.retryDelay = time.Millisecond
+	if err := m.Send(context.Background(), Message{To: "ann@example.com", Subject: "Hi", Text: "x"}); err != nil {
+		t.Fatalf("Send() err = %v", err)
+	}
+	_ = workers.Wait(context.Background())
+	if next.attempts != 2 || len(next.sent) != 0 {
+		t.Errorf("attempts = %d, sent = %d; want two failed attempts", next.attempts, len(next.sent))
+	}
+}
+
+func TestAsyncMailer_RejectsInvalidMessages(t *testing.T) {
+	next := &flakyMailer{}
+	m := NewAsyncMailer(next, background.NewGroup(), nopLogger{}, 3)
+	if err := m.Send(context.Background(), Message{To: "not an address", Text: "x"}); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("Send() err = %v, want ErrInvalidMessage", err)
+	}
+	if err := m.Send(context.Background(), Message{From: "bad sender", To: "ann@example.com", Text: "x"}); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("Send() with a bad sender err = %v, want ErrInvalidMessage", err)
+	}
+	if next.attempts != 0 {
+		t.Errorf("attempts = %d, want none", next.attempts)
+	}
+}
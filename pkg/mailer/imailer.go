@@ -0,0 +1,18 @@
+package mailer
+
+import "context"
+
+// Message is a single outgoing email. HTML is optional; when set the mail is sent as multipart/alternative.
+// From overrides the configured sender, e.g. with a project's own address.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// IMailer delivers transactional email (magic links, notifications).
+type IMailer interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,185 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/pkg/background"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+var ErrInvalidMessage = errors.New("mailer: invalid message")
+
+const (
+	DriverSMTP     = "smtp"
+	DriverSES      = "ses"
+	DriverSendGrid = "sendgrid"
+
+	defaultSMTPPort    = 587
+	defaultMaxAttempts = 3
+)
+
+// NewMailer returns the driver selected by AppConfig.Mail.Driver (env: MAIL_DRIVER, default smtp), sending in
+// the background with retries. With the smtp driver and no SMTP_HOST, mail is dropped with a warning so local
+// setups run without a relay.
+func NewMailer(cfg *config.AppConfig, logger logger.ILogger, workers *background.Group) (IMailer, error) {
+	var m IMailer
+	switch cfg.Mail.Driver {
+	case "", DriverSMTP:
+		if cfg.Mail.SMTPHost == "" {
+			logger.Warn("SMTP_HOST is not set; outgoing email will be dropped")
+			return &noopMailer{logger: logger}, nil
+		}
+		m = NewSMTPMailer(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort, cfg.Mail.Username, cfg.Mail.Password, cfg.Mail.From)
+	case DriverSES:
+		if cfg.Mail.SESRegion == "" || cfg.Mail.SESAccessKeyID == "" || cfg.Mail.SESSecretAccessKey == "" {
+			return nil, fmt.Errorf("mailer: ses requires SES_REGION, SES_ACCESS_KEY_ID and SES_SECRET_ACCESS_KEY")
+		}
+		m = NewSESMailer(cfg.Mail.SESRegion, cfg.Mail.SESAccessKeyID, cfg.Mail.SESSecretAccessKey, cfg.Mail.From)
+	case DriverSendGrid:
+		if cfg.Mail.SendGridAPIKey == "" {
+			return nil, fmt.Errorf("mailer: sendgrid requires SENDGRID_API_KEY")
+		}
+		m = NewSendGridMailer(cfg.Mail.SendGridAPIKey, cfg.Mail.From)
+	default:
+		return nil, fmt.Errorf("mailer: unknown driver %q", cfg.Mail.Driver)
+	}
+	if _, err := mail.ParseAddress(cfg.Mail.From); err != nil {
+		return nil, fmt.Errorf("mailer: MAIL_FROM: %w", err)
+	}
+	attempts := cfg.Mail.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultMaxAttempts
+	}
+	return NewAsyncMailer(m, workers, logger, attempts), nil
+}
+
+// SMTPMailer sends mail through an SMTP relay, using STARTTLS when the server offers it.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer returns a mailer for the relay at host; port defaults to 587. Without a username no
+// authentication is attempted.
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	m := &SMTPMailer{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		from: from,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	sender := senderOf(m.from, msg)
+	data, err := buildMessage(sender, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	to, _ := mail.ParseAddress(msg.To)
+	from, _ := mail.ParseAddress(sender)
+	return smtp.SendMail(m.addr, m.auth, from.Address, []string{to.Address}, data)
+}
+
+// senderOf returns the message's own sender, or the configured one.
+func senderOf(from string, msg Message) string {
+	if msg.From != "" {
+		return msg.From
+	}
+	return from
+}
+
+// validate checks msg before it is handed to a provider. Header values are validated so a recipient or
+// subject taken from user input cannot inject extra headers.
+func validate(msg Message) error {
+	if _, err := mail.ParseAddress(msg.To); err != nil {
+		return fmt.Errorf("%w: recipient: %v", ErrInvalidMessage, err)
+	}
+	if msg.From != "" {
+		if _, err := mail.ParseAddress(msg.From); err != nil {
+			return fmt.Errorf("%w: sender: %v", ErrInvalidMessage, err)
+		}
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("%w: subject contains a line break", ErrInvalidMessage)
+	}
+	if msg.Text == "" && msg.HTML == "" {
+		return fmt.Errorf("%w: empty body", ErrInvalidMessage)
+	}
+	return nil
+}
+
+// buildMessage renders msg as an RFC 5322 message from the sender from.
+func buildMessage(from string, msg Message, now time.Time) ([]byte, error) {
+	if err := validate(msg); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		writePart(&buf, "text/plain", msg.Text)
+		return buf.Bytes(), nil
+	}
+	boundary := randomBoundary()
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	if msg.Text != "" {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		writePart(&buf, "text/plain", msg.Text)
+	}
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	writePart(&buf, "text/html", msg.HTML)
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+func writePart(buf *bytes.Buffer, contentType, body string) {
+	fmt.Fprintf(buf, "Content-Type: %s; charset=utf-8\r\n", contentType)
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	w := quotedprintable.NewWriter(buf)
+	_, _ = w.Write([]byte(body))
+	_ = w.Close()
+	buf.WriteString("\r\n")
+}
+
+func randomBoundary() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// noopMailer drops mail. Only the recipient and subject are logged, never the body,
+// since bodies carry sign-in links.
+type noopMailer struct {
+	logger logger.ILogger
+}
+
+func (m *noopMailer) Send(ctx context.Context, msg Message) error {
+	m.logger.Warn("email dropped: SMTP is not configured", "to", msg.To, "subject", msg.Subject)
+	return nil
+}
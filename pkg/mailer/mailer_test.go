@@ -0,0 +1,95 @@
+package mailer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/pkg/background"
+)
+
+func TestBuildMessage(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, err := buildMessage("Dreon <no-reply@example.com>", Message{
+		To:      "ann@example.com",
+		Subject: "Sign in to Dreon",
+		Text:    "Open https://app.example.com/magic?token=abc",
+	}, now)
+	if err != nil {
+		t.Fatalf("buildMessage() err = %v", err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		"From: Dreon <no-reply@example.com>\r\n",
+		"To: ann@example.com\r\n",
+		"Subject: Sign in to Dreon\r\n",
+		"Date: Fri, 02 Jan 2026 03:04:05 +0000\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"token=3Dabc",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("message missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "multipart") {
+		t.Error("text-only message should not be multipart")
+	}
+}
+
+func TestBuildMessage_Alternative(t *testing.T) {
+	data, err := buildMessage("no-reply@example.com", Message{To: "ann@example.com", Subject: "Hi", Text: "plain", HTML: "<p>html</p>"}, time.Now())
+	if err != nil {
+		t.Fatalf("buildMessage() err = %v", err)
+	}
+	got := string(data)
+	if !strings.Contains(got, "multipart/alternative") || !strings.Contains(got, "text/plain") || !strings.Contains(got, "text/html") {
+		t.Errorf("want a multipart/alternative message with both parts:\n%s", got)
+	}
+}
+
+func TestBuildMessage_RejectsHeaderInjection(t *testing.T) {
+	tests := []Message{
+		{To: "ann@example.com\r\nBcc: eve@example.com", Subject: "Hi", Text: "x"},
+		{To: "ann@example.com", Subject: "Hi\r\nBcc: eve@example.com", Text: "x"},
+		{To: "not an address", Subject: "Hi", Text: "x"},
+		{To: "ann@example.com", Subject: "Hi"},
+	}
+	for _, msg := range tests {
+		if _, err := buildMessage("no-reply@example.com", msg, time.Now()); !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("buildMessage(%+v) err = %v, want ErrInvalidMessage", msg, err)
+		}
+	}
+}
+
+func TestNewMailer_Drivers(t *testing.T) {
+	newCfg := func(driver string) *config.AppConfig {
+		cfg := &config.AppConfig{}
+		cfg.Mail.Driver = driver
+		cfg.Mail.From = "no-reply@example.com"
+		return cfg
+	}
+	if m, err := NewMailer(newCfg(""), nopLogger{}, background.NewGroup()); err != nil {
+		t.Errorf("NewMailer(no SMTP_HOST) err = %v", err)
+	} else if _, ok := m.(*noopMailer); !ok {
+		t.Errorf("NewMailer(no SMTP_HOST) = %T, want the dropping mailer", m)
+	}
+	if _, err := NewMailer(newCfg(DriverSES), nopLogger{}, background.NewGroup()); err == nil {
+		t.Error("NewMailer(ses without credentials) err = nil")
+	}
+	if _, err := NewMailer(newCfg("postfix"), nopLogger{}, background.NewGroup()); err == nil {
+		t.Error("NewMailer(unknown driver) err = nil")
+	}
+	cfg := newCfg(DriverSendGrid)
+	cfg.Mail.SendGridAPIKey = "SG.key"
+	if m, err := NewMailer(cfg, nopLogger{}, background.NewGroup()); err != nil {
+		t.Errorf("NewMailer(sendgrid) err = %v", err)
+	} else if async, ok := m.(*AsyncMailer); !ok || async.maxAttempts != defaultMaxAttempts {
+		t.Errorf("NewMailer(sendgrid) = %#v, want an AsyncMailer with %d attempts", m, defaultMaxAttempts)
+	}
+	cfg.Mail.From = "not an address"
+	if _, err := NewMailer(cfg, nopLogger{}, background.NewGroup()); err == nil {
+		t.Error("NewMailer(bad MAIL_FROM) err = nil")
+	}
+}
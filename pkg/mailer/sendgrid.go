@@ -0,0 +1,99 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const sendGridBaseURL = "https://api.sendgrid.com"
+
+// SendGridMailer sends mail through the SendGrid v3 Mail Send API. The sender (MAIL_FROM, or a project's
+// own) has to be a verified SendGrid sender.
+type SendGridMailer struct {
+	baseURL string
+	apiKey  string
+	from    string
+	client  *http.Client
+}
+
+func NewSendGridMailer(apiKey, from string) *SendGridMailer {
+	return &SendGridMailer{
+		baseURL: sendGridBaseURL,
+		apiKey:  apiKey,
+		from:    from,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func (m *SendGridMailer) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(senderOf(m.from, msg))
+	if err != nil {
+		return fmt.Errorf("%w: sender: %v", ErrInvalidMessage, err)
+	}
+	to, _ := mail.ParseAddress(msg.To)
+	// SendGrid requires text/plain before text/html.
+	var content []sendGridContent
+	if msg.Text != "" {
+		content = append(content, sendGridContent{Type: "text/plain", Value: msg.Text})
+	}
+	if msg.HTML != "" {
+		content = append(content, sendGridContent{Type: "text/html", Value: msg.HTML})
+	}
+	payload, err := json.Marshal(map[string]any{
+		"personalizations": []map[string]any{{"to": []sendGridAddress{{Email: to.Address, Name: to.Name}}}},
+		"from":             sendGridAddress{Email: from.Address, Name: from.Name},
+		"subject":          msg.Subject,
+		"content":          content,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/v3/mail/send", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("mailer: sendgrid request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		_ = json.Unmarshal(data, &apiErr)
+		messages := make([]string, 0, len(apiErr.Errors))
+		for _, e := range apiErr.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("mailer: sendgrid returned %d: %s", resp.StatusCode, strings.Join(messages, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSendGridMailer_Send(t *testing.T) {
+	var gotPath, gotAuth string
+	var got struct {
+		Personalizations []struct{ To []sendGridAddress }
+		From             sendGridAddress
+		Subject          string
+		Content          []sendGridContent
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &got)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	m := NewSendGridMailer("SG.key", "Dreon <no-reply@example.com>")
+	m.baseURL = srv.URL
+	if err := m.Send(context.Background(), Message{To: "Ann <ann@example.com>", Subject: "Hi", Text: "plain", HTML: "<p>html</p>"}); err != nil {
+		t.Fatalf("Send() err = %v", err)
+	}
+	if gotPath != "/v3/mail/send" || gotAuth != "Bearer SG.key" {
+		t.Errorf("path = %q, auth = %q", gotPath, gotAuth)
+	}
+	if got.From != (sendGridAddress{Email: "no-reply@example.com", Name: "Dreon"}) ||
+		got.Personalizations[0].To[0] != (sendGridAddress{Email: "ann@example.com", Name: "Ann"}) || got.Subject != "Hi" {
+		t.Errorf("request = %+v", got)
+	}
+	if len(got.Content) != 2 || got.Content[0].Type != "text/plain" || got.Content[1].Type != "text/html" {
+		t.Errorf("content = %+v, want text/plain then text/html", got.Content)
+	}
+}
+
+func TestSendGridMailer_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errors": [{"message": "The from address does not match a verified Sender Identity."}]}`))
+	}))
+	defer srv.Close()
+
+	m := NewSendGridMailer("SG.key", "no-reply@example.com")
+	m.baseURL = srv.URL
+	err := m.Send(context.Background(), Message{To: "ann@example.com", Subject: "Hi", Text: "x"})
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "Sender Identity") {
+		t.Errorf("Send() err = %v, want the SendGrid error", err)
+	}
+}
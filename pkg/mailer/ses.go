@@ -0,0 +1,146 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SESMailer sends mail through the Amazon SES v2 SendEmail API. The sender (MAIL_FROM, or a project's own)
+// has to be a verified SES identity.
+type SESMailer struct {
+	endpoint        string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	from            string
+	client          *http.Client
+	now             func() time.Time
+}
+
+func NewSESMailer(region, accessKeyID, secretAccessKey, from string) *SESMailer {
+	return &SESMailer{
+		endpoint:        fmt.Sprintf("https://email.%s.amazonaws.com", region),
+		region:          region,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		from:            from,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		now:             time.Now,
+	}
+}
+
+type sesContent struct {
+	Data    string `json:"Data"`
+	Charset string `json:"Charset"`
+}
+
+func (m *SESMailer) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(senderOf(m.from, msg))
+	if err != nil {
+		return fmt.Errorf("%w: sender: %v", ErrInvalidMessage, err)
+	}
+	body := map[string]*sesContent{}
+	if msg.Text != "" {
+		body["Text"] = &sesContent{Data: msg.Text, Charset: "UTF-8"}
+	}
+	if msg.HTML != "" {
+		body["Html"] = &sesContent{Data: msg.HTML, Charset: "UTF-8"}
+	}
+	payload, err := json.Marshal(map[string]any{
+		"FromEmailAddress": from.String(),
+		"Destination":      map[string]any{"ToAddresses": []string{msg.To}},
+		"Content": map[string]any{"Simple": map[string]any{
+			"Subject": sesContent{Data: msg.Subject, Charset: "UTF-8"},
+			"Body":    body,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint+"/v2/email/outbound-emails", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signV4(req, payload, m.accessKeyID, m.secretAccessKey, m.region, "ses", m.now())
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("mailer: ses request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		_ = json.Unmarshal(data, &apiErr)
+		return fmt.Errorf("mailer: ses returned %d: %s %s", resp.StatusCode, resp.Header.Get("X-Amzn-ErrorType"), apiErr.Message)
+	}
+	return nil
+}
+
+// signV4 adds AWS Signature Version 4 headers to req, signing the host and every header already set.
+// Query strings are not supported: the SES endpoint takes none.
+func signV4(req *http.Request, body []byte, accessKeyID, secretAccessKey, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,77 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The get-vanilla case of the AWS Signature Version 4 test suite.
+func TestSignV4_TestSuiteVector(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	signV4(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q\nwant %q", got, want)
+	}
+}
+
+func TestSESMailer_Send(t *testing.T) {
+	var gotPath, gotAuth string
+	var got struct {
+		FromEmailAddress string
+		Destination      struct{ ToAddresses []string }
+		Content          struct {
+			Simple struct {
+				Subject struct{ Data string }
+				Body    struct{ Text, Html *struct{ Data string } }
+			}
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &got)
+		_, _ = w.Write([]byte(`{"MessageId": "m-1"}`))
+	}))
+	defer srv.Close()
+
+	m := NewSESMailer("eu-west-1", "AKID", "secret", "Dreon <no-reply@example.com>")
+	m.endpoint = srv.URL
+	err := m.Send(context.Background(), Message{From: "Acme <hello@acme.test>", To: "ann@example.com", Subject: "Hi", Text: "plain", HTML: "<p>html</p>"})
+	if err != nil {
+		t.Fatalf("Send() err = %v", err)
+	}
+	if gotPath != "/v2/email/outbound-emails" || !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+		!strings.Contains(gotAuth, "/eu-west-1/ses/aws4_request") {
+		t.Errorf("path = %q, auth = %q", gotPath, gotAuth)
+	}
+	body := got.Content.Simple.Body
+	if got.FromEmailAddress != `"Acme" <hello@acme.test>` || got.Destination.ToAddresses[0] != "ann@example.com" ||
+		got.Content.Simple.Subject.Data != "Hi" || body.Text == nil || body.Text.Data != "plain" || body.Html == nil {
+		t.Errorf("request = %+v", got)
+	}
+}
+
+func TestSESMailer_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-ErrorType", "MessageRejected")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"message": "Email address is not verified."}`))
+	}))
+	defer srv.Close()
+
+	m := NewSESMailer("eu-west-1", "AKID", "secret", "no-reply@example.com")
+	m.endpoint = srv.URL
+	err := m.Send(context.Background(), Message{To: "ann@example.com", Subject: "Hi", Text: "x"})
+	if err == nil || !strings.Contains(err.Error(), "MessageRejected") || !strings.Contains(err.Error(), "not verified") {
+		t.Errorf("Send() err = %v, want the SES error", err)
+	}
+}
@@ -0,0 +1,84 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Template names a transactional email. Each has a plain-text version with its subject in
+// templates/<name>.txt and an HTML version in templates/<name>.html, rendered in templates/layout.html.
+type Template string
+
+const (
+	TemplateVerification  Template = "verification"
+	TemplatePasswordReset Template = "password_reset"
+	TemplateMagicLink     Template = "magic_link"
+	TemplateSecurityAlert Template = "security_alert"
+)
+
+// TemplateData fills in a template. Fields a template does not use are ignored.
+type TemplateData struct {
+	AppName   string
+	Intro     string        // opening sentence, replacing the template's own
+	Link      string        // the link the email is about
+	ExpiresIn time.Duration // how long Link works
+
+	// Security alerts: what happened, and when and where from.
+	Event    string
+	Time     time.Time
+	Device   string
+	IP       string
+	Location string
+}
+
+//go:embed templates
+var templateFiles embed.FS
+
+type parsedTemplate struct {
+	text *template.Template
+	html *htmltemplate.Template
+}
+
+var templates = parseTemplates(TemplateVerification, TemplatePasswordReset, TemplateMagicLink, TemplateSecurityAlert)
+
+func parseTemplates(names ...Template) map[Template]parsedTemplate {
+	funcs := map[string]any{"minutes": func(d time.Duration) int { return int(d / time.Minute) }}
+	parsed := make(map[Template]parsedTemplate, len(names))
+	for _, name := range names {
+		parsed[name] = parsedTemplate{
+			text: template.Must(template.New(string(name)).Funcs(funcs).ParseFS(templateFiles, "templates/"+string(name)+".txt")),
+			html: htmltemplate.Must(htmltemplate.New(string(name)).Funcs(funcs).ParseFS(templateFiles, "templates/layout.html", "templates/"+string(name)+".html")),
+		}
+	}
+	return parsed
+}
+
+// Render builds the message of tmpl for the recipient to, with both a plain-text and an HTML body.
+func Render(to string, tmpl Template, data TemplateData) (Message, error) {
+	t, ok := templates[tmpl]
+	if !ok {
+		return Message{}, fmt.Errorf("mailer: unknown template %q", tmpl)
+	}
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("mailer: render %s subject: %w", tmpl, err)
+	}
+	if err := t.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, fmt.Errorf("mailer: render %s text: %w", tmpl, err)
+	}
+	if err := t.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Message{}, fmt.Errorf("mailer: render %s html: %w", tmpl, err)
+	}
+	return Message{
+		To: to,
+		// Line breaks in the data would otherwise make the subject an invalid header.
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimLeft(text.String(), "\n"),
+		HTML:    html.String(),
+	}, nil
+}
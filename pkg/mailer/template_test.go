@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender_AllTemplates(t *testing.T) {
+	for _, tmpl := range []Template{TemplateVerification, TemplatePasswordReset, TemplateMagicLink, TemplateSecurityAlert} {
+		msg, err := Render("ann@example.com", tmpl, TemplateData{AppName: "Dreon", Link: "https://app.example.com/x?token=a&b=c", ExpiresIn: 15 * time.Minute})
+		if err != nil {
+			t.Fatalf("Render(%s) err = %v", tmpl, err)
+		}
+		if msg.To != "ann@example.com" || !strings.Contains(msg.Subject, "Dreon") || validate(msg) != nil {
+			t.Errorf("Render(%s) = %+v", tmpl, msg)
+		}
+		if !strings.Contains(msg.Text, "https://app.example.com/x?token=a&b=c") || !strings.Contains(msg.HTML, `href="https://app.example.com/x?token=a&amp;b=c"`) {
+			t.Errorf("Render(%s) does not carry the link:\n%s\n%s", tmpl, msg.Text, msg.HTML)
+		}
+	}
+	if _, err := Render("ann@example.com", "welcome", TemplateData{}); err == nil {
+		t.Error("Render(unknown template) err = nil")
+	}
+}
+
+func TestRender_MagicLink(t *testing.T) {
+	msg, err := Render("ann@example.com", TemplateMagicLink, TemplateData{
+		AppName: "Dreon", Intro: "We noticed a new sign-in.", Link: "https://app.example.com/magic?token=t", ExpiresIn: 15 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "We noticed a new sign-in. It expires in 15 minutes and works once.\n\nhttps://app.example.com/magic?token=t\n\n" +
+		"If you did not request it, you can ignore this email.\n"
+	if msg.Subject != "Sign in to Dreon" || msg.Text != want {
+		t.Errorf("subject = %q, text = %q", msg.Subject, msg.Text)
+	}
+}
+
+func TestRender_EscapesHTML(t *testing.T) {
+	msg, err := Render("ann@example.com", TemplateSecurityAlert, TemplateData{
+		AppName: "Dreon", Event: "New sign-in", Device: "<script>alert(1)</script>", IP: "203.0.113.7", Time: time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(msg.HTML, "<script>") || !strings.Contains(msg.HTML, "&lt;script&gt;") {
+		t.Errorf("HTML does not escape the device:\n%s", msg.HTML)
+	}
+	for _, want := range []string{"What happened: New sign-in", "When: 2026-01-02 03:04 UTC", "IP address: 203.0.113.7"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("text missing %q:\n%s", want, msg.Text)
+		}
+	}
+}
+
+func TestRender_SubjectStaysOneLine(t *testing.T) {
+	msg, err := Render("ann@example.com", TemplateMagicLink, TemplateData{AppName: "Evil\r\nBcc: eve@example.com", Link: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		t.Errorf("subject = %q", msg.Subject)
+	}
+}
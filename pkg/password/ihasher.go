@@ -0,0 +1,17 @@
+package password
+
+// IPasswordHasher hashes passwords with the configured algorithm and pepper and verifies hashes made with
+// any supported one, so the algorithm, its parameters or the pepper can change without invalidating stored
+// passwords. Callers store the pepper version next to each hash.
+type IPasswordHasher interface {
+	// Hash returns the hash of plain and the version of the pepper it was made with, 0 for none.
+	Hash(plain string) (hashed string, pepperVersion int, err error)
+	// Compare returns nil when plain matches hashed, ErrMismatch when it does not, ErrUnknownHash when
+	// hashed is in no supported format and ErrUnknownPepper when its pepper is no longer configured.
+	Compare(hashed string, pepperVersion int, plain string) error
+	// NeedsRehash reports whether hashed was made with another algorithm, other parameters or another
+	// pepper than Hash uses now; callers replace it once they know the password, e.g. after a successful login.
+	NeedsRehash(hashed string, pepperVersion int) bool
+	// IsHash reports whether hashed is in a supported format.
+	IsHash(hashed string) bool
+}
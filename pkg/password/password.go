@@ -0,0 +1,261 @@
+package password
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var (
+	// ErrMismatch is returned by Compare when the password does not match the hash.
+	ErrMismatch = errors.New("password: hash and password do not match")
+	// ErrUnknownHash is returned by Compare for hashes in no supported format.
+	ErrUnknownHash = errors.New("password: unsupported hash format")
+	// ErrUnknownPepper is returned by Compare for hashes peppered with a version that is not configured.
+	ErrUnknownPepper = errors.New("password: unknown pepper version")
+)
+
+// Argon2Params are the argon2id cost parameters.
+type Argon2Params struct {
+	MemoryKiB   uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params is used for the parameters PASSWORD_ARGON2_* leaves unset: 64 MiB and 3 passes, as in
+// the second recommended option of RFC 9106, with 2 lanes.
+var DefaultArgon2Params = Argon2Params{MemoryKiB: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+
+// NewHasher returns the hasher selected by AppConfig.Password (env: PASSWORD_HASH_ALGORITHM), bcrypt by default,
+// with the peppers of NewPepperSource.
+func NewHasher(cfg *config.AppConfig) (IPasswordHasher, error) {
+	argon2Params := DefaultArgon2Params
+	if cfg.Password.Argon2MemoryKiB > 0 {
+		argon2Params.MemoryKiB = uint32(cfg.Password.Argon2MemoryKiB)
+	}
+	if cfg.Password.Argon2Iterations > 0 {
+		argon2Params.Iterations = uint32(cfg.Password.Argon2Iterations)
+	}
+	if cfg.Password.Argon2Parallelism > 0 {
+		if cfg.Password.Argon2Parallelism > 255 {
+			return nil, fmt.Errorf("password: PASSWORD_ARGON2_PARALLELISM must be at most 255")
+		}
+		argon2Params.Parallelism = uint8(cfg.Password.Argon2Parallelism)
+	}
+	bcryptCost := bcrypt.DefaultCost
+	if cfg.Password.BcryptCost > 0 {
+		bcryptCost = cfg.Password.BcryptCost
+	}
+	source, err := NewPepperSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	peppers, err := source.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm := cfg.Password.Algorithm
+	if algorithm == "" {
+		algorithm = AlgorithmBcrypt
+	}
+	return NewHasherWith(algorithm, bcryptCost, argon2Params, peppers)
+}
+
+// ParsePeppers parses PASSWORD_PEPPERS: comma-separated version:secret pairs, each version a positive
+// integer and each secret standard base64 of at least 16 bytes.
+func ParsePeppers(value string) (map[int][]byte, error) {
+	peppers := map[int][]byte{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		version, secret, ok := strings.Cut(pair, ":")
+		v, err := strconv.Atoi(version)
+		if !ok || err != nil || v < 1 {
+			return nil, fmt.Errorf("password: PASSWORD_PEPPERS entries must be version:base64 with a positive version")
+		}
+		if _, dup := peppers[v]; dup {
+			return nil, fmt.Errorf("password: pepper version %d is listed twice", v)
+		}
+		key, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil || len(key) < 16 {
+			return nil, fmt.Errorf("password: pepper version %d must be base64 of at least 16 bytes", v)
+		}
+		peppers[v] = key
+	}
+	return peppers, nil
+}
+
+// Hasher hashes with one algorithm and verifies bcrypt and argon2id hashes alike. With peppers it first
+// replaces the password with its HMAC-SHA256 under the newest one, so hashes are useless without the
+// pepper; a version of 0 means a hash made without pepper.
+type Hasher struct {
+	algorithm     string
+	bcryptCost    int
+	argon2        Argon2Params
+	peppers       map[int][]byte
+	pepperVersion int
+}
+
+// NewHasherWith returns a Hasher that hashes with algorithm and the highest version of peppers, which
+// may be empty; the parameters of the other algorithm are only used to tell whether its hashes need
+// rehashing, which they always do.
+func NewHasherWith(algorithm string, bcryptCost int, argon2Params Argon2Params, peppers map[int][]byte) (*Hasher, error) {
+	if algorithm != AlgorithmBcrypt && algorithm != AlgorithmArgon2id {
+		return nil, fmt.Errorf("password: unknown hash algorithm %q", algorithm)
+	}
+	if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("password: bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if argon2Params.MemoryKiB < 8*uint32(argon2Params.Parallelism) || argon2Params.Iterations < 1 || argon2Params.Parallelism < 1 ||
+		argon2Params.SaltLength < 8 || argon2Params.KeyLength < 16 {
+		return nil, fmt.Errorf("password: invalid argon2id parameters %+v", argon2Params)
+	}
+	h := &Hasher{algorithm: algorithm, bcryptCost: bcryptCost, argon2: argon2Params, peppers: peppers}
+	if len(peppers) > 0 {
+		h.pepperVersion = slices.Max(slices.Collect(maps.Keys(peppers)))
+	}
+	return h, nil
+}
+
+func (h *Hasher) Hash(plain string) (string, int, error) {
+	input, err := h.pepper(plain, h.pepperVersion)
+	if err != nil {
+		return "", 0, err
+	}
+	if h.algorithm == AlgorithmBcrypt {
+		hashed, err := bcrypt.GenerateFromPassword(input, h.bcryptCost)
+		if err != nil {
+			return "", 0, err
+		}
+		return string(hashed), h.pepperVersion, nil
+	}
+	salt := make([]byte, h.argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", 0, err
+	}
+	p := h.argon2
+	key := argon2.IDKey(input, salt, p.Iterations, p.MemoryKiB, p.Parallelism, p.KeyLength)
+	return encodeArgon2id(p, salt, key), h.pepperVersion, nil
+}
+
+func (h *Hasher) Compare(hashed string, pepperVersion int, plain string) error {
+	input, err := h.pepper(plain, pepperVersion)
+	if err != nil {
+		return err
+	}
+	if isBcrypt(hashed) {
+		err := bcrypt.CompareHashAndPassword([]byte(hashed), input)
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatch
+		}
+		return err
+	}
+	p, salt, key, err := decodeArgon2id(hashed)
+	if err != nil {
+		return err
+	}
+	other := argon2.IDKey(input, salt, p.Iterations, p.MemoryKiB, p.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (h *Hasher) NeedsRehash(hashed string, pepperVersion int) bool {
+	if pepperVersion != h.pepperVersion {
+		return true
+	}
+	if h.algorithm == AlgorithmBcrypt {
+		cost, err := bcrypt.Cost([]byte(hashed))
+		return err != nil || cost != h.bcryptCost
+	}
+	p, salt, key, err := decodeArgon2id(hashed)
+	if err != nil {
+		return true
+	}
+	p.SaltLength, p.KeyLength = uint32(len(salt)), uint32(len(key))
+	return p != h.argon2
+}
+
+func (h *Hasher) IsHash(hashed string) bool {
+	if isBcrypt(hashed) {
+		_, err := bcrypt.Cost([]byte(hashed))
+		return err == nil
+	}
+	_, _, _, err := decodeArgon2id(hashed)
+	return err == nil
+}
+
+// pepper returns the input to hash for plain: plain itself for version 0, otherwise its HMAC under the
+// pepper, base64-encoded so it stays within bcrypt's 72-byte limit.
+func (h *Hasher) pepper(plain string, version int) ([]byte, error) {
+	if version == 0 {
+		return []byte(plain), nil
+	}
+	key, ok := h.peppers[version]
+	if !ok {
+		return nil, ErrUnknownPepper
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(plain))
+	return []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil))), nil
+}
+
+func isBcrypt(hashed string) bool {
+	return strings.HasPrefix(hashed, "$2a$") || strings.HasPrefix(hashed, "$2b$") || strings.HasPrefix(hashed, "$2y$")
+}
+
+// encodeArgon2id formats an argon2id hash in the PHC string format of the reference implementation:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>, with unpadded standard base64.
+func encodeArgon2id(p Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.MemoryKiB, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2id(hashed string) (p Argon2Params, salt, key []byte, err error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != AlgorithmArgon2id {
+		return p, nil, nil, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.MemoryKiB, &p.Iterations, &p.Parallelism); err != nil ||
+		p.Iterations < 1 || p.Parallelism < 1 {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnknownHash
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,176 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2 keeps the tests fast; production parameters are far more expensive.
+var testArgon2 = Argon2Params{MemoryKiB: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func newTestHasher(t *testing.T, algorithm string, bcryptCost int, argon2Params Argon2Params) *Hasher {
+	t.Helper()
+	h, err := NewHasherWith(algorithm, bcryptCost, argon2Params, nil)
+	if err != nil {
+		t.Fatalf("NewHasherWith(%s) err = %v", algorithm, err)
+	}
+	return h
+}
+
+func TestHasher_HashAndCompare(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmBcrypt, AlgorithmArgon2id} {
+		h := newTestHasher(t, algorithm, bcrypt.MinCost, testArgon2)
+		hashed, version, err := h.Hash("correct horse")
+		if err != nil || version != 0 {
+			t.Fatalf("%s: Hash version = %d, err = %v", algorithm, version, err)
+		}
+		if algorithm == AlgorithmArgon2id && !strings.HasPrefix(hashed, "$argon2id$v=19$m=64,t=1,p=1$") {
+			t.Errorf("argon2id hash = %q, want the PHC format", hashed)
+		}
+		if err := h.Compare(hashed, 0, "correct horse"); err != nil {
+			t.Errorf("%s: Compare(right password) err = %v", algorithm, err)
+		}
+		if err := h.Compare(hashed, 0, "wrong horse"); !errors.Is(err, ErrMismatch) {
+			t.Errorf("%s: Compare(wrong password) err = %v, want ErrMismatch", algorithm, err)
+		}
+		if !h.IsHash(hashed) || h.NeedsRehash(hashed, 0) {
+			t.Errorf("%s: IsHash = %v, NeedsRehash = %v for its own hash", algorithm, h.IsHash(hashed), h.NeedsRehash(hashed, 0))
+		}
+	}
+}
+
+func TestHasher_VerifiesOtherAlgorithm(t *testing.T) {
+	bcryptHasher := newTestHasher(t, AlgorithmBcrypt, bcrypt.MinCost, testArgon2)
+	argon2Hasher := newTestHasher(t, AlgorithmArgon2id, bcrypt.MinCost, testArgon2)
+	old, _, _ := bcryptHasher.Hash("s3cret")
+	if err := argon2Hasher.Compare(old, 0, "s3cret"); err != nil {
+		t.Errorf("argon2id hasher Compare(bcrypt hash) err = %v", err)
+	}
+	if !argon2Hasher.NeedsRehash(old, 0) {
+		t.Error("argon2id hasher NeedsRehash(bcrypt hash) = false")
+	}
+	newer, _, _ := argon2Hasher.Hash("s3cret")
+	if err := bcryptHasher.Compare(newer, 0, "s3cret"); err != nil || !bcryptHasher.NeedsRehash(newer, 0) {
+		t.Errorf("bcrypt hasher on an argon2id hash: Compare err = %v, NeedsRehash = %v", err, bcryptHasher.NeedsRehash(newer, 0))
+	}
+}
+
+func TestHasher_NeedsRehashOnNewParameters(t *testing.T) {
+	hashed, _, _ := newTestHasher(t, AlgorithmBcrypt, bcrypt.MinCost, testArgon2).Hash("pw")
+	if !newTestHasher(t, AlgorithmBcrypt, bcrypt.MinCost+1, testArgon2).NeedsRehash(hashed, 0) {
+		t.Error("bcrypt hash of a lower cost does not need rehashing")
+	}
+
+	hashed, _, _ = newTestHasher(t, AlgorithmArgon2id, bcrypt.MinCost, testArgon2).Hash("pw")
+	stronger := testArgon2
+	stronger.Iterations = 2
+	if !newTestHasher(t, AlgorithmArgon2id, bcrypt.MinCost, stronger).NeedsRehash(hashed, 0) {
+		t.Error("argon2id hash with fewer iterations does not need rehashing")
+	}
+}
+
+func TestHasher_UnknownHash(t *testing.T) {
+	h := newTestHasher(t, AlgorithmArgon2id, bcrypt.MinCost, testArgon2)
+	for _, hashed := range []string{"", "plaintext", "$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5", "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5", "$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHQ$a2V5"} {
+		if err := h.Compare(hashed, 0, "pw"); !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("Compare(%q) err = %v, want ErrUnknownHash", hashed, err)
+		}
+		if h.IsHash(hashed) || !h.NeedsRehash(hashed, 0) {
+			t.Errorf("%q: IsHash = %v, NeedsRehash = %v", hashed, h.IsHash(hashed), h.NeedsRehash(hashed, 0))
+		}
+	}
+}
+
+func TestHasher_Peppers(t *testing.T) {
+	v1 := map[int][]byte{1: []byte("first-pepper-secret")}
+	rotated := map[int][]byte{1: v1[1], 2: []byte("second-pepper-secret")}
+	for _, algorithm := range []string{AlgorithmBcrypt, AlgorithmArgon2id} {
+		plain := newTestHasher(t, algorithm, bcrypt.MinCost, testArgon2)
+		h, err := NewHasherWith(algorithm, bcrypt.MinCost, testArgon2, v1)
+		if err != nil {
+			t.Fatalf("%s: NewHasherWith err = %v", algorithm, err)
+		}
+		hashed, version, err := h.Hash("pw")
+		if err != nil || version != 1 {
+			t.Fatalf("%s: Hash version = %d, err = %v, want version 1", algorithm, version, err)
+		}
+		if err := h.Compare(hashed, 1, "pw"); err != nil {
+			t.Errorf("%s: Compare(peppered) err = %v", algorithm, err)
+		}
+		if err := plain.Compare(hashed, 0, "pw"); !errors.Is(err, ErrMismatch) {
+			t.Errorf("%s: Compare without the pepper err = %v, want ErrMismatch", algorithm, err)
+		}
+		if err := plain.Compare(hashed, 1, "pw"); !errors.Is(err, ErrUnknownPepper) {
+			t.Errorf("%s: Compare with an unknown pepper err = %v, want ErrUnknownPepper", algorithm, err)
+		}
+
+		// Unpeppered hashes keep verifying and are upgraded; after a rotation so are version 1 hashes.
+		old, _, _ := plain.Hash("pw")
+		if err := h.Compare(old, 0, "pw"); err != nil || !h.NeedsRehash(old, 0) || h.NeedsRehash(hashed, 1) {
+			t.Errorf("%s: unpeppered hash: Compare err = %v, NeedsRehash = %v", algorithm, err, h.NeedsRehash(old, 0))
+		}
+		next, _ := NewHasherWith(algorithm, bcrypt.MinCost, testArgon2, rotated)
+		if err := next.Compare(hashed, 1, "pw"); err != nil || !next.NeedsRehash(hashed, 1) {
+			t.Errorf("%s: after rotation: Compare err = %v, NeedsRehash = %v", algorithm, err, next.NeedsRehash(hashed, 1))
+		}
+		if _, version, _ := next.Hash("pw"); version != 2 {
+			t.Errorf("%s: Hash after rotation version = %d, want 2", algorithm, version)
+		}
+	}
+}
+
+func TestParsePeppers(t *testing.T) {
+	peppers, err := ParsePeppers(" 1:AAECAwQFBgcICQoLDA0ODw==, 3:EBESExQVFhcYGRobHB0eHw== ")
+	if err != nil || len(peppers) != 2 || peppers[3][0] != 0x10 {
+		t.Fatalf("ParsePeppers = %v, %v", peppers, err)
+	}
+	if peppers, err := ParsePeppers(""); err != nil || len(peppers) != 0 {
+		t.Errorf("ParsePeppers(\"\") = %v, %v", peppers, err)
+	}
+	for _, bad := range []string{"AAECAwQFBgcICQoLDA0ODw==", "0:AAECAwQFBgcICQoLDA0ODw==", "1:c2hvcnQ=", "1:not base64",
+		"1:AAECAwQFBgcICQoLDA0ODw==,1:EBESExQVFhcYGRobHB0eHw=="} {
+		if _, err := ParsePeppers(bad); err == nil {
+			t.Errorf("ParsePeppers(%q) err = nil", bad)
+		}
+	}
+}
+
+func TestNewHasher(t *testing.T) {
+	cfg := &config.AppConfig{}
+	h, err := NewHasher(cfg)
+	if err != nil {
+		t.Fatalf("NewHasher(default) err = %v", err)
+	}
+	if hasher := h.(*Hasher); hasher.algorithm != AlgorithmBcrypt || hasher.bcryptCost != bcrypt.DefaultCost || hasher.argon2 != DefaultArgon2Params {
+		t.Errorf("default hasher = %+v", hasher)
+	}
+
+	cfg.Password.Algorithm = AlgorithmArgon2id
+	cfg.Password.Argon2MemoryKiB = 19456
+	cfg.Password.Argon2Iterations = 2
+	cfg.Password.Argon2Parallelism = 1
+	h, err = NewHasher(cfg)
+	if err != nil {
+		t.Fatalf("NewHasher(argon2id) err = %v", err)
+	}
+	if hasher := h.(*Hasher); hasher.algorithm != AlgorithmArgon2id || hasher.argon2.MemoryKiB != 19456 || hasher.argon2.Iterations != 2 || hasher.argon2.Parallelism != 1 {
+		t.Errorf("argon2id hasher = %+v", hasher)
+	}
+
+	for _, bad := range []func(*config.AppConfig){
+		func(c *config.AppConfig) { c.Password.Algorithm = "md5" },
+		func(c *config.AppConfig) { c.Password.BcryptCost = 40 },
+		func(c *config.AppConfig) { c.Password.Argon2Parallelism = 300 },
+		func(c *config.AppConfig) { c.Password.Peppers = "1:short" },
+	} {
+		cfg := &config.AppConfig{}
+		bad(cfg)
+		if _, err := NewHasher(cfg); err == nil {
+			t.Errorf("NewHasher(%+v) err = nil", cfg.Password)
+		}
+	}
+}
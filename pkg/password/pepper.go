@@ -0,0 +1,151 @@
+package password
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+)
+
+// IPepperSource loads the password peppers, by version.
+type IPepperSource interface {
+	Load(ctx context.Context) (map[int][]byte, error)
+}
+
+// NewPepperSource returns where AppConfig.Password says the peppers come from: PASSWORD_PEPPERS in
+// plaintext, a file a secrets manager mounts (PASSWORD_PEPPERS_FILE), or peppers wrapped by a key in a
+// Vault transit engine (PASSWORD_PEPPERS_WRAPPED with PASSWORD_PEPPERS_VAULT_*). At most one may be set;
+// with none there is no pepper.
+func NewPepperSource(cfg *config.AppConfig) (IPepperSource, error) {
+	p := cfg.Password
+	set := 0
+	for _, v := range []string{p.Peppers, p.PeppersFile, p.PeppersWrapped} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, errors.New("password: set only one of PASSWORD_PEPPERS, PASSWORD_PEPPERS_FILE and PASSWORD_PEPPERS_WRAPPED")
+	}
+	switch {
+	case p.PeppersFile != "":
+		return FilePepperSource{Path: p.PeppersFile}, nil
+	case p.PeppersWrapped != "":
+		if p.PeppersVaultAddr == "" || p.PeppersVaultKey == "" {
+			return nil, errors.New("password: PASSWORD_PEPPERS_WRAPPED needs PASSWORD_PEPPERS_VAULT_ADDR and PASSWORD_PEPPERS_VAULT_TRANSIT_KEY")
+		}
+		return NewVaultTransitPepperSource(p.PeppersVaultAddr, p.PeppersVaultToken, p.PeppersVaultKey, p.PeppersWrapped), nil
+	default:
+		return StaticPepperSource(p.Peppers), nil
+	}
+}
+
+// StaticPepperSource is peppers in the PASSWORD_PEPPERS format.
+type StaticPepperSource string
+
+func (s StaticPepperSource) Load(ctx context.Context) (map[int][]byte, error) {
+	return ParsePeppers(string(s))
+}
+
+// FilePepperSource reads peppers in the PASSWORD_PEPPERS format from a file, e.g. one a Kubernetes secret,
+// Docker secret or Vault agent mounts. Pairs may also be on separate lines.
+type FilePepperSource struct {
+	Path string
+}
+
+func (s FilePepperSource) Load(ctx context.Context) (map[int][]byte, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("password: read PASSWORD_PEPPERS_FILE: %w", err)
+	}
+	return ParsePeppers(strings.ReplaceAll(string(data), "\n", ","))
+}
+
+// VaultTransitPepperSource unwraps peppers encrypted by a key of a Vault (or OpenBao) transit engine, so only
+// ciphertexts are kept in the configuration and the key never leaves the KMS. Wrapped lists version:ciphertext
+// pairs, e.g. "1:vault:v1:...", where each ciphertext is the output of transit/encrypt for the base64 pepper.
+type VaultTransitPepperSource struct {
+	addr    string
+	token   string
+	key     string
+	wrapped string
+	client  *http.Client
+}
+
+func NewVaultTransitPepperSource(addr, token, key, wrapped string) *VaultTransitPepperSource {
+	return &VaultTransitPepperSource{
+		addr:    strings.TrimRight(addr, "/"),
+		token:   token,
+		key:     key,
+		wrapped: wrapped,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *VaultTransitPepperSource) Load(ctx context.Context) (map[int][]byte, error) {
+	var pairs []string
+	for _, pair := range strings.Split(s.wrapped, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		version, ciphertext, ok := strings.Cut(pair, ":")
+		v, err := strconv.Atoi(version)
+		if !ok || err != nil || v < 1 {
+			return nil, fmt.Errorf("password: PASSWORD_PEPPERS_WRAPPED entries must be version:ciphertext with a positive version")
+		}
+		plaintext, err := s.decrypt(ctx, ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("password: unwrap pepper version %d: %w", v, err)
+		}
+		pairs = append(pairs, version+":"+plaintext)
+	}
+	return ParsePeppers(strings.Join(pairs, ","))
+}
+
+// decrypt returns the base64 plaintext of ciphertext from transit/decrypt.
+func (s *VaultTransitPepperSource) decrypt(ctx context.Context, ciphertext string) (string, error) {
+	body, err := json.Marshal(map[string]string{"ciphertext": ciphertext})
+	if err != nil {
+		return "", err
+	}
+	endpoint := s.addr + "/v1/transit/decrypt/" + url.PathEscape(s.key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", s.token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transit decrypt returned %s", resp.Status)
+	}
+	var out struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	// transit returns the plaintext base64-encoded; the pepper itself is base64 too.
+	pepper, err := base64.StdEncoding.DecodeString(out.Data.Plaintext)
+	if err != nil {
+		return "", fmt.Errorf("transit plaintext is not base64: %w", err)
+	}
+	return strings.TrimSpace(string(pepper)), nil
+}
@@ -0,0 +1,107 @@
+package password
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/config"
+)
+
+func TestFilePepperSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peppers")
+	if err := os.WriteFile(path, []byte("1:AAECAwQFBgcICQoLDA0ODw==\n3:EBESExQVFhcYGRobHB0eHw==\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	peppers, err := FilePepperSource{Path: path}.Load(context.Background())
+	if err != nil || len(peppers) != 2 || peppers[3][0] != 0x10 {
+		t.Fatalf("Load = %v, %v", peppers, err)
+	}
+	if _, err := (FilePepperSource{Path: path + ".missing"}).Load(context.Background()); err == nil {
+		t.Error("Load(missing file) err = nil")
+	}
+}
+
+// fakeTransit decrypts "vault:v1:<base64 plaintext>" ciphertexts for the key "peppers" and token "root".
+func fakeTransit(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/transit/decrypt/peppers" || r.Header.Get("X-Vault-Token") != "root" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		var req struct {
+			Ciphertext string `json:"ciphertext"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		plaintext, ok := strings.CutPrefix(req.Ciphertext, "vault:v1:")
+		if !ok {
+			http.Error(w, "invalid ciphertext", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"plaintext": plaintext}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func wrap(pepper string) string {
+	return "vault:v1:" + base64.StdEncoding.EncodeToString([]byte(pepper))
+}
+
+func TestVaultTransitPepperSource(t *testing.T) {
+	srv := fakeTransit(t)
+	wrapped := "1:" + wrap("AAECAwQFBgcICQoLDA0ODw==") + ", 2:" + wrap("EBESExQVFhcYGRobHB0eHw==")
+	peppers, err := NewVaultTransitPepperSource(srv.URL+"/", "root", "peppers", wrapped).Load(context.Background())
+	if err != nil || len(peppers) != 2 || peppers[1][1] != 0x01 || peppers[2][0] != 0x10 {
+		t.Fatalf("Load = %v, %v", peppers, err)
+	}
+
+	for name, src := range map[string]*VaultTransitPepperSource{
+		"bad token":      NewVaultTransitPepperSource(srv.URL, "wrong", "peppers", wrapped),
+		"no version":     NewVaultTransitPepperSource(srv.URL, "root", "peppers", wrap("AAECAwQFBgcICQoLDA0ODw==")),
+		"short pepper":   NewVaultTransitPepperSource(srv.URL, "root", "peppers", "1:"+wrap("c2hvcnQ=")),
+		"bad ciphertext": NewVaultTransitPepperSource(srv.URL, "root", "peppers", "1:garbage"),
+	} {
+		if _, err := src.Load(context.Background()); err == nil {
+			t.Errorf("%s: Load err = nil", name)
+		}
+	}
+}
+
+func TestNewPepperSource(t *testing.T) {
+	srv := fakeTransit(t)
+	cfg := &config.AppConfig{}
+	cfg.Password.PeppersWrapped = "1:" + wrap("AAECAwQFBgcICQoLDA0ODw==")
+	cfg.Password.PeppersVaultAddr = srv.URL
+	cfg.Password.PeppersVaultToken = "root"
+	cfg.Password.PeppersVaultKey = "peppers"
+	h, err := NewHasher(cfg)
+	if err != nil {
+		t.Fatalf("NewHasher(wrapped) err = %v", err)
+	}
+	if _, version, _ := h.Hash("pw"); version != 1 {
+		t.Errorf("Hash version = %d, want 1", version)
+	}
+
+	for _, bad := range []func(*config.AppConfig){
+		func(c *config.AppConfig) { c.Password.Peppers = "1:AAECAwQFBgcICQoLDA0ODw==" },
+		func(c *config.AppConfig) { c.Password.PeppersVaultAddr = "" },
+		func(c *config.AppConfig) { c.Password.PeppersVaultKey = "other" },
+	} {
+		cfg := *cfg
+		bad(&cfg)
+		if _, err := NewHasher(&cfg); err == nil {
+			t.Errorf("NewHasher(%+v) err = nil", cfg.Password)
+		}
+	}
+}
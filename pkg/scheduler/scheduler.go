@@ -0,0 +1,146 @@
+// Package scheduler runs named background jobs at fixed intervals for the lifetime of the process.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/tracing"
+)
+
+// ErrInvalidJob is returned by Add for a job without a name, a positive interval or a Run func.
+var ErrInvalidJob = errors.New("scheduler: job needs a name, a positive interval and a run func")
+
+// Job is a task run every Interval. A run that is still going when the next tick fires delays that tick
+// instead of overlapping it, and each run's context is cancelled after Interval or when the scheduler stops.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// RunOnStart runs the job once immediately when the scheduler starts, instead of waiting one Interval.
+	RunOnStart bool
+	Run        func(ctx context.Context) error
+}
+
+// IScheduler runs registered jobs between Start and Stop.
+type IScheduler interface {
+	// Add registers job. Jobs added while the scheduler is running start right away.
+	Add(job Job) error
+	Start()
+	// Stop cancels running jobs and waits for them to return, or for ctx to be done.
+	Stop(ctx context.Context) error
+}
+
+type Scheduler struct {
+	logger logger.ILogger
+
+	mu      sync.Mutex
+	jobs    []Job
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+// New creates a stopped scheduler with no jobs.
+func New(logger logger.ILogger) *Scheduler {
+	return &Scheduler{logger: logger}
+}
+
+// NewScheduler is New for fx injection.
+func NewScheduler(logger logger.ILogger) IScheduler {
+	return New(logger)
+}
+
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Interval <= 0 || job.Run == nil {
+		return fmt.Errorf("%w: %q", ErrInvalidJob, job.Name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, job)
+	if s.ctx != nil {
+		s.launch(job)
+	}
+	return nil
+}
+
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx != nil {
+		return
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for _, job := range s.jobs {
+		s.launch(job)
+	}
+}
+
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if s.ctx == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	s.cancel()
+	s.ctx, s.cancel = nil, nil
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// launch starts job's loop; s.mu must be held.
+func (s *Scheduler) launch(job Job) {
+	ctx := s.ctx
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		if job.RunOnStart {
+			s.run(ctx, job)
+		}
+		ticker := time.NewTicker(job.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.run(ctx, job)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) run(parent context.Context, job Job) {
+	ctx, cancel := context.WithTimeout(parent, job.Interval)
+	defer cancel()
+	// Each run is its own trace root so the queries a job makes are traced too.
+	ctx, span := tracing.Start(ctx, "job."+job.Name)
+	defer span.End()
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			tracing.RecordError(span, fmt.Errorf("panic: %v", r))
+			s.logger.Error("Scheduled job panicked", "job", job.Name, "panic", r)
+		}
+	}()
+	if err := job.Run(ctx); err != nil {
+		tracing.RecordError(span, err)
+		s.logger.Error("Scheduled job failed", "job", job.Name, "duration", time.Since(start), "error", err)
+		return
+	}
+	s.logger.Debug("Scheduled job finished", "job", job.Name, "duration", time.Since(start))
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+)
+
+func TestAdd_invalidJob_returnsErrInvalidJob(t *testing.T) {
+	s := New(testutil.NewLogger())
+	for _, job := range []Job{
+		{Interval: time.Second, Run: func(context.Context) error { return nil }},
+		{Name: "no-interval", Run: func(context.Context) error { return nil }},
+		{Name: "no-run", Interval: time.Second},
+	} {
+		if err := s.Add(job); !errors.Is(err, ErrInvalidJob) {
+			t.Errorf("Add(%+v) err = %v, want ErrInvalidJob", job, err)
+		}
+	}
+}
+
+func TestStart_runsJobsUntilStop(t *testing.T) {
+	s := New(testutil.NewLogger())
+	var runs atomic.Int32
+	if err := s.Add(Job{Name: "tick", Interval: 5 * time.Millisecond, RunOnStart: true, Run: func(context.Context) error {
+		runs.Add(1)
+		return errors.New("failures are logged, not fatal")
+	}}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	s.Start()
+	deadline := time.Now().Add(time.Second)
+	for runs.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if runs.Load() < 3 {
+		t.Fatalf("job ran %d times, want at least 3", runs.Load())
+	}
+
+	stopped := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	if runs.Load() != stopped {
+		t.Errorf("job ran %d more times after Stop", runs.Load()-stopped)
+	}
+}
+
+func TestStop_cancelsRunningJob(t *testing.T) {
+	s := New(testutil.NewLogger())
+	started := make(chan struct{})
+	_ = s.Add(Job{Name: "slow", Interval: time.Hour, RunOnStart: true, Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	s.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+}
+
+func TestRun_recoversPanic(t *testing.T) {
+	s := New(testutil.NewLogger())
+	done := make(chan struct{})
+	_ = s.Add(Job{Name: "panics", Interval: time.Hour, RunOnStart: true, Run: func(context.Context) error {
+		defer close(done)
+		panic("boom")
+	}})
+	s.Start()
+	<-done
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+package sms
+
+import "context"
+
+// ISender delivers SMS text messages. to is an E.164 phone number.
+type ISender interface {
+	Send(ctx context.Context, to, body string) error
+}
@@ -0,0 +1,94 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+)
+
+const (
+	ProviderTwilio = "twilio"
+
+	twilioBaseURL = "https://api.twilio.com/2010-04-01"
+)
+
+// NewSender returns the SMS provider selected by AppConfig.SMS.Provider (env: SMS_PROVIDER).
+// Without a provider, messages are dropped with a warning so local setups run without one.
+func NewSender(cfg *config.AppConfig, logger logger.ILogger) (ISender, error) {
+	switch cfg.SMS.Provider {
+	case "":
+		logger.Warn("SMS_PROVIDER is not set; outgoing SMS will be dropped")
+		return &noopSender{logger: logger}, nil
+	case ProviderTwilio:
+		if cfg.SMS.TwilioAccountSID == "" || cfg.SMS.TwilioAuthToken == "" || cfg.SMS.From == "" {
+			return nil, fmt.Errorf("sms: twilio requires TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and SMS_FROM")
+		}
+		return NewTwilioSender(cfg.SMS.TwilioAccountSID, cfg.SMS.TwilioAuthToken, cfg.SMS.From), nil
+	default:
+		return nil, fmt.Errorf("sms: unknown provider %q", cfg.SMS.Provider)
+	}
+}
+
+// TwilioSender sends messages through the Twilio Messages API.
+type TwilioSender struct {
+	baseURL    string
+	accountSID string
+	authToken  string
+	from       string
+	client     *http.Client
+}
+
+func NewTwilioSender(accountSID, authToken, from string) *TwilioSender {
+	return &TwilioSender{
+		baseURL:    twilioBaseURL,
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *TwilioSender) Send(ctx context.Context, to, body string) error {
+	form := url.Values{"To": {to}, "From": {s.from}, "Body": {body}}
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", s.baseURL, url.PathEscape(s.accountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.accountSID, s.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sms: twilio request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		_ = json.Unmarshal(data, &apiErr)
+		return fmt.Errorf("sms: twilio returned %d: %d %s", resp.StatusCode, apiErr.Code, apiErr.Message)
+	}
+	return nil
+}
+
+// noopSender drops messages. The body is never logged, since it carries one-time codes.
+type noopSender struct {
+	logger logger.ILogger
+}
+
+func (s *noopSender) Send(ctx context.Context, to, body string) error {
+	s.logger.Warn("SMS dropped: no provider configured", "to", to)
+	return nil
+}
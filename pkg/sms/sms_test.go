@@ -0,0 +1,49 @@
+package sms
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTwilioSender_Send(t *testing.T) {
+	var gotPath, gotUser, gotPass string
+	var gotForm map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotUser, gotPass, _ = r.BasicAuth()
+		_ = r.ParseForm()
+		gotForm = r.PostForm
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	s := NewTwilioSender("AC123", "secret", "+15550000000")
+	s.baseURL = srv.URL
+	if err := s.Send(context.Background(), "+15551234567", "Your code is 123456"); err != nil {
+		t.Fatalf("Send() err = %v", err)
+	}
+	if gotPath != "/Accounts/AC123/Messages.json" || gotUser != "AC123" || gotPass != "secret" {
+		t.Errorf("path = %q, auth = %q:%q", gotPath, gotUser, gotPass)
+	}
+	if gotForm["To"][0] != "+15551234567" || gotForm["From"][0] != "+15550000000" || gotForm["Body"][0] != "Your code is 123456" {
+		t.Errorf("form = %v", gotForm)
+	}
+}
+
+func TestTwilioSender_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"code": 21211, "message": "Invalid 'To' Phone Number"}`))
+	}))
+	defer srv.Close()
+
+	s := NewTwilioSender("AC123", "secret", "+15550000000")
+	s.baseURL = srv.URL
+	err := s.Send(context.Background(), "+1", "x")
+	if err == nil || !strings.Contains(err.Error(), "21211") {
+		t.Errorf("Send() err = %v, want the Twilio error code", err)
+	}
+}
@@ -0,0 +1,135 @@
+package statetoken
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+)
+
+var (
+	ErrInvalidSecret = errors.New("statetoken: secret is required")
+	ErrInvalidToken  = errors.New("statetoken: invalid token")
+	ErrExpiredToken  = errors.New("statetoken: token expired")
+)
+
+// ISealer seals values into opaque, tamper-proof tokens and opens them again.
+type ISealer interface {
+	Seal(value any, ttl time.Duration) (string, error)
+	Open(token string, value any) error
+	// Bind returns an HMAC of parts under the secret, to seal into a token and compare when it comes back,
+	// e.g. to tie a state to the client it was issued to without storing the client's details.
+	Bind(parts ...string) string
+}
+
+// Sealer encrypts and authenticates values with AES-256-GCM.
+// Any instance configured with the same secret can open tokens sealed by another,
+// so no shared storage is needed between replicas or regions.
+type Sealer struct {
+	aead    cipher.AEAD
+	bindKey []byte
+}
+
+type envelope struct {
+	ExpiresAt int64           `json:"exp"` // 0 means the token never expires
+	Data      json.RawMessage `json:"data"`
+}
+
+// New creates a Sealer whose key is derived from secret with SHA-256.
+func New(secret string) (*Sealer, error) {
+	if secret == "" {
+		return nil, ErrInvalidSecret
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// A key of its own, so a binding reveals nothing about the encryption key.
+	bindKey := sha256.Sum256([]byte("statetoken-bind:" + secret))
+	return &Sealer{aead: aead, bindKey: bindKey[:]}, nil
+}
+
+// NewSealerFromConfig creates a Sealer from OAUTH_STATE_SECRET, which is required. The key is never
+// derived from the JWT private key: that key is shared with whoever holds the signing material, and an
+// encryption key must not depend on it.
+func NewSealerFromConfig(cfg *config.AppConfig) (ISealer, error) {
+	if cfg.OAuth.StateSecret == "" {
+		return nil, fmt.Errorf("%w: set OAUTH_STATE_SECRET", ErrInvalidSecret)
+	}
+	return New(cfg.OAuth.StateSecret)
+}
+
+// Seal JSON-encodes value, attaches an expiry and returns a URL-safe encrypted token.
+// A ttl of 0 seals a value that never expires, e.g. a secret encrypted at rest.
+func (s *Sealer) Seal(value any, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	var expiresAt int64
+	if ttl != 0 {
+		expiresAt = time.Now().Add(ttl).Unix()
+	}
+	plain, err := json.Marshal(envelope{ExpiresAt: expiresAt, Data: data})
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, plain, nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Bind returns the unpadded base64url HMAC-SHA256 of parts, each length-prefixed so no two lists collide.
+func (s *Sealer) Bind(parts ...string) string {
+	mac := hmac.New(sha256.New, s.bindKey)
+	for _, part := range parts {
+		_ = binary.Write(mac, binary.BigEndian, uint32(len(part)))
+		mac.Write([]byte(part))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Open decrypts token into value. Returns ErrInvalidToken if the token was tampered with
+// or sealed with another secret, and ErrExpiredToken once its TTL has passed.
+func (s *Sealer) Open(token string, value any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	nonceSize := s.aead.NonceSize()
+	if len(raw) < nonceSize {
+		return ErrInvalidToken
+	}
+	plain, err := s.aead.Open(nil, raw[:nonceSize], raw[nonceSize:], nil)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	var env envelope
+	if err := json.Unmarshal(plain, &env); err != nil {
+		return ErrInvalidToken
+	}
+	if env.ExpiresAt != 0 && time.Now().Unix() > env.ExpiresAt {
+		return ErrExpiredToken
+	}
+	if err := json.Unmarshal(env.Data, value); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Package tracing sets up OpenTelemetry and gives the rest of the code one tracer to start spans with,
+// so a request can be followed from the HTTP handler through services, Postgres and Redis.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName identifies the spans this service creates.
+const InstrumentationName = "github.com/hiamthach108/dreon-auth"
+
+// Tracer returns the service tracer from the global provider; it is a no-op until a provider is installed.
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// Start starts a span named name, as a child of the span in ctx if there is one.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// RecordError marks span as failed with err. A nil err is ignored.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// IProvider flushes and stops span export on shutdown.
+type IProvider interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Provider wraps the SDK tracer provider; a zero Provider (tracing disabled) shuts down as a no-op.
+type Provider struct {
+	tp *sdktrace.TracerProvider
+}
+
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.tp == nil {
+		return nil
+	}
+	return p.tp.Shutdown(ctx)
+}
+
+// NewProviderFromConfig installs the global tracer provider described by AppConfig.Tracing.
+// W3C trace context is always propagated, so callers' traces continue through this service even when
+// OTEL_EXPORTER_OTLP_ENDPOINT is unset and no spans are exported.
+func NewProviderFromConfig(cfg *config.AppConfig, logger logger.ILogger) (IProvider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	if cfg.Tracing.Endpoint == "" {
+		logger.Info("OTEL_EXPORTER_OTLP_ENDPOINT is not set; traces will not be exported")
+		return &Provider{}, nil
+	}
+
+	opts := []otlptracegrpc.Option{}
+	if strings.Contains(cfg.Tracing.Endpoint, "://") {
+		opts = append(opts, otlptracegrpc.WithEndpointURL(cfg.Tracing.Endpoint))
+	} else {
+		opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Tracing.Endpoint))
+	}
+	if cfg.Tracing.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	// The exporter connects lazily, so a collector that is down does not block startup.
+	exporter, err := otlptracegrpc.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: create OTLP exporter: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(newResource(cfg)),
+		sdktrace.WithSampler(newSampler(cfg.Tracing.SampleRatio)),
+	)
+	otel.SetTracerProvider(tp)
+	logger.Info("Exporting traces", "endpoint", cfg.Tracing.Endpoint)
+	return &Provider{tp: tp}, nil
+}
+
+func newResource(cfg *config.AppConfig) *resource.Resource {
+	name := cfg.App.Name
+	if name == "" {
+		name = "dreon-auth"
+	}
+	attrs := []attribute.KeyValue{attribute.String("service.name", name)}
+	if cfg.App.Version != "" {
+		attrs = append(attrs, attribute.String("service.version", cfg.App.Version))
+	}
+	if cfg.App.Env != "" {
+		attrs = append(attrs, attribute.String("deployment.environment.name", cfg.App.Env))
+	}
+	return resource.NewSchemaless(attrs...)
+}
+
+// newSampler keeps the caller's sampling decision and samples ratio of the traces that start here.
+// A ratio outside (0, 1] records every trace.
+func newSampler(ratio float64) sdktrace.Sampler {
+	if ratio <= 0 || ratio >= 1 {
+		return sdktrace.ParentBased(sdktrace.AlwaysSample())
+	}
+	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+}
@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any)         {}
+func (nopLogger) Info(string, ...any)          {}
+func (nopLogger) Warn(string, ...any)          {}
+func (nopLogger) Error(string, ...any)         {}
+func (nopLogger) Fatal(string, ...any)         {}
+func (l nopLogger) With(...any) logger.ILogger { return l }
+func (nopLogger) GetZapLogger() *zap.Logger    { return zap.NewNop() }
+
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestStart_NestsUnderParent(t *testing.T) {
+	recorder := useRecorder(t)
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	child.End()
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ended spans = %d, want 2", len(spans))
+	}
+	if spans[0].Name() != "child" || spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Errorf("child span %q has parent %s, want %s", spans[0].Name(), spans[0].Parent().SpanID(), spans[1].SpanContext().SpanID())
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	recorder := useRecorder(t)
+
+	_, ok := Start(context.Background(), "ok")
+	RecordError(ok, nil)
+	ok.End()
+	_, failed := Start(context.Background(), "failed")
+	RecordError(failed, errors.New("boom"))
+	failed.End()
+
+	spans := recorder.Ended()
+	if spans[0].Status().Code != codes.Unset {
+		t.Errorf("nil error set status %v", spans[0].Status())
+	}
+	if spans[1].Status().Code != codes.Error || spans[1].Status().Description != "boom" || len(spans[1].Events()) != 1 {
+		t.Errorf("failed span status = %v, events = %d", spans[1].Status(), len(spans[1].Events()))
+	}
+}
+
+func TestNewSampler(t *testing.T) {
+	tests := []struct {
+		name  string
+		ratio float64
+		want  string
+	}{
+		{name: "unset records everything", ratio: 0, want: "ParentBased{root:AlwaysOnSampler"},
+		{name: "one records everything", ratio: 1, want: "ParentBased{root:AlwaysOnSampler"},
+		{name: "fraction", ratio: 0.25, want: "ParentBased{root:TraceIDRatioBased{0.25}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newSampler(tt.ratio).Description()
+			if len(got) < len(tt.want) || got[:len(tt.want)] != tt.want {
+				t.Errorf("newSampler(%v) = %s, want prefix %s", tt.ratio, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewProviderFromConfig_WithoutEndpoint(t *testing.T) {
+	provider, err := NewProviderFromConfig(&config.AppConfig{}, nopLogger{})
+	if err != nil {
+		t.Fatalf("NewProviderFromConfig: %v", err)
+	}
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	if fields := otel.GetTextMapPropagator().Fields(); len(fields) == 0 {
+		t.Error("no propagator installed")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/hiamthach108/dreon-auth/internal/aggregate"
 	"github.com/hiamthach108/dreon-auth/internal/errorx"
 	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
 	"github.com/hiamthach108/dreon-auth/pkg/logger"
 	authinternal "github.com/hiamthach108/dreon-auth/presentation/grpc/gen/proto"
 	"go.uber.org/fx"
@@ -142,7 +143,8 @@ func NewGRPCServer(
 	}
 }
 
-// RegisterHooks registers the gRPC server with fx lifecycle (start listening on GRPC_PORT).
+// RegisterHooks registers the gRPC server with fx lifecycle (start listening on GRPC_PORT). On stop it lets
+// in-flight calls finish for up to SHUTDOWN_TIMEOUT_SEC before cutting them off.
 func RegisterHooks(lc fx.Lifecycle, srv *GRPCServer) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
@@ -164,7 +166,11 @@ func RegisterHooks(lc fx.Lifecycle, srv *GRPCServer) {
 			return nil
 		},
 		OnStop: func(ctx context.Context) error {
-			srv.logger.Info("Shutting down gRPC server...")
+			timeout := constant.DefaultShutdownTimeout
+			if srv.config.Server.ShutdownTimeoutSec > 0 {
+				timeout = time.Duration(srv.config.Server.ShutdownTimeoutSec) * time.Second
+			}
+			srv.logger.Info("Shutting down gRPC server...", "timeout", timeout)
 			stopped := make(chan struct{})
 			go func() {
 				srv.server.GracefulStop()
@@ -174,7 +180,8 @@ func RegisterHooks(lc fx.Lifecycle, srv *GRPCServer) {
 			case <-ctx.Done():
 				srv.server.Stop()
 			case <-stopped:
-			case <-time.After(5 * time.Second):
+			case <-time.After(timeout):
+				srv.logger.Warn("Stopping gRPC server with calls still in flight")
 				srv.server.Stop()
 			}
 			return nil
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	echomw "github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// AccessPolicyHandler handles HTTP requests for a project's network access policy.
+type AccessPolicyHandler struct {
+	accessPolicySvc service.IAccessPolicySvc
+	logger          logger.ILogger
+	verifyJWT       echomw.VerifyJWTMiddleware
+	authorize       echomw.AuthorizeMiddleware
+}
+
+// NewAccessPolicyHandler creates a new access policy handler.
+func NewAccessPolicyHandler(accessPolicySvc service.IAccessPolicySvc, logger logger.ILogger, verifyJWT echomw.VerifyJWTMiddleware, authorize echomw.AuthorizeMiddleware) *AccessPolicyHandler {
+	return &AccessPolicyHandler{
+		accessPolicySvc: accessPolicySvc,
+		logger:          logger,
+		verifyJWT:       verifyJWT,
+		authorize:       authorize,
+	}
+}
+
+// RegisterRoutes registers policy routes on a group mounted at /projects/:id/access-policy.
+func (h *AccessPolicyHandler) RegisterRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
+	g.GET("", h.HandleGetPolicy)
+	g.PUT("", h.HandleUpsertPolicy)
+	g.DELETE("", h.HandleDeletePolicy)
+	g.GET("/denials", h.HandleListDenials)
+}
+
+// HandleGetPolicy returns the project's access policy.
+func (h *AccessPolicyHandler) HandleGetPolicy(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.accessPolicySvc.GetPolicy(ctx, c.Param("id"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleUpsertPolicy creates or replaces the project's access policy.
+func (h *AccessPolicyHandler) HandleUpsertPolicy(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.UpsertAccessPolicyReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.accessPolicySvc.UpsertPolicy(ctx, c.Param("id"), req)
+	if err != nil {
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to save access policy", "projectID", c.Param("id"), "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleDeletePolicy removes the project's access policy.
+func (h *AccessPolicyHandler) HandleDeletePolicy(c echo.Context) error {
+	ctx := c.Request().Context()
+	if err := h.accessPolicySvc.DeletePolicy(ctx, c.Param("id")); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
+
+// HandleListDenials returns the audit log of requests the policy rejected, newest first.
+// Query: page (default 1), pageSize (default 10, max 100).
+func (h *AccessPolicyHandler) HandleListDenials(c echo.Context) error {
+	ctx := c.Request().Context()
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	pageSize, _ := strconv.Atoi(c.QueryParam("pageSize"))
+
+	result, err := h.accessPolicySvc.ListDenials(ctx, c.Param("id"), page, pageSize)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
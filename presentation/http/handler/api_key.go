@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type APIKeyHandler struct {
+	apiKeySvc service.IAPIKeySvc
+	logger    logger.ILogger
+	verifyJWT middleware.VerifyJWTMiddleware
+	authorize middleware.AuthorizeMiddleware
+}
+
+func NewAPIKeyHandler(
+	apiKeySvc service.IAPIKeySvc,
+	logger logger.ILogger,
+	verifyJWT middleware.VerifyJWTMiddleware,
+	authorize middleware.AuthorizeMiddleware,
+) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeySvc: apiKeySvc,
+		logger:    logger,
+		verifyJWT: verifyJWT,
+		authorize: authorize,
+	}
+}
+
+// RegisterRoutes registers API key management on a group mounted at /projects/:id/api-keys.
+func (h *APIKeyHandler) RegisterRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
+	g.GET("", h.HandleListKeys)
+	g.POST("", h.HandleCreateKey)
+	g.DELETE("/:keyId", h.HandleRevokeKey)
+}
+
+// HandleListKeys lists the project's API keys; secrets are never returned.
+func (h *APIKeyHandler) HandleListKeys(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.apiKeySvc.List(ctx, c.Param("id"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleCreateKey mints an API key for the project. The full key is only in this response.
+func (h *APIKeyHandler) HandleCreateKey(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.CreateAPIKeyReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	result, err := h.apiKeySvc.Create(ctx, c.Param("id"), req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleRevokeKey revokes an API key; requests presenting it fail from then on.
+func (h *APIKeyHandler) HandleRevokeKey(c echo.Context) error {
+	ctx := c.Request().Context()
+	if err := h.apiKeySvc.Revoke(ctx, c.Param("id"), c.Param("keyId")); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
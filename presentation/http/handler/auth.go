@@ -6,6 +6,8 @@ import (
 	"github.com/hiamthach108/dreon-auth/internal/aggregate"
 	"github.com/hiamthach108/dreon-auth/internal/errorx"
 	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/oidc"
 	"github.com/hiamthach108/dreon-auth/pkg/logger"
 	"github.com/hiamthach108/dreon-auth/presentation/http/middleware"
 	"github.com/labstack/echo/v4"
@@ -15,26 +17,44 @@ type AuthHandler struct {
 	authSvc   service.IAuthSvc
 	logger    logger.ILogger
 	verifyJWT middleware.VerifyJWTMiddleware
+	rateLimit *middleware.RateLimitMiddleware
 }
 
-func NewAuthHandler(authSvc service.IAuthSvc, logger logger.ILogger, verifyJWT middleware.VerifyJWTMiddleware) *AuthHandler {
+func NewAuthHandler(authSvc service.IAuthSvc, logger logger.ILogger, verifyJWT middleware.VerifyJWTMiddleware, rateLimit *middleware.RateLimitMiddleware) *AuthHandler {
 	return &AuthHandler{
 		authSvc:   authSvc,
 		logger:    logger,
 		verifyJWT: verifyJWT,
+		rateLimit: rateLimit,
 	}
 }
 
 func (h *AuthHandler) RegisterRoutes(g *echo.Group) {
-	g.POST("/login", h.HandleLogin)
-	g.POST("/register", h.HandleRegister)
+	// Login also sends the magic links and phone OTPs, so its limit caps those too
+	g.POST("/login", h.HandleLogin, h.rateLimit.Limit(constant.RateLimitRouteLogin))
+	g.POST("/register", h.HandleRegister, h.rateLimit.Limit(constant.RateLimitRouteRegister))
 	g.POST("/refresh-token", h.HandleRefreshToken)
 	g.POST("/logout", h.HandleLogout)
 	g.GET("/google/callback", h.HandleGoogleOAuthCallback)
+	g.GET("/oidc/:provider/callback", h.HandleOIDCCallback)
 	g.POST("/session-from-state", h.HandleSessionFromState)
+	g.GET("/end-session", h.HandleEndSession)
+	g.POST("/end-session", h.HandleEndSession)
+	g.POST("/mfa/verify", h.HandleVerifyTOTP, h.rateLimit.Limit(constant.RateLimitRouteOTP))
+	g.POST("/magic-link/verify", h.HandleVerifyMagicLink, h.rateLimit.Limit(constant.RateLimitRouteOTP))
+	g.POST("/otp/verify", h.HandleVerifyPhoneOTP, h.rateLimit.Limit(constant.RateLimitRouteOTP))
+	g.POST("/device/code", h.HandleDeviceCode, h.rateLimit.Limit(constant.RateLimitRouteLogin))
+	g.POST("/device/token", h.HandleDeviceToken)
 
 	g.Use(echo.MiddlewareFunc(h.verifyJWT))
 	g.GET("/session", h.HandleGetSession)
+	g.POST("/revoke", h.HandleRevokeToken)
+	g.POST("/mfa/totp/enable", h.HandleEnableTOTP)
+	g.POST("/mfa/totp/confirm", h.HandleVerifyTOTP)
+	g.POST("/mfa/totp/disable", h.HandleDisableTOTP)
+	g.GET("/device", h.HandleGetDeviceAuthorization)
+	// Guessing user codes needs many tries, so verification shares the OTP limit
+	g.POST("/device/verify", h.HandleCompleteDeviceAuthorization, h.rateLimit.Limit(constant.RateLimitRouteOTP))
 }
 
 func (h *AuthHandler) HandleLogin(c echo.Context) error {
@@ -95,11 +115,16 @@ func (h *AuthHandler) HandleLogout(c echo.Context) error {
 
 func (h *AuthHandler) HandleGetSession(c echo.Context) error {
 	ctx := c.Request().Context()
-	payload := middleware.GetJWTPayload(ctx)
-	if payload == nil {
-		return HandleError(c, errorx.New(errorx.ErrUnauthorized, "missing payload"))
+	req, err := HandleValidateBind[aggregate.GetSessionReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.authSvc.GetSession(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
 	}
-	return HandleSuccess(c, payload)
+	return HandleSuccess(c, result)
 }
 
 func (h *AuthHandler) HandleGoogleOAuthCallback(c echo.Context) error {
@@ -113,6 +138,17 @@ func (h *AuthHandler) HandleGoogleOAuthCallback(c echo.Context) error {
 	return c.Redirect(http.StatusFound, redirectURL)
 }
 
+func (h *AuthHandler) HandleOIDCCallback(c echo.Context) error {
+	ctx := c.Request().Context()
+	code := c.QueryParam("code")
+	state := c.QueryParam("state")
+	redirectURL, err := h.authSvc.ExchangeOIDCCode(ctx, c.Param("provider"), code, state)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.Redirect(http.StatusFound, redirectURL)
+}
+
 func (h *AuthHandler) HandleSessionFromState(c echo.Context) error {
 	ctx := c.Request().Context()
 	req, err := HandleValidateBind[aggregate.SessionFromStateReq](c)
@@ -125,3 +161,178 @@ func (h *AuthHandler) HandleSessionFromState(c echo.Context) error {
 	}
 	return HandleSuccess(c, result)
 }
+
+func (h *AuthHandler) HandleEndSession(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.EndSessionReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	redirectURL, err := h.authSvc.EndSession(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	if redirectURL != "" {
+		return c.Redirect(http.StatusFound, redirectURL)
+	}
+	return HandleSuccess(c, nil)
+}
+
+// HandleEnableTOTP starts TOTP enrollment and returns the secret and otpauth URL (requires JWT).
+func (h *AuthHandler) HandleEnableTOTP(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.EnableTOTPReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.authSvc.EnableTOTP(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleVerifyTOTP serves both /mfa/verify (login challenge, with mfaToken) and
+// /mfa/totp/confirm (enrollment confirmation, with JWT).
+func (h *AuthHandler) HandleVerifyTOTP(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.VerifyTOTPReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.authSvc.VerifyTOTP(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleVerifyMagicLink exchanges an emailed sign-in token for tokens, or an MFA challenge when TOTP is enabled.
+func (h *AuthHandler) HandleVerifyMagicLink(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.VerifyMagicLinkReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.authSvc.VerifyMagicLink(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleVerifyPhoneOTP exchanges an SMS sign-in code for tokens, or an MFA challenge when TOTP is enabled.
+func (h *AuthHandler) HandleVerifyPhoneOTP(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.VerifyPhoneOTPReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.authSvc.VerifyPhoneOTP(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleRevokeToken blacklists an access token until it expires (requires JWT).
+// Body: { "token": "..." }; omit token to revoke the one used for this request.
+func (h *AuthHandler) HandleRevokeToken(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.RevokeTokenReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	if err := h.authSvc.RevokeToken(ctx, req); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
+
+// HandleDisableTOTP turns TOTP off after checking a current code (requires JWT).
+func (h *AuthHandler) HandleDisableTOTP(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.DisableTOTPReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	if err := h.authSvc.DisableTOTP(ctx, req); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
+
+// HandleDeviceCode starts the device authorization grant (RFC 8628) for a registered client. Form body
+// with client_id and optional scope; errors use the OAuth 2.0 format.
+func (h *AuthHandler) HandleDeviceCode(c echo.Context) error {
+	ctx := c.Request().Context()
+	var req aggregate.DeviceCodeReq
+	if err := c.Bind(&req); err != nil {
+		return oauthTokenError(c, h.logger, oidc.NewError(oidc.ErrCodeInvalidRequest, "malformed device authorization request"))
+	}
+	if id, secret, ok := basicClientAuth(c); ok {
+		req.ClientID, req.ClientSecret = id, secret
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	result, err := h.authSvc.StartDeviceAuthorization(ctx, req)
+	if err != nil {
+		return oauthTokenError(c, h.logger, err)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// HandleDeviceToken is polled by the device with grant_type=urn:ietf:params:oauth:grant-type:device_code
+// until the user approves or denies it. Errors use the OAuth 2.0 format.
+func (h *AuthHandler) HandleDeviceToken(c echo.Context) error {
+	ctx := c.Request().Context()
+	var req aggregate.OIDCTokenReq
+	if err := c.Bind(&req); err != nil {
+		return oauthTokenError(c, h.logger, oidc.NewError(oidc.ErrCodeInvalidRequest, "malformed token request"))
+	}
+	if id, secret, ok := basicClientAuth(c); ok {
+		req.ClientID, req.ClientSecret = id, secret
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	result, err := h.authSvc.DeviceToken(ctx, req)
+	if err != nil {
+		return oauthTokenError(c, h.logger, err)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// HandleGetDeviceAuthorization shows the verification page which client a user code belongs to (requires JWT).
+func (h *AuthHandler) HandleGetDeviceAuthorization(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.GetDeviceAuthorizationReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.authSvc.GetDeviceAuthorization(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleCompleteDeviceAuthorization approves or denies a device's sign-in for the caller (requires JWT).
+// Body: { "userCode": "BDFG-HJKL", "deny": false }.
+func (h *AuthHandler) HandleCompleteDeviceAuthorization(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.CompleteDeviceAuthorizationReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	if err := h.authSvc.CompleteDeviceAuthorization(ctx, req); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
@@ -0,0 +1,1279 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/internal/shared/oidc"
+	"github.com/hiamthach108/dreon-auth/internal/shared/totp"
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/breach"
+	"github.com/hiamthach108/dreon-auth/pkg/captcha"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/password"
+	"github.com/hiamthach108/dreon-auth/pkg/statetoken"
+)
+
+func TestAuthHandler_RefreshTokenStoredHashed(t *testing.T) {
+	h := apitest.New(t)
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "hal@example.com", Password: "password123"}, "")
+	var tokens aggregate.TokenResp
+	apitest.Decode(t, resp, &tokens)
+
+	stored := h.Sessions.FindOneById(context.Background(), tokens.SessionID)
+	if stored == nil || stored.RefreshTokenHash != helper.HashRefreshToken(tokens.RefreshToken) {
+		t.Fatalf("stored session = %+v, want the refresh token's digest", stored)
+	}
+
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/refresh-token", aggregate.RefreshTokenReq{RefreshToken: tokens.RefreshToken}, "")
+	var refreshed aggregate.TokenResp
+	apitest.Decode(t, resp, &refreshed)
+	if resp.StatusCode != http.StatusOK || refreshed.AccessToken == "" {
+		t.Fatalf("refresh status = %d, resp = %+v", resp.StatusCode, refreshed)
+	}
+	// The digest itself is not a usable refresh token.
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/refresh-token", aggregate.RefreshTokenReq{RefreshToken: stored.RefreshTokenHash}, "")
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Error("refreshing with the stored digest succeeded")
+	}
+}
+
+func TestAuthHandler_AccessPolicyDeniesLogin(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) { cfg.Server.TrustedProxyCIDRs = "" }))
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	project, err := h.Projects.Create(context.Background(), &model.Project{Code: "corp", Name: "Corp"})
+	if err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{
+		Email:    "carol@example.com",
+		Password: "password123",
+	}, "")
+	resp.Body.Close()
+
+	policyPath := "/api/v1/projects/" + project.ID + "/access-policy"
+	resp = h.Do(t, http.MethodPut, policyPath, aggregate.UpsertAccessPolicyReq{AllowedCIDRs: []string{"10.0.0.0/8"}}, admin)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("upsert policy status = %d, want 200", resp.StatusCode)
+	}
+
+	login := aggregate.LoginReq{AuthType: "EMAIL", Email: "carol@example.com", Password: "password123", ProjectID: project.ID}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("login outside allowed network status = %d, want 403", resp.StatusCode)
+	}
+	deniedRequestID := resp.Header.Get("X-Request-Id")
+
+	resp = h.Do(t, http.MethodGet, policyPath+"/denials", nil, admin)
+	var denials aggregate.PaginationResp[aggregate.AccessDenialResp]
+	apitest.Decode(t, resp, &denials)
+	if denials.Total != 1 || denials.Items[0].Reason != "IP_NOT_ALLOWED" || denials.Items[0].Stage != "LOGIN" {
+		t.Errorf("denials = %+v, want one LOGIN/IP_NOT_ALLOWED record", denials)
+	}
+	if denials.Total == 1 && (deniedRequestID == "" || denials.Items[0].RequestID != deniedRequestID) {
+		t.Errorf("denial requestId = %q, want the login's %q", denials.Items[0].RequestID, deniedRequestID)
+	}
+
+	// Claiming an allowed address in X-Forwarded-For does not help when no proxy is trusted.
+	body, _ := json.Marshal(login)
+	req, _ := http.NewRequest(http.MethodPost, h.Server.URL+"/api/v1/auth/login", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", "10.1.2.3")
+	resp, err = h.Server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("login with forged X-Forwarded-For status = %d, want 403", resp.StatusCode)
+	}
+
+	// Leaving projectId out does not skip the policy, and neither does finishing the login at a provider.
+	projectless := login
+	projectless.ProjectID = ""
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", projectless, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("login without projectId status = %d, want 403", resp.StatusCode)
+	}
+	sealer, err := statetoken.NewSealerFromConfig(h.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, _ := sealer.Seal(aggregate.OAuthRefreshState{
+		ID:        uuid.NewString(),
+		AuthType:  constant.UserAuthTypeSAML,
+		Provider:  "saml:" + project.ID,
+		UserData:  aggregate.OAuthUserData{Email: "carol@example.com", ProviderID: "carol"},
+		ProjectID: project.ID,
+	}, time.Minute)
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/session-from-state", aggregate.SessionFromStateReq{RefreshState: state}, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("external login outside allowed network status = %d, want 403", resp.StatusCode)
+	}
+	// The refreshState carried the project, so the denial is the project's.
+	denials = aggregate.PaginationResp[aggregate.AccessDenialResp]{}
+	apitest.Decode(t, h.Do(t, http.MethodGet, policyPath+"/denials", nil, admin), &denials)
+	if denials.Total != 3 || denials.Items[0].Email != "carol@example.com" {
+		t.Errorf("denials after the external login = %+v, want a third record", denials)
+	}
+
+	resp = h.Do(t, http.MethodPut, policyPath, aggregate.UpsertAccessPolicyReq{AllowedCIDRs: []string{"127.0.0.0/8", "::1/128"}}, admin)
+	resp.Body.Close()
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("login inside allowed network status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestAuthHandler_TOTPLoginChallenge(t *testing.T) {
+	h := apitest.New(t)
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "dana@example.com", Password: "password123"}, "")
+	var tokens aggregate.TokenResp
+	apitest.Decode(t, resp, &tokens)
+
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/mfa/totp/enable", aggregate.EnableTOTPReq{}, tokens.AccessToken)
+	var enrollment aggregate.EnableTOTPResp
+	if body := apitest.Decode(t, resp, &enrollment); resp.StatusCode != http.StatusOK || enrollment.Secret == "" {
+		t.Fatalf("enable status = %d, body = %+v", resp.StatusCode, body)
+	}
+	step := totp.Step(time.Now())
+	code := func(s int64) string { c, _ := totp.Code(enrollment.Secret, s); return c }
+
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/mfa/totp/confirm", aggregate.VerifyTOTPReq{Code: code(step)}, tokens.AccessToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("confirm status = %d, want 200", resp.StatusCode)
+	}
+
+	login := aggregate.LoginReq{AuthType: "EMAIL", Email: "dana@example.com", Password: "password123"}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	var challenge aggregate.LoginResp
+	apitest.Decode(t, resp, &challenge)
+	if !challenge.MFARequired || challenge.MFAToken == "" || challenge.AccessToken != "" {
+		t.Fatalf("login = %+v, want an MFA challenge without tokens", challenge)
+	}
+
+	// The code used for enrollment cannot be replayed.
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/mfa/verify", aggregate.VerifyTOTPReq{MFAToken: challenge.MFAToken, Code: code(step)}, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("replayed code status = %d, want 401", resp.StatusCode)
+	}
+
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/mfa/verify", aggregate.VerifyTOTPReq{MFAToken: challenge.MFAToken, Code: code(step + 1), TrustDevice: true}, "")
+	var verified aggregate.VerifyTOTPResp
+	apitest.Decode(t, resp, &verified)
+	if resp.StatusCode != http.StatusOK || verified.TokenResp == nil || verified.AccessToken == "" || verified.DeviceToken == "" {
+		t.Fatalf("verify status = %d, resp = %+v; want tokens and a device token", resp.StatusCode, verified)
+	}
+	if payload, _ := h.Jwt.Verify(context.Background(), verified.AccessToken); payload == nil || !payload.MFA {
+		t.Errorf("access token payload = %+v, want MFA set", payload)
+	}
+	// The session remembers the first factor through the challenge.
+	var verifiedSession aggregate.UserSessionResp
+	verifiedSession.FromModel(h.Sessions.First(func(m *model.Session) bool { return m.ID == verified.SessionID }))
+	if verifiedSession.LoginMethod != string(constant.UserAuthTypeEmail) {
+		t.Errorf("session login method after MFA = %q, want EMAIL", verifiedSession.LoginMethod)
+	}
+
+	login.DeviceToken = verified.DeviceToken
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	var trusted aggregate.LoginResp
+	apitest.Decode(t, resp, &trusted)
+	if trusted.MFARequired || trusted.AccessToken == "" {
+		t.Errorf("login from trusted device = %+v, want tokens without a challenge", trusted)
+	}
+
+	// An external login replaces the password, not the second factor.
+	sealer, err := statetoken.NewSealerFromConfig(h.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	external := func(deviceToken string) aggregate.LoginResp {
+		t.Helper()
+		state, _ := sealer.Seal(aggregate.OAuthRefreshState{
+			ID:       uuid.NewString(),
+			AuthType: constant.UserAuthTypeSAML,
+			Provider: "saml:corp",
+			UserData: aggregate.OAuthUserData{Email: "dana@example.com", ProviderID: "dana"},
+		}, time.Minute)
+		var out aggregate.LoginResp
+		apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/session-from-state", aggregate.SessionFromStateReq{RefreshState: state, DeviceToken: deviceToken}, ""), &out)
+		return out
+	}
+	if resp := external(""); !resp.MFARequired || resp.AccessToken != "" {
+		t.Errorf("external login = %+v, want an MFA challenge without tokens", resp)
+	}
+	if resp := external(verified.DeviceToken); resp.MFARequired || resp.AccessToken == "" {
+		t.Errorf("external login from trusted device = %+v, want tokens without a challenge", resp)
+	}
+}
+
+func TestAuthHandler_OIDCLogin(t *testing.T) {
+	issuer := httptest.NewServer(nil)
+	t.Cleanup(issuer.Close)
+	issuer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 issuer.URL,
+				"authorization_endpoint": issuer.URL + "/authorize",
+				"token_endpoint":         issuer.URL + "/token",
+				"userinfo_endpoint":      issuer.URL + "/userinfo",
+			})
+		case "/token":
+			_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "at-1", "token_type": "Bearer", "expires_in": 300})
+		case "/userinfo":
+			if r.Header.Get("Authorization") != "Bearer at-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"sub": "okta-42", "email": "erin@example.com", "email_verified": true, "groups": []string{"eng"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	providersFile := filepath.Join(t.TempDir(), "oidc_providers.json")
+	providers, _ := json.Marshal([]map[string]any{{
+		"key": "okta", "issuer": issuer.URL, "clientId": "dreon", "clientSecret": "s3cret",
+		"redirectUrl": "https://auth.example.com/api/v1/auth/oidc/okta/callback",
+	}})
+	if err := os.WriteFile(providersFile, providers, 0644); err != nil {
+		t.Fatalf("write providers file: %v", err)
+	}
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) { cfg.OIDC.ProvidersFile = providersFile }))
+
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", aggregate.LoginReq{AuthType: "OIDC", Provider: "unknown", RedirectURL: "https://app.example.com/cb"}, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown provider status = %d, want 400", resp.StatusCode)
+	}
+
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", aggregate.LoginReq{AuthType: "OIDC", Provider: "okta", RedirectURL: "https://app.example.com/cb"}, "")
+	var start aggregate.LoginResp
+	apitest.Decode(t, resp, &start)
+	authURL, err := url.Parse(start.RedirectURL)
+	if err != nil || authURL.Path != "/authorize" || authURL.Query().Get("client_id") != "dreon" {
+		t.Fatalf("login redirectUrl = %q, want the issuer's authorization endpoint", start.RedirectURL)
+	}
+	state := authURL.Query().Get("state")
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	callback := func(provider string) *http.Response {
+		t.Helper()
+		resp, err := client.Get(h.Server.URL + "/api/v1/auth/oidc/" + provider + "/callback?code=c-1&state=" + url.QueryEscape(state))
+		if err != nil {
+			t.Fatalf("callback: %v", err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	// A state issued for one provider is rejected at another provider's callback.
+	if resp := callback("google"); resp.StatusCode == http.StatusFound {
+		t.Fatalf("callback for another provider status = %d, want rejection", resp.StatusCode)
+	}
+	resp = callback("okta")
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if resp.StatusCode != http.StatusFound || err != nil || location.Host != "app.example.com" {
+		t.Fatalf("callback status = %d, location = %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/session-from-state", aggregate.SessionFromStateReq{RefreshState: location.Query().Get("refreshState")}, "")
+	var tokens aggregate.TokenResp
+	apitest.Decode(t, resp, &tokens)
+	if resp.StatusCode != http.StatusOK || tokens.AccessToken == "" {
+		t.Fatalf("session-from-state status = %d, want tokens", resp.StatusCode)
+	}
+	user, _ := h.Users.FindByEmail(context.Background(), "erin@example.com")
+	identity := h.UserIdentities.FindBySubject(context.Background(), constant.UserAuthTypeOIDC, "okta", "okta-42")
+	if user == nil || identity == nil || identity.UserID != user.ID {
+		t.Errorf("user = %+v, identity = %+v, want an OIDC user linked to the issuer subject", user, identity)
+	}
+}
+
+func TestAuthHandler_MagicLinkLogin(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) { cfg.MagicLink.URL = "https://app.example.com/magic" }))
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "fay@example.com", Password: "password123"}, "")
+	resp.Body.Close()
+	// Rows created before statuses were set explicitly carry the column default in lower case.
+	fay := h.Users.First(func(u *model.User) bool { return u.Email == "fay@example.com" })
+	if err := h.Users.Update(context.Background(), fay.ID, model.User{Status: "active"}, "status"); err != nil {
+		t.Fatalf("set status: %v", err)
+	}
+
+	for _, email := range []string{"fay@example.com", "nobody@example.com"} {
+		resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", aggregate.LoginReq{AuthType: "MAGIC_LINK", Email: email}, "")
+		var sent aggregate.LoginResp
+		apitest.Decode(t, resp, &sent)
+		if resp.StatusCode != http.StatusOK || !sent.MagicLinkSent || sent.AccessToken != "" {
+			t.Fatalf("magic link login for %s: status = %d, resp = %+v", email, resp.StatusCode, sent)
+		}
+	}
+	mails := h.Mailer.Sent()
+	if len(mails) != 1 || mails[0].To != "fay@example.com" {
+		t.Fatalf("sent mail = %+v, want one message to the registered user", mails)
+	}
+	start := strings.Index(mails[0].Text, "https://app.example.com/magic?")
+	if start < 0 {
+		t.Fatalf("mail body has no link: %q", mails[0].Text)
+	}
+	link, err := url.Parse(strings.Fields(mails[0].Text[start:])[0])
+	if err != nil {
+		t.Fatalf("parse link: %v", err)
+	}
+
+	verify := aggregate.VerifyMagicLinkReq{Token: link.Query().Get("token")}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/magic-link/verify", verify, "")
+	var tokens aggregate.LoginResp
+	apitest.Decode(t, resp, &tokens)
+	if resp.StatusCode != http.StatusOK || tokens.AccessToken == "" {
+		t.Fatalf("verify status = %d, resp = %+v; want tokens", resp.StatusCode, tokens)
+	}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/magic-link/verify", verify, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("reused link status = %d, want 401", resp.StatusCode)
+	}
+
+	// Requests are throttled per address, including addresses without an account.
+	for i := 0; i < 4; i++ {
+		resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", aggregate.LoginReq{AuthType: "MAGIC_LINK", Email: "nobody@example.com"}, "")
+		resp.Body.Close()
+	}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", aggregate.LoginReq{AuthType: "MAGIC_LINK", Email: "nobody@example.com"}, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("sixth request status = %d, want 429", resp.StatusCode)
+	}
+}
+
+func TestAuthHandler_PhoneOTPLogin(t *testing.T) {
+	h := apitest.New(t)
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "gus@example.com", Password: "password123"}, "")
+	resp.Body.Close()
+	user := h.Users.First(func(u *model.User) bool { return u.Email == "gus@example.com" })
+	if err := h.Users.Update(context.Background(), user.ID, model.User{Phone: "+15551234567", Status: "active"}, "phone", "status"); err != nil {
+		t.Fatalf("set phone: %v", err)
+	}
+
+	for _, phone := range []string{"+15551234567", "+15559999999"} {
+		resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", aggregate.LoginReq{AuthType: "PHONE_OTP", Phone: phone}, "")
+		var sent aggregate.LoginResp
+		apitest.Decode(t, resp, &sent)
+		if resp.StatusCode != http.StatusOK || !sent.OTPSent || sent.AccessToken != "" {
+			t.Fatalf("phone OTP login for %s: status = %d, resp = %+v", phone, resp.StatusCode, sent)
+		}
+	}
+	texts := h.SMS.Sent()
+	if len(texts) != 1 || texts[0].To != "+15551234567" {
+		t.Fatalf("sent SMS = %+v, want one message to the registered number", texts)
+	}
+	code := strings.Fields(texts[0].Body)[0]
+
+	// Resending inside the cooldown is refused, including for numbers without an account.
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", aggregate.LoginReq{AuthType: "PHONE_OTP", Phone: "+15559999999"}, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("resend status = %d, want 429", resp.StatusCode)
+	}
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/otp/verify", aggregate.VerifyPhoneOTPReq{Phone: "+15551234567", Code: wrong}, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong code status = %d, want 401", resp.StatusCode)
+	}
+
+	verify := aggregate.VerifyPhoneOTPReq{Phone: "+15551234567", Code: code}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/otp/verify", verify, "")
+	var tokens aggregate.LoginResp
+	apitest.Decode(t, resp, &tokens)
+	if resp.StatusCode != http.StatusOK || tokens.AccessToken == "" {
+		t.Fatalf("verify status = %d, resp = %+v; want tokens", resp.StatusCode, tokens)
+	}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/otp/verify", verify, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("reused code status = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestAuthHandler_RevokeAccessToken(t *testing.T) {
+	h := apitest.New(t)
+	own := h.Token(jwt.Payload{UserID: "u1", Email: "u1@example.com"})
+	other := h.Token(jwt.Payload{UserID: "u1", Email: "u1@example.com"})
+	stranger := h.Token(jwt.Payload{UserID: "u2", Email: "u2@example.com"})
+
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/revoke", aggregate.RevokeTokenReq{Token: other}, stranger)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("revoking another user's token status = %d, want 403", resp.StatusCode)
+	}
+
+	// An empty body revokes the token the request was made with.
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/revoke", aggregate.RevokeTokenReq{}, own)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("self revoke status = %d", resp.StatusCode)
+	}
+	resp = h.Do(t, http.MethodGet, "/api/v1/auth/session", nil, own)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("revoked token status = %d, want 401", resp.StatusCode)
+	}
+	resp = h.Do(t, http.MethodGet, "/api/v1/auth/session", nil, other)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("sibling token status = %d, want 200", resp.StatusCode)
+	}
+
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/revoke", aggregate.RevokeTokenReq{Token: stranger}, admin)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || h.RevokedTokens.Len() != 2 {
+		t.Fatalf("admin revoke status = %d, revoked = %d", resp.StatusCode, h.RevokedTokens.Len())
+	}
+	// The database entry still applies once the cache entry is gone.
+	revoked := h.RevokedTokens.First(func(m *model.RevokedToken) bool { return m.UserID == "u2" })
+	if err := h.Cache.Delete(constant.CacheKeyPrefixRevokedToken + revoked.JTI); err != nil {
+		t.Fatalf("delete cache entry: %v", err)
+	}
+	resp = h.Do(t, http.MethodGet, "/api/v1/auth/session", nil, stranger)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("revoked token after cache loss status = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestAuthHandler_DeviceAuthorizationGrant(t *testing.T) {
+	h := apitest.New(t,
+		apitest.WithConfig(func(cfg *config.AppConfig) {
+			cfg.OIDC.DeviceVerificationURL = "https://id.example.com/device"
+		}),
+		func(h *apitest.Harness) {
+			h.OIDCClients = oidc.NewClientRegistryFromList([]oidc.Client{{ClientID: "tv"}})
+		},
+	)
+	now := time.Now()
+	h.Cache.SetClock(func() time.Time { return now })
+
+	post := func(path string, form url.Values) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, h.Server.URL+"/api/v1/auth/device/"+path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+	start := func() aggregate.DeviceCodeResp {
+		t.Helper()
+		resp := post("code", url.Values{"client_id": {"tv"}, "scope": {"email"}})
+		var started aggregate.DeviceCodeResp
+		if err := json.NewDecoder(resp.Body).Decode(&started); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("device code status = %d, %v", resp.StatusCode, err)
+		}
+		return started
+	}
+	// poll waits out the interval first unless told not to.
+	poll := func(deviceCode string, wait bool) (aggregate.OIDCTokenResp, string) {
+		t.Helper()
+		if wait {
+			now = now.Add(constant.DeviceCodePollInterval + time.Second)
+		}
+		resp := post("token", url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:device_code"}, "device_code": {deviceCode}, "client_id": {"tv"}})
+		body, _ := io.ReadAll(resp.Body)
+		var tokens aggregate.OIDCTokenResp
+		var oauthErr oidc.Error
+		if resp.StatusCode == http.StatusOK {
+			_ = json.Unmarshal(body, &tokens)
+		} else {
+			_ = json.Unmarshal(body, &oauthErr)
+		}
+		return tokens, oauthErr.Code
+	}
+
+	if resp := post("code", url.Values{"client_id": {"unknown"}}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unknown client status = %d, want 401", resp.StatusCode)
+	}
+	started := start()
+	if !regexp.MustCompile(`^[A-Z]{4}-[A-Z]{4}$`).MatchString(started.UserCode) || started.VerificationURI != "https://id.example.com/device" ||
+		!strings.Contains(started.VerificationURIComplete, "userCode="+started.UserCode) || started.Interval != 5 {
+		t.Fatalf("device code response = %+v", started)
+	}
+	if _, code := poll(started.DeviceCode, false); code != oidc.ErrCodeAuthorizationPending {
+		t.Errorf("first poll = %q, want authorization_pending", code)
+	}
+	if _, code := poll(started.DeviceCode, false); code != oidc.ErrCodeSlowDown {
+		t.Errorf("early poll = %q, want slow_down", code)
+	}
+
+	var registered aggregate.TokenResp
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "tv-owner@example.com", Password: "password123"}, ""), &registered)
+	if err := h.Users.Update(context.Background(), registered.UserID, model.User{Status: "active"}, "status"); err != nil {
+		t.Fatalf("set status: %v", err)
+	}
+
+	// Users may type the code in lower case and without the dash.
+	typed := strings.ToLower(strings.ReplaceAll(started.UserCode, "-", ""))
+	var pending aggregate.DeviceAuthorizationResp
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/api/v1/auth/device?userCode="+typed, nil, registered.AccessToken), &pending)
+	if pending.ClientID != "tv" || pending.UserCode != started.UserCode || pending.Scope != "email" {
+		t.Errorf("pending authorization = %+v", pending)
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/device/verify", aggregate.CompleteDeviceAuthorizationReq{UserCode: typed}, ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("verify without a token status = %d, want 401", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/device/verify", aggregate.CompleteDeviceAuthorizationReq{UserCode: typed}, registered.AccessToken); resp.StatusCode != http.StatusOK {
+		t.Fatalf("verify status = %d", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/device/verify", aggregate.CompleteDeviceAuthorizationReq{UserCode: typed}, registered.AccessToken); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("reused user code status = %d, want 400", resp.StatusCode)
+	}
+
+	tokens, code := poll(started.DeviceCode, true)
+	if code != "" || tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.IDToken != "" {
+		t.Fatalf("approved poll = %+v, %q", tokens, code)
+	}
+	var session aggregate.SessionResp
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/api/v1/auth/session", nil, tokens.AccessToken), &session)
+	if session.UserID != registered.UserID {
+		t.Errorf("device session user = %q, want %q", session.UserID, registered.UserID)
+	}
+	if _, code := poll(started.DeviceCode, true); code != oidc.ErrCodeExpiredToken {
+		t.Errorf("redeemed device code poll = %q, want expired_token", code)
+	}
+
+	denied := start()
+	h.Do(t, http.MethodPost, "/api/v1/auth/device/verify", aggregate.CompleteDeviceAuthorizationReq{UserCode: denied.UserCode, Deny: true}, registered.AccessToken)
+	if _, code := poll(denied.DeviceCode, true); code != oidc.ErrCodeAccessDenied {
+		t.Errorf("denied poll = %q, want access_denied", code)
+	}
+}
+
+func TestAuthHandler_DomainEvents(t *testing.T) {
+	h := apitest.New(t)
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "eve@example.com", Password: "password123"}, "")
+	var tokens aggregate.TokenResp
+	apitest.Decode(t, resp, &tokens)
+
+	registered := h.Events.Published(constant.EventUserRegistered)
+	if len(registered) != 1 || registered[0].Subject == "" || registered[0].ID == "" {
+		t.Fatalf("user.registered events = %+v", registered)
+	}
+	if user, ok := registered[0].Data.(aggregate.UserDto); !ok || user.Email != "eve@example.com" {
+		t.Errorf("user.registered data = %#v", registered[0].Data)
+	}
+
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/logout", aggregate.LogoutReq{RefreshToken: tokens.RefreshToken}, tokens.AccessToken)
+	resp.Body.Close()
+	ended := h.Events.Published(constant.EventSessionEnded)
+	if len(ended) != 1 || ended[0].Data.(service.SessionEndedEvent).SessionID != tokens.SessionID {
+		t.Errorf("session.ended events = %+v", ended)
+	}
+}
+
+func TestAuthHandler_CaptchaAfterFailures(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) {
+		cfg.Captcha.Provider = captcha.ProviderTurnstile
+		cfg.Captcha.FailureThreshold = 2
+	}))
+	register := func(email, token string) int {
+		t.Helper()
+		resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: email, Password: "password123", CaptchaToken: token}, "")
+		return apitest.Decode(t, resp, nil).Code
+	}
+	login := func(password, token string) int {
+		t.Helper()
+		req := aggregate.LoginReq{AuthType: constant.UserAuthTypeEmail, Email: "kim@example.com", Password: password, CaptchaToken: token}
+		return apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/login", req, ""), nil).Code
+	}
+	if code := register("kim@example.com", ""); code != http.StatusOK {
+		t.Fatalf("register: code %d", code)
+	}
+
+	for i := range 2 {
+		if code := login("wrong-password", ""); code != int(errorx.ErrInvalidPassword) {
+			t.Fatalf("failed login %d: code %d", i, code)
+		}
+	}
+	// Past the threshold even the right password needs a captcha, and only a valid one will do.
+	if code := login("password123", ""); code != http.StatusPreconditionRequired {
+		t.Errorf("login without captcha: code %d, want 428", code)
+	}
+	if code := login("password123", "bogus"); code != http.StatusPreconditionRequired {
+		t.Errorf("login with a rejected captcha: code %d, want 428", code)
+	}
+	if code := login("password123", testutil.CaptchaToken); code != http.StatusOK {
+		t.Errorf("login with captcha: code %d, want 200", code)
+	}
+	if h.Captcha.Checks() != 2 {
+		t.Errorf("captcha checks = %d, want 2", h.Captcha.Checks())
+	}
+	// Signing in clears the account's failures but not those of the IP they came from.
+	if code := login("password123", ""); code != http.StatusPreconditionRequired {
+		t.Errorf("login from the same IP after success: code %d, want 428", code)
+	}
+
+	// Registration counts its own failures: taken emails.
+	if code := register("lee@example.com", ""); code != http.StatusOK {
+		t.Errorf("register after failed logins: code %d, want 200", code)
+	}
+	for range 2 {
+		register("kim@example.com", "")
+	}
+	if code := register("max@example.com", ""); code != http.StatusPreconditionRequired {
+		t.Errorf("register after taken emails: code %d, want 428", code)
+	}
+	if code := register("max@example.com", testutil.CaptchaToken); code != http.StatusOK {
+		t.Errorf("register with captcha: code %d, want 200", code)
+	}
+}
+
+func TestAuthHandler_SuspiciousLogin(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) { cfg.MagicLink.URL = "https://app.example.com/magic" }))
+	login := func(userAgent, country string) aggregate.LoginResp {
+		t.Helper()
+		body, _ := json.Marshal(aggregate.LoginReq{AuthType: constant.UserAuthTypeEmail, Email: "nia@example.com", Password: "password123"})
+		req, _ := http.NewRequest(http.MethodPost, h.Server.URL+"/api/v1/auth/login", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		req.Header.Set("CF-IPCountry", country)
+		resp, err := h.Server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out aggregate.LoginResp
+		if code := apitest.Decode(t, resp, &out).Code; code != http.StatusOK {
+			t.Fatalf("login from %q in %q: code %d", userAgent, country, code)
+		}
+		return out
+	}
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "nia@example.com", Password: "password123"}, "")
+	var user aggregate.TokenResp
+	apitest.Decode(t, resp, &user)
+
+	// The registration session has no country yet, so locations fall back to the client network.
+	login("Mozilla/5.0 (X11; Linux x86_64) Firefox/128.0", "DE")
+	login("Mozilla/5.0 (X11; Linux x86_64) Firefox/131.0", "DE")
+	if events := h.Events.Published(constant.EventLoginSuspicious); len(events) != 1 {
+		t.Fatalf("login.suspicious events = %d, want 1 for the first browser only (updates are the same device)", len(events))
+	}
+
+	flagged := login("Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) Safari/604.1", "BR")
+	events := h.Events.Published(constant.EventLoginSuspicious)
+	if len(events) != 2 {
+		t.Fatalf("login.suspicious events = %d, want 2", len(events))
+	}
+	event, ok := events[1].Data.(service.LoginSuspiciousEvent)
+	if !ok || event.SessionID != flagged.SessionID || event.Country != "BR" ||
+		!slices.Equal(event.Anomalies, []string{constant.LoginAnomalyNewDevice, constant.LoginAnomalyNewLocation}) {
+		t.Fatalf("event data = %+v, want both anomalies for session %s", events[1].Data, flagged.SessionID)
+	}
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	var sessions aggregate.PaginationResp[aggregate.UserSessionResp]
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/api/v1/users/"+user.UserID+"/sessions", nil, admin), &sessions)
+	for _, session := range sessions.Items {
+		if session.ID == flagged.SessionID && len(session.Anomalies) != 2 {
+			t.Errorf("flagged session anomalies = %v, want 2", session.Anomalies)
+		}
+	}
+
+	// With confirmation required, a login from yet another device gets a sign-in link instead of tokens.
+	h.Config.LoginAnomaly.RequireConfirmation = true
+	held := login("Mozilla/5.0 (Windows NT 10.0; Win64; x64) Edg/126.0", "BR")
+	if !held.ConfirmationRequired || held.AccessToken != "" {
+		t.Fatalf("held login = %+v, want confirmationRequired and no tokens", held)
+	}
+	if mails := h.Mailer.Sent(); len(mails) != 1 || !strings.Contains(mails[0].Text, "https://app.example.com/magic?token=") {
+		t.Fatalf("sent mail = %+v, want one sign-in link", mails)
+	}
+	if events := h.Events.Published(constant.EventLoginSuspicious); len(events) != 3 || !events[2].Data.(service.LoginSuspiciousEvent).ConfirmationRequired {
+		t.Errorf("login.suspicious events = %+v, want a third one with confirmationRequired", events)
+	}
+	if known := login("Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) Safari/604.1", "BR"); known.AccessToken == "" {
+		t.Errorf("login from a known device and country = %+v, want tokens", known)
+	}
+}
+
+func TestAuthHandler_AuthzClaimsInAccessToken(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) {
+		cfg.Jwt.EmbedRoles = true
+		cfg.Jwt.EmbedPermissions = true
+	}))
+	ctx := context.Background()
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "gina@example.com", Password: "password123"}, "")
+	var tokens aggregate.TokenResp
+	apitest.Decode(t, resp, &tokens)
+	if payload, err := h.Jwt.Verify(ctx, tokens.AccessToken); err != nil || len(payload.Roles) != 0 || len(payload.Permissions) != 0 {
+		t.Fatalf("fresh user token = %+v, %v; want no roles or permissions", payload, err)
+	}
+
+	project, _ := h.Projects.Create(ctx, &model.Project{Code: "acme", Name: "Acme"})
+	viewer, _ := h.Roles.Create(ctx, &model.Role{Code: "viewer", Name: "Viewer", Permissions: model.PermissionsToJSON([]string{"users.view"})})
+	editor, _ := h.Roles.Create(ctx, &model.Role{Code: "editor", Name: "Editor", ProjectID: &project.ID, Permissions: model.PermissionsToJSON([]string{"docs.edit"})})
+	h.UserRoles.Create(ctx, &model.UserRole{UserID: tokens.UserID, RoleID: viewer.ID})
+	h.UserRoles.Create(ctx, &model.UserRole{UserID: tokens.UserID, RoleID: editor.ID, ProjectID: &project.ID})
+	h.Cache.Clear()
+
+	// Claims are a snapshot taken when the token is issued, so the refreshed token carries the new roles.
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/refresh-token", aggregate.RefreshTokenReq{RefreshToken: tokens.RefreshToken}, "")
+	apitest.Decode(t, resp, &tokens)
+	payload, err := h.Jwt.Verify(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("verify refreshed token: %v", err)
+	}
+	if !slices.Equal(payload.Roles, []string{"editor", "viewer"}) {
+		t.Errorf("roles claim = %v, want [editor viewer]", payload.Roles)
+	}
+	if want := []string{project.ID + "/docs.edit", "system/users.view"}; !slices.Equal(payload.Permissions, want) {
+		t.Errorf("perms claim = %v, want %v", payload.Permissions, want)
+	}
+}
+
+func TestAuthHandler_ProjectRedirectURLs(t *testing.T) {
+	h := apitest.New(t)
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	if resp := h.Do(t, http.MethodPost, "/api/v1/projects", aggregate.CreateProjectReq{Name: "Bad", RedirectURLs: []string{"not a url"}}, admin); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("create with invalid redirect URL: status %d, want 400", resp.StatusCode)
+	}
+	resp := h.Do(t, http.MethodPost, "/api/v1/projects", aggregate.CreateProjectReq{Name: "Portal", RedirectURLs: []string{"https://app.example.com/callback"}}, admin)
+	var project aggregate.ProjectDto
+	apitest.Decode(t, resp, &project)
+	if resp.StatusCode != http.StatusOK || len(project.RedirectURLs) != 1 {
+		t.Fatalf("create project: status %d, %+v", resp.StatusCode, project)
+	}
+
+	login := func(projectID, redirectURL string) int {
+		t.Helper()
+		resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", aggregate.LoginReq{AuthType: "GOOGLE", RedirectURL: redirectURL, ProjectID: projectID}, "")
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := login(project.ID, "https://evil.example.net/callback"); status != http.StatusBadRequest {
+		t.Errorf("login to unlisted redirect URL: status %d, want 400", status)
+	}
+	if status := login(project.ID, "https://app.example.com/callback/other"); status != http.StatusBadRequest {
+		t.Errorf("login to unlisted path: status %d, want 400", status)
+	}
+	if status := login(project.ID, "https://APP.example.com/callback?next=/settings"); status != http.StatusOK {
+		t.Errorf("login to listed redirect URL: status %d, want 200", status)
+	}
+	if status := login("missing", "https://app.example.com/callback"); status == http.StatusOK {
+		t.Error("login to a missing project succeeded")
+	}
+	// Without a project only OAUTH_REDIRECT_URLS may receive the refreshState.
+	if status := login("", "https://evil.example.net/callback"); status != http.StatusBadRequest {
+		t.Errorf("project-less login to unlisted redirect URL: status %d, want 400", status)
+	}
+	if status := login("", "https://app.example.com/cb"); status != http.StatusOK {
+		t.Errorf("project-less login to OAUTH_REDIRECT_URLS: status %d, want 200", status)
+	}
+
+	urls := []string{"https://portal.example.com/cb"}
+	resp = h.Do(t, http.MethodPut, "/api/v1/projects/"+project.ID, aggregate.UpdateProjectReq{RedirectURLs: &urls}, admin)
+	apitest.Decode(t, resp, &project)
+	if resp.StatusCode != http.StatusOK || len(project.RedirectURLs) != 1 || project.RedirectURLs[0] != urls[0] {
+		t.Fatalf("update redirect URLs: status %d, %+v", resp.StatusCode, project)
+	}
+	if status := login(project.ID, "https://app.example.com/callback"); status != http.StatusBadRequest {
+		t.Errorf("login to a removed redirect URL: status %d, want 400", status)
+	}
+	if status := login(project.ID, "https://portal.example.com/cb"); status != http.StatusOK {
+		t.Errorf("login to the new redirect URL: status %d, want 200", status)
+	}
+}
+
+func TestAuthHandler_ProjectMailSender(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) { cfg.MagicLink.URL = "https://app.example.com/magic" }))
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	if resp := h.Do(t, http.MethodPost, "/api/v1/projects", aggregate.CreateProjectReq{Name: "Acme", MailFrom: "not an address"}, admin); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("create with a bad mailFrom status = %d, want 400", resp.StatusCode)
+	}
+	var project aggregate.ProjectDto
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/projects", aggregate.CreateProjectReq{Name: "Acme", MailFrom: "Acme <hello@acme.test>"}, admin), &project)
+	if project.MailFrom != "Acme <hello@acme.test>" {
+		t.Fatalf("project = %+v", project)
+	}
+	h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "gus@example.com", Password: "password123"}, "").Body.Close()
+
+	login := aggregate.LoginReq{AuthType: "MAGIC_LINK", Email: "gus@example.com", ProjectID: project.ID}
+	h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "").Body.Close()
+	login.ProjectID = ""
+	h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "").Body.Close()
+	mails := h.Mailer.Sent()
+	if len(mails) != 2 || mails[0].From != "Acme <hello@acme.test>" || mails[1].From != "" {
+		t.Fatalf("sent mail = %+v, want the project's sender, then the default one", mails)
+	}
+	if !strings.Contains(mails[0].HTML, `href="https://app.example.com/magic?token=`) || mails[0].Subject == "" {
+		t.Errorf("magic link mail = %+v, want an HTML version with the link", mails[0])
+	}
+
+	// Clearing the sender goes back to MAIL_FROM.
+	cleared := ""
+	var updated aggregate.ProjectDto
+	apitest.Decode(t, h.Do(t, http.MethodPut, "/api/v1/projects/"+project.ID, aggregate.UpdateProjectReq{MailFrom: &cleared}, admin), &updated)
+	if updated.ID != project.ID || updated.MailFrom != "" {
+		t.Errorf("project after clearing mailFrom = %+v", updated)
+	}
+}
+
+func TestAuthHandler_RefreshStateSingleUse(t *testing.T) {
+	h := apitest.New(t)
+	sealer, err := statetoken.NewSealerFromConfig(h.Config)
+	if err != nil {
+		t.Fatalf("sealer: %v", err)
+	}
+	seal := func() string {
+		t.Helper()
+		state, err := sealer.Seal(aggregate.OAuthRefreshState{
+			ID:       uuid.NewString(),
+			AuthType: constant.UserAuthTypeGoogle,
+			UserData: aggregate.OAuthUserData{Email: "rae@example.com", ProviderID: "g-rae"},
+		}, time.Minute)
+		if err != nil {
+			t.Fatalf("seal: %v", err)
+		}
+		return state
+	}
+
+	// Of concurrent exchanges of the same state exactly one signs in.
+	state := seal()
+	statuses := make(chan int, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(statuses); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body, _ := json.Marshal(aggregate.SessionFromStateReq{RefreshState: state})
+			resp, err := h.Server.Client().Post(h.Server.URL+"/api/v1/auth/session-from-state", "application/json", strings.NewReader(string(body)))
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	ok := 0
+	for status := range statuses {
+		if status == http.StatusOK {
+			ok++
+		}
+	}
+	if ok != 1 {
+		t.Errorf("successful concurrent exchanges = %d, want 1", ok)
+	}
+
+	// When its use cannot be recorded the state is not accepted.
+	h.Cache.SetNXErr = errors.New("connection refused")
+	if code := apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/session-from-state", aggregate.SessionFromStateReq{RefreshState: seal()}, ""), nil).Code; code != int(errorx.ErrInternal) {
+		t.Errorf("exchange with the cache down code = %d, want ErrInternal", code)
+	}
+}
+
+func TestAuthHandler_ExternalLoginPKCE(t *testing.T) {
+	const (
+		verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+	h := apitest.New(t)
+	sealer, err := statetoken.NewSealerFromConfig(h.Config)
+	if err != nil {
+		t.Fatalf("sealer: %v", err)
+	}
+
+	// The challenge must be S256 and travels in the provider state.
+	login := aggregate.LoginReq{AuthType: constant.UserAuthTypeGoogle, RedirectURL: "https://app.example.com/cb", CodeChallenge: challenge, CodeChallengeMethod: "plain"}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("plain challenge: status %d, want 400", resp.StatusCode)
+	}
+	login.CodeChallengeMethod = oidc.CodeChallengeMethodS256
+	var started aggregate.LoginResp
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""), &started)
+	authURL, err := url.Parse(started.RedirectURL)
+	if err != nil {
+		t.Fatalf("auth URL %q: %v", started.RedirectURL, err)
+	}
+	var loginState aggregate.OAuthLoginState
+	if err := sealer.Open(authURL.Query().Get("state"), &loginState); err != nil || loginState.CodeChallenge != challenge {
+		t.Fatalf("login state = %+v, %v; want the challenge", loginState, err)
+	}
+
+	state, err := sealer.Seal(aggregate.OAuthRefreshState{
+		ID:            uuid.NewString(),
+		AuthType:      constant.UserAuthTypeGoogle,
+		UserData:      aggregate.OAuthUserData{Email: "pat@example.com", ProviderID: "g-pat"},
+		CodeChallenge: challenge,
+	}, time.Minute)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	exchange := func(codeVerifier string) (aggregate.LoginResp, int) {
+		t.Helper()
+		var out aggregate.LoginResp
+		req := aggregate.SessionFromStateReq{RefreshState: state, CodeVerifier: codeVerifier}
+		code := apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/session-from-state", req, ""), &out).Code
+		return out, code
+	}
+	for _, wrong := range []string{"", strings.Repeat("x", 43)} {
+		if _, code := exchange(wrong); code != int(errorx.ErrInvalidRefreshState) {
+			t.Errorf("verifier %q: code %d, want %d", wrong, code, errorx.ErrInvalidRefreshState)
+		}
+	}
+	// Failed attempts do not use up the state.
+	if tokens, code := exchange(verifier); code != http.StatusOK || tokens.AccessToken == "" {
+		t.Fatalf("right verifier: code %d, %+v", code, tokens)
+	}
+	if _, code := exchange(verifier); code == http.StatusOK {
+		t.Error("replayed state was accepted")
+	}
+}
+
+func TestAuthHandler_PasswordRehashOnLogin(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) {
+		cfg.Password.Algorithm = password.AlgorithmArgon2id
+		cfg.Password.Argon2MemoryKiB = 64
+		cfg.Password.Argon2Iterations = 1
+		cfg.Password.Argon2Parallelism = 1
+	}))
+	ctx := context.Background()
+	hashed, err := helper.HashPassword("password123")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	user, _ := h.Users.Create(ctx, &model.User{Username: "ivy", Email: "ivy@example.com", Password: hashed})
+
+	login := aggregate.LoginReq{AuthType: "EMAIL", Email: "ivy@example.com", Password: "password123"}
+	for i := range 2 {
+		resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("login %d status = %d, want 200", i+1, resp.StatusCode)
+		}
+		if stored := h.Users.FindOneById(ctx, user.ID).Password; !strings.HasPrefix(stored, "$argon2id$v=19$m=64,t=1,p=1$") {
+			t.Fatalf("stored hash after login %d = %q, want an argon2id hash", i+1, stored)
+		}
+	}
+
+	login.Password = "wrong"
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	if apitest.Decode(t, resp, nil).Code != int(errorx.ErrInvalidPassword) {
+		t.Errorf("login with a wrong password = %d, want ErrInvalidPassword", resp.StatusCode)
+	}
+}
+
+func TestAuthHandler_PasswordPepperRotation(t *testing.T) {
+	first, second := "AAECAwQFBgcICQoLDA0ODw==", "EBESExQVFhcYGRobHB0eHw=="
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) {
+		cfg.Password.Peppers = "1:" + first + ",2:" + second
+	}))
+	ctx := context.Background()
+	peppers, _ := password.ParsePeppers("1:" + first)
+	old, err := password.NewHasherWith(password.AlgorithmBcrypt, 4, password.DefaultArgon2Params, peppers)
+	if err != nil {
+		t.Fatalf("hasher: %v", err)
+	}
+	hashed, version, _ := old.Hash("password123")
+	user, _ := h.Users.Create(ctx, &model.User{Username: "jo", Email: "jo@example.com", Password: hashed, PasswordPepperVersion: version})
+
+	login := aggregate.LoginReq{AuthType: "EMAIL", Email: "jo@example.com", Password: "password123"}
+	for i := range 2 {
+		resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("login %d status = %d, want 200", i+1, resp.StatusCode)
+		}
+		if stored := h.Users.FindOneById(ctx, user.ID); stored.PasswordPepperVersion != 2 || stored.Password == hashed {
+			t.Fatalf("after login %d pepper version = %d, want the hash replaced under version 2", i+1, stored.PasswordPepperVersion)
+		}
+	}
+
+	// New passwords are peppered with the newest version.
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "kim@example.com", Password: "password123"}, "")
+	resp.Body.Close()
+	if registered, _ := h.Users.FindByEmail(ctx, "kim@example.com"); registered == nil || registered.PasswordPepperVersion != 2 {
+		t.Errorf("registered user = %+v, want pepper version 2", registered)
+	}
+}
+
+func TestAuthHandler_BreachedPasswordPolicy(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) { cfg.Password.BreachPolicy = "warn" }))
+	h.Breach.Add("password123")
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	reject := constant.BreachedPasswordReject
+	var project aggregate.ProjectDto
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/projects", aggregate.CreateProjectReq{Name: "Strict", BreachedPasswordPolicy: reject}, admin), &project)
+	if project.BreachedPasswordPolicy != reject {
+		t.Fatalf("project policy = %q, want reject", project.BreachedPasswordPolicy)
+	}
+
+	// Outside the project the global policy only warns.
+	var tokens aggregate.TokenResp
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "lee@example.com", Password: "password123"}, ""), &tokens)
+	if tokens.AccessToken == "" || len(tokens.Warnings) != 1 {
+		t.Fatalf("register with a breached password = %+v, want tokens and a warning", tokens)
+	}
+
+	// The project rejects it, for sign-ups and password changes alike.
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "max@example.com", Password: "password123", ProjectID: project.ID}, "")
+	if code := apitest.Decode(t, resp, nil).Code; code != int(errorx.ErrPasswordBreached) {
+		t.Errorf("register in the project code = %d, want ErrPasswordBreached", code)
+	}
+	var fresh aggregate.TokenResp
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "max@example.com", Password: "n0t-in-any-breach", ProjectID: project.ID}, ""), &fresh)
+	if fresh.AccessToken == "" || len(fresh.Warnings) != 0 {
+		t.Fatalf("register with a clean password = %+v, want tokens without warnings", fresh)
+	}
+	inProject := h.Token(jwt.Payload{UserID: fresh.UserID, Email: "max@example.com", ProjectID: project.ID})
+	change := aggregate.ChangePasswordReq{CurrentPassword: "n0t-in-any-breach", NewPassword: "password123"}
+	if code := apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/me/change-password", change, inProject), nil).Code; code != int(errorx.ErrPasswordBreached) {
+		t.Errorf("change to a breached password code = %d, want ErrPasswordBreached", code)
+	}
+
+	// Without an answer from any breach source the password is accepted.
+	h.Breach.SetErr(breach.ErrUnavailable)
+	var changed aggregate.ChangePasswordResp
+	resp = h.Do(t, http.MethodPost, "/api/v1/me/change-password", change, inProject)
+	if apitest.Decode(t, resp, &changed); resp.StatusCode != http.StatusOK || len(changed.Warnings) != 0 {
+		t.Errorf("change with the check unavailable status = %d, resp = %+v, want 200", resp.StatusCode, changed)
+	}
+}
+
+type claimsFunc func(ctx context.Context, payload jwt.Payload) (map[string]any, error)
+
+func (f claimsFunc) EnrichClaims(ctx context.Context, payload jwt.Payload) (map[string]any, error) {
+	return f(ctx, payload)
+}
+
+func TestAuthHandler_ClaimsEnrichers(t *testing.T) {
+	var failing error
+	var seenSession string
+	h := apitest.New(t,
+		apitest.WithClaimsEnricher(claimsFunc(func(ctx context.Context, p jwt.Payload) (map[string]any, error) {
+			seenSession = p.SessionID
+			return map[string]any{"tenant": "t-" + p.UserID, "plan": "free"}, nil
+		})),
+		apitest.WithClaimsEnricher(claimsFunc(func(ctx context.Context, p jwt.Payload) (map[string]any, error) {
+			return map[string]any{"plan": "pro", "flags": []string{"beta"}}, failing
+		})),
+	)
+	ctx := context.Background()
+	hashed, _ := helper.HashPassword("password123")
+	user, _ := h.Users.Create(ctx, &model.User{Username: "kim", Email: "kim@example.com", Password: hashed})
+
+	login := aggregate.LoginReq{AuthType: "EMAIL", Email: "kim@example.com", Password: "password123"}
+	var tokens aggregate.LoginResp
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	if apitest.Decode(t, resp, &tokens); resp.StatusCode != http.StatusOK {
+		t.Fatalf("login status = %d, want 200", resp.StatusCode)
+	}
+	payload, err := h.Jwt.Verify(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if payload.Custom["tenant"] != "t-"+user.ID || payload.Custom["plan"] != "pro" || payload.Custom["flags"] == nil {
+		t.Errorf("custom claims = %v, want tenant, the later enricher's plan and flags", payload.Custom)
+	}
+	if seenSession == "" || seenSession != payload.SessionID {
+		t.Errorf("enricher saw session %q, want %q", seenSession, payload.SessionID)
+	}
+
+	var refreshed aggregate.TokenResp
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/refresh-token", aggregate.RefreshTokenReq{RefreshToken: tokens.RefreshToken}, "")
+	if apitest.Decode(t, resp, &refreshed); resp.StatusCode != http.StatusOK {
+		t.Fatalf("refresh status = %d, want 200", resp.StatusCode)
+	}
+	if payload, _ := h.Jwt.Verify(ctx, refreshed.AccessToken); payload == nil || payload.Custom["plan"] != "pro" {
+		t.Errorf("refreshed token claims = %+v, want the custom claims again", payload)
+	}
+
+	failing = errors.New("plan service down")
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	if apitest.Decode(t, resp, nil).Code != int(errorx.ErrInternal) {
+		t.Errorf("login with a failing enricher status = %d, want ErrInternal", resp.StatusCode)
+	}
+}
+
+func TestAuthHandler_RefreshStateClientBinding(t *testing.T) {
+	h := apitest.New(t)
+	sealer, err := statetoken.NewSealerFromConfig(h.Config)
+	if err != nil {
+		t.Fatalf("sealer: %v", err)
+	}
+	post := func(path, userAgent string, body any) *http.Response {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPost, h.Server.URL+path, strings.NewReader(string(data)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := h.Server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	// The login state records the starting client, for the callback to pass on to the refreshState.
+	var start aggregate.LoginResp
+	apitest.Decode(t, post("/api/v1/auth/login", "Firefox", aggregate.LoginReq{AuthType: constant.UserAuthTypeGoogle, RedirectURL: "https://app.example.com/cb"}), &start)
+	authURL, _ := url.Parse(start.RedirectURL)
+	var loginState aggregate.OAuthLoginState
+	if err := sealer.Open(authURL.Query().Get("state"), &loginState); err != nil || loginState.ClientBinding != sealer.Bind(constant.OAuthStateBindingUserAgent, "Firefox") {
+		t.Fatalf("login state = %+v, %v, want it bound to the user agent", loginState, err)
+	}
+
+	refreshState := func(challenge string) string {
+		state, err := sealer.Seal(aggregate.OAuthRefreshState{
+			ID:            uuid.NewString(),
+			AuthType:      constant.UserAuthTypeGoogle,
+			UserData:      aggregate.OAuthUserData{Email: "lee@example.com", ProviderID: "g-lee"},
+			CodeChallenge: challenge,
+			ClientBinding: loginState.ClientBinding,
+		}, time.Minute)
+		if err != nil {
+			t.Fatalf("seal: %v", err)
+		}
+		return state
+	}
+	state := refreshState("")
+	if code := apitest.Decode(t, post("/api/v1/auth/session-from-state", "curl/8.0", aggregate.SessionFromStateReq{RefreshState: state}), nil).Code; code != int(errorx.ErrInvalidRefreshState) {
+		t.Errorf("refreshState from another client: code %d, want ErrInvalidRefreshState", code)
+	}
+	// The rejection does not burn the state for the client it was issued to.
+	if resp := post("/api/v1/auth/session-from-state", "Firefox", aggregate.SessionFromStateReq{RefreshState: state}); apitest.Decode(t, resp, nil).Code != http.StatusOK {
+		t.Errorf("refreshState from the starting client status = %d, want 200", resp.StatusCode)
+	}
+
+	// With PKCE the verifier binds the state instead, so another user agent may finish the login.
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	if resp := post("/api/v1/auth/session-from-state", "MyApp/1.0", aggregate.SessionFromStateReq{RefreshState: refreshState("E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"), CodeVerifier: verifier}); apitest.Decode(t, resp, nil).Code != http.StatusOK {
+		t.Errorf("PKCE-bound refreshState from another client status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestAuthHandler_RefreshStateClientBindingOff(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) { cfg.OAuth.StateBinding = constant.OAuthStateBindingNone }))
+	sealer, _ := statetoken.NewSealerFromConfig(h.Config)
+	state, _ := sealer.Seal(aggregate.OAuthRefreshState{
+		ID:            uuid.NewString(),
+		AuthType:      constant.UserAuthTypeGoogle,
+		UserData:      aggregate.OAuthUserData{Email: "mo@example.com", ProviderID: "g-mo"},
+		ClientBinding: sealer.Bind(constant.OAuthStateBindingUserAgent, "Firefox"),
+	}, time.Minute)
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/session-from-state", aggregate.SessionFromStateReq{RefreshState: state}, ""); apitest.Decode(t, resp, nil).Code != http.StatusOK {
+		t.Errorf("session-from-state with binding off status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestAuthHandler_AccountEnumerationProtection(t *testing.T) {
+	login := func(h *apitest.Harness, email, password string) int {
+		req := aggregate.LoginReq{AuthType: constant.UserAuthTypeEmail, Email: email, Password: password}
+		return apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/login", req, ""), nil).Code
+	}
+	register := func(h *apitest.Harness, email string) int {
+		req := aggregate.RegisterReq{Email: email, Password: "password123"}
+		return apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/register", req, ""), nil).Code
+	}
+
+	// Without protection the errors tell an unknown email from a wrong password and a taken email.
+	h := apitest.New(t)
+	register(h, "ana@example.com")
+	if code := login(h, "nobody@example.com", "password123"); code != int(errorx.ErrUserNotFound) {
+		t.Errorf("unknown email: code %d, want ErrUserNotFound", code)
+	}
+	if code := login(h, "ana@example.com", "wrong-password"); code != int(errorx.ErrInvalidPassword) {
+		t.Errorf("wrong password: code %d, want ErrInvalidPassword", code)
+	}
+	if code := register(h, "ana@example.com"); code != int(errorx.ErrUserConflict) {
+		t.Errorf("taken email: code %d, want ErrUserConflict", code)
+	}
+
+	h = apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) {
+		cfg.AccountEnumeration.Protection = true
+		cfg.MagicLink.URL = "https://app.example.com/magic"
+	}))
+
+	// A new and a taken email get the same answer, without tokens; both owners are emailed a sign-in link after
+	// the response.
+	for _, email := range []string{"ana@example.com", "ana@example.com"} {
+		req := aggregate.RegisterReq{Email: email, Password: "password123"}
+		var tokens aggregate.TokenResp
+		base := apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/register", req, ""), &tokens)
+		if base.Code != http.StatusOK || !tokens.SignInLinkSent || tokens.AccessToken != "" || tokens.UserID != "" {
+			t.Errorf("register %s: code %d, resp %+v", email, base.Code, tokens)
+		}
+	}
+	apitest.Eventually(t, func() bool { return len(h.Mailer.Sent()) == 2 }, "registration sign-in links were not sent")
+	if h.Users.Len() != 1 || h.Sessions.Len() != 0 {
+		t.Errorf("after registering twice: %d users, %d sessions, want 1 and 0", h.Users.Len(), h.Sessions.Len())
+	}
+
+	for name, code := range map[string]int{
+		"unknown email":  login(h, "nobody@example.com", "password123"),
+		"wrong password": login(h, "ana@example.com", "wrong-password"),
+	} {
+		if code != int(errorx.ErrInvalidCredentials) {
+			t.Errorf("%s: code %d, want ErrInvalidCredentials", name, code)
+		}
+	}
+	if code := login(h, "ana@example.com", "password123"); code != http.StatusOK {
+		t.Errorf("login: code %d, want 200", code)
+	}
+
+	// Magic links, how users who forgot their password sign in, are sent after the response for a known email.
+	for _, email := range []string{"nobody@example.com", "ana@example.com"} {
+		req := aggregate.LoginReq{AuthType: constant.UserAuthTypeMagicLink, Email: email}
+		var sent aggregate.LoginResp
+		if base := apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/login", req, ""), &sent); base.Code != http.StatusOK || !sent.MagicLinkSent {
+			t.Errorf("magic link for %s: code %d, resp %+v", email, base.Code, sent)
+		}
+	}
+	apitest.Eventually(t, func() bool { return len(h.Mailer.Sent()) == 3 }, "magic link for ana@example.com was not sent")
+
+	// Protected registration signs in through the emailed link, so it needs MAGIC_LINK_URL.
+	h = apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) { cfg.AccountEnumeration.Protection = true }))
+	if code := register(h, "ana@example.com"); code != int(errorx.ErrBadRequest) {
+		t.Errorf("register without MAGIC_LINK_URL: code %d, want ErrBadRequest", code)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
 	"github.com/labstack/echo/v4"
 )
 
@@ -13,6 +14,8 @@ type BaseResp struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Data    any    `json:"data,omitempty"`
+	// RequestID is set on errors so a failed call can be found in the logs.
+	RequestID string `json:"requestId,omitempty"`
 }
 
 // ValidationErrItem describes one invalid field for validation error responses.
@@ -23,9 +26,10 @@ type ValidationErrItem struct {
 
 // ValidationErrResp is the response body when validation fails.
 type ValidationErrResp struct {
-	Code    int                 `json:"code"`
-	Message string              `json:"message"`
-	Errors  []ValidationErrItem `json:"errors"`
+	Code      int                 `json:"code"`
+	Message   string              `json:"message"`
+	Errors    []ValidationErrItem `json:"errors"`
+	RequestID string              `json:"requestId,omitempty"`
 }
 
 // HandleValidateBind binds the request body to a value of type T and validates it.
@@ -51,6 +55,7 @@ func HandleSuccess(c echo.Context, data any) error {
 }
 
 func HandleError(c echo.Context, err error) error {
+	requestID := logger.RequestIDFromContext(c.Request().Context())
 	// Validation errors: return list of invalid/missing fields
 	var valErrs validator.ValidationErrors
 	if errors.As(err, &valErrs) {
@@ -62,13 +67,14 @@ func HandleError(c echo.Context, err error) error {
 			})
 		}
 		return c.JSON(http.StatusBadRequest, ValidationErrResp{
-			Code:    http.StatusBadRequest,
-			Message: "Validation failed",
-			Errors:  errors,
+			Code:      http.StatusBadRequest,
+			Message:   "Validation failed",
+			Errors:    errors,
+			RequestID: requestID,
 		})
 	}
 
-	resp := BaseResp{}
+	resp := BaseResp{RequestID: requestID}
 	var appErr *errorx.AppError
 	if errors.As(err, &appErr) {
 		// If wrapped error is validation, still return validation response
@@ -81,9 +87,10 @@ func HandleError(c echo.Context, err error) error {
 				})
 			}
 			return c.JSON(http.StatusBadRequest, ValidationErrResp{
-				Code:    int(appErr.Code),
-				Message: "Validation failed",
-				Errors:  errors,
+				Code:      int(appErr.Code),
+				Message:   "Validation failed",
+				Errors:    errors,
+				RequestID: requestID,
 			})
 		}
 		resp.Code = int(appErr.Code)
@@ -101,6 +108,35 @@ func HandleError(c echo.Context, err error) error {
 	return c.JSON(http.StatusInternalServerError, resp)
 }
 
+// HTTPErrorHandler is the Echo error handler. It writes errors that reach Echo from middlewares, route
+// matching and handlers alike as a BaseResp with the request ID, so clients parse one error shape.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	var he *echo.HTTPError
+	if !errors.As(err, &he) {
+		_ = HandleError(c, err)
+		return
+	}
+	resp := BaseResp{
+		Code:      he.Code,
+		Message:   http.StatusText(he.Code),
+		RequestID: logger.RequestIDFromContext(c.Request().Context()),
+	}
+	switch m := he.Message.(type) {
+	case string:
+		resp.Message = m
+	case error:
+		resp.Message = m.Error()
+	}
+	if c.Request().Method == http.MethodHead {
+		_ = c.NoContent(he.Code)
+		return
+	}
+	_ = c.JSON(he.Code, resp)
+}
+
 // validationTagMessage returns a short message for common validator tags.
 func validationTagMessage(tag string) string {
 	switch tag {
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/pkg/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// newTestContext returns a context for a request to the handler, with the server's validator and a request ID.
+func newTestContext(method, target, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	e.Validator = validator.New()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req = req.WithContext(logger.ContextWithRequestID(req.Context(), "req-1"))
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
+
+func TestHandleError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    float64
+		message string
+	}{
+		{"client error", errorx.New(errorx.ErrBadRequest, "bad input"), http.StatusBadRequest, float64(errorx.ErrBadRequest), "bad input"},
+		{"app-specific code", errorx.New(errorx.ErrUserNotFound, "User not found"), http.StatusInternalServerError, float64(errorx.ErrUserNotFound), "User not found"},
+		{"unexpected", errors.New("boom"), http.StatusInternalServerError, float64(errorx.ErrInternal), errorx.GetErrorMessage(int(errorx.ErrInternal))},
+	}
+	for _, tt := range tests {
+		c, rec := newTestContext(http.MethodGet, "/", "")
+		_ = HandleError(c, tt.err)
+		body := decodeBody(t, rec)
+		if rec.Code != tt.status || body["code"] != tt.code || body["message"] != tt.message || body["requestId"] != "req-1" {
+			t.Errorf("%s: status %d, body %v; want %d with code %v", tt.name, rec.Code, body, tt.status, tt.code)
+		}
+	}
+}
+
+func TestHandleValidateBind(t *testing.T) {
+	c, _ := newTestContext(http.MethodPost, "/", `{"namespace":"document","objectId":"readme","relation":"viewer","subjectNamespace":"user","subjectObjectId":"alice"}`)
+	if req, err := HandleValidateBind[aggregate.ListObjectsReq](c); err != nil || req.SubjectObjectID != "alice" {
+		t.Fatalf("HandleValidateBind = %+v, %v", req, err)
+	}
+
+	// Invalid requests are 400s listing each field.
+	c, rec := newTestContext(http.MethodPost, "/", `{"namespace":"document"}`)
+	_, err := HandleValidateBind[aggregate.ListObjectsReq](c)
+	_ = HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	body := decodeBody(t, rec)
+	if fields, _ := body["errors"].([]any); rec.Code != http.StatusBadRequest || len(fields) != 3 || body["requestId"] != "req-1" {
+		t.Errorf("invalid request: status %d, body %v; want 400 with 3 invalid fields", rec.Code, body)
+	}
+
+	c, _ = newTestContext(http.MethodPost, "/", `not json`)
+	if _, err := HandleValidateBind[aggregate.ListObjectsReq](c); err == nil {
+		t.Error("HandleValidateBind(malformed body) err = nil")
+	}
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		method  string
+		err     error
+		status  int
+		message string
+	}{
+		{"middleware error", http.MethodGet, echo.NewHTTPError(http.StatusUnauthorized, "missing authorization header"), http.StatusUnauthorized, "missing authorization header"},
+		{"route not found", http.MethodGet, echo.ErrNotFound, http.StatusNotFound, "Not Found"},
+		{"wrapped error message", http.MethodGet, echo.NewHTTPError(http.StatusForbidden, errors.New("denied")), http.StatusForbidden, "denied"},
+		{"app error", http.MethodGet, errorx.New(errorx.ErrConflict, "taken"), http.StatusConflict, "taken"},
+	} {
+		c, rec := newTestContext(tt.method, "/", "")
+		HTTPErrorHandler(tt.err, c)
+		body := decodeBody(t, rec)
+		if rec.Code != tt.status || body["code"] != float64(tt.status) || body["message"] != tt.message || body["requestId"] != "req-1" || len(body) != 3 {
+			t.Errorf("%s: status %d, body %v; want a %d envelope", tt.name, rec.Code, body, tt.status)
+		}
+	}
+
+	c, rec := newTestContext(http.MethodHead, "/", "")
+	HTTPErrorHandler(echo.ErrNotFound, c)
+	if rec.Code != http.StatusNotFound || rec.Body.Len() != 0 {
+		t.Errorf("HEAD: status %d, body %q; want 404 without a body", rec.Code, rec.Body.String())
+	}
+}
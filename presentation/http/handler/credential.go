@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type CredentialHandler struct {
+	credentialSvc service.ICredentialSvc
+	logger        logger.ILogger
+	verifyJWT     middleware.VerifyJWTMiddleware
+}
+
+func NewCredentialHandler(
+	credentialSvc service.ICredentialSvc,
+	logger logger.ILogger,
+	verifyJWT middleware.VerifyJWTMiddleware,
+) *CredentialHandler {
+	return &CredentialHandler{
+		credentialSvc: credentialSvc,
+		logger:        logger,
+		verifyJWT:     verifyJWT,
+	}
+}
+
+func (h *CredentialHandler) RegisterRoutes(g *echo.Group) {
+	// Callers only ever see and manage their own credentials
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+
+	g.GET("", h.HandleListCredentials)
+	g.PATCH("/:id", h.HandleRenameCredential)
+	g.DELETE("/:id", h.HandleDeleteCredential)
+}
+
+// HandleListCredentials lists the caller's passkeys and MFA devices.
+func (h *CredentialHandler) HandleListCredentials(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.credentialSvc.ListCredentials(ctx)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleRenameCredential changes a credential's display name.
+func (h *CredentialHandler) HandleRenameCredential(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+	if id == "" {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, nil))
+	}
+
+	req, err := HandleValidateBind[aggregate.RenameCredentialReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.credentialSvc.RenameCredential(ctx, id, req)
+	if err != nil {
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to rename credential", "id", id, "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleDeleteCredential removes a credential; the body must carry the caller's password.
+func (h *CredentialHandler) HandleDeleteCredential(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+	if id == "" {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, nil))
+	}
+
+	req, err := HandleValidateBind[aggregate.DeleteCredentialReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	if err := h.credentialSvc.DeleteCredential(ctx, id, req); err != nil {
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to delete credential", "id", id, "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
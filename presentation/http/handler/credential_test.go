@@ -0,0 +1,48 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+)
+
+func TestCredentialHandler_DeleteRequiresStepUp(t *testing.T) {
+	h := apitest.New(t)
+
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{
+		Email:    "bob@example.com",
+		Password: "password123",
+	}, "")
+	var tokens aggregate.TokenResp
+	apitest.Decode(t, resp, &tokens)
+	user := h.Users.First(func(u *model.User) bool { return u.Email == "bob@example.com" })
+	if user == nil {
+		t.Fatal("registered user not found")
+	}
+	cred, err := h.Credentials.Create(context.Background(), &model.UserCredential{
+		UserID: user.ID,
+		Type:   constant.CredentialTypeWebAuthn,
+		Name:   "Laptop",
+	})
+	if err != nil {
+		t.Fatalf("create credential: %v", err)
+	}
+
+	path := "/api/v1/credentials/" + cred.ID
+	resp = h.Do(t, http.MethodDelete, path, aggregate.DeleteCredentialReq{Password: "wrong"}, tokens.AccessToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || h.Credentials.Len() != 1 {
+		t.Fatalf("wrong password status = %d, credentials = %d; want 403 and 1", resp.StatusCode, h.Credentials.Len())
+	}
+
+	resp = h.Do(t, http.MethodDelete, path, aggregate.DeleteCredentialReq{Password: "password123"}, tokens.AccessToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || h.Credentials.Len() != 0 {
+		t.Errorf("step-up delete status = %d, credentials = %d; want 200 and 0", resp.StatusCode, h.Credentials.Len())
+	}
+}
@@ -0,0 +1,37 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	h := apitest.New(t)
+	user := h.Token(jwt.Payload{UserID: "user-1"})
+	tests := []struct {
+		name, method, path, token string
+		status                    int
+		message                   string
+	}{
+		{"missing token", http.MethodGet, "/api/v1/auth/session", "", http.StatusUnauthorized, "missing authorization header"},
+		{"bad token", http.MethodGet, "/api/v1/auth/session", "not-a-jwt", http.StatusUnauthorized, ""},
+		{"super-admin route", http.MethodGet, "/api/v1/projects", user, http.StatusForbidden, "super admin access required"},
+		{"unknown route", http.MethodGet, "/api/v1/nowhere", "", http.StatusNotFound, "Not Found"},
+		{"wrong method", http.MethodPost, "/.well-known/jwks.json", "", http.StatusMethodNotAllowed, "Method Not Allowed"},
+	}
+	for _, tt := range tests {
+		resp := h.Do(t, tt.method, tt.path, nil, tt.token)
+		var body map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: decode: %v", tt.name, err)
+		}
+		if resp.StatusCode != tt.status || body["code"] != float64(tt.status) || body["requestId"] == nil || body["message"] == "" ||
+			(tt.message != "" && body["message"] != tt.message) || len(body) != 3 {
+			t.Errorf("%s: status = %d, body = %v; want a %d envelope", tt.name, resp.StatusCode, body, tt.status)
+		}
+	}
+}
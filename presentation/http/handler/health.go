@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/labstack/echo/v4"
+)
+
+type HealthHandler struct {
+	healthSvc service.IHealthSvc
+}
+
+func NewHealthHandler(healthSvc service.IHealthSvc) *HealthHandler {
+	return &HealthHandler{healthSvc: healthSvc}
+}
+
+// RegisterRoutes registers the probes on a group mounted at the root.
+func (h *HealthHandler) RegisterRoutes(g *echo.Group) {
+	g.GET("/ping", h.HandlePing)
+	g.GET("/healthz", h.HandleHealth)
+	g.GET("/readyz", h.HandleReady)
+}
+
+// HandlePing is the liveness probe: it answers as long as the process serves HTTP, whatever the state of
+// its dependencies, so an outage of Postgres or Redis does not get every instance restarted.
+func (h *HealthHandler) HandlePing(c echo.Context) error {
+	return c.JSON(http.StatusOK, echo.Map{
+		"code":    http.StatusOK,
+		"message": "pong",
+	})
+}
+
+// HandleHealth reports the status of each dependency, with 503 when any of them is unavailable.
+func (h *HealthHandler) HandleHealth(c echo.Context) error {
+	return healthResponse(c, h.healthSvc.Check(c.Request().Context()))
+}
+
+// HandleReady is the readiness probe: like /healthz, but also 503 while the app is starting or shutting down.
+func (h *HealthHandler) HandleReady(c echo.Context) error {
+	return healthResponse(c, h.healthSvc.Ready(c.Request().Context()))
+}
+
+func healthResponse(c echo.Context, result *aggregate.HealthResp) error {
+	if result.Status == constant.HealthStatusOK {
+		return HandleSuccess(c, result)
+	}
+	return c.JSON(http.StatusServiceUnavailable, BaseResp{
+		Code:    http.StatusServiceUnavailable,
+		Message: result.Status,
+		Data:    result,
+	})
+}
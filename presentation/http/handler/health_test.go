@@ -0,0 +1,56 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+)
+
+func TestHealthHandler_Probes(t *testing.T) {
+	h := apitest.New(t)
+	probe := func(path string) (*http.Response, aggregate.HealthResp) {
+		t.Helper()
+		resp := h.Do(t, http.MethodGet, path, nil, "")
+		var health aggregate.HealthResp
+		apitest.Decode(t, resp, &health)
+		return resp, health
+	}
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		resp, health := probe(path)
+		if resp.StatusCode != http.StatusOK || health.Status != "ok" || len(health.Checks) != 2 {
+			t.Errorf("%s status = %d, health = %+v, want ok with postgres and redis", path, resp.StatusCode, health)
+		}
+	}
+
+	// Readiness follows the app lifecycle around the checks.
+	svc := service.NewHealthSvc(h.Config, testutil.NewLogger(), h.Cache, h.Database)
+	if got := svc.Ready(context.Background()).Status; got != "starting" {
+		t.Errorf("ready before start = %q, want starting", got)
+	}
+	svc.MarkStarted()
+	svc.MarkStopping()
+	if got := svc.Ready(context.Background()).Status; got != "stopping" {
+		t.Errorf("ready after shutdown began = %q, want stopping", got)
+	}
+
+	h.Database.Err = errors.New("connection refused")
+	resp, health := probe("/readyz")
+	if resp.StatusCode != http.StatusServiceUnavailable || health.Status != "unavailable" {
+		t.Fatalf("readyz with postgres down status = %d, health = %+v", resp.StatusCode, health)
+	}
+	if pg := health.Checks["postgres"]; pg.Status != "unavailable" || pg.Error != "connection refused" || health.Checks["redis"].Status != "ok" {
+		t.Errorf("checks = %+v, want only postgres unavailable", health.Checks)
+	}
+
+	// Liveness does not depend on the dependencies.
+	if resp := h.Do(t, http.MethodGet, "/ping", nil, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("ping status = %d, want 200", resp.StatusCode)
+	}
+}
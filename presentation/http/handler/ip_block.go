@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	echomw "github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type IPBlockHandler struct {
+	ipFilterSvc service.IIPFilterSvc
+	logger      logger.ILogger
+	verifyJWT   echomw.VerifyJWTMiddleware
+	authorize   echomw.AuthorizeMiddleware
+}
+
+func NewIPBlockHandler(
+	ipFilterSvc service.IIPFilterSvc,
+	logger logger.ILogger,
+	verifyJWT echomw.VerifyJWTMiddleware,
+	authorize echomw.AuthorizeMiddleware,
+) *IPBlockHandler {
+	return &IPBlockHandler{
+		ipFilterSvc: ipFilterSvc,
+		logger:      logger,
+		verifyJWT:   verifyJWT,
+		authorize:   authorize,
+	}
+}
+
+// RegisterRoutes registers IP denylist management on a group mounted at /ip-blocks.
+func (h *IPBlockHandler) RegisterRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
+	g.GET("", h.HandleListBlocks)
+	g.POST("", h.HandleCreateBlock)
+	g.DELETE("/:id", h.HandleDeleteBlock)
+}
+
+// RegisterBanRoutes registers the automatic IP bans on a group mounted at /ip-bans.
+func (h *IPBlockHandler) RegisterBanRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
+	g.GET("", h.HandleListBans)
+	g.DELETE("/:ip", h.HandleLiftBan)
+}
+
+// HandleListBlocks lists the IP denylist, expired entries included.
+func (h *IPBlockHandler) HandleListBlocks(c echo.Context) error {
+	result, err := h.ipFilterSvc.ListBlocks(c.Request().Context())
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleCreateBlock denies a network range access to the whole API.
+func (h *IPBlockHandler) HandleCreateBlock(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.CreateIPBlockReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.ipFilterSvc.CreateBlock(ctx, req)
+	if err != nil {
+		logger.WithContext(ctx, h.logger).Error("Failed to create IP block", "cidr", req.CIDR, "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleDeleteBlock lifts a block.
+func (h *IPBlockHandler) HandleDeleteBlock(c echo.Context) error {
+	if err := h.ipFilterSvc.DeleteBlock(c.Request().Context(), c.Param("id")); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
+
+// HandleListBans lists the IPs banned for failing sign-in too often.
+func (h *IPBlockHandler) HandleListBans(c echo.Context) error {
+	result, err := h.ipFilterSvc.ListBans(c.Request().Context())
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleLiftBan ends an IP ban before it expires.
+func (h *IPBlockHandler) HandleLiftBan(c echo.Context) error {
+	if err := h.ipFilterSvc.LiftBan(c.Request().Context(), c.Param("ip")); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
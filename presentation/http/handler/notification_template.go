@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type NotificationTemplateHandler struct {
+	templateSvc service.INotificationTemplateSvc
+	logger      logger.ILogger
+	verifyJWT   middleware.VerifyJWTMiddleware
+	authorize   middleware.AuthorizeMiddleware
+}
+
+func NewNotificationTemplateHandler(
+	templateSvc service.INotificationTemplateSvc,
+	logger logger.ILogger,
+	verifyJWT middleware.VerifyJWTMiddleware,
+	authorize middleware.AuthorizeMiddleware,
+) *NotificationTemplateHandler {
+	return &NotificationTemplateHandler{
+		templateSvc: templateSvc,
+		logger:      logger,
+		verifyJWT:   verifyJWT,
+		authorize:   authorize,
+	}
+}
+
+// RegisterRoutes registers template management on a group mounted at /projects/:id/templates.
+func (h *NotificationTemplateHandler) RegisterRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
+	g.GET("", h.HandleListTemplates)
+	g.PUT("", h.HandleUpsertTemplate)
+	g.DELETE("/:templateId", h.HandleDeleteTemplate)
+}
+
+// HandleListTemplates lists the project's email and SMS templates with the variables each can use.
+func (h *NotificationTemplateHandler) HandleListTemplates(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.templateSvc.List(ctx, c.Param("id"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleUpsertTemplate creates or replaces the project's template for a notification and locale.
+func (h *NotificationTemplateHandler) HandleUpsertTemplate(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.UpsertNotificationTemplateReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.templateSvc.Upsert(ctx, c.Param("id"), req)
+	if err != nil {
+		logger.WithContext(ctx, h.logger).Error("Failed to save notification template", "projectID", c.Param("id"), "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleDeleteTemplate removes a template; the notification falls back to a less specific one.
+func (h *NotificationTemplateHandler) HandleDeleteTemplate(c echo.Context) error {
+	ctx := c.Request().Context()
+	if err := h.templateSvc.Delete(ctx, c.Param("id"), c.Param("templateId")); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
@@ -0,0 +1,99 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestNotificationTemplateHandler(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) { cfg.MagicLink.URL = "https://app.example.com/magic" }))
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	var project aggregate.ProjectDto
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/projects", aggregate.CreateProjectReq{Name: "Acme"}, admin), &project)
+	path := "/api/v1/projects/" + project.ID + "/templates"
+
+	for name, bad := range map[string]aggregate.UpsertNotificationTemplateReq{
+		"unknown notification":  {Channel: "email", Name: "welcome", Subject: "Hi", Text: "Hi"},
+		"unknown variable":      {Channel: "email", Name: "magic_link", Subject: "Hi", Text: "Your code is {{code}}"},
+		"email without subject": {Channel: "email", Name: "magic_link", Text: "{{link}}"},
+		"sms with a subject":    {Channel: "sms", Name: "phone_otp", Subject: "Hi", Text: "{{code}}"},
+		"bad locale":            {Channel: "sms", Name: "phone_otp", Locale: "not a locale", Text: "{{code}}"},
+	} {
+		if resp := h.Do(t, http.MethodPut, path, bad, admin); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, resp.StatusCode)
+		}
+	}
+
+	var tmpl aggregate.NotificationTemplateResp
+	for _, req := range []aggregate.UpsertNotificationTemplateReq{
+		{Channel: "email", Name: "magic_link", Subject: "Sign in to {{appName}}", Text: "Hello {{email}}: {{link}}", HTML: `<a href="{{link}}">Sign in</a>`},
+		{Channel: "email", Name: "magic_link", Locale: "vi", Subject: "Đăng nhập {{appName}}", Text: "Xin chào: {{link}} ({{expiresInMinutes}} phút)"},
+		{Channel: "sms", Name: "phone_otp", Locale: "VI", Text: "Mã {{appName}} của bạn: {{code}}"},
+	} {
+		resp := h.Do(t, http.MethodPut, path, req, admin)
+		apitest.Decode(t, resp, &tmpl)
+		if resp.StatusCode != http.StatusOK || tmpl.ID == "" || tmpl.Variables == nil {
+			t.Fatalf("upsert %s/%s: status = %d, template = %+v", req.Name, req.Locale, resp.StatusCode, tmpl)
+		}
+	}
+	if tmpl.Locale != "vi" {
+		t.Errorf("locale = %q, want it lower-cased", tmpl.Locale)
+	}
+	var templates []aggregate.NotificationTemplateResp
+	apitest.Decode(t, h.Do(t, http.MethodGet, path, nil, admin), &templates)
+	if len(templates) != 3 {
+		t.Fatalf("templates = %+v, want 3", templates)
+	}
+
+	h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "gus@example.com", Password: "password123"}, "").Body.Close()
+	user := h.Users.First(func(u *model.User) bool { return u.Email == "gus@example.com" })
+	user.SetAttributes(map[string]any{"locale": "vi-VN"})
+	user.Phone = "+15551234567"
+	if err := h.Users.Update(context.Background(), user.ID, *user, "attributes", "phone"); err != nil {
+		t.Fatalf("set locale: %v", err)
+	}
+
+	// The user's vi-VN falls back to the vi templates.
+	login := aggregate.LoginReq{AuthType: "MAGIC_LINK", Email: "gus@example.com", ProjectID: project.ID}
+	h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "").Body.Close()
+	h.Do(t, http.MethodPost, "/api/v1/auth/login", aggregate.LoginReq{AuthType: "PHONE_OTP", Phone: user.Phone, ProjectID: project.ID}, "").Body.Close()
+	mails, texts := h.Mailer.Sent(), h.SMS.Sent()
+	if len(mails) != 1 || mails[0].Subject != "Đăng nhập "+h.Config.App.Name || !strings.Contains(mails[0].Text, "https://app.example.com/magic?token=") ||
+		!strings.Contains(mails[0].Text, "(15 phút)") || mails[0].HTML != "" {
+		t.Fatalf("sent mail = %+v, want the vi template", mails)
+	}
+	if len(texts) != 1 || !strings.HasPrefix(texts[0].Body, "Mã "+h.Config.App.Name+" của bạn: ") {
+		t.Fatalf("sent SMS = %+v, want the vi template", texts)
+	}
+
+	// Without the vi template the default one applies, with the link escaped in its HTML.
+	vi := h.Templates.First(func(m *model.NotificationTemplate) bool { return m.Channel == "email" && m.Locale == "vi" })
+	if resp := h.Do(t, http.MethodDelete, path+"/"+vi.ID, nil, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete template: status = %d", resp.StatusCode)
+	}
+	if code := apitest.Decode(t, h.Do(t, http.MethodDelete, path+"/"+vi.ID, nil, admin), nil).Code; code != int(errorx.ErrTemplateNotFound) {
+		t.Errorf("delete twice: code %d, want %d", code, errorx.ErrTemplateNotFound)
+	}
+	h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "").Body.Close()
+	mails = h.Mailer.Sent()
+	if len(mails) != 2 || mails[1].Subject != "Sign in to "+h.Config.App.Name || !strings.HasPrefix(mails[1].Text, "Hello gus@example.com: ") ||
+		!strings.Contains(mails[1].HTML, `href="https://app.example.com/magic?token=`) {
+		t.Fatalf("sent mail = %+v, want the default template", mails)
+	}
+
+	// Logins outside the project keep the built-in email.
+	login.ProjectID = ""
+	h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "").Body.Close()
+	if mails = h.Mailer.Sent(); len(mails) != 3 || strings.HasPrefix(mails[2].Text, "Hello") {
+		t.Fatalf("sent mail = %+v, want the built-in template", mails)
+	}
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/oidc"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// OIDCProviderHandler serves the endpoints that let registered clients use dreon-auth as their OpenID Provider.
+// Discovery, token and userinfo responses use the bare OIDC formats rather than the BaseResp envelope.
+type OIDCProviderHandler struct {
+	authSvc   service.IAuthSvc
+	logger    logger.ILogger
+	verifyJWT middleware.VerifyJWTMiddleware
+}
+
+func NewOIDCProviderHandler(authSvc service.IAuthSvc, logger logger.ILogger, verifyJWT middleware.VerifyJWTMiddleware) *OIDCProviderHandler {
+	return &OIDCProviderHandler{
+		authSvc:   authSvc,
+		logger:    logger,
+		verifyJWT: verifyJWT,
+	}
+}
+
+// RegisterWellKnownRoutes registers the discovery document on a group mounted at /.well-known.
+func (h *OIDCProviderHandler) RegisterWellKnownRoutes(g *echo.Group) {
+	g.GET("/openid-configuration", h.HandleDiscovery)
+}
+
+// RegisterRoutes registers the authorization, token and userinfo endpoints on a group mounted at /oauth2.
+func (h *OIDCProviderHandler) RegisterRoutes(g *echo.Group) {
+	g.GET("/authorize", h.HandleAuthorize)
+	g.POST("/token", h.HandleToken)
+
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.POST("/authorize/complete", h.HandleCompleteAuthorize)
+	g.GET("/userinfo", h.HandleUserInfo)
+	g.POST("/userinfo", h.HandleUserInfo)
+}
+
+// HandleDiscovery returns the OpenID Provider metadata.
+func (h *OIDCProviderHandler) HandleDiscovery(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.authSvc.OIDCDiscovery(ctx)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(http.StatusOK, result)
+}
+
+// HandleAuthorize validates an authentication request and redirects to the login page with ?authRequest=.
+func (h *OIDCProviderHandler) HandleAuthorize(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.OIDCAuthorizeReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	redirectURL, err := h.authSvc.OIDCAuthorize(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.Redirect(http.StatusFound, redirectURL)
+}
+
+// HandleCompleteAuthorize issues an authorization code for the signed-in user (requires JWT).
+// Body: { "authRequest": "..." }; the response redirectUrl hands the code to the client.
+func (h *OIDCProviderHandler) HandleCompleteAuthorize(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.CompleteOIDCAuthorizeReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	result, err := h.authSvc.CompleteOIDCAuthorize(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleToken exchanges an authorization code or refresh token. Clients authenticate with HTTP Basic
+// (client_secret_basic) or client_id/client_secret form fields; public clients send only client_id.
+func (h *OIDCProviderHandler) HandleToken(c echo.Context) error {
+	ctx := c.Request().Context()
+	var req aggregate.OIDCTokenReq
+	if err := c.Bind(&req); err != nil {
+		return h.tokenError(c, oidc.NewError(oidc.ErrCodeInvalidRequest, "malformed token request"))
+	}
+	if id, secret, ok := basicClientAuth(c); ok {
+		req.ClientID, req.ClientSecret = id, secret
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	result, err := h.authSvc.OIDCToken(ctx, req)
+	if err != nil {
+		return h.tokenError(c, err)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *OIDCProviderHandler) tokenError(c echo.Context, err error) error {
+	return oauthTokenError(c, h.logger, err)
+}
+
+// basicClientAuth reads client credentials sent with HTTP Basic auth (client_secret_basic).
+func basicClientAuth(c echo.Context) (clientID, clientSecret string, ok bool) {
+	id, secret, ok := c.Request().BasicAuth()
+	if !ok {
+		return "", "", false
+	}
+	// RFC 6749 section 2.3.1: credentials are form-encoded before Basic encoding.
+	clientID, _ = url.QueryUnescape(id)
+	clientSecret, _ = url.QueryUnescape(secret)
+	return clientID, clientSecret, true
+}
+
+// oauthTokenError writes an *oidc.Error as a bare OAuth 2.0 error response; other errors use HandleError.
+func oauthTokenError(c echo.Context, log logger.ILogger, err error) error {
+	var oauthErr *oidc.Error
+	if errors.As(err, &oauthErr) {
+		return c.JSON(oauthErr.Status, oauthErr)
+	}
+	logger.WithContext(c.Request().Context(), log).Error("Failed to issue tokens", "error", err)
+	return HandleError(c, err)
+}
+
+// HandleUserInfo returns the standard claims about the user the access token belongs to (requires JWT).
+func (h *OIDCProviderHandler) HandleUserInfo(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.authSvc.OIDCUserInfo(ctx)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,230 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/oidc"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestOIDCProviderHandler_AuthorizationCodeFlow(t *testing.T) {
+	const (
+		redirectURI = "https://rp.example.com/callback"
+		verifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge   = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+	h := apitest.New(t,
+		apitest.WithConfig(func(cfg *config.AppConfig) {
+			cfg.OIDC.IssuerURL = "https://id.example.com/"
+			cfg.OIDC.LoginURL = "https://id.example.com/login"
+		}),
+		func(h *apitest.Harness) {
+			h.OIDCClients = oidc.NewClientRegistryFromList([]oidc.Client{
+				{ClientID: "rp", ClientSecret: "rp-secret", RedirectURIs: []string{redirectURI}},
+			})
+		},
+	)
+
+	resp := h.Do(t, http.MethodGet, "/.well-known/openid-configuration", nil, "")
+	var discovery aggregate.OIDCDiscoveryResp
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil || discovery.Issuer != "https://id.example.com" {
+		t.Fatalf("discovery = %+v, %v", discovery, err)
+	}
+	if discovery.TokenEndpoint != "https://id.example.com/api/v1/oauth2/token" || discovery.JwksURI != "https://id.example.com/.well-known/jwks.json" {
+		t.Errorf("discovery endpoints = %+v", discovery)
+	}
+
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "gil@example.com", Password: "password123"}, "")
+	var registered aggregate.TokenResp
+	apitest.Decode(t, resp, &registered)
+	if err := h.Users.Update(context.Background(), registered.UserID, model.User{Status: "active"}, "status"); err != nil {
+		t.Fatalf("set status: %v", err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	authorize := func(query url.Values) *http.Response {
+		t.Helper()
+		resp, err := client.Get(h.Server.URL + "/api/v1/oauth2/authorize?" + query.Encode())
+		if err != nil {
+			t.Fatalf("authorize: %v", err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+	query := url.Values{
+		"client_id": {"rp"}, "redirect_uri": {redirectURI}, "response_type": {"code"}, "scope": {"openid email"},
+		"state": {"st-1"}, "nonce": {"n-1"}, "code_challenge": {challenge}, "code_challenge_method": {"S256"},
+	}
+
+	// An unregistered redirect_uri is never redirected to.
+	bad := url.Values{}
+	for k, v := range query {
+		bad[k] = v
+	}
+	bad.Set("redirect_uri", "https://evil.example.com/callback")
+	if resp := authorize(bad); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unregistered redirect_uri status = %d, want 400", resp.StatusCode)
+	}
+	bad.Set("redirect_uri", redirectURI)
+	bad.Set("scope", "email")
+	if resp := authorize(bad); !strings.HasPrefix(resp.Header.Get("Location"), redirectURI+"?error=invalid_scope") {
+		t.Errorf("missing openid scope location = %q, want an invalid_scope redirect", resp.Header.Get("Location"))
+	}
+
+	resp = authorize(query)
+	login, err := url.Parse(resp.Header.Get("Location"))
+	if resp.StatusCode != http.StatusFound || err != nil || login.Path != "/login" || login.Query().Get("authRequest") == "" {
+		t.Fatalf("authorize status = %d, location = %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	resp = h.Do(t, http.MethodPost, "/api/v1/oauth2/authorize/complete", aggregate.CompleteOIDCAuthorizeReq{AuthRequest: login.Query().Get("authRequest")}, registered.AccessToken)
+	var completed aggregate.CompleteOIDCAuthorizeResp
+	apitest.Decode(t, resp, &completed)
+	callback, err := url.Parse(completed.RedirectURL)
+	if resp.StatusCode != http.StatusOK || err != nil || callback.Query().Get("state") != "st-1" || callback.Query().Get("code") == "" {
+		t.Fatalf("complete status = %d, redirectUrl = %q", resp.StatusCode, completed.RedirectURL)
+	}
+
+	token := func(form url.Values, basicAuth bool) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, h.Server.URL+"/api/v1/oauth2/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if basicAuth {
+			req.SetBasicAuth("rp", "rp-secret")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("token: %v", err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+	exchange := url.Values{"grant_type": {"authorization_code"}, "code": {callback.Query().Get("code")}, "redirect_uri": {redirectURI}, "code_verifier": {verifier}}
+
+	if resp := token(exchange, false); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("token without client credentials status = %d, want 401", resp.StatusCode)
+	}
+	resp = token(exchange, true)
+	var tokens aggregate.OIDCTokenResp
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil || resp.StatusCode != http.StatusOK || tokens.IDToken == "" || tokens.TokenType != "Bearer" {
+		t.Fatalf("token status = %d, resp = %+v, %v", resp.StatusCode, tokens, err)
+	}
+	var idToken oidc.IDTokenClaims
+	if err := json.Unmarshal([]byte(tokens.IDToken), &idToken); err != nil {
+		t.Fatalf("decode id token: %v", err)
+	}
+	if idToken.Subject != registered.UserID || idToken.Nonce != "n-1" || idToken.Email != "gil@example.com" ||
+		idToken.Issuer != "https://id.example.com" || len(idToken.Audience) != 1 || idToken.Audience[0] != "rp" {
+		t.Errorf("id token claims = %+v", idToken)
+	}
+
+	// Codes work once.
+	resp = token(exchange, true)
+	var replay oidc.Error
+	if err := json.NewDecoder(resp.Body).Decode(&replay); err != nil || resp.StatusCode != http.StatusBadRequest || replay.Code != "invalid_grant" {
+		t.Errorf("replayed code status = %d, error = %+v", resp.StatusCode, replay)
+	}
+
+	resp = h.Do(t, http.MethodGet, "/api/v1/oauth2/userinfo", nil, tokens.AccessToken)
+	var userInfo aggregate.OIDCUserInfoResp
+	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil || userInfo.Sub != registered.UserID || userInfo.Email != "gil@example.com" {
+		t.Errorf("userinfo = %+v, %v", userInfo, err)
+	}
+
+	resp = token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}}, true)
+	var refreshed aggregate.OIDCTokenResp
+	if err := json.NewDecoder(resp.Body).Decode(&refreshed); err != nil || resp.StatusCode != http.StatusOK || refreshed.AccessToken == "" {
+		t.Errorf("refresh status = %d, resp = %+v", resp.StatusCode, refreshed)
+	}
+
+	// The ID token works as id_token_hint for RP-initiated logout.
+	resp = h.Do(t, http.MethodGet, "/api/v1/auth/end-session?id_token_hint="+url.QueryEscape(tokens.IDToken), nil, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("end-session with id token status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestOIDCProviderHandler_ClientCredentialsGrant(t *testing.T) {
+	h := apitest.New(t)
+	project, err := h.Projects.Create(context.Background(), &model.Project{Code: "reports", Name: "Reports"})
+	if err != nil {
+		t.Fatalf("seed project: %v", err)
+	}
+	if err := h.Permissions.BulkCreate(context.Background(), []model.Permission{{Code: "reports.read", Name: "Read reports"}, {Code: "reports.write", Name: "Write reports"}}); err != nil {
+		t.Fatalf("seed permissions: %v", err)
+	}
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+
+	resp := h.Do(t, http.MethodPost, "/api/v1/projects/"+project.ID+"/service-accounts", aggregate.CreateServiceAccountReq{
+		Name:        "report-exporter",
+		Permissions: []string{"reports.read", "reports.write"},
+	}, admin)
+	var account aggregate.CreateServiceAccountResp
+	if body := apitest.Decode(t, resp, &account); resp.StatusCode != http.StatusOK || account.ClientSecret == "" {
+		t.Fatalf("create status = %d, body = %+v", resp.StatusCode, body)
+	}
+
+	token := func(form url.Values, secret string) *http.Response {
+		t.Helper()
+		form.Set("grant_type", "client_credentials")
+		req, _ := http.NewRequest(http.MethodPost, h.Server.URL+"/api/v1/auth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(account.ClientID, secret)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("token: %v", err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	resp = token(url.Values{}, "wrong")
+	var oauthErr oidc.Error
+	if json.NewDecoder(resp.Body).Decode(&oauthErr); resp.StatusCode != http.StatusUnauthorized || oauthErr.Code != oidc.ErrCodeInvalidClient {
+		t.Errorf("wrong secret status = %d, error = %+v", resp.StatusCode, oauthErr)
+	}
+	resp = token(url.Values{"scope": {"reports.delete"}}, account.ClientSecret)
+	if json.NewDecoder(resp.Body).Decode(&oauthErr); resp.StatusCode != http.StatusBadRequest || oauthErr.Code != oidc.ErrCodeInvalidScope {
+		t.Errorf("ungranted scope status = %d, error = %+v", resp.StatusCode, oauthErr)
+	}
+
+	resp = token(url.Values{"scope": {"reports.read"}}, account.ClientSecret)
+	var tokens aggregate.OIDCTokenResp
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil || resp.StatusCode != http.StatusOK || tokens.Scope != "reports.read" {
+		t.Fatalf("token status = %d, resp = %+v, %v", resp.StatusCode, tokens, err)
+	}
+	payload, err := h.Jwt.Verify(context.Background(), tokens.AccessToken)
+	if err != nil || payload.ProjectID != project.ID || payload.ServiceAccountID != account.ID || payload.UserID != "" {
+		t.Fatalf("token payload = %+v, %v", payload, err)
+	}
+
+	resp = h.Do(t, http.MethodGet, "/api/v1/permissions", nil, tokens.AccessToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("service account status = %d, want 200", resp.StatusCode)
+	}
+	resp = h.Do(t, http.MethodGet, "/api/v1/projects", nil, tokens.AccessToken)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("super admin route status = %d, want 403", resp.StatusCode)
+	}
+
+	resp = h.Do(t, http.MethodDelete, "/api/v1/projects/"+project.ID+"/service-accounts/"+account.ID, nil, admin)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete status = %d", resp.StatusCode)
+	}
+	if resp := token(url.Values{}, account.ClientSecret); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("deleted account status = %d, want 401", resp.StatusCode)
+	}
+}
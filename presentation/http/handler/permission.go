@@ -1,29 +1,109 @@
 package handler
 
 import (
-	"github.com/hiamthach108/dreon-auth/internal/shared/permission"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
 	"github.com/hiamthach108/dreon-auth/presentation/http/middleware"
 	"github.com/labstack/echo/v4"
 )
 
 type PermissionHandler struct {
-	registry  *permission.Registry
-	verifyJWT middleware.VerifyJWTMiddleware
+	permissionSvc service.IPermissionSvc
+	roleSvc       service.IRoleSvc
+	verifyJWT     middleware.VerifyJWTMiddleware
+	authorize     middleware.AuthorizeMiddleware
 }
 
-func NewPermissionHandler(registry *permission.Registry, verifyJWT middleware.VerifyJWTMiddleware) *PermissionHandler {
-	return &PermissionHandler{registry: registry, verifyJWT: verifyJWT}
+func NewPermissionHandler(permissionSvc service.IPermissionSvc, roleSvc service.IRoleSvc, verifyJWT middleware.VerifyJWTMiddleware, authorize middleware.AuthorizeMiddleware) *PermissionHandler {
+	return &PermissionHandler{permissionSvc: permissionSvc, roleSvc: roleSvc, verifyJWT: verifyJWT, authorize: authorize}
 }
 
+// RegisterRoutes registers the system catalog and the permission check on /permissions.
 func (h *PermissionHandler) RegisterRoutes(g *echo.Group) {
+	h.registerCatalogRoutes(g)
+	g.POST("/check", h.HandleCheckPermission)
+}
+
+// RegisterProjectRoutes registers the codes a project defines on a group mounted at /projects/:id/permissions.
+func (h *PermissionHandler) RegisterProjectRoutes(g *echo.Group) {
+	h.registerCatalogRoutes(g)
+}
+
+func (h *PermissionHandler) registerCatalogRoutes(g *echo.Group) {
 	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
 	g.GET("", h.HandleListPermissions)
+	g.GET("/:code", h.HandleGetPermission)
+	g.POST("", h.HandleCreatePermission)
+	g.PUT("/:code", h.HandleUpdatePermission)
+	g.DELETE("/:code", h.HandleDeletePermission)
 }
 
 func (h *PermissionHandler) HandleListPermissions(c echo.Context) error {
-	if h.registry == nil {
-		return HandleSuccess(c, []struct{}{})
+	result, err := h.permissionSvc.ListPermissions(c.Request().Context(), permissionProject(c))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+func (h *PermissionHandler) HandleGetPermission(c echo.Context) error {
+	result, err := h.permissionSvc.GetPermission(c.Request().Context(), permissionProject(c), c.Param("code"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+func (h *PermissionHandler) HandleCreatePermission(c echo.Context) error {
+	req, err := HandleValidateBind[aggregate.CreatePermissionReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	result, err := h.permissionSvc.CreatePermission(c.Request().Context(), permissionProject(c), req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+func (h *PermissionHandler) HandleUpdatePermission(c echo.Context) error {
+	req, err := HandleValidateBind[aggregate.UpdatePermissionReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	result, err := h.permissionSvc.UpdatePermission(c.Request().Context(), permissionProject(c), c.Param("code"), req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+func (h *PermissionHandler) HandleDeletePermission(c echo.Context) error {
+	if err := h.permissionSvc.DeletePermission(c.Request().Context(), permissionProject(c), c.Param("code")); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, map[string]string{"message": "Permission deleted successfully"})
+}
+
+// HandleCheckPermission reports whether a user holds a permission code, with the roles that grant it
+func (h *PermissionHandler) HandleCheckPermission(c echo.Context) error {
+	req, err := HandleValidateBind[aggregate.CheckPermissionReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	result, err := h.roleSvc.CheckUserPermission(c.Request().Context(), req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// permissionProject returns the project of /projects/:id/permissions routes, or nil for the system catalog.
+func permissionProject(c echo.Context) *string {
+	if projectID := c.Param("id"); projectID != "" {
+		return &projectID
 	}
-	list := h.registry.List()
-	return HandleSuccess(c, list)
+	return nil
 }
@@ -13,31 +13,36 @@ import (
 
 // ProjectHandler handles HTTP requests for project CRUD.
 type ProjectHandler struct {
-	projectSvc       service.IProjectSvc
-	logger           logger.ILogger
-	verifyJWT        echomw.VerifyJWTMiddleware
-	verifySuperAdmin echomw.VerifySuperAdminMiddleware
+	projectSvc service.IProjectSvc
+	usageSvc   service.IProjectUsageSvc
+	logger     logger.ILogger
+	verifyJWT  echomw.VerifyJWTMiddleware
+	authorize  echomw.AuthorizeMiddleware
 }
 
 // NewProjectHandler creates a new project handler.
-func NewProjectHandler(projectSvc service.IProjectSvc, logger logger.ILogger, verifyJWT echomw.VerifyJWTMiddleware, verifySuperAdmin echomw.VerifySuperAdminMiddleware) *ProjectHandler {
+func NewProjectHandler(projectSvc service.IProjectSvc, usageSvc service.IProjectUsageSvc, logger logger.ILogger, verifyJWT echomw.VerifyJWTMiddleware, authorize echomw.AuthorizeMiddleware) *ProjectHandler {
 	return &ProjectHandler{
-		projectSvc:       projectSvc,
-		logger:           logger,
-		verifyJWT:        verifyJWT,
-		verifySuperAdmin: verifySuperAdmin,
+		projectSvc: projectSvc,
+		usageSvc:   usageSvc,
+		logger:     logger,
+		verifyJWT:  verifyJWT,
+		authorize:  authorize,
 	}
 }
 
-// RegisterRoutes registers project routes on the given group and applies JWT verification middleware.
+// RegisterRoutes registers project routes on the given group and applies JWT verification and authorization middleware.
 func (h *ProjectHandler) RegisterRoutes(g *echo.Group) {
 	g.Use(echo.MiddlewareFunc(h.verifyJWT))
-	g.Use(echo.MiddlewareFunc(h.verifySuperAdmin))
+	g.Use(echo.MiddlewareFunc(h.authorize))
 	g.GET("", h.HandleListProjects)
 	g.GET("/:id", h.HandleGetProjectByID)
 	g.POST("", h.HandleCreateProject)
 	g.PUT("/:id", h.HandleUpdateProject)
 	g.DELETE("/:id", h.HandleDeleteProject)
+	g.GET("/:id/usage", h.HandleGetProjectUsage)
+	g.POST("/:id/archive", h.HandleArchiveProject)
+	g.POST("/:id/restore", h.HandleRestoreProject)
 }
 
 // List returns a paginated list of projects.
@@ -55,7 +60,7 @@ func (h *ProjectHandler) HandleListProjects(c echo.Context) error {
 
 	result, err := h.projectSvc.List(ctx, page, pageSize)
 	if err != nil {
-		h.logger.Error("Failed to list projects", "error", err)
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to list projects", "error", err)
 		return HandleError(c, err)
 	}
 	return HandleSuccess(c, result)
@@ -71,7 +76,7 @@ func (h *ProjectHandler) HandleGetProjectByID(c echo.Context) error {
 
 	project, err := h.projectSvc.GetByID(ctx, id)
 	if err != nil {
-		h.logger.Error("Failed to get project", "id", id, "error", err)
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to get project", "id", id, "error", err)
 		return HandleError(c, err)
 	}
 	return HandleSuccess(c, project)
@@ -83,13 +88,13 @@ func (h *ProjectHandler) HandleCreateProject(c echo.Context) error {
 
 	req, err := HandleValidateBind[aggregate.CreateProjectReq](c)
 	if err != nil {
-		h.logger.Error("Failed to bind create project request", "error", err)
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to bind create project request", "error", err)
 		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
 	}
 
 	project, err := h.projectSvc.Create(ctx, req)
 	if err != nil {
-		h.logger.Error("Failed to create project", "error", err)
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to create project", "error", err)
 		return HandleError(c, err)
 	}
 	return HandleSuccess(c, project)
@@ -105,13 +110,13 @@ func (h *ProjectHandler) HandleUpdateProject(c echo.Context) error {
 
 	req, err := HandleValidateBind[aggregate.UpdateProjectReq](c)
 	if err != nil {
-		h.logger.Error("Failed to bind update project request", "error", err)
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to bind update project request", "error", err)
 		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
 	}
 
 	project, err := h.projectSvc.Update(ctx, id, req)
 	if err != nil {
-		h.logger.Error("Failed to update project", "id", id, "error", err)
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to update project", "id", id, "error", err)
 		return HandleError(c, err)
 	}
 	return HandleSuccess(c, project)
@@ -126,8 +131,38 @@ func (h *ProjectHandler) HandleDeleteProject(c echo.Context) error {
 	}
 
 	if err := h.projectSvc.Delete(ctx, id); err != nil {
-		h.logger.Error("Failed to delete project", "id", id, "error", err)
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to delete project", "id", id, "error", err)
 		return HandleError(c, err)
 	}
 	return HandleSuccess(c, nil)
 }
+
+// HandleGetProjectUsage returns the project's metered requests for the current day and month against its quota.
+func (h *ProjectHandler) HandleGetProjectUsage(c echo.Context) error {
+	ctx := c.Request().Context()
+	usage, err := h.usageSvc.GetUsage(ctx, c.Param("id"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, usage)
+}
+
+// HandleArchiveProject archives a project, blocking logins and access changes in it until it is restored.
+func (h *ProjectHandler) HandleArchiveProject(c echo.Context) error {
+	ctx := c.Request().Context()
+	project, err := h.projectSvc.Archive(ctx, c.Param("id"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, project)
+}
+
+// HandleRestoreProject restores an archived project.
+func (h *ProjectHandler) HandleRestoreProject(c echo.Context) error {
+	ctx := c.Request().Context()
+	project, err := h.projectSvc.Restore(ctx, c.Param("id"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, project)
+}
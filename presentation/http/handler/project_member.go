@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type ProjectMemberHandler struct {
+	memberSvc service.IProjectMemberSvc
+	logger    logger.ILogger
+	verifyJWT middleware.VerifyJWTMiddleware
+	authorize middleware.AuthorizeMiddleware
+}
+
+func NewProjectMemberHandler(
+	memberSvc service.IProjectMemberSvc,
+	logger logger.ILogger,
+	verifyJWT middleware.VerifyJWTMiddleware,
+	authorize middleware.AuthorizeMiddleware,
+) *ProjectMemberHandler {
+	return &ProjectMemberHandler{
+		memberSvc: memberSvc,
+		logger:    logger,
+		verifyJWT: verifyJWT,
+		authorize: authorize,
+	}
+}
+
+// RegisterRoutes registers project membership on a group mounted at /projects/:id/members.
+func (h *ProjectMemberHandler) RegisterRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
+	g.GET("", h.HandleListMembers)
+	g.POST("", h.HandleInviteMember)
+	g.POST("/accept", h.HandleAcceptInvitation)
+	g.DELETE("/:userId", h.HandleRemoveMember)
+}
+
+// HandleListMembers lists the project's members, optionally filtered by ?status=invited|active.
+func (h *ProjectMemberHandler) HandleListMembers(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.ListProjectMembersReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	result, err := h.memberSvc.List(ctx, c.Param("id"), req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleInviteMember invites a user to the project; they become a member once they accept.
+func (h *ProjectMemberHandler) HandleInviteMember(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.InviteProjectMemberReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	result, err := h.memberSvc.Invite(ctx, c.Param("id"), req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleAcceptInvitation makes the caller an active member of a project they were invited to.
+func (h *ProjectMemberHandler) HandleAcceptInvitation(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.memberSvc.Accept(ctx, c.Param("id"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleRemoveMember removes a member or pending invitation, along with the user's roles in the project.
+func (h *ProjectMemberHandler) HandleRemoveMember(c echo.Context) error {
+	ctx := c.Request().Context()
+	if err := h.memberSvc.Remove(ctx, c.Param("id"), c.Param("userId")); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
@@ -0,0 +1,80 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestProjectMemberHandler_Membership(t *testing.T) {
+	h := apitest.New(t)
+	ctx := context.Background()
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	project, _ := h.Projects.Create(ctx, &model.Project{Code: "acme", Name: "Acme"})
+	hana, _ := h.Users.Create(ctx, &model.User{Username: "hana", Email: "hana@example.com"})
+	hanaToken := h.Token(jwt.Payload{UserID: hana.ID})
+	editor, _ := h.Roles.Create(ctx, &model.Role{Code: "editor", Name: "Editor", ProjectID: &project.ID, Permissions: model.PermissionsToJSON([]string{"docs.edit"})})
+	members := "/api/v1/projects/" + project.ID + "/members"
+
+	resp := h.Do(t, http.MethodPost, members, aggregate.InviteProjectMemberReq{Email: "hana@example.com"}, admin)
+	var invited aggregate.ProjectMemberResp
+	apitest.Decode(t, resp, &invited)
+	if resp.StatusCode != http.StatusOK || invited.UserID != hana.ID || invited.Status != constant.ProjectMemberInvited {
+		t.Fatalf("invite: status %d, %+v", resp.StatusCode, invited)
+	}
+	if resp := h.Do(t, http.MethodPost, members, aggregate.InviteProjectMemberReq{UserID: hana.ID}, admin); resp.StatusCode != http.StatusConflict {
+		t.Errorf("second invite: status %d, want 409", resp.StatusCode)
+	}
+
+	// Invited users are not members yet, so project-scoped access is refused.
+	assign := aggregate.AssignRoleToUserReq{UserID: hana.ID, RoleID: editor.ID, ProjectID: &project.ID}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/roles/assign", assign, admin); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("assign to invited user: status %d, want 403", resp.StatusCode)
+	}
+	grant := aggregate.GrantRelationReq{Namespace: "project", ObjectID: project.ID, Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: hana.ID}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/relations/grant", grant, admin); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("grant to invited user: status %d, want 403", resp.StatusCode)
+	}
+
+	if resp := h.Do(t, http.MethodPost, members+"/accept", nil, h.Token(jwt.Payload{UserID: "stranger"})); resp.StatusCode == http.StatusOK {
+		t.Error("accepted an invitation that was never sent")
+	}
+	resp = h.Do(t, http.MethodPost, members+"/accept", nil, hanaToken)
+	var accepted aggregate.ProjectMemberResp
+	apitest.Decode(t, resp, &accepted)
+	if resp.StatusCode != http.StatusOK || accepted.Status != constant.ProjectMemberActive || accepted.AcceptedAt == nil {
+		t.Fatalf("accept: status %d, %+v", resp.StatusCode, accepted)
+	}
+	var active []aggregate.ProjectMemberResp
+	apitest.Decode(t, h.Do(t, http.MethodGet, members+"?status=active", nil, admin), &active)
+	if len(active) != 1 || active[0].UserID != hana.ID {
+		t.Errorf("active members = %+v", active)
+	}
+	if resp := h.Do(t, http.MethodGet, members, nil, hanaToken); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("member lists members: status %d, want 403", resp.StatusCode)
+	}
+
+	if resp := h.Do(t, http.MethodPost, "/api/v1/roles/assign", assign, admin); resp.StatusCode != http.StatusOK {
+		t.Errorf("assign to member: status %d", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/relations/grant", grant, admin); resp.StatusCode != http.StatusOK {
+		t.Errorf("grant to member: status %d", resp.StatusCode)
+	}
+
+	// Removing the member also takes away their roles in the project.
+	if resp := h.Do(t, http.MethodDelete, members+"/"+hana.ID, nil, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("remove member: status %d", resp.StatusCode)
+	}
+	if h.ProjectMembers.Len() != 0 || h.UserRoles.Len() != 0 {
+		t.Errorf("after removal: %d members, %d role assignments; want none", h.ProjectMembers.Len(), h.UserRoles.Len())
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/roles/assign", assign, admin); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("assign to removed member: status %d, want 403", resp.StatusCode)
+	}
+}
@@ -0,0 +1,120 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestProjectHandler_UsageQuota(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) { cfg.ProjectQuota.DailyRequests = 3 }))
+	ctx := context.Background()
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	project, _ := h.Projects.Create(ctx, &model.Project{Code: "metered", Name: "Metered"})
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "ivy@example.com", Password: "password123"}, "")
+	resp.Body.Close()
+
+	login := aggregate.LoginReq{AuthType: "EMAIL", Email: "ivy@example.com", Password: "password123", ProjectID: project.ID}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	var tokens aggregate.LoginResp
+	apitest.Decode(t, resp, &tokens)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("login: status %d", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodGet, "/api/v1/auth/session", nil, tokens.AccessToken); resp.StatusCode != http.StatusOK {
+		t.Errorf("session: status %d", resp.StatusCode)
+	}
+	check := aggregate.CheckRelationReq{Namespace: "document", ObjectID: "readme", Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: "ivy"}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/relations/check", check, tokens.AccessToken); resp.StatusCode != http.StatusOK {
+		t.Errorf("relation check: status %d", resp.StatusCode)
+	}
+
+	// The fourth request of the day is over the project quota, whatever its kind.
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("login over quota: status %d, want 429", resp.StatusCode)
+	}
+	// Requests outside the project are not metered.
+	if resp := h.Do(t, http.MethodPost, "/api/v1/relations/check", check, admin); resp.StatusCode != http.StatusOK {
+		t.Errorf("unscoped relation check: status %d", resp.StatusCode)
+	}
+
+	resp = h.Do(t, http.MethodGet, "/api/v1/projects/"+project.ID+"/usage", nil, admin)
+	var usage aggregate.ProjectUsageResp
+	apitest.Decode(t, resp, &usage)
+	if resp.StatusCode != http.StatusOK || usage.Daily.Used != 4 || usage.Daily.Limit != 3 {
+		t.Fatalf("usage: status %d, %+v", resp.StatusCode, usage)
+	}
+	want := map[string]int64{constant.ProjectUsageLogin: 1, constant.ProjectUsageTokenValidation: 1, constant.ProjectUsageRelationCheck: 1}
+	for kind, n := range want {
+		if got := usage.ByKind[kind]; got.Daily != n || got.Monthly != n {
+			t.Errorf("usage of %s = %+v, want %d", kind, got, n)
+		}
+	}
+	if resp := h.Do(t, http.MethodGet, "/api/v1/projects/missing/usage", nil, admin); resp.StatusCode == http.StatusOK {
+		t.Error("usage of a missing project succeeded")
+	}
+}
+
+func TestProjectHandler_Archive(t *testing.T) {
+	h := apitest.New(t)
+	ctx := context.Background()
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	project, _ := h.Projects.Create(ctx, &model.Project{Code: "old", Name: "Old"})
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "jo@example.com", Password: "password123"}, "")
+	resp.Body.Close()
+	jo, _ := h.Users.FindByEmail(ctx, "jo@example.com")
+	h.ProjectMembers.Create(ctx, &model.ProjectMember{ProjectID: project.ID, UserID: jo.ID, Status: constant.ProjectMemberActive})
+	viewer, _ := h.Roles.Create(ctx, &model.Role{Code: "viewer", Name: "Viewer", ProjectID: &project.ID})
+	login := aggregate.LoginReq{AuthType: "EMAIL", Email: "jo@example.com", Password: "password123", ProjectID: project.ID}
+	assign := aggregate.AssignRoleToUserReq{UserID: jo.ID, RoleID: viewer.ID, ProjectID: &project.ID}
+	grant := aggregate.GrantRelationReq{Namespace: "project", ObjectID: project.ID, Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: jo.ID}
+	base := "/api/v1/projects/" + project.ID
+
+	if resp := h.Do(t, http.MethodPost, "/api/v1/relations/grant", grant, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("grant before archive: status %d", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodPost, base+"/archive", nil, h.Token(jwt.Payload{UserID: jo.ID})); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin archive: status %d, want 403", resp.StatusCode)
+	}
+	resp = h.Do(t, http.MethodPost, base+"/archive", nil, admin)
+	var archived aggregate.ProjectDto
+	apitest.Decode(t, resp, &archived)
+	if resp.StatusCode != http.StatusOK || archived.ArchivedAt == nil {
+		t.Fatalf("archive: status %d, %+v", resp.StatusCode, archived)
+	}
+
+	// Archived projects keep their data but refuse logins and access changes.
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("login to archived project: status %d, want 403", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/roles/assign", assign, admin); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("assign in archived project: status %d, want 403", resp.StatusCode)
+	}
+	revoke := aggregate.RevokeRelationReq{Namespace: "project", ObjectID: project.ID, Relation: "viewer", SubjectNamespace: "user", SubjectObjectID: jo.ID}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/relations/revoke", revoke, admin); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("revoke in archived project: status %d, want 403", resp.StatusCode)
+	}
+	if h.Projects.Len() != 1 || h.ProjectMembers.Len() != 1 {
+		t.Errorf("archive dropped data: %d projects, %d members", h.Projects.Len(), h.ProjectMembers.Len())
+	}
+
+	resp = h.Do(t, http.MethodPost, base+"/restore", nil, admin)
+	var restored aggregate.ProjectDto
+	apitest.Decode(t, resp, &restored)
+	if resp.StatusCode != http.StatusOK || restored.ArchivedAt != nil {
+		t.Fatalf("restore: status %d, %+v", resp.StatusCode, restored)
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("login after restore: status %d", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/roles/assign", assign, admin); resp.StatusCode != http.StatusOK {
+		t.Errorf("assign after restore: status %d", resp.StatusCode)
+	}
+}
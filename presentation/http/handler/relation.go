@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"net/http"
+
 	"github.com/hiamthach108/dreon-auth/internal/aggregate"
 	"github.com/hiamthach108/dreon-auth/internal/errorx"
 	"github.com/hiamthach108/dreon-auth/internal/service"
@@ -13,31 +15,44 @@ type RelationHandler struct {
 	relationSvc service.IRelationSvc
 	logger      logger.ILogger
 	verifyJWT   middleware.VerifyJWTMiddleware
+	authorize   middleware.AuthorizeMiddleware
 }
 
 func NewRelationHandler(
 	relationSvc service.IRelationSvc,
 	logger logger.ILogger,
 	verifyJWT middleware.VerifyJWTMiddleware,
+	authorize middleware.AuthorizeMiddleware,
 ) *RelationHandler {
 	return &RelationHandler{
 		relationSvc: relationSvc,
 		logger:      logger,
 		verifyJWT:   verifyJWT,
+		authorize:   authorize,
 	}
 }
 
 func (h *RelationHandler) RegisterRoutes(g *echo.Group) {
 	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
 
 	g.POST("/grant", h.HandleGrantRelation)
 	g.POST("/revoke", h.HandleRevokeRelation)
+	g.POST("/extend", h.HandleExtendRelation)
 	g.POST("/bulk-grant", h.HandleBulkGrantRelations)
 	g.POST("/bulk-revoke", h.HandleBulkRevokeRelations)
 	g.POST("/check", h.HandleCheckRelation)
 	g.GET("/list", h.HandleListRelations)
 	g.POST("/expand", h.HandleExpandRelation)
+	g.POST("/list-objects", h.HandleListObjects)
+	g.GET("/export", h.HandleExportRelations)
+	g.POST("/import", h.HandleImportRelations)
 	g.DELETE("/cleanup", h.HandleCleanupExpired)
+
+	g.GET("/namespaces", h.HandleListNamespaces)
+	g.GET("/namespaces/:name", h.HandleGetNamespace)
+	g.PUT("/namespaces/:name", h.HandleUpsertNamespace)
+	g.DELETE("/namespaces/:name", h.HandleDeleteNamespace)
 }
 
 // HandleGrantRelation grants a relation to a subject
@@ -71,6 +86,22 @@ func (h *RelationHandler) HandleRevokeRelation(c echo.Context) error {
 	return HandleSuccess(c, map[string]string{"message": "Relation revoked successfully"})
 }
 
+// HandleExtendRelation renews the expiry of a relation
+func (h *RelationHandler) HandleExtendRelation(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.ExtendRelationReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.relationSvc.ExtendRelation(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+
+	return HandleSuccess(c, result)
+}
+
 // HandleBulkGrantRelations grants multiple relations
 func (h *RelationHandler) HandleBulkGrantRelations(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -150,6 +181,62 @@ func (h *RelationHandler) HandleExpandRelation(c echo.Context) error {
 	return HandleSuccess(c, result)
 }
 
+// HandleListObjects lists the objects a subject has a relation on
+func (h *RelationHandler) HandleListObjects(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.ListObjectsReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.relationSvc.ListObjects(ctx, req.SubjectNamespace, req.SubjectObjectID, req.Namespace, req.Relation)
+	if err != nil {
+		return HandleError(c, err)
+	}
+
+	return HandleSuccess(c, result)
+}
+
+// HandleExportRelations streams the matching relations as NDJSON
+func (h *RelationHandler) HandleExportRelations(c echo.Context) error {
+	ctx := c.Request().Context()
+	var req aggregate.ExportRelationsReq
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	if _, err := h.relationSvc.ExportRelations(ctx, req, c.Response()); err != nil {
+		if !c.Response().Committed {
+			return HandleError(c, err)
+		}
+		// The stream has started; the client sees a truncated body.
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to export relations", "error", err)
+		return nil
+	}
+	if !c.Response().Committed {
+		c.Response().WriteHeader(http.StatusOK)
+	}
+
+	return nil
+}
+
+// HandleImportRelations grants the relations in an NDJSON body
+func (h *RelationHandler) HandleImportRelations(c echo.Context) error {
+	ctx := c.Request().Context()
+	var req aggregate.ImportRelationsReq
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.relationSvc.ImportRelations(ctx, req, c.Request().Body)
+	if err != nil {
+		return HandleError(c, err)
+	}
+
+	return HandleSuccess(c, result)
+}
+
 // HandleCleanupExpired removes expired relations
 func (h *RelationHandler) HandleCleanupExpired(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -164,3 +251,48 @@ func (h *RelationHandler) HandleCleanupExpired(c echo.Context) error {
 		"count":   count,
 	})
 }
+
+// HandleListNamespaces lists the namespaces that have rewrite rules
+func (h *RelationHandler) HandleListNamespaces(c echo.Context) error {
+	result, err := h.relationSvc.ListNamespaces(c.Request().Context())
+	if err != nil {
+		return HandleError(c, err)
+	}
+
+	return HandleSuccess(c, result)
+}
+
+// HandleGetNamespace returns the rewrite rules of a namespace
+func (h *RelationHandler) HandleGetNamespace(c echo.Context) error {
+	result, err := h.relationSvc.GetNamespace(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+
+	return HandleSuccess(c, result)
+}
+
+// HandleUpsertNamespace creates or replaces the rewrite rules of a namespace
+func (h *RelationHandler) HandleUpsertNamespace(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.UpsertRelationNamespaceReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.relationSvc.UpsertNamespace(ctx, c.Param("name"), req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+
+	return HandleSuccess(c, result)
+}
+
+// HandleDeleteNamespace removes the rewrite rules of a namespace
+func (h *RelationHandler) HandleDeleteNamespace(c echo.Context) error {
+	if err := h.relationSvc.DeleteNamespace(c.Request().Context(), c.Param("name")); err != nil {
+		return HandleError(c, err)
+	}
+
+	return HandleSuccess(c, nil)
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+)
+
+// fakeRelationSvc exports and imports raw NDJSON lines, recording what the handler passed it.
+type fakeRelationSvc struct {
+	service.IRelationSvc
+	export    string
+	exportReq aggregate.ExportRelationsReq
+	importReq aggregate.ImportRelationsReq
+	imported  string
+}
+
+func (f *fakeRelationSvc) ExportRelations(ctx context.Context, req aggregate.ExportRelationsReq, w io.Writer) (int, error) {
+	f.exportReq = req
+	n, err := io.WriteString(w, f.export)
+	return n, err
+}
+
+func (f *fakeRelationSvc) ImportRelations(ctx context.Context, req aggregate.ImportRelationsReq, r io.Reader) (*aggregate.ImportRelationsResp, error) {
+	f.importReq = req
+	body, err := io.ReadAll(r)
+	f.imported = string(body)
+	return &aggregate.ImportRelationsResp{DryRun: req.DryRun}, err
+}
+
+func TestRelationHandler_ExportImport(t *testing.T) {
+	svc := &fakeRelationSvc{export: `{"namespace":"document"}` + "\n"}
+	h := NewRelationHandler(svc, testutil.NewLogger(), nil, nil)
+
+	c, rec := newTestContext(http.MethodGet, "/api/v1/relations/export?namespace=document&relation=viewer", "")
+	if err := h.HandleExportRelations(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" || rec.Body.String() != svc.export {
+		t.Errorf("export: status %d, content type %q, body %q", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	if svc.exportReq != (aggregate.ExportRelationsReq{Namespace: "document", Relation: "viewer"}) {
+		t.Errorf("export request = %+v", svc.exportReq)
+	}
+
+	c, rec = newTestContext(http.MethodPost, "/api/v1/relations/import?dryRun=true&onConflict=fail", svc.export)
+	if err := h.HandleImportRelations(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || svc.imported != svc.export || svc.importReq != (aggregate.ImportRelationsReq{DryRun: true, OnConflict: "fail"}) {
+		t.Errorf("import: status %d, request %+v, body %q", rec.Code, svc.importReq, svc.imported)
+	}
+}
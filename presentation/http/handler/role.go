@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"strconv"
+
 	"github.com/hiamthach108/dreon-auth/internal/aggregate"
 	"github.com/hiamthach108/dreon-auth/internal/errorx"
 	"github.com/hiamthach108/dreon-auth/internal/service"
@@ -10,29 +12,30 @@ import (
 )
 
 type RoleHandler struct {
-	roleSvc          service.IRoleSvc
-	logger           logger.ILogger
-	verifyJWT        middleware.VerifyJWTMiddleware
-	verifySuperAdmin middleware.VerifySuperAdminMiddleware
+	roleSvc   service.IRoleSvc
+	logger    logger.ILogger
+	verifyJWT middleware.VerifyJWTMiddleware
+	authorize middleware.AuthorizeMiddleware
 }
 
 func NewRoleHandler(
 	roleSvc service.IRoleSvc,
 	logger logger.ILogger,
 	verifyJWT middleware.VerifyJWTMiddleware,
-	verifySuperAdmin middleware.VerifySuperAdminMiddleware,
+	authorize middleware.AuthorizeMiddleware,
 ) *RoleHandler {
 	return &RoleHandler{
-		roleSvc:          roleSvc,
-		logger:           logger,
-		verifyJWT:        verifyJWT,
-		verifySuperAdmin: verifySuperAdmin,
+		roleSvc:   roleSvc,
+		logger:    logger,
+		verifyJWT: verifyJWT,
+		authorize: authorize,
 	}
 }
 
 func (h *RoleHandler) RegisterRoutes(g *echo.Group) {
-	// All routes require JWT authentication
+	// All routes require JWT authentication; access rules are declared in middleware.RouteAccess
 	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
 
 	// Role CRUD - Create, Update, Delete require super admin for system roles
 	g.POST("", h.HandleCreateRole)
@@ -40,11 +43,15 @@ func (h *RoleHandler) RegisterRoutes(g *echo.Group) {
 	g.PUT("/:id", h.HandleUpdateRole)
 	g.DELETE("/:id", h.HandleDeleteRole)
 	g.GET("", h.HandleListRoles)
+	g.GET("/:id/history", h.HandleGetRoleHistory)
 
 	// User role assignments - require super admin for system roles
 	g.POST("/assign", h.HandleAssignRoleToUser)
 	g.POST("/remove", h.HandleRemoveRoleFromUser)
+	g.POST("/bulk-assign", h.HandleBulkAssignRoles)
+	g.POST("/bulk-remove", h.HandleBulkRemoveRoles)
 	g.GET("/user/:userId/permissions", h.HandleGetUserPermissions)
+	g.POST("/users/permissions", h.HandleGetUsersPermissions)
 }
 
 // HandleCreateRole creates a new role
@@ -55,11 +62,7 @@ func (h *RoleHandler) HandleCreateRole(c echo.Context) error {
 		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
 	}
 
-	// Get JWT payload to check if user is super admin
-	payload := middleware.GetJWTPayload(ctx)
-	isSuperAdmin := payload != nil && payload.IsSuperAdmin
-
-	result, err := h.roleSvc.CreateRole(ctx, req, isSuperAdmin)
+	result, err := h.roleSvc.CreateRole(ctx, req)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -89,11 +92,7 @@ func (h *RoleHandler) HandleUpdateRole(c echo.Context) error {
 		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
 	}
 
-	// Get JWT payload to check if user is super admin
-	payload := middleware.GetJWTPayload(ctx)
-	isSuperAdmin := payload != nil && payload.IsSuperAdmin
-
-	result, err := h.roleSvc.UpdateRole(ctx, roleID, req, isSuperAdmin)
+	result, err := h.roleSvc.UpdateRole(ctx, roleID, req)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -106,17 +105,27 @@ func (h *RoleHandler) HandleDeleteRole(c echo.Context) error {
 	ctx := c.Request().Context()
 	roleID := c.Param("id")
 
-	// Get JWT payload to check if user is super admin
-	payload := middleware.GetJWTPayload(ctx)
-	isSuperAdmin := payload != nil && payload.IsSuperAdmin
-
-	if err := h.roleSvc.DeleteRole(ctx, roleID, isSuperAdmin); err != nil {
+	if err := h.roleSvc.DeleteRole(ctx, roleID); err != nil {
 		return HandleError(c, err)
 	}
 
 	return HandleSuccess(c, map[string]string{"message": "Role deleted successfully"})
 }
 
+// HandleGetRoleHistory returns the recorded changes to a role and its assignments, newest first.
+// Query: page (default 1), pageSize (default 10, max 100).
+func (h *RoleHandler) HandleGetRoleHistory(c echo.Context) error {
+	ctx := c.Request().Context()
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	pageSize, _ := strconv.Atoi(c.QueryParam("pageSize"))
+
+	result, err := h.roleSvc.GetRoleHistory(ctx, c.Param("id"), page, pageSize)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
 // HandleListRoles lists roles with optional filters
 func (h *RoleHandler) HandleListRoles(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -141,11 +150,7 @@ func (h *RoleHandler) HandleAssignRoleToUser(c echo.Context) error {
 		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
 	}
 
-	// Get JWT payload to check if user is super admin
-	payload := middleware.GetJWTPayload(ctx)
-	isSuperAdmin := payload != nil && payload.IsSuperAdmin
-
-	result, err := h.roleSvc.AssignRoleToUser(ctx, req, isSuperAdmin)
+	result, err := h.roleSvc.AssignRoleToUser(ctx, req)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -161,17 +166,45 @@ func (h *RoleHandler) HandleRemoveRoleFromUser(c echo.Context) error {
 		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
 	}
 
-	// Get JWT payload to check if user is super admin
-	payload := middleware.GetJWTPayload(ctx)
-	isSuperAdmin := payload != nil && payload.IsSuperAdmin
-
-	if err := h.roleSvc.RemoveRoleFromUser(ctx, req, isSuperAdmin); err != nil {
+	if err := h.roleSvc.RemoveRoleFromUser(ctx, req); err != nil {
 		return HandleError(c, err)
 	}
 
 	return HandleSuccess(c, map[string]string{"message": "Role removed from user successfully"})
 }
 
+// HandleBulkAssignRoles assigns a list of roles in one transaction and reports each item
+func (h *RoleHandler) HandleBulkAssignRoles(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.BulkAssignRolesReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.roleSvc.BulkAssignRoles(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+
+	return HandleSuccess(c, result)
+}
+
+// HandleBulkRemoveRoles removes a list of role assignments in one statement and reports each item
+func (h *RoleHandler) HandleBulkRemoveRoles(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.BulkRemoveRolesReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.roleSvc.BulkRemoveRoles(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+
+	return HandleSuccess(c, result)
+}
+
 // HandleGetUserPermissions retrieves all permissions assigned to a user
 func (h *RoleHandler) HandleGetUserPermissions(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -184,3 +217,19 @@ func (h *RoleHandler) HandleGetUserPermissions(c echo.Context) error {
 
 	return HandleSuccess(c, result)
 }
+
+// HandleGetUsersPermissions retrieves the permissions of several users, keyed by user ID
+func (h *RoleHandler) HandleGetUsersPermissions(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.GetUsersPermissionsReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.roleSvc.GetUsersPermissions(ctx, req.UserIDs)
+	if err != nil {
+		return HandleError(c, err)
+	}
+
+	return HandleSuccess(c, result)
+}
@@ -0,0 +1,129 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestRoleHandler_BulkAssignment(t *testing.T) {
+	h := apitest.New(t)
+	ctx := context.Background()
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	member := h.Token(jwt.Payload{UserID: "member"})
+	project, _ := h.Projects.Create(ctx, &model.Project{Code: "acme", Name: "Acme"})
+	alice, _ := h.Users.Create(ctx, &model.User{Username: "alice", Email: "alice@example.com"})
+	bob, _ := h.Users.Create(ctx, &model.User{Username: "bob", Email: "bob@example.com"})
+	editor, _ := h.Roles.Create(ctx, &model.Role{Code: "editor", Name: "Editor", ProjectID: &project.ID, Permissions: model.PermissionsToJSON([]string{"docs.edit"})})
+	system := constant.SystemProjectID
+	root, _ := h.Roles.Create(ctx, &model.Role{Code: "root", Name: "Root", ProjectID: &system})
+	manager, _ := h.Roles.Create(ctx, &model.Role{Code: "manager", Name: "Manager", ProjectID: &system, IsActive: true, Permissions: model.PermissionsToJSON([]string{"roles.assign", "roles.revoke"})})
+	h.UserRoles.Create(ctx, &model.UserRole{UserID: "member", RoleID: manager.ID, ProjectID: &system})
+	h.UserRoles.Create(ctx, &model.UserRole{UserID: bob.ID, RoleID: editor.ID, ProjectID: &project.ID})
+	for _, user := range []*model.User{alice, bob} {
+		h.ProjectMembers.Create(ctx, &model.ProjectMember{ProjectID: project.ID, UserID: user.ID, Status: constant.ProjectMemberActive})
+	}
+
+	// Warm alice's permission cache so the bulk assignment has to invalidate it.
+	h.Do(t, http.MethodGet, "/api/v1/roles/user/"+alice.ID+"/permissions", nil, admin)
+
+	assign := aggregate.BulkAssignRolesReq{Assignments: []aggregate.AssignRoleToUserReq{
+		{UserID: alice.ID, RoleID: editor.ID, ProjectID: &project.ID},
+		{UserID: bob.ID, RoleID: editor.ID, ProjectID: &project.ID},
+		{UserID: "ghost", RoleID: editor.ID, ProjectID: &project.ID},
+		{UserID: alice.ID, RoleID: root.ID},
+		{UserID: alice.ID, RoleID: editor.ID, ProjectID: &project.ID},
+	}}
+	stranger := h.Token(jwt.Payload{UserID: "stranger"})
+	if resp := h.Do(t, http.MethodPost, "/api/v1/roles/bulk-assign", assign, stranger); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("bulk assign without roles.assign: status %d, want 403", resp.StatusCode)
+	}
+	resp := h.Do(t, http.MethodPost, "/api/v1/roles/bulk-assign", assign, member)
+	var result aggregate.BulkRoleAssignmentResp
+	apitest.Decode(t, resp, &result)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("bulk assign: status %d", resp.StatusCode)
+	}
+	want := []string{constant.RoleAssignmentAssigned, constant.RoleAssignmentSkipped, constant.RoleAssignmentError, constant.RoleAssignmentError, constant.RoleAssignmentSkipped}
+	for i, status := range want {
+		if result.Results[i].Index != i || result.Results[i].Status != status {
+			t.Errorf("result %d = %+v, want %s", i, result.Results[i], status)
+		}
+	}
+	if result.Succeeded != 1 || result.Skipped != 2 || result.Failed != 2 || result.Results[0].Assignment == nil {
+		t.Errorf("bulk assign totals = %+v", result)
+	}
+
+	var permissions aggregate.UserPermissions
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/api/v1/roles/user/"+alice.ID+"/permissions", nil, admin), &permissions)
+	if !permissions[project.ID+"/docs.edit"] {
+		t.Errorf("alice's permissions after bulk assign = %v, want %s/docs.edit", permissions, project.ID)
+	}
+
+	remove := aggregate.BulkRemoveRolesReq{Assignments: []aggregate.RemoveRoleFromUserReq{
+		{UserID: alice.ID, RoleID: editor.ID, ProjectID: &project.ID},
+		{UserID: bob.ID, RoleID: editor.ID, ProjectID: &project.ID},
+		{UserID: bob.ID, RoleID: editor.ID},
+		{UserID: bob.ID, RoleID: "missing"},
+	}}
+	resp = h.Do(t, http.MethodPost, "/api/v1/roles/bulk-remove", remove, member)
+	result = aggregate.BulkRoleAssignmentResp{}
+	apitest.Decode(t, resp, &result)
+	want = []string{constant.RoleAssignmentRemoved, constant.RoleAssignmentRemoved, constant.RoleAssignmentSkipped, constant.RoleAssignmentError}
+	for i, status := range want {
+		if result.Results[i].Status != status {
+			t.Errorf("remove result %d = %+v, want %s", i, result.Results[i], status)
+		}
+	}
+	if result.Succeeded != 2 || h.UserRoles.Len() != 1 {
+		t.Errorf("bulk remove: %+v, %d assignments left", result, h.UserRoles.Len())
+	}
+}
+
+func TestRoleHandler_PermissionCacheInvalidation(t *testing.T) {
+	h := apitest.New(t)
+	ctx := context.Background()
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	user, _ := h.Users.Create(ctx, &model.User{Username: "hugo", Email: "hugo@example.com"})
+	h.Permissions.BulkCreate(ctx, []model.Permission{{Code: "users.view", Name: "User View"}, {Code: "users.update", Name: "User Update"}})
+	role, _ := h.Roles.Create(ctx, &model.Role{Code: "support", Name: "Support", Permissions: model.PermissionsToJSON([]string{"users.view"})})
+
+	permissions := func() aggregate.UserPermissions {
+		t.Helper()
+		var permissions aggregate.UserPermissions
+		apitest.Decode(t, h.Do(t, http.MethodGet, "/api/v1/roles/user/"+user.ID+"/permissions", nil, admin), &permissions)
+		return permissions
+	}
+
+	// Assignment changes are in force as soon as their request returns, with the old permissions cached; a
+	// role update reaches its holders in the background.
+	permissions()
+	if resp := h.Do(t, http.MethodPost, "/api/v1/roles/assign", aggregate.AssignRoleToUserReq{UserID: user.ID, RoleID: role.ID}, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("assign: status %d", resp.StatusCode)
+	}
+	if got := permissions(); !got["system/users.view"] {
+		t.Errorf("permissions after assign = %v, want system/users.view", got)
+	}
+
+	update := aggregate.UpdateRoleReq{Name: "Support", Permissions: []string{"users.update"}}
+	if resp := h.Do(t, http.MethodPut, "/api/v1/roles/"+role.ID, update, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("update role: status %d", resp.StatusCode)
+	}
+	if got := permissions(); got["system/users.view"] || !got["system/users.update"] {
+		t.Errorf("permissions after role update = %v, want only system/users.update", got)
+	}
+
+	remove := aggregate.RemoveRoleFromUserReq{UserID: user.ID, RoleID: role.ID}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/roles/remove", remove, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("remove: status %d", resp.StatusCode)
+	}
+	if got := permissions(); len(got) != 0 {
+		t.Errorf("permissions after removal = %v, want none", got)
+	}
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	echomw "github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// SAMLHandler handles SAML SSO for projects and the management of their IdP connections.
+type SAMLHandler struct {
+	samlSvc   service.ISAMLSvc
+	logger    logger.ILogger
+	verifyJWT echomw.VerifyJWTMiddleware
+	authorize echomw.AuthorizeMiddleware
+}
+
+// NewSAMLHandler creates a new SAML handler.
+func NewSAMLHandler(samlSvc service.ISAMLSvc, logger logger.ILogger, verifyJWT echomw.VerifyJWTMiddleware, authorize echomw.AuthorizeMiddleware) *SAMLHandler {
+	return &SAMLHandler{
+		samlSvc:   samlSvc,
+		logger:    logger,
+		verifyJWT: verifyJWT,
+		authorize: authorize,
+	}
+}
+
+// RegisterRoutes registers the public service provider routes on a group mounted at /saml.
+func (h *SAMLHandler) RegisterRoutes(g *echo.Group) {
+	g.GET("/:projectId/metadata", h.HandleMetadata)
+	g.GET("/:projectId/login", h.HandleLogin)
+	g.POST("/:projectId/acs", h.HandleACS)
+}
+
+// RegisterConnectionRoutes registers connection management on a group mounted at /projects/:id/saml.
+func (h *SAMLHandler) RegisterConnectionRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
+	g.GET("", h.HandleGetConnection)
+	g.PUT("", h.HandleUpsertConnection)
+	g.DELETE("", h.HandleDeleteConnection)
+}
+
+// HandleMetadata returns the project's SP metadata for registration at the IdP.
+func (h *SAMLHandler) HandleMetadata(c echo.Context) error {
+	ctx := c.Request().Context()
+	metadata, err := h.samlSvc.Metadata(ctx, c.Param("projectId"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.Blob(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// HandleLogin redirects the browser to the project's IdP.
+// Query: redirectUrl, where the browser lands with ?refreshState=... after the IdP responds; optionally
+// codeChallenge and codeChallengeMethod (S256) for PKCE.
+func (h *SAMLHandler) HandleLogin(c echo.Context) error {
+	ctx := c.Request().Context()
+	authURL, err := h.samlSvc.StartLogin(ctx, c.Param("projectId"), aggregate.StartSAMLLoginReq{
+		RedirectURL:         c.QueryParam("redirectUrl"),
+		CodeChallenge:       c.QueryParam("codeChallenge"),
+		CodeChallengeMethod: c.QueryParam("codeChallengeMethod"),
+	})
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// HandleACS receives the IdP's HTTP-POST response and redirects to the frontend with a refreshState.
+func (h *SAMLHandler) HandleACS(c echo.Context) error {
+	ctx := c.Request().Context()
+	redirectURL, err := h.samlSvc.ConsumeResponse(ctx, c.Param("projectId"), c.FormValue("SAMLResponse"), c.FormValue("RelayState"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.Redirect(http.StatusFound, redirectURL)
+}
+
+// HandleGetConnection returns the project's SAML connection.
+func (h *SAMLHandler) HandleGetConnection(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.samlSvc.GetConnection(ctx, c.Param("id"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleUpsertConnection creates or replaces the project's SAML connection.
+func (h *SAMLHandler) HandleUpsertConnection(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.UpsertSAMLConnectionReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.samlSvc.UpsertConnection(ctx, c.Param("id"), req)
+	if err != nil {
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to save SAML connection", "projectID", c.Param("id"), "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleDeleteConnection removes the project's SAML connection.
+func (h *SAMLHandler) HandleDeleteConnection(c echo.Context) error {
+	ctx := c.Request().Context()
+	if err := h.samlSvc.DeleteConnection(ctx, c.Param("id")); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
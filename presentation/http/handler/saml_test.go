@@ -0,0 +1,152 @@
+package handler_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/xml"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	gosaml "github.com/crewjam/saml"
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+// spMetadata serves SP metadata fetched from the server under test to the fake IdP.
+type spMetadata struct{ entity *gosaml.EntityDescriptor }
+
+func (p spMetadata) GetServiceProvider(*http.Request, string) (*gosaml.EntityDescriptor, error) {
+	return p.entity, nil
+}
+
+func newTestIdP(t *testing.T) *gosaml.IdentityProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate idp key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create idp certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	metadataURL, _ := url.Parse("https://idp.example.com/metadata")
+	ssoURL, _ := url.Parse("https://idp.example.com/sso")
+	return &gosaml.IdentityProvider{Key: key, Certificate: cert, MetadataURL: *metadataURL, SSOURL: *ssoURL}
+}
+
+func TestSAMLHandler_Login(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) { cfg.SAML.BaseURL = "https://auth.example.com/api/v1" }))
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	project := &model.Project{Code: "corp", Name: "Corp"}
+	project.SetRedirectURLs([]string{"https://app.example.com/cb"})
+	project, err := h.Projects.Create(context.Background(), project)
+	if err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	idp := newTestIdP(t)
+	idpMetadata, _ := xml.Marshal(idp.Metadata())
+
+	resp := h.Do(t, http.MethodPut, "/api/v1/projects/"+project.ID+"/saml", aggregate.UpsertSAMLConnectionReq{
+		IdPMetadataXML:  string(idpMetadata),
+		GroupsAttribute: "eduPersonAffiliation",
+		AllowedDomains:  []string{"corp.example"},
+	}, admin)
+	var conn aggregate.SAMLConnectionResp
+	apitest.Decode(t, resp, &conn)
+	if resp.StatusCode != http.StatusOK || conn.ACSURL != "https://auth.example.com/api/v1/saml/"+project.ID+"/acs" {
+		t.Fatalf("upsert status = %d, connection = %+v", resp.StatusCode, conn)
+	}
+
+	resp = h.Do(t, http.MethodGet, "/api/v1/saml/"+project.ID+"/metadata", nil, "")
+	var sp gosaml.EntityDescriptor
+	if err := xml.NewDecoder(resp.Body).Decode(&sp); err != nil || sp.EntityID != conn.SPEntityID {
+		t.Fatalf("metadata entityID = %q, err = %v; want %q", sp.EntityID, err, conn.SPEntityID)
+	}
+	idp.ServiceProviderProvider = spMetadata{entity: &sp}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp = h.Do(t, http.MethodGet, "/api/v1/saml/"+project.ID+"/login?redirectUrl="+url.QueryEscape("https://evil.example.net/cb"), nil, "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("login to unlisted redirect URL: status %d, want 400", resp.StatusCode)
+	}
+	// login runs SP-initiated SSO for nameID and returns the ACS form the IdP would post.
+	login := func(nameID string) url.Values {
+		t.Helper()
+		resp, err := client.Get(h.Server.URL + "/api/v1/saml/" + project.ID + "/login?redirectUrl=" + url.QueryEscape("https://app.example.com/cb"))
+		if err != nil || resp.StatusCode != http.StatusFound {
+			t.Fatalf("login: status = %v, err = %v", resp, err)
+		}
+		resp.Body.Close()
+		authnHTTP, _ := http.NewRequest(http.MethodGet, resp.Header.Get("Location"), nil)
+		authn, err := gosaml.NewIdpAuthnRequest(idp, authnHTTP)
+		if err != nil {
+			t.Fatalf("parse AuthnRequest: %v", err)
+		}
+		if err := authn.Validate(); err != nil {
+			t.Fatalf("validate AuthnRequest: %v", err)
+		}
+		session := &gosaml.Session{ID: "s1", NameID: nameID, Groups: []string{"eng"}}
+		if err := (gosaml.DefaultAssertionMaker{}).MakeAssertion(authn, session); err != nil {
+			t.Fatalf("make assertion: %v", err)
+		}
+		form, err := authn.PostBinding()
+		if err != nil {
+			t.Fatalf("post binding: %v", err)
+		}
+		return url.Values{"SAMLResponse": {form.SAMLResponse}, "RelayState": {form.RelayState}}
+	}
+	acs := func(form url.Values) *http.Response {
+		t.Helper()
+		resp, err := client.Post(h.Server.URL+"/api/v1/saml/"+project.ID+"/acs", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatalf("acs: %v", err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	form := login("ann@corp.example")
+	resp = acs(form)
+	location, _ := url.Parse(resp.Header.Get("Location"))
+	if resp.StatusCode != http.StatusFound || location == nil || location.Host != "app.example.com" {
+		t.Fatalf("acs status = %d, location = %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if resp := acs(form); resp.StatusCode == http.StatusFound {
+		t.Error("replayed SAML response was accepted")
+	}
+
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/session-from-state", aggregate.SessionFromStateReq{RefreshState: location.Query().Get("refreshState")}, "")
+	var tokens aggregate.TokenResp
+	apitest.Decode(t, resp, &tokens)
+	if resp.StatusCode != http.StatusOK || tokens.AccessToken == "" {
+		t.Fatalf("session-from-state status = %d, want tokens", resp.StatusCode)
+	}
+	if user, _ := h.Users.FindByEmail(context.Background(), "ann@corp.example"); user == nil {
+		t.Error("SAML login did not create the user")
+	} else if identities, _ := h.UserIdentities.FindByUserID(context.Background(), user.ID); len(identities) != 1 || identities[0].AuthType != constant.UserAuthTypeSAML {
+		t.Errorf("identities = %+v, want one SAML identity", identities)
+	}
+
+	// The IdP cannot sign in addresses outside the connection's domains.
+	if resp := acs(login("mallory@other.example")); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("foreign domain status = %d, want 403", resp.StatusCode)
+	}
+}
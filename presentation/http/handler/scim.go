@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/scim"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type SCIMHandler struct {
+	scimSvc  service.ISCIMSvc
+	logger   logger.ILogger
+	scimAuth middleware.SCIMAuthMiddleware
+}
+
+func NewSCIMHandler(
+	scimSvc service.ISCIMSvc,
+	logger logger.ILogger,
+	scimAuth middleware.SCIMAuthMiddleware,
+) *SCIMHandler {
+	return &SCIMHandler{
+		scimSvc:  scimSvc,
+		logger:   logger,
+		scimAuth: scimAuth,
+	}
+}
+
+// RegisterRoutes registers the SCIM 2.0 service provider on a group mounted at /scim/v2.
+// Requests and responses use the SCIM format, not the API's {"code", "message", "data"} envelope.
+func (h *SCIMHandler) RegisterRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.scimAuth))
+	g.GET("/ServiceProviderConfig", h.HandleServiceProviderConfig)
+
+	g.GET("/Users", h.HandleListUsers)
+	g.POST("/Users", h.HandleCreateUser)
+	g.GET("/Users/:id", h.HandleGetUser)
+	g.PUT("/Users/:id", h.HandleReplaceUser)
+	g.PATCH("/Users/:id", h.HandlePatchUser)
+	g.DELETE("/Users/:id", h.HandleDeleteUser)
+
+	g.GET("/Groups", h.HandleListGroups)
+	g.POST("/Groups", h.HandleCreateGroup)
+	g.GET("/Groups/:id", h.HandleGetGroup)
+	g.PUT("/Groups/:id", h.HandleReplaceGroup)
+	g.PATCH("/Groups/:id", h.HandlePatchGroup)
+	g.DELETE("/Groups/:id", h.HandleDeleteGroup)
+}
+
+// HandleServiceProviderConfig advertises the supported SCIM features.
+func (h *SCIMHandler) HandleServiceProviderConfig(c echo.Context) error {
+	return scimJSON(c, http.StatusOK, aggregate.SCIMServiceProviderConfig{
+		Schemas:        []string{scim.SchemaServiceProviderConfig},
+		Patch:          aggregate.SCIMSupported{Supported: true},
+		Filter:         aggregate.SCIMFilterSupport{Supported: true, MaxResults: 100},
+		ChangePassword: aggregate.SCIMSupported{Supported: false},
+		AuthenticationSchemes: []aggregate.SCIMAuthScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "API key",
+			Description: "A project API key with the scim.provision scope, sent as a Bearer token",
+		}},
+	})
+}
+
+func (h *SCIMHandler) HandleListUsers(c echo.Context) error {
+	var req aggregate.SCIMListReq
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+		return h.error(c, scim.BadRequest(scim.ErrTypeInvalidValue, "invalid query parameters"))
+	}
+	result, err := h.scimSvc.ListUsers(c.Request().Context(), scimProjectID(c), req)
+	if err != nil {
+		return h.error(c, err)
+	}
+	return scimJSON(c, http.StatusOK, result)
+}
+
+func (h *SCIMHandler) HandleGetUser(c echo.Context) error {
+	result, err := h.scimSvc.GetUser(c.Request().Context(), scimProjectID(c), c.Param("id"))
+	if err != nil {
+		return h.error(c, err)
+	}
+	return scimJSON(c, http.StatusOK, result)
+}
+
+func (h *SCIMHandler) HandleCreateUser(c echo.Context) error {
+	req, err := bindSCIM[aggregate.SCIMUser](c)
+	if err != nil {
+		return h.error(c, err)
+	}
+	result, err := h.scimSvc.CreateUser(c.Request().Context(), scimProjectID(c), req)
+	if err != nil {
+		return h.error(c, err)
+	}
+	return scimJSON(c, http.StatusCreated, result)
+}
+
+func (h *SCIMHandler) HandleReplaceUser(c echo.Context) error {
+	req, err := bindSCIM[aggregate.SCIMUser](c)
+	if err != nil {
+		return h.error(c, err)
+	}
+	result, err := h.scimSvc.ReplaceUser(c.Request().Context(), scimProjectID(c), c.Param("id"), req)
+	if err != nil {
+		return h.error(c, err)
+	}
+	return scimJSON(c, http.StatusOK, result)
+}
+
+func (h *SCIMHandler) HandlePatchUser(c echo.Context) error {
+	req, err := bindSCIM[aggregate.SCIMPatchReq](c)
+	if err != nil {
+		return h.error(c, err)
+	}
+	result, err := h.scimSvc.PatchUser(c.Request().Context(), scimProjectID(c), c.Param("id"), req)
+	if err != nil {
+		return h.error(c, err)
+	}
+	return scimJSON(c, http.StatusOK, result)
+}
+
+func (h *SCIMHandler) HandleDeleteUser(c echo.Context) error {
+	if err := h.scimSvc.DeleteUser(c.Request().Context(), scimProjectID(c), c.Param("id")); err != nil {
+		return h.error(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *SCIMHandler) HandleListGroups(c echo.Context) error {
+	var req aggregate.SCIMListReq
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+		return h.error(c, scim.BadRequest(scim.ErrTypeInvalidValue, "invalid query parameters"))
+	}
+	result, err := h.scimSvc.ListGroups(c.Request().Context(), scimProjectID(c), req)
+	if err != nil {
+		return h.error(c, err)
+	}
+	return scimJSON(c, http.StatusOK, result)
+}
+
+func (h *SCIMHandler) HandleGetGroup(c echo.Context) error {
+	result, err := h.scimSvc.GetGroup(c.Request().Context(), scimProjectID(c), c.Param("id"))
+	if err != nil {
+		return h.error(c, err)
+	}
+	return scimJSON(c, http.StatusOK, result)
+}
+
+func (h *SCIMHandler) HandleCreateGroup(c echo.Context) error {
+	req, err := bindSCIM[aggregate.SCIMGroup](c)
+	if err != nil {
+		return h.error(c, err)
+	}
+	result, err := h.scimSvc.CreateGroup(c.Request().Context(), scimProjectID(c), req)
+	if err != nil {
+		return h.error(c, err)
+	}
+	return scimJSON(c, http.StatusCreated, result)
+}
+
+func (h *SCIMHandler) HandleReplaceGroup(c echo.Context) error {
+	req, err := bindSCIM[aggregate.SCIMGroup](c)
+	if err != nil {
+		return h.error(c, err)
+	}
+	result, err := h.scimSvc.ReplaceGroup(c.Request().Context(), scimProjectID(c), c.Param("id"), req)
+	if err != nil {
+		return h.error(c, err)
+	}
+	return scimJSON(c, http.StatusOK, result)
+}
+
+func (h *SCIMHandler) HandlePatchGroup(c echo.Context) error {
+	req, err := bindSCIM[aggregate.SCIMPatchReq](c)
+	if err != nil {
+		return h.error(c, err)
+	}
+	result, err := h.scimSvc.PatchGroup(c.Request().Context(), scimProjectID(c), c.Param("id"), req)
+	if err != nil {
+		return h.error(c, err)
+	}
+	return scimJSON(c, http.StatusOK, result)
+}
+
+func (h *SCIMHandler) HandleDeleteGroup(c echo.Context) error {
+	if err := h.scimSvc.DeleteGroup(c.Request().Context(), scimProjectID(c), c.Param("id")); err != nil {
+		return h.error(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// error writes err as a SCIM error response. Failures that are not *scim.Error keep their status when
+// it is a 4xx and are otherwise logged and reported as 500.
+func (h *SCIMHandler) error(c echo.Context, err error) error {
+	var scimErr *scim.Error
+	if errors.As(err, &scimErr) {
+		return scimJSON(c, scimErr.Status, scimErr)
+	}
+	var appErr *errorx.AppError
+	if errors.As(err, &appErr) && appErr.Code < 500 {
+		return scimJSON(c, int(appErr.Code), scim.NewError(int(appErr.Code), "", appErr.Message))
+	}
+	logger.WithContext(c.Request().Context(), h.logger).Error("SCIM request failed", "path", c.Path(), "error", err)
+	return scimJSON(c, http.StatusInternalServerError, scim.NewError(http.StatusInternalServerError, "", "internal server error"))
+}
+
+// bindSCIM decodes a SCIM JSON body, which Echo's binder rejects for the application/scim+json media type,
+// and validates it.
+func bindSCIM[T any](c echo.Context) (T, error) {
+	var req T
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return req, scim.BadRequest(scim.ErrTypeInvalidSyntax, "malformed JSON body")
+	}
+	if err := c.Validate(&req); err != nil {
+		return req, scim.BadRequest(scim.ErrTypeInvalidValue, err.Error())
+	}
+	return req, nil
+}
+
+func scimJSON(c echo.Context, status int, body any) error {
+	c.Response().Header().Set(echo.HeaderContentType, scim.ContentType)
+	return c.JSON(status, body)
+}
+
+// scimProjectID is the project of the API key that authenticated the request.
+func scimProjectID(c echo.Context) string {
+	return middleware.GetJWTPayload(c.Request().Context()).ProjectID
+}
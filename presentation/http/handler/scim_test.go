@@ -0,0 +1,107 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestSCIMHandler_Provisioning(t *testing.T) {
+	h := apitest.New(t)
+	project, err := h.Projects.Create(context.Background(), &model.Project{Code: "acme", Name: "Acme"})
+	if err != nil {
+		t.Fatalf("seed project: %v", err)
+	}
+	if _, err := h.Permissions.Create(context.Background(), &model.Permission{Code: "scim.provision", Name: "SCIM Provision"}); err != nil {
+		t.Fatalf("seed permission: %v", err)
+	}
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	newKey := func(scopes ...string) string {
+		t.Helper()
+		resp := h.Do(t, http.MethodPost, "/api/v1/projects/"+project.ID+"/api-keys", aggregate.CreateAPIKeyReq{Name: "okta", Scopes: scopes}, admin)
+		var created aggregate.CreateAPIKeyResp
+		apitest.Decode(t, resp, &created)
+		return created.Key
+	}
+	key := newKey("scim.provision")
+	scimDo := func(method, path string, body any, out any) int {
+		t.Helper()
+		resp := h.Do(t, method, "/scim/v2"+path, body, key)
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatalf("%s %s: decode: %v", method, path, err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	if resp := h.Do(t, http.MethodGet, "/scim/v2/Users", nil, ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("no key status = %d, want 401", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodGet, "/scim/v2/Users", nil, newKey()); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("key without scim.provision status = %d, want 403", resp.StatusCode)
+	}
+
+	newUser := aggregate.SCIMUser{UserName: "ada@example.com", ExternalID: "00u1", Emails: []aggregate.SCIMEmail{{Value: "ada@example.com", Primary: true}}}
+	resp := h.Do(t, http.MethodPost, "/scim/v2/Users", newUser, key)
+	var user aggregate.SCIMUser
+	_ = json.NewDecoder(resp.Body).Decode(&user)
+	if resp.StatusCode != http.StatusCreated || user.ID == "" || user.Active == nil || !*user.Active {
+		t.Fatalf("create user status = %d, user = %+v", resp.StatusCode, user)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/scim+json") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var scimErr map[string]any
+	if status := scimDo(http.MethodPost, "/Users", newUser, &scimErr); status != http.StatusConflict || scimErr["scimType"] != "uniqueness" {
+		t.Errorf("duplicate user status = %d, body = %v", status, scimErr)
+	}
+
+	var list aggregate.SCIMListResp[aggregate.SCIMUser]
+	scimDo(http.MethodGet, "/Users?filter="+url.QueryEscape(`userName eq "ada@example.com"`), nil, &list)
+	if list.TotalResults != 1 || len(list.Resources) != 1 || list.Resources[0].ExternalID != "00u1" {
+		t.Errorf("filtered users = %+v", list)
+	}
+
+	var group aggregate.SCIMGroup
+	status := scimDo(http.MethodPost, "/Groups", aggregate.SCIMGroup{DisplayName: "Engineering", Members: []aggregate.SCIMGroupMember{{Value: user.ID}}}, &group)
+	if status != http.StatusCreated || len(group.Members) != 1 {
+		t.Fatalf("create group status = %d, group = %+v", status, group)
+	}
+	if assigned := h.UserRoles.First(func(m *model.UserRole) bool { return m.UserID == user.ID && m.RoleID == group.ID }); assigned == nil || *assigned.ProjectID != project.ID {
+		t.Errorf("role assignment = %+v, want one in the project", assigned)
+	}
+	removeMember := aggregate.SCIMPatchReq{Operations: []aggregate.SCIMPatchOp{{Op: "remove", Path: `members[value eq "` + user.ID + `"]`}}}
+	var patched aggregate.SCIMGroup
+	if status := scimDo(http.MethodPatch, "/Groups/"+group.ID, removeMember, &patched); status != http.StatusOK || len(patched.Members) != 0 {
+		t.Errorf("remove member status = %d, group = %+v", status, patched)
+	}
+
+	session, _ := h.Sessions.Create(context.Background(), &model.Session{UserID: user.ID, IsActive: true, ExpiresAt: time.Now().Add(time.Hour)})
+	deactivate := aggregate.SCIMPatchReq{Operations: []aggregate.SCIMPatchOp{{Op: "Replace", Path: "active", Value: json.RawMessage(`"False"`)}}}
+	if status := scimDo(http.MethodPatch, "/Users/"+user.ID, deactivate, &user); status != http.StatusOK || *user.Active {
+		t.Errorf("deactivate status = %d, user = %+v", status, user)
+	}
+	if got := h.Sessions.FindOneById(context.Background(), session.ID); got.IsActive {
+		t.Error("session still active after the user was deactivated")
+	}
+
+	if status := scimDo(http.MethodDelete, "/Users/"+user.ID, nil, nil); status != http.StatusNoContent {
+		t.Errorf("delete user status = %d", status)
+	}
+	if h.Users.FindOneById(context.Background(), user.ID) != nil {
+		t.Error("user not deleted")
+	}
+	if status := scimDo(http.MethodGet, "/Users/"+user.ID, nil, nil); status != http.StatusNotFound {
+		t.Errorf("deleted user status = %d, want 404", status)
+	}
+}
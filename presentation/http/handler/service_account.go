@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/oidc"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type ServiceAccountHandler struct {
+	serviceAccountSvc service.IServiceAccountSvc
+	logger            logger.ILogger
+	verifyJWT         middleware.VerifyJWTMiddleware
+	authorize         middleware.AuthorizeMiddleware
+}
+
+func NewServiceAccountHandler(
+	serviceAccountSvc service.IServiceAccountSvc,
+	logger logger.ILogger,
+	verifyJWT middleware.VerifyJWTMiddleware,
+	authorize middleware.AuthorizeMiddleware,
+) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		serviceAccountSvc: serviceAccountSvc,
+		logger:            logger,
+		verifyJWT:         verifyJWT,
+		authorize:         authorize,
+	}
+}
+
+// RegisterTokenRoutes registers the public client_credentials token endpoint on the /auth group.
+// Call it before AuthHandler.RegisterRoutes, which adds JWT verification to the rest of that group.
+func (h *ServiceAccountHandler) RegisterTokenRoutes(g *echo.Group) {
+	g.POST("/token", h.HandleToken)
+}
+
+// RegisterRoutes registers service account management on a group mounted at /projects/:id/service-accounts.
+func (h *ServiceAccountHandler) RegisterRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
+	g.GET("", h.HandleListAccounts)
+	g.POST("", h.HandleCreateAccount)
+	g.DELETE("/:accountId", h.HandleDeleteAccount)
+}
+
+// HandleToken implements the client_credentials grant. Clients authenticate with HTTP Basic
+// (client_secret_basic) or client_id/client_secret form fields. Errors use the OAuth 2.0 format.
+func (h *ServiceAccountHandler) HandleToken(c echo.Context) error {
+	ctx := c.Request().Context()
+	var req aggregate.ClientCredentialsTokenReq
+	if err := c.Bind(&req); err != nil {
+		return oauthTokenError(c, h.logger, oidc.NewError(oidc.ErrCodeInvalidRequest, "malformed token request"))
+	}
+	if id, secret, ok := basicClientAuth(c); ok {
+		req.ClientID, req.ClientSecret = id, secret
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	result, err := h.serviceAccountSvc.IssueToken(ctx, req)
+	if err != nil {
+		return oauthTokenError(c, h.logger, err)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// HandleListAccounts lists the project's service accounts; secrets are never returned.
+func (h *ServiceAccountHandler) HandleListAccounts(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.serviceAccountSvc.List(ctx, c.Param("id"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleCreateAccount creates a service account. The client secret is only in this response.
+func (h *ServiceAccountHandler) HandleCreateAccount(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.CreateServiceAccountReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	result, err := h.serviceAccountSvc.Create(ctx, c.Param("id"), req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleDeleteAccount deletes a service account; tokens it already holds expire on their own.
+func (h *ServiceAccountHandler) HandleDeleteAccount(c echo.Context) error {
+	ctx := c.Request().Context()
+	if err := h.serviceAccountSvc.Delete(ctx, c.Param("id"), c.Param("accountId")); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
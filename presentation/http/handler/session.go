@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type SessionHandler struct {
+	sessionSvc service.ISessionSvc
+	logger     logger.ILogger
+	verifyJWT  middleware.VerifyJWTMiddleware
+	authorize  middleware.AuthorizeMiddleware
+}
+
+func NewSessionHandler(
+	sessionSvc service.ISessionSvc,
+	logger logger.ILogger,
+	verifyJWT middleware.VerifyJWTMiddleware,
+	authorize middleware.AuthorizeMiddleware,
+) *SessionHandler {
+	return &SessionHandler{
+		sessionSvc: sessionSvc,
+		logger:     logger,
+		verifyJWT:  verifyJWT,
+		authorize:  authorize,
+	}
+}
+
+// RegisterRoutes registers the session search and stats on a group mounted at /sessions.
+func (h *SessionHandler) RegisterRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
+	g.GET("", h.HandleSearchSessions)
+	g.GET("/stats", h.HandleGetSessionStats)
+}
+
+// HandleSearchSessions lists the sessions of all users, filtered by user, IP, issue time and activity.
+// Query: userId, ip, from, to (RFC 3339), active, and paging like HandleListUsers.
+func (h *SessionHandler) HandleSearchSessions(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.SearchSessionsReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	result, err := h.sessionSvc.Search(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleGetSessionStats returns daily active users, logins by method and denied logins.
+// Query: from, to (RFC 3339), defaulting to the last 30 days.
+func (h *SessionHandler) HandleGetSessionStats(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.SessionStatsReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	result, err := h.sessionSvc.GetStats(ctx, req)
+	if err != nil {
+		logger.WithContext(ctx, h.logger).Error("Failed to compute session stats", "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
@@ -0,0 +1,96 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestSessionHandler_SearchAndStats(t *testing.T) {
+	h := apitest.New(t)
+	admin := h.Token(jwt.Payload{UserID: "admin", Email: "admin@example.com", IsSuperAdmin: true})
+	var alice, bob aggregate.TokenResp
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "alice@example.com", Password: "password123"}, ""), &alice)
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "bob@example.com", Password: "password123"}, ""), &bob)
+	var login aggregate.LoginResp
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/login", aggregate.LoginReq{AuthType: constant.UserAuthTypeEmail, Email: "alice@example.com", Password: "password123"}, ""), &login)
+	var refreshed aggregate.TokenResp
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/refresh-token", aggregate.RefreshTokenReq{RefreshToken: login.RefreshToken}, ""), &refreshed)
+	if refreshed.SessionID == "" {
+		t.Fatalf("refresh = %+v", refreshed)
+	}
+	if _, err := h.AccessDenials.Create(context.Background(), &model.AccessDenial{ProjectID: "p1", UserID: alice.UserID, Stage: constant.AccessStageLogin, Reason: "ip_not_allowed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := h.Do(t, http.MethodGet, "/api/v1/sessions", nil, alice.AccessToken); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("session search by a user status = %d, want 403", resp.StatusCode)
+	}
+	search := func(query string) aggregate.PaginationResp[aggregate.SessionDetailResp] {
+		t.Helper()
+		var page aggregate.PaginationResp[aggregate.SessionDetailResp]
+		resp := h.Do(t, http.MethodGet, "/api/v1/sessions?"+query, nil, admin)
+		if body := apitest.Decode(t, resp, &page); resp.StatusCode != http.StatusOK {
+			t.Fatalf("search %q status = %d, body = %+v", query, resp.StatusCode, body)
+		}
+		return page
+	}
+
+	// Only logins record a method: the refreshed session has none.
+	page := search("userId=" + alice.UserID)
+	methods := map[string]string{}
+	for _, s := range page.Items {
+		methods[s.ID] = s.LoginMethod
+		if s.UserID != alice.UserID || s.IP != "127.0.0.1" {
+			t.Errorf("alice's session = %+v", s)
+		}
+	}
+	if page.Total != 3 || methods[login.SessionID] != "EMAIL" || methods[alice.SessionID] != "EMAIL" || methods[refreshed.SessionID] != "" {
+		t.Errorf("alice's sessions = %+v", page.Items)
+	}
+	if page := search("ip=10.0.0.1"); page.Total != 0 {
+		t.Errorf("sessions from another IP = %+v", page.Items)
+	}
+	if page := search("ip=127.0.0.1&from=" + url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))); page.Total != 0 {
+		t.Errorf("sessions issued in the future = %+v", page.Items)
+	}
+	if resp := h.Do(t, http.MethodDelete, "/api/v1/users/"+bob.UserID+"/sessions", nil, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("end bob's sessions status = %d", resp.StatusCode)
+	}
+	if page := search("userId=" + bob.UserID + "&active=false"); page.Total != 1 || page.Items[0].ID != bob.SessionID || page.Items[0].IsActive {
+		t.Errorf("bob's ended sessions = %+v", page.Items)
+	}
+	if page := search("userId=" + bob.UserID + "&active=true"); page.Total != 0 {
+		t.Errorf("bob's active sessions = %+v", page.Items)
+	}
+	now := time.Now()
+	bad := "/api/v1/sessions?from=" + url.QueryEscape(now.Format(time.RFC3339)) + "&to=" + url.QueryEscape(now.Add(-time.Hour).Format(time.RFC3339))
+	if resp := h.Do(t, http.MethodGet, bad, nil, admin); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("search with from after to status = %d, want 400", resp.StatusCode)
+	}
+
+	var stats aggregate.SessionStatsResp
+	resp := h.Do(t, http.MethodGet, "/api/v1/sessions/stats", nil, admin)
+	if body := apitest.Decode(t, resp, &stats); resp.StatusCode != http.StatusOK {
+		t.Fatalf("stats status = %d, body = %+v", resp.StatusCode, body)
+	}
+	days := stats.DailyActiveUsers
+	if len(days) < 30 || days[len(days)-1].Date != now.UTC().Format(time.DateOnly) || days[len(days)-1].Count != 2 || days[0].Count != 0 {
+		t.Errorf("daily active users = %+v", days)
+	}
+	if stats.LoginsByMethod["EMAIL"] != 3 || len(stats.LoginsByMethod) != 1 || stats.DeniedLogins != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+	tooLong := "/api/v1/sessions/stats?from=" + url.QueryEscape(now.AddDate(-2, 0, 0).Format(time.RFC3339))
+	if resp := h.Do(t, http.MethodGet, tooLong, nil, admin); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("stats over two years status = %d, want 400", resp.StatusCode)
+	}
+}
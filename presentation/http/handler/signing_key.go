@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	echomw "github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type SigningKeyHandler struct {
+	signingKeySvc service.ISigningKeySvc
+	logger        logger.ILogger
+	verifyJWT     echomw.VerifyJWTMiddleware
+	authorize     echomw.AuthorizeMiddleware
+}
+
+func NewSigningKeyHandler(
+	signingKeySvc service.ISigningKeySvc,
+	logger logger.ILogger,
+	verifyJWT echomw.VerifyJWTMiddleware,
+	authorize echomw.AuthorizeMiddleware,
+) *SigningKeyHandler {
+	return &SigningKeyHandler{
+		signingKeySvc: signingKeySvc,
+		logger:        logger,
+		verifyJWT:     verifyJWT,
+		authorize:     authorize,
+	}
+}
+
+// RegisterWellKnownRoutes registers the public JWKS document on a group mounted at /.well-known.
+func (h *SigningKeyHandler) RegisterWellKnownRoutes(g *echo.Group) {
+	g.GET("/jwks.json", h.HandleJWKS)
+}
+
+// RegisterRoutes registers key management on a group mounted at /signing-keys.
+func (h *SigningKeyHandler) RegisterRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
+	g.GET("", h.HandleListKeys)
+	g.POST("/rotate", h.HandleRotateKey)
+	g.DELETE("/:kid", h.HandleRetireKey)
+}
+
+// HandleJWKS returns the public keys tokens may be signed with, as a bare JWK Set.
+func (h *SigningKeyHandler) HandleJWKS(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(http.StatusOK, h.signingKeySvc.JWKS())
+}
+
+// HandleListKeys lists every accepted key and which one signs new tokens.
+func (h *SigningKeyHandler) HandleListKeys(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.signingKeySvc.List(ctx)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleRotateKey creates a new signing key; it is published at once and signs tokens after the activation delay.
+func (h *SigningKeyHandler) HandleRotateKey(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.signingKeySvc.Rotate(ctx)
+	if err != nil {
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to rotate signing key", "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleRetireKey stops accepting tokens signed by a managed key.
+func (h *SigningKeyHandler) HandleRetireKey(c echo.Context) error {
+	ctx := c.Request().Context()
+	kid := c.Param("kid")
+	if err := h.signingKeySvc.Retire(ctx, kid); err != nil {
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to retire signing key", "kid", kid, "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
@@ -0,0 +1,108 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestSigningKeyHandler_Rotation(t *testing.T) {
+	h := apitest.New(t)
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	configKID := h.Keys.SigningKey().ID
+
+	jwks := func() jwt.JWKS {
+		t.Helper()
+		resp := h.Do(t, http.MethodGet, "/.well-known/jwks.json", nil, "")
+		var out jwt.JWKS
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("jwks status = %d, err = %v", resp.StatusCode, err)
+		}
+		return out
+	}
+	if keys := jwks().Keys; len(keys) != 1 || keys[0].Kid != configKID || keys[0].Kty != "RSA" {
+		t.Fatalf("initial jwks = %+v", keys)
+	}
+
+	resp := h.Do(t, http.MethodPost, "/api/v1/signing-keys/rotate", nil, h.Token(jwt.Payload{UserID: "u1"}))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("rotate as non-admin status = %d, want 403", resp.StatusCode)
+	}
+
+	var first, second aggregate.SigningKeyResp
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/signing-keys/rotate", nil, admin), &first)
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/signing-keys/rotate", nil, admin), &second)
+	// New keys are published at once but keep signing with the config key until they activate.
+	if keys := jwks().Keys; len(keys) != 3 || h.Keys.SigningKey().ID != configKID {
+		t.Fatalf("jwks after rotate = %d keys, signing %s", len(keys), h.Keys.SigningKey().ID)
+	}
+	if stored := h.SigningKeys.First(func(m *model.SigningKey) bool { return m.KID == first.KID }); stored == nil || strings.Contains(stored.PrivateKey, "PRIVATE KEY") {
+		t.Fatalf("stored key = %+v, want a sealed private key", stored)
+	}
+
+	// Once the first key activates it signs; retiring the second reloads the set.
+	stored := h.SigningKeys.First(func(m *model.SigningKey) bool { return m.KID == first.KID })
+	if err := h.SigningKeys.Update(context.Background(), stored.ID, model.SigningKey{ActivatesAt: time.Now().Add(-time.Minute)}, "activates_at"); err != nil {
+		t.Fatalf("activate key: %v", err)
+	}
+	resp = h.Do(t, http.MethodDelete, "/api/v1/signing-keys/"+second.KID, nil, admin)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || h.Keys.SigningKey().ID != first.KID {
+		t.Fatalf("retire status = %d, signing = %s, want %s", resp.StatusCode, h.Keys.SigningKey().ID, first.KID)
+	}
+
+	var list []aggregate.SigningKeyResp
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/api/v1/signing-keys", nil, admin), &list)
+	if len(list) != 2 || list[0].KID != first.KID || !list[0].Signing || list[0].Source != "managed" || list[1].Source != "config" {
+		t.Errorf("list = %+v, want the managed key signing and the config key still accepted", list)
+	}
+
+	resp = h.Do(t, http.MethodDelete, "/api/v1/signing-keys/"+configKID, nil, admin)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("retire config key status = %d, want 400", resp.StatusCode)
+	}
+	resp = h.Do(t, http.MethodDelete, "/api/v1/signing-keys/unknown", nil, admin)
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Error("retiring an unknown key succeeded")
+	}
+}
+
+func TestSigningKeyHandler_Broadcast(t *testing.T) {
+	h := apitest.New(t)
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	var announced []string
+	h.Cache.OnBroadcast(constant.CacheChannelSigningKeys, func(kid string) { announced = append(announced, kid) })
+
+	var key aggregate.SigningKeyResp
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/signing-keys/rotate", nil, admin), &key)
+	if len(announced) != 1 || announced[0] != key.KID {
+		t.Fatalf("announced = %v, want the rotated key", announced)
+	}
+	if len(h.Keys.JWKS().Keys) != 2 {
+		t.Fatalf("jwks after rotate = %+v", h.Keys.JWKS().Keys)
+	}
+
+	// Another replica retires the key and announces it: this one stops accepting it without waiting for
+	// the reload interval.
+	stored := h.SigningKeys.First(func(m *model.SigningKey) bool { return m.KID == key.KID })
+	now := time.Now()
+	h.SigningKeys.Update(context.Background(), stored.ID, model.SigningKey{RetiredAt: &now}, "retired_at")
+	if err := h.Cache.Broadcast(constant.CacheChannelSigningKeys, key.KID); err != nil {
+		t.Fatal(err)
+	}
+	if keys := h.Keys.JWKS().Keys; len(keys) != 1 || keys[0].Kid == key.KID {
+		t.Errorf("jwks after another replica retired the key = %+v", keys)
+	}
+}
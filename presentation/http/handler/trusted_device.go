@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type TrustedDeviceHandler struct {
+	trustedDeviceSvc service.ITrustedDeviceSvc
+	logger           logger.ILogger
+	verifyJWT        middleware.VerifyJWTMiddleware
+}
+
+func NewTrustedDeviceHandler(
+	trustedDeviceSvc service.ITrustedDeviceSvc,
+	logger logger.ILogger,
+	verifyJWT middleware.VerifyJWTMiddleware,
+) *TrustedDeviceHandler {
+	return &TrustedDeviceHandler{
+		trustedDeviceSvc: trustedDeviceSvc,
+		logger:           logger,
+		verifyJWT:        verifyJWT,
+	}
+}
+
+func (h *TrustedDeviceHandler) RegisterRoutes(g *echo.Group) {
+	// Callers only ever see and revoke their own devices
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+
+	g.GET("", h.HandleListDevices)
+	g.DELETE("", h.HandleRevokeAllDevices)
+	g.DELETE("/:id", h.HandleRevokeDevice)
+}
+
+// HandleListDevices lists the caller's unexpired trusted devices.
+func (h *TrustedDeviceHandler) HandleListDevices(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.trustedDeviceSvc.ListDevices(ctx)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleRevokeDevice stops one device from skipping MFA.
+func (h *TrustedDeviceHandler) HandleRevokeDevice(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+	if id == "" {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, nil))
+	}
+	if err := h.trustedDeviceSvc.RevokeDevice(ctx, id); err != nil {
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to revoke trusted device", "id", id, "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
+
+// HandleRevokeAllDevices forgets every trusted device, e.g. after a lost laptop.
+func (h *TrustedDeviceHandler) HandleRevokeAllDevices(c echo.Context) error {
+	ctx := c.Request().Context()
+	if err := h.trustedDeviceSvc.RevokeAllDevices(ctx); err != nil {
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to revoke trusted devices", "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
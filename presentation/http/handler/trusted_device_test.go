@@ -0,0 +1,41 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestTrustedDeviceHandler(t *testing.T) {
+	h := apitest.New(t)
+	ctx := context.Background()
+	mine, _ := h.TrustedDevices.Create(ctx, &model.TrustedDevice{UserID: "u1", TokenHash: "a", ExpiresAt: time.Now().Add(time.Hour)})
+	h.TrustedDevices.Create(ctx, &model.TrustedDevice{UserID: "u1", TokenHash: "b", ExpiresAt: time.Now().Add(-time.Hour)})
+	theirs, _ := h.TrustedDevices.Create(ctx, &model.TrustedDevice{UserID: "u2", TokenHash: "c", ExpiresAt: time.Now().Add(time.Hour)})
+	token := h.Token(jwt.Payload{UserID: "u1"})
+
+	resp := h.Do(t, http.MethodGet, "/api/v1/trusted-devices", nil, token)
+	var devices []aggregate.TrustedDeviceResp
+	apitest.Decode(t, resp, &devices)
+	if len(devices) != 1 || devices[0].ID != mine.ID {
+		t.Fatalf("devices = %+v, want only the unexpired device of u1", devices)
+	}
+
+	resp = h.Do(t, http.MethodDelete, "/api/v1/trusted-devices/"+theirs.ID, nil, token)
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK || h.TrustedDevices.FindOneById(ctx, theirs.ID) == nil {
+		t.Errorf("revoking another user's device status = %d, want failure", resp.StatusCode)
+	}
+
+	resp = h.Do(t, http.MethodDelete, "/api/v1/trusted-devices", nil, token)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || h.TrustedDevices.Len() != 1 {
+		t.Errorf("revoke all status = %d, devices left = %d; want 200 and only u2's", resp.StatusCode, h.TrustedDevices.Len())
+	}
+}
@@ -1,11 +1,17 @@
 package handler
 
 import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/hiamthach108/dreon-auth/internal/aggregate"
 	"github.com/hiamthach108/dreon-auth/internal/errorx"
 	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
 	"github.com/hiamthach108/dreon-auth/pkg/logger"
 	echomw "github.com/hiamthach108/dreon-auth/presentation/http/middleware"
 	"github.com/labstack/echo/v4"
@@ -13,46 +19,79 @@ import (
 
 // UserHandler handles HTTP requests for user CRUD.
 type UserHandler struct {
-	userSvc   service.IUserSvc
-	logger    logger.ILogger
-	verifyJWT echomw.VerifyJWTMiddleware
+	userSvc     service.IUserSvc
+	transferSvc service.IUserTransferSvc
+	privacySvc  service.IPrivacySvc
+	authSvc     service.IAuthSvc
+	logger      logger.ILogger
+	verifyJWT   echomw.VerifyJWTMiddleware
+	authorize   echomw.AuthorizeMiddleware
 }
 
-// NewUserHandler creates a new user handler. verifyJWT is injected by fx for protected routes.
-func NewUserHandler(userSvc service.IUserSvc, logger logger.ILogger, verifyJWT echomw.VerifyJWTMiddleware) *UserHandler {
+// NewUserHandler creates a new user handler. verifyJWT and authorize are injected by fx for protected routes.
+func NewUserHandler(
+	userSvc service.IUserSvc,
+	transferSvc service.IUserTransferSvc,
+	privacySvc service.IPrivacySvc,
+	authSvc service.IAuthSvc,
+	logger logger.ILogger,
+	verifyJWT echomw.VerifyJWTMiddleware,
+	authorize echomw.AuthorizeMiddleware,
+) *UserHandler {
 	return &UserHandler{
-		userSvc:   userSvc,
-		logger:    logger,
-		verifyJWT: verifyJWT,
+		userSvc:     userSvc,
+		transferSvc: transferSvc,
+		privacySvc:  privacySvc,
+		authSvc:     authSvc,
+		logger:      logger,
+		verifyJWT:   verifyJWT,
+		authorize:   authorize,
 	}
 }
 
-// RegisterRoutes registers user routes on the given group and applies JWT verification middleware.
+// RegisterRoutes registers user routes on the given group and applies JWT verification and authorization middleware.
 func (h *UserHandler) RegisterRoutes(g *echo.Group) {
 	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
 	g.GET("", h.HandleListUsers)
+	g.GET("/export", h.HandleExportUsers)
+	g.POST("/import", h.HandleImportUsers)
+	g.GET("/import/:jobId", h.HandleGetUserImport)
 	g.GET("/:id", h.HandleGetUserByID)
 	g.POST("", h.HandleCreateUser)
 	g.PUT("/:id", h.HandleUpdateUser)
 	g.DELETE("/:id", h.HandleDeleteUser)
+	g.POST("/:id/restore", h.HandleRestoreUser)
+	g.POST("/:id/status", h.HandleUpdateUserStatus)
+	g.GET("/:id/sessions", h.HandleListUserSessions)
+	g.DELETE("/:id/sessions", h.HandleEndUserSessions)
+	g.PATCH("/:id/attributes", h.HandleUpdateUserAttributes)
+	g.POST("/:id/impersonate", h.HandleImpersonateUser)
+}
+
+// RegisterMeRoutes registers the caller's self-service profile routes on a group mounted at /me.
+func (h *UserHandler) RegisterMeRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
+	g.GET("", h.HandleGetProfile)
+	g.PUT("", h.HandleUpdateProfile)
+	g.POST("/change-password", h.HandleChangePassword)
+	g.GET("/data-export", h.HandleExportMyData)
+	g.DELETE("", h.HandleEraseAccount)
 }
 
 // List returns a paginated list of users.
-// Query: page (default 1), pageSize (default 10, max 100).
+// Query: page (default 1) or cursor (the nextCursor of the previous page), pageSize (default 10, max 100).
 func (h *UserHandler) HandleListUsers(c echo.Context) error {
 	ctx := c.Request().Context()
-	page, _ := strconv.Atoi(c.QueryParam("page"))
-	pageSize, _ := strconv.Atoi(c.QueryParam("pageSize"))
-	if page == 0 {
-		page = 1
-	}
-	if pageSize == 0 {
-		pageSize = 10
+	req, err := HandleValidateBind[aggregate.PaginationReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
 	}
 
-	result, err := h.userSvc.List(ctx, page, pageSize)
+	result, err := h.userSvc.List(ctx, req)
 	if err != nil {
-		h.logger.Error("Failed to list users", "error", err)
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to list users", "error", err)
 		return HandleError(c, err)
 	}
 	return HandleSuccess(c, result)
@@ -68,7 +107,7 @@ func (h *UserHandler) HandleGetUserByID(c echo.Context) error {
 
 	user, err := h.userSvc.GetByID(ctx, id)
 	if err != nil {
-		h.logger.Error("Failed to get user", "id", id, "error", err)
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to get user", "id", id, "error", err)
 		return HandleError(c, err)
 	}
 	return HandleSuccess(c, user)
@@ -80,13 +119,13 @@ func (h *UserHandler) HandleCreateUser(c echo.Context) error {
 
 	req, err := HandleValidateBind[aggregate.CreateUserReq](c)
 	if err != nil {
-		h.logger.Error("Failed to bind create user request", "error", err)
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to bind create user request", "error", err)
 		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
 	}
 
 	user, err := h.userSvc.Create(ctx, req)
 	if err != nil {
-		h.logger.Error("Failed to create user", "error", err)
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to create user", "error", err)
 		return HandleError(c, err)
 	}
 	return HandleSuccess(c, user)
@@ -102,19 +141,19 @@ func (h *UserHandler) HandleUpdateUser(c echo.Context) error {
 
 	req, err := HandleValidateBind[aggregate.UpdateUserReq](c)
 	if err != nil {
-		h.logger.Error("Failed to bind update user request", "error", err)
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to bind update user request", "error", err)
 		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
 	}
 
 	user, err := h.userSvc.Update(ctx, id, req)
 	if err != nil {
-		h.logger.Error("Failed to update user", "id", id, "error", err)
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to update user", "id", id, "error", err)
 		return HandleError(c, err)
 	}
 	return HandleSuccess(c, user)
 }
 
-// Delete deletes a user by ID.
+// Delete soft-deletes a user by ID. Query: hard=true removes the user permanently (super-admin only).
 func (h *UserHandler) HandleDeleteUser(c echo.Context) error {
 	ctx := c.Request().Context()
 	id := c.Param("id")
@@ -122,9 +161,238 @@ func (h *UserHandler) HandleDeleteUser(c echo.Context) error {
 		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, nil))
 	}
 
-	if err := h.userSvc.Delete(ctx, id); err != nil {
-		h.logger.Error("Failed to delete user", "id", id, "error", err)
+	hard, _ := strconv.ParseBool(c.QueryParam("hard"))
+	if err := h.userSvc.Delete(ctx, id, hard); err != nil {
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to delete user", "id", id, "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
+
+// HandleRestoreUser restores a soft-deleted user by ID.
+func (h *UserHandler) HandleRestoreUser(c echo.Context) error {
+	ctx := c.Request().Context()
+	user, err := h.userSvc.Restore(ctx, c.Param("id"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, user)
+}
+
+// HandleUpdateUserStatus activates, deactivates or blocks a user. Body: {"status": "ACTIVE|INACTIVE|BLOCKED"}.
+func (h *UserHandler) HandleUpdateUserStatus(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.UpdateUserStatusReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	user, err := h.userSvc.UpdateStatus(ctx, c.Param("id"), req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, user)
+}
+
+// HandleListUserSessions lists a user's sessions, paginated like HandleListUsers.
+func (h *UserHandler) HandleListUserSessions(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.PaginationReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+
+	result, err := h.userSvc.ListSessions(ctx, c.Param("id"), req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleEndUserSessions signs a user out everywhere by ending all of their sessions.
+func (h *UserHandler) HandleEndUserSessions(c echo.Context) error {
+	ctx := c.Request().Context()
+	if err := h.userSvc.EndSessions(ctx, c.Param("id")); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
+
+// HandleUpdateUserAttributes merges custom attributes into a user's. Body: {"attributes": {"plan": "pro", "locale": null}}.
+func (h *UserHandler) HandleUpdateUserAttributes(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.UpdateUserAttributesReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	user, err := h.userSvc.UpdateAttributes(ctx, c.Param("id"), req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, user)
+}
+
+// HandleImportUsers starts importing a CSV or NDJSON file of users, sent as the body or as the multipart
+// field "file", and returns the job to poll. Query: format (csv or ndjson; by default taken from the
+// content type or file name), forcePasswordReset=true.
+func (h *UserHandler) HandleImportUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+	var file io.Reader = c.Request().Body
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	filename := ""
+	if strings.HasPrefix(contentType, echo.MIMEMultipartForm) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return HandleError(c, errorx.New(errorx.ErrBadRequest, "missing multipart field file"))
+		}
+		f, err := header.Open()
+		if err != nil {
+			return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+		}
+		defer f.Close()
+		file = f
+		contentType = header.Header.Get(echo.HeaderContentType)
+		filename = header.Filename
+	}
+	format := c.QueryParam("format")
+	if format == "" {
+		format = transferFormat(contentType, filename)
+	}
+	forcePasswordReset, _ := strconv.ParseBool(c.QueryParam("forcePasswordReset"))
+
+	job, err := h.transferSvc.StartImport(ctx, format, forcePasswordReset, file)
+	if err != nil {
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to start user import", "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, job)
+}
+
+// HandleGetUserImport returns the progress of a user import job.
+func (h *UserHandler) HandleGetUserImport(c echo.Context) error {
+	ctx := c.Request().Context()
+	job, err := h.transferSvc.GetImport(ctx, c.Param("jobId"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, job)
+}
+
+// HandleExportUsers streams every user as NDJSON or CSV. Query: format (ndjson by default or csv),
+// includePasswordHashes=true to add bcrypt hashes for a migration to another system.
+func (h *UserHandler) HandleExportUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+	format := c.QueryParam("format")
+	if format == "" {
+		format = constant.UserTransferNDJSON
+	}
+	contentType := "application/x-ndjson"
+	switch format {
+	case constant.UserTransferNDJSON:
+	case constant.UserTransferCSV:
+		contentType = "text/csv"
+	default:
+		return HandleError(c, errorx.New(errorx.ErrBadRequest, "Export format must be csv or ndjson"))
+	}
+	withPasswordHashes, _ := strconv.ParseBool(c.QueryParam("includePasswordHashes"))
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="users.%s"`, format))
+	res.Header().Set(echo.HeaderCacheControl, "no-store")
+	res.WriteHeader(http.StatusOK)
+	if err := h.transferSvc.Export(ctx, format, withPasswordHashes, res); err != nil {
+		// The status is already sent; the client sees a truncated file.
+		logger.WithContext(c.Request().Context(), h.logger).Error("Failed to export users", "error", err)
+	}
+	return nil
+}
+
+// transferFormat picks the bulk file format from a content type or file name.
+func transferFormat(contentType, filename string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "text/csv" || strings.HasSuffix(filename, ".csv"):
+		return constant.UserTransferCSV
+	case mediaType == "application/x-ndjson" || mediaType == "application/jsonl" ||
+		strings.HasSuffix(filename, ".ndjson") || strings.HasSuffix(filename, ".jsonl"):
+		return constant.UserTransferNDJSON
+	}
+	return ""
+}
+
+// HandleGetProfile returns the calling user.
+func (h *UserHandler) HandleGetProfile(c echo.Context) error {
+	ctx := c.Request().Context()
+	user, err := h.userSvc.GetProfile(ctx)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, user)
+}
+
+// HandleUpdateProfile updates the calling user's username and phone.
+func (h *UserHandler) HandleUpdateProfile(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.UpdateProfileReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	user, err := h.userSvc.UpdateProfile(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, user)
+}
+
+// HandleChangePassword changes the calling user's password and signs them out everywhere.
+func (h *UserHandler) HandleChangePassword(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.ChangePasswordReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	resp, err := h.userSvc.ChangePassword(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, resp)
+}
+
+// HandleExportMyData returns what is stored about the calling user as one JSON document.
+func (h *UserHandler) HandleExportMyData(c echo.Context) error {
+	ctx := c.Request().Context()
+	export, err := h.privacySvc.ExportData(ctx)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="data-export.json"`)
+	return HandleSuccess(c, export)
+}
+
+// HandleEraseAccount erases the calling user's account. The body repeats the account's email to confirm.
+func (h *UserHandler) HandleEraseAccount(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.EraseAccountReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	if err := h.privacySvc.EraseAccount(ctx, req); err != nil {
 		return HandleError(c, err)
 	}
 	return HandleSuccess(c, nil)
 }
+
+// HandleImpersonateUser issues the calling super admin a short-lived token acting as the user (super-admin only).
+// Body: {"reason": "ticket #123", "projectId": "optional"}.
+func (h *UserHandler) HandleImpersonateUser(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.ImpersonateReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	result, err := h.authSvc.Impersonate(ctx, c.Param("id"), req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type UserDeviceHandler struct {
+	deviceSvc service.IUserDeviceSvc
+	logger    logger.ILogger
+	verifyJWT middleware.VerifyJWTMiddleware
+	authorize middleware.AuthorizeMiddleware
+}
+
+func NewUserDeviceHandler(
+	deviceSvc service.IUserDeviceSvc,
+	logger logger.ILogger,
+	verifyJWT middleware.VerifyJWTMiddleware,
+	authorize middleware.AuthorizeMiddleware,
+) *UserDeviceHandler {
+	return &UserDeviceHandler{
+		deviceSvc: deviceSvc,
+		logger:    logger,
+		verifyJWT: verifyJWT,
+		authorize: authorize,
+	}
+}
+
+// RegisterRoutes registers the caller's devices on a group mounted at /me/devices.
+func (h *UserDeviceHandler) RegisterRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
+	g.GET("", h.HandleListDevices)
+	g.PATCH("/:id", h.HandleRenameDevice)
+	g.DELETE("/:id/sessions", h.HandleSignOutDevice)
+}
+
+// HandleListDevices lists the devices the caller signed in from, with when they were last seen.
+func (h *UserDeviceHandler) HandleListDevices(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.deviceSvc.ListDevices(ctx)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleRenameDevice sets the name the caller gave one of their devices.
+func (h *UserDeviceHandler) HandleRenameDevice(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.RenameUserDeviceReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	result, err := h.deviceSvc.RenameDevice(ctx, c.Param("id"), req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleSignOutDevice ends every session of one of the caller's devices, e.g. a lost phone.
+func (h *UserDeviceHandler) HandleSignOutDevice(c echo.Context) error {
+	ctx := c.Request().Context()
+	if err := h.deviceSvc.SignOutDevice(ctx, c.Param("id")); err != nil {
+		logger.WithContext(ctx, h.logger).Error("Failed to sign out device", "id", c.Param("id"), "error", err)
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
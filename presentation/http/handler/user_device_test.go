@@ -0,0 +1,100 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+)
+
+func TestUserDeviceHandler(t *testing.T) {
+	h := apitest.New(t)
+	chrome := func(version string) http.Header {
+		return http.Header{
+			"User-Agent":         {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/" + version + ".0.0.0 Safari/537.36"},
+			"Sec-Ch-Ua":          {`"Chromium";v="` + version + `", "Google Chrome";v="` + version + `", "Not.A/Brand";v="` + version[1:] + `"`},
+			"Sec-Ch-Ua-Platform": {`"Windows"`},
+			"Sec-Ch-Ua-Mobile":   {"?0"},
+		}
+	}
+	iphone := http.Header{"User-Agent": {"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) Safari/604.1"}}
+	do := func(method, path string, body any, token string, header http.Header) *http.Response {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, h.Server.URL+path, strings.NewReader(string(b)))
+		maps.Copy(req.Header, header)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := h.Server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+	login := func(email string, header http.Header) aggregate.LoginResp {
+		t.Helper()
+		var out aggregate.LoginResp
+		req := aggregate.LoginReq{AuthType: constant.UserAuthTypeEmail, Email: email, Password: "password123"}
+		if code := apitest.Decode(t, do(http.MethodPost, "/api/v1/auth/login", req, "", header), &out).Code; code != http.StatusOK {
+			t.Fatalf("login %s: code %d", email, code)
+		}
+		return out
+	}
+	for _, email := range []string{"ola@example.com", "pia@example.com"} {
+		do(http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: email, Password: "password123"}, "", chrome("128"))
+	}
+
+	// An updated browser, with a new GREASE brand, is still the same device; the phone is a new one.
+	desktop := login("ola@example.com", chrome("131"))
+	phone := login("ola@example.com", iphone)
+	var devices []aggregate.UserDeviceResp
+	apitest.Decode(t, do(http.MethodGet, "/api/v1/me/devices", nil, desktop.AccessToken, chrome("131")), &devices)
+	if len(devices) != 2 || devices[0].ID == devices[1].ID {
+		t.Fatalf("devices = %+v, want the desktop and the phone", devices)
+	}
+	byPlatform := map[string]aggregate.UserDeviceResp{}
+	for _, d := range devices {
+		byPlatform[d.Platform] = d
+	}
+	pc, mobile := byPlatform["Windows"], byPlatform[""]
+	if !pc.Current || pc.ActiveSessions != 2 || mobile.Current || mobile.ActiveSessions != 1 || !strings.Contains(mobile.UserAgent, "iPhone") {
+		t.Fatalf("devices = %+v, want the current desktop with 2 sessions and the phone with 1", devices)
+	}
+
+	var renamed aggregate.UserDeviceResp
+	resp := do(http.MethodPatch, "/api/v1/me/devices/"+mobile.ID, aggregate.RenameUserDeviceReq{Name: " Ola's phone "}, desktop.AccessToken, nil)
+	if apitest.Decode(t, resp, &renamed).Code != http.StatusOK || renamed.Name != "Ola's phone" {
+		t.Fatalf("rename = %+v, want the trimmed name", renamed)
+	}
+	other := login("pia@example.com", chrome("131"))
+	if code := apitest.Decode(t, do(http.MethodDelete, "/api/v1/me/devices/"+mobile.ID+"/sessions", nil, other.AccessToken, nil), nil).Code; code != int(errorx.ErrUserDeviceNotFound) {
+		t.Errorf("signing out another user's device: code %d, want %d", code, errorx.ErrUserDeviceNotFound)
+	}
+
+	// Signing the phone out ends its sessions only.
+	if code := apitest.Decode(t, do(http.MethodDelete, "/api/v1/me/devices/"+mobile.ID+"/sessions", nil, desktop.AccessToken, nil), nil).Code; code != http.StatusOK {
+		t.Fatalf("sign out device: code %d", code)
+	}
+	refresh := func(tokens aggregate.LoginResp) int {
+		return apitest.Decode(t, do(http.MethodPost, "/api/v1/auth/refresh-token", aggregate.RefreshTokenReq{RefreshToken: tokens.RefreshToken}, "", nil), nil).Code
+	}
+	if code := refresh(phone); code == http.StatusOK {
+		t.Error("refreshing a signed-out device's session succeeded")
+	}
+	if code := refresh(desktop); code != http.StatusOK {
+		t.Errorf("refreshing the desktop session: code %d, want 200", code)
+	}
+	if session := h.Sessions.FindOneById(context.Background(), phone.SessionID); session == nil || session.IsActive {
+		t.Errorf("phone session = %+v, want ended", session)
+	}
+}
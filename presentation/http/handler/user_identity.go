@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/hiamthach108/dreon-auth/presentation/http/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+type UserIdentityHandler struct {
+	identitySvc service.IUserIdentitySvc
+	logger      logger.ILogger
+	verifyJWT   middleware.VerifyJWTMiddleware
+	authorize   middleware.AuthorizeMiddleware
+}
+
+func NewUserIdentityHandler(
+	identitySvc service.IUserIdentitySvc,
+	logger logger.ILogger,
+	verifyJWT middleware.VerifyJWTMiddleware,
+	authorize middleware.AuthorizeMiddleware,
+) *UserIdentityHandler {
+	return &UserIdentityHandler{
+		identitySvc: identitySvc,
+		logger:      logger,
+		verifyJWT:   verifyJWT,
+		authorize:   authorize,
+	}
+}
+
+// RegisterRoutes registers the caller's linked identities on a group mounted at /me/identities.
+func (h *UserIdentityHandler) RegisterRoutes(g *echo.Group) {
+	g.Use(echo.MiddlewareFunc(h.verifyJWT))
+	g.Use(echo.MiddlewareFunc(h.authorize))
+	g.GET("", h.HandleListIdentities)
+	g.POST("/confirm", h.HandleConfirmLink)
+	g.DELETE("/:id", h.HandleUnlinkIdentity)
+}
+
+// HandleListIdentities lists the external logins linked to the caller's account.
+func (h *UserIdentityHandler) HandleListIdentities(c echo.Context) error {
+	ctx := c.Request().Context()
+	result, err := h.identitySvc.List(ctx)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleConfirmLink links the external login behind a linkToken from /auth/session-from-state to the caller's account.
+func (h *UserIdentityHandler) HandleConfirmLink(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := HandleValidateBind[aggregate.ConfirmIdentityLinkReq](c)
+	if err != nil {
+		return HandleError(c, errorx.Wrap(errorx.ErrBadRequest, err))
+	}
+	result, err := h.identitySvc.ConfirmLink(ctx, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, result)
+}
+
+// HandleUnlinkIdentity removes one of the caller's linked external logins.
+func (h *UserIdentityHandler) HandleUnlinkIdentity(c echo.Context) error {
+	ctx := c.Request().Context()
+	if err := h.identitySvc.Unlink(ctx, c.Param("id")); err != nil {
+		return HandleError(c, err)
+	}
+	return HandleSuccess(c, nil)
+}
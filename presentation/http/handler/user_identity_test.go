@@ -0,0 +1,151 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/hiamthach108/dreon-auth/pkg/statetoken"
+)
+
+func TestUserIdentityHandler_Linking(t *testing.T) {
+	h := apitest.New(t)
+	ctx := context.Background()
+	sealer, err := statetoken.NewSealerFromConfig(h.Config)
+	if err != nil {
+		t.Fatalf("sealer: %v", err)
+	}
+	googleLogin := func(email, sub string) *http.Response {
+		t.Helper()
+		state, err := sealer.Seal(aggregate.OAuthRefreshState{
+			ID:       uuid.NewString(),
+			AuthType: constant.UserAuthTypeGoogle,
+			UserData: aggregate.OAuthUserData{Email: email, ProviderID: sub},
+		}, time.Minute)
+		if err != nil {
+			t.Fatalf("seal: %v", err)
+		}
+		return h.Do(t, http.MethodPost, "/api/v1/auth/session-from-state", aggregate.SessionFromStateReq{RefreshState: state}, "")
+	}
+
+	// A new email gets an account with the identity already linked.
+	resp := googleLogin("new@example.com", "g-new")
+	var created aggregate.LoginResp
+	apitest.Decode(t, resp, &created)
+	if resp.StatusCode != http.StatusOK || created.AccessToken == "" || h.UserIdentities.Len() != 1 {
+		t.Fatalf("first google login: status %d, %+v, %d identities", resp.StatusCode, created, h.UserIdentities.Len())
+	}
+
+	// An existing password account is not signed in by a matching email alone.
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "nia@example.com", Password: "password123"}, "")
+	resp.Body.Close()
+	resp = googleLogin("nia@example.com", "g-nia")
+	var pending aggregate.LoginResp
+	apitest.Decode(t, resp, &pending)
+	if resp.StatusCode != http.StatusOK || !pending.LinkRequired || pending.LinkToken == "" || pending.AccessToken != "" {
+		t.Fatalf("google login for existing email: status %d, %+v", resp.StatusCode, pending)
+	}
+
+	confirm := "/api/v1/me/identities/confirm"
+	newUser, _ := h.Users.FindByEmail(ctx, "new@example.com")
+	if resp := h.Do(t, http.MethodPost, confirm, aggregate.ConfirmIdentityLinkReq{LinkToken: pending.LinkToken}, h.Token(jwt.Payload{UserID: newUser.ID})); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("confirm from another account: status %d, want 403", resp.StatusCode)
+	}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", aggregate.LoginReq{AuthType: "EMAIL", Email: "nia@example.com", Password: "password123"}, "")
+	var nia aggregate.LoginResp
+	apitest.Decode(t, resp, &nia)
+	resp = h.Do(t, http.MethodPost, confirm, aggregate.ConfirmIdentityLinkReq{LinkToken: pending.LinkToken}, nia.AccessToken)
+	var linked aggregate.UserIdentityResp
+	apitest.Decode(t, resp, &linked)
+	if resp.StatusCode != http.StatusOK || linked.AuthType != constant.UserAuthTypeGoogle {
+		t.Fatalf("confirm: status %d, %+v", resp.StatusCode, linked)
+	}
+	if resp := h.Do(t, http.MethodPost, confirm, aggregate.ConfirmIdentityLinkReq{LinkToken: pending.LinkToken}, nia.AccessToken); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("reused link token: status %d, want 400", resp.StatusCode)
+	}
+
+	// Once linked, the Google login signs straight in.
+	resp = googleLogin("nia@example.com", "g-nia")
+	var signedIn aggregate.LoginResp
+	apitest.Decode(t, resp, &signedIn)
+	if resp.StatusCode != http.StatusOK || signedIn.LinkRequired || signedIn.AccessToken == "" {
+		t.Fatalf("google login after linking: status %d, %+v", resp.StatusCode, signedIn)
+	}
+
+	var identities []aggregate.UserIdentityResp
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/api/v1/me/identities", nil, nia.AccessToken), &identities)
+	if len(identities) != 1 || identities[0].ID != linked.ID {
+		t.Fatalf("identities = %+v", identities)
+	}
+	if resp := h.Do(t, http.MethodDelete, "/api/v1/me/identities/"+linked.ID, nil, h.Token(jwt.Payload{UserID: newUser.ID})); resp.StatusCode == http.StatusOK {
+		t.Error("unlinked another user's identity")
+	}
+	if resp := h.Do(t, http.MethodDelete, "/api/v1/me/identities/"+linked.ID, nil, nia.AccessToken); resp.StatusCode != http.StatusOK {
+		t.Fatalf("unlink: status %d", resp.StatusCode)
+	}
+	resp = googleLogin("nia@example.com", "g-nia")
+	apitest.Decode(t, resp, &pending)
+	if !pending.LinkRequired {
+		t.Error("google login after unlinking signed in without confirmation")
+	}
+}
+
+func TestUserIdentityHandler_MultipleIdentities(t *testing.T) {
+	h := apitest.New(t)
+	ctx := context.Background()
+	sealer, err := statetoken.NewSealerFromConfig(h.Config)
+	if err != nil {
+		t.Fatalf("sealer: %v", err)
+	}
+	externalLogin := func(authType constant.UserAuthType, provider, sub string) aggregate.LoginResp {
+		t.Helper()
+		state, err := sealer.Seal(aggregate.OAuthRefreshState{
+			ID:       uuid.NewString(),
+			AuthType: authType,
+			Provider: provider,
+			UserData: aggregate.OAuthUserData{Email: "omar@example.com", ProviderID: sub},
+		}, time.Minute)
+		if err != nil {
+			t.Fatalf("seal: %v", err)
+		}
+		resp := h.Do(t, http.MethodPost, "/api/v1/auth/session-from-state", aggregate.SessionFromStateReq{RefreshState: state}, "")
+		var out aggregate.LoginResp
+		apitest.Decode(t, resp, &out)
+		if resp.StatusCode != http.StatusOK || out.AccessToken == "" {
+			t.Fatalf("%s login: status %d, %+v", authType, resp.StatusCode, out)
+		}
+		return out
+	}
+
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "omar@example.com", Password: "password123"}, "")
+	resp.Body.Close()
+	user, _ := h.Users.FindByEmail(ctx, "omar@example.com")
+	// One linked directly, one as migrated from the user row, without its OIDC issuer.
+	h.UserIdentities.Create(ctx, &model.UserIdentity{UserID: user.ID, AuthType: constant.UserAuthTypeGoogle, ProviderUserID: "g-omar"})
+	legacy, _ := h.UserIdentities.Create(ctx, &model.UserIdentity{UserID: user.ID, AuthType: constant.UserAuthTypeOIDC, ProviderUserID: "okta-omar"})
+
+	// Password, Google and OIDC all sign in to the same account.
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", aggregate.LoginReq{AuthType: "EMAIL", Email: "omar@example.com", Password: "password123"}, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("password login: status %d", resp.StatusCode)
+	}
+	externalLogin(constant.UserAuthTypeGoogle, "", "g-omar")
+	tokens := externalLogin(constant.UserAuthTypeOIDC, "okta", "okta-omar")
+
+	if claimed := h.UserIdentities.FindOneById(ctx, legacy.ID); claimed == nil || claimed.Provider != "okta" || claimed.LastUsedAt == nil {
+		t.Errorf("migrated identity = %+v, want it claimed by the okta login", claimed)
+	}
+	var identities []aggregate.UserIdentityResp
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/api/v1/me/identities", nil, tokens.AccessToken), &identities)
+	if len(identities) != 2 || h.Users.Len() != 1 {
+		t.Errorf("identities = %+v, users = %d, want two identities on one user", identities, h.Users.Len())
+	}
+}
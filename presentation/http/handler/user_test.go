@@ -0,0 +1,528 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/helper"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestUserHandler_SoftDelete(t *testing.T) {
+	h := apitest.New(t)
+	ctx := context.Background()
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	user := h.Token(jwt.Payload{UserID: "operator"})
+	kim, _ := h.Users.Create(ctx, &model.User{Username: "kim", Email: "kim@example.com"})
+	path := "/api/v1/users/" + kim.ID
+
+	if resp := h.Do(t, http.MethodDelete, path, nil, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodGet, path, nil, admin); resp.StatusCode == http.StatusOK {
+		t.Error("soft-deleted user is still returned")
+	}
+	if h.Users.FindDeletedById(ctx, kim.ID) == nil {
+		t.Fatal("soft delete removed the row")
+	}
+	if _, err := h.Users.Create(ctx, &model.User{Username: "kim", Email: "other@example.com"}); err == nil {
+		t.Error("soft-deleted user's username was released")
+	}
+
+	if resp := h.Do(t, http.MethodPost, path+"/restore", nil, user); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin restore: status %d, want 403", resp.StatusCode)
+	}
+	resp := h.Do(t, http.MethodPost, path+"/restore", nil, admin)
+	var restored aggregate.UserDto
+	apitest.Decode(t, resp, &restored)
+	if resp.StatusCode != http.StatusOK || restored.ID != kim.ID {
+		t.Fatalf("restore: status %d, %+v", resp.StatusCode, restored)
+	}
+	if resp := h.Do(t, http.MethodPost, path+"/restore", nil, admin); resp.StatusCode == http.StatusOK {
+		t.Error("restored a user that is not deleted")
+	}
+
+	if resp := h.Do(t, http.MethodDelete, path+"?hard=true", nil, user); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin hard delete: status %d, want 403", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodDelete, path+"?hard=true", nil, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("hard delete: status %d", resp.StatusCode)
+	}
+	if h.Users.FindOneById(ctx, kim.ID) != nil || h.Users.FindDeletedById(ctx, kim.ID) != nil {
+		t.Error("hard delete kept the row")
+	}
+}
+
+func TestUserHandler_Status(t *testing.T) {
+	h := apitest.New(t)
+	ctx := context.Background()
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "lee@example.com", Password: "password123"}, "")
+	resp.Body.Close()
+	lee, _ := h.Users.FindByEmail(ctx, "lee@example.com")
+	login := aggregate.LoginReq{AuthType: "EMAIL", Email: "lee@example.com", Password: "password123"}
+	status := "/api/v1/users/" + lee.ID + "/status"
+
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	var tokens aggregate.LoginResp
+	apitest.Decode(t, resp, &tokens)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("login: status %d", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodGet, "/api/v1/auth/session", nil, tokens.AccessToken); resp.StatusCode != http.StatusOK {
+		t.Fatalf("session before block: status %d", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodGet, "/api/v1/me", nil, tokens.AccessToken); resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /me before block: status %d", resp.StatusCode)
+	}
+
+	if resp := h.Do(t, http.MethodPost, status, aggregate.UpdateUserStatusReq{Status: constant.UserStatusBlocked}, tokens.AccessToken); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin status change: status %d, want 403", resp.StatusCode)
+	}
+	resp = h.Do(t, http.MethodPost, status, aggregate.UpdateUserStatusReq{Status: constant.UserStatusBlocked}, admin)
+	var blocked aggregate.UserDto
+	apitest.Decode(t, resp, &blocked)
+	if resp.StatusCode != http.StatusOK || blocked.Status != constant.UserStatusBlocked.String() {
+		t.Fatalf("block: status %d, %+v", resp.StatusCode, blocked)
+	}
+
+	// Blocking ends the user's sessions, so tokens already issued stop validating and logins fail.
+	if resp := h.Do(t, http.MethodGet, "/api/v1/auth/session", nil, tokens.AccessToken); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("session after block: status %d, want 401", resp.StatusCode)
+	}
+	// The rest of the API rejects them too, although the status seen before the block was cached.
+	if resp := h.Do(t, http.MethodGet, "/api/v1/me", nil, tokens.AccessToken); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /me after block: status %d, want 401", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/refresh-token", aggregate.RefreshTokenReq{RefreshToken: tokens.RefreshToken}, ""); resp.StatusCode == http.StatusOK {
+		t.Error("refreshed a blocked user's session")
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""); resp.StatusCode == http.StatusOK {
+		t.Error("blocked user logged in")
+	}
+
+	// Blocked users have to be reactivated before they can be deactivated.
+	if resp := h.Do(t, http.MethodPost, status, aggregate.UpdateUserStatusReq{Status: constant.UserStatusInactive}, admin); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("blocked to inactive: status %d, want 400", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodPost, status, aggregate.UpdateUserStatusReq{Status: "DELETED"}, admin); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown status: status %d, want 400", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodPost, status, aggregate.UpdateUserStatusReq{Status: constant.UserStatusActive}, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("reactivate: status %d", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("login after reactivation: status %d", resp.StatusCode)
+	}
+}
+
+func TestUserHandler_EndUserSessions(t *testing.T) {
+	h := apitest.New(t)
+	ctx := context.Background()
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "sam@example.com", Password: "password123"}, "")
+	resp.Body.Close()
+	sam, _ := h.Users.FindByEmail(ctx, "sam@example.com")
+	login := aggregate.LoginReq{AuthType: "EMAIL", Email: "sam@example.com", Password: "password123"}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	var tokens aggregate.LoginResp
+	apitest.Decode(t, resp, &tokens)
+	path := "/api/v1/users/" + sam.ID + "/sessions"
+
+	if resp := h.Do(t, http.MethodDelete, path, nil, tokens.AccessToken); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin end sessions: status %d, want 403", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodDelete, "/api/v1/users/missing/sessions", nil, admin); resp.StatusCode == http.StatusOK {
+		t.Error("ended the sessions of an unknown user")
+	}
+	if resp := h.Do(t, http.MethodDelete, path, nil, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("end sessions: status %d", resp.StatusCode)
+	}
+
+	if resp := h.Do(t, http.MethodGet, "/api/v1/auth/session", nil, tokens.AccessToken); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("session after revocation: status %d, want 401", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/refresh-token", aggregate.RefreshTokenReq{RefreshToken: tokens.RefreshToken}, ""); resp.StatusCode == http.StatusOK {
+		t.Error("refreshed a revoked session")
+	}
+	// Unlike blocking, revocation leaves the account usable.
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("login after revocation: status %d", resp.StatusCode)
+	}
+}
+
+func TestUserHandler_SelfServiceProfile(t *testing.T) {
+	h := apitest.New(t)
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "sam@example.com", Password: "password123"}, "")
+	resp.Body.Close()
+	login := aggregate.LoginReq{AuthType: "EMAIL", Email: "sam@example.com", Password: "password123"}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	var tokens aggregate.LoginResp
+	apitest.Decode(t, resp, &tokens)
+
+	resp = h.Do(t, http.MethodGet, "/api/v1/me", nil, tokens.AccessToken)
+	var me aggregate.UserDto
+	apitest.Decode(t, resp, &me)
+	if resp.StatusCode != http.StatusOK || me.Email != "sam@example.com" {
+		t.Fatalf("get profile: status %d, %+v", resp.StatusCode, me)
+	}
+	if resp := h.Do(t, http.MethodGet, "/api/v1/me", nil, h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("super admin profile: status %d, want 401", resp.StatusCode)
+	}
+
+	username, phone := "maxine", "+15550001111"
+	resp = h.Do(t, http.MethodPut, "/api/v1/me", aggregate.UpdateProfileReq{Username: &username, Phone: &phone}, tokens.AccessToken)
+	var updated aggregate.UserDto
+	apitest.Decode(t, resp, &updated)
+	if resp.StatusCode != http.StatusOK || updated.Username != username || updated.Phone != phone || updated.Email != "sam@example.com" {
+		t.Fatalf("update profile: status %d, %+v", resp.StatusCode, updated)
+	}
+
+	wrong := aggregate.ChangePasswordReq{CurrentPassword: "nope", NewPassword: "newpassword123"}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/me/change-password", wrong, tokens.AccessToken); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("wrong current password: status %d, want 400", resp.StatusCode)
+	}
+	change := aggregate.ChangePasswordReq{CurrentPassword: "password123", NewPassword: "newpassword123"}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/me/change-password", change, tokens.AccessToken); resp.StatusCode != http.StatusOK {
+		t.Fatalf("change password: status %d", resp.StatusCode)
+	}
+
+	// Changing the password ends the sessions opened with the old one.
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/refresh-token", aggregate.RefreshTokenReq{RefreshToken: tokens.RefreshToken}, ""); resp.StatusCode == http.StatusOK {
+		t.Error("old session still refreshes")
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""); resp.StatusCode == http.StatusOK {
+		t.Error("old password still works")
+	}
+	login.Password = "newpassword123"
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("login with new password: status %d", resp.StatusCode)
+	}
+}
+
+func TestUserHandler_Attributes(t *testing.T) {
+	h := apitest.New(t)
+	ctx := context.Background()
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	resp := h.Do(t, http.MethodPost, "/api/v1/projects", aggregate.CreateProjectReq{
+		Name: "Shop",
+		AttributeSchema: []aggregate.UserAttributeDef{
+			{Key: "plan", Type: constant.UserAttributeString, Claim: true},
+			{Key: "seats", Type: constant.UserAttributeNumber},
+		},
+	}, admin)
+	var project aggregate.ProjectDto
+	apitest.Decode(t, resp, &project)
+	if resp.StatusCode != http.StatusOK || len(project.AttributeSchema) != 2 {
+		t.Fatalf("create project: status %d, %+v", resp.StatusCode, project)
+	}
+	dup := aggregate.UpdateProjectReq{AttributeSchema: &[]aggregate.UserAttributeDef{{Key: "plan", Type: "string"}, {Key: "plan", Type: "number"}}}
+	if resp := h.Do(t, http.MethodPut, "/api/v1/projects/"+project.ID, dup, admin); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("duplicate schema keys: status %d, want 400", resp.StatusCode)
+	}
+
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "pia@example.com", Password: "password123"}, "")
+	resp.Body.Close()
+	pia, _ := h.Users.FindByEmail(ctx, "pia@example.com")
+	h.ProjectMembers.Create(ctx, &model.ProjectMember{ProjectID: project.ID, UserID: pia.ID, Status: constant.ProjectMemberActive})
+	path := "/api/v1/users/" + pia.ID + "/attributes"
+
+	bad := aggregate.UpdateUserAttributesReq{Attributes: map[string]any{"seats": "many"}}
+	if resp := h.Do(t, http.MethodPatch, path, bad, admin); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("attribute of the wrong type: status %d, want 400", resp.StatusCode)
+	}
+	patch := aggregate.UpdateUserAttributesReq{Attributes: map[string]any{"plan": "pro", "seats": 5, "locale": "vi"}}
+	if resp := h.Do(t, http.MethodPatch, path, patch, admin); resp.StatusCode != http.StatusOK {
+		t.Fatalf("patch attributes: status %d", resp.StatusCode)
+	}
+	resp = h.Do(t, http.MethodPatch, path, aggregate.UpdateUserAttributesReq{Attributes: map[string]any{"locale": nil}}, admin)
+	var user aggregate.UserDto
+	apitest.Decode(t, resp, &user)
+	if _, ok := user.Attributes["locale"]; resp.StatusCode != http.StatusOK || ok || user.Attributes["plan"] != "pro" {
+		t.Fatalf("remove attribute: status %d, attributes %v", resp.StatusCode, user.Attributes)
+	}
+
+	// Only attributes the schema marks as claims reach the token, and only for the project's sign-ins.
+	login := aggregate.LoginReq{AuthType: "EMAIL", Email: "pia@example.com", Password: "password123", ProjectID: project.ID}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	var tokens aggregate.LoginResp
+	apitest.Decode(t, resp, &tokens)
+	payload, err := h.Jwt.Verify(ctx, tokens.AccessToken)
+	if err != nil || len(payload.Attributes) != 1 || payload.Attributes["plan"] != "pro" {
+		t.Errorf("project token attributes = %v, %v", payload, err)
+	}
+	login.ProjectID = ""
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	apitest.Decode(t, resp, &tokens)
+	if payload, _ := h.Jwt.Verify(ctx, tokens.AccessToken); payload == nil || payload.Attributes != nil {
+		t.Errorf("token without project carries attributes: %+v", payload)
+	}
+
+	// Claim attributes end up in tokens, so users cannot set them, not even their own.
+	own := aggregate.UpdateUserAttributesReq{Attributes: map[string]any{"plan": "enterprise"}}
+	if resp := h.Do(t, http.MethodPatch, path, own, tokens.AccessToken); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("patch own attributes without admin: status %d, want 403", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodPatch, "/api/v1/users/admin/attributes", own, tokens.AccessToken); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("patch another user's attributes without admin: status %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestUserHandler_ImportExport(t *testing.T) {
+	h := apitest.New(t)
+	ctx := context.Background()
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	upload := func(query, contentType, body, token string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, h.Server.URL+"/api/v1/users/import"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := h.Server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+	waitForJob := func(resp *http.Response) aggregate.UserImportJob {
+		t.Helper()
+		var job aggregate.UserImportJob
+		apitest.Decode(t, resp, &job)
+		if resp.StatusCode != http.StatusOK || job.ID == "" {
+			t.Fatalf("start import: status %d, %+v", resp.StatusCode, job)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for job.Status == constant.UserImportRunning && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+			apitest.Decode(t, h.Do(t, http.MethodGet, "/api/v1/users/import/"+job.ID, nil, admin), &job)
+		}
+		if job.Status != constant.UserImportCompleted {
+			t.Fatalf("import job = %+v, want completed", job)
+		}
+		return job
+	}
+
+	if resp := upload("", "text/csv", "email\nx@example.com\n", h.Token(jwt.Payload{UserID: "operator"})); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin import: status %d, want 403", resp.StatusCode)
+	}
+	if resp := upload("", "text/csv", "username\nana\n", admin); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("CSV without email column: status %d, want 400", resp.StatusCode)
+	}
+
+	hash, _ := helper.HashPassword("password123")
+	csvFile := "email,username,passwordHash,status\n" +
+		"ana@example.com,ana," + hash + ",\n" +
+		"bo@example.com,,,active\n" +
+		"not-an-email,,,\n" +
+		"ana@example.com,ana2,,\n"
+	job := waitForJob(upload("", "text/csv", csvFile, admin))
+	if job.Total != 4 || job.Imported != 2 || job.Skipped != 2 || len(job.Errors) != 2 || job.Errors[0].Line != 4 {
+		t.Fatalf("CSV import job = %+v", job)
+	}
+	login := aggregate.LoginReq{AuthType: "EMAIL", Email: "ana@example.com", Password: "password123"}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("login with imported hash: status %d", resp.StatusCode)
+	}
+	if bo, _ := h.Users.FindByEmail(ctx, "bo@example.com"); bo == nil || bo.Username != "bo@example.com" || !bo.PasswordResetRequired {
+		t.Errorf("user imported without hash = %+v, want reset required", bo)
+	}
+
+	// Forcing a reset blocks password sign-in until the user sets a new password.
+	ndjson := `{"email":"cy@example.com","passwordHash":"` + hash + `","attributes":{"plan":"pro"}}` + "\n\n{broken\n"
+	job = waitForJob(upload("?forcePasswordReset=true", "application/x-ndjson", ndjson, admin))
+	if job.Imported != 1 || job.Skipped != 1 || job.Errors[0].Line != 3 {
+		t.Fatalf("NDJSON import job = %+v", job)
+	}
+	login.Email = "cy@example.com"
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("login before reset: status %d, want 403", resp.StatusCode)
+	}
+	cy, _ := h.Users.FindByEmail(ctx, "cy@example.com")
+	reset := aggregate.ChangePasswordReq{NewPassword: "newpassword123"}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/me/change-password", reset, h.Token(jwt.Payload{UserID: cy.ID})); resp.StatusCode != http.StatusOK {
+		t.Fatalf("set new password: status %d", resp.StatusCode)
+	}
+	login.Password = "newpassword123"
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("login after reset: status %d", resp.StatusCode)
+	}
+
+	resp := h.Do(t, http.MethodGet, "/api/v1/users/export", nil, admin)
+	body, _ := io.ReadAll(resp.Body)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	var first aggregate.UserExportRecord
+	if resp.StatusCode != http.StatusOK || len(lines) != 3 || json.Unmarshal([]byte(lines[0]), &first) != nil || first.Email == "" || first.PasswordHash != "" {
+		t.Fatalf("NDJSON export: status %d, %s", resp.StatusCode, body)
+	}
+	resp = h.Do(t, http.MethodGet, "/api/v1/users/export?format=csv&includePasswordHashes=true", nil, admin)
+	body, _ = io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(string(body), "id,email,username,phone,status,passwordHash") || !strings.Contains(string(body), hash) {
+		t.Errorf("CSV export: status %d, %s", resp.StatusCode, body)
+	}
+}
+
+func TestUserHandler_AccountErasure(t *testing.T) {
+	h := apitest.New(t)
+	ctx := context.Background()
+	resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "eve@example.com", Password: "password123"}, "")
+	resp.Body.Close()
+	eve, _ := h.Users.FindByEmail(ctx, "eve@example.com")
+	login := aggregate.LoginReq{AuthType: "EMAIL", Email: "eve@example.com", Password: "password123"}
+	resp = h.Do(t, http.MethodPost, "/api/v1/auth/login", login, "")
+	var tokens aggregate.LoginResp
+	apitest.Decode(t, resp, &tokens)
+
+	for _, tuple := range []model.RelationTuple{
+		{Namespace: "document", ObjectID: "doc-1", Relation: constant.RelationEditor, SubjectNamespace: constant.RelationNamespaceUser, SubjectObjectID: eve.ID, IsActive: true},
+		{Namespace: constant.RelationNamespaceUser, ObjectID: eve.ID, Relation: "manager", SubjectNamespace: constant.RelationNamespaceUser, SubjectObjectID: "boss", IsActive: true},
+		{Namespace: "document", ObjectID: "doc-1", Relation: constant.RelationEditor, SubjectNamespace: constant.RelationNamespaceUser, SubjectObjectID: "someone-else", IsActive: true},
+	} {
+		if _, err := h.RelationTuple.Create(ctx, &tuple); err != nil {
+			t.Fatalf("seed tuple: %v", err)
+		}
+	}
+	if _, err := h.UserIdentities.Create(ctx, &model.UserIdentity{UserID: eve.ID, AuthType: constant.UserAuthTypeGoogle, ProviderUserID: "g-eve", Email: eve.Email}); err != nil {
+		t.Fatalf("seed identity: %v", err)
+	}
+	if _, err := h.AccessDenials.Create(ctx, &model.AccessDenial{ProjectID: "p1", UserID: eve.ID, Email: eve.Email, Stage: "LOGIN", Reason: "ip", IP: "10.0.0.1", Country: "DE"}); err != nil {
+		t.Fatalf("seed denial: %v", err)
+	}
+
+	resp = h.Do(t, http.MethodGet, "/api/v1/me/data-export", nil, tokens.AccessToken)
+	var export aggregate.UserDataExport
+	apitest.Decode(t, resp, &export)
+	if resp.StatusCode != http.StatusOK || export.Profile.Email != eve.Email {
+		t.Fatalf("data export: status %d, %+v", resp.StatusCode, export.Profile)
+	}
+	if len(export.Sessions) == 0 || len(export.Identities) != 1 || len(export.Relations) != 2 || len(export.AccessDenials) != 1 {
+		t.Errorf("data export: %d sessions, %d identities, %d relations, %d denials",
+			len(export.Sessions), len(export.Identities), len(export.Relations), len(export.AccessDenials))
+	}
+
+	if resp := h.Do(t, http.MethodDelete, "/api/v1/me", aggregate.EraseAccountReq{ConfirmEmail: "wrong@example.com"}, tokens.AccessToken); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("erase with wrong confirmation: status %d, want 400", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodDelete, "/api/v1/me", aggregate.EraseAccountReq{ConfirmEmail: "EVE@example.com"}, tokens.AccessToken); resp.StatusCode != http.StatusOK {
+		t.Fatalf("erase: status %d", resp.StatusCode)
+	}
+
+	if h.Users.FindOneById(ctx, eve.ID) != nil {
+		t.Error("erased user is still live")
+	}
+	erased := h.Users.FindDeletedById(ctx, eve.ID)
+	if erased == nil || erased.ErasedAt == nil || erased.Email == "eve@example.com" || erased.Username == "eve@example.com" {
+		t.Fatalf("erased user was not anonymized: %+v", erased)
+	}
+	if sessions, _ := h.Sessions.FindByUserID(ctx, eve.ID); len(sessions) != 0 {
+		t.Errorf("%d sessions kept", len(sessions))
+	}
+	if identities, _ := h.UserIdentities.FindByUserID(ctx, eve.ID); len(identities) != 0 {
+		t.Errorf("%d identities kept", len(identities))
+	}
+	if h.RelationTuple.Len() != 1 {
+		t.Errorf("%d relation tuples left, want only the other user's", h.RelationTuple.Len())
+	}
+	denials, _ := h.AccessDenials.FindByUserID(ctx, eve.ID)
+	if len(denials) != 1 || denials[0].Email != "" || denials[0].IP != "" || denials[0].Country != "" {
+		t.Errorf("access denials not kept anonymized: %+v", denials)
+	}
+	if len(h.Events.Published(constant.EventUserErased)) != 1 {
+		t.Error("user.erased was not published")
+	}
+
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/refresh-token", aggregate.RefreshTokenReq{RefreshToken: tokens.RefreshToken}, ""); resp.StatusCode == http.StatusOK {
+		t.Error("erased user's session still refreshes")
+	}
+	if resp := h.Do(t, http.MethodPost, "/api/v1/auth/login", login, ""); resp.StatusCode == http.StatusOK {
+		t.Error("erased user logged in")
+	}
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	if resp := h.Do(t, http.MethodPost, "/api/v1/users/"+eve.ID+"/restore", nil, admin); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("restore erased user: status %d, want 400", resp.StatusCode)
+	}
+
+	// The purge waits for the audit retention to pass, then removes the row and its audit records.
+	if n, err := h.Privacy.PurgeErased(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("purge within retention: %d, %v", n, err)
+	}
+	if n, err := h.Privacy.PurgeErased(ctx, time.Now().Add(time.Second)); err != nil || n != 1 {
+		t.Fatalf("purge: %d, %v", n, err)
+	}
+	if h.Users.FindDeletedById(ctx, eve.ID) != nil || h.AccessDenials.Len() != 0 {
+		t.Error("purge kept the erased user's data")
+	}
+}
+
+func TestUserHandler_Impersonation(t *testing.T) {
+	h := apitest.New(t)
+	admin := h.Token(jwt.Payload{UserID: "admin", Email: "admin@example.com", IsSuperAdmin: true})
+	var user aggregate.TokenResp
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{Email: "ivy@example.com", Password: "password123"}, ""), &user)
+	path := "/api/v1/users/" + user.UserID + "/impersonate"
+	if err := h.Users.Update(context.Background(), user.UserID, model.User{Status: "active"}, "status"); err != nil {
+		t.Fatalf("set status: %v", err)
+	}
+
+	if resp := h.Do(t, http.MethodPost, path, aggregate.ImpersonateReq{Reason: "support"}, user.AccessToken); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("impersonation by a user status = %d, want 403", resp.StatusCode)
+	}
+	if resp := h.Do(t, http.MethodPost, path, aggregate.ImpersonateReq{}, admin); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("impersonation without a reason status = %d, want 400", resp.StatusCode)
+	}
+	var imp aggregate.ImpersonationResp
+	apitest.Decode(t, h.Do(t, http.MethodPost, path, aggregate.ImpersonateReq{Reason: "ticket 42"}, admin), &imp)
+	if imp.AccessToken == "" || imp.UserID != user.UserID || time.Until(imp.ExpiresAt) > constant.ImpersonationTTL {
+		t.Fatalf("impersonation = %+v", imp)
+	}
+
+	// The token acts as the user and names the admin for the banner.
+	var session aggregate.SessionResp
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/api/v1/auth/session", nil, imp.AccessToken), &session)
+	if session.UserID != user.UserID || session.IsSuperAdmin || session.Impersonator == nil || session.Impersonator.ID != "admin" {
+		t.Errorf("impersonated session = %+v", session.Payload)
+	}
+	if resp := h.Do(t, http.MethodGet, "/api/v1/me", nil, imp.AccessToken); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /me while impersonating status = %d, want 200", resp.StatusCode)
+	}
+	for _, blocked := range []struct{ method, path string }{
+		{http.MethodPost, "/api/v1/me/change-password"},
+		{http.MethodDelete, "/api/v1/me"},
+		{http.MethodDelete, "/api/v1/trusted-devices"},
+		{http.MethodPost, "/api/v1/auth/mfa/totp/enable"},
+	} {
+		if resp := h.Do(t, blocked.method, blocked.path, map[string]any{}, imp.AccessToken); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s %s while impersonating status = %d, want 403", blocked.method, blocked.path, resp.StatusCode)
+		}
+	}
+
+	records := h.Impersonations.Filter(func(*model.Impersonation) bool { return true })
+	if len(records) != 1 || records[0].ImpersonatorID != "admin" || records[0].UserID != user.UserID ||
+		records[0].Reason != "ticket 42" || records[0].SessionID != imp.SessionID {
+		t.Errorf("impersonation records = %+v", records)
+	}
+	events := h.Events.Published(constant.EventUserImpersonated)
+	if len(events) != 1 || events[0].Subject != user.UserID || events[0].Data.(service.UserImpersonatedEvent).ImpersonatorID != "admin" {
+		t.Errorf("user.impersonated events = %+v", events)
+	}
+	var sessions aggregate.PaginationResp[aggregate.UserSessionResp]
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/api/v1/users/"+user.UserID+"/sessions", nil, admin), &sessions)
+	var listed *aggregate.UserSessionResp
+	for i := range sessions.Items {
+		if sessions.Items[i].ID == imp.SessionID {
+			listed = &sessions.Items[i]
+		}
+	}
+	if listed == nil || listed.ImpersonatorID != "admin" || listed.ExpiresAt.After(imp.ExpiresAt.Add(time.Second)) {
+		t.Errorf("impersonation session = %+v", listed)
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderAPIKey carries a project API key on machine-to-machine requests.
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeyMiddleware is the Echo middleware that authenticates X-API-Key requests. Use NewAPIKeyMiddleware for fx injection.
+type APIKeyMiddleware echo.MiddlewareFunc
+
+// NewAPIKeyMiddleware creates the API key middleware with apiKeySvc injected by fx.
+func NewAPIKeyMiddleware(apiKeySvc service.IAPIKeySvc) APIKeyMiddleware {
+	return APIKeyMiddleware(apiKey(apiKeySvc))
+}
+
+// apiKey returns an Echo middleware that, when X-API-Key is present, authenticates it and sets the
+// key's payload on the context; VerifyJWTMiddleware then accepts the request without a Bearer token.
+// Requests without the header pass through untouched. Returns 401 for unknown, revoked or expired keys
+// and 429 once the key's usage quota is exhausted.
+func apiKey(apiKeySvc service.IAPIKeySvc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderAPIKey)
+			if key == "" {
+				return next(c)
+			}
+			payload, err := apiKeySvc.Authenticate(c.Request().Context(), key)
+			if err != nil {
+				status, message := http.StatusInternalServerError, "failed to authenticate API key"
+				var appErr *errorx.AppError
+				if errors.As(err, &appErr) && appErr.Code < 500 {
+					status, message = int(appErr.Code), appErr.Message
+				}
+				return echo.NewHTTPError(status, message)
+			}
+			ctx := context.WithValue(c.Request().Context(), constant.JWT_PAYLOAD_CONTEXT_KEY, payload)
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
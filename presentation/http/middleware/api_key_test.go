@@ -0,0 +1,68 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) { cfg.Quota.DailyRequests = 3 }))
+	project, err := h.Projects.Create(context.Background(), &model.Project{Code: "billing", Name: "Billing"})
+	if err != nil {
+		t.Fatalf("seed project: %v", err)
+	}
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+
+	resp := h.Do(t, http.MethodPost, "/api/v1/projects/"+project.ID+"/api-keys", aggregate.CreateAPIKeyReq{Name: "billing-worker"}, admin)
+	var created aggregate.CreateAPIKeyResp
+	if body := apitest.Decode(t, resp, &created); resp.StatusCode != http.StatusOK || created.Key == "" {
+		t.Fatalf("create status = %d, body = %+v", resp.StatusCode, body)
+	}
+	stored := h.APIKeys.FindOneById(context.Background(), created.ID)
+	if stored == nil || strings.Contains(created.Key, stored.SecretHash) {
+		t.Fatalf("stored key = %+v, want only the secret's digest", stored)
+	}
+
+	withKey := func(method, path, key string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, h.Server.URL+path, nil)
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := withKey(http.MethodGet, "/api/v1/permissions", created.Key); status != http.StatusOK {
+		t.Errorf("api key status = %d, want 200", status)
+	}
+	if status := withKey(http.MethodGet, "/api/v1/permissions", created.Key+"x"); status != http.StatusUnauthorized {
+		t.Errorf("wrong secret status = %d, want 401", status)
+	}
+	if status := withKey(http.MethodGet, "/api/v1/projects", created.Key); status != http.StatusForbidden {
+		t.Errorf("super admin route status = %d, want 403", status)
+	}
+	withKey(http.MethodGet, "/api/v1/permissions", created.Key)
+	if status := withKey(http.MethodGet, "/api/v1/permissions", created.Key); status != http.StatusTooManyRequests {
+		t.Errorf("over quota status = %d, want 429", status)
+	}
+
+	resp = h.Do(t, http.MethodDelete, "/api/v1/projects/"+project.ID+"/api-keys/"+created.ID, nil, admin)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("revoke status = %d", resp.StatusCode)
+	}
+	if status := withKey(http.MethodGet, "/api/v1/permissions", created.Key); status != http.StatusUnauthorized {
+		t.Errorf("revoked key status = %d, want 401", status)
+	}
+}
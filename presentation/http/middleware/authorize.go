@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/labstack/echo/v4"
+)
+
+// AccessRule declares what a caller needs to reach a route.
+// An empty rule only requires a valid JWT (enforced by VerifyJWTMiddleware).
+type AccessRule struct {
+	// SuperAdmin requires payload.IsSuperAdmin.
+	SuperAdmin bool
+	// Permission is an RBAC permission code (e.g. "users.view") the caller must hold.
+	// Super admins always pass permission checks.
+	Permission string
+	// ProjectParam is the path param holding the project ID the permission is scoped to.
+	// When empty, the permission is checked in the system project.
+	ProjectParam string
+}
+
+// AuthorizeMiddleware is the Echo middleware that enforces the RouteAccess table.
+// Must be used after VerifyJWTMiddleware so the payload is set on the context.
+type AuthorizeMiddleware echo.MiddlewareFunc
+
+// NewAuthorizeMiddleware creates the authorization middleware backed by the RouteAccess table.
+func NewAuthorizeMiddleware(roleSvc service.IRoleSvc) AuthorizeMiddleware {
+	return AuthorizeMiddleware(authorize(roleSvc, RouteAccess))
+}
+
+// routeKey builds the lookup key for a route: "<METHOD> <path pattern>".
+func routeKey(method, path string) string {
+	return method + " " + path
+}
+
+// ErrNoAccessRule is the message of the 403 returned for a route missing from the table.
+const ErrNoAccessRule = "route has no access rule"
+
+// authorize returns an Echo middleware that looks up the matched route in table and enforces its rule.
+// Routes missing from the table are refused, even to super admins, so a route added without a rule fails
+// closed rather than open.
+func authorize(roleSvc service.IRoleSvc, table map[string]AccessRule) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			payload := GetJWTPayload(c.Request().Context())
+			if payload == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing payload")
+			}
+
+			rule, ok := table[routeKey(c.Request().Method, c.Path())]
+			if !ok {
+				return echo.NewHTTPError(http.StatusForbidden, ErrNoAccessRule)
+			}
+			if payload.IsSuperAdmin {
+				return next(c)
+			}
+			if payload.IsMachine() {
+				return authorizeMachine(c, next, payload, rule)
+			}
+
+			if rule.SuperAdmin {
+				return echo.NewHTTPError(http.StatusForbidden, "super admin access required")
+			}
+
+			if rule.Permission != "" {
+				projectID := constant.SystemProjectID
+				if rule.ProjectParam != "" && c.Param(rule.ProjectParam) != "" {
+					projectID = c.Param(rule.ProjectParam)
+				}
+				permissions, err := roleSvc.GetUserPermissions(c.Request().Context(), payload.UserID)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, "failed to resolve permissions")
+				}
+				if !permissions[fmt.Sprintf("%s/%s", projectID, rule.Permission)] {
+					return echo.NewHTTPError(http.StatusForbidden, "missing permission: "+rule.Permission)
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// authorizeMachine enforces rule for an API key or service account: it never passes super-admin rules, and holds a
+// permission only through its scopes, in its own project.
+func authorizeMachine(c echo.Context, next echo.HandlerFunc, payload *jwt.Payload, rule AccessRule) error {
+	if rule.SuperAdmin {
+		return echo.NewHTTPError(http.StatusForbidden, "super admin access required")
+	}
+	if rule.Permission != "" {
+		projectID := constant.SystemProjectID
+		if rule.ProjectParam != "" && c.Param(rule.ProjectParam) != "" {
+			projectID = c.Param(rule.ProjectParam)
+		}
+		if projectID != payload.ProjectID || !slices.Contains(payload.Scopes, rule.Permission) {
+			return echo.NewHTTPError(http.StatusForbidden, "missing permission: "+rule.Permission)
+		}
+	}
+	return next(c)
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/labstack/echo/v4"
+)
+
+// fakeRoleSvc grants every user the same permissions.
+type fakeRoleSvc struct {
+	service.IRoleSvc
+	permissions aggregate.UserPermissions
+	err         error
+}
+
+func (f fakeRoleSvc) GetUserPermissions(ctx context.Context, userID string) (aggregate.UserPermissions, error) {
+	return f.permissions, f.err
+}
+
+func TestAuthorize(t *testing.T) {
+	table := map[string]AccessRule{
+		routeKey(http.MethodGet, "/open"):                 {},
+		routeKey(http.MethodGet, "/admin"):                {SuperAdmin: true},
+		routeKey(http.MethodGet, "/users"):                {Permission: "users.view"},
+		routeKey(http.MethodGet, "/projects/:id/members"): {Permission: "members.view", ProjectParam: "id"},
+	}
+	roles := fakeRoleSvc{permissions: aggregate.UserPermissions{"system/users.view": true, "p1/members.view": true}}
+	user := &jwt.Payload{UserID: "u1"}
+	admin := &jwt.Payload{UserID: "admin", IsSuperAdmin: true}
+	apiKey := &jwt.Payload{APIKeyID: "k1", ProjectID: "p1", Scopes: []string{"members.view"}}
+
+	tests := []struct {
+		name    string
+		roles   fakeRoleSvc
+		payload *jwt.Payload
+		route   string
+		path    string
+		status  int
+	}{
+		{"no payload", roles, nil, "/users", "/users", http.StatusUnauthorized},
+		{"jwt only route", roles, user, "/open", "/open", http.StatusNoContent},
+		{"unlisted route", roles, user, "/unlisted", "/unlisted", http.StatusForbidden},
+		{"unlisted route as super admin", fakeRoleSvc{}, admin, "/unlisted", "/unlisted", http.StatusForbidden},
+		{"super admin route", roles, user, "/admin", "/admin", http.StatusForbidden},
+		{"super admin", fakeRoleSvc{}, admin, "/admin", "/admin", http.StatusNoContent},
+		{"super admin passes permissions", fakeRoleSvc{}, admin, "/users", "/users", http.StatusNoContent},
+		{"system permission", roles, user, "/users", "/users", http.StatusNoContent},
+		{"project permission", roles, user, "/projects/:id/members", "/projects/p1/members", http.StatusNoContent},
+		{"other project", roles, user, "/projects/:id/members", "/projects/p2/members", http.StatusForbidden},
+		{"missing permission", fakeRoleSvc{permissions: aggregate.UserPermissions{}}, user, "/users", "/users", http.StatusForbidden},
+		{"permissions unavailable", fakeRoleSvc{err: errors.New("db down")}, user, "/users", "/users", http.StatusInternalServerError},
+		{"api key scope", roles, apiKey, "/projects/:id/members", "/projects/p1/members", http.StatusNoContent},
+		{"api key other project", roles, apiKey, "/projects/:id/members", "/projects/p2/members", http.StatusForbidden},
+		{"api key without scope", roles, apiKey, "/users", "/users", http.StatusForbidden},
+		{"api key super admin route", roles, apiKey, "/admin", "/admin", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		e := echo.New()
+		e.GET(tt.route, func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }, authorize(tt.roles, table))
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.payload != nil {
+			req = req.WithContext(context.WithValue(req.Context(), constant.JWT_PAYLOAD_CONTEXT_KEY, tt.payload))
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestRouteAccess_SuperAdminRoutes(t *testing.T) {
+	for _, route := range []string{
+		routeKey(http.MethodGet, "/api/v1/projects"),
+		routeKey(http.MethodPut, "/api/v1/relations/namespaces/:name"),
+		routeKey(http.MethodDelete, "/api/v1/relations/namespaces/:name"),
+	} {
+		if !RouteAccess[route].SuperAdmin {
+			t.Errorf("%s is not super-admin only", route)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/labstack/echo/v4"
+)
+
+// IPFilterMiddleware is the Echo middleware that applies the IP bans, the IP denylist and ADMIN_ALLOWED_CIDRS.
+// Use NewIPFilterMiddleware for fx injection.
+type IPFilterMiddleware echo.MiddlewareFunc
+
+// NewIPFilterMiddleware creates the IP filter middleware with ipFilterSvc injected by fx.
+func NewIPFilterMiddleware(ipFilterSvc service.IIPFilterSvc) IPFilterMiddleware {
+	return IPFilterMiddleware(ipFilter(ipFilterSvc, RouteAccess))
+}
+
+// ipFilter returns an Echo middleware that answers 403 to banned clients and those in the IP denylist on every
+// route, and to clients outside ADMIN_ALLOWED_CIDRS on the super-admin routes of table. It needs the client IP
+// that the request metadata middleware puts in the context, and the matched route, so it is used with Echo.Use.
+func ipFilter(ipFilterSvc service.IIPFilterSvc, table map[string]AccessRule) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			ip, _ := ctx.Value(constant.ContextKeyClientIP).(string)
+			if ip == "" {
+				ip = c.RealIP()
+			}
+			if err := ipFilterSvc.CheckBlocked(ctx, ip); err != nil {
+				return echo.NewHTTPError(http.StatusForbidden, err.Error())
+			}
+			if table[routeKey(c.Request().Method, c.Path())].SuperAdmin {
+				if err := ipFilterSvc.CheckAdmin(ctx, ip); err != nil {
+					return echo.NewHTTPError(http.StatusForbidden, err.Error())
+				}
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+)
+
+func TestIPFilter(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) { cfg.Admin.AllowedCIDRs = "10.0.0.0/8, 192.0.2.1" }))
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	do := func(method, path, ip string, body any) *http.Response {
+		var reader io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			reader = strings.NewReader(string(b))
+		}
+		req, _ := http.NewRequest(method, h.Server.URL+path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+admin)
+		req.Header.Set("X-Forwarded-For", ip)
+		resp, err := h.Server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	// The super-admin API and super-admin sign-in are limited to ADMIN_ALLOWED_CIDRS.
+	if resp := do(http.MethodGet, "/api/v1/ip-blocks", "198.51.100.7", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("super-admin route from outside status = %d, want 403", resp.StatusCode)
+	}
+	superAdminLogin := aggregate.LoginReq{AuthType: constant.UserAuthTypeSuperAdmin, Email: "root@example.com", Password: "password123"}
+	if code := apitest.Decode(t, do(http.MethodPost, "/api/v1/auth/login", "198.51.100.7", superAdminLogin), nil).Code; code != int(errorx.ErrForbidden) {
+		t.Errorf("super-admin login from outside code = %d, want ErrForbidden", code)
+	}
+	if code := apitest.Decode(t, do(http.MethodPost, "/api/v1/auth/login", "192.0.2.1", superAdminLogin), nil).Code; code == int(errorx.ErrForbidden) {
+		t.Errorf("super-admin login from an allowed address was refused")
+	}
+	if resp := do(http.MethodGet, "/api/v1/ip-blocks", "10.1.2.3", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("super-admin route from inside status = %d, want 200", resp.StatusCode)
+	}
+	// Other routes are not.
+	if resp := do(http.MethodGet, "/api/v1/me", "198.51.100.7", nil); resp.StatusCode == http.StatusForbidden {
+		t.Errorf("user route from outside status = 403, want it allowed")
+	}
+
+	// The denylist applies to every route.
+	var block aggregate.IPBlockResp
+	if code := apitest.Decode(t, do(http.MethodPost, "/api/v1/ip-blocks", "10.1.2.3", aggregate.CreateIPBlockReq{CIDR: "198.51.100.9/24", Reason: "credential stuffing"}), &block).Code; code != http.StatusOK || block.CIDR != "198.51.100.0/24" || !block.Active {
+		t.Fatalf("create block: code %d, %+v", code, block)
+	}
+	if code := apitest.Decode(t, do(http.MethodPost, "/api/v1/ip-blocks", "10.1.2.3", aggregate.CreateIPBlockReq{CIDR: "198.51.100.0/24"}), nil).Code; code != int(errorx.ErrConflict) {
+		t.Errorf("duplicate block code = %d, want ErrConflict", code)
+	}
+	if code := apitest.Decode(t, do(http.MethodPost, "/api/v1/ip-blocks", "10.1.2.3", aggregate.CreateIPBlockReq{CIDR: "10.1.0.0/16"}), nil).Code; code != int(errorx.ErrBadRequest) {
+		t.Errorf("block of the caller's own address code = %d, want ErrBadRequest", code)
+	}
+	login := aggregate.LoginReq{AuthType: constant.UserAuthTypeEmail, Email: "ana@example.com", Password: "password123"}
+	if resp := do(http.MethodPost, "/api/v1/auth/login", "198.51.100.7", login); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("login from a blocked network status = %d, want 403", resp.StatusCode)
+	}
+	if resp := do(http.MethodPost, "/api/v1/auth/login", "203.0.113.7", login); resp.StatusCode == http.StatusForbidden {
+		t.Errorf("login from another network status = 403, want it allowed")
+	}
+
+	if code := apitest.Decode(t, do(http.MethodDelete, "/api/v1/ip-blocks/"+block.ID, "10.1.2.3", nil), nil).Code; code != http.StatusOK {
+		t.Fatalf("delete block code = %d", code)
+	}
+	if resp := do(http.MethodPost, "/api/v1/auth/login", "198.51.100.7", login); resp.StatusCode == http.StatusForbidden {
+		t.Errorf("login after the block was lifted status = 403, want it allowed")
+	}
+}
+
+func TestIPBans(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) {
+		cfg.IPBan.Threshold = 3
+		cfg.Admin.AllowedCIDRs = "10.0.0.0/8"
+	}))
+	admin := h.Token(jwt.Payload{UserID: "admin", IsSuperAdmin: true})
+	do := func(method, path, ip string, body any, token string) *http.Response {
+		var reader io.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			reader = strings.NewReader(string(b))
+		}
+		req, _ := http.NewRequest(method, h.Server.URL+path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set("X-Forwarded-For", ip)
+		resp, err := h.Server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+	login := aggregate.LoginReq{AuthType: constant.UserAuthTypeEmail, Email: "nobody@example.com", Password: "password123"}
+
+	for i := range 3 {
+		if resp := do(http.MethodPost, "/api/v1/auth/login", "198.51.100.7", login, ""); resp.StatusCode == http.StatusForbidden {
+			t.Fatalf("failure %d was refused before the threshold", i+1)
+		}
+	}
+	// The third failure bans the IP from every route; other addresses are unaffected.
+	if resp := do(http.MethodGet, "/ping", "198.51.100.7", nil, ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("banned IP status = %d, want 403", resp.StatusCode)
+	}
+	if resp := do(http.MethodPost, "/api/v1/auth/login", "198.51.100.8", login, ""); resp.StatusCode == http.StatusForbidden {
+		t.Errorf("other IP status = 403, want it allowed")
+	}
+	if events := h.Events.Published(constant.EventIPBanned); len(events) != 1 || events[0].Subject != "198.51.100.7" {
+		t.Errorf("ip.banned events = %+v, want one for the banned IP", events)
+	}
+
+	var bans []aggregate.IPBanResp
+	apitest.Decode(t, do(http.MethodGet, "/api/v1/ip-bans", "10.0.0.1", nil, admin), &bans)
+	if len(bans) != 1 || bans[0].IP != "198.51.100.7" || bans[0].Failures != 3 || bans[0].Route != constant.RateLimitRouteLogin {
+		t.Fatalf("bans = %+v, want the banned IP with 3 login failures", bans)
+	}
+	if code := apitest.Decode(t, do(http.MethodDelete, "/api/v1/ip-bans/198.51.100.7", "10.0.0.1", nil, admin), nil).Code; code != http.StatusOK {
+		t.Fatalf("lift ban code = %d", code)
+	}
+	if resp := do(http.MethodGet, "/ping", "198.51.100.7", nil, ""); resp.StatusCode == http.StatusForbidden {
+		t.Errorf("IP status after the ban was lifted = 403, want it allowed")
+	}
+	if code := apitest.Decode(t, do(http.MethodDelete, "/api/v1/ip-bans/198.51.100.7", "10.0.0.1", nil, admin), nil).Code; code != int(errorx.ErrIPBanNotFound) {
+		t.Errorf("lift of a lifted ban code = %d, want ErrIPBanNotFound", code)
+	}
+
+	// Admin networks are never banned.
+	for range 4 {
+		do(http.MethodPost, "/api/v1/auth/login", "10.0.0.2", login, "")
+	}
+	if resp := do(http.MethodGet, "/ping", "10.0.0.2", nil, ""); resp.StatusCode == http.StatusForbidden {
+		t.Errorf("admin network IP was banned")
+	}
+
+	// Without a trusted proxy X-Forwarded-For is ignored: rotating it does not dodge a ban, and naming
+	// someone else's address in it does not get them banned.
+	direct := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) {
+		cfg.IPBan.Threshold = 3
+		cfg.Server.TrustedProxyCIDRs = ""
+	}))
+	h = direct
+	for i := range 3 {
+		do(http.MethodPost, "/api/v1/auth/login", fmt.Sprintf("198.51.100.%d", 20+i), login, "")
+	}
+	if resp := do(http.MethodGet, "/ping", "198.51.100.30", nil, ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status with a fresh forwarded address = %d, want the connection's address banned", resp.StatusCode)
+	}
+	for _, key := range direct.Cache.Keys() {
+		if strings.HasPrefix(key, constant.CacheKeyPrefixIPBan+"198.51.100.") {
+			t.Errorf("forwarded address was banned: %s", key)
+		}
+	}
+}
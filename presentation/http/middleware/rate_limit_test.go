@@ -0,0 +1,84 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiamthach108/dreon-auth/config"
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+)
+
+func TestRateLimit(t *testing.T) {
+	h := apitest.New(t, apitest.WithConfig(func(cfg *config.AppConfig) {
+		cfg.RateLimit.Disabled = false
+		cfg.RateLimit.Login = "ip:3/1m"
+		cfg.RateLimit.Register = "off"
+		cfg.Server.TrustedProxyCIDRs = ""
+	}))
+	now := time.Now()
+	h.Cache.SetClock(func() time.Time { return now })
+
+	// Every attempt claims a new address in X-Forwarded-For. Without a trusted proxy the header is ignored,
+	// so they all count against the connection's address.
+	attempts := 0
+	login := func() *http.Response {
+		t.Helper()
+		attempts++
+		body, _ := json.Marshal(aggregate.LoginReq{
+			AuthType: constant.UserAuthTypeEmail,
+			Email:    "nobody@example.com",
+			Password: "wrong-password",
+		})
+		req, _ := http.NewRequest(http.MethodPost, h.Server.URL+"/api/v1/auth/login", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", attempts))
+		resp, err := h.Server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+	for i := range 3 {
+		resp := login()
+		if resp.StatusCode == http.StatusTooManyRequests {
+			t.Fatalf("login %d limited", i+1)
+		}
+		if got := resp.Header.Get("X-RateLimit-Remaining"); got != fmt.Sprint(2-i) {
+			t.Errorf("login %d remaining = %q, want %d", i+1, got, 2-i)
+		}
+	}
+	resp := login()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("fourth login status = %d, want 429", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "20" {
+		t.Errorf("Retry-After = %q, want 20", got)
+	}
+
+	// One request's worth of tokens comes back every 20 seconds.
+	now = now.Add(20 * time.Second)
+	if resp := login(); resp.StatusCode == http.StatusTooManyRequests {
+		t.Errorf("login after refill status = %d", resp.StatusCode)
+	}
+	if resp := login(); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("second login after refill status = %d, want 429", resp.StatusCode)
+	}
+
+	// Routes whose policy is off are not limited.
+	for i := range 5 {
+		resp := h.Do(t, http.MethodPost, "/api/v1/auth/register", aggregate.RegisterReq{
+			Email:    fmt.Sprintf("user%d@example.com", i),
+			Password: "password123",
+		}, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("register %d status = %d, want 200", i+1, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/labstack/echo/v4"
+)
+
+// RelationMiddleware guards resource routes with relation tuples. Use NewRelationMiddleware for fx injection.
+type RelationMiddleware struct {
+	relationSvc service.IRelationSvc
+}
+
+// NewRelationMiddleware creates the relation middleware factory with relationSvc injected by fx.
+func NewRelationMiddleware(relationSvc service.IRelationSvc) *RelationMiddleware {
+	return &RelationMiddleware{relationSvc: relationSvc}
+}
+
+// RequireRelation returns an Echo middleware that only lets the caller through when the tuple
+// namespace:<objectIDParam>#relation@user:<caller> holds, e.g. RequireRelation("document", "editor", "id").
+// Must be used after VerifyJWTMiddleware. Super admins always pass; API keys and service accounts have no
+// user subject and are rejected.
+func (m *RelationMiddleware) RequireRelation(namespace, relation, objectIDParam string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			payload := GetJWTPayload(c.Request().Context())
+			if payload == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing payload")
+			}
+			if payload.IsSuperAdmin {
+				return next(c)
+			}
+			objectID := c.Param(objectIDParam)
+			if payload.IsMachine() || payload.UserID == "" || objectID == "" {
+				return relationDenied(namespace, relation)
+			}
+
+			result, err := m.relationSvc.CheckRelation(c.Request().Context(), aggregate.CheckRelationReq{
+				Namespace:        namespace,
+				ObjectID:         objectID,
+				Relation:         relation,
+				SubjectNamespace: constant.RelationNamespaceUser,
+				SubjectObjectID:  payload.UserID,
+			})
+			if errorx.GetCode(err) == errorx.ErrRateLimit {
+				return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+			}
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to check relation")
+			}
+			if !result.Allowed {
+				return relationDenied(namespace, relation)
+			}
+			return next(c)
+		}
+	}
+}
+
+func relationDenied(namespace, relation string) error {
+	return echo.NewHTTPError(http.StatusForbidden, "missing relation: "+namespace+"#"+relation)
+}
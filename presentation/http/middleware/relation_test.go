@@ -0,0 +1,76 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/model"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequireRelation(t *testing.T) {
+	h := apitest.New(t)
+	if _, err := h.RelationTuple.Create(context.Background(), &model.RelationTuple{
+		Namespace:        "document",
+		ObjectID:         "doc-1",
+		Relation:         constant.RelationEditor,
+		SubjectNamespace: constant.RelationNamespaceUser,
+		SubjectObjectID:  "user-1",
+		IsActive:         true,
+	}); err != nil {
+		t.Fatalf("seed tuple: %v", err)
+	}
+
+	e := echo.New()
+	e.PUT("/documents/:docId", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}, func(next echo.HandlerFunc) echo.HandlerFunc {
+		// Stand-in for VerifyJWTMiddleware: the test sends the payload it wants as JSON.
+		return func(c echo.Context) error {
+			var payload jwt.Payload
+			if err := json.Unmarshal([]byte(c.Request().Header.Get("X-Test-Payload")), &payload); err == nil {
+				ctx := context.WithValue(c.Request().Context(), constant.JWT_PAYLOAD_CONTEXT_KEY, &payload)
+				c.SetRequest(c.Request().WithContext(ctx))
+			}
+			return next(c)
+		}
+	}, h.Relations.RequireRelation("document", constant.RelationEditor, "docId"))
+
+	do := func(path string, payload *jwt.Payload) int {
+		req := httptest.NewRequest(http.MethodPut, path, nil)
+		if payload != nil {
+			b, _ := json.Marshal(payload)
+			req.Header.Set("X-Test-Payload", string(b))
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		payload *jwt.Payload
+		want    int
+	}{
+		{name: "editor", path: "/documents/doc-1", payload: &jwt.Payload{UserID: "user-1"}, want: http.StatusNoContent},
+		{name: "other document", path: "/documents/doc-2", payload: &jwt.Payload{UserID: "user-1"}, want: http.StatusForbidden},
+		{name: "other user", path: "/documents/doc-1", payload: &jwt.Payload{UserID: "user-2"}, want: http.StatusForbidden},
+		{name: "super admin", path: "/documents/doc-2", payload: &jwt.Payload{UserID: "admin", IsSuperAdmin: true}, want: http.StatusNoContent},
+		{name: "api key", path: "/documents/doc-1", payload: &jwt.Payload{UserID: "user-1", APIKeyID: "key-1"}, want: http.StatusForbidden},
+		{name: "unauthenticated", path: "/documents/doc-1", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := do(tt.path, tt.payload); got != tt.want {
+				t.Errorf("PUT %s = %d, want %d", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"github.com/google/uuid"
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderRequestID carries the request ID: taken from the caller when it sends one, returned on every response.
+const HeaderRequestID = echo.HeaderXRequestID
+
+// maxRequestIDLength bounds the caller-provided IDs that are kept.
+const maxRequestIDLength = 128
+
+// RequestID returns an Echo middleware that keeps the caller's X-Request-ID, or generates one when it is
+// missing or not a plain token, puts it in the request context for logger.WithContext and the services,
+// and sets it on the response.
+func RequestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			id := req.Header.Get(HeaderRequestID)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+			c.SetRequest(req.WithContext(logger.ContextWithRequestID(req.Context(), id)))
+			c.Response().Header().Set(HeaderRequestID, id)
+			return next(c)
+		}
+	}
+}
+
+// validRequestID reports whether a caller-provided ID is safe to echo and log: letters, digits and -_.: only.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/pkg/logger"
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequestID(t *testing.T) {
+	for sent, keep := range map[string]bool{
+		"req-abc.123":               true,
+		"trace:01HX_2":              true,
+		"":                          false,
+		"<script>alert(1)</script>": false,
+		"has space":                 false,
+		strings.Repeat("a", 129):    false,
+	} {
+		e := echo.New()
+		var inContext string
+		e.GET("/", func(c echo.Context) error {
+			inContext = logger.RequestIDFromContext(c.Request().Context())
+			return c.NoContent(http.StatusNoContent)
+		}, RequestID())
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(HeaderRequestID, sent)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(HeaderRequestID)
+		if id == "" || id != inContext || (id == sent) != keep {
+			t.Errorf("sent %q: response ID %q, context ID %q; want kept = %v", sent, id, inContext, keep)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import "net/http"
+
+// RouteAccess is the single place where protected routes declare their authorization requirements.
+// Keys are "<METHOD> <route path>" exactly as registered on Echo (e.g. "GET /api/v1/projects/:id").
+// Every route behind AuthorizeMiddleware must be listed: unlisted routes are refused. Use an empty rule for routes
+// that only need a valid JWT.
+var RouteAccess = map[string]AccessRule{
+	// Projects (super-admin only)
+	routeKey(http.MethodGet, "/api/v1/projects"):              {SuperAdmin: true},
+	routeKey(http.MethodGet, "/api/v1/projects/:id"):          {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/projects"):             {SuperAdmin: true},
+	routeKey(http.MethodPut, "/api/v1/projects/:id"):          {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/projects/:id"):       {SuperAdmin: true},
+	routeKey(http.MethodGet, "/api/v1/projects/:id/usage"):    {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/projects/:id/archive"): {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/projects/:id/restore"): {SuperAdmin: true},
+
+	// Project access policies (super-admin only)
+	routeKey(http.MethodGet, "/api/v1/projects/:id/access-policy"):         {SuperAdmin: true},
+	routeKey(http.MethodPut, "/api/v1/projects/:id/access-policy"):         {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/projects/:id/access-policy"):      {SuperAdmin: true},
+	routeKey(http.MethodGet, "/api/v1/projects/:id/access-policy/denials"): {SuperAdmin: true},
+
+	// Project SAML connections (super-admin only)
+	routeKey(http.MethodGet, "/api/v1/projects/:id/saml"):    {SuperAdmin: true},
+	routeKey(http.MethodPut, "/api/v1/projects/:id/saml"):    {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/projects/:id/saml"): {SuperAdmin: true},
+
+	// Project API keys (super-admin only)
+	routeKey(http.MethodGet, "/api/v1/projects/:id/api-keys"):           {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/projects/:id/api-keys"):          {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/projects/:id/api-keys/:keyId"): {SuperAdmin: true},
+
+	// Project service accounts (super-admin only)
+	routeKey(http.MethodGet, "/api/v1/projects/:id/service-accounts"):               {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/projects/:id/service-accounts"):              {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/projects/:id/service-accounts/:accountId"): {SuperAdmin: true},
+
+	// Project notification templates (super-admin only)
+	routeKey(http.MethodGet, "/api/v1/projects/:id/templates"):                {SuperAdmin: true},
+	routeKey(http.MethodPut, "/api/v1/projects/:id/templates"):                {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/projects/:id/templates/:templateId"): {SuperAdmin: true},
+
+	// The caller's own profile, devices and linked identities (JWT only)
+	routeKey(http.MethodGet, "/api/v1/me"):                         {},
+	routeKey(http.MethodPut, "/api/v1/me"):                         {},
+	routeKey(http.MethodDelete, "/api/v1/me"):                      {},
+	routeKey(http.MethodPost, "/api/v1/me/change-password"):        {},
+	routeKey(http.MethodGet, "/api/v1/me/data-export"):             {},
+	routeKey(http.MethodGet, "/api/v1/me/devices"):                 {},
+	routeKey(http.MethodPatch, "/api/v1/me/devices/:id"):           {},
+	routeKey(http.MethodDelete, "/api/v1/me/devices/:id/sessions"): {},
+	routeKey(http.MethodGet, "/api/v1/me/identities"):              {},
+	routeKey(http.MethodPost, "/api/v1/me/identities/confirm"):     {},
+	routeKey(http.MethodDelete, "/api/v1/me/identities/:id"):       {},
+
+	// User CRUD (require users.* in the system project; a hard delete also needs a super admin)
+	routeKey(http.MethodGet, "/api/v1/users"):        {Permission: "users.view"},
+	routeKey(http.MethodGet, "/api/v1/users/:id"):    {Permission: "users.view"},
+	routeKey(http.MethodPost, "/api/v1/users"):       {Permission: "users.create"},
+	routeKey(http.MethodPut, "/api/v1/users/:id"):    {Permission: "users.update"},
+	routeKey(http.MethodDelete, "/api/v1/users/:id"): {Permission: "users.delete"},
+
+	// User restore, status changes, attributes, session revocation and bulk import/export (super-admin only).
+	// Attributes can become token claims, so users must not set their own.
+	routeKey(http.MethodPost, "/api/v1/users/:id/restore"):     {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/users/:id/status"):      {SuperAdmin: true},
+	routeKey(http.MethodPatch, "/api/v1/users/:id/attributes"): {SuperAdmin: true},
+	routeKey(http.MethodGet, "/api/v1/users/:id/sessions"):     {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/users/:id/sessions"):  {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/users/import"):          {SuperAdmin: true},
+	routeKey(http.MethodGet, "/api/v1/users/import/:jobId"):    {SuperAdmin: true},
+	routeKey(http.MethodGet, "/api/v1/users/export"):           {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/users/:id/impersonate"): {SuperAdmin: true},
+
+	// Session search and sign-in stats across all users (super-admin only)
+	routeKey(http.MethodGet, "/api/v1/sessions"):       {SuperAdmin: true},
+	routeKey(http.MethodGet, "/api/v1/sessions/stats"): {SuperAdmin: true},
+
+	// Project members (super-admin only; accepting an invitation only requires a JWT)
+	routeKey(http.MethodPost, "/api/v1/projects/:id/members/accept"):    {},
+	routeKey(http.MethodGet, "/api/v1/projects/:id/members"):            {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/projects/:id/members"):           {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/projects/:id/members/:userId"): {SuperAdmin: true},
+
+	// Permission catalog (super-admin only; reading and checking only require a JWT)
+	routeKey(http.MethodGet, "/api/v1/permissions"):          {},
+	routeKey(http.MethodGet, "/api/v1/permissions/:code"):    {},
+	routeKey(http.MethodPost, "/api/v1/permissions/check"):   {},
+	routeKey(http.MethodPost, "/api/v1/permissions"):         {SuperAdmin: true},
+	routeKey(http.MethodPut, "/api/v1/permissions/:code"):    {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/permissions/:code"): {SuperAdmin: true},
+
+	// Project-defined permissions (super-admin only)
+	routeKey(http.MethodGet, "/api/v1/projects/:id/permissions"):          {SuperAdmin: true},
+	routeKey(http.MethodGet, "/api/v1/projects/:id/permissions/:code"):    {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/projects/:id/permissions"):         {SuperAdmin: true},
+	routeKey(http.MethodPut, "/api/v1/projects/:id/permissions/:code"):    {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/projects/:id/permissions/:code"): {SuperAdmin: true},
+
+	// Roles and role assignments (require roles.* in the system project; roles of the system project itself
+	// also need a super admin, which the role service checks against the stored role)
+	routeKey(http.MethodGet, "/api/v1/roles"):                          {Permission: "roles.view"},
+	routeKey(http.MethodGet, "/api/v1/roles/:id"):                      {Permission: "roles.view"},
+	routeKey(http.MethodPost, "/api/v1/roles"):                         {Permission: "roles.create"},
+	routeKey(http.MethodPut, "/api/v1/roles/:id"):                      {Permission: "roles.update"},
+	routeKey(http.MethodDelete, "/api/v1/roles/:id"):                   {Permission: "roles.delete"},
+	routeKey(http.MethodGet, "/api/v1/roles/:id/history"):              {Permission: "roles.view"},
+	routeKey(http.MethodPost, "/api/v1/roles/assign"):                  {Permission: "roles.assign"},
+	routeKey(http.MethodPost, "/api/v1/roles/bulk-assign"):             {Permission: "roles.assign"},
+	routeKey(http.MethodPost, "/api/v1/roles/remove"):                  {Permission: "roles.revoke"},
+	routeKey(http.MethodPost, "/api/v1/roles/bulk-remove"):             {Permission: "roles.revoke"},
+	routeKey(http.MethodGet, "/api/v1/roles/user/:userId/permissions"): {Permission: "roles.view"},
+	routeKey(http.MethodPost, "/api/v1/roles/users/permissions"):       {Permission: "roles.view"},
+
+	// Relation tuple writes (super-admin only)
+	routeKey(http.MethodPost, "/api/v1/relations/grant"):       {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/relations/revoke"):      {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/relations/extend"):      {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/relations/bulk-grant"):  {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/relations/bulk-revoke"): {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/relations/cleanup"):   {SuperAdmin: true},
+
+	// Relation checks and lookups (JWT only)
+	routeKey(http.MethodPost, "/api/v1/relations/check"):        {},
+	routeKey(http.MethodGet, "/api/v1/relations/list"):          {},
+	routeKey(http.MethodPost, "/api/v1/relations/expand"):       {},
+	routeKey(http.MethodPost, "/api/v1/relations/list-objects"): {},
+
+	// Relation import/export (super-admin only)
+	routeKey(http.MethodGet, "/api/v1/relations/export"):  {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/relations/import"): {SuperAdmin: true},
+
+	// Relation namespace configuration (super-admin only)
+	routeKey(http.MethodGet, "/api/v1/relations/namespaces"):          {SuperAdmin: true},
+	routeKey(http.MethodGet, "/api/v1/relations/namespaces/:name"):    {SuperAdmin: true},
+	routeKey(http.MethodPut, "/api/v1/relations/namespaces/:name"):    {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/relations/namespaces/:name"): {SuperAdmin: true},
+
+	// IP denylist and automatic IP bans (super-admin only)
+	routeKey(http.MethodGet, "/api/v1/ip-blocks"):        {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/ip-blocks"):       {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/ip-blocks/:id"): {SuperAdmin: true},
+	routeKey(http.MethodGet, "/api/v1/ip-bans"):          {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/ip-bans/:ip"):   {SuperAdmin: true},
+
+	// JWT signing keys (super-admin only)
+	routeKey(http.MethodGet, "/api/v1/signing-keys"):         {SuperAdmin: true},
+	routeKey(http.MethodPost, "/api/v1/signing-keys/rotate"): {SuperAdmin: true},
+	routeKey(http.MethodDelete, "/api/v1/signing-keys/:kid"): {SuperAdmin: true},
+}
+
+// ImpersonationBlocked lists the routes an impersonation token may not call, on top of every DELETE route:
+// they change how the user signs in, or hand out tokens that would outlive the impersonation.
+var ImpersonationBlocked = map[string]bool{
+	routeKey(http.MethodPut, "/api/v1/me"):                         true,
+	routeKey(http.MethodPost, "/api/v1/me/change-password"):        true,
+	routeKey(http.MethodPost, "/api/v1/me/identities/confirm"):     true,
+	routeKey(http.MethodPost, "/api/v1/auth/mfa/totp/enable"):      true,
+	routeKey(http.MethodPost, "/api/v1/auth/mfa/totp/confirm"):     true,
+	routeKey(http.MethodPost, "/api/v1/auth/mfa/totp/disable"):     true,
+	routeKey(http.MethodPost, "/api/v1/auth/device/verify"):        true,
+	routeKey(http.MethodPost, "/api/v1/oauth2/authorize/complete"): true,
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/hiamthach108/dreon-auth/internal/errorx"
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/shared/scim"
+	"github.com/labstack/echo/v4"
+)
+
+// SCIMAuthMiddleware is the Echo middleware that authenticates SCIM clients. Use NewSCIMAuthMiddleware for fx injection.
+type SCIMAuthMiddleware echo.MiddlewareFunc
+
+// NewSCIMAuthMiddleware creates the SCIM authentication middleware with apiKeySvc injected by fx.
+func NewSCIMAuthMiddleware(apiKeySvc service.IAPIKeySvc) SCIMAuthMiddleware {
+	return SCIMAuthMiddleware(scimAuth(apiKeySvc))
+}
+
+// scimAuth returns an Echo middleware that requires a project API key holding the scim.provision scope.
+// Identity providers send the key as "Authorization: Bearer <key>"; X-API-Key, already authenticated by
+// APIKeyMiddleware, works too. The key's project is the one provisioned. Failures are SCIM errors.
+func scimAuth(apiKeySvc service.IAPIKeySvc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			payload := GetJWTPayload(ctx)
+			if payload == nil {
+				key, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+				if !ok || strings.TrimSpace(key) == "" {
+					return scimAuthError(c, http.StatusUnauthorized, "missing API key")
+				}
+				var err error
+				payload, err = apiKeySvc.Authenticate(ctx, strings.TrimSpace(key))
+				if err != nil {
+					status, detail := http.StatusInternalServerError, "failed to authenticate API key"
+					var appErr *errorx.AppError
+					if errors.As(err, &appErr) && appErr.Code < 500 {
+						status, detail = int(appErr.Code), appErr.Message
+					}
+					return scimAuthError(c, status, detail)
+				}
+				ctx = context.WithValue(ctx, constant.JWT_PAYLOAD_CONTEXT_KEY, payload)
+				c.SetRequest(c.Request().WithContext(ctx))
+			}
+			if payload.APIKeyID == "" || payload.ProjectID == "" {
+				return scimAuthError(c, http.StatusUnauthorized, "SCIM requires a project API key")
+			}
+			if !slices.Contains(payload.Scopes, scim.ProvisionScope) {
+				return scimAuthError(c, http.StatusForbidden, "missing permission: "+scim.ProvisionScope)
+			}
+			return next(c)
+		}
+	}
+}
+
+func scimAuthError(c echo.Context, status int, detail string) error {
+	c.Response().Header().Set(echo.HeaderContentType, scim.ContentType)
+	return c.JSON(status, scim.NewError(status, "", detail))
+}
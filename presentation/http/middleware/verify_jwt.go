@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/hiamthach108/dreon-auth/internal/service"
 	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
 	"github.com/hiamthach108/dreon-auth/pkg/jwt"
 	"github.com/labstack/echo/v4"
@@ -13,45 +14,61 @@ import (
 // VerifyJWTMiddleware is the Echo middleware that validates JWT. Use NewVerifyJWTMiddleware for fx injection.
 type VerifyJWTMiddleware echo.MiddlewareFunc
 
-// NewVerifyJWTMiddleware creates the JWT verification middleware with jwtManager injected by fx.
+// NewVerifyJWTMiddleware creates the JWT verification middleware with jwtManager and revocations injected by fx.
 // Register in fx.Provide(middleware.NewVerifyJWTMiddleware) and inject VerifyJWTMiddleware where needed.
-func NewVerifyJWTMiddleware(jwtManager jwt.IJwtTokenManager) VerifyJWTMiddleware {
-	return VerifyJWTMiddleware(verifyJWT(jwtManager))
+func NewVerifyJWTMiddleware(jwtManager jwt.IJwtTokenManager, revocations service.ITokenRevocationSvc) VerifyJWTMiddleware {
+	return VerifyJWTMiddleware(verifyJWT(jwtManager, revocations))
 }
 
 // verifyJWT returns an Echo middleware that validates the Bearer JWT and sets the payload on the context.
-// Expects "Authorization: Bearer <token>". Returns 401 when the header is missing or the token is invalid.
-func verifyJWT(jwtManager jwt.IJwtTokenManager) echo.MiddlewareFunc {
+// Expects "Authorization: Bearer <token>". Returns 401 when the header is missing, the token is invalid,
+// its jti was revoked or its session has ended (so users who were blocked, deactivated or signed out lose
+// access at once), 403 when an impersonation token calls a DELETE or ImpersonationBlocked route, and 503
+// when the revocation or session status cannot be checked.
+// Requests already authenticated by APIKeyMiddleware pass through.
+func verifyJWT(jwtManager jwt.IJwtTokenManager, revocations service.ITokenRevocationSvc) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			if p := GetJWTPayload(c.Request().Context()); p != nil && p.APIKeyID != "" {
+				return next(c)
+			}
 			auth := c.Request().Header.Get(echo.HeaderAuthorization)
 			if auth == "" {
-				return echo.NewHTTPError(http.StatusUnauthorized, echo.Map{
-					"message": "missing authorization header",
-					"code":    http.StatusUnauthorized,
-				})
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing authorization header")
 			}
 			const prefix = "Bearer "
 			if !strings.HasPrefix(auth, prefix) {
-				return echo.NewHTTPError(http.StatusUnauthorized, echo.Map{
-					"message": "invalid authorization format",
-					"code":    http.StatusUnauthorized,
-				})
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid authorization format")
 			}
 			tokenString := strings.TrimSpace(auth[len(prefix):])
 			if tokenString == "" {
-				return echo.NewHTTPError(http.StatusUnauthorized, echo.Map{
-					"message": "missing token",
-					"code":    http.StatusUnauthorized,
-				})
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing token")
 			}
 
 			payload, err := jwtManager.Verify(c.Request().Context(), tokenString)
 			if err != nil {
-				return echo.NewHTTPError(http.StatusUnauthorized, echo.Map{
-					"message": err.Error(),
-					"code":    http.StatusUnauthorized,
-				})
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+			if payload.TokenID != "" {
+				revoked, err := revocations.IsRevoked(c.Request().Context(), payload.TokenID)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusServiceUnavailable, "token revocation status unavailable")
+				}
+				if revoked {
+					return echo.NewHTTPError(http.StatusUnauthorized, "token has been revoked")
+				}
+			}
+			if payload.SessionID != "" {
+				ended, err := revocations.IsSessionEnded(c.Request().Context(), payload.SessionID)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusServiceUnavailable, "session status unavailable")
+				}
+				if ended {
+					return echo.NewHTTPError(http.StatusUnauthorized, "session has ended")
+				}
+			}
+			if payload.Impersonator != nil && (c.Request().Method == http.MethodDelete || ImpersonationBlocked[routeKey(c.Request().Method, c.Path())]) {
+				return echo.NewHTTPError(http.StatusForbidden, "not allowed while impersonating")
 			}
 			ctx := context.WithValue(c.Request().Context(), constant.JWT_PAYLOAD_CONTEXT_KEY, payload)
 			c.SetRequest(c.Request().WithContext(ctx))
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/service"
+	"github.com/hiamthach108/dreon-auth/internal/testutil"
+	"github.com/hiamthach108/dreon-auth/pkg/jwt"
+	"github.com/labstack/echo/v4"
+)
+
+// fakeRevocations answers IsRevoked and IsSessionEnded from its fields.
+type fakeRevocations struct {
+	service.ITokenRevocationSvc
+	revoked, ended map[string]bool
+	err            error
+}
+
+func (f *fakeRevocations) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return f.revoked[jti], f.err
+}
+
+func (f *fakeRevocations) IsSessionEnded(ctx context.Context, sessionID string) (bool, error) {
+	return f.ended[sessionID], f.err
+}
+
+// serve runs a request to method path through mw and returns the status and the payload the route saw.
+func serve(t *testing.T, mw echo.MiddlewareFunc, method, path string, header http.Header) (int, *jwt.Payload) {
+	t.Helper()
+	e := echo.New()
+	var seen *jwt.Payload
+	e.Add(method, path, func(c echo.Context) error {
+		seen = GetJWTPayload(c.Request().Context())
+		return c.NoContent(http.StatusNoContent)
+	}, mw)
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code, seen
+}
+
+func TestVerifyJWT(t *testing.T) {
+	tokens := testutil.NewJwtTokenManager()
+	revocations := &fakeRevocations{revoked: map[string]bool{}, ended: map[string]bool{}}
+	mw := verifyJWT(tokens, revocations)
+	bearer := func(token string) http.Header { return http.Header{"Authorization": {"Bearer " + token}} }
+
+	valid := tokens.Issue(jwt.Payload{UserID: "u1", SessionID: "s1"})
+	if code, payload := serve(t, mw, http.MethodGet, "/me", bearer(valid)); code != http.StatusNoContent || payload == nil || payload.UserID != "u1" {
+		t.Fatalf("valid token: status %d, payload %+v", code, payload)
+	}
+
+	for name, header := range map[string]http.Header{
+		"missing header": nil,
+		"not bearer":     {"Authorization": {"Basic dTE6cHc="}},
+		"empty token":    bearer(""),
+		"unknown token":  bearer("not-a-token"),
+	} {
+		if code, _ := serve(t, mw, http.MethodGet, "/me", header); code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want 401", name, code)
+		}
+	}
+
+	revoked := tokens.Issue(jwt.Payload{UserID: "u1"})
+	payload, _ := tokens.Verify(context.Background(), revoked)
+	revocations.revoked[payload.TokenID] = true
+	if code, _ := serve(t, mw, http.MethodGet, "/me", bearer(revoked)); code != http.StatusUnauthorized {
+		t.Errorf("revoked token: status %d, want 401", code)
+	}
+	revocations.ended["s1"] = true
+	if code, _ := serve(t, mw, http.MethodGet, "/me", bearer(valid)); code != http.StatusUnauthorized {
+		t.Errorf("ended session: status %d, want 401", code)
+	}
+
+	// Fails closed when revocations cannot be checked.
+	revocations.err = errors.New("redis down")
+	if code, _ := serve(t, mw, http.MethodGet, "/me", bearer(tokens.Issue(jwt.Payload{UserID: "u2"}))); code != http.StatusServiceUnavailable {
+		t.Errorf("revocation lookup failing: status %d, want 503", code)
+	}
+	revocations.err = nil
+
+	impersonating := tokens.Issue(jwt.Payload{UserID: "u3", Impersonator: &jwt.Impersonator{ID: "admin"}})
+	if code, _ := serve(t, mw, http.MethodGet, "/api/v1/me", bearer(impersonating)); code != http.StatusNoContent {
+		t.Errorf("impersonation read: status %d, want 204", code)
+	}
+	for _, route := range [][2]string{{http.MethodDelete, "/api/v1/me/sessions"}, {http.MethodPut, "/api/v1/me"}} {
+		if code, _ := serve(t, mw, route[0], route[1], bearer(impersonating)); code != http.StatusForbidden {
+			t.Errorf("impersonation %s %s: status %d, want 403", route[0], route[1], code)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+)
+
+func TestServer_RequestID(t *testing.T) {
+	h := apitest.New(t)
+	send := func(path, requestID string, body any) *http.Response {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPost, h.Server.URL+path, strings.NewReader(string(b)))
+		req.Header.Set("Content-Type", "application/json")
+		if requestID != "" {
+			req.Header.Set("X-Request-ID", requestID)
+		}
+		resp, err := h.Server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	// The caller's ID is kept and recorded on the session.
+	resp := send("/api/v1/auth/register", "req-abc.123", aggregate.RegisterReq{Email: "rid@example.com", Password: "password123"})
+	var tokens aggregate.TokenResp
+	apitest.Decode(t, resp, &tokens)
+	if got := resp.Header.Get("X-Request-Id"); got != "req-abc.123" {
+		t.Fatalf("X-Request-Id = %q, want the caller's ID", got)
+	}
+	var meta map[string]any
+	if session := h.Sessions.FindOneById(context.Background(), tokens.SessionID); session == nil || json.Unmarshal(session.Metadata, &meta) != nil || meta["request_id"] != "req-abc.123" {
+		t.Errorf("session metadata = %v, want request_id req-abc.123", meta)
+	}
+
+	// A missing or unsafe ID is replaced, and errors carry it in the body.
+	for _, sent := range []string{"", "<script>alert(1)</script>"} {
+		resp = send("/api/v1/auth/login", sent, aggregate.LoginReq{AuthType: constant.UserAuthTypeEmail, Email: "rid@example.com", Password: "wrong-password"})
+		id := resp.Header.Get("X-Request-Id")
+		if id == "" || id == sent {
+			t.Fatalf("X-Request-Id = %q for sent %q, want a generated ID", id, sent)
+		}
+		if body := apitest.Decode(t, resp, nil); body.RequestID != id {
+			t.Errorf("error body requestId = %q, want %q", body.RequestID, id)
+		}
+	}
+
+	// So do errors returned by middlewares.
+	resp = h.Do(t, http.MethodGet, "/api/v1/auth/session", nil, "")
+	if body := apitest.Decode(t, resp, nil); resp.StatusCode != http.StatusUnauthorized || body.RequestID == "" || body.RequestID != resp.Header.Get("X-Request-Id") {
+		t.Errorf("unauthenticated status = %d, requestId = %q", resp.StatusCode, body.RequestID)
+	}
+}
@@ -2,7 +2,11 @@ package http
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/hiamthach108/dreon-auth/config"
@@ -10,6 +14,7 @@ import (
 	"github.com/hiamthach108/dreon-auth/pkg/logger"
 	"github.com/hiamthach108/dreon-auth/pkg/validator"
 	"github.com/hiamthach108/dreon-auth/presentation/http/handler"
+	echomw "github.com/hiamthach108/dreon-auth/presentation/http/middleware"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"go.uber.org/fx"
@@ -30,27 +35,53 @@ func NewHttpServer(
 	relationHandler *handler.RelationHandler,
 	roleHandler *handler.RoleHandler,
 	permissionHandler *handler.PermissionHandler,
-) *HttpServer {
+	credentialHandler *handler.CredentialHandler,
+	accessPolicyHandler *handler.AccessPolicyHandler,
+	trustedDeviceHandler *handler.TrustedDeviceHandler,
+	samlHandler *handler.SAMLHandler,
+	signingKeyHandler *handler.SigningKeyHandler,
+	oidcProviderHandler *handler.OIDCProviderHandler,
+	apiKeyHandler *handler.APIKeyHandler,
+	serviceAccountHandler *handler.ServiceAccountHandler,
+	projectMemberHandler *handler.ProjectMemberHandler,
+	notificationTemplateHandler *handler.NotificationTemplateHandler,
+	userIdentityHandler *handler.UserIdentityHandler,
+	userDeviceHandler *handler.UserDeviceHandler,
+	sessionHandler *handler.SessionHandler,
+	scimHandler *handler.SCIMHandler,
+	healthHandler *handler.HealthHandler,
+	ipBlockHandler *handler.IPBlockHandler,
+	apiKeyMiddleware echomw.APIKeyMiddleware,
+	ipFilterMiddleware echomw.IPFilterMiddleware,
+) (*HttpServer, error) {
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
+	// Client IPs decide the IP filters, bans, rate limits and project network policies, so forwarded headers
+	// are only believed from the proxies in TRUSTED_PROXY_CIDRS
+	ipExtractor, err := clientIPExtractor(config.Server.TrustedProxyCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	trustedProxies, err := parseTrustedProxies(config.Server.TrustedProxyCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	e.IPExtractor = ipExtractor
 	e.Validator = validator.New()
-	// Inject request metadata (ip, user_agent, referer) into context for all routes
-	e.Use(requestMetadataMiddleware)
+	e.HTTPErrorHandler = handler.HTTPErrorHandler
+	// Start the request span before anything else so the rest of the chain is traced
+	e.Use(echomw.Tracing())
+	// Tag the request with its X-Request-ID for log lines, error responses and audit records
+	e.Use(echomw.RequestID())
+	// Inject request metadata (ip, user_agent, referer, client hints, country) into context for all routes
+	e.Use(requestMetadataMiddleware(config.AccessPolicy.CountryHeader, trustedProxies))
 	// Use middleware with your logger
-	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			logger.Info("Request",
-				"ip", c.RealIP(),
-				"method", c.Request().Method,
-				"path", c.Request().URL.Path,
-				"user-agent", c.Request().UserAgent(),
-				"referer", c.Request().Referer(),
-			)
-			return next(c)
-		}
-	})
+	e.Use(requestLogMiddleware(logger))
 	e.Use(middleware.Recover())
+	// Turn away banned clients and those in the IP denylist, and clients outside ADMIN_ALLOWED_CIDRS on
+	// super-admin routes
+	e.Use(echo.MiddlewareFunc(ipFilterMiddleware))
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"*"},
 		AllowHeaders: []string{
@@ -65,8 +96,13 @@ func NewHttpServer(
 			echo.HeaderCacheControl,
 			echo.HeaderContentLength,
 			echo.HeaderUpgrade,
+			echomw.HeaderAPIKey,
+			echomw.HeaderRequestID,
+			"traceparent",
+			"tracestate",
 		},
-		AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		ExposeHeaders: []string{echomw.HeaderTraceID, echomw.HeaderRequestID},
+		AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 	}))
 	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -74,41 +110,165 @@ func NewHttpServer(
 			return next(c)
 		}
 	})
+	// Authenticate X-API-Key callers before any route's JWT check
+	e.Use(echo.MiddlewareFunc(apiKeyMiddleware))
 
-	// Healthcheck route
-	e.GET("/ping", func(c echo.Context) error {
-		return c.JSON(http.StatusOK, echo.Map{
-			"code":    http.StatusOK,
-			"message": "pong",
-		})
-	})
+	// Liveness, health and readiness probes
+	healthHandler.RegisterRoutes(e.Group(""))
+
+	wellKnown := e.Group("/.well-known")
+	signingKeyHandler.RegisterWellKnownRoutes(wellKnown)
+	oidcProviderHandler.RegisterWellKnownRoutes(wellKnown)
 
 	v1 := e.Group("/api/v1")
 
 	// Register user routes (middleware applied inside RegisterRoutes)
 	userHandler.RegisterRoutes(v1.Group("/users"))
-	authHandler.RegisterRoutes(v1.Group("/auth"))
+	userHandler.RegisterMeRoutes(v1.Group("/me"))
+	userIdentityHandler.RegisterRoutes(v1.Group("/me/identities"))
+	userDeviceHandler.RegisterRoutes(v1.Group("/me/devices"))
+	sessionHandler.RegisterRoutes(v1.Group("/sessions"))
+	auth := v1.Group("/auth")
+	serviceAccountHandler.RegisterTokenRoutes(auth)
+	authHandler.RegisterRoutes(auth)
 	projectHandler.RegisterRoutes(v1.Group("/projects"))
 	relationHandler.RegisterRoutes(v1.Group("/relations"))
 	roleHandler.RegisterRoutes(v1.Group("/roles"))
 	permissionHandler.RegisterRoutes(v1.Group("/permissions"))
+	permissionHandler.RegisterProjectRoutes(v1.Group("/projects/:id/permissions"))
+	credentialHandler.RegisterRoutes(v1.Group("/credentials"))
+	accessPolicyHandler.RegisterRoutes(v1.Group("/projects/:id/access-policy"))
+	trustedDeviceHandler.RegisterRoutes(v1.Group("/trusted-devices"))
+	samlHandler.RegisterRoutes(v1.Group("/saml"))
+	samlHandler.RegisterConnectionRoutes(v1.Group("/projects/:id/saml"))
+	signingKeyHandler.RegisterRoutes(v1.Group("/signing-keys"))
+	ipBlockHandler.RegisterRoutes(v1.Group("/ip-blocks"))
+	ipBlockHandler.RegisterBanRoutes(v1.Group("/ip-bans"))
+	oidcProviderHandler.RegisterRoutes(v1.Group("/oauth2"))
+	apiKeyHandler.RegisterRoutes(v1.Group("/projects/:id/api-keys"))
+	serviceAccountHandler.RegisterRoutes(v1.Group("/projects/:id/service-accounts"))
+	projectMemberHandler.RegisterRoutes(v1.Group("/projects/:id/members"))
+	notificationTemplateHandler.RegisterRoutes(v1.Group("/projects/:id/templates"))
+
+	// SCIM 2.0 provisioning for identity providers, at the path they expect
+	scimHandler.RegisterRoutes(e.Group("/scim/v2"))
 
 	return &HttpServer{
 		config: *config,
 		logger: logger,
 		echo:   e,
+	}, nil
+}
+
+// parseTrustedProxies parses TRUSTED_PROXY_CIDRS: comma-separated CIDRs or single addresses.
+func parseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXY_CIDRS entry %q: %w", value, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix)
 	}
+	return prefixes, nil
 }
 
-// requestMetadataMiddleware adds IP, User-Agent, and Referer to the request context for all HTTP routes.
-func requestMetadataMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		ctx := c.Request().Context()
-		ctx = context.WithValue(ctx, constant.ContextKeyClientIP, c.RealIP())
-		ctx = context.WithValue(ctx, constant.ContextKeyUserAgent, c.Request().UserAgent())
-		ctx = context.WithValue(ctx, constant.ContextKeyReferer, c.Request().Referer())
-		c.SetRequest(c.Request().WithContext(ctx))
-		return next(c)
+// clientIPExtractor returns how RealIP finds the client: the connection's peer address, or, when requests
+// come through the comma-separated trusted proxies (CIDRs or addresses), the last X-Forwarded-For entry
+// that none of them added. Entries a client writes itself sit before those and are never reached.
+func clientIPExtractor(trustedProxies string) (echo.IPExtractor, error) {
+	prefixes, err := parseTrustedProxies(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefixes) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+	options := make([]echo.TrustOption, 0, len(prefixes)+3)
+	for _, prefix := range prefixes {
+		options = append(options, echo.TrustIPRange(&net.IPNet{
+			IP:   prefix.Masked().Addr().AsSlice(),
+			Mask: net.CIDRMask(prefix.Bits(), prefix.Addr().BitLen()),
+		}))
+	}
+	// Only the listed proxies: Echo otherwise also trusts every loopback, link-local and private address.
+	options = append(options, echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false))
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
+// fromTrustedProxy reports whether r arrived directly from one of the trusted proxies.
+func fromTrustedProxy(r *http.Request, trustedProxies []netip.Prefix) bool {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := peer.Addr().Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Routes lists every route registered on the server.
+func (s *HttpServer) Routes() []*echo.Route {
+	return s.echo.Routes()
+}
+
+// ServeHTTP lets the server be mounted directly on an httptest.Server or any other http.Handler consumer.
+func (s *HttpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.echo.ServeHTTP(w, r)
+}
+
+// requestLogMiddleware logs every request with its request ID and the trace ID of its span.
+func requestLogMiddleware(log logger.ILogger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			logger.WithContext(c.Request().Context(), log).Info("Request",
+				"ip", c.RealIP(),
+				"method", c.Request().Method,
+				"path", c.Request().URL.Path,
+				"user-agent", c.Request().UserAgent(),
+				"referer", c.Request().Referer(),
+			)
+			return next(c)
+		}
+	}
+}
+
+// requestMetadataMiddleware adds IP, User-Agent, Referer, the User-Agent client hints and the proxy-provided country to the
+// request context for all HTTP routes. The country is left empty unless the request came straight from a trusted proxy,
+// since anyone else can write the header.
+func requestMetadataMiddleware(countryHeader string, trustedProxies []netip.Prefix) echo.MiddlewareFunc {
+	if countryHeader == "" {
+		countryHeader = "CF-IPCountry"
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			ctx = context.WithValue(ctx, constant.ContextKeyClientIP, c.RealIP())
+			ctx = context.WithValue(ctx, constant.ContextKeyUserAgent, c.Request().UserAgent())
+			ctx = context.WithValue(ctx, constant.ContextKeyReferer, c.Request().Referer())
+			var country string
+			if fromTrustedProxy(c.Request(), trustedProxies) {
+				country = c.Request().Header.Get(countryHeader)
+			}
+			ctx = context.WithValue(ctx, constant.ContextKeyCountry, country)
+			ctx = context.WithValue(ctx, constant.ContextKeyClientHintBrands, c.Request().Header.Get("Sec-CH-UA"))
+			ctx = context.WithValue(ctx, constant.ContextKeyClientHintPlatform, c.Request().Header.Get("Sec-CH-UA-Platform"))
+			ctx = context.WithValue(ctx, constant.ContextKeyClientHintMobile, c.Request().Header.Get("Sec-CH-UA-Mobile"))
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
 	}
 }
 
@@ -124,11 +284,20 @@ func RegisterHooks(lc fx.Lifecycle, server *HttpServer) {
 			}()
 			return nil
 		},
+		// Stop accepting connections and let in-flight requests finish, up to SHUTDOWN_TIMEOUT_SEC.
 		OnStop: func(ctx context.Context) error {
-			server.logger.Info("Shutting down HTTP server...")
-			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			timeout := constant.DefaultShutdownTimeout
+			if server.config.Server.ShutdownTimeoutSec > 0 {
+				timeout = time.Duration(server.config.Server.ShutdownTimeoutSec) * time.Second
+			}
+			server.logger.Info("Shutting down HTTP server...", "timeout", timeout)
+			ctx, cancel := context.WithTimeout(ctx, timeout)
 			defer cancel()
-			return server.echo.Shutdown(ctx)
+			if err := server.echo.Shutdown(ctx); err != nil {
+				server.logger.Warn("Closing HTTP connections with requests still in flight", "error", err)
+				return server.echo.Close()
+			}
+			return nil
 		},
 	})
 }
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/shared/constant"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIPExtractor(t *testing.T) {
+	request := func(peer, xff string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = peer + ":40000"
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		req.Header.Set("X-Real-IP", "198.51.100.99")
+		return req
+	}
+
+	tests := []struct {
+		name, trusted, peer, xff, want string
+	}{
+		{"no trusted proxies ignores the headers", "", "203.0.113.5", "198.51.100.1", "203.0.113.5"},
+		{"private peers are not trusted by default", "", "10.0.0.2", "198.51.100.1", "10.0.0.2"},
+		{"trusted proxy forwards the client", "10.0.0.0/8", "10.0.0.2", "198.51.100.1", "198.51.100.1"},
+		{"entries the client wrote are skipped", "10.0.0.0/8", "10.0.0.2", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"chained trusted proxies", "10.0.0.0/8, 192.0.2.7", "10.0.0.2", "198.51.100.1, 192.0.2.7", "198.51.100.1"},
+		{"untrusted peer is the client", "10.0.0.0/8", "203.0.113.5", "198.51.100.1", "203.0.113.5"},
+		{"loopback is only trusted when listed", "10.0.0.0/8", "127.0.0.1", "198.51.100.1", "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extract, err := clientIPExtractor(tt.trusted)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, extract(request(tt.peer, tt.xff)))
+		})
+	}
+
+	_, err := clientIPExtractor("10.0.0.0/8, proxy.internal")
+	assert.Error(t, err)
+}
+
+func TestRequestMetadataCountry(t *testing.T) {
+	trusted, err := parseTrustedProxies("10.0.0.0/8")
+	require.NoError(t, err)
+	country := func(peer string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = peer + ":40000"
+		req.Header.Set("CF-IPCountry", "KP")
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		var got string
+		_ = requestMetadataMiddleware("", trusted)(func(c echo.Context) error {
+			got, _ = c.Request().Context().Value(constant.ContextKeyCountry).(string)
+			return nil
+		})(c)
+		return got
+	}
+
+	assert.Equal(t, "KP", country("10.0.0.2"), "header from a trusted proxy")
+	assert.Equal(t, "", country("203.0.113.5"), "header written by the client")
+}
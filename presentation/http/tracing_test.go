@@ -0,0 +1,59 @@
+package http_test
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hiamthach108/dreon-auth/internal/aggregate"
+	"github.com/hiamthach108/dreon-auth/internal/testutil/apitest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestServer_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	h := apitest.New(t)
+
+	const callerTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	body, _ := json.Marshal(aggregate.RegisterReq{Email: "trace@example.com", Password: "password123"})
+	req, _ := http.NewRequest(http.MethodPost, h.Server.URL+"/api/v1/auth/register", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+callerTraceID+"-00f067aa0ba902b7-01")
+	resp, err := h.Server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Trace-Id"); got != callerTraceID {
+		t.Fatalf("X-Trace-Id = %q, want the caller's trace %s", got, callerTraceID)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	server, ok := spans["POST /api/v1/auth/register"]
+	if !ok {
+		t.Fatalf("no server span, got %v", slices.Collect(maps.Keys(spans)))
+	}
+	svc, ok := spans["AuthSvc.Register"]
+	if !ok || svc.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatalf("AuthSvc.Register span missing or not a child of the server span")
+	}
+	if svc.SpanContext().TraceID().String() != callerTraceID {
+		t.Errorf("service span trace = %s, want %s", svc.SpanContext().TraceID(), callerTraceID)
+	}
+}